@@ -0,0 +1,50 @@
+// mock-mcp-server 启动按脚本响应的 MCP 远程服务器，用于插件开发和集成测试
+package main
+
+import (
+	"flag"
+	"log"
+	"net"
+	"net/http"
+
+	"github.com/liangsj/vimcoplit/internal/core/mcp"
+	"github.com/liangsj/vimcoplit/internal/testutil/mockmcp"
+)
+
+func main() {
+	addr := flag.String("addr", "localhost:8931", "listen address")
+	toolsPath := flag.String("tools", "", "JSON file with tool specs and scripted responses")
+	configPath := flag.String("config", "", "write an MCP config registering this server to the given path")
+	serverID := flag.String("id", "mock", "server id used in the written MCP config")
+	flag.Parse()
+
+	specs := []mockmcp.ToolSpec{{Tool: mcp.Tool{
+		ID:          "echo",
+		Name:        "echo",
+		Description: "returns its params",
+		Parameters:  []mcp.ToolParameter{{Name: "text", Type: "string"}},
+	}}}
+	if *toolsPath != "" {
+		var err error
+		if specs, err = mockmcp.LoadSpecs(*toolsPath); err != nil {
+			log.Fatalf("读取工具定义失败: %v", err)
+		}
+	}
+	server := mockmcp.New(specs...)
+
+	listener, err := net.Listen("tcp", *addr)
+	if err != nil {
+		log.Fatalf("监听失败: %v", err)
+	}
+	baseURL := "http://" + listener.Addr().String()
+	if *configPath != "" {
+		if err := server.WriteConfig(*configPath, *serverID, baseURL); err != nil {
+			log.Fatalf("写入 MCP 配置失败: %v", err)
+		}
+	}
+
+	log.Printf("模拟 MCP 服务器已启动: %s\n", baseURL)
+	if err := http.Serve(listener, server); err != nil {
+		log.Fatalf("服务器错误: %v", err)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/liangsj/vimcoplit/internal/backup"
+	"github.com/liangsj/vimcoplit/internal/config"
+	"github.com/liangsj/vimcoplit/internal/core"
+	"github.com/liangsj/vimcoplit/internal/i18n"
+)
+
+// runBackup 将配置、MCP 定义和数据目录打包为一个归档
+//
+// 用法: vimcoplit backup [-config path] [-o file]
+func runBackup(args []string) error {
+	fs := flag.NewFlagSet("backup", flag.ExitOnError)
+	configPath := fs.String("config", "", i18n.T("cli.flag_config"))
+	output := fs.String("o", "", i18n.T("cli.flag_output"))
+	fs.Parse(args)
+
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+
+	if *output == "" {
+		*output = fmt.Sprintf("vimcoplit-backup-%s.tar.gz", time.Now().Format("20060102-150405"))
+	}
+	f, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	manifest, err := backup.Create(f, core.BackupSources(cfg), nil)
+	if err != nil {
+		os.Remove(*output)
+		return err
+	}
+	log.Println(i18n.T("cli.backup_done", *output, len(manifest.Files)))
+	return nil
+}
+
+// runRestore 从归档恢复配置、MCP 定义和数据目录，恢复前应先停止服务
+//
+// 用法: vimcoplit restore [-config path] file
+func runRestore(args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	configPath := fs.String("config", "", i18n.T("cli.flag_config"))
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		return i18n.Error("cli.restore_usage")
+	}
+
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	manifest, _, err := backup.Extract(f, core.BackupSources(cfg))
+	if err != nil {
+		return err
+	}
+	log.Println(i18n.T("cli.restore_done", manifest.CreatedAt.Format(time.RFC3339), len(manifest.Files)))
+	return nil
+}
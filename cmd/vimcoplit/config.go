@@ -0,0 +1,44 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/liangsj/vimcoplit/internal/config"
+	"github.com/liangsj/vimcoplit/internal/i18n"
+)
+
+// runConfig 显示叠加各层配置后生效的配置，-origin 时同时显示每个配置项的来源
+//
+// 用法: vimcoplit config show [-config path] [-origin]
+func runConfig(args []string) error {
+	if len(args) == 0 || args[0] != "show" {
+		return i18n.Error("cli.config_usage")
+	}
+	fs := flag.NewFlagSet("config show", flag.ExitOnError)
+	configPath := fs.String("config", "", i18n.T("cli.flag_config"))
+	origin := fs.Bool("origin", false, i18n.T("cli.flag_origin"))
+	fs.Parse(args[1:])
+
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, v := range cfg.Values() {
+		value, _ := json.Marshal(v.Value)
+		if config.IsSecret(v.Key) && v.Origin != config.OriginDefault {
+			value = []byte(`"***"`)
+		}
+		if *origin {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", v.Key, value, v.Origin)
+		} else {
+			fmt.Fprintf(w, "%s\t%s\n", v.Key, value)
+		}
+	}
+	return w.Flush()
+}
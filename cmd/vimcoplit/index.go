@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/liangsj/vimcoplit/internal/config"
+	"github.com/liangsj/vimcoplit/internal/core"
+	"github.com/liangsj/vimcoplit/internal/core/index"
+	"github.com/liangsj/vimcoplit/internal/i18n"
+)
+
+// runIndex 查看或重建代码检索索引，重建前应先停止服务
+//
+// 用法: vimcoplit index [-config path] stats|rebuild
+func runIndex(args []string) error {
+	fs := flag.NewFlagSet("index", flag.ExitOnError)
+	configPath := fs.String("config", "", i18n.T("cli.flag_config"))
+	fs.Parse(args)
+
+	if fs.NArg() != 1 || (fs.Arg(0) != "stats" && fs.Arg(0) != "rebuild") {
+		return i18n.Error("cli.index_usage")
+	}
+
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+
+	x := core.OpenCodeIndex(cfg)
+	if fs.Arg(0) == "rebuild" {
+		start := time.Now()
+		if err := x.Rebuild(context.Background()); err != nil {
+			return err
+		}
+		log.Println(i18n.T("cli.index_rebuilt", time.Since(start).Round(time.Millisecond)))
+	}
+	printIndexStats(x.Stats())
+	return nil
+}
+
+// printIndexStats 输出索引的统计信息
+func printIndexStats(stats index.Stats) {
+	updated := "-"
+	if !stats.UpdatedAt.IsZero() {
+		updated = stats.UpdatedAt.Format(time.RFC3339)
+	}
+	fmt.Println(i18n.T("cli.index_stats", stats.Version, stats.Files, stats.Chunks, stats.Terms, stats.Segments, stats.DiskBytes, updated))
+}
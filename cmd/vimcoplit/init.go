@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/liangsj/vimcoplit/internal/i18n"
+	"github.com/liangsj/vimcoplit/internal/models"
+	"github.com/liangsj/vimcoplit/internal/setup"
+)
+
+// runInit 配置模型提供商、API Key 的保存方式、工作区根目录和允许执行的命令，
+// 检查连通性后写入配置文件。未通过参数指定的选项会交互询问，-y 时使用默认值
+//
+// 用法: vimcoplit init [-config path] [-provider type] [-api-key key] [-key-storage file] [-root dir] [-allowed-cmds git,go] [-y]
+func runInit(args []string) error {
+	fs := flag.NewFlagSet("init", flag.ExitOnError)
+	configPath := fs.String("config", "", i18n.T("cli.flag_config"))
+	provider := fs.String("provider", "", i18n.T("cli.flag_provider"))
+	apiKey := fs.String("api-key", "", i18n.T("cli.flag_api_key"))
+	keyStorage := fs.String("key-storage", "", i18n.T("cli.flag_key_storage"))
+	root := fs.String("root", "", i18n.T("cli.flag_root"))
+	allowedCmds := fs.String("allowed-cmds", "", i18n.T("cli.flag_allowed_cmds"))
+	skipValidate := fs.Bool("skip-validate", false, i18n.T("cli.flag_skip_validate"))
+	yes := fs.Bool("y", false, i18n.T("cli.flag_yes"))
+	fs.Parse(args)
+
+	opts, err := setup.Defaults(*configPath)
+	if err != nil {
+		return err
+	}
+	opts.SkipValidation = *skipValidate
+
+	in := bufio.NewReader(os.Stdin)
+	ask := func(value *string, flagValue, label, def string) {
+		switch {
+		case flagValue != "":
+			*value = flagValue
+		case *yes:
+			*value = def
+		default:
+			if def != "" {
+				fmt.Printf("%s [%s]: ", label, def)
+			} else {
+				fmt.Printf("%s: ", label)
+			}
+			line, _ := in.ReadString('\n')
+			if line = strings.TrimSpace(line); line == "" {
+				line = def
+			}
+			*value = line
+		}
+	}
+
+	var providerValue, storageValue, cmdsValue string
+	types := make([]string, 0, len(models.SupportedModelTypes()))
+	for _, t := range models.SupportedModelTypes() {
+		types = append(types, string(t))
+	}
+	ask(&providerValue, *provider, i18n.T("cli.init_provider", strings.Join(types, ", ")), string(opts.Provider))
+	ask(&opts.APIKey, *apiKey, i18n.T("cli.init_api_key"), os.Getenv("VIMCOPLIT_API_KEY"))
+	ask(&storageValue, *keyStorage, i18n.T("cli.init_key_storage"), string(opts.KeyStorage))
+	ask(&opts.WorkspaceRoot, *root, i18n.T("cli.init_root"), opts.WorkspaceRoot)
+	ask(&cmdsValue, *allowedCmds, i18n.T("cli.init_allowed_cmds"), strings.Join(opts.AllowedCmds, ","))
+	opts.Provider = models.ModelType(providerValue)
+	opts.KeyStorage = setup.KeyStorage(storageValue)
+	opts.AllowedCmds = nil
+	for _, cmd := range strings.Split(cmdsValue, ",") {
+		if cmd = strings.TrimSpace(cmd); cmd != "" {
+			opts.AllowedCmds = append(opts.AllowedCmds, cmd)
+		}
+	}
+
+	if !opts.SkipValidation {
+		log.Println(i18n.T("cli.init_validating", opts.Provider))
+	}
+	result, _, err := setup.Run(context.Background(), *configPath, opts)
+	if err != nil {
+		return err
+	}
+	if result.Test != nil {
+		log.Println(i18n.T("cli.init_validated", result.Test.Latency))
+	}
+	log.Println(i18n.T("cli.init_done", result.ConfigPath))
+	return nil
+}
@@ -1,33 +1,151 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 
 	"github.com/liangsj/vimcoplit/internal/api"
+	"github.com/liangsj/vimcoplit/internal/buildinfo"
+	"github.com/liangsj/vimcoplit/internal/config"
 	"github.com/liangsj/vimcoplit/internal/core"
+	"github.com/liangsj/vimcoplit/internal/i18n"
+	"github.com/liangsj/vimcoplit/internal/logbuf"
 )
 
 func main() {
+	// 子命令
+	if len(os.Args) > 1 {
+		var run func(args []string) error
+		switch os.Args[1] {
+		case "init":
+			run = runInit
+		case "update":
+			run = runUpdate
+		case "tunnel":
+			run = runTunnel
+		case "backup":
+			run = runBackup
+		case "restore":
+			run = runRestore
+		case "index":
+			run = runIndex
+		case "config":
+			run = runConfig
+		case "shell":
+			run = runShell
+		}
+		if run != nil {
+			if err := run(os.Args[2:]); err != nil {
+				log.Fatalln(i18n.T("cli.command_failed", os.Args[1], err))
+			}
+			return
+		}
+	}
+
 	// 解析命令行参数
-	port := flag.Int("port", 8080, "服务器监听端口")
+	configPath := flag.String("config", "", i18n.T("cli.flag_config"))
+	host := flag.String("host", "", i18n.T("cli.flag_host"))
+	port := flag.Int("port", 0, i18n.T("cli.flag_port"))
+	showVersion := flag.Bool("version", false, i18n.T("cli.flag_version"))
 	flag.Parse()
 
+	if *showVersion {
+		fmt.Println(buildinfo.Get())
+		return
+	}
+
+	// 同时在内存中保留最近的日志，供管理面板查看
+	logs := logbuf.New(500)
+	log.SetOutput(io.MultiWriter(os.Stderr, logs))
+
+	// 加载配置
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		log.Fatalln(i18n.T("cli.load_config_failed", err))
+	}
+	if err := i18n.SetLocale(i18n.Locale(cfg.Locale)); err != nil {
+		log.Println(i18n.T("cli.invalid_locale", err))
+	}
+	if !cfg.Exists() {
+		log.Println(i18n.T("cli.not_configured", cfg.Path()))
+	}
+	if *host != "" {
+		cfg.Set("server.host", *host, "flag -host")
+	}
+	if *port != 0 {
+		cfg.Set("server.port", strconv.Itoa(*port), "flag -port")
+	}
+
 	// 初始化核心服务
-	coreService := core.NewService()
+	coreService := core.NewService(cfg)
+
+	// 恢复上次退出时的持久化状态
+	report, err := coreService.Recover(context.Background())
+	if err != nil {
+		log.Fatalln(i18n.T("cli.recover_failed", err))
+	}
+	log.Println(i18n.T("cli.recover_done",
+		report.ReplayedWrites, report.SkippedWrites, report.ResumedTasks, report.FailedTasks, report.ResetServers))
+
+	// 预热模型，尽早发现无效的 API Key 等配置问题
+	if cfg.Model.WarmUp {
+		go func() {
+			result := coreService.TestModel(context.Background())
+			if result.OK {
+				log.Println(i18n.T("cli.warmup_ok", result.Model, result.Latency))
+			} else {
+				log.Println(i18n.T("cli.warmup_failed", result.Error, result.AuthValid))
+			}
+		}()
+	}
 
 	// 初始化API处理器
 	handler := api.NewHandler(coreService)
+	handler.SetLogBuffer(logs)
+
+	// 远程开发模式下校验 Host 头和客户端子网
+	restricted, err := api.RestrictAccess(cfg.Server.AllowedHosts, cfg.Server.AllowedSubnets, api.Compress(handler))
+	if err != nil {
+		log.Fatalln(i18n.T("cli.access_config_invalid", err))
+	}
 
 	// 设置HTTP服务器
+	addr := net.JoinHostPort(cfg.Server.Host, strconv.Itoa(cfg.Server.Port))
+	if ip := net.ParseIP(cfg.Server.Host); cfg.Server.Token == "" && cfg.Server.Host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		log.Println(i18n.T("cli.server_token_missing", addr))
+	}
 	server := &http.Server{
-		Addr:    fmt.Sprintf(":%d", *port),
-		Handler: handler,
+		Addr:    addr,
+		Handler: restricted,
+	}
+
+	// 调试端口单独监听，提供 pprof 和状态快照
+	var debugServer *http.Server
+	if cfg.Debug.Port > 0 {
+		if cfg.Debug.Token == "" {
+			log.Println(i18n.T("cli.debug_token_required"))
+		} else {
+			debugAddr := net.JoinHostPort(cfg.Debug.Host, strconv.Itoa(cfg.Debug.Port))
+			debugServer = &http.Server{
+				Addr:    debugAddr,
+				Handler: api.NewDebugHandler(handler, cfg.Debug.Token),
+			}
+			go func() {
+				log.Println(i18n.T("cli.debug_started", debugAddr))
+				if err := debugServer.ListenAndServe(); err != http.ErrServerClosed {
+					log.Println(i18n.T("cli.debug_server_error", err))
+				}
+			}()
+		}
 	}
 
 	// 优雅关闭
@@ -35,15 +153,23 @@ func main() {
 		sigChan := make(chan os.Signal, 1)
 		signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 		<-sigChan
-		log.Println("正在关闭服务器...")
+		log.Println(i18n.T("cli.shutting_down"))
+		if err := handler.Close(); err != nil {
+			log.Println(i18n.T("cli.close_failed", err))
+		}
+		if debugServer != nil {
+			debugServer.Close()
+		}
 		if err := server.Close(); err != nil {
-			log.Printf("关闭服务器时出错: %v\n", err)
+			log.Println(i18n.T("cli.shutdown_failed", err))
 		}
 	}()
 
 	// 启动服务器
-	log.Printf("VimCoplit 服务器启动在端口 %d\n", *port)
+	log.Println(buildinfo.Get())
+	log.Println(i18n.T("cli.server_started", addr))
+	log.Println(i18n.T("cli.dashboard_url", addr))
 	if err := server.ListenAndServe(); err != http.ErrServerClosed {
-		log.Fatalf("服务器错误: %v\n", err)
+		log.Fatalln(i18n.T("cli.server_error", err))
 	}
 }
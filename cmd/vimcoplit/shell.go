@@ -0,0 +1,39 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"os/exec"
+
+	"github.com/liangsj/vimcoplit/internal/config"
+	"github.com/liangsj/vimcoplit/internal/core/shell"
+	"github.com/liangsj/vimcoplit/internal/i18n"
+)
+
+// runShell 在工作区根目录启动交互式 shell，使用与 MCP 服务器 start_cmd 相同的 shell 配置，
+// 编辑器的终端可以用它得到与 agent 一致的 PATH。shell 的退出码作为本命令的退出码
+//
+// 用法: vimcoplit shell [-config path]
+func runShell(args []string) error {
+	fs := flag.NewFlagSet("shell", flag.ExitOnError)
+	configPath := fs.String("config", "", i18n.T("cli.flag_config"))
+	fs.Parse(args)
+
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+
+	sh := &shell.Shell{Path: cfg.Shell.Path, Login: cfg.Shell.Login, RCFile: cfg.Shell.RCFile}
+	path, shellArgs := sh.Interactive()
+	cmd := exec.Command(path, shellArgs...)
+	cmd.Dir = cfg.WorkspaceRoot()
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	err = cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		os.Exit(exitErr.ExitCode())
+	}
+	return err
+}
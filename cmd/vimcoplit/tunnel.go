@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+
+	"github.com/liangsj/vimcoplit/internal/i18n"
+)
+
+// runTunnel 通过 SSH 端口转发将远程开发机上的服务映射到本地，供本地插件访问
+//
+// 用法: vimcoplit tunnel [-local-port 8080] [-remote-port 8080] [user@]host
+func runTunnel(args []string) error {
+	fs := flag.NewFlagSet("tunnel", flag.ExitOnError)
+	localPort := fs.Int("local-port", 8080, i18n.T("cli.flag_local_port"))
+	remotePort := fs.Int("remote-port", 8080, i18n.T("cli.flag_remote_port"))
+	remoteHost := fs.String("remote-host", "localhost", i18n.T("cli.flag_remote_host"))
+	identity := fs.String("i", "", i18n.T("cli.flag_identity"))
+	sshBin := fs.String("ssh", "ssh", i18n.T("cli.flag_ssh"))
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		return i18n.Error("cli.tunnel_usage")
+	}
+	destination := fs.Arg(0)
+
+	sshArgs := []string{
+		"-N",
+		"-L", fmt.Sprintf("%d:%s:%d", *localPort, *remoteHost, *remotePort),
+		"-o", "ExitOnForwardFailure=yes",
+		"-o", "ServerAliveInterval=30",
+	}
+	if *identity != "" {
+		sshArgs = append(sshArgs, "-i", *identity)
+	}
+	sshArgs = append(sshArgs, destination)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	cmd := exec.CommandContext(ctx, *sshBin, sshArgs...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	log.Println(i18n.T("cli.tunnel_opening", *localPort, destination, *remoteHost, *remotePort))
+	if err := cmd.Run(); err != nil && ctx.Err() == nil {
+		return fmt.Errorf("ssh exited: %v", err)
+	}
+	log.Println(i18n.T("cli.tunnel_closed"))
+	return nil
+}
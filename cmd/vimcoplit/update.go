@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/liangsj/vimcoplit/internal/buildinfo"
+	"github.com/liangsj/vimcoplit/internal/config"
+	"github.com/liangsj/vimcoplit/internal/i18n"
+	"github.com/liangsj/vimcoplit/internal/update"
+)
+
+// runUpdate 从配置的发布渠道检查新版本，下载当前平台的可执行文件，校验后替换正在使用的可执行文件。
+// 公钥优先使用配置文件中的 update.public_key，其次是构建时注入的 buildinfo.UpdatePublicKey，
+// 都没有时只有 -insecure 才会安装
+//
+// 用法: vimcoplit update [-config path] [-channel stable|beta] [-check] [-insecure]
+func runUpdate(args []string) error {
+	fs := flag.NewFlagSet("update", flag.ExitOnError)
+	configPath := fs.String("config", "", i18n.T("cli.flag_config"))
+	channel := fs.String("channel", "", i18n.T("cli.flag_channel"))
+	checkOnly := fs.Bool("check", false, i18n.T("cli.flag_check"))
+	insecure := fs.Bool("insecure", false, i18n.T("cli.flag_insecure"))
+	fs.Parse(args)
+
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	if *channel == "" {
+		*channel = cfg.Update.Channel
+	}
+	publicKey := cfg.Update.PublicKey
+	if publicKey == "" {
+		publicKey = buildinfo.UpdatePublicKey
+	}
+	updater, err := update.New(cfg.Update.URL, *channel, publicKey)
+	if err != nil {
+		return err
+	}
+	updater.Insecure = *insecure
+
+	ctx := context.Background()
+	release, asset, newer, err := updater.Check(ctx, buildinfo.Version)
+	if err != nil {
+		return err
+	}
+	if !newer {
+		log.Println(i18n.T("cli.update_latest", buildinfo.Version, updater.Channel))
+		return nil
+	}
+	log.Println(i18n.T("cli.update_available", buildinfo.Version, release.Version, updater.Channel))
+	if *checkOnly {
+		return nil
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return err
+	}
+	if err := updater.Install(ctx, release, asset, exe); err != nil {
+		return err
+	}
+	log.Println(i18n.T("cli.update_done", release.Version))
+	return nil
+}
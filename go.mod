@@ -2,9 +2,9 @@ module github.com/liangsj/vimcoplit
 
 go 1.24.3
 
-require github.com/fsnotify/fsnotify v1.9.0
-
 require (
-	github.com/google/uuid v1.6.0 // indirect
-	golang.org/x/sys v0.13.0 // indirect
+	github.com/fsnotify/fsnotify v1.9.0
+	github.com/google/uuid v1.6.0
 )
+
+require golang.org/x/sys v0.13.0 // indirect
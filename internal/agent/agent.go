@@ -0,0 +1,922 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/liangsj/vimcoplit/internal/config"
+	"github.com/liangsj/vimcoplit/internal/core"
+	"github.com/liangsj/vimcoplit/internal/core/filter"
+	"github.com/liangsj/vimcoplit/internal/core/mcp"
+	"github.com/liangsj/vimcoplit/internal/core/merge"
+	"github.com/liangsj/vimcoplit/internal/core/syntax"
+	"github.com/liangsj/vimcoplit/internal/core/textedit"
+	"github.com/liangsj/vimcoplit/internal/events"
+	"github.com/liangsj/vimcoplit/internal/models"
+	"github.com/liangsj/vimcoplit/internal/permission"
+)
+
+// Agent 负责生成计划并在用户审批后执行
+type Agent struct {
+	service     core.Service
+	cfg         *config.Config // 服务的配置，service 为 nil 时使用默认配置
+	store       *runStore
+	defaults    Limits
+	cassettes   *cassetteStore     // 为 nil 时不录制
+	events      *events.Bus        // 运行记录变化和审批事件发布到服务的事件总线
+	permissions *permission.Broker // 执行步骤前等待插件回复的权限请求
+	replay      *Cassette          // 不为 nil 时从 cassette 回放，不访问模型和执行器
+
+	mu        sync.Mutex
+	running   map[string]*activeRun // 正在生成计划或执行中的运行
+	yolo      *YoloWindow           // 当前的临时自动审批窗口
+	yoloTimer *time.Timer           // 窗口到期时结束窗口
+}
+
+// activeRun 是正在进行的运行，cancel 中断模型请求和正在执行的步骤
+type activeRun struct {
+	cancel    context.CancelFunc
+	done      chan struct{}
+	startedAt time.Time
+}
+
+// ActiveRun 是正在生成计划或执行中的运行，用于诊断
+type ActiveRun struct {
+	ID        string    `json:"id"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+// New 创建一个新的 agent，运行记录保存在 storePath，defaults 为每次运行的默认上限
+func New(service core.Service, storePath string, defaults Limits) *Agent {
+	a := &Agent{
+		service:  service,
+		cfg:      config.DefaultConfig(),
+		store:    newRunStore(storePath),
+		defaults: defaults,
+		running:  make(map[string]*activeRun),
+	}
+	if service != nil {
+		a.cfg = service.Config()
+		a.events = service.Events()
+	}
+	a.store.notify = func(run *Run) {
+		a.events.Publish(events.TypeRun, run)
+	}
+	a.permissions = permission.NewBroker(a.events)
+	return a
+}
+
+// ApprovalEvent 是计划等待审批、审批结果或临时自动审批窗口开启和结束的事件
+type ApprovalEvent struct {
+	RunID  string      `json:"run_id,omitempty"`
+	Status string      `json:"status"` // requested、approved、auto_approved、rejected、yolo_started 或 yolo_ended
+	Plan   *Plan       `json:"plan,omitempty"`
+	Window *YoloWindow `json:"window,omitempty"`
+}
+
+// publishApproval 发布审批事件
+func (a *Agent) publishApproval(run *Run, status string) {
+	a.events.Publish(events.TypeApproval, &ApprovalEvent{RunID: run.ID, Status: status, Plan: run.Plan})
+}
+
+// track 登记正在进行的运行，返回可取消的 ctx 和结束时调用的函数
+func (a *Agent) track(ctx context.Context, id string) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	active := &activeRun{cancel: cancel, done: make(chan struct{}), startedAt: time.Now()}
+	a.mu.Lock()
+	a.running[id] = active
+	a.mu.Unlock()
+
+	return ctx, func() {
+		a.mu.Lock()
+		if a.running[id] == active {
+			delete(a.running, id)
+		}
+		a.mu.Unlock()
+		cancel()
+		close(active.done)
+	}
+}
+
+// Cancel 中断正在生成计划或执行中的运行，等待当前步骤停止后返回运行记录，
+// 已经产生的步骤输出会保留在运行记录中
+func (a *Agent) Cancel(ctx context.Context, id string) (*Run, error) {
+	a.mu.Lock()
+	active, ok := a.running[id]
+	a.mu.Unlock()
+	if !ok {
+		return nil, errors.New("run is not in progress")
+	}
+
+	active.cancel()
+	select {
+	case <-active.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return a.store.get(id)
+}
+
+// ActiveRuns 返回正在生成计划或执行中的运行，按开始时间排序
+func (a *Agent) ActiveRuns() []ActiveRun {
+	a.mu.Lock()
+	runs := make([]ActiveRun, 0, len(a.running))
+	for id, active := range a.running {
+		runs = append(runs, ActiveRun{ID: id, StartedAt: active.startedAt})
+	}
+	a.mu.Unlock()
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].StartedAt.Before(runs[j].StartedAt)
+	})
+	return runs
+}
+
+// SetCassetteDir 设置 cassette 目录，之后每次运行的模型响应和步骤结果都会录制到该目录
+func (a *Agent) SetCassetteDir(dir string) {
+	a.cassettes = &cassetteStore{dir: dir}
+}
+
+// Cassette 获取运行录制的 cassette
+func (a *Agent) Cassette(runID string) (*Cassette, error) {
+	if a.cassettes == nil {
+		return nil, errors.New("cassette recording is disabled")
+	}
+	if _, err := a.store.get(runID); err != nil {
+		return nil, err
+	}
+	return LoadCassette(a.cassettes.path(runID))
+}
+
+// StartRun 为目标创建一次运行并生成计划，计划需要审批后才会执行
+// taskID 为空时会自动创建一个任务，limits 中非零的字段会覆盖默认上限
+func (a *Agent) StartRun(ctx context.Context, goal, taskID string, limits *Limits) (*Run, error) {
+	run, err := a.newRun(ctx, goal, taskID, limits)
+	if err != nil {
+		return nil, err
+	}
+	a.record(run.ID, func(c *Cassette) { c.Goal = goal })
+
+	planCtx, finish := a.track(ctx, run.ID)
+	defer finish()
+	planCtx, usage := models.RecordUsage(planCtx)
+	prompt := planPrompt(goal, a.cfg.CommentLanguage())
+	output, err := a.generateStructured(planCtx, run.ID, prompt, planSchema)
+	run.Usage.Tokens += usage.Tokens(prompt, output)
+	if err == nil {
+		run.Plan, err = parsePlan(output)
+	}
+	if err == nil {
+		a.trackBases(planCtx, run.Plan, nil)
+	}
+	if err != nil {
+		run.Status = RunStatusFailed
+		if planCtx.Err() != nil {
+			run.Status = RunStatusCanceled
+		}
+		run.Error = err.Error()
+		a.store.put(run)
+		a.updateTask(ctx, run)
+		return run, nil
+	}
+
+	return a.awaitApproval(ctx, run)
+}
+
+// newRun 创建并保存一次处于计划阶段的运行，taskID 为空时自动创建任务
+func (a *Agent) newRun(ctx context.Context, goal, taskID string, limits *Limits) (*Run, error) {
+	if goal == "" {
+		return nil, errors.New("goal is required")
+	}
+
+	if taskID == "" && a.service != nil {
+		task := &core.Task{Name: goal, Description: goal}
+		if err := a.service.CreateTask(ctx, task); err != nil {
+			return nil, fmt.Errorf("failed to create task: %v", err)
+		}
+		taskID = task.ID
+	}
+
+	run := &Run{
+		ID:        uuid.New().String(),
+		TaskID:    taskID,
+		Goal:      goal,
+		Status:    RunStatusPlanning,
+		Limits:    a.defaults.merge(limits),
+		CreatedAt: time.Now(),
+	}
+	if err := a.store.put(run); err != nil {
+		return nil, err
+	}
+	if a.service != nil {
+		go a.titleRun(run.ID, goal)
+	}
+	return run, nil
+}
+
+// titleTimeout 是在后台生成运行标题的超时时间
+const titleTimeout = 30 * time.Second
+
+// titleRun 在后台根据目标为运行生成标题，生成期间用户重命名时保留用户的标题
+func (a *Agent) titleRun(id, goal string) {
+	ctx, cancel := context.WithTimeout(context.Background(), titleTimeout)
+	defer cancel()
+
+	title := a.service.SuggestTitle(ctx, "coding task", goal)
+	if title == "" {
+		return
+	}
+	_, err := a.store.update(id, func(run *Run) error {
+		if run.Title == "" {
+			run.Title = title
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("保存运行标题失败: %v\n", err)
+	}
+}
+
+// RenameRun 修改运行的标题
+func (a *Agent) RenameRun(id, title string) (*Run, error) {
+	title = strings.TrimSpace(title)
+	if title == "" {
+		return nil, errors.New("title is required")
+	}
+	return a.store.update(id, func(run *Run) error {
+		run.Title = title
+		return nil
+	})
+}
+
+// awaitApproval 保存生成的计划并等待审批，符合自动审批级别时直接开始执行
+func (a *Agent) awaitApproval(ctx context.Context, run *Run) (*Run, error) {
+	a.assess(ctx, run.Plan)
+	run.Plan.Version = 1
+	run.Plan.UpdatedAt = time.Now()
+	run.Status = RunStatusAwaitingApproval
+	if err := a.store.put(run); err != nil {
+		return nil, err
+	}
+	if a.autoApproved(ctx, run) {
+		run, err := a.approve(run.ID)
+		if err != nil {
+			return nil, err
+		}
+		a.publishApproval(run, "auto_approved")
+		a.start(run.ID)
+		return run, nil
+	}
+	run = run.clone()
+	a.publishApproval(run, "requested")
+	return run, nil
+}
+
+// StartUpgrade 创建升级 Go 模块依赖的运行：go get 指定版本并整理 go.mod 后构建和测试，
+// 失败时由模型根据错误修改代码后重试。计划同样需要审批，version 为空时升级到最新版本
+func (a *Agent) StartUpgrade(ctx context.Context, module, version, taskID string, limits *Limits) (*Run, error) {
+	if module == "" {
+		return nil, errors.New("module is required")
+	}
+	if version == "" {
+		version = "latest"
+	}
+
+	run, err := a.newRun(ctx, fmt.Sprintf("Upgrade %s to %s and fix breakages", module, version), taskID, limits)
+	if err != nil {
+		return nil, err
+	}
+	run.Plan = &Plan{Steps: []Step{
+		{Description: "Upgrade " + module, Action: ActionCommand, Command: "go", Args: []string{"get", module + "@" + version}},
+		{Description: "Tidy go.mod and go.sum", Action: ActionCommand, Command: "go", Args: []string{"mod", "tidy"}},
+		{Description: "Build and fix breakages", Action: ActionVerify, Command: "go", Args: []string{"build", "./..."}},
+		{Description: "Test and fix breakages", Action: ActionVerify, Command: "go", Args: []string{"test", "./..."}},
+	}}
+	run.Plan.normalize()
+	return a.awaitApproval(ctx, run)
+}
+
+// autoApproved 判断计划是否在临时自动审批窗口内或符合工作区设置的自动审批级别，
+// 包含高风险步骤的计划总是需要用户审批
+func (a *Agent) autoApproved(ctx context.Context, run *Run) bool {
+	plan := run.Plan
+	if plan.HighRisk() {
+		return false
+	}
+	if a.useYolo(run.TaskID, func(w *YoloWindow) { w.Approved++ }) {
+		return true
+	}
+	if a.service == nil {
+		return false
+	}
+	switch a.service.GetSettings(ctx).AutoApprove {
+	case core.AutoApproveAll:
+		return true
+	case core.AutoApproveEdits:
+		for _, step := range plan.Steps {
+			if step.Action != ActionWriteFile && step.Action != ActionNote {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// GetRun 获取运行记录
+func (a *Agent) GetRun(id string) (*Run, error) {
+	return a.store.get(id)
+}
+
+// ListRuns 列出所有运行记录
+func (a *Agent) ListRuns() []*Run {
+	return a.store.list()
+}
+
+// UpdatePlan 使用用户编辑后的步骤替换计划，只能在审批前修改
+// 步骤的顺序即执行顺序，重新排序只需按新顺序提交
+func (a *Agent) UpdatePlan(id string, steps []Step) (*Run, error) {
+	plan := &Plan{Steps: steps}
+	if err := plan.Validate(); err != nil {
+		return nil, err
+	}
+	plan.normalize()
+
+	return a.store.update(id, func(run *Run) error {
+		if run.Status != RunStatusAwaitingApproval {
+			return fmt.Errorf("plan cannot be edited in status %s", run.Status)
+		}
+		a.trackBases(context.Background(), plan, run.Plan)
+		a.assess(context.Background(), plan)
+		plan.Version = run.Plan.Version + 1
+		plan.UpdatedAt = time.Now()
+		run.Plan = plan
+		return nil
+	})
+}
+
+// Approve 审批计划并在后台开始执行
+func (a *Agent) Approve(id string) (*Run, error) {
+	run, err := a.approve(id)
+	if err != nil {
+		return nil, err
+	}
+	a.publishApproval(run, "approved")
+
+	a.start(run.ID)
+	return run, nil
+}
+
+// approve 将运行标记为执行中，并录制审批时的计划
+func (a *Agent) approve(id string) (*Run, error) {
+	run, err := a.store.update(id, func(run *Run) error {
+		if run.Status != RunStatusAwaitingApproval {
+			return fmt.Errorf("run cannot be approved in status %s", run.Status)
+		}
+		run.Status = RunStatusRunning
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	a.record(run.ID, func(c *Cassette) { c.Plan = run.clone().Plan })
+	return run, nil
+}
+
+// Continue 在运行因超出上限暂停后确认继续执行
+// limits 为 nil 时剩余步骤不再受上限约束，否则用其中非零的字段放宽上限
+func (a *Agent) Continue(id string, limits *Limits) (*Run, error) {
+	run, err := a.store.update(id, func(run *Run) error {
+		if run.Status != RunStatusPaused {
+			return fmt.Errorf("run cannot be continued in status %s", run.Status)
+		}
+		if limits == nil {
+			run.Limits = Limits{}
+		} else {
+			run.Limits = run.Limits.merge(limits)
+		}
+		if run.Blocked {
+			run.Plan.Steps[run.NextStep].Override = true
+			run.Blocked = false
+		}
+		run.Status = RunStatusRunning
+		run.PauseReason = ""
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	a.start(run.ID)
+	return run, nil
+}
+
+// Reject 拒绝计划
+func (a *Agent) Reject(ctx context.Context, id string) (*Run, error) {
+	run, err := a.store.update(id, func(run *Run) error {
+		if run.Status != RunStatusAwaitingApproval {
+			return fmt.Errorf("run cannot be rejected in status %s", run.Status)
+		}
+		run.Status = RunStatusRejected
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	a.publishApproval(run, "rejected")
+	a.updateTask(ctx, run)
+	return run, nil
+}
+
+// start 在后台执行运行，执行期间可以通过 Cancel 中断
+func (a *Agent) start(id string) {
+	ctx, finish := a.track(context.Background(), id)
+	go func() {
+		defer finish()
+		a.execute(ctx, id)
+	}()
+}
+
+// execute 从 NextStep 开始按顺序执行计划步骤，任一步骤失败时跳过剩余步骤
+// 执行下一步会超出上限时暂停运行，等待用户确认后再继续
+func (a *Agent) execute(ctx context.Context, id string) {
+	run, err := a.store.get(id)
+	if err != nil {
+		return
+	}
+	a.updateTask(ctx, run)
+
+	branch, stash, err := a.prepare(ctx, id)
+	if err != nil {
+		a.store.update(id, func(run *Run) error {
+			run.Status = RunStatusPaused
+			run.PauseReason = fmt.Sprintf("workspace: %v", err)
+			return nil
+		})
+		return
+	}
+	if branch != nil && branch.Worktree != "" {
+		ctx = withWorkDir(ctx, branch.Worktree)
+	}
+
+	usage := run.Usage
+	base, started := usage.Duration, time.Now()
+	var runErr error
+	for i := run.NextStep; i < len(run.Plan.Steps); i++ {
+		step := &run.Plan.Steps[i]
+		if runErr == nil && ctx.Err() != nil {
+			runErr = errors.New("run canceled")
+		}
+		if runErr != nil {
+			a.setStep(id, i, func(step *Step) { step.Status = StepStatusSkipped })
+			continue
+		}
+
+		usage.Duration = base + time.Since(started).Seconds()
+		if reason := run.Limits.check(&usage, step); reason != "" {
+			a.store.update(id, func(run *Run) error {
+				run.Status = RunStatusPaused
+				run.PauseReason = reason
+				run.NextStep = i
+				run.Usage = usage
+				return nil
+			})
+			return
+		}
+
+		if err := a.checkStep(ctx, step); err != nil {
+			a.store.update(id, func(run *Run) error {
+				run.Status = RunStatusPaused
+				run.PauseReason = fmt.Sprintf("step %d: %v", i+1, err)
+				run.Blocked = true
+				run.NextStep = i
+				run.Usage = usage
+				return nil
+			})
+			return
+		}
+
+		if err := a.authorize(ctx, run, step); err != nil {
+			a.store.update(id, func(run *Run) error {
+				stored := &run.Plan.Steps[i]
+				stored.Status = StepStatusFailed
+				stored.Error = err.Error()
+				run.NextStep = i + 1
+				run.Usage = usage
+				return nil
+			})
+			runErr = fmt.Errorf("step %d failed: %v", i+1, err)
+			continue
+		}
+
+		stepCtx := ctx
+		if step.Override {
+			stepCtx = filter.WithOverride(ctx)
+		}
+		usage.record(step)
+		a.setStep(id, i, func(step *Step) { step.Status = StepStatusRunning })
+		output, err := a.runStep(stepCtx, id, step)
+		a.store.update(id, func(run *Run) error {
+			stored := &run.Plan.Steps[i]
+			stored.Output = output
+			stored.Diff = step.Diff
+			if err != nil {
+				stored.Status = StepStatusFailed
+				stored.Error = err.Error()
+			} else {
+				stored.Status = StepStatusCompleted
+			}
+			run.NextStep = i + 1
+			run.Usage = usage
+			return nil
+		})
+		if err != nil {
+			runErr = fmt.Errorf("step %d failed: %v", i+1, err)
+		}
+		if branch != nil {
+			if err := a.commitStep(ctx, id, branch, step); err != nil && runErr == nil {
+				runErr = fmt.Errorf("step %d: failed to commit to the task branch: %v", i+1, err)
+			}
+		}
+	}
+
+	usage.Duration = base + time.Since(started).Seconds()
+	run, err = a.store.update(id, func(run *Run) error {
+		run.Usage = usage
+		if runErr != nil {
+			run.Status = RunStatusFailed
+			if ctx.Err() != nil {
+				run.Status = RunStatusCanceled
+			}
+			run.Error = runErr.Error()
+		} else {
+			run.Status = RunStatusCompleted
+		}
+		return nil
+	})
+	if branch != nil || stash != nil {
+		a.leave(ctx, id, branch, stash)
+		run, err = a.store.get(id)
+	}
+	if err == nil {
+		a.updateTask(ctx, run)
+	}
+}
+
+// setStep 修改第 i 个步骤的状态
+func (a *Agent) setStep(id string, i int, fn func(step *Step)) {
+	a.store.update(id, func(run *Run) error {
+		fn(&run.Plan.Steps[i])
+		return nil
+	})
+}
+
+// checkStep 在执行前用输出过滤扫描命令和工具步骤，用户已确认的步骤直接放行
+func (a *Agent) checkStep(ctx context.Context, step *Step) error {
+	if a.service == nil || step.Override {
+		return nil
+	}
+
+	var text string
+	switch step.Action {
+	case ActionCommand, ActionVerify:
+		text = strings.Join(append([]string{step.Command}, step.Args...), " ")
+	case ActionTool:
+		params, _ := json.Marshal(step.Params)
+		text = step.Tool + " " + string(params)
+	default:
+		return nil
+	}
+	_, err := a.service.CheckOutput(ctx, text)
+	return err
+}
+
+// generate 调用模型生成响应，录制或从 cassette 回放
+func (a *Agent) generate(ctx context.Context, runID, prompt string) (string, error) {
+	if a.replay != nil {
+		return a.replay.next(InteractionModel, prompt)
+	}
+	output, err := a.service.GenerateResponse(ctx, a.withRepoMap(ctx, prompt))
+	a.record(runID, func(c *Cassette) {
+		c.Interactions = append(c.Interactions, Interaction{
+			Kind: InteractionModel, Input: prompt, Output: output, Error: errorString(err),
+		})
+	})
+	return output, err
+}
+
+// generateStructured 调用模型生成符合 schema 的 JSON，不符合时由服务让模型修正后重试。
+// cassette 中录制原始提示词和最终输出，回放时直接使用录制的输出
+func (a *Agent) generateStructured(ctx context.Context, runID, prompt, jsonSchema string) (string, error) {
+	if a.replay != nil {
+		return a.replay.next(InteractionModel, prompt)
+	}
+	var output string
+	result, err := a.service.GenerateStructured(ctx, &core.StructuredRequest{
+		Prompt: a.withRepoMap(ctx, prompt),
+		Schema: json.RawMessage(jsonSchema),
+	})
+	var structErr *core.StructuredError
+	if err == nil {
+		output = string(result.Value)
+	} else if errors.As(err, &structErr) {
+		output = structErr.Output
+	}
+	a.record(runID, func(c *Cassette) {
+		c.Interactions = append(c.Interactions, Interaction{
+			Kind: InteractionModel, Input: prompt, Output: output, Error: errorString(err),
+		})
+	})
+	return output, err
+}
+
+// withRepoMap 在提示词前加上仓库地图，cassette 中仍录制原始提示词以便回放时匹配
+func (a *Agent) withRepoMap(ctx context.Context, prompt string) string {
+	if !a.cfg.RepoMap.Enabled {
+		return prompt
+	}
+	m, err := a.service.RepoMap(ctx, false)
+	if err != nil {
+		log.Printf("生成仓库地图失败: %v\n", err)
+		return prompt
+	}
+	if budget := a.service.GetSettings(ctx).ContextBudget; m.Text == "" || (budget > 0 && m.Tokens > budget) {
+		return prompt
+	}
+	return "Repository map:\n" + m.Text + "\n" + prompt
+}
+
+// runStep 执行单个步骤，录制或从 cassette 回放
+func (a *Agent) runStep(ctx context.Context, runID string, step *Step) (string, error) {
+	key := stepKey(step)
+	if a.replay != nil {
+		return a.replay.next(InteractionStep, key)
+	}
+	output, err := a.executeStep(ctx, runID, step)
+	a.record(runID, func(c *Cassette) {
+		c.Interactions = append(c.Interactions, Interaction{
+			Kind: InteractionStep, Input: key, Output: output, Error: errorString(err),
+		})
+	})
+	return output, err
+}
+
+// record 修改运行的 cassette，未开启录制或回放时不做任何事
+func (a *Agent) record(runID string, fn func(c *Cassette)) {
+	if a.cassettes == nil || a.replay != nil {
+		return
+	}
+	if err := a.cassettes.update(runID, fn); err != nil {
+		log.Printf("录制 cassette 失败: %v\n", err)
+	}
+}
+
+// executeStep 执行单个步骤，返回步骤输出
+func (a *Agent) executeStep(ctx context.Context, runID string, step *Step) (string, error) {
+	switch step.Action {
+	case ActionCommand:
+		result, err := a.service.ExecuteCommand(ctx, &core.Command{
+			Command:  step.Command,
+			Args:     step.Args,
+			WorkDir:  workDir(ctx),
+			Metadata: map[string]string{"source": "agent", "run_id": runID},
+		})
+		if err != nil {
+			return "", err
+		}
+		output := result.Stdout + result.Stderr
+		if result.ExitCode != 0 {
+			return output, fmt.Errorf("command exited with code %d", result.ExitCode)
+		}
+		return output, nil
+
+	case ActionWriteFile:
+		return a.writeFile(ctx, runID, step)
+
+	case ActionVerify:
+		return a.verify(ctx, runID, step)
+
+	case ActionTool:
+		result, err := a.service.GetMCPManager().ExecuteTool(ctx, step.Tool, step.Params)
+		if err != nil {
+			return "", err
+		}
+		if result.Status != string(mcp.ToolExecutionStatusSuccess) {
+			return result.Text(), errors.New(result.Error)
+		}
+		return result.Text(), nil
+
+	case ActionTools:
+		return a.runTools(ctx, runID, step)
+
+	case ActionNote:
+		return "", nil
+
+	default:
+		return "", fmt.Errorf("unsupported action %q", step.Action)
+	}
+}
+
+// trackBases 记录写文件步骤计划时目标文件的摘要，执行时文件已被修改则三方合并而不是覆盖。
+// previous 为编辑前的计划，未修改目标文件的步骤沿用原来的摘要
+func (a *Agent) trackBases(ctx context.Context, plan, previous *Plan) {
+	if a.service == nil {
+		return
+	}
+	bases := make(map[string]string)
+	if previous != nil {
+		for _, step := range previous.Steps {
+			bases[step.ID+"\x00"+step.Target] = step.BaseHash
+		}
+	}
+	for i := range plan.Steps {
+		step := &plan.Steps[i]
+		if step.Action != ActionWriteFile || step.BaseHash != "" {
+			continue
+		}
+		if hash := bases[step.ID+"\x00"+step.Target]; hash != "" {
+			step.BaseHash = hash
+			continue
+		}
+		_, hash, err := a.service.TrackFile(ctx, step.Target)
+		switch {
+		case err == nil:
+			step.BaseHash = hash
+		case errors.Is(err, fs.ErrNotExist):
+			step.BaseHash = merge.Hash(nil)
+		default:
+			log.Printf("记录文件摘要失败: %v\n", err)
+		}
+	}
+}
+
+// writeFile 写入文件并在 step.Diff 中记录实际写入的修改，
+// 内容存在语法错误时把错误交给模型修正后重试，最多 maxSyntaxRepairs 次
+func (a *Agent) writeFile(ctx context.Context, runID string, step *Step) (string, error) {
+	target := a.path(ctx, step.Target)
+	edit := &core.FileEdit{Path: target, BaseHash: step.BaseHash, Content: step.Content, Edits: step.Edits}
+	var note string
+	var warnings []string
+	if len(step.RejectedHunks) > 0 {
+		// 只写入审阅时接受的段，结果仍基于计划时的内容，与之后的改动合并
+		content, deps, ok, err := a.applyReviewed(ctx, runID, step)
+		if err != nil {
+			return "", err
+		}
+		if !ok {
+			return fmt.Sprintf("all hunks for %s rejected, file unchanged", step.Target), nil
+		}
+		edit = &core.FileEdit{Path: target, BaseHash: step.BaseHash, Content: content}
+		note, warnings = fmt.Sprintf(" (%d hunk(s) rejected)", len(step.RejectedHunks)), deps
+	}
+	for repairs := 0; ; repairs++ {
+		result, err := a.service.EditFile(ctx, edit)
+		var invalid *syntax.InvalidError
+		if err == nil {
+			step.Diff = result.Diff
+			output := fmt.Sprintf("wrote %d bytes to %s", len(edit.Content), step.Target)
+			if len(edit.Edits) > 0 {
+				output = fmt.Sprintf("applied %d edit(s) to %s", len(edit.Edits), step.Target)
+			}
+			output += note
+			if result.Merged {
+				output += " (merged with changes made after planning)"
+			}
+			if repairs > 0 {
+				output += fmt.Sprintf(" after %d syntax repair(s)", repairs)
+			}
+			for _, w := range warnings {
+				output += "\nwarning: " + w
+			}
+			return output, nil
+		}
+		if !errors.As(err, &invalid) || repairs == maxSyntaxRepairs {
+			return "", err
+		}
+
+		content := edit.Content
+		if len(edit.Edits) > 0 {
+			if content, err = a.editedContent(ctx, edit, err); err != nil {
+				return "", err
+			}
+		}
+		fixed, genErr := a.service.GenerateResponse(ctx, repairPrompt(step.Target, content, a.cfg.CommentLanguage(), invalid))
+		if genErr != nil {
+			return "", fmt.Errorf("%v; repair failed: %v", err, genErr)
+		}
+		// 修正后的内容是完整的文件，之后按整文件写入
+		edit = &core.FileEdit{Path: target, BaseHash: edit.BaseHash, Content: stripCodeFence(fixed)}
+	}
+}
+
+// editedContent 返回范围修改应用后的完整内容，用于修正语法错误。
+// 文件在计划后被改动过时无法得到修改基于的内容，返回原来的错误
+func (a *Agent) editedContent(ctx context.Context, edit *core.FileEdit, cause error) (string, error) {
+	current, err := a.service.ReadFile(ctx, edit.Path)
+	if err != nil {
+		return "", cause
+	}
+	if edit.BaseHash != "" && merge.Hash(current) != edit.BaseHash {
+		return "", cause
+	}
+	return textedit.Apply(string(current), edit.Edits)
+}
+
+// verify 执行验证命令，失败时把输出交给模型解释并应用建议的修改后重试，最多 maxVerifyFixes 次。
+// 修改的 diff 累积记录在 step.Diff 中
+func (a *Agent) verify(ctx context.Context, runID string, step *Step) (string, error) {
+	var log strings.Builder
+	command := &Step{Action: ActionCommand, Command: step.Command, Args: step.Args}
+	for fixes := 0; ; fixes++ {
+		output, err := a.executeStep(ctx, runID, command)
+		fmt.Fprintf(&log, "$ %s\n%s", strings.Join(append([]string{step.Command}, step.Args...), " "), output)
+		if err == nil {
+			return log.String(), nil
+		}
+		if fixes == maxVerifyFixes || ctx.Err() != nil {
+			return log.String(), err
+		}
+
+		explanation, explainErr := a.service.ExplainError(ctx, &core.ExplainRequest{Error: output})
+		if explainErr != nil {
+			return log.String(), fmt.Errorf("%v; fix failed: %v", err, explainErr)
+		}
+		fmt.Fprintf(&log, "\nfix %d: %s\n", fixes+1, explanation.Explanation)
+		if len(explanation.Edits) == 0 {
+			return log.String(), fmt.Errorf("%v; no fix suggested", err)
+		}
+		paths, groups := groupFixEdits(explanation.Edits)
+		for _, path := range paths {
+			diff, err := a.applyFix(ctx, path, groups[path])
+			if err != nil {
+				return log.String(), fmt.Errorf("failed to apply fix to %s: %v", path, err)
+			}
+			step.Diff += diff
+		}
+	}
+}
+
+// groupFixEdits 按文件分组修改，保持文件第一次出现的顺序。同一文件的多处修改都基于原内容，
+// 逐个应用时前面的修改会使后面的行号失效，需要一次应用
+func groupFixEdits(edits []core.FixEdit) ([]string, map[string][]textedit.Edit) {
+	var paths []string
+	groups := make(map[string][]textedit.Edit)
+	for _, e := range edits {
+		if _, ok := groups[e.Path]; !ok {
+			paths = append(paths, e.Path)
+		}
+		groups[e.Path] = append(groups[e.Path], e.Edit)
+	}
+	return paths, groups
+}
+
+// applyFix 将模型建议的对同一文件的修改一次写入，返回实际写入的 diff
+func (a *Agent) applyFix(ctx context.Context, path string, edits []textedit.Edit) (string, error) {
+	result, err := a.service.EditFile(ctx, &core.FileEdit{
+		Path:  a.path(ctx, filepath.Join(a.cfg.WorkspaceRoot(), filepath.FromSlash(path))),
+		Edits: edits,
+	})
+	if err != nil {
+		return "", err
+	}
+	return result.Diff, nil
+}
+
+// updateTask 将运行状态同步到对应的任务，运行结束时关闭绑定该任务的临时自动审批窗口
+func (a *Agent) updateTask(ctx context.Context, run *Run) {
+	switch run.Status {
+	case RunStatusCompleted, RunStatusFailed, RunStatusRejected, RunStatusCanceled:
+		a.finishTaskYolo(run.TaskID)
+	}
+	if a.service == nil {
+		return
+	}
+	task, err := a.service.GetTask(ctx, run.TaskID)
+	if err != nil {
+		return
+	}
+
+	switch run.Status {
+	case RunStatusRunning:
+		task.Status = core.TaskStatusRunning
+	case RunStatusCompleted:
+		task.Status = core.TaskStatusComplete
+	case RunStatusFailed:
+		task.Status = core.TaskStatusFailed
+		task.Error = run.Error
+	case RunStatusRejected, RunStatusCanceled:
+		task.Status = core.TaskStatusCancelled
+	default:
+		return
+	}
+	a.service.UpdateTask(ctx, task)
+}
@@ -0,0 +1,108 @@
+package agent
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/liangsj/vimcoplit/internal/core"
+	"github.com/liangsj/vimcoplit/internal/core/textedit"
+	"github.com/liangsj/vimcoplit/internal/permission"
+)
+
+func TestStartUpgrade(t *testing.T) {
+	a := New(nil, filepath.Join(t.TempDir(), "runs.json"), Limits{})
+
+	if _, err := a.StartUpgrade(context.Background(), "", "", "", nil); err == nil {
+		t.Error("expected error for empty module")
+	}
+
+	run, err := a.StartUpgrade(context.Background(), "golang.org/x/text", "", "task-1", nil)
+	if err != nil {
+		t.Fatalf("failed to start upgrade: %v", err)
+	}
+	if run.Status != RunStatusAwaitingApproval {
+		t.Errorf("expected run to await approval, got %s", run.Status)
+	}
+	steps := run.Plan.Steps
+	if len(steps) != 4 {
+		t.Fatalf("expected 4 steps, got %d", len(steps))
+	}
+	if got := steps[0].Args; len(got) != 2 || got[1] != "golang.org/x/text@latest" {
+		t.Errorf("expected go get of latest version, got %v", got)
+	}
+	for _, step := range steps[2:] {
+		if step.Action != ActionVerify {
+			t.Errorf("expected verify step, got %s", step.Action)
+		}
+	}
+	if err := run.Plan.Validate(); err != nil {
+		t.Errorf("expected upgrade plan to be valid: %v", err)
+	}
+}
+
+func TestAssessPlan(t *testing.T) {
+	a := New(nil, filepath.Join(t.TempDir(), "runs.json"), Limits{})
+	plan := &Plan{Steps: []Step{
+		{Action: ActionNote, Description: "explain"},
+		{Action: ActionWriteFile, Target: "notes.txt", Content: "hi\n"},
+		{Action: ActionWriteFile, Target: "../../outside.txt", Content: "hi\n"},
+	}}
+	a.assess(context.Background(), plan)
+
+	if plan.Steps[0].Risk != nil {
+		t.Errorf("expected note step to have no risk, got %+v", plan.Steps[0].Risk)
+	}
+	if risk := plan.Steps[1].Risk; risk == nil || risk.Level != permission.RiskLow {
+		t.Errorf("expected low risk for workspace file, got %+v", risk)
+	}
+	if risk := plan.Steps[2].Risk; risk == nil || risk.Level != permission.RiskHigh {
+		t.Errorf("expected high risk for file outside workspace, got %+v", risk)
+	}
+	if !plan.HighRisk() {
+		t.Error("expected plan to be high risk")
+	}
+}
+
+func TestRenameRun(t *testing.T) {
+	a := New(nil, filepath.Join(t.TempDir(), "runs.json"), Limits{})
+	run, err := a.newRun(context.Background(), "add retries to the client", "task-1", nil)
+	if err != nil {
+		t.Fatalf("failed to create run: %v", err)
+	}
+	if _, err := a.RenameRun(run.ID, "  "); err == nil {
+		t.Error("expected error for empty title")
+	}
+	if _, err := a.RenameRun("missing", "x"); err == nil {
+		t.Error("expected error for missing run")
+	}
+	renamed, err := a.RenameRun(run.ID, " Client retries ")
+	if err != nil || renamed.Title != "Client retries" {
+		t.Fatalf("unexpected rename result %+v %v", renamed, err)
+	}
+
+	// 执行过程持有的记录没有标题，保存时保留已有的标题
+	run.Status = RunStatusFailed
+	if err := a.store.put(run); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := a.GetRun(run.ID); got.Title != "Client retries" || got.Status != RunStatusFailed {
+		t.Errorf("expected title to survive a stale put, got %+v", got)
+	}
+}
+
+func TestGroupFixEdits(t *testing.T) {
+	edits := []core.FixEdit{
+		{Path: "b.go", Edit: textedit.Edit{StartLine: 3, EndLine: 3, Replacement: "x"}},
+		{Path: "a.go", Edit: textedit.Edit{StartLine: 1, EndLine: 1, Replacement: "y"}},
+		{Path: "b.go", Edit: textedit.Edit{StartLine: 9, EndLine: 8, Replacement: "z"}},
+	}
+	paths, groups := groupFixEdits(edits)
+	if len(paths) != 2 || paths[0] != "b.go" || paths[1] != "a.go" {
+		t.Fatalf("expected files in order of first appearance, got %v", paths)
+	}
+	// 同一文件的修改一起应用，行号都基于原内容
+	if b := groups["b.go"]; len(b) != 2 || b[0].StartLine != 3 || b[1].StartLine != 9 {
+		t.Errorf("unexpected edits for b.go %+v", b)
+	}
+}
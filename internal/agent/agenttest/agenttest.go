@@ -0,0 +1,41 @@
+// Package agenttest 提供基于 cassette 回放 agent 运行的测试辅助函数
+package agenttest
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/liangsj/vimcoplit/internal/agent"
+)
+
+// Replay 加载 path 处的 cassette 并回放，返回回放结束后的运行记录，失败时终止测试
+func Replay(t testing.TB, path string) *agent.Run {
+	t.Helper()
+
+	c, err := agent.LoadCassette(path)
+	if err != nil {
+		t.Fatalf("failed to load cassette: %v", err)
+	}
+	return ReplayCassette(t, c)
+}
+
+// ReplayCassette 回放内存中的 cassette，返回回放结束后的运行记录，失败时终止测试
+func ReplayCassette(t testing.TB, c *agent.Cassette) *agent.Run {
+	t.Helper()
+
+	run, err := agent.Replay(context.Background(), c, filepath.Join(t.TempDir(), "runs.json"))
+	if err != nil {
+		t.Fatalf("failed to replay cassette: %v", err)
+	}
+	return run
+}
+
+// AssertStatus 断言运行的最终状态
+func AssertStatus(t testing.TB, run *agent.Run, want agent.RunStatus) {
+	t.Helper()
+
+	if run.Status != want {
+		t.Fatalf("expected run status %s, got %s (error: %s)", want, run.Status, run.Error)
+	}
+}
@@ -0,0 +1,343 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/liangsj/vimcoplit/internal/core"
+	"github.com/liangsj/vimcoplit/internal/core/forge"
+	"github.com/liangsj/vimcoplit/internal/core/taskbranch"
+)
+
+// maxCommitDiffBytes 是生成提交信息时交给模型的 diff 的最大长度
+const maxCommitDiffBytes = 16 << 10
+
+// FinishRequest 是任务分支的收尾操作，运行结束后由用户确认
+type FinishRequest struct {
+	Squash      bool   `json:"squash"`            // 把任务分支上的提交压缩为一个
+	Message     string `json:"message,omitempty"` // 压缩后的提交信息，为空时根据全部修改生成
+	PullRequest bool   `json:"pull_request"`      // 推送任务分支并创建以原分支为目标的 PR
+	Draft       bool   `json:"draft,omitempty"`
+}
+
+// workDirKey 是在工作区副本中执行的运行保存副本目录的 context 键
+type workDirKey struct{}
+
+// withWorkDir 让步骤在工作区副本 dir 中执行
+func withWorkDir(ctx context.Context, dir string) context.Context {
+	return context.WithValue(ctx, workDirKey{}, dir)
+}
+
+// workDir 返回执行步骤的目录，为空时在工作区中执行
+func workDir(ctx context.Context) string {
+	dir, _ := ctx.Value(workDirKey{}).(string)
+	return dir
+}
+
+// path 把工作区中的文件路径映射到运行的工作区副本中，不在副本中执行或路径在工作区外时原样返回
+func (a *Agent) path(ctx context.Context, target string) string {
+	dir := workDir(ctx)
+	if dir == "" {
+		return target
+	}
+	rel, ok := a.relPath(target)
+	if !ok {
+		return target
+	}
+	return filepath.Join(dir, rel)
+}
+
+// relPath 返回文件相对工作区根目录的路径，在工作区外时返回 false
+func (a *Agent) relPath(target string) (string, bool) {
+	rel := filepath.Clean(target)
+	if filepath.IsAbs(target) {
+		var err error
+		if rel, err = filepath.Rel(a.cfg.WorkspaceRoot(), target); err != nil {
+			return "", false
+		}
+	}
+	if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return rel, true
+}
+
+// prepare 在执行前按工作区设置保护用户未提交的修改，并在需要时进入任务分支
+func (a *Agent) prepare(ctx context.Context, id string) (*taskbranch.Branch, *taskbranch.Stash, error) {
+	if err := a.protect(ctx, id); err != nil {
+		return nil, nil, err
+	}
+	branch, err := a.enterBranch(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	run, err := a.store.get(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	return branch, run.Stash, nil
+}
+
+// protect 检查计划要修改的文件是否有用户未提交的修改，按 dirty_files 设置拒绝执行、暂存修改
+// 或改为在工作区副本中执行。使用任务分支时切换分支会带上所有修改，因此检查所有已跟踪的文件
+func (a *Agent) protect(ctx context.Context, id string) error {
+	run, err := a.store.get(id)
+	if err != nil {
+		return err
+	}
+	if a.service == nil || run.Branch != nil || run.Stash != nil {
+		return nil
+	}
+	settings := a.service.GetSettings(ctx)
+	policy := settings.DirtyFiles
+	if policy == "" || policy == core.DirtyAllow {
+		return nil
+	}
+	var paths []string
+	if !settings.TaskBranches {
+		for _, step := range run.Plan.Steps {
+			if rel, ok := a.relPath(step.Target); ok && step.Action == ActionWriteFile && !slices.Contains(paths, rel) {
+				paths = append(paths, rel)
+			}
+		}
+		if len(paths) == 0 {
+			return nil
+		}
+	}
+	root := a.cfg.WorkspaceRoot()
+	dirty, err := taskbranch.DirtyFiles(ctx, root, paths)
+	if err != nil || len(dirty) == 0 {
+		return err
+	}
+
+	switch policy {
+	case core.DirtyStash:
+		stash, err := taskbranch.StashFiles(ctx, root, "vimcoplit: run "+run.ID, dirty)
+		if err != nil {
+			return err
+		}
+		_, err = a.store.update(id, func(run *Run) error {
+			run.Stash = stash
+			return nil
+		})
+		return err
+	case core.DirtyWorktree:
+		name := taskbranch.Name(runTitle(run), run.ID)
+		dir := filepath.Join(root, ".vimcoplit", "worktrees", run.ID)
+		branch, err := taskbranch.StartWorktree(ctx, root, dir, name)
+		if err != nil {
+			return err
+		}
+		_, err = a.saveBranch(id, branch)
+		return err
+	default:
+		return fmt.Errorf("uncommitted changes in %s; commit or stash them first", strings.Join(dirty, ", "))
+	}
+}
+
+// enterBranch 在工作区设置开启 task_branches 时让运行在专用分支上执行：
+// 第一次执行时创建分支，暂停后继续时切回该分支
+func (a *Agent) enterBranch(ctx context.Context, id string) (*taskbranch.Branch, error) {
+	run, err := a.store.get(id)
+	if err != nil {
+		return nil, err
+	}
+	root := a.cfg.WorkspaceRoot()
+	if run.Branch != nil {
+		return run.Branch, run.Branch.Checkout(ctx, root)
+	}
+	if a.service == nil || !a.service.GetSettings(ctx).TaskBranches {
+		return nil, nil
+	}
+	branch, err := taskbranch.Start(ctx, root, taskbranch.Name(runTitle(run), run.ID))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := a.saveBranch(id, branch); err != nil {
+		return nil, err
+	}
+	return branch, nil
+}
+
+// runTitle 返回运行的标题，还没有生成标题时使用目标
+func runTitle(run *Run) string {
+	if run.Title != "" {
+		return run.Title
+	}
+	return run.Goal
+}
+
+// commitStep 把步骤产生的修改提交到任务分支，提交信息由模型根据 diff 生成，
+// 生成失败时使用步骤描述。步骤执行的命令产生的修改也一起提交
+func (a *Agent) commitStep(ctx context.Context, id string, branch *taskbranch.Branch, step *Step) error {
+	// 运行被取消时仍然提交已经写入的修改，只有生成提交信息会被中断
+	root, gitCtx := branch.Dir(a.cfg.WorkspaceRoot()), context.WithoutCancel(ctx)
+	diff, err := branch.Stage(gitCtx, root)
+	if err != nil || diff == "" {
+		return err
+	}
+	message := a.commitMessage(ctx, step.Description, diff)
+	if _, err := branch.Commit(gitCtx, root, message, step.ID); err != nil {
+		return err
+	}
+	_, err = a.saveBranch(id, branch)
+	return err
+}
+
+// leave 在运行结束后切回原分支或删除工作区副本，再恢复运行前暂存的修改。
+// 任务的修改只保留在任务分支上；恢复失败时 stash 保留并记录原因
+func (a *Agent) leave(ctx context.Context, id string, branch *taskbranch.Branch, stash *taskbranch.Stash) {
+	ctx, root := context.WithoutCancel(ctx), a.cfg.WorkspaceRoot()
+	if branch != nil {
+		if err := branch.Restore(ctx, root); err != nil {
+			log.Printf("切回分支 %s 失败: %v\n", branch.Original, err)
+			if stash != nil {
+				// 还在任务分支上时恢复会把用户的修改带进任务分支
+				stash.Error = fmt.Sprintf("not restored because the workspace is still on %s", branch.Name)
+				a.saveStash(id, stash)
+				return
+			}
+		}
+	}
+	if stash == nil {
+		return
+	}
+	if err := stash.Restore(ctx, root); err != nil {
+		log.Printf("恢复暂存的修改失败: %v\n", err)
+		stash.Error = err.Error()
+	}
+	a.saveStash(id, stash)
+}
+
+// saveStash 保存暂存的修改的恢复状态
+func (a *Agent) saveStash(id string, stash *taskbranch.Stash) {
+	a.store.update(id, func(run *Run) error {
+		run.Stash = stash.Clone()
+		return nil
+	})
+}
+
+// commitMessage 让模型为 diff 生成提交信息，失败时返回 fallback
+func (a *Agent) commitMessage(ctx context.Context, fallback, diff string) string {
+	if len(diff) > maxCommitDiffBytes {
+		diff = diff[:maxCommitDiffBytes] + "\n... (diff truncated)"
+	}
+	output, err := a.service.GenerateResponse(ctx, commitPrompt(fallback, diff, a.cfg.CommitLanguage()))
+	message := strings.TrimSpace(stripCodeFence(output))
+	if err != nil || message == "" {
+		if fallback = strings.TrimSpace(fallback); fallback == "" {
+			fallback = "Apply agent changes"
+		}
+		return fallback
+	}
+	return message
+}
+
+// commitPrompt 返回为 agent 的修改生成提交信息的提示词
+func commitPrompt(description, diff, language string) string {
+	prompt := "Write a git commit message for the following changes: a short imperative subject line " +
+		"under 72 characters, a blank line, then a brief body explaining what changed and why. " +
+		"Output only the message."
+	if language != "" {
+		prompt += " Write the message in " + language + "."
+	}
+	if description != "" {
+		prompt += "\nThe changes were made for this task: " + description
+	}
+	return prompt + "\n\n" + diff
+}
+
+// FinishBranch 对已结束的运行的任务分支执行收尾操作：压缩提交、推送并创建 PR。
+// 操作不切换工作区的分支，用户的工作分支保持不变
+func (a *Agent) FinishBranch(ctx context.Context, id string, req *FinishRequest) (*Run, error) {
+	run, err := a.store.get(id)
+	if err != nil {
+		return nil, err
+	}
+	switch run.Status {
+	case RunStatusCompleted, RunStatusFailed, RunStatusCanceled:
+	default:
+		return nil, fmt.Errorf("run cannot be finished in status %s", run.Status)
+	}
+	branch := run.Branch
+	if branch == nil {
+		return nil, errors.New("run has no task branch")
+	}
+	if len(branch.Commits) == 0 {
+		return nil, errors.New("the task branch has no commits")
+	}
+	root := a.cfg.WorkspaceRoot()
+
+	if req.Squash && !branch.Squashed {
+		if branch.PullRequest != "" {
+			return nil, errors.New("cannot squash after the pull request was created")
+		}
+		message := strings.TrimSpace(req.Message)
+		if message == "" {
+			diff, err := branch.Diff(ctx, root)
+			if err != nil {
+				return nil, err
+			}
+			message = a.commitMessage(ctx, run.Goal, diff)
+		}
+		if _, err := branch.Squash(ctx, root, message); err != nil {
+			return nil, err
+		}
+		if run, err = a.saveBranch(id, branch); err != nil {
+			return nil, err
+		}
+	}
+
+	if req.PullRequest {
+		if branch.PullRequest != "" {
+			return nil, fmt.Errorf("pull request already created: %s", branch.PullRequest)
+		}
+		title, body := pullRequestText(run)
+		pr, err := a.service.CreatePullRequest(ctx, &core.PullRequestRequest{
+			NewPullRequest: forge.NewPullRequest{Title: title, Body: body, Head: branch.Name, Base: branch.Original, Draft: req.Draft},
+			Push:           true,
+		})
+		if err != nil {
+			return nil, err
+		}
+		branch.PullRequest = pr.URL
+		if run, err = a.saveBranch(id, branch); err != nil {
+			return nil, err
+		}
+	}
+	return run, nil
+}
+
+// saveBranch 保存任务分支的状态
+func (a *Agent) saveBranch(id string, branch *taskbranch.Branch) (*Run, error) {
+	return a.store.update(id, func(run *Run) error {
+		run.Branch = branch.Clone()
+		return nil
+	})
+}
+
+// pullRequestText 返回任务分支 PR 的标题和描述：只有一个提交时使用提交信息，
+// 否则使用运行的标题，描述中列出目标和各个提交
+func pullRequestText(run *Run) (string, string) {
+	commits := run.Branch.Commits
+	if len(commits) == 1 {
+		subject, body, _ := strings.Cut(commits[0].Message, "\n")
+		return strings.TrimSpace(subject), strings.TrimSpace(body)
+	}
+	title := run.Title
+	if title == "" {
+		title, _, _ = strings.Cut(run.Goal, "\n")
+	}
+	var body strings.Builder
+	body.WriteString(run.Goal + "\n")
+	for _, c := range commits {
+		subject, _, _ := strings.Cut(c.Message, "\n")
+		fmt.Fprintf(&body, "\n- %s", subject)
+	}
+	return strings.TrimSpace(title), body.String()
+}
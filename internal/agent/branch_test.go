@@ -0,0 +1,62 @@
+package agent
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/liangsj/vimcoplit/internal/core/taskbranch"
+)
+
+func TestPullRequestText(t *testing.T) {
+	run := &Run{Goal: "add retries to the client", Title: "Client retries", Branch: &taskbranch.Branch{
+		Commits: []taskbranch.Commit{{Message: "Add retry helper\n\nRetries idempotent requests."}},
+	}}
+	if title, body := pullRequestText(run); title != "Add retry helper" || body != "Retries idempotent requests." {
+		t.Errorf("expected the single commit message to be used, got %q %q", title, body)
+	}
+
+	run.Branch.Commits = append(run.Branch.Commits, taskbranch.Commit{Message: "Use retries in the client"})
+	title, body := pullRequestText(run)
+	if title != "Client retries" || !strings.Contains(body, "- Add retry helper\n- Use retries in the client") {
+		t.Errorf("expected the run title and a commit list, got %q %q", title, body)
+	}
+}
+
+func TestFinishBranchRequiresBranch(t *testing.T) {
+	a := New(nil, filepath.Join(t.TempDir(), "runs.json"), Limits{})
+	run, err := a.newRun(context.Background(), "add retries to the client", "task-1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.FinishBranch(context.Background(), run.ID, &FinishRequest{Squash: true}); err == nil {
+		t.Error("expected a run that has not finished to be rejected")
+	}
+	run.Status = RunStatusCompleted
+	a.store.put(run)
+	if _, err := a.FinishBranch(context.Background(), run.ID, &FinishRequest{Squash: true}); err == nil {
+		t.Error("expected a run without a task branch to be rejected")
+	}
+}
+
+func TestPathInWorktree(t *testing.T) {
+	a := New(nil, filepath.Join(t.TempDir(), "runs.json"), Limits{})
+	root := a.cfg.WorkspaceRoot()
+	if got := a.path(context.Background(), "main.go"); got != "main.go" {
+		t.Errorf("expected paths to be unchanged outside a worktree, got %s", got)
+	}
+
+	ctx := withWorkDir(context.Background(), "/tmp/wt")
+	tests := map[string]string{
+		"main.go":                             filepath.Join("/tmp/wt", "main.go"),
+		filepath.Join(root, "pkg", "a.go"):    filepath.Join("/tmp/wt", "pkg", "a.go"),
+		"../outside.txt":                      "../outside.txt",
+		filepath.Join(root, "..", "other.go"): filepath.Join(root, "..", "other.go"),
+	}
+	for target, want := range tests {
+		if got := a.path(ctx, target); got != want {
+			t.Errorf("%s: expected %s, got %s", target, want, got)
+		}
+	}
+}
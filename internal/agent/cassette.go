@@ -0,0 +1,134 @@
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// InteractionKind 表示 agent 与外部交互的类型
+type InteractionKind string
+
+const (
+	InteractionModel InteractionKind = "model"
+	InteractionStep  InteractionKind = "step"
+)
+
+// Interaction 记录一次模型调用或步骤执行的输入和结果
+type Interaction struct {
+	Kind   InteractionKind `json:"kind"`
+	Input  string          `json:"input"`
+	Output string          `json:"output"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// Cassette 记录一次运行中所有模型响应和工具结果，用于确定性回放
+type Cassette struct {
+	RunID        string        `json:"run_id"`
+	Goal         string        `json:"goal"`
+	Plan         *Plan         `json:"plan,omitempty"` // 审批时的计划，包含用户的编辑
+	Interactions []Interaction `json:"interactions"`
+
+	pos int
+	err error
+}
+
+// LoadCassette 从文件加载 cassette
+func LoadCassette(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c Cassette
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("invalid cassette: %v", err)
+	}
+	return &c, nil
+}
+
+// Save 将 cassette 保存到文件
+func (c *Cassette) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// next 按顺序取出下一条交互，类型或输入与录制时不一致时返回错误
+func (c *Cassette) next(kind InteractionKind, input string) (string, error) {
+	if c.err != nil {
+		return "", c.err
+	}
+	if c.pos >= len(c.Interactions) {
+		c.err = fmt.Errorf("cassette exhausted: unexpected %s interaction", kind)
+		return "", c.err
+	}
+
+	interaction := c.Interactions[c.pos]
+	if interaction.Kind != kind || interaction.Input != input {
+		c.err = fmt.Errorf("cassette mismatch at interaction %d: expected %s, got %s", c.pos+1, interaction.Kind, kind)
+		return "", c.err
+	}
+	c.pos++
+
+	if interaction.Error != "" {
+		return interaction.Output, errors.New(interaction.Error)
+	}
+	return interaction.Output, nil
+}
+
+// cassetteStore 将每次运行的 cassette 保存在目录下的 <run_id>.json
+type cassetteStore struct {
+	mu  sync.Mutex
+	dir string
+}
+
+// path 返回运行对应的 cassette 文件路径
+func (s *cassetteStore) path(runID string) string {
+	return filepath.Join(s.dir, runID+".json")
+}
+
+// update 加载运行的 cassette，修改后保存，不存在时新建
+func (s *cassetteStore) update(runID string, fn func(c *Cassette)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, err := LoadCassette(s.path(runID))
+	if errors.Is(err, os.ErrNotExist) {
+		c, err = &Cassette{RunID: runID}, nil
+	}
+	if err != nil {
+		return err
+	}
+	fn(c)
+	return c.Save(s.path(runID))
+}
+
+// stepKey 返回步骤动作的规范表示，作为步骤交互的输入
+func stepKey(step *Step) string {
+	data, _ := json.Marshal(Step{
+		Action:  step.Action,
+		Target:  step.Target,
+		Command: step.Command,
+		Args:    step.Args,
+		Content: step.Content,
+		Tool:    step.Tool,
+		Params:  step.Params,
+	})
+	return string(data)
+}
+
+// errorString 返回错误信息，err 为 nil 时返回空字符串
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
@@ -0,0 +1,173 @@
+package agent
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/liangsj/vimcoplit/internal/core/merge"
+)
+
+// Patch 是补丁序列中的一个补丁，对应一个已完成的写文件步骤
+type Patch struct {
+	Name    string `json:"name"` // 如 0001-update-readme.patch
+	Step    int    `json:"step"` // 对应的步骤序号，从 1 开始
+	Content string `json:"content"`
+}
+
+// Export 是一次运行的可重放产物：按执行顺序的 shell 脚本和补丁序列
+type Export struct {
+	RunID   string  `json:"run_id"`
+	Goal    string  `json:"goal"`
+	Script  string  `json:"script"`
+	Patches []Patch `json:"patches"`
+}
+
+// Mbox 将补丁序列合并为 mbox 格式，可以直接用 git am 应用
+func (e *Export) Mbox() string {
+	var out strings.Builder
+	for _, p := range e.Patches {
+		out.WriteString(p.Content)
+	}
+	return out.String()
+}
+
+// Export 导出运行中已完成的步骤，只包含命令和写文件步骤实际产生的效果
+func (a *Agent) Export(id string) (*Export, error) {
+	run, err := a.store.get(id)
+	if err != nil {
+		return nil, err
+	}
+	if run.Plan == nil {
+		return nil, fmt.Errorf("run has no plan")
+	}
+	return exportRun(run), nil
+}
+
+// exportRun 根据运行记录生成脚本和补丁序列
+func exportRun(run *Run) *Export {
+	export := &Export{RunID: run.ID, Goal: run.Goal, Patches: []Patch{}}
+
+	// 写文件步骤和自动修复过代码的验证步骤各生成一个补丁
+	var writes []int
+	for i, step := range run.Plan.Steps {
+		if step.Status == StepStatusCompleted && (step.Action == ActionWriteFile || step.Diff != "") {
+			writes = append(writes, i)
+		}
+	}
+	for n, i := range writes {
+		step := run.Plan.Steps[i]
+		export.Patches = append(export.Patches, Patch{
+			Name:    fmt.Sprintf("%04d-%s.patch", n+1, slug(step.Description)),
+			Step:    i + 1,
+			Content: formatPatch(run, step, n+1, len(writes)),
+		})
+	}
+
+	var script strings.Builder
+	fmt.Fprintf(&script, "#!/bin/sh\n# vimcoplit run %s\n# goal: %s\n", run.ID, oneLine(run.Goal))
+	script.WriteString("# run from the repository root, or pass the target directory as the first argument\nset -eu\ncd \"${1:-.}\"\n")
+	for i, step := range run.Plan.Steps {
+		if step.Status != StepStatusCompleted {
+			continue
+		}
+		fmt.Fprintf(&script, "\n# step %d: %s\n", i+1, oneLine(step.Description))
+		switch step.Action {
+		case ActionCommand:
+			script.WriteString(commandLine(step) + "\n")
+		case ActionVerify:
+			// 先应用验证失败时模型做的修复，再执行一次验证命令
+			if step.Diff != "" {
+				writeHeredoc(&script, "git apply", step.Diff)
+			}
+			script.WriteString(commandLine(step) + "\n")
+		case ActionWriteFile:
+			if step.Diff != "" {
+				writeHeredoc(&script, "git apply", step.Diff)
+			} else {
+				// 回放或旧的运行记录没有 diff，直接写入完整内容
+				writeHeredoc(&script, "cat > "+shellQuote(step.Target), step.Content)
+			}
+		case ActionTool:
+			fmt.Fprintf(&script, "# MCP tool %s cannot be replayed from a shell script\n", oneLine(step.Tool))
+		}
+	}
+	export.Script = script.String()
+	return export
+}
+
+// commandLine 返回步骤的命令行，参数按 shell 规则转义
+func commandLine(step Step) string {
+	words := []string{shellQuote(step.Command)}
+	for _, arg := range step.Args {
+		words = append(words, shellQuote(arg))
+	}
+	return strings.Join(words, " ")
+}
+
+// writeHeredoc 将 body 作为 here-document 传给命令 cmd
+func writeHeredoc(script *strings.Builder, cmd, body string) {
+	delim := heredocDelimiter(body)
+	fmt.Fprintf(script, "%s <<'%s'\n%s", cmd, delim, body)
+	if !strings.HasSuffix(body, "\n") {
+		script.WriteString("\n")
+	}
+	script.WriteString(delim + "\n")
+}
+
+// formatPatch 按 git format-patch 的格式生成第 n 个补丁
+func formatPatch(run *Run, step Step, n, total int) string {
+	var out strings.Builder
+	out.WriteString("From 0000000000000000000000000000000000000000 Mon Sep 17 00:00:00 2001\n")
+	out.WriteString("From: vimcoplit <vimcoplit@localhost>\n")
+	fmt.Fprintf(&out, "Date: %s\n", run.UpdatedAt.Format(time.RFC1123Z))
+	fmt.Fprintf(&out, "Subject: [PATCH %d/%d] %s\n\n", n, total, oneLine(step.Description))
+	fmt.Fprintf(&out, "Goal: %s\nRun: %s\n---\n", oneLine(run.Goal), run.ID)
+	diff := step.Diff
+	if diff == "" {
+		diff = merge.Diff(step.Target, "", step.Content)
+	}
+	out.WriteString(diff)
+	out.WriteString("-- \nvimcoplit\n\n")
+	return out.String()
+}
+
+// slugPattern 匹配补丁文件名中不允许的字符
+var slugPattern = regexp.MustCompile(`[^a-z0-9]+`)
+
+// slug 将步骤描述转换为补丁文件名
+func slug(s string) string {
+	s = strings.Trim(slugPattern.ReplaceAllString(strings.ToLower(s), "-"), "-")
+	if len(s) > 50 {
+		s = strings.TrimRight(s[:50], "-")
+	}
+	if s == "" {
+		return "step"
+	}
+	return s
+}
+
+// oneLine 将多行文本合并为一行，用于注释和补丁标题
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// shellQuote 用单引号转义 shell 参数
+func shellQuote(s string) string {
+	if s != "" && strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./=:,+@%", r))
+	}) < 0 {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// heredocDelimiter 返回不会出现在内容中的 here-document 结束标记
+func heredocDelimiter(body string) string {
+	delim := "VIMCOPLIT_EOF"
+	for i := 1; strings.Contains(body, delim); i++ {
+		delim = fmt.Sprintf("VIMCOPLIT_EOF_%d", i)
+	}
+	return delim
+}
@@ -0,0 +1,70 @@
+package agent
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportRun(t *testing.T) {
+	run := &Run{
+		ID:        "run-1",
+		Goal:      "add greeting",
+		UpdatedAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		Plan: &Plan{Steps: []Step{
+			{Description: "Write hello.txt", Action: ActionWriteFile, Target: "hello.txt", Content: "hi\n",
+				Diff: "--- /dev/null\n+++ b/hello.txt\n@@ -0,0 +1 @@\n+hi\n", Status: StepStatusCompleted},
+			{Description: "list files", Action: ActionCommand, Command: "ls", Args: []string{"-l", "it's"}, Status: StepStatusCompleted},
+			{Description: "skipped", Action: ActionCommand, Command: "rm", Status: StepStatusSkipped},
+			{Description: "Replayed write", Action: ActionWriteFile, Target: "b.txt", Content: "b", Status: StepStatusCompleted},
+			{Description: "Build and fix breakages", Action: ActionVerify, Command: "go", Args: []string{"build", "./..."},
+				Diff: "--- a/b.txt\n+++ b/b.txt\n@@ -1 +1 @@\n-b\n+c\n", Status: StepStatusCompleted},
+			{Description: "Test", Action: ActionVerify, Command: "go", Args: []string{"test", "./..."}, Status: StepStatusCompleted},
+		}},
+	}
+
+	export := exportRun(run)
+	if len(export.Patches) != 3 {
+		t.Fatalf("expected 3 patches, got %d", len(export.Patches))
+	}
+	if p := export.Patches[0]; p.Name != "0001-write-hello-txt.patch" || p.Step != 1 {
+		t.Errorf("unexpected first patch %q for step %d", p.Name, p.Step)
+	}
+	if !strings.Contains(export.Patches[0].Content, "Subject: [PATCH 1/3] Write hello.txt\n") {
+		t.Errorf("expected patch subject, got:\n%s", export.Patches[0].Content)
+	}
+	if !strings.Contains(export.Patches[1].Content, "+++ b/b.txt\n") {
+		t.Errorf("expected diff generated from content, got:\n%s", export.Patches[1].Content)
+	}
+
+	for _, want := range []string{
+		"git apply <<'VIMCOPLIT_EOF'\n--- /dev/null\n",
+		"ls -l 'it'\\''s'\n",
+		"cat > b.txt <<'VIMCOPLIT_EOF'\nb\nVIMCOPLIT_EOF\n",
+		"+c\nVIMCOPLIT_EOF\ngo build ./...\n",
+		"go test ./...\n",
+	} {
+		if !strings.Contains(export.Script, want) {
+			t.Errorf("expected script to contain %q, got:\n%s", want, export.Script)
+		}
+	}
+	if strings.Contains(export.Script, "rm") {
+		t.Errorf("expected skipped steps to be omitted, got:\n%s", export.Script)
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	tests := map[string]string{
+		"go":        "go",
+		"./...":     "./...",
+		"":          "''",
+		"a b":       "'a b'",
+		"$(rm -rf)": "'$(rm -rf)'",
+		"it's":      `'it'\''s'`,
+	}
+	for in, want := range tests {
+		if got := shellQuote(in); got != want {
+			t.Errorf("shellQuote(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package agent
+
+import "fmt"
+
+// Limits 定义单次运行的资源上限，0 表示不限制
+type Limits struct {
+	MaxTokens        int `json:"max_tokens,omitempty"`
+	MaxToolCalls     int `json:"max_tool_calls,omitempty"`
+	MaxDuration      int `json:"max_duration,omitempty"` // 秒
+	MaxFilesModified int `json:"max_files_modified,omitempty"`
+}
+
+// Usage 记录单次运行已经消耗的资源
+type Usage struct {
+	Tokens        int      `json:"tokens"`
+	ToolCalls     int      `json:"tool_calls"`
+	Duration      float64  `json:"duration"` // 秒，不包含暂停的时间
+	FilesModified []string `json:"files_modified,omitempty"`
+}
+
+// merge 返回用 override 中非零字段覆盖后的上限
+func (l Limits) merge(override *Limits) Limits {
+	if override == nil {
+		return l
+	}
+	if override.MaxTokens != 0 {
+		l.MaxTokens = override.MaxTokens
+	}
+	if override.MaxToolCalls != 0 {
+		l.MaxToolCalls = override.MaxToolCalls
+	}
+	if override.MaxDuration != 0 {
+		l.MaxDuration = override.MaxDuration
+	}
+	if override.MaxFilesModified != 0 {
+		l.MaxFilesModified = override.MaxFilesModified
+	}
+	return l
+}
+
+// check 检查执行 step 之前是否已经或将要超出上限，返回超出原因
+func (l Limits) check(u *Usage, step *Step) string {
+	if l.MaxTokens > 0 && u.Tokens >= l.MaxTokens {
+		return fmt.Sprintf("token budget exhausted: used %d of %d", u.Tokens, l.MaxTokens)
+	}
+	if l.MaxDuration > 0 && u.Duration >= float64(l.MaxDuration) {
+		return fmt.Sprintf("time budget exhausted: ran %.0fs of %ds", u.Duration, l.MaxDuration)
+	}
+
+	switch step.Action {
+	case ActionCommand, ActionTool, ActionTools, ActionVerify:
+		if l.MaxToolCalls > 0 && u.ToolCalls+1 > l.MaxToolCalls {
+			return fmt.Sprintf("tool call limit reached: %d of %d", u.ToolCalls, l.MaxToolCalls)
+		}
+	case ActionWriteFile:
+		if l.MaxFilesModified > 0 && !u.modified(step.Target) && len(u.FilesModified)+1 > l.MaxFilesModified {
+			return fmt.Sprintf("modified file limit reached: %d of %d", len(u.FilesModified), l.MaxFilesModified)
+		}
+	}
+	return ""
+}
+
+// record 记录执行 step 所消耗的资源
+func (u *Usage) record(step *Step) {
+	switch step.Action {
+	case ActionCommand, ActionTool, ActionTools, ActionVerify:
+		u.ToolCalls++
+	case ActionWriteFile:
+		if !u.modified(step.Target) {
+			u.FilesModified = append(u.FilesModified, step.Target)
+		}
+	}
+}
+
+// modified 判断文件是否已经被修改过
+func (u *Usage) modified(path string) bool {
+	for _, p := range u.FilesModified {
+		if p == path {
+			return true
+		}
+	}
+	return false
+}
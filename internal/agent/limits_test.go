@@ -0,0 +1,41 @@
+package agent
+
+import "testing"
+
+func TestLimitsCheck(t *testing.T) {
+	limits := Limits{MaxToolCalls: 1, MaxFilesModified: 1}
+	usage := &Usage{}
+
+	command := &Step{Action: ActionCommand, Command: "go"}
+	if reason := limits.check(usage, command); reason != "" {
+		t.Fatalf("expected first command to be allowed, got %q", reason)
+	}
+	usage.record(command)
+	if reason := limits.check(usage, command); reason == "" {
+		t.Error("expected second command to exceed tool call limit")
+	}
+
+	write := &Step{Action: ActionWriteFile, Target: "a.go"}
+	usage.record(write)
+	if reason := limits.check(usage, write); reason != "" {
+		t.Errorf("expected rewriting the same file to be allowed, got %q", reason)
+	}
+	if reason := limits.check(usage, &Step{Action: ActionWriteFile, Target: "b.go"}); reason == "" {
+		t.Error("expected new file to exceed modified file limit")
+	}
+
+	if reason := (Limits{}).check(usage, command); reason != "" {
+		t.Errorf("expected zero limits to be unlimited, got %q", reason)
+	}
+}
+
+func TestLimitsMerge(t *testing.T) {
+	defaults := Limits{MaxTokens: 100, MaxToolCalls: 5}
+	merged := defaults.merge(&Limits{MaxToolCalls: 10})
+	if merged.MaxTokens != 100 || merged.MaxToolCalls != 10 {
+		t.Errorf("unexpected merged limits: %+v", merged)
+	}
+	if defaults.merge(nil) != defaults {
+		t.Error("expected nil override to keep defaults")
+	}
+}
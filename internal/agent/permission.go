@@ -0,0 +1,144 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/liangsj/vimcoplit/internal/core/merge"
+	"github.com/liangsj/vimcoplit/internal/permission"
+)
+
+// sideEffectTools 是会执行代码、发出请求或修改外部状态的内置工具，没有匹配的规则时也需要询问
+var sideEffectTools = map[string]bool{
+	"run_code_blocks": true,
+	"http_request":    true,
+	"sql_query":       true,
+	"forge_comment":   true,
+	"forge_create_pr": true,
+}
+
+// PendingPermissions 返回等待插件回复的权限请求
+func (a *Agent) PendingPermissions() []*permission.Request {
+	return a.permissions.Pending()
+}
+
+// RespondPermission 回复权限请求，等待该请求的步骤随即继续或失败
+func (a *Agent) RespondPermission(id string, answer permission.Answer) (*permission.Request, error) {
+	return a.permissions.Respond(id, answer)
+}
+
+// authorize 在执行步骤前按工作区的策略规则检查权限，规则要求询问时发出权限请求并等待回复。
+// 没有匹配的规则时直接放行，计划本身已经经过审批，但 sideEffectTools 中的工具仍然询问；
+// 临时自动审批窗口内询问的步骤自动允许一次
+func (a *Agent) authorize(ctx context.Context, run *Run, step *Step) error {
+	if a.service == nil || a.replay != nil {
+		return nil
+	}
+	op, target := permissionTarget(step)
+	if op == "" {
+		return nil
+	}
+
+	decision, ok := permission.Evaluate(a.service.GetSettings(ctx).Permissions, op, target)
+	if !ok && op == permission.OpTool && sideEffectTools[target] {
+		decision, ok = permission.DecisionAsk, true
+	}
+	if !ok || decision == permission.DecisionAllow {
+		return nil
+	}
+	if decision == permission.DecisionDeny {
+		return fmt.Errorf("%s %q is denied by permission policy", op, target)
+	}
+	if a.useYolo(run.TaskID, func(w *YoloWindow) { w.Allowed++ }) {
+		return nil
+	}
+
+	req := &permission.Request{
+		RunID:     run.ID,
+		StepID:    step.ID,
+		Operation: op,
+		Target:    target,
+		Risk:      permission.DefaultRisk(op),
+	}
+	if step.Risk != nil {
+		req.Risk = step.Risk.Level
+	}
+	if op == permission.OpWriteFile {
+		req.Diff = a.previewDiff(ctx, step)
+	}
+	answer, err := a.permissions.Ask(ctx, req)
+	if err != nil {
+		return err
+	}
+	switch answer {
+	case permission.AnswerDeny:
+		return errors.New("permission denied")
+	case permission.AnswerAllowAlways:
+		rule := permission.Rule{Operation: op, Target: target, Decision: permission.DecisionAllow}
+		if err := a.service.AddPermissionRule(ctx, rule); err != nil {
+			log.Printf("保存权限规则失败: %v\n", err)
+		}
+	}
+	return nil
+}
+
+// AuthorizeTool 按权限策略检查不属于任何运行的工具调用，用于 /api/generate/tools 中模型请求的调用。
+// 只有不绑定任务的临时自动审批窗口对这些调用生效，没有规则放行的副作用工具等待插件回复权限请求
+func (a *Agent) AuthorizeTool(ctx context.Context, tool string, params map[string]interface{}) error {
+	return a.authorizeTool(ctx, &Run{}, "", tool, params)
+}
+
+// authorizeTool 评估模型请求的工具调用的风险，再与计划中的工具步骤一样检查权限
+func (a *Agent) authorizeTool(ctx context.Context, run *Run, stepID, tool string, params map[string]interface{}) error {
+	plan := &Plan{Steps: []Step{{ID: stepID, Action: ActionTool, Tool: tool, Params: params}}}
+	a.assess(ctx, plan)
+	return a.authorize(ctx, run, &plan.Steps[0])
+}
+
+// assess 评估计划中每个步骤的风险，写文件步骤与目标文件的当前内容比较
+func (a *Agent) assess(ctx context.Context, plan *Plan) {
+	scorer := &permission.Scorer{Workspace: a.cfg.WorkspaceRoot(), AllowedCmds: a.cfg.Command.AllowedCmds}
+	if a.service != nil {
+		scorer.AllowedCmds = a.service.AllowedCommands()
+	}
+	for i := range plan.Steps {
+		step := &plan.Steps[i]
+		op, target := permissionTarget(step)
+		if op == "" {
+			step.Risk = nil
+			continue
+		}
+		action := &permission.Action{Operation: op, Target: target, Command: step.Command, Content: step.Content}
+		if op == permission.OpWriteFile && a.service != nil {
+			current, _ := a.service.ReadFile(ctx, step.Target)
+			action.Current = string(current)
+		}
+		step.Risk = scorer.Score(action)
+	}
+}
+
+// permissionTarget 返回步骤需要权限的操作和目标，不需要权限的步骤返回空操作
+func permissionTarget(step *Step) (permission.Operation, string) {
+	switch step.Action {
+	case ActionCommand, ActionVerify:
+		return permission.OpCommand, strings.Join(append([]string{step.Command}, step.Args...), " ")
+	case ActionWriteFile:
+		return permission.OpWriteFile, step.Target
+	case ActionTool:
+		return permission.OpTool, step.Tool
+	}
+	return "", ""
+}
+
+// previewDiff 返回写文件步骤将写入的 diff，不包括审阅时被拒绝的段；文件不存在时视为空文件
+func (a *Agent) previewDiff(ctx context.Context, step *Step) string {
+	base, f, err := a.plannedPatch(ctx, step)
+	if err != nil {
+		current, _ := a.service.ReadFile(ctx, step.Target)
+		return merge.Diff(step.Target, string(current), step.Content)
+	}
+	return merge.Diff(step.Target, base, f.Apply(base))
+}
@@ -0,0 +1,74 @@
+package agent
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/liangsj/vimcoplit/internal/config"
+	"github.com/liangsj/vimcoplit/internal/core"
+	"github.com/liangsj/vimcoplit/internal/events"
+	"github.com/liangsj/vimcoplit/internal/permission"
+)
+
+// policyService 只提供权限检查用到的方法，其余方法调用时 panic
+type policyService struct {
+	core.Service
+	rules []permission.Rule
+}
+
+func (s *policyService) Config() *config.Config    { return config.DefaultConfig() }
+func (s *policyService) Events() *events.Bus       { return nil }
+func (s *policyService) AllowedCommands() []string { return nil }
+func (s *policyService) GetSettings(ctx context.Context) *core.WorkspaceSettings {
+	return &core.WorkspaceSettings{Permissions: s.rules}
+}
+
+func TestAuthorizeToolDefault(t *testing.T) {
+	tests := []struct {
+		name  string
+		tool  string
+		rules []permission.Rule
+		asks  bool
+	}{
+		{"read-only tool", "forge_read", nil, false},
+		{"unmatched code execution", "run_code_blocks", nil, true},
+		{"unmatched http request", "http_request", nil, true},
+		{"unmatched sql query", "sql_query", nil, true},
+		{"unmatched pull request", "forge_create_pr", nil, true},
+		{"allowed by rule", "sql_query", []permission.Rule{{Operation: permission.OpTool, Target: "sql_*", Decision: permission.DecisionAllow}}, false},
+	}
+	for _, tt := range tests {
+		a := New(&policyService{rules: tt.rules}, filepath.Join(t.TempDir(), "runs.json"), Limits{})
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		err := a.AuthorizeTool(ctx, tt.tool, nil)
+		cancel()
+		if tt.asks && err == nil {
+			t.Errorf("%s: expected the call to be refused without an answer", tt.name)
+		}
+		if !tt.asks && err != nil {
+			t.Errorf("%s: expected the call to be allowed, got %v", tt.name, err)
+		}
+	}
+
+	// 插件拒绝时调用失败，允许时放行
+	a := New(&policyService{}, filepath.Join(t.TempDir(), "runs.json"), Limits{})
+	for answer, allowed := range map[permission.Answer]bool{permission.AnswerDeny: false, permission.AnswerAllowOnce: true} {
+		done := make(chan error, 1)
+		go func() { done <- a.AuthorizeTool(context.Background(), "http_request", nil) }()
+		for len(a.PendingPermissions()) == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		req := a.PendingPermissions()[0]
+		if req.Operation != permission.OpTool || req.Target != "http_request" {
+			t.Errorf("unexpected permission request %+v", req)
+		}
+		if _, err := a.RespondPermission(req.ID, answer); err != nil {
+			t.Fatal(err)
+		}
+		if err := <-done; (err == nil) != allowed {
+			t.Errorf("%s: expected allowed=%v, got %v", answer, allowed, err)
+		}
+	}
+}
@@ -0,0 +1,287 @@
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/liangsj/vimcoplit/internal/core/schema"
+	"github.com/liangsj/vimcoplit/internal/core/syntax"
+	"github.com/liangsj/vimcoplit/internal/core/taskbranch"
+	"github.com/liangsj/vimcoplit/internal/core/textedit"
+	"github.com/liangsj/vimcoplit/internal/permission"
+)
+
+// RunStatus 表示一次 agent 运行的状态
+type RunStatus string
+
+const (
+	RunStatusPlanning         RunStatus = "planning"
+	RunStatusAwaitingApproval RunStatus = "awaiting_approval"
+	RunStatusRunning          RunStatus = "running"
+	RunStatusPaused           RunStatus = "paused"
+	RunStatusCompleted        RunStatus = "completed"
+	RunStatusFailed           RunStatus = "failed"
+	RunStatusRejected         RunStatus = "rejected"
+	RunStatusCanceled         RunStatus = "canceled"
+)
+
+// ActionType 表示计划步骤的动作类型
+type ActionType string
+
+const (
+	ActionCommand   ActionType = "command"
+	ActionWriteFile ActionType = "write_file"
+	ActionTool      ActionType = "tool"
+	ActionNote      ActionType = "note"
+	ActionVerify    ActionType = "verify" // 执行命令，失败时由模型修复后重试
+	ActionTools     ActionType = "tools"  // 由模型调用 MCP 工具完成步骤描述的任务
+)
+
+// StepStatus 表示计划步骤的执行状态
+type StepStatus string
+
+const (
+	StepStatusPending   StepStatus = "pending"
+	StepStatusRunning   StepStatus = "running"
+	StepStatusCompleted StepStatus = "completed"
+	StepStatusFailed    StepStatus = "failed"
+	StepStatusSkipped   StepStatus = "skipped"
+)
+
+// Step 表示计划中的一个步骤
+type Step struct {
+	ID            string                 `json:"id"`
+	Description   string                 `json:"description"`
+	Action        ActionType             `json:"action"`
+	Target        string                 `json:"target,omitempty"`
+	Command       string                 `json:"command,omitempty"`
+	Args          []string               `json:"args,omitempty"`
+	Content       string                 `json:"content,omitempty"`
+	Edits         []textedit.Edit        `json:"edits,omitempty"`          // 写文件步骤的多处范围修改，不为空时忽略 Content
+	RejectedHunks []string               `json:"rejected_hunks,omitempty"` // 审阅时被拒绝的段 ID，执行时只写入其余的段
+	Tool          string                 `json:"tool,omitempty"`
+	Params        map[string]interface{} `json:"params,omitempty"`
+	Override      bool                   `json:"override,omitempty"`  // 用户已确认跳过输出过滤
+	BaseHash      string                 `json:"base_hash,omitempty"` // 计划时目标文件内容的摘要，执行时据此检测冲突
+	Status        StepStatus             `json:"status"`
+	Output        string                 `json:"output,omitempty"`
+	Diff          string                 `json:"diff,omitempty"` // 写文件步骤实际写入的统一 diff
+	Risk          *permission.Assessment `json:"risk,omitempty"` // 计划生成或编辑时的风险评估
+	Error         string                 `json:"error,omitempty"`
+}
+
+// Plan 表示 agent 在执行前给出的结构化计划
+type Plan struct {
+	Steps     []Step    `json:"steps"`
+	Version   int       `json:"version"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Run 表示一次 agent 运行：先生成计划，经用户编辑和审批后按步骤执行
+type Run struct {
+	ID     string    `json:"id"`
+	TaskID string    `json:"task_id"`
+	Goal   string    `json:"goal"`
+	Title  string    `json:"title,omitempty"` // 根据目标自动生成的简短标题，可以重命名
+	Status RunStatus `json:"status"`
+	Plan   *Plan     `json:"plan,omitempty"`
+	Limits Limits    `json:"limits"`
+	Usage  Usage     `json:"usage"`
+	// NextStep 是暂停后继续执行时的起始步骤
+	NextStep    int    `json:"next_step,omitempty"`
+	PauseReason string `json:"pause_reason,omitempty"`
+	// Blocked 表示暂停是因为 NextStep 被输出过滤拦截，继续执行即确认放行该步骤
+	Blocked bool `json:"blocked,omitempty"`
+	// Reviews 按时间顺序记录逐段审阅的结果和执行时实际应用的段
+	Reviews []HunkReview `json:"reviews,omitempty"`
+	// Branch 是工作区设置开启 task_branches 时运行使用的专用分支
+	Branch *taskbranch.Branch `json:"branch,omitempty"`
+	// Stash 是按 dirty_files 设置在执行前暂存的用户修改，运行结束后恢复
+	Stash     *taskbranch.Stash `json:"stash,omitempty"`
+	Error     string            `json:"error,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+// clone 返回运行记录的深拷贝，避免读取方与执行过程并发访问同一对象
+func (r *Run) clone() *Run {
+	c := *r
+	if r.Plan != nil {
+		plan := *r.Plan
+		plan.Steps = append([]Step(nil), r.Plan.Steps...)
+		c.Plan = &plan
+	}
+	c.Usage.FilesModified = append([]string(nil), r.Usage.FilesModified...)
+	c.Reviews = append([]HunkReview(nil), r.Reviews...)
+	if r.Branch != nil {
+		c.Branch = r.Branch.Clone()
+	}
+	if r.Stash != nil {
+		c.Stash = r.Stash.Clone()
+	}
+	return &c
+}
+
+// Validate 校验计划步骤是否合法
+func (p *Plan) Validate() error {
+	if len(p.Steps) == 0 {
+		return errors.New("plan has no steps")
+	}
+	for i, step := range p.Steps {
+		switch step.Action {
+		case ActionCommand, ActionVerify:
+			if step.Command == "" {
+				return fmt.Errorf("step %d: command is required", i+1)
+			}
+		case ActionWriteFile:
+			if step.Target == "" {
+				return fmt.Errorf("step %d: target file is required", i+1)
+			}
+		case ActionTool:
+			if step.Tool == "" {
+				return fmt.Errorf("step %d: tool is required", i+1)
+			}
+		case ActionNote, ActionTools:
+		default:
+			return fmt.Errorf("step %d: unsupported action %q", i+1, step.Action)
+		}
+	}
+	return nil
+}
+
+// step 返回指定 ID 的步骤，不存在时返回 nil
+func (p *Plan) step(id string) *Step {
+	for i := range p.Steps {
+		if p.Steps[i].ID == id {
+			return &p.Steps[i]
+		}
+	}
+	return nil
+}
+
+// HighRisk 判断计划是否包含高风险步骤
+func (p *Plan) HighRisk() bool {
+	for _, step := range p.Steps {
+		if step.Risk != nil && step.Risk.Level == permission.RiskHigh {
+			return true
+		}
+	}
+	return false
+}
+
+// normalize 为步骤补全 ID 并重置执行状态
+func (p *Plan) normalize() {
+	for i := range p.Steps {
+		if p.Steps[i].ID == "" {
+			p.Steps[i].ID = uuid.New().String()
+		}
+		p.Steps[i].Status = StepStatusPending
+		p.Steps[i].Override = false
+		p.Steps[i].Output = ""
+		p.Steps[i].Error = ""
+	}
+}
+
+// planSchema 是模型输出计划需要符合的 JSON Schema，步骤的字段要求由 Plan.Validate 检查
+const planSchema = `{
+  "type": "object",
+  "required": ["steps"],
+  "properties": {
+    "steps": {
+      "type": "array",
+      "minItems": 1,
+      "items": {
+        "type": "object",
+        "required": ["description", "action"],
+        "properties": {
+          "description": {"type": "string"},
+          "action": {"enum": ["command", "write_file", "tool", "tools", "note"], "description": "tools lets you call MCP tools while carrying out the description"},
+          "target": {"type": "string", "description": "file path for write_file"},
+          "command": {"type": "string", "description": "executable"},
+          "args": {"type": "array", "items": {"type": "string"}},
+          "content": {"type": "string", "description": "full file content for write_file"},
+          "edits": {
+            "type": "array",
+            "description": "instead of content, replace several ranges of the existing file at once; positions refer to the file before any edit",
+            "items": {
+              "type": "object",
+              "required": ["start_line", "end_line", "replacement"],
+              "properties": {
+                "start_line": {"type": "integer"},
+                "end_line": {"type": "integer", "description": "start_line-1 inserts before start_line"},
+                "start_column": {"type": "integer", "description": "omit both columns to replace whole lines"},
+                "end_column": {"type": "integer", "description": "exclusive"},
+                "replacement": {"type": "string"}
+              }
+            }
+          },
+          "tool": {"type": "string", "description": "MCP tool id"},
+          "params": {"type": "object"}
+        }
+      }
+    }
+  }
+}`
+
+// planPrompt 构造让模型输出结构化计划的提示词，输出格式由 planSchema 约束，
+// commentLanguage 是写入文件的代码中注释使用的语言
+func planPrompt(goal, commentLanguage string) string {
+	return `You are a coding agent. Before doing anything, produce a plan for the goal below.
+Steps are executed in order. Keep the plan minimal.
+Write code comments in files in ` + commentLanguage + `.
+
+Goal: ` + goal
+}
+
+// maxSyntaxRepairs 是写入文件出现语法错误时让模型修正的最多次数
+const maxSyntaxRepairs = 2
+
+// maxVerifyFixes 是验证命令失败时让模型修复的最多次数
+const maxVerifyFixes = 3
+
+// repairPrompt 构造让模型修正语法错误的提示词
+func repairPrompt(path, content, commentLanguage string, invalid *syntax.InvalidError) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "The content you planned to write to %s has syntax errors:\n", path)
+	for _, e := range invalid.Errors {
+		fmt.Fprintf(&b, "%d:%d: %s\n", e.Line, e.Column, e.Message)
+	}
+	fmt.Fprintf(&b, "\nRespond with the corrected full file content and nothing else. Keep code comments in %s.\n\n", commentLanguage)
+	b.WriteString(content)
+	return b.String()
+}
+
+// stripCodeFence 去掉模型输出外层的 Markdown 代码块
+func stripCodeFence(output string) string {
+	trimmed := strings.TrimSpace(output)
+	if !strings.HasPrefix(trimmed, "```") {
+		return output
+	}
+	trimmed = strings.TrimPrefix(trimmed, "```")
+	if i := strings.Index(trimmed, "\n"); i >= 0 {
+		trimmed = trimmed[i+1:]
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(trimmed, "```"), "\n") + "\n"
+}
+
+// parsePlan 从模型输出中解析计划，容忍代码块包裹和前后多余文本
+func parsePlan(output string) (*Plan, error) {
+	value, err := schema.Extract(output)
+	if err != nil {
+		return nil, errors.New("model returned no plan")
+	}
+
+	var plan Plan
+	if err := json.Unmarshal(value, &plan); err != nil {
+		return nil, fmt.Errorf("model returned invalid plan: %v", err)
+	}
+	if err := plan.Validate(); err != nil {
+		return nil, fmt.Errorf("model returned invalid plan: %v", err)
+	}
+	plan.normalize()
+	return &plan, nil
+}
@@ -0,0 +1,58 @@
+package agent
+
+import "testing"
+
+func TestParsePlan(t *testing.T) {
+	output := "Here is the plan:\n```json\n" + `{"steps": [
+		{"description": "run tests", "action": "command", "command": "go", "args": ["test", "./..."]},
+		{"description": "update readme", "action": "write_file", "target": "README.md", "content": "hi"},
+		{"description": "check why the pod restarts", "action": "tools"}
+	]}` + "\n```"
+
+	plan, err := parsePlan(output)
+	if err != nil {
+		t.Fatalf("failed to parse plan: %v", err)
+	}
+	if len(plan.Steps) != 3 {
+		t.Fatalf("expected 3 steps, got %d", len(plan.Steps))
+	}
+	for _, step := range plan.Steps {
+		if step.ID == "" {
+			t.Error("expected step ID to be assigned")
+		}
+		if step.Status != StepStatusPending {
+			t.Errorf("expected step status %s, got %s", StepStatusPending, step.Status)
+		}
+	}
+	if plan.Steps[0].Command != "go" || len(plan.Steps[0].Args) != 2 {
+		t.Errorf("unexpected command step: %+v", plan.Steps[0])
+	}
+}
+
+func TestParsePlanInvalid(t *testing.T) {
+	tests := []string{
+		"",
+		"no json here",
+		`{"steps": []}`,
+		`{"steps": [{"description": "x", "action": "delete_everything"}]}`,
+		`{"steps": [{"description": "x", "action": "write_file"}]}`,
+	}
+	for _, output := range tests {
+		if _, err := parsePlan(output); err == nil {
+			t.Errorf("expected error for output %q", output)
+		}
+	}
+}
+
+func TestStripCodeFence(t *testing.T) {
+	tests := map[string]string{
+		"package a\n":                         "package a\n",
+		"```go\npackage a\n```":               "package a\n",
+		"\n```\npackage a\n\nfunc f()\n```\n": "package a\n\nfunc f()\n",
+	}
+	for output, want := range tests {
+		if got := stripCodeFence(output); got != want {
+			t.Errorf("stripCodeFence(%q) = %q, want %q", output, got, want)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/liangsj/vimcoplit/internal/config"
+)
+
+// Replay 使用 cassette 确定性地回放一次运行，不访问网络、模型、命令和 MCP 工具
+// 回放按录制时审批的计划同步执行，运行记录保存在 storePath
+// 交互顺序或输入与录制时不一致，或 cassette 有未使用的交互时返回错误
+func Replay(ctx context.Context, c *Cassette, storePath string) (*Run, error) {
+	c.pos, c.err = 0, nil
+	a := &Agent{
+		cfg:     config.DefaultConfig(),
+		store:   newRunStore(storePath),
+		replay:  c,
+		running: make(map[string]*activeRun),
+	}
+
+	run, err := a.StartRun(ctx, c.Goal, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	if run.Status == RunStatusAwaitingApproval && c.Plan != nil {
+		if _, err := a.UpdatePlan(run.ID, c.Plan.Steps); err != nil {
+			return nil, err
+		}
+		if _, err := a.approve(run.ID); err != nil {
+			return nil, err
+		}
+		a.execute(ctx, run.ID)
+	}
+
+	if c.err != nil {
+		return nil, c.err
+	}
+	if remaining := len(c.Interactions) - c.pos; remaining > 0 {
+		return nil, fmt.Errorf("cassette has %d unused interactions", remaining)
+	}
+	return a.store.get(run.ID)
+}
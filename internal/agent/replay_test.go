@@ -0,0 +1,72 @@
+package agent
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/liangsj/vimcoplit/internal/config"
+)
+
+func testCassette() *Cassette {
+	plan := &Plan{Steps: []Step{
+		{Description: "run tests", Action: ActionCommand, Command: "go", Args: []string{"test", "./..."}},
+		{Description: "done", Action: ActionNote},
+	}}
+	return &Cassette{
+		Goal: "fix tests",
+		Plan: plan,
+		Interactions: []Interaction{
+			{Kind: InteractionModel, Input: planPrompt("fix tests", config.DefaultConfig().CommentLanguage()), Output: `{"steps": [{"description": "x", "action": "note"}]}`},
+			{Kind: InteractionStep, Input: stepKey(&plan.Steps[0]), Output: "ok"},
+			{Kind: InteractionStep, Input: stepKey(&plan.Steps[1])},
+		},
+	}
+}
+
+func TestReplay(t *testing.T) {
+	run, err := Replay(context.Background(), testCassette(), filepath.Join(t.TempDir(), "runs.json"))
+	if err != nil {
+		t.Fatalf("failed to replay: %v", err)
+	}
+	if run.Status != RunStatusCompleted {
+		t.Fatalf("expected status %s, got %s", RunStatusCompleted, run.Status)
+	}
+	if len(run.Plan.Steps) != 2 || run.Plan.Steps[0].Output != "ok" {
+		t.Errorf("expected recorded plan and output to be replayed, got %+v", run.Plan.Steps)
+	}
+}
+
+func TestReplayMismatch(t *testing.T) {
+	c := testCassette()
+	c.Plan.Steps[0].Args = []string{"vet", "./..."}
+	if _, err := Replay(context.Background(), c, filepath.Join(t.TempDir(), "runs.json")); err == nil {
+		t.Error("expected mismatch error")
+	}
+
+	c = testCassette()
+	c.Interactions = append(c.Interactions, Interaction{Kind: InteractionStep})
+	if _, err := Replay(context.Background(), c, filepath.Join(t.TempDir(), "runs.json")); err == nil {
+		t.Error("expected unused interaction error")
+	}
+}
+
+func TestCassetteStore(t *testing.T) {
+	s := &cassetteStore{dir: t.TempDir()}
+	for i := 0; i < 2; i++ {
+		err := s.update("run", func(c *Cassette) {
+			c.Interactions = append(c.Interactions, Interaction{Kind: InteractionModel})
+		})
+		if err != nil {
+			t.Fatalf("failed to update cassette: %v", err)
+		}
+	}
+
+	c, err := LoadCassette(s.path("run"))
+	if err != nil {
+		t.Fatalf("failed to load cassette: %v", err)
+	}
+	if c.RunID != "run" || len(c.Interactions) != 2 {
+		t.Errorf("unexpected cassette: %+v", c)
+	}
+}
@@ -0,0 +1,214 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/liangsj/vimcoplit/internal/core/merge"
+	"github.com/liangsj/vimcoplit/internal/core/patch"
+	"github.com/liangsj/vimcoplit/internal/core/textedit"
+)
+
+// FilePreview 是一个写文件步骤计划的修改，相对计划时的文件内容计算
+type FilePreview struct {
+	StepID      string `json:"step_id"`
+	Step        int    `json:"step"` // 步骤序号，从 1 开始
+	Description string `json:"description"`
+	*patch.File
+}
+
+// Preview 是运行计划中所有写文件步骤的修改，供插件逐段审阅。
+// Dependencies 是被接受的段对被拒绝的段的依赖，只应用被接受的段时可能无法编译
+type Preview struct {
+	RunID        string             `json:"run_id"`
+	PlanVersion  int                `json:"plan_version"`
+	Files        []*FilePreview     `json:"files"`
+	Dependencies []patch.Dependency `json:"dependencies"`
+}
+
+// HunkReview 是运行记录中的一次逐段审阅：Event 为 reviewed 时是提交的审阅结果，
+// 为 applied 时是执行时实际应用的段，Diff 为由接受的段重新计算的补丁
+type HunkReview struct {
+	StepID    string    `json:"step_id"`
+	Event     string    `json:"event"`
+	Accepted  []string  `json:"accepted"`
+	Rejected  []string  `json:"rejected"`
+	Warnings  []string  `json:"warnings,omitempty"`
+	Diff      string    `json:"diff,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// newHunkReview 根据文件当前的审阅结果和依赖生成审阅记录，只包含涉及该文件中被接受的段的依赖
+func newHunkReview(stepID, event string, f *patch.File, deps []patch.Dependency) HunkReview {
+	review := HunkReview{StepID: stepID, Event: event, Accepted: []string{}, Rejected: []string{}, CreatedAt: time.Now()}
+	for _, h := range f.Hunks {
+		if h.Rejected {
+			review.Rejected = append(review.Rejected, h.ID)
+		} else {
+			review.Accepted = append(review.Accepted, h.ID)
+		}
+	}
+	for _, d := range deps {
+		if slices.Contains(review.Accepted, d.Hunk) {
+			review.Warnings = append(review.Warnings, d.String())
+		}
+	}
+	return review
+}
+
+// Unified 返回所有文件的统一 diff
+func (p *Preview) Unified() string {
+	var b strings.Builder
+	for _, f := range p.Files {
+		b.WriteString(f.Unified())
+	}
+	return b.String()
+}
+
+// SideBySide 返回所有文件左右对照的 diff，width 为总宽度
+func (p *Preview) SideBySide(width int) string {
+	var b strings.Builder
+	for i, f := range p.Files {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(f.SideBySide(width))
+	}
+	return b.String()
+}
+
+// Preview 渲染运行计划中写文件步骤的修改，每段带 ID、所在的语法区域和审阅结果
+func (a *Agent) Preview(ctx context.Context, id string) (*Preview, error) {
+	run, err := a.store.get(id)
+	if err != nil {
+		return nil, err
+	}
+	if run.Plan == nil {
+		return nil, errors.New("run has no plan")
+	}
+	preview := &Preview{RunID: run.ID, PlanVersion: run.Plan.Version, Files: []*FilePreview{}}
+	var files []*patch.File
+	for i := range run.Plan.Steps {
+		step := &run.Plan.Steps[i]
+		if step.Action != ActionWriteFile {
+			continue
+		}
+		_, f, err := a.plannedPatch(ctx, step)
+		if err != nil {
+			return nil, fmt.Errorf("step %d: %v", i+1, err)
+		}
+		preview.Files = append(preview.Files, &FilePreview{StepID: step.ID, Step: i + 1, Description: step.Description, File: f})
+		files = append(files, f)
+	}
+	preview.Dependencies = patch.Dependencies(files)
+	return preview, nil
+}
+
+// ReviewHunks 记录对计划中各段修改的审阅结果，只能在审批前修改；被拒绝的段执行时不会写入。
+// ID 必须属于当前计划中的段，计划在审阅期间被编辑时返回错误。每个涉及的步骤在运行记录中
+// 留下一条审阅记录，被接受的段依赖被拒绝的段时附带警告
+func (a *Agent) ReviewHunks(ctx context.Context, id string, accept, reject []string) (*Preview, error) {
+	preview, err := a.Preview(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	decisions := make(map[string]bool) // 段 ID 到是否拒绝
+	for _, hunk := range accept {
+		decisions[hunk] = false
+	}
+	for _, hunk := range reject {
+		decisions[hunk] = true
+	}
+	touched := make(map[string]bool)
+	var files []*patch.File
+	for _, f := range preview.Files {
+		for _, h := range f.Hunks {
+			if decision, ok := decisions[h.ID]; ok {
+				h.Rejected = decision
+				touched[f.StepID] = true
+				delete(decisions, h.ID)
+			}
+		}
+		files = append(files, f.File)
+	}
+	for hunk := range decisions {
+		return nil, fmt.Errorf("hunk %s is not part of the plan", hunk)
+	}
+	preview.Dependencies = patch.Dependencies(files)
+
+	_, err = a.store.update(id, func(run *Run) error {
+		if run.Status != RunStatusAwaitingApproval {
+			return fmt.Errorf("hunks cannot be reviewed in status %s", run.Status)
+		}
+		if run.Plan.Version != preview.PlanVersion {
+			return errors.New("plan changed during review")
+		}
+		for _, f := range preview.Files {
+			step := run.Plan.step(f.StepID)
+			if step == nil || !touched[f.StepID] {
+				continue
+			}
+			review := newHunkReview(f.StepID, "reviewed", f.File, preview.Dependencies)
+			step.RejectedHunks = review.Rejected
+			run.Reviews = append(run.Reviews, review)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return preview, nil
+}
+
+// applyReviewed 返回写文件步骤只包含被接受的段的内容，并在运行记录中记录实际应用的段、
+// 重新计算的补丁和对被拒绝的段的依赖警告。所有段都被拒绝时返回 false
+func (a *Agent) applyReviewed(ctx context.Context, runID string, step *Step) (string, []string, bool, error) {
+	base, f, err := a.plannedPatch(ctx, step)
+	if err != nil {
+		return "", nil, false, err
+	}
+	var deps []patch.Dependency
+	if preview, err := a.Preview(ctx, runID); err == nil {
+		deps = preview.Dependencies
+	}
+	content := f.Apply(base)
+	review := newHunkReview(step.ID, "applied", f, deps)
+	review.Diff = merge.Diff(step.Target, base, content)
+	a.store.update(runID, func(run *Run) error {
+		run.Reviews = append(run.Reviews, review)
+		return nil
+	})
+	return content, review.Warnings, f.Accepted() > 0, nil
+}
+
+// plannedPatch 返回写文件步骤计划时的文件内容和计划的修改，修改已按步骤的审阅结果标记。
+// 记录了摘要的步骤使用当时的快照，否则使用当前的文件内容，文件不存在时视为空文件
+func (a *Agent) plannedPatch(ctx context.Context, step *Step) (string, *patch.File, error) {
+	var base []byte
+	var err error
+	if step.BaseHash != "" {
+		base, err = a.service.ReadSnapshot(ctx, step.BaseHash)
+	} else {
+		base, err = a.service.ReadFile(ctx, a.path(ctx, step.Target))
+		if errors.Is(err, fs.ErrNotExist) {
+			err = nil
+		}
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	content := step.Content
+	if len(step.Edits) > 0 {
+		if content, err = textedit.Apply(string(base), step.Edits); err != nil {
+			return "", nil, err
+		}
+	}
+	f := patch.New(step.Target, string(base), content)
+	f.Reject(step.RejectedHunks)
+	return string(base), f, nil
+}
@@ -0,0 +1,115 @@
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// runStore 是运行记录的持久化存储
+type runStore struct {
+	mu   sync.RWMutex
+	path string
+	runs map[string]*Run
+	// notify 在运行记录保存后调用，参数为记录的拷贝，在锁内调用以保证顺序
+	notify func(run *Run)
+}
+
+// newRunStore 创建一个新的运行记录存储，并加载已有记录
+func newRunStore(path string) *runStore {
+	s := &runStore{
+		path: path,
+		runs: make(map[string]*Run),
+	}
+	if data, err := os.ReadFile(path); err == nil {
+		json.Unmarshal(data, &s.runs)
+	}
+	return s
+}
+
+// save 保存到文件，调用方需持有锁
+func (s *runStore) save() error {
+	data, err := json.MarshalIndent(s.runs, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(s.path, data, 0644)
+}
+
+// put 保存运行记录，run 没有标题时保留已保存的标题，标题在后台生成，执行过程持有的记录可能还没有标题
+func (s *runStore) put(run *Run) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if old, ok := s.runs[run.ID]; ok && run.Title == "" {
+		run.Title = old.Title
+	}
+	run.UpdatedAt = time.Now()
+	s.runs[run.ID] = run.clone()
+	if err := s.save(); err != nil {
+		return err
+	}
+	s.changed(run)
+	return nil
+}
+
+// changed 通知运行记录的变化，调用方需持有锁
+func (s *runStore) changed(run *Run) {
+	if s.notify != nil {
+		s.notify(run.clone())
+	}
+}
+
+// get 获取运行记录的拷贝
+func (s *runStore) get(id string) (*Run, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	run, exists := s.runs[id]
+	if !exists {
+		return nil, errors.New("run not found")
+	}
+	return run.clone(), nil
+}
+
+// update 在锁内修改运行记录并保存，返回修改后的拷贝
+func (s *runStore) update(id string, fn func(run *Run) error) (*Run, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	run, exists := s.runs[id]
+	if !exists {
+		return nil, errors.New("run not found")
+	}
+	if err := fn(run); err != nil {
+		return nil, err
+	}
+	run.UpdatedAt = time.Now()
+	if err := s.save(); err != nil {
+		return nil, err
+	}
+	s.changed(run)
+	return run.clone(), nil
+}
+
+// list 按创建时间倒序列出所有运行记录
+func (s *runStore) list() []*Run {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	runs := make([]*Run, 0, len(s.runs))
+	for _, run := range s.runs {
+		runs = append(runs, run.clone())
+	}
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].CreatedAt.After(runs[j].CreatedAt)
+	})
+	return runs
+}
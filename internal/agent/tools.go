@@ -0,0 +1,44 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/liangsj/vimcoplit/internal/core"
+)
+
+// runTools 让模型调用 MCP 工具完成步骤描述的任务，每次调用前与计划中的工具步骤一样
+// 评估风险并按权限策略检查，被拒绝的调用不执行，原因交给模型处理
+func (a *Agent) runTools(ctx context.Context, runID string, step *Step) (string, error) {
+	run, err := a.store.get(runID)
+	if err != nil {
+		return "", err
+	}
+	result, err := a.service.GenerateWithTools(ctx, &core.ToolLoopRequest{
+		System: "You are a coding agent working towards this goal: " + run.Goal +
+			"\nUse the tools to carry out the current step, then reply with a short summary of what you did.",
+		Prompt: step.Description,
+		Authorize: func(ctx context.Context, tool string, params map[string]interface{}) error {
+			return a.authorizeTool(ctx, run, step.ID, tool, params)
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	return toolsOutput(result), nil
+}
+
+// toolsOutput 返回工具调用步骤的输出：模型的总结和每次调用的结果
+func toolsOutput(result *core.ToolLoopResult) string {
+	var b strings.Builder
+	b.WriteString(strings.TrimSpace(result.Text))
+	for _, call := range result.Calls {
+		if call.Error != "" {
+			fmt.Fprintf(&b, "\n- %s: %s", call.Tool, call.Error)
+		} else {
+			fmt.Fprintf(&b, "\n- %s: ok", call.Tool)
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
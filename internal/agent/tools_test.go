@@ -0,0 +1,21 @@
+package agent
+
+import (
+	"testing"
+
+	"github.com/liangsj/vimcoplit/internal/core"
+)
+
+func TestToolsOutput(t *testing.T) {
+	result := &core.ToolLoopResult{
+		Text: "The pod restarts because it runs out of memory.\n",
+		Calls: []*core.ToolInvocation{
+			{Tool: "kubectl", Output: "OOMKilled"},
+			{Tool: "docker", Error: "permission denied"},
+		},
+	}
+	want := "The pod restarts because it runs out of memory.\n- kubectl: ok\n- docker: permission denied"
+	if got := toolsOutput(result); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,145 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/liangsj/vimcoplit/internal/events"
+)
+
+// maxYoloDuration 是临时自动审批窗口的最长时间
+const maxYoloDuration = 8 * time.Hour
+
+// ErrYoloActive 表示已经有一个临时自动审批窗口
+var ErrYoloActive = errors.New("yolo mode is already active")
+
+// YoloWindow 是临时自动审批窗口：窗口内除高风险计划外的所有计划自动审批，
+// 策略规则要求询问的步骤自动允许一次，到期或绑定的任务结束后自动恢复原来的设置
+type YoloWindow struct {
+	TaskID    string     `json:"task_id,omitempty"` // 不为空时只作用于该任务的运行，运行结束时关闭
+	StartedAt time.Time  `json:"started_at"`
+	ExpiresAt time.Time  `json:"expires_at,omitempty"` // 为零时只在任务结束或手动关闭时结束
+	Approved  int        `json:"approved"`             // 窗口内自动审批的计划数
+	Allowed   int        `json:"allowed"`              // 窗口内自动允许的权限请求数
+	EndedAt   *time.Time `json:"ended_at,omitempty"`
+	EndReason string     `json:"end_reason,omitempty"` // expired、task_finished 或 stopped
+}
+
+// StartYolo 开启临时自动审批窗口，d 为持续时间，taskID 不为空时只作用于该任务，两者至少指定一个
+func (a *Agent) StartYolo(d time.Duration, taskID string) (*YoloWindow, error) {
+	if d <= 0 && taskID == "" {
+		return nil, errors.New("duration or task is required")
+	}
+	if d > maxYoloDuration {
+		return nil, fmt.Errorf("duration must not exceed %s", maxYoloDuration)
+	}
+
+	a.mu.Lock()
+	if a.yolo != nil {
+		a.mu.Unlock()
+		return nil, ErrYoloActive
+	}
+	w := &YoloWindow{TaskID: taskID, StartedAt: time.Now()}
+	if d > 0 {
+		w.ExpiresAt = w.StartedAt.Add(d)
+		a.yoloTimer = time.AfterFunc(d, func() { a.endYolo(w, "expired") })
+	}
+	a.yolo = w
+	c := *w
+	a.mu.Unlock()
+
+	log.Printf("已开启临时自动审批: 范围 %s，到期时间 %s\n", formatScope(taskID), formatExpiry(w.ExpiresAt))
+	a.events.Publish(events.TypeApproval, &ApprovalEvent{Status: "yolo_started", Window: &c})
+	return &c, nil
+}
+
+// StopYolo 手动关闭临时自动审批窗口
+func (a *Agent) StopYolo() (*YoloWindow, error) {
+	a.mu.Lock()
+	w := a.yolo
+	a.mu.Unlock()
+	if w != nil {
+		if ended := a.endYolo(w, "stopped"); ended != nil {
+			return ended, nil
+		}
+	}
+	return nil, errors.New("yolo mode is not active")
+}
+
+// Yolo 返回当前的临时自动审批窗口，没有时返回 nil
+func (a *Agent) Yolo() *YoloWindow {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.yolo == nil {
+		return nil
+	}
+	c := *a.yolo
+	return &c
+}
+
+// endYolo 结束窗口并记录窗口的使用情况，窗口已经结束时返回 nil
+func (a *Agent) endYolo(w *YoloWindow, reason string) *YoloWindow {
+	a.mu.Lock()
+	if a.yolo != w {
+		a.mu.Unlock()
+		return nil
+	}
+	if a.yoloTimer != nil {
+		a.yoloTimer.Stop()
+		a.yoloTimer = nil
+	}
+	a.yolo = nil
+	now := time.Now()
+	w.EndedAt = &now
+	w.EndReason = reason
+	c := *w
+	a.mu.Unlock()
+
+	log.Printf("临时自动审批已结束（%s），期间自动审批计划 %d 个，自动允许权限请求 %d 个\n", reason, c.Approved, c.Allowed)
+	a.events.Publish(events.TypeApproval, &ApprovalEvent{Status: "yolo_ended", Window: &c})
+	return &c
+}
+
+// useYolo 判断窗口是否作用于该任务的运行，作用时调用 count 记录使用情况
+func (a *Agent) useYolo(taskID string, count func(w *YoloWindow)) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	w := a.yolo
+	if w == nil || (w.TaskID != "" && w.TaskID != taskID) {
+		return false
+	}
+	if !w.ExpiresAt.IsZero() && time.Now().After(w.ExpiresAt) {
+		return false
+	}
+	count(w)
+	return true
+}
+
+// finishTaskYolo 在任务的运行结束时关闭绑定该任务的窗口
+func (a *Agent) finishTaskYolo(taskID string) {
+	a.mu.Lock()
+	w := a.yolo
+	a.mu.Unlock()
+	if w != nil && w.TaskID != "" && w.TaskID == taskID {
+		a.endYolo(w, "task_finished")
+	}
+}
+
+// formatExpiry 格式化窗口的到期时间，用于日志
+func formatExpiry(t time.Time) string {
+	if t.IsZero() {
+		return "任务结束时"
+	}
+	return t.Format(time.RFC3339)
+}
+
+// formatScope 格式化窗口的作用范围，用于日志
+func formatScope(taskID string) string {
+	if taskID == "" {
+		return "所有任务"
+	}
+	return "任务 " + taskID
+}
@@ -0,0 +1,69 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/liangsj/vimcoplit/internal/permission"
+)
+
+func TestYoloWindow(t *testing.T) {
+	a := New(nil, filepath.Join(t.TempDir(), "runs.json"), Limits{})
+	ctx := context.Background()
+	run := &Run{TaskID: "t1", Plan: &Plan{Steps: []Step{{Action: ActionNote}}}}
+	risky := &Run{TaskID: "t1", Plan: &Plan{Steps: []Step{{Action: ActionCommand, Risk: &permission.Assessment{Level: permission.RiskHigh}}}}}
+
+	if _, err := a.StartYolo(0, ""); err == nil {
+		t.Error("expected error without duration or task")
+	}
+	if a.autoApproved(ctx, run) {
+		t.Fatal("expected no auto-approval before yolo mode")
+	}
+
+	if _, err := a.StartYolo(time.Hour, ""); err != nil {
+		t.Fatalf("StartYolo: %v", err)
+	}
+	if _, err := a.StartYolo(time.Hour, ""); !errors.Is(err, ErrYoloActive) {
+		t.Errorf("expected ErrYoloActive, got %v", err)
+	}
+	if !a.autoApproved(ctx, run) {
+		t.Error("expected plan to be auto-approved in yolo mode")
+	}
+	if a.autoApproved(ctx, risky) {
+		t.Error("expected high-risk plan to still require approval")
+	}
+	ended, err := a.StopYolo()
+	if err != nil || ended.Approved != 1 || ended.EndReason != "stopped" {
+		t.Fatalf("unexpected ended window %+v %v", ended, err)
+	}
+	if a.Yolo() != nil || a.autoApproved(ctx, run) {
+		t.Error("expected yolo mode to be off after stop")
+	}
+}
+
+func TestYoloWindowScopes(t *testing.T) {
+	a := New(nil, filepath.Join(t.TempDir(), "runs.json"), Limits{})
+	ctx := context.Background()
+	plan := &Plan{Steps: []Step{{Action: ActionNote}}}
+
+	a.StartYolo(0, "t1")
+	if a.autoApproved(ctx, &Run{TaskID: "t2", Plan: plan}) {
+		t.Error("expected window to apply only to its task")
+	}
+	a.updateTask(ctx, &Run{TaskID: "t1", Status: RunStatusCompleted})
+	if a.Yolo() != nil {
+		t.Error("expected window to end when the task finishes")
+	}
+
+	a.StartYolo(10*time.Millisecond, "")
+	deadline := time.Now().Add(time.Second)
+	for a.Yolo() != nil && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if a.Yolo() != nil || a.autoApproved(ctx, &Run{Plan: plan}) {
+		t.Error("expected window to expire")
+	}
+}
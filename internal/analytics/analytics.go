@@ -0,0 +1,205 @@
+package analytics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// saveInterval 是两次自动保存之间的最短间隔
+const saveInterval = 30 * time.Second
+
+// LatencyBuckets 是延迟直方图的桶上界（毫秒），最后一个桶之外计入溢出桶
+var LatencyBuckets = []int64{10, 50, 100, 250, 500, 1000, 2500, 5000, 10000}
+
+// Histogram 是延迟直方图，Counts 比 Buckets 多一个溢出桶
+type Histogram struct {
+	Buckets []int64 `json:"buckets"`
+	Counts  []int64 `json:"counts"`
+	SumMs   float64 `json:"sum_ms"`
+}
+
+// FeatureStats 是单个功能的使用统计
+type FeatureStats struct {
+	Count    int64     `json:"count"`
+	Errors   int64     `json:"errors"`
+	Latency  Histogram `json:"latency"`
+	LastUsed time.Time `json:"last_used"`
+}
+
+// Snapshot 是本地统计数据
+type Snapshot struct {
+	// InstallID 是随机生成的匿名标识，与用户和机器信息无关
+	InstallID string                   `json:"install_id"`
+	Since     time.Time                `json:"since"`
+	Features  map[string]*FeatureStats `json:"features"`
+}
+
+// Store 是仅保存在本地的使用统计，只有显式开启导出时数据才会离开本机
+type Store struct {
+	mu       sync.Mutex
+	path     string
+	enabled  bool
+	data     *Snapshot
+	dirty    bool
+	lastSave time.Time
+}
+
+// New 创建统计存储并加载已有数据，enabled 为 false 时不记录任何数据
+func New(path string, enabled bool) *Store {
+	s := &Store{
+		path:    path,
+		enabled: enabled,
+		data:    newSnapshot(),
+	}
+	if data, err := os.ReadFile(path); err == nil {
+		var snapshot Snapshot
+		if json.Unmarshal(data, &snapshot) == nil && snapshot.Features != nil {
+			s.data = &snapshot
+		}
+	}
+	return s
+}
+
+// newSnapshot 创建空的统计数据
+func newSnapshot() *Snapshot {
+	return &Snapshot{
+		InstallID: uuid.New().String(),
+		Since:     time.Now(),
+		Features:  make(map[string]*FeatureStats),
+	}
+}
+
+// Enabled 返回是否记录统计
+func (s *Store) Enabled() bool {
+	return s.enabled
+}
+
+// Record 记录一次功能调用
+func (s *Store) Record(feature string, latency time.Duration, failed bool) {
+	if !s.enabled {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats, exists := s.data.Features[feature]
+	if !exists {
+		stats = &FeatureStats{Latency: Histogram{
+			Buckets: LatencyBuckets,
+			Counts:  make([]int64, len(LatencyBuckets)+1),
+		}}
+		s.data.Features[feature] = stats
+	}
+	stats.Count++
+	if failed {
+		stats.Errors++
+	}
+	stats.LastUsed = time.Now()
+	stats.Latency.observe(latency)
+
+	s.dirty = true
+	if time.Since(s.lastSave) >= saveInterval {
+		s.save()
+	}
+}
+
+// observe 将一次延迟计入直方图
+func (h *Histogram) observe(latency time.Duration) {
+	ms := float64(latency) / float64(time.Millisecond)
+	h.SumMs += ms
+	for i, bound := range h.Buckets {
+		if ms <= float64(bound) {
+			h.Counts[i]++
+			return
+		}
+	}
+	h.Counts[len(h.Buckets)]++
+}
+
+// Snapshot 返回统计数据的拷贝
+func (s *Store) Snapshot() *Snapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c := *s.data
+	c.Features = make(map[string]*FeatureStats, len(s.data.Features))
+	for name, stats := range s.data.Features {
+		sc := *stats
+		sc.Latency.Counts = append([]int64(nil), stats.Latency.Counts...)
+		c.Features[name] = &sc
+	}
+	return &c
+}
+
+// Flush 将未保存的统计写入文件
+func (s *Store) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.dirty {
+		return nil
+	}
+	return s.save()
+}
+
+// Reset 清空本地统计
+func (s *Store) Reset() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data = newSnapshot()
+	return s.save()
+}
+
+// save 保存到文件，调用方需持有锁
+func (s *Store) save() error {
+	data, err := json.MarshalIndent(s.data, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(s.path, data, 0644); err != nil {
+		return err
+	}
+	s.dirty = false
+	s.lastSave = time.Now()
+	return nil
+}
+
+// Export 将匿名统计发送到 url，只包含功能名、计数和延迟分布
+func (s *Store) Export(ctx context.Context, url string) error {
+	if url == "" {
+		return errors.New("analytics export url is not configured")
+	}
+	data, err := json.Marshal(s.Snapshot())
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to export analytics: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("failed to export analytics: status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package analytics
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "analytics.json")
+	s := New(path, true)
+	s.Record("generate", 5*time.Millisecond, false)
+	s.Record("generate", 300*time.Millisecond, true)
+	s.Record("generate", time.Minute, false)
+
+	stats := s.Snapshot().Features["generate"]
+	if stats == nil || stats.Count != 3 || stats.Errors != 1 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	counts := stats.Latency.Counts
+	if counts[0] != 1 || counts[4] != 1 || counts[len(counts)-1] != 1 {
+		t.Errorf("unexpected histogram counts: %v", counts)
+	}
+
+	if err := s.Flush(); err != nil {
+		t.Fatalf("failed to flush: %v", err)
+	}
+	reloaded := New(path, true).Snapshot()
+	if reloaded.Features["generate"].Count != 3 {
+		t.Errorf("expected stats to persist, got %+v", reloaded.Features["generate"])
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	s := New(filepath.Join(t.TempDir(), "analytics.json"), false)
+	s.Record("generate", time.Millisecond, false)
+	if len(s.Snapshot().Features) != 0 {
+		t.Error("expected disabled store not to record")
+	}
+}
+
+func TestExport(t *testing.T) {
+	var received Snapshot
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&received)
+	}))
+	defer server.Close()
+
+	s := New(filepath.Join(t.TempDir(), "analytics.json"), true)
+	s.Record("tasks", time.Millisecond, false)
+	if err := s.Export(context.Background(), server.URL); err != nil {
+		t.Fatalf("failed to export: %v", err)
+	}
+	if received.Features["tasks"] == nil || received.InstallID == "" {
+		t.Errorf("unexpected exported data: %+v", received)
+	}
+
+	if err := s.Export(context.Background(), ""); err == nil {
+		t.Error("expected error without export url")
+	}
+}
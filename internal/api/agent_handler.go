@@ -0,0 +1,378 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/liangsj/vimcoplit/internal/agent"
+	"github.com/liangsj/vimcoplit/internal/core"
+	"github.com/liangsj/vimcoplit/internal/i18n"
+	"github.com/liangsj/vimcoplit/internal/permission"
+)
+
+// handleAgentRuns 处理 agent 运行的创建、查询和重命名
+func (h *Handler) handleAgentRuns(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "POST":
+		var req struct {
+			Goal   string        `json:"goal"`
+			TaskID string        `json:"task_id"`
+			Limits *agent.Limits `json:"limits"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		run, err := h.agent.StartRun(r.Context(), req.Goal, req.TaskID, req.Limits)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(run)
+
+	case "GET":
+		runID := r.URL.Query().Get("id")
+		if runID == "" {
+			json.NewEncoder(w).Encode(h.agent.ListRuns())
+			return
+		}
+		run, err := h.agent.GetRun(runID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(run)
+
+	case "PATCH":
+		var req struct {
+			Title string `json:"title"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if strings.TrimSpace(req.Title) == "" {
+			http.Error(w, i18n.T("api.title_required"), http.StatusBadRequest)
+			return
+		}
+		run, err := h.agent.RenameRun(r.URL.Query().Get("id"), req.Title)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(run)
+
+	default:
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+	}
+}
+
+// handleAgentUpgrade 创建升级 Go 依赖的运行，构建或测试失败时由模型修复
+func (h *Handler) handleAgentUpgrade(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		Module  string        `json:"module"`
+		Version string        `json:"version"`
+		TaskID  string        `json:"task_id"`
+		Limits  *agent.Limits `json:"limits"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	run, err := h.agent.StartUpgrade(r.Context(), req.Module, req.Version, req.TaskID, req.Limits)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	json.NewEncoder(w).Encode(run)
+}
+
+// handleAgentPlan 处理计划的查看和编辑，步骤按提交顺序执行
+func (h *Handler) handleAgentPlan(w http.ResponseWriter, r *http.Request) {
+	runID := r.URL.Query().Get("run_id")
+	if runID == "" {
+		http.Error(w, i18n.T("api.run_id_required"), http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		run, err := h.agent.GetRun(runID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(run.Plan)
+
+	case "PUT":
+		var req struct {
+			Steps []agent.Step `json:"steps"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		run, err := h.agent.UpdatePlan(runID, req.Steps)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		json.NewEncoder(w).Encode(run.Plan)
+
+	default:
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+	}
+}
+
+// handleAgentPreview 渲染计划中写文件步骤的修改供逐段审阅（GET），format 为 unified 或
+// side_by_side（width 为总宽度，默认 160）时返回文本，否则返回 JSON；
+// 提交审阅结果（POST，{"accept": [...], "reject": [...]}），被拒绝的段执行时不会写入
+func (h *Handler) handleAgentPreview(w http.ResponseWriter, r *http.Request) {
+	runID := r.URL.Query().Get("run_id")
+	if runID == "" {
+		http.Error(w, i18n.T("api.run_id_required"), http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		preview, err := h.agent.Preview(r.Context(), runID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		switch r.URL.Query().Get("format") {
+		case "unified":
+			w.Header().Set("Content-Type", "text/x-diff; charset=utf-8")
+			io.WriteString(w, preview.Unified())
+		case "side_by_side":
+			width, _ := strconv.Atoi(r.URL.Query().Get("width"))
+			if width <= 0 {
+				width = 160
+			}
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			io.WriteString(w, preview.SideBySide(width))
+		default:
+			json.NewEncoder(w).Encode(preview)
+		}
+
+	case "POST":
+		var req struct {
+			Accept []string `json:"accept"`
+			Reject []string `json:"reject"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		preview, err := h.agent.ReviewHunks(r.Context(), runID, req.Accept, req.Reject)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		json.NewEncoder(w).Encode(preview)
+
+	default:
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+	}
+}
+
+// handleAgentApprove 审批计划并开始执行
+func (h *Handler) handleAgentApprove(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+		return
+	}
+	run, err := h.agent.Approve(r.URL.Query().Get("run_id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	json.NewEncoder(w).Encode(run)
+}
+
+// handleAgentReject 拒绝计划
+func (h *Handler) handleAgentReject(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+		return
+	}
+	run, err := h.agent.Reject(r.Context(), r.URL.Query().Get("run_id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	json.NewEncoder(w).Encode(run)
+}
+
+// handleAgentContinue 确认继续执行因超出上限而暂停的运行
+// 请求体可选，包含新的上限；为空时剩余步骤不再受上限约束
+func (h *Handler) handleAgentContinue(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+		return
+	}
+	var limits *agent.Limits
+	if r.ContentLength != 0 {
+		limits = &agent.Limits{}
+		if err := json.NewDecoder(r.Body).Decode(limits); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	run, err := h.agent.Continue(r.URL.Query().Get("run_id"), limits)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	json.NewEncoder(w).Encode(run)
+}
+
+// handleAgentFinish 对运行的任务分支执行收尾操作（POST ?run_id=...，请求体为 agent.FinishRequest），
+// 如 {"squash": true, "pull_request": true}，返回更新后的运行记录
+func (h *Handler) handleAgentFinish(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+		return
+	}
+	var req agent.FinishRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	run, err := h.agent.FinishBranch(r.Context(), r.URL.Query().Get("run_id"), &req)
+	switch {
+	case errors.Is(err, core.ErrForgeDisabled):
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		json.NewEncoder(w).Encode(run)
+	}
+}
+
+// handleAgentCassette 导出运行录制的 cassette，可用于回放调试和回归测试
+func (h *Handler) handleAgentCassette(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+		return
+	}
+	cassette, err := h.agent.Cassette(r.URL.Query().Get("run_id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(cassette)
+}
+
+// handleAgentExport 导出运行为可重放的 shell 脚本和补丁序列，
+// format 为 script 或 patch 时直接返回文本，否则返回 JSON
+func (h *Handler) handleAgentExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+		return
+	}
+	runID := r.URL.Query().Get("run_id")
+	if runID == "" {
+		http.Error(w, i18n.T("api.run_id_required"), http.StatusBadRequest)
+		return
+	}
+	export, err := h.agent.Export(runID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	switch r.URL.Query().Get("format") {
+	case "script":
+		w.Header().Set("Content-Type", "text/x-shellscript; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "vimcoplit-"+runID+".sh"))
+		io.WriteString(w, export.Script)
+	case "patch":
+		w.Header().Set("Content-Type", "application/mbox")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "vimcoplit-"+runID+".mbox"))
+		io.WriteString(w, export.Mbox())
+	default:
+		json.NewEncoder(w).Encode(export)
+	}
+}
+
+// handleAgentPermissions 列出等待回复的权限请求（GET）或回复其中一个（POST）
+func (h *Handler) handleAgentPermissions(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		json.NewEncoder(w).Encode(h.agent.PendingPermissions())
+
+	case "POST":
+		var req struct {
+			ID     string            `json:"id"`
+			Answer permission.Answer `json:"answer"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !req.Answer.Valid() {
+			http.Error(w, i18n.T("api.permission_answer_invalid", req.Answer), http.StatusBadRequest)
+			return
+		}
+		answered, err := h.agent.RespondPermission(req.ID, req.Answer)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(answered)
+
+	default:
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+	}
+}
+
+// handleAgentYolo 查询（GET）、开启（POST）或关闭（DELETE）临时自动审批窗口
+func (h *Handler) handleAgentYolo(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		json.NewEncoder(w).Encode(h.agent.Yolo())
+
+	case "POST":
+		var req struct {
+			Minutes int    `json:"minutes"`
+			TaskID  string `json:"task_id"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		window, err := h.agent.StartYolo(time.Duration(req.Minutes)*time.Minute, req.TaskID)
+		if errors.Is(err, agent.ErrYoloActive) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(window)
+
+	case "DELETE":
+		window, err := h.agent.StopYolo()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(window)
+
+	default:
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/liangsj/vimcoplit/internal/i18n"
+)
+
+// statusWriter 记录响应状态码，用于统计失败的请求
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader 记录状态码
+func (w *statusWriter) WriteHeader(code int) {
+	w.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Flush 实现 http.Flusher，保证流式响应正常工作
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// handleAnalytics 返回或清空本地使用统计
+func (h *Handler) handleAnalytics(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"enabled":        h.analytics.Enabled(),
+			"export_enabled": h.cfg.Analytics.Export,
+			"stats":          h.analytics.Snapshot(),
+		})
+
+	case "DELETE":
+		if err := h.analytics.Reset(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+	}
+}
+
+// handleAnalyticsExport 导出匿名统计，需要在配置中显式开启
+func (h *Handler) handleAnalyticsExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+		return
+	}
+	cfg := h.cfg
+	if !cfg.Analytics.Export {
+		http.Error(w, i18n.T("api.analytics_export_disabled"), http.StatusForbidden)
+		return
+	}
+	if err := h.analytics.Export(r.Context(), cfg.Analytics.ExportURL); err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
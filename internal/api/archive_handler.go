@@ -0,0 +1,93 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/liangsj/vimcoplit/internal/core/archive"
+	"github.com/liangsj/vimcoplit/internal/i18n"
+)
+
+// handleArchive 列出按保留策略归档的对话和任务
+func (h *Handler) handleArchive(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+		return
+	}
+	kind := archive.Kind(r.URL.Query().Get("kind"))
+	entries := []*archive.Entry{}
+	for _, e := range h.service.ListArchive(r.Context()) {
+		if kind == "" || e.Kind == kind {
+			entries = append(entries, e)
+		}
+	}
+	json.NewEncoder(w).Encode(entries)
+}
+
+// handleArchiveRestore 将一条归档放回活动存储
+func (h *Handler) handleArchiveRestore(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+		return
+	}
+	params := r.URL.Query()
+	entry, err := h.service.RestoreArchive(r.Context(), archive.Kind(params.Get("kind")), params.Get("id"))
+	if errors.Is(err, archive.ErrNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	json.NewEncoder(w).Encode(entry)
+}
+
+// handleArchiveExport 下载所有归档，导出的文件可以通过 /api/archive/import 重新导入
+func (h *Handler) handleArchiveExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+		return
+	}
+	var buf bytes.Buffer
+	if _, err := h.service.ExportArchive(r.Context(), &buf); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	filename := fmt.Sprintf("vimcoplit-archive-%s.jsonl.gz", time.Now().Format("20060102-150405"))
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.Write(buf.Bytes())
+}
+
+// handleArchiveImport 导入上传的归档导出文件
+func (h *Handler) handleArchiveImport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+		return
+	}
+	count, err := h.service.ImportArchive(r.Context(), r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]int{"imported": count})
+}
+
+// handleRetention 立即执行一次保留策略
+func (h *Handler) handleRetention(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+		return
+	}
+	report, err := h.service.ApplyRetention(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/liangsj/vimcoplit/internal/core/attachment"
+	"github.com/liangsj/vimcoplit/internal/i18n"
+)
+
+// handleAttachments 列出附件及存储占用（GET），指定 id 时下载附件内容；
+// 上传附件（POST，请求体为内容，类型取自 Content-Type，name 为可选的文件名）；删除附件（DELETE ?id=）
+func (h *Handler) handleAttachments(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	switch r.Method {
+	case "GET":
+		if id == "" {
+			list, usage := h.service.ListAttachments(r.Context())
+			json.NewEncoder(w).Encode(map[string]interface{}{"attachments": list, "usage": usage})
+			return
+		}
+		h.downloadAttachment(w, r, id)
+	case "POST":
+		mediaType := r.Header.Get("Content-Type")
+		if mediaType == "application/octet-stream" {
+			mediaType = ""
+		}
+		a, err := h.service.PutAttachment(r.Context(), r.Body, mediaType, r.URL.Query().Get("name"))
+		switch {
+		case errors.Is(err, attachment.ErrQuotaExceeded), errors.Is(err, attachment.ErrTooLarge):
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{"attachment": a, "uri": a.URI()})
+	case "DELETE":
+		if err := h.service.DeleteAttachment(r.Context(), id); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+	}
+}
+
+// downloadAttachment 返回附件内容，有文件名时以该文件名下载
+func (h *Handler) downloadAttachment(w http.ResponseWriter, r *http.Request, id string) {
+	content, a, err := h.service.OpenAttachment(r.Context(), id)
+	if errors.Is(err, attachment.ErrNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer content.Close()
+
+	w.Header().Set("Content-Type", a.MediaType)
+	w.Header().Set("Content-Length", strconv.FormatInt(a.Size, 10))
+	// 内容按 ID 寻址，不会改变
+	w.Header().Set("Cache-Control", "private, max-age=31536000, immutable")
+	w.Header().Set("ETag", fmt.Sprintf("%q", a.ID))
+	if a.Name != "" {
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": a.Name}))
+	}
+	io.Copy(w, content)
+}
+
+// handleAttachmentsCollect 立即回收不再被引用的附件
+func (h *Handler) handleAttachmentsCollect(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+		return
+	}
+	result, err := h.service.CollectAttachments(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/liangsj/vimcoplit/internal/audit"
+	"github.com/liangsj/vimcoplit/internal/i18n"
+)
+
+// auditLog 返回审计日志，未启用时写入 404 并返回 nil
+func (h *Handler) auditLog(w http.ResponseWriter, r *http.Request) *audit.Log {
+	if r.Method != "GET" {
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+		return nil
+	}
+	l := h.service.Audit()
+	if l == nil {
+		http.Error(w, i18n.T("api.audit_disabled"), http.StatusNotFound)
+	}
+	return l
+}
+
+// handleAuditVerify 校验审计日志的哈希链
+func (h *Handler) handleAuditVerify(w http.ResponseWriter, r *http.Request) {
+	l := h.auditLog(w, r)
+	if l == nil {
+		return
+	}
+	report, err := l.Verify()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(report)
+}
+
+// handleAuditExport 以 JSONL 格式下载完整的审计日志，供离线复核
+func (h *Handler) handleAuditExport(w http.ResponseWriter, r *http.Request) {
+	l := h.auditLog(w, r)
+	if l == nil {
+		return
+	}
+	filename := fmt.Sprintf("vimcoplit-audit-%s.jsonl", time.Now().Format("20060102-150405"))
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	if err := l.Export(w); err != nil {
+		log.Printf("导出审计日志失败: %v\n", err)
+	}
+}
@@ -0,0 +1,155 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/liangsj/vimcoplit/internal/buildinfo"
+	"github.com/liangsj/vimcoplit/internal/core/mcp"
+	"github.com/liangsj/vimcoplit/internal/i18n"
+	"github.com/liangsj/vimcoplit/internal/models"
+)
+
+// APIVersion 表示当前 HTTP API 的版本
+const APIVersion = "1"
+
+// Capabilities 描述当前服务构建支持的功能集合
+type Capabilities struct {
+	Version       string   `json:"version"` // 服务构建的版本号
+	APIVersion    string   `json:"api_version"`
+	APIPrefix     string   `json:"api_prefix"`
+	Streaming     bool     `json:"streaming"`
+	Embeddings    bool     `json:"embeddings"`
+	MCPTransports []string `json:"mcp_transports"`
+	Models        []string `json:"models"`
+	Features      []string `json:"features"`
+}
+
+// currentCapabilities 返回当前服务构建的功能集合
+func currentCapabilities() *Capabilities {
+	modelTypes := models.SupportedModelTypes()
+	modelNames := make([]string, 0, len(modelTypes))
+	for _, t := range modelTypes {
+		modelNames = append(modelNames, string(t))
+	}
+
+	return &Capabilities{
+		Version:    buildinfo.Version,
+		APIVersion: APIVersion,
+		APIPrefix:  strings.TrimSuffix(versionedAPIPrefix, "/"),
+		Streaming:  true,
+		Embeddings: false,
+		MCPTransports: []string{
+			string(mcp.ServerTypeLocal),
+			string(mcp.ServerTypeRemote),
+		},
+		Models: modelNames,
+		Features: []string{
+			"tasks",
+			"files",
+			"execute",
+			"generate",
+			"model",
+			"capabilities",
+			"processes",
+			"backup",
+			"agent_plans",
+			"agent_limits",
+			"agent_replay",
+			"output_filter",
+			"secret_scan",
+			"analytics",
+			"model_test",
+			"usage",
+			"offline",
+			"command_history",
+			"quickfix_format",
+			"inline_completion",
+			"suggestion_feedback",
+			"experiments",
+			"conversations",
+			"alternatives",
+			"cancel_generation",
+			"context_window",
+			"related_files",
+			"repo_map",
+			"edit_conflicts",
+			"workspace_settings",
+			"setup",
+			"version",
+			"dashboard",
+			"logs",
+			"observers",
+			"agent_export",
+			"explain_error",
+			"go_deps",
+			"code_search",
+			"index_progress",
+			"index_scope",
+			"context_sources",
+			"structured_generation",
+			"reproducible_generation",
+			"resource_limits",
+			"debug_server",
+			"go_sdk",
+			"event_bus",
+			"audit_log",
+			"permission_prompts",
+			"risk_scoring",
+			"yolo_mode",
+			"model_profiles",
+			"model_catalog",
+			"tool_content",
+			"file_search_stream",
+			"saved_searches",
+			"generate_batch",
+			"diff_summary",
+			"chat_mentions",
+			"slash_commands",
+			"auto_titles",
+			"archive",
+			"transcript_search",
+			"attachments",
+			"web_cache",
+			"agent_hunk_review",
+			"call_watchdog",
+			"stream_resume",
+			"vector_quantization",
+			"language_detection",
+			"code_block_execution",
+			"http_request_tool",
+			"generate_stream",
+			"claude_api",
+			"web_search",
+			"sql_query_tool",
+			"doubao_api",
+			"token_usage",
+			"deepseek_api",
+			"ops_tools",
+			"forge_integration",
+			"openai_compatible_api",
+			"task_branches",
+			"dirty_worktree_protection",
+			"tool_calling",
+		},
+	}
+}
+
+// handleCapabilities 返回服务支持的功能，供插件进行降级处理
+func (h *Handler) handleCapabilities(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+		return
+	}
+	json.NewEncoder(w).Encode(currentCapabilities())
+}
+
+// handleVersion 返回服务构建的版本、提交和构建时间，供问题反馈和插件的健康检查使用
+func (h *Handler) handleVersion(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+		return
+	}
+	json.NewEncoder(w).Encode(buildinfo.Get())
+}
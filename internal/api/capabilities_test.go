@@ -0,0 +1,46 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestCapabilities(t *testing.T) {
+	h := &Handler{}
+
+	rec := httptest.NewRecorder()
+	h.handleCapabilities(rec, httptest.NewRequest(http.MethodGet, "/api/capabilities", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	var caps Capabilities
+	if err := json.NewDecoder(rec.Body).Decode(&caps); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if caps.APIVersion != APIVersion || caps.APIPrefix != "/api/v"+APIVersion {
+		t.Errorf("unexpected API version %q prefix %q", caps.APIVersion, caps.APIPrefix)
+	}
+	if len(caps.Models) == 0 || len(caps.MCPTransports) == 0 {
+		t.Errorf("expected models and MCP transports, got %+v", caps)
+	}
+	// 插件按名称判断功能，名称不能重复
+	seen := make(map[string]bool)
+	for _, feature := range caps.Features {
+		if seen[feature] {
+			t.Errorf("duplicate feature %q", feature)
+		}
+		seen[feature] = true
+	}
+	if !slices.Contains(caps.Features, "capabilities") {
+		t.Error("expected the capabilities feature to be listed")
+	}
+
+	rec = httptest.NewRecorder()
+	h.handleCapabilities(rec, httptest.NewRequest(http.MethodPost, "/api/capabilities", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405, got %d", rec.Code)
+	}
+}
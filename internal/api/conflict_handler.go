@@ -0,0 +1,92 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/liangsj/vimcoplit/internal/core"
+	"github.com/liangsj/vimcoplit/internal/i18n"
+)
+
+// handleConflicts 列出未解决的文件冲突，指定 id 时返回单个冲突
+func (h *Handler) handleConflicts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+		return
+	}
+	if id := r.URL.Query().Get("id"); id != "" {
+		conflict, err := h.service.GetConflict(r.Context(), id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(conflict)
+		return
+	}
+
+	conflicts, err := h.service.ListConflicts(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(conflicts)
+}
+
+// handleResolveConflict 解决冲突，keep 为 current 时保留磁盘上的内容，为 edit 时写入计划的内容，
+// 否则写入 content（通常是用户编辑后的合并结果）
+func (h *Handler) handleResolveConflict(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		ID      string  `json:"id"`
+		Keep    string  `json:"keep"`
+		Content *string `json:"content"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	content := req.Content
+	switch req.Keep {
+	case "current":
+		content = nil
+	case "edit":
+		conflict, err := h.service.GetConflict(r.Context(), req.ID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		content = &conflict.Edit
+	case "":
+		if content == nil {
+			http.Error(w, i18n.T("api.conflict_resolution_required"), http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, i18n.T("api.conflict_keep_invalid"), http.StatusBadRequest)
+		return
+	}
+
+	conflict, err := h.service.ResolveConflict(r.Context(), req.ID, content)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	json.NewEncoder(w).Encode(conflict)
+}
+
+// writeConflict 以 409 返回无法自动合并的冲突，不是冲突错误时返回 false
+func writeConflict(w http.ResponseWriter, err error) bool {
+	var conflictErr *core.ConflictError
+	if !errors.As(err, &conflictErr) {
+		return false
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusConflict)
+	json.NewEncoder(w).Encode(conflictErr.Conflict)
+	return true
+}
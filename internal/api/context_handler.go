@@ -2,6 +2,7 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 
 	"github.com/liangsj/vimcoplit/internal/core"
@@ -25,9 +26,22 @@ func ContextHandler(svc core.Service) http.Handler {
 			w.WriteHeader(http.StatusBadRequest)
 			return
 		}
-		item := core.NewContextItem(req.ID, req.Type, req.Value)
+		// question 类型的上下文在启用网页搜索时解析为问题和搜索结果，搜索失败时保留原问题
+		result := map[string]interface{}{"id": req.ID}
+		value := req.Value
+		if req.Type == core.ContextTypeQuestion {
+			resolved, err := svc.ResolveQuestion(r.Context(), req.Value)
+			switch {
+			case err == nil:
+				value = resolved
+				result["resolved"] = true
+			case !errors.Is(err, core.ErrWebSearchDisabled):
+				result["error"] = err.Error()
+			}
+		}
+		item := core.NewContextItem(req.ID, req.Type, value)
 		svc.GetContextManager().AddItem(item)
-		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(result)
 	})
 
 	mux.HandleFunc("/api/context/delete", func(w http.ResponseWriter, r *http.Request) {
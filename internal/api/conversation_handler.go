@@ -0,0 +1,139 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/liangsj/vimcoplit/internal/core"
+	"github.com/liangsj/vimcoplit/internal/i18n"
+)
+
+// handleConversations 处理对话的创建、查询和重命名
+func (h *Handler) handleConversations(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "POST":
+		var req struct {
+			Title string `json:"title"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		conv, err := h.service.CreateConversation(r.Context(), req.Title)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(conv)
+
+	case "GET":
+		id := r.URL.Query().Get("id")
+		if id == "" {
+			list, err := h.service.ListConversations(r.Context())
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			json.NewEncoder(w).Encode(list)
+			return
+		}
+		conv, err := h.service.GetConversation(r.Context(), id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(conv)
+
+	case "PATCH":
+		var req struct {
+			Title string `json:"title"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if strings.TrimSpace(req.Title) == "" {
+			http.Error(w, i18n.T("api.title_required"), http.StatusBadRequest)
+			return
+		}
+		conv, err := h.service.RenameConversation(r.Context(), r.URL.Query().Get("id"), req.Title)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(conv)
+
+	default:
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+	}
+}
+
+// handleConversationMessages 在对话中发送消息，指定 parent_id 时从该消息创建分支
+func (h *Handler) handleConversationMessages(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+		return
+	}
+	var req core.MessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	convID := r.URL.Query().Get("id")
+	replies, err := h.service.SendMessage(r.Context(), convID, &req)
+	if errors.Is(err, core.ErrInvalidCommand) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		writeModelError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(replies)
+}
+
+// handleConversationBranches 列出对话的所有分支
+func (h *Handler) handleConversationBranches(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+		return
+	}
+	branches, err := h.service.ListBranches(r.Context(), r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(branches)
+}
+
+// handleConversationPromote 将分支设为主线
+func (h *Handler) handleConversationPromote(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		MessageID string `json:"message_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	conv, err := h.service.PromoteBranch(r.Context(), r.URL.Query().Get("id"), req.MessageID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(conv)
+}
+
+// handleCommands 列出聊天可用的斜杠命令，供插件在输入框中补全
+func (h *Handler) handleCommands(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+		return
+	}
+	json.NewEncoder(w).Encode(h.service.ListCommands(r.Context()))
+}
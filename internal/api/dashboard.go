@@ -0,0 +1,71 @@
+package api
+
+import (
+	"embed"
+	"encoding/json"
+	"io/fs"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/liangsj/vimcoplit/internal/i18n"
+	"github.com/liangsj/vimcoplit/internal/logbuf"
+)
+
+// dashboardFiles 是管理面板的静态页面，编译进二进制文件
+//
+//go:embed dashboard
+var dashboardFiles embed.FS
+
+// dashboardPrefix 是管理面板的路由前缀
+const dashboardPrefix = "/dashboard"
+
+// SetLogBuffer 设置最近日志的缓冲区，供 /api/logs 和管理面板查看
+func (h *Handler) SetLogBuffer(logs *logbuf.Buffer) {
+	h.logs = logs
+}
+
+// isDashboardRoute 判断路径是否属于管理面板
+func isDashboardRoute(path string) bool {
+	return path == dashboardPrefix || strings.HasPrefix(path, dashboardPrefix+"/")
+}
+
+// handleDashboard 提供管理面板的静态文件，页面通过现有的 API 管理守护进程
+func (h *Handler) handleDashboard(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+		return
+	}
+	if r.URL.Path == dashboardPrefix {
+		http.Redirect(w, r, dashboardPrefix+"/", http.StatusMovedPermanently)
+		return
+	}
+	sub, err := fs.Sub(dashboardFiles, "dashboard")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.StripPrefix(dashboardPrefix, http.FileServer(http.FS(sub))).ServeHTTP(w, r)
+}
+
+// handleLogs 返回最近的日志行，limit 限制返回的行数
+func (h *Handler) handleLogs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+		return
+	}
+	limit := 200
+	if s := r.URL.Query().Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			http.Error(w, i18n.T("api.limit_invalid", err), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	lines := []string{}
+	if h.logs != nil {
+		lines = append(lines, h.logs.Lines(limit)...)
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"lines": lines})
+}
@@ -0,0 +1,42 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDashboardServesIndex(t *testing.T) {
+	h := &Handler{}
+
+	rec := httptest.NewRecorder()
+	h.handleDashboard(rec, httptest.NewRequest(http.MethodGet, "/dashboard", nil))
+	if rec.Code != http.StatusMovedPermanently || rec.Header().Get("Location") != "/dashboard/" {
+		t.Fatalf("expected redirect to /dashboard/, got %d %q", rec.Code, rec.Header().Get("Location"))
+	}
+
+	rec = httptest.NewRecorder()
+	h.handleDashboard(rec, httptest.NewRequest(http.MethodGet, "/dashboard/", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), "<title>VimCoplit Dashboard</title>") {
+		t.Errorf("expected dashboard page, got %q", rec.Body.String())
+	}
+}
+
+func TestIsDashboardRoute(t *testing.T) {
+	for path, want := range map[string]bool{
+		"/dashboard":         true,
+		"/dashboard/":        true,
+		"/dashboard/app.js":  true,
+		"/dashboards":        false,
+		"/api/dashboard":     false,
+		"/api/v1/agent/runs": false,
+	} {
+		if got := isDashboardRoute(path); got != want {
+			t.Errorf("isDashboardRoute(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package api
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	runtimepprof "runtime/pprof"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/liangsj/vimcoplit/internal/agent"
+	"github.com/liangsj/vimcoplit/internal/i18n"
+)
+
+// NewDebugHandler 返回调试端口的处理器，提供 pprof、expvar 运行时指标和 /api/debug/dump 状态快照。
+// 调试端口与 API 分开监听，所有请求都需要携带 Authorization: Bearer <token>，
+// 令牌不接受查询参数，避免出现在代理日志和浏览器历史中
+func NewDebugHandler(h *Handler, token string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+	mux.HandleFunc("/api/debug/dump", h.handleDebugDump)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !validDebugToken(bearerToken(r), token) {
+			http.Error(w, i18n.T("api.debug_token_invalid"), http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// validDebugToken 以常数时间比较令牌，未配置令牌时拒绝所有请求
+func validDebugToken(got, want string) bool {
+	return want != "" && subtle.ConstantTimeCompare([]byte(got), []byte(want)) == 1
+}
+
+// handleDebugDump 返回诊断卡住问题用的快照：goroutine 堆栈、进行中的 HTTP 请求、
+// agent 运行、生成请求、命令和队列长度。stacks=full 时返回每个 goroutine 的完整堆栈，
+// 默认按相同堆栈合并
+func (h *Handler) handleDebugDump(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+		return
+	}
+	level := 1
+	if r.URL.Query().Get("stacks") == "full" {
+		level = 2
+	}
+	var stacks bytes.Buffer
+	runtimepprof.Lookup("goroutine").WriteTo(&stacks, level)
+
+	var runs []agent.ActiveRun
+	if h.agent != nil {
+		runs = h.agent.ActiveRuns()
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"time":       time.Now(),
+		"goroutines": runtime.NumGoroutine(),
+		"requests":   h.requests.list(),
+		"agent_runs": runs,
+		"service":    h.service.DebugState(),
+		"stacks":     stacks.String(),
+	})
+}
+
+// activeRequest 是进行中的 HTTP 请求
+type activeRequest struct {
+	id        uint64
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	StartedAt time.Time `json:"started_at"`
+	Duration  string    `json:"duration"`
+}
+
+// requestTracker 记录进行中的 HTTP 请求，零值可以直接使用
+type requestTracker struct {
+	mu     sync.Mutex
+	nextID uint64
+	active map[uint64]*activeRequest
+}
+
+// begin 登记一个请求，返回请求结束时调用的函数
+func (t *requestTracker) begin(r *http.Request) func() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.active == nil {
+		t.active = make(map[uint64]*activeRequest)
+	}
+	t.nextID++
+	id := t.nextID
+	t.active[id] = &activeRequest{id: id, Method: r.Method, Path: r.URL.Path, StartedAt: time.Now()}
+	return func() {
+		t.mu.Lock()
+		delete(t.active, id)
+		t.mu.Unlock()
+	}
+}
+
+// list 返回进行中的请求，按开始顺序排列
+func (t *requestTracker) list() []activeRequest {
+	t.mu.Lock()
+	requests := make([]activeRequest, 0, len(t.active))
+	for _, req := range t.active {
+		c := *req
+		c.Duration = time.Since(c.StartedAt).Round(time.Millisecond).String()
+		requests = append(requests, c)
+	}
+	t.mu.Unlock()
+	sort.Slice(requests, func(i, j int) bool {
+		return requests[i].id < requests[j].id
+	})
+	return requests
+}
@@ -0,0 +1,54 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDebugHandlerAuth(t *testing.T) {
+	tests := []struct {
+		name       string
+		configured string
+		token      string
+		query      string
+		want       int
+	}{
+		{"valid token", "secret", "secret", "", http.StatusOK},
+		{"missing token", "secret", "", "", http.StatusUnauthorized},
+		{"wrong token", "secret", "guess", "", http.StatusUnauthorized},
+		{"not configured", "", "", "", http.StatusUnauthorized},
+		{"query parameter", "secret", "", "?observer_token=secret", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		handler := NewDebugHandler(&Handler{}, tt.configured)
+		req := httptest.NewRequest("GET", "/debug/vars"+tt.query, nil)
+		if tt.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s: expected %d, got %d", tt.name, tt.want, rec.Code)
+		}
+	}
+}
+
+func TestRequestTracker(t *testing.T) {
+	var tracker requestTracker
+	endFirst := tracker.begin(httptest.NewRequest("POST", "/api/generate", nil))
+	endSecond := tracker.begin(httptest.NewRequest("GET", "/api/observe", nil))
+
+	requests := tracker.list()
+	if len(requests) != 2 || requests[0].Path != "/api/generate" || requests[1].Method != "GET" {
+		t.Fatalf("unexpected active requests: %+v", requests)
+	}
+	endFirst()
+	if requests = tracker.list(); len(requests) != 1 || requests[0].Path != "/api/observe" {
+		t.Errorf("unexpected active requests after first ended: %+v", requests)
+	}
+	endSecond()
+	if requests = tracker.list(); len(requests) != 0 {
+		t.Errorf("expected no active requests, got %+v", requests)
+	}
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/liangsj/vimcoplit/internal/core"
+	"github.com/liangsj/vimcoplit/internal/core/stacktrace"
+	"github.com/liangsj/vimcoplit/internal/i18n"
+)
+
+// handleExplain 解释编译或运行时错误并给出修改建议，
+// format=quickfix 时只返回错误文本中解析出的位置
+func (h *Handler) handleExplain(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+		return
+	}
+	var req core.ExplainRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Error) == "" {
+		http.Error(w, i18n.T("api.error_text_required"), http.StatusBadRequest)
+		return
+	}
+	if wantQuickfix(r) {
+		writeQuickfix(w, locationQuickfix(stacktrace.Parse(req.Error)))
+		return
+	}
+	result, err := h.service.ExplainError(r.Context(), &req)
+	if err != nil {
+		writeModelError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(result)
+}
+
+// handleDiffSummary 对 diff 或工作区 git 仓库中的修订范围做分层摘要，
+// 超出单次调用上下文的大型 diff 按文件和 hunk 分块摘要后逐层合并
+func (h *Handler) handleDiffSummary(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+		return
+	}
+	var req core.DiffSummaryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Diff) == "" && req.Range == "" {
+		http.Error(w, i18n.T("api.diff_required"), http.StatusBadRequest)
+		return
+	}
+	summary, err := h.service.SummarizeDiff(r.Context(), &req)
+	if errors.Is(err, core.ErrInvalidDiff) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		writeModelError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(summary)
+}
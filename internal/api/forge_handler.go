@@ -0,0 +1,144 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/liangsj/vimcoplit/internal/core"
+	"github.com/liangsj/vimcoplit/internal/core/forge"
+	"github.com/liangsj/vimcoplit/internal/i18n"
+)
+
+// handleForge 返回代码托管平台集成的状态：{"enabled": true, "provider": "github", "repo": "owner/name"}
+func (h *Handler) handleForge(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+		return
+	}
+	json.NewEncoder(w).Encode(h.service.ForgeStatus())
+}
+
+// handleForgeIssues 列出 issue（GET ?state=open&labels=bug,ui&limit=30），带 number 参数时返回一个 issue 及其评论
+func (h *Handler) handleForgeIssues(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+		return
+	}
+	var (
+		result interface{}
+		err    error
+	)
+	if number, ok, perr := forgeNumber(r); perr != nil {
+		http.Error(w, perr.Error(), http.StatusBadRequest)
+		return
+	} else if ok {
+		result, err = h.service.GetIssue(r.Context(), number)
+	} else {
+		result, err = h.service.ListIssues(r.Context(), forgeListOptions(r))
+	}
+	writeForgeResult(w, result, err)
+}
+
+// handleForgePulls 列出 PR（GET，参数同 issue），带 number 参数时返回一个 PR 及其评论；
+// POST 创建 PR，请求体为 core.PullRequestRequest
+func (h *Handler) handleForgePulls(w http.ResponseWriter, r *http.Request) {
+	var (
+		result interface{}
+		err    error
+	)
+	switch r.Method {
+	case "GET":
+		number, ok, perr := forgeNumber(r)
+		if perr != nil {
+			http.Error(w, perr.Error(), http.StatusBadRequest)
+			return
+		}
+		if ok {
+			result, err = h.service.GetPullRequest(r.Context(), number)
+		} else {
+			result, err = h.service.ListPullRequests(r.Context(), forgeListOptions(r))
+		}
+	case "POST":
+		var req core.PullRequestRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.Title == "" {
+			http.Error(w, i18n.T("api.title_required"), http.StatusBadRequest)
+			return
+		}
+		result, err = h.service.CreatePullRequest(r.Context(), &req)
+	default:
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+		return
+	}
+	writeForgeResult(w, result, err)
+}
+
+// handleForgeComments 在 issue 或 PR 下发表评论（POST {"kind": "issue", "number": 123, "body": "..."}）
+func (h *Handler) handleForgeComments(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		Kind   forge.Kind `json:"kind"`
+		Number int        `json:"number"`
+		Body   string     `json:"body"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Number <= 0 || req.Body == "" {
+		http.Error(w, i18n.T("api.number_body_required"), http.StatusBadRequest)
+		return
+	}
+	if req.Kind == "" {
+		req.Kind = forge.KindIssue
+	}
+	comment, err := h.service.PostComment(r.Context(), req.Kind, req.Number, req.Body)
+	writeForgeResult(w, comment, err)
+}
+
+// forgeNumber 读取 number 查询参数
+func forgeNumber(r *http.Request) (int, bool, error) {
+	value := r.URL.Query().Get("number")
+	if value == "" {
+		return 0, false, nil
+	}
+	number, err := strconv.Atoi(strings.TrimPrefix(value, "#"))
+	if err != nil || number <= 0 {
+		return 0, false, errors.New("invalid number")
+	}
+	return number, true, nil
+}
+
+// forgeListOptions 从查询参数中读取列表条件
+func forgeListOptions(r *http.Request) *forge.ListOptions {
+	q := r.URL.Query()
+	opts := &forge.ListOptions{State: q.Get("state")}
+	if labels := q.Get("labels"); labels != "" {
+		opts.Labels = strings.Split(labels, ",")
+	}
+	opts.Limit, _ = strconv.Atoi(q.Get("limit"))
+	return opts
+}
+
+// writeForgeResult 写出平台请求的结果：未配置返回 503，不存在返回 404，Token 无效返回 502（不是调用方的鉴权问题）
+func writeForgeResult(w http.ResponseWriter, result interface{}, err error) {
+	switch {
+	case errors.Is(err, core.ErrForgeDisabled):
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	case errors.Is(err, forge.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadGateway)
+	default:
+		json.NewEncoder(w).Encode(result)
+	}
+}
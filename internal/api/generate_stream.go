@@ -0,0 +1,146 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/liangsj/vimcoplit/internal/core"
+	"github.com/liangsj/vimcoplit/internal/core/offline"
+	"github.com/liangsj/vimcoplit/internal/core/secrets"
+	"github.com/liangsj/vimcoplit/internal/models"
+)
+
+// streamGenerate 以 SSE 推送生成的输出：token 事件为新增的一段输出，done 事件的内容与非流式响应相同，
+// 生成被取消时 done 事件带 canceled 和已生成的部分。推送前每段输出连同之前的输出一起经过输出过滤，
+// 被拦截时停止生成并发送 error 事件。第一段输出之前失败时按普通请求返回错误状态码
+func (h *Handler) streamGenerate(w http.ResponseWriter, r *http.Request, ctx context.Context, done func(error) *core.GenerationRecord,
+	id, prompt string, redactions []secrets.Finding, override, deferred bool) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var mu sync.Mutex
+	var pending strings.Builder
+	notify := make(chan struct{}, 1)
+	ctx = models.AddTokenHandler(ctx, func(token string) {
+		mu.Lock()
+		pending.WriteString(token)
+		mu.Unlock()
+		select {
+		case notify <- struct{}{}:
+		default:
+		}
+	})
+
+	type result struct {
+		alternatives []string
+		params       []*core.GenerationParams
+		err          error
+	}
+	finished := make(chan result, 1)
+	go func() {
+		alternatives, params, err := h.service.GenerateAlternatives(ctx, prompt, 1)
+		finished <- result{alternatives, params, err}
+	}()
+
+	started := false
+	send := func(event string, v interface{}) {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return
+		}
+		if !started {
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Header().Set("Cache-Control", "no-cache")
+			w.WriteHeader(http.StatusOK)
+			started = true
+		}
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+		flush(w)
+	}
+
+	// push 推送新增的输出，被拦截后不再推送
+	var sent strings.Builder
+	blocked := false
+	push := func(chunk string) {
+		if chunk == "" || blocked {
+			return
+		}
+		sent.WriteString(chunk)
+		if _, err := h.service.CheckOutput(filterContext(r.Context(), override), sent.String()); err != nil {
+			blocked = true
+			cancel()
+			send("error", map[string]string{"id": id, "error": err.Error()})
+			return
+		}
+		send("token", map[string]string{"token": chunk})
+	}
+	takePending := func() string {
+		mu.Lock()
+		defer mu.Unlock()
+		chunk := pending.String()
+		pending.Reset()
+		return chunk
+	}
+
+	for {
+		select {
+		case <-notify:
+			push(takePending())
+		case res := <-finished:
+			push(takePending())
+			record := done(res.err)
+			if blocked {
+				return
+			}
+			if record.Status == core.GenerationStatusCanceled {
+				send("done", map[string]interface{}{"id": id, "canceled": true, "response": record.Partial})
+				return
+			}
+			if res.err != nil {
+				h.streamError(w, r, id, prompt, res.err, started, deferred, send)
+				return
+			}
+			// 没有流式输出的模型在结束后一次推送剩余的输出
+			if rest, ok := strings.CutPrefix(res.alternatives[0], sent.String()); ok {
+				push(rest)
+			}
+			if blocked {
+				return
+			}
+			findings, _ := h.service.CheckOutput(filterContext(r.Context(), override), res.alternatives[0])
+			send("done", map[string]interface{}{
+				"id":         id,
+				"response":   res.alternatives[0],
+				"params":     res.params[0],
+				"findings":   findings,
+				"redactions": redactions,
+			})
+			return
+		}
+	}
+}
+
+// streamError 报告流式生成的错误，还没有推送输出时与非流式请求一样返回状态码或排队延后执行
+func (h *Handler) streamError(w http.ResponseWriter, r *http.Request, id, prompt string, err error, started, deferred bool,
+	send func(event string, v interface{})) {
+	if started {
+		send("error", map[string]string{"id": id, "error": err.Error()})
+		return
+	}
+	if errors.Is(err, offline.ErrOffline) && deferred {
+		item, err := h.service.DeferGeneration(r.Context(), prompt)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]interface{}{"deferred": item})
+		return
+	}
+	writeModelError(w, err)
+}
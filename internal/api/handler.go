@@ -1,39 +1,123 @@
 package api
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
 	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/google/uuid"
+	"github.com/liangsj/vimcoplit/internal/agent"
+	"github.com/liangsj/vimcoplit/internal/analytics"
+	"github.com/liangsj/vimcoplit/internal/config"
 	"github.com/liangsj/vimcoplit/internal/core"
+	"github.com/liangsj/vimcoplit/internal/core/filter"
+	"github.com/liangsj/vimcoplit/internal/core/mcp"
+	"github.com/liangsj/vimcoplit/internal/core/offline"
+	"github.com/liangsj/vimcoplit/internal/core/secrets"
+	"github.com/liangsj/vimcoplit/internal/core/syntax"
+	"github.com/liangsj/vimcoplit/internal/core/textedit"
+	"github.com/liangsj/vimcoplit/internal/core/window"
+	"github.com/liangsj/vimcoplit/internal/i18n"
+	"github.com/liangsj/vimcoplit/internal/logbuf"
 	"github.com/liangsj/vimcoplit/internal/models"
+	"github.com/liangsj/vimcoplit/internal/observer"
 )
 
 // Handler 处理所有HTTP请求
 type Handler struct {
-	service core.Service
+	service   core.Service
+	cfg       *config.Config // 服务的配置
+	agent     *agent.Agent
+	analytics *analytics.Store
+	mcp       *MCPHandler
+	logs      *logbuf.Buffer
+	observers *observer.Hub
+	requests  requestTracker // 进行中的请求，在调试端口的状态快照中显示
 }
 
-// NewHandler 创建新的API处理器
+// NewHandler 创建新的API处理器，使用服务的配置
 func NewHandler(service core.Service) *Handler {
-	return &Handler{
-		service: service,
+	cfg := service.Config()
+	limits := agent.Limits{
+		MaxTokens:        cfg.Agent.MaxTokens,
+		MaxToolCalls:     cfg.Agent.MaxToolCalls,
+		MaxDuration:      int(cfg.Agent.MaxDuration.Std() / time.Second),
+		MaxFilesModified: cfg.Agent.MaxFilesModified,
 	}
+	a := agent.New(service, filepath.Join(cfg.DataDir(), "runs.json"), limits)
+	a.SetCassetteDir(filepath.Join(cfg.DataDir(), "cassettes"))
+	h := &Handler{
+		service:   service,
+		cfg:       cfg,
+		agent:     a,
+		analytics: analytics.New(filepath.Join(cfg.DataDir(), "analytics.json"), cfg.Analytics.Enabled),
+		observers: observer.New(),
+	}
+	if manager, ok := service.GetMCPManager().(*mcp.Manager); ok {
+		h.mcp = NewMCPHandler(manager)
+	}
+	return h
+}
+
+// Close 保存尚未落盘的数据并关闭核心服务，在服务器关闭时调用
+func (h *Handler) Close() error {
+	return errors.Join(h.analytics.Flush(), h.service.Close())
 }
 
 // ServeHTTP 实现http.Handler接口
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	// 设置CORS头
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	// 只允许本机页面跨域调用，不能使用 *，否则任何网页都可以读取响应
+	if origin := r.Header.Get("Origin"); origin != "" && isLoopbackOrigin(origin) {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Add("Vary", "Origin")
+	}
 
 	if r.Method == "OPTIONS" {
 		w.WriteHeader(http.StatusOK)
 		return
 	}
 
+	// 管理面板是静态页面，不计入功能统计
+	if isDashboardRoute(r.URL.Path) {
+		h.handleDashboard(w, r)
+		return
+	}
+
+	// 版本化路由：/api/v1/xxx 与旧的 /api/xxx 指向同一处理函数
+	route, legacy := normalizeAPIPath(r.URL.Path)
+	if legacy {
+		setDeprecationHeaders(w, route)
+	}
+
+	// 观察者令牌只能访问只读的接口
+	if !h.checkObserver(w, r, route) {
+		return
+	}
+
+	// 记录功能使用次数和延迟，未知路由不记录
+	start := time.Now()
+	sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+	feature := strings.TrimPrefix(route, "/api/")
+	defer func() {
+		if feature != "" {
+			h.analytics.Record(feature, time.Since(start), sw.status >= 400)
+		}
+	}()
+	w = sw
+	defer h.requests.begin(r)()
+
 	// 路由处理
-	switch r.URL.Path {
+	switch route {
 	case "/api/tasks":
 		h.handleTasks(w, r)
 	case "/api/files":
@@ -42,13 +126,209 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		h.handleExecute(w, r)
 	case "/api/generate":
 		h.handleGenerate(w, r)
+	case "/api/complete":
+		h.handleComplete(w, r)
+	case "/api/explain":
+		h.handleExplain(w, r)
+	case "/api/diff/summary":
+		h.handleDiffSummary(w, r)
+	case "/api/feedback":
+		h.handleFeedback(w, r)
+	case "/api/feedback/stats":
+		h.handleFeedbackStats(w, r)
+	case "/api/experiments":
+		h.handleExperiments(w, r)
+	case "/api/conversations":
+		h.handleConversations(w, r)
+	case "/api/conversations/messages":
+		h.handleConversationMessages(w, r)
+	case "/api/conversations/branches":
+		h.handleConversationBranches(w, r)
+	case "/api/conversations/promote":
+		h.handleConversationPromote(w, r)
+	case "/api/commands":
+		h.handleCommands(w, r)
+	case "/api/context/related":
+		h.handleRelatedFiles(w, r)
+	case "/api/repomap":
+		h.handleRepoMap(w, r)
+	case "/api/search":
+		h.handleSearch(w, r)
+	case "/api/search/files":
+		h.handleSearchFiles(w, r)
+	case "/api/search/transcripts":
+		h.handleSearchTranscripts(w, r)
+	case "/api/searches":
+		h.handleSavedSearches(w, r)
+	case "/api/searches/run":
+		h.handleRunSavedSearch(w, r)
+	case "/api/index":
+		h.handleIndex(w, r)
+	case "/api/index/progress":
+		h.handleIndexProgress(w, r)
+	case "/api/index/files":
+		h.handleIndexFiles(w, r)
+	case "/api/settings":
+		h.handleSettings(w, r)
+	case "/api/setup":
+		h.handleSetup(w, r)
+	case "/api/context/window":
+		h.handleContextWindow(w, r)
 	case "/api/model":
 		h.handleModel(w, r)
+	case "/api/models":
+		h.handleModels(w, r)
+	case "/api/model/test":
+		h.handleModelTest(w, r)
+	case "/api/generate/batch":
+		h.handleGenerateBatch(w, r)
+	case "/api/generate/structured":
+		h.handleGenerateStructured(w, r)
+	case "/api/generate/tools":
+		h.handleGenerateTools(w, r)
+	case "/api/generate/deferred":
+		h.handleDeferredGenerations(w, r)
+	case "/api/conflicts":
+		h.handleConflicts(w, r)
+	case "/api/conflicts/resolve":
+		h.handleResolveConflict(w, r)
+	case "/api/history":
+		h.handleHistory(w, r)
+	case "/api/history/rerun":
+		h.handleHistoryRerun(w, r)
+	case "/api/offline":
+		h.handleOffline(w, r)
+	case "/api/usage":
+		h.handleUsage(w, r)
+	case "/api/capabilities":
+		h.handleCapabilities(w, r)
+	case "/api/version":
+		h.handleVersion(w, r)
+	case "/api/processes":
+		h.handleProcesses(w, r)
+	case "/api/backup":
+		h.handleBackup(w, r)
+	case "/api/restore":
+		h.handleRestore(w, r)
+	case "/api/archive":
+		h.handleArchive(w, r)
+	case "/api/archive/restore":
+		h.handleArchiveRestore(w, r)
+	case "/api/archive/export":
+		h.handleArchiveExport(w, r)
+	case "/api/archive/import":
+		h.handleArchiveImport(w, r)
+	case "/api/retention":
+		h.handleRetention(w, r)
+	case "/api/attachments":
+		h.handleAttachments(w, r)
+	case "/api/attachments/gc":
+		h.handleAttachmentsCollect(w, r)
+	case "/api/webcache":
+		h.handleWebCache(w, r)
+	case "/api/websearch":
+		h.handleWebSearch(w, r)
+	case "/api/forge":
+		h.handleForge(w, r)
+	case "/api/forge/issues":
+		h.handleForgeIssues(w, r)
+	case "/api/forge/pulls":
+		h.handleForgePulls(w, r)
+	case "/api/forge/comments":
+		h.handleForgeComments(w, r)
+	case "/api/secrets/scan":
+		h.handleSecretsScan(w, r)
+	case "/api/analytics":
+		h.handleAnalytics(w, r)
+	case "/api/analytics/export":
+		h.handleAnalyticsExport(w, r)
+	case "/api/audit/verify":
+		h.handleAuditVerify(w, r)
+	case "/api/audit/export":
+		h.handleAuditExport(w, r)
+	case "/api/agent/runs":
+		h.handleAgentRuns(w, r)
+	case "/api/agent/plan":
+		h.handleAgentPlan(w, r)
+	case "/api/agent/preview":
+		h.handleAgentPreview(w, r)
+	case "/api/agent/approve":
+		h.handleAgentApprove(w, r)
+	case "/api/agent/reject":
+		h.handleAgentReject(w, r)
+	case "/api/agent/continue":
+		h.handleAgentContinue(w, r)
+	case "/api/agent/finish":
+		h.handleAgentFinish(w, r)
+	case "/api/agent/cassette":
+		h.handleAgentCassette(w, r)
+	case "/api/agent/export":
+		h.handleAgentExport(w, r)
+	case "/api/agent/upgrade":
+		h.handleAgentUpgrade(w, r)
+	case "/api/agent/permissions":
+		h.handleAgentPermissions(w, r)
+	case "/api/agent/yolo":
+		h.handleAgentYolo(w, r)
+	case "/api/mcp/servers", "/api/mcp/tools", "/api/mcp/config":
+		h.handleMCP(w, r, route)
+	case "/api/logs":
+		h.handleLogs(w, r)
+	case "/api/observe":
+		h.handleObserve(w, r)
+	case "/api/observers":
+		h.handleObservers(w, r)
 	default:
+		// 取消进行中的生成请求：/api/generate/{id}/cancel、/api/conversations/{id}/cancel、/api/agent/runs/{id}/cancel
+		for _, prefix := range []string{"/api/generate/", "/api/conversations/", "/api/agent/runs/"} {
+			if id, ok := cancelRouteID(route, prefix); ok {
+				feature = strings.TrimPrefix(prefix, "/api/") + "cancel"
+				h.handleCancel(w, r, prefix, id)
+				return
+			}
+		}
+		feature = ""
 		http.NotFound(w, r)
 	}
 }
 
+// cancelRouteID 从 prefix{id}/cancel 形式的路由中取出 id
+func cancelRouteID(route, prefix string) (string, bool) {
+	if !strings.HasPrefix(route, prefix) || !strings.HasSuffix(route, "/cancel") {
+		return "", false
+	}
+	id := strings.TrimSuffix(strings.TrimPrefix(route, prefix), "/cancel")
+	if id == "" || strings.Contains(id, "/") {
+		return "", false
+	}
+	return id, true
+}
+
+// handleCancel 取消进行中的生成请求、对话回复或 agent 运行，返回记录的部分输出
+func (h *Handler) handleCancel(w http.ResponseWriter, r *http.Request, prefix, id string) {
+	if r.Method != "POST" {
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+		return
+	}
+	var (
+		result interface{}
+		err    error
+	)
+	switch prefix {
+	case "/api/generate/":
+		result, err = h.service.CancelGeneration(r.Context(), id)
+	case "/api/conversations/":
+		result, err = h.service.CancelMessage(r.Context(), id)
+	default:
+		result, err = h.agent.Cancel(r.Context(), id)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(result)
+}
+
 // handleTasks 处理任务相关的请求
 func (h *Handler) handleTasks(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
@@ -73,7 +353,12 @@ func (h *Handler) handleTasks(w http.ResponseWriter, r *http.Request) {
 	case "GET":
 		taskID := r.URL.Query().Get("id")
 		if taskID == "" {
-			http.Error(w, "task ID is required", http.StatusBadRequest)
+			tasks, err := h.service.ListTasks(r.Context())
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			json.NewEncoder(w).Encode(tasks)
 			return
 		}
 		task, err := h.service.GetTask(r.Context(), taskID)
@@ -84,7 +369,7 @@ func (h *Handler) handleTasks(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(task)
 
 	default:
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
 	}
 }
 
@@ -94,45 +379,73 @@ func (h *Handler) handleFiles(w http.ResponseWriter, r *http.Request) {
 	case "GET":
 		path := r.URL.Query().Get("path")
 		if path == "" {
-			http.Error(w, "path is required", http.StatusBadRequest)
+			http.Error(w, i18n.T("api.path_required"), http.StatusBadRequest)
 			return
 		}
-		content, err := h.service.ReadFile(r.Context(), path)
+		// 同时保存快照，写入时带上 hash 即可检测期间的修改
+		content, hash, err := h.service.TrackFile(r.Context(), path)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
-		json.NewEncoder(w).Encode(map[string]string{"content": string(content)})
+		json.NewEncoder(w).Encode(map[string]string{"content": string(content), "hash": hash})
 
 	case "POST":
 		var req struct {
-			Path    string `json:"path"`
-			Content string `json:"content"`
+			Path     string          `json:"path"`
+			Content  string          `json:"content"`
+			Edits    []textedit.Edit `json:"edits"`     // 多处范围修改，不为空时忽略 content
+			BaseHash string          `json:"base_hash"` // 读取时返回的 hash，文件期间被修改时自动合并
+			Override bool            `json:"override"`  // 用户确认后跳过语法检查
 		}
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		if err := h.service.WriteFile(r.Context(), req.Path, []byte(req.Content)); err != nil {
+		ctx := r.Context()
+		if req.Override {
+			ctx = syntax.WithOverride(ctx)
+		}
+		result, err := h.service.EditFile(ctx, &core.FileEdit{Path: req.Path, BaseHash: req.BaseHash, Content: req.Content, Edits: req.Edits})
+		if err != nil {
+			if writeConflict(w, err) {
+				return
+			}
+			if errors.Is(err, textedit.ErrInvalidRange) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			var invalid *syntax.InvalidError
+			if errors.As(err, &invalid) {
+				if wantQuickfix(r) {
+					w.Header().Set("Content-Type", "application/json")
+					w.WriteHeader(http.StatusUnprocessableEntity)
+					writeQuickfix(w, syntaxQuickfix(invalid))
+					return
+				}
+				http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+				return
+			}
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
-		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(result)
 
 	default:
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
 	}
 }
 
 // handleExecute 处理命令执行请求
 func (h *Handler) handleExecute(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "POST" {
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
 		return
 	}
 	var req struct {
-		Command string   `json:"command"`
-		Args    []string `json:"args"`
+		Command  string   `json:"command"`
+		Args     []string `json:"args"`
+		Override bool     `json:"override"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
@@ -142,9 +455,14 @@ func (h *Handler) handleExecute(w http.ResponseWriter, r *http.Request) {
 		Command: req.Command,
 		Args:    req.Args,
 	}
-	result, err := h.service.ExecuteCommand(r.Context(), cmd)
+	result, err := h.service.ExecuteCommand(filterContext(r.Context(), req.Override), cmd)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), filterStatus(err, http.StatusInternalServerError))
+		return
+	}
+	if wantQuickfix(r) {
+		// 编译器、linter 和 grep 的输出按 file:line:col 解析
+		writeQuickfix(w, quickfixFromText(result.Stdout+"\n"+result.Stderr))
 		return
 	}
 	json.NewEncoder(w).Encode(result)
@@ -153,31 +471,353 @@ func (h *Handler) handleExecute(w http.ResponseWriter, r *http.Request) {
 // handleGenerate 处理AI响应生成请求
 func (h *Handler) handleGenerate(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "POST" {
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		Prompt   string `json:"prompt"`
+		Override bool   `json:"override"`
+		Defer    bool   `json:"defer"`  // 非紧急请求，离线时排队到恢复联网后执行
+		N        int    `json:"n"`      // 候选回复数，大于 1 时在 alternatives 中返回所有候选
+		ID       string `json:"id"`     // 生成请求 ID，用于通过 /api/generate/{id}/cancel 取消
+		Stream   bool   `json:"stream"` // 以 SSE 逐段推送输出，不支持多个候选
+		models.Params
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// 提示词中的疑似密钥会被脱敏后再发送，并在响应中告知用户
+	prompt, redactions := h.service.RedactSecrets(req.Prompt)
+	if req.ID == "" {
+		req.ID = uuid.New().String()
+	}
+	if req.Stream && req.N > 1 {
+		http.Error(w, i18n.T("api.stream_multiple"), http.StatusBadRequest)
+		return
+	}
+	ctx, done := h.service.BeginGeneration(models.WithParams(r.Context(), req.Params), req.ID, "generate")
+	if req.Stream {
+		h.streamGenerate(w, r, ctx, done, req.ID, prompt, redactions, req.Override, req.Defer)
+		return
+	}
+	alternatives, params, err := h.service.GenerateAlternatives(ctx, prompt, req.N)
+	if record := done(err); record.Status == core.GenerationStatusCanceled {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"id":       req.ID,
+			"canceled": true,
+			"response": record.Partial,
+		})
+		return
+	}
+	if errors.Is(err, offline.ErrOffline) && req.Defer {
+		item, err := h.service.DeferGeneration(r.Context(), prompt)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]interface{}{"deferred": item})
+		return
+	}
+	if err != nil {
+		writeModelError(w, err)
+		return
+	}
+	// 响应在返回给用户前经过输出过滤，被拦截时需带 override 重新请求
+	var findings []filter.Finding
+	for _, response := range alternatives {
+		found, err := h.service.CheckOutput(filterContext(r.Context(), req.Override), response)
+		if err != nil {
+			http.Error(w, err.Error(), filterStatus(err, http.StatusInternalServerError))
+			return
+		}
+		findings = append(findings, found...)
+	}
+	result := map[string]interface{}{
+		"id":         req.ID,
+		"response":   alternatives[0],
+		"params":     params[0],
+		"findings":   findings,
+		"redactions": redactions,
+	}
+	if len(alternatives) > 1 {
+		result["alternatives"] = alternatives
+		result["alternative_params"] = params
+	}
+	json.NewEncoder(w).Encode(result)
+}
+
+// handleGenerateBatch 并发生成多个提示词的响应，结果按提示词 ID 返回。
+// 单个提示词失败或被输出过滤拦截时只在该结果的 error 中说明，不影响其他提示词
+func (h *Handler) handleGenerateBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
 		return
 	}
 	var req struct {
-		Prompt string `json:"prompt"`
+		Prompts  []core.BatchPrompt `json:"prompts"`
+		Override bool               `json:"override"`
+		models.Params
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// 提示词中的疑似密钥会被脱敏后再发送，并在对应的结果中告知用户
+	redactions := make(map[string][]secrets.Finding, len(req.Prompts))
+	for i := range req.Prompts {
+		if req.Prompts[i].ID == "" {
+			req.Prompts[i].ID = strconv.Itoa(i)
+		}
+		var found []secrets.Finding
+		req.Prompts[i].Prompt, found = h.service.RedactSecrets(req.Prompts[i].Prompt)
+		redactions[req.Prompts[i].ID] = found
+	}
+	results, err := h.service.GenerateBatch(models.WithParams(r.Context(), req.Params), req.Prompts)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidParams) || errors.Is(err, offline.ErrOffline) || errors.Is(err, core.ErrTooManySessions) {
+			writeModelError(w, err)
+		} else {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+
+	type batchItem struct {
+		*core.BatchResult
+		Findings   []filter.Finding  `json:"findings"`
+		Redactions []secrets.Finding `json:"redactions"`
+	}
+	items := make(map[string]*batchItem, len(results))
+	for id, result := range results {
+		item := &batchItem{BatchResult: result, Redactions: redactions[id]}
+		if result.Error == "" {
+			// 响应在返回给用户前经过输出过滤，被拦截的响应不返回，需带 override 重新请求
+			findings, err := h.service.CheckOutput(filterContext(r.Context(), req.Override), result.Response)
+			var blocked *filter.BlockedError
+			if errors.As(err, &blocked) {
+				findings = blocked.Findings
+			}
+			if err != nil {
+				result.Response, result.Error = "", err.Error()
+			}
+			item.Findings = findings
+		}
+		items[id] = item
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"results": items})
+}
+
+// writeModelError 返回模型调用错误，被提供商限流时返回 429 和 Retry-After，便于插件退避
+// 离线或同时进行的会话数达到上限时返回 503，采样参数无效时返回 400
+func writeModelError(w http.ResponseWriter, err error) {
+	if errors.Is(err, models.ErrInvalidParams) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, offline.ErrOffline) || errors.Is(err, core.ErrTooManySessions) {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	var rateErr *models.RateLimitError
+	if errors.As(err, &rateErr) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(rateErr.RetryAfter.Seconds()))))
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+// handleComplete 处理行内补全请求，继续输入与上次补全一致时由缓存直接返回
+func (h *Handler) handleComplete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+		return
 	}
+	var req core.CompletionRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	response, err := h.service.GenerateResponse(r.Context(), req.Prompt)
+	result, err := h.service.Complete(r.Context(), &req)
+	if err != nil {
+		writeModelError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(result)
+}
+
+// handleFeedback 记录插件上报的建议处理结果：accepted、partial 或 rejected
+func (h *Handler) handleFeedback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+		return
+	}
+	var fb core.Feedback
+	if err := json.NewDecoder(r.Body).Decode(&fb); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	result, err := h.service.RecordFeedback(r.Context(), &fb)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	json.NewEncoder(w).Encode(result)
+}
+
+// handleFeedbackStats 返回按模型和模板汇总的建议接受率
+func (h *Handler) handleFeedbackStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+		return
+	}
+	stats, err := h.service.GetFeedbackStats(r.Context())
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	json.NewEncoder(w).Encode(map[string]string{"response": response})
+	json.NewEncoder(w).Encode(stats)
+}
+
+// handleExperiments 返回当前 A/B 实验各分组的请求数、平均延迟和接受率
+func (h *Handler) handleExperiments(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+		return
+	}
+	json.NewEncoder(w).Encode(h.service.GetExperimentReport())
+}
+
+// handleRelatedFiles 返回当前文件的相关文件及推荐原因
+func (h *Handler) handleRelatedFiles(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+		return
+	}
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		http.Error(w, i18n.T("api.path_required"), http.StatusBadRequest)
+		return
+	}
+	limit := 10
+	if value := r.URL.Query().Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			http.Error(w, i18n.T("api.limit_invalid", err), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	candidates, err := h.service.RelatedFiles(r.Context(), path, limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(candidates)
+}
+
+// handleRepoMap 返回仓库地图，refresh=true 时立即重新扫描修改过的文件
+func (h *Handler) handleRepoMap(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+		return
+	}
+	m, err := h.service.RepoMap(r.Context(), r.URL.Query().Get("refresh") == "true")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(m)
+}
+
+// handleSettings 查询或部分修改当前工作区的运行时设置
+func (h *Handler) handleSettings(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		json.NewEncoder(w).Encode(h.service.GetSettings(r.Context()))
+
+	case "PATCH":
+		var patch core.SettingsPatch
+		if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		settings, err := h.service.UpdateSettings(r.Context(), &patch)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(settings)
+
+	default:
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+	}
+}
+
+// handleContextWindow 返回光标所在的函数或类以及导入语句，content 为空时读取文件
+func (h *Handler) handleContextWindow(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		Path    string `json:"path"`
+		Content string `json:"content"`
+		Line    int    `json:"line"`
+		Column  int    `json:"column"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	content := []byte(req.Content)
+	if req.Content == "" {
+		data, err := h.service.ReadFile(r.Context(), req.Path)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		content = data
+	}
+	win, err := window.Extract(req.Path, content, req.Line, req.Column)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"window": win,
+		"tokens": models.EstimateTokens(win.String()),
+	})
+}
+
+// filterContext 在用户明确要求时声明覆盖输出过滤
+func filterContext(ctx context.Context, override bool) context.Context {
+	if override {
+		return filter.WithOverride(ctx)
+	}
+	return ctx
+}
+
+// filterStatus 为被输出过滤拦截的请求返回 422，其他错误返回 code
+func filterStatus(err error, code int) int {
+	var blocked *filter.BlockedError
+	if errors.As(err, &blocked) {
+		return http.StatusUnprocessableEntity
+	}
+	return code
 }
 
 // handleModel 处理模型相关的请求
 func (h *Handler) handleModel(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case "GET":
-		// 获取当前模型
-		modelType := h.service.GetCurrentModel()
-		json.NewEncoder(w).Encode(map[string]string{"model": string(modelType)})
+		// 获取当前模型及生效的默认生成参数
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"model":  h.service.GetCurrentModel(),
+			"params": h.service.GetModelDefaults(),
+		})
 
 	case "POST":
 		// 切换模型
@@ -195,6 +835,161 @@ func (h *Handler) handleModel(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 
 	default:
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+	}
+}
+
+// handleModels 列出可选的模型及其能力，供插件填充模型选择器
+func (h *Handler) handleModels(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+		return
+	}
+	json.NewEncoder(w).Encode(h.service.ListModels())
+}
+
+// handleModelTest 对配置的模型提供商执行一次最小生成，报告延迟、API Key 是否有效和 token 用量
+func (h *Handler) handleModelTest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+		return
+	}
+	json.NewEncoder(w).Encode(h.service.TestModel(r.Context()))
+}
+
+// handleDeferredGenerations 查询离线时排队的生成请求，带 id 时返回单个请求
+func (h *Handler) handleDeferredGenerations(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+		return
+	}
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		json.NewEncoder(w).Encode(h.service.ListDeferredGenerations())
+		return
+	}
+	item, err := h.service.GetDeferredGeneration(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(item)
+}
+
+// handleOffline 查询联网状态或切换显式离线模式
+func (h *Handler) handleOffline(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		json.NewEncoder(w).Encode(h.service.GetConnectivity())
+
+	case "POST":
+		var req struct {
+			Offline bool `json:"offline"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		h.service.SetOffline(req.Offline)
+		json.NewEncoder(w).Encode(h.service.GetConnectivity())
+
+	default:
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+	}
+}
+
+// handleUsage 返回当前模型提供商的剩余配额、限流状态、每个 API Key 的使用统计和资源上限的用量
+func (h *Handler) handleUsage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"model":      h.service.GetCurrentModel(),
+		"rate_limit": h.service.GetRateLimit(),
+		"keys":       h.service.GetKeyUsage(),
+		"resources":  h.service.GetResourceUsage(),
+	})
+}
+
+// handleProcesses 返回本地 MCP 服务器和命令的资源占用
+func (h *Handler) handleProcesses(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+		return
+	}
+	json.NewEncoder(w).Encode(h.service.GetProcessStats(r.Context()))
+}
+
+// handleSecretsScan 扫描文件或内容（如 diff）中的疑似密钥，返回脱敏后的内容
+func (h *Handler) handleSecretsScan(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		Path    string `json:"path"`
+		Content string `json:"content"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Path != "" {
+		content, err := h.service.ReadFile(r.Context(), req.Path)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		req.Content = string(content)
+	}
+	redacted, findings := h.service.RedactSecrets(req.Content)
+	if wantQuickfix(r) {
+		entries := make([]QuickfixEntry, 0, len(findings))
+		for _, f := range findings {
+			entries = append(entries, QuickfixEntry{
+				Filename: req.Path,
+				Lnum:     f.Line,
+				Text:     fmt.Sprintf("%s: %s", f.Rule, f.Preview),
+				Type:     "W",
+			})
+		}
+		writeQuickfix(w, entries)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"redacted": redacted,
+		"findings": findings,
+	})
+}
+
+// handleBackup 导出服务的完整状态归档
+func (h *Handler) handleBackup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+		return
+	}
+	var buf bytes.Buffer
+	if _, err := h.service.Backup(r.Context(), &buf); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	filename := fmt.Sprintf("vimcoplit-backup-%s.tar.gz", time.Now().Format("20060102-150405"))
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.Write(buf.Bytes())
+}
+
+// handleRestore 从上传的归档恢复服务状态
+func (h *Handler) handleRestore(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+		return
+	}
+	manifest, err := h.service.Restore(r.Context(), r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
+	json.NewEncoder(w).Encode(manifest)
 }
@@ -0,0 +1,102 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/liangsj/vimcoplit/internal/core"
+	"github.com/liangsj/vimcoplit/internal/i18n"
+)
+
+// handleHistory 列出或搜索命令历史，指定 id 时返回单条记录
+func (h *Handler) handleHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+		return
+	}
+	params := r.URL.Query()
+	if id := params.Get("id"); id != "" {
+		entry, err := h.service.GetCommandHistory(r.Context(), id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(entry)
+		return
+	}
+
+	query := &core.HistoryQuery{
+		Text:   params.Get("q"),
+		Source: params.Get("source"),
+		RunID:  params.Get("run_id"),
+		Limit:  100,
+	}
+	var err error
+	if query.Since, err = parseHistoryTime(params.Get("since")); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if query.Until, err = parseHistoryTime(params.Get("until")); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if limit := params.Get("limit"); limit != "" {
+		if query.Limit, err = strconv.Atoi(limit); err != nil {
+			http.Error(w, i18n.T("api.limit_invalid", err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	entries, err := h.service.SearchCommandHistory(r.Context(), query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if entries == nil {
+		entries = []*core.HistoryEntry{}
+	}
+	json.NewEncoder(w).Encode(entries)
+}
+
+// handleHistoryRerun 重新执行历史中的一条命令
+func (h *Handler) handleHistoryRerun(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+		return
+	}
+	entry, err := h.service.GetCommandHistory(r.Context(), r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	override := r.URL.Query().Get("override") == "true"
+	result, err := h.service.ExecuteCommand(filterContext(r.Context(), override), &core.Command{
+		Command:  entry.Command,
+		Args:     entry.Args,
+		WorkDir:  entry.WorkDir,
+		Metadata: map[string]string{"source": "rerun", "rerun_of": entry.ID},
+	})
+	if err != nil {
+		http.Error(w, err.Error(), filterStatus(err, http.StatusInternalServerError))
+		return
+	}
+	json.NewEncoder(w).Encode(result)
+}
+
+// parseHistoryTime 解析 RFC3339 或 2006-01-02 格式的时间，空字符串返回零值
+func parseHistoryTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q: %v", value, err)
+	}
+	return t, nil
+}
@@ -4,9 +4,10 @@ import (
 	"context"
 	"encoding/json"
 	"net/http"
-	"time"
 
 	"github.com/liangsj/vimcoplit/internal/core/mcp"
+	"github.com/liangsj/vimcoplit/internal/i18n"
+	"github.com/liangsj/vimcoplit/internal/units"
 )
 
 // MCPHandler 处理 MCP 相关的 HTTP 请求
@@ -28,6 +29,22 @@ func (h *MCPHandler) RegisterRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("/api/mcp/config", h.handleConfig)
 }
 
+// handleMCP 将 /api/mcp/* 的请求转发给 MCP 处理器，MCP 管理器不可用时返回 404
+func (h *Handler) handleMCP(w http.ResponseWriter, r *http.Request, route string) {
+	if h.mcp == nil {
+		http.NotFound(w, r)
+		return
+	}
+	switch route {
+	case "/api/mcp/servers":
+		h.mcp.handleServers(w, r)
+	case "/api/mcp/tools":
+		h.mcp.handleTools(w, r)
+	case "/api/mcp/config":
+		h.mcp.handleConfig(w, r)
+	}
+}
+
 // handleServers 处理服务器相关的请求
 func (h *MCPHandler) handleServers(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
@@ -38,7 +55,7 @@ func (h *MCPHandler) handleServers(w http.ResponseWriter, r *http.Request) {
 	case http.MethodDelete:
 		h.removeServer(w, r)
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
 	}
 }
 
@@ -50,7 +67,7 @@ func (h *MCPHandler) handleTools(w http.ResponseWriter, r *http.Request) {
 	case http.MethodPost:
 		h.executeTool(w, r)
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
 	}
 }
 
@@ -62,7 +79,7 @@ func (h *MCPHandler) handleConfig(w http.ResponseWriter, r *http.Request) {
 	case http.MethodPut:
 		h.updateConfig(w, r)
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
 	}
 }
 
@@ -98,7 +115,7 @@ func (h *MCPHandler) addServer(w http.ResponseWriter, r *http.Request) {
 func (h *MCPHandler) removeServer(w http.ResponseWriter, r *http.Request) {
 	serverID := r.URL.Query().Get("id")
 	if serverID == "" {
-		http.Error(w, "Server ID is required", http.StatusBadRequest)
+		http.Error(w, i18n.T("api.server_id_required"), http.StatusBadRequest)
 		return
 	}
 
@@ -126,7 +143,7 @@ func (h *MCPHandler) executeTool(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		ToolID  string                 `json:"tool_id"`
 		Params  map[string]interface{} `json:"params"`
-		Timeout time.Duration          `json:"timeout,omitempty"`
+		Timeout units.Duration         `json:"timeout,omitempty"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -138,7 +155,7 @@ func (h *MCPHandler) executeTool(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	if req.Timeout > 0 {
 		var cancel context.CancelFunc
-		ctx, cancel = context.WithTimeout(ctx, req.Timeout)
+		ctx, cancel = context.WithTimeout(ctx, req.Timeout.Std())
 		defer cancel()
 	}
 
@@ -147,6 +164,10 @@ func (h *MCPHandler) executeTool(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if wantQuickfix(r) {
+		writeQuickfix(w, quickfixFromContent(result.Content))
+		return
+	}
 
 	json.NewEncoder(w).Encode(result)
 }
@@ -154,11 +175,11 @@ func (h *MCPHandler) executeTool(w http.ResponseWriter, r *http.Request) {
 // getConfig 获取配置
 func (h *MCPHandler) getConfig(w http.ResponseWriter, r *http.Request) {
 	config := struct {
-		AutoApprove bool          `json:"auto_approve"`
-		Timeout     time.Duration `json:"timeout"`
+		AutoApprove bool           `json:"auto_approve"`
+		Timeout     units.Duration `json:"timeout"`
 	}{
 		AutoApprove: h.manager.GetAutoApprove(r.Context()),
-		Timeout:     h.manager.GetTimeout(r.Context()),
+		Timeout:     units.Duration(h.manager.GetTimeout(r.Context())),
 	}
 
 	json.NewEncoder(w).Encode(config)
@@ -167,8 +188,8 @@ func (h *MCPHandler) getConfig(w http.ResponseWriter, r *http.Request) {
 // updateConfig 更新配置
 func (h *MCPHandler) updateConfig(w http.ResponseWriter, r *http.Request) {
 	var config struct {
-		AutoApprove *bool          `json:"auto_approve,omitempty"`
-		Timeout     *time.Duration `json:"timeout,omitempty"`
+		AutoApprove *bool           `json:"auto_approve,omitempty"`
+		Timeout     *units.Duration `json:"timeout,omitempty"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
@@ -184,7 +205,7 @@ func (h *MCPHandler) updateConfig(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if config.Timeout != nil {
-		if err := h.manager.SetTimeout(r.Context(), *config.Timeout); err != nil {
+		if err := h.manager.SetTimeout(r.Context(), config.Timeout.Std()); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
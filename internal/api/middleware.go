@@ -0,0 +1,221 @@
+package api
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/liangsj/vimcoplit/internal/i18n"
+)
+
+// minCompressSize 是启用压缩的最小响应体大小，过小的响应压缩收益有限
+const minCompressSize = 1024
+
+// Compress 为响应提供 gzip/deflate 压缩以及 ETag/If-None-Match 协商缓存
+func Compress(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bw := &bufferedResponseWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(bw, r)
+		if bw.passthrough {
+			return
+		}
+
+		body := bw.buf.Bytes()
+		header := w.Header()
+
+		// 仅对成功的 GET/HEAD 响应计算 ETag
+		if bw.status == http.StatusOK && (r.Method == http.MethodGet || r.Method == http.MethodHead) {
+			etag := computeETag(body)
+			header.Set("ETag", etag)
+			if etagMatches(r.Header.Get("If-None-Match"), etag) {
+				header.Del("Content-Length")
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+
+		encoding := ""
+		if len(body) >= minCompressSize && header.Get("Content-Encoding") == "" {
+			encoding = negotiateEncoding(r.Header.Get("Accept-Encoding"))
+		}
+		if encoding == "" {
+			w.WriteHeader(bw.status)
+			w.Write(body)
+			return
+		}
+
+		var compressed bytes.Buffer
+		var zw io.WriteCloser
+		if encoding == "gzip" {
+			zw = gzip.NewWriter(&compressed)
+		} else {
+			zw, _ = flate.NewWriter(&compressed, flate.DefaultCompression)
+		}
+		zw.Write(body)
+		zw.Close()
+
+		header.Set("Content-Encoding", encoding)
+		header.Add("Vary", "Accept-Encoding")
+		header.Set("Content-Length", strconv.Itoa(compressed.Len()))
+		w.WriteHeader(bw.status)
+		w.Write(compressed.Bytes())
+	})
+}
+
+// bufferedResponseWriter 缓存响应体以便计算 ETag 和压缩
+// 对于流式响应（text/event-stream）直接透传，不做缓存
+type bufferedResponseWriter struct {
+	http.ResponseWriter
+	buf         bytes.Buffer
+	status      int
+	wroteHeader bool
+	passthrough bool
+}
+
+// WriteHeader 记录状态码，流式响应立即写出
+func (w *bufferedResponseWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	w.status = status
+	if strings.HasPrefix(w.Header().Get("Content-Type"), "text/event-stream") {
+		w.passthrough = true
+		w.ResponseWriter.WriteHeader(status)
+	}
+}
+
+// Write 写入响应体
+func (w *bufferedResponseWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.passthrough {
+		return w.ResponseWriter.Write(p)
+	}
+	return w.buf.Write(p)
+}
+
+// Flush 实现 http.Flusher，仅在透传模式下生效
+func (w *bufferedResponseWriter) Flush() {
+	if !w.passthrough {
+		return
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// computeETag 根据响应体计算弱 ETag
+// 使用弱校验是因为同一资源可能以不同的压缩编码返回
+func computeETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `W/"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// etagMatches 判断 If-None-Match 是否命中当前 ETag
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	if strings.TrimSpace(ifNoneMatch) == "*" {
+		return true
+	}
+	target := strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == target {
+			return true
+		}
+	}
+	return false
+}
+
+// negotiateEncoding 根据 Accept-Encoding 选择压缩编码，优先 gzip
+func negotiateEncoding(acceptEncoding string) string {
+	accepted := make(map[string]bool)
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+		enabled := true
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if q, ok := strings.CutPrefix(param, "q="); ok {
+				if v, err := strconv.ParseFloat(q, 64); err == nil && v == 0 {
+					enabled = false
+				}
+			}
+		}
+		accepted[name] = enabled
+	}
+
+	switch {
+	case accepted["gzip"]:
+		return "gzip"
+	case accepted["deflate"]:
+		return "deflate"
+	default:
+		return ""
+	}
+}
+
+// RestrictAccess 校验请求的 Host 头和客户端地址，用于远程开发模式
+// allowedHosts 为空时不校验 Host，allowedSubnets 为空时不校验客户端地址
+func RestrictAccess(allowedHosts, allowedSubnets []string, next http.Handler) (http.Handler, error) {
+	hosts := make(map[string]bool, len(allowedHosts))
+	for _, host := range allowedHosts {
+		hosts[strings.ToLower(strings.TrimSpace(host))] = true
+	}
+
+	subnets := make([]*net.IPNet, 0, len(allowedSubnets))
+	for _, cidr := range allowedSubnets {
+		_, subnet, err := net.ParseCIDR(strings.TrimSpace(cidr))
+		if err != nil {
+			return nil, fmt.Errorf("invalid subnet %q: %v", cidr, err)
+		}
+		subnets = append(subnets, subnet)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(hosts) > 0 && !hosts[strings.ToLower(stripPort(r.Host))] {
+			http.Error(w, i18n.T("api.host_not_allowed"), http.StatusForbidden)
+			return
+		}
+		if len(subnets) > 0 && !ipInSubnets(stripPort(r.RemoteAddr), subnets) {
+			http.Error(w, i18n.T("api.client_not_allowed"), http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}), nil
+}
+
+// stripPort 去掉地址中的端口部分
+func stripPort(hostport string) string {
+	host, _, err := net.SplitHostPort(hostport)
+	if err != nil {
+		return strings.Trim(hostport, "[]")
+	}
+	return host
+}
+
+// ipInSubnets 判断 IP 是否属于任一允许的子网
+func ipInSubnets(addr string, subnets []*net.IPNet) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, subnet := range subnets {
+		if subnet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,148 @@
+package api
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCompressGzip(t *testing.T) {
+	payload := strings.Repeat("vimcoplit ", 500)
+	handler := Compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, payload)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/files", nil)
+	req.Header.Set("Accept-Encoding", "gzip, deflate")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if got := rec.Header().Get("Content-Encoding"); got != "gzip" {
+		t.Fatalf("expected gzip encoding, got %q", got)
+	}
+	zr, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatalf("failed to create gzip reader: %v", err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("failed to decompress body: %v", err)
+	}
+	if string(data) != payload {
+		t.Errorf("decompressed body does not match payload")
+	}
+}
+
+func TestCompressSkipsSmallBodies(t *testing.T) {
+	handler := Compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/model", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if got := rec.Header().Get("Content-Encoding"); got != "" {
+		t.Errorf("expected no encoding for small body, got %q", got)
+	}
+	if rec.Body.String() != "ok" {
+		t.Errorf("expected body 'ok', got %q", rec.Body.String())
+	}
+}
+
+func TestETagNotModified(t *testing.T) {
+	handler := Compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"model":"doubao"}`)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/model", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	etag := rec.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("expected ETag header to be set")
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/model", nil)
+	req.Header.Set("If-None-Match", etag)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("expected status %d, got %d", http.StatusNotModified, rec.Code)
+	}
+	if rec.Body.Len() != 0 {
+		t.Errorf("expected empty body for 304 response")
+	}
+}
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", ""},
+		{"gzip", "gzip"},
+		{"deflate", "deflate"},
+		{"gzip;q=0, deflate", "deflate"},
+		{"br, identity", ""},
+	}
+	for _, tt := range tests {
+		if got := negotiateEncoding(tt.header); got != tt.want {
+			t.Errorf("negotiateEncoding(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestRestrictAccess(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	if _, err := RestrictAccess(nil, []string{"10.0.0.0"}, next); err == nil {
+		t.Error("expected an invalid subnet to fail")
+	}
+	handler, err := RestrictAccess([]string{"devbox.local", " Remote.Example "}, []string{"10.0.0.0/8", "fd00::/8"}, next)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		host   string
+		remote string
+		want   int
+	}{
+		{"allowed host and subnet", "devbox.local:8080", "10.1.2.3:5000", http.StatusOK},
+		{"host is case insensitive", "REMOTE.example", "10.1.2.3:5000", http.StatusOK},
+		{"ipv6 client", "devbox.local", "[fd00::1]:5000", http.StatusOK},
+		{"unknown host", "evil.example", "10.1.2.3:5000", http.StatusForbidden},
+		{"client outside subnets", "devbox.local", "192.168.1.2:5000", http.StatusForbidden},
+		{"invalid client address", "devbox.local", "bogus", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/api/tasks", nil)
+		req.Host = tt.host
+		req.RemoteAddr = tt.remote
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s: expected %d, got %d", tt.name, tt.want, rec.Code)
+		}
+	}
+
+	// 没有配置时不做校验
+	open, err := RestrictAccess(nil, nil, next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/api/tasks", nil)
+	req.RemoteAddr = "203.0.113.1:5000"
+	rec := httptest.NewRecorder()
+	open.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected open access without allowlists, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,307 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/liangsj/vimcoplit/internal/events"
+	"github.com/liangsj/vimcoplit/internal/i18n"
+)
+
+// observerRoutes 是观察者令牌可以访问的只读接口
+var observerRoutes = map[string]bool{
+	"/api/observe":                true,
+	"/api/agent/runs":             true,
+	"/api/agent/plan":             true,
+	"/api/agent/preview":          true,
+	"/api/agent/export":           true,
+	"/api/conversations":          true,
+	"/api/conversations/branches": true,
+	"/api/capabilities":           true,
+	"/api/version":                true,
+}
+
+// observerToken 从 Authorization 头或 observer_token 参数中取出观察者令牌，
+// 浏览器的 EventSource 无法设置请求头，只能使用参数
+func observerToken(r *http.Request) string {
+	if token := bearerToken(r); token != "" {
+		return token
+	}
+	return r.URL.Query().Get("observer_token")
+}
+
+// bearerToken 返回 Authorization 头中的令牌
+func bearerToken(r *http.Request) string {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return ""
+	}
+	return token
+}
+
+// checkObserver 校验请求的身份，返回 false 时已写入错误响应。观察者令牌只能访问只读接口；
+// 其他请求按所有者处理，需要在 Authorization 头中带 server.token，没有配置时只接受本机和进程内的请求，
+// 否则任何不带令牌的客户端都可以修改内容或签发新的观察者令牌
+func (h *Handler) checkObserver(w http.ResponseWriter, r *http.Request, route string) bool {
+	token := observerToken(r)
+	if token != "" && h.observers.Valid(token) {
+		if r.Method != "GET" || !observerRoutes[route] {
+			http.Error(w, i18n.T("api.observer_read_only"), http.StatusForbidden)
+			return false
+		}
+		return true
+	}
+	owner := h.ownerToken()
+	switch {
+	case token == "" && r.RemoteAddr == "":
+		// 只有进程内通过 client.WithHandler 的调用没有客户端地址
+		return true
+	case owner != "" && subtle.ConstantTimeCompare([]byte(bearerToken(r)), []byte(owner)) == 1:
+		return true
+	case owner == "" && token == "" && isLoopback(r.RemoteAddr):
+		return h.checkLocal(w, r)
+	case owner == "" && token != "":
+		http.Error(w, i18n.T("api.observer_token_invalid"), http.StatusUnauthorized)
+	default:
+		http.Error(w, i18n.T("api.owner_token_required"), http.StatusUnauthorized)
+	}
+	return false
+}
+
+// checkLocal 拒绝没有令牌的本机请求中可能由浏览器里其他网页发出的请求，返回 false 时已写入错误响应。
+// 任何网页都可以向本机端口发送不需要预检的 POST，所以 Origin 必须是本机，Host 必须是本机或
+// server.allowed_hosts 中的主机（防止 DNS 重绑定），修改状态的请求必须带非简单的 Content-Type
+// （application/json 或上传内容的类型），text/plain、表单和没有类型的请求体都被拒绝
+func (h *Handler) checkLocal(w http.ResponseWriter, r *http.Request) bool {
+	if origin := r.Header.Get("Origin"); origin != "" && !isLoopbackOrigin(origin) {
+		http.Error(w, i18n.T("api.origin_not_allowed"), http.StatusForbidden)
+		return false
+	}
+	if !isLoopbackHost(stripPort(r.Host)) && !h.allowedHost(stripPort(r.Host)) {
+		http.Error(w, i18n.T("api.host_not_allowed"), http.StatusForbidden)
+		return false
+	}
+	switch r.Method {
+	case "GET", "HEAD", "OPTIONS":
+		return true
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "", "text/plain", "application/x-www-form-urlencoded", "multipart/form-data":
+		http.Error(w, i18n.T("api.content_type_required"), http.StatusUnsupportedMediaType)
+		return false
+	}
+	return true
+}
+
+// allowedHost 判断 Host 是否在 server.allowed_hosts 中
+func (h *Handler) allowedHost(host string) bool {
+	if h.cfg == nil {
+		return false
+	}
+	for _, allowed := range h.cfg.Server.AllowedHosts {
+		if strings.EqualFold(strings.TrimSpace(allowed), host) {
+			return true
+		}
+	}
+	return false
+}
+
+// isLoopbackHost 判断主机名是否指向本机
+func isLoopbackHost(host string) bool {
+	if strings.EqualFold(host, "localhost") || strings.HasSuffix(strings.ToLower(host), ".localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// isLoopbackOrigin 判断请求的 Origin 是否为本机页面，例如同源的管理面板
+func isLoopbackOrigin(origin string) bool {
+	u, err := url.Parse(origin)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && isLoopbackHost(u.Hostname())
+}
+
+// ownerToken 返回所有者令牌 server.token
+func (h *Handler) ownerToken() string {
+	if h.cfg == nil {
+		return ""
+	}
+	return h.cfg.Server.Token
+}
+
+// isLoopback 判断客户端地址是否为本机，经 SSH 隧道转发的请求也来自本机
+func isLoopback(remoteAddr string) bool {
+	ip := net.ParseIP(stripPort(remoteAddr))
+	return ip != nil && ip.IsLoopback()
+}
+
+// handleObservers 管理只读的观察者令牌
+func (h *Handler) handleObservers(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		json.NewEncoder(w).Encode(h.observers.Tokens())
+
+	case "POST":
+		var req struct {
+			Name string `json:"name"`
+			TTL  int    `json:"ttl"` // 有效期（秒），0 表示不过期
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		token, err := h.observers.IssueToken(req.Name, time.Duration(req.TTL)*time.Second)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(token)
+
+	case "DELETE":
+		if err := h.observers.Revoke(r.URL.Query().Get("token")); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+	}
+}
+
+// 事件流的心跳间隔，客户端可以通过 heartbeat 参数（秒）调整
+const (
+	defaultHeartbeat = 15 * time.Second
+	maxHeartbeat     = 5 * time.Minute
+	reconnectDelay   = 3 * time.Second // 通过 retry 字段告知客户端的重连间隔
+)
+
+// handleObserve 以 Server-Sent Events 推送事件总线上的事件，types 参数（逗号分隔）只订阅指定类型。
+// 每个事件的 id 为续传令牌，重连时通过 Last-Event-ID 头或 since 参数从令牌之后继续推送；
+// 没有事件时按 heartbeat 参数的间隔发送 heartbeat 帧，其 id 同样是续传令牌，
+// 客户端超过两个间隔没有收到任何帧时应视为断线并重连
+func (h *Handler) handleObserve(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+		return
+	}
+	token := r.Header.Get("Last-Event-ID")
+	if token == "" {
+		token = r.URL.Query().Get("since")
+	}
+	heartbeat := defaultHeartbeat
+	if v := r.URL.Query().Get("heartbeat"); v != "" {
+		seconds, err := strconv.Atoi(v)
+		if err != nil || seconds <= 0 {
+			http.Error(w, i18n.T("api.heartbeat_invalid", v), http.StatusBadRequest)
+			return
+		}
+		heartbeat = min(time.Duration(seconds)*time.Second, maxHeartbeat)
+	}
+
+	var types []events.Type
+	for _, t := range strings.Split(r.URL.Query().Get("types"), ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			types = append(types, events.Type(t))
+		}
+	}
+	streamEvents(w, r, h.service.Events(), token, types, heartbeat)
+}
+
+// streamEvents 从 token 之后推送事件直到客户端断开。无法无缝续传时先发送 reset 帧，
+// 订阅者缓冲溢出时从最后发送的事件重新订阅，补发保留在历史中的事件，
+// 已被移出历史时发送 reason 为 expired 的 reset 帧
+func streamEvents(w http.ResponseWriter, r *http.Request, bus *events.Bus, token string, types []events.Type, heartbeat time.Duration) {
+	if token == "" {
+		token = bus.Position()
+	}
+	sub, err := bus.Resume(token, types...)
+	if err != nil {
+		http.Error(w, i18n.T("api.event_id_invalid", token), http.StatusBadRequest)
+		return
+	}
+	defer func() { sub.Cancel() }()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", reconnectDelay.Milliseconds())
+
+	// send 发送订阅的重置提示和历史事件，返回最后发送的事件的令牌
+	send := func(sub *events.Subscription, last string) string {
+		if sub.Reset != "" {
+			writeReset(w, sub.Reset, last)
+		}
+		for _, event := range sub.Backlog {
+			last = writeEvent(w, bus, event)
+		}
+		flush(w)
+		return last
+	}
+	token = send(sub, token)
+
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case event := <-sub.Events:
+			if sub.Dropped() > 0 {
+				// 缓冲溢出时通道中的事件不完整，从最后发送的事件重新订阅，
+				// 丢失的事件仍在历史中时客户端不会察觉
+				sub.Cancel()
+				if sub, err = bus.Resume(token, types...); err != nil {
+					return
+				}
+				token = send(sub, token)
+				continue
+			}
+			token = writeEvent(w, bus, event)
+			flush(w)
+			ticker.Reset(heartbeat)
+		case now := <-ticker.C:
+			// 先取位置再检查通道：通道为空说明位置之前订阅的事件都已发送，令牌可以前移，
+			// 按类型过滤的订阅长时间没有事件时续传位置不会落到历史之外
+			if position := bus.Position(); len(sub.Events) == 0 {
+				token = position
+			}
+			data, _ := json.Marshal(map[string]interface{}{"time": now, "interval": int(heartbeat.Seconds())})
+			fmt.Fprintf(w, "id: %s\nevent: heartbeat\ndata: %s\n\n", token, data)
+			flush(w)
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// writeEvent 按 SSE 格式写入一个事件，id 为事件的续传令牌，返回该令牌
+func writeEvent(w http.ResponseWriter, bus *events.Bus, event events.Event) string {
+	token := bus.Token(event.ID)
+	data, err := json.Marshal(event.Data)
+	if err != nil {
+		return token
+	}
+	fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", token, event.Type, data)
+	return token
+}
+
+// writeReset 写入不带 id 的 reset 帧，提示客户端重新获取完整状态
+func writeReset(w http.ResponseWriter, reason, token string) {
+	data, _ := json.Marshal(map[string]string{"reason": reason, "token": token})
+	fmt.Fprintf(w, "event: reset\ndata: %s\n\n", data)
+}
+
+// flush 立即把缓冲的数据发送给客户端
+func flush(w http.ResponseWriter) {
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,200 @@
+package api
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/liangsj/vimcoplit/internal/config"
+	"github.com/liangsj/vimcoplit/internal/events"
+	"github.com/liangsj/vimcoplit/internal/observer"
+)
+
+func TestCheckObserver(t *testing.T) {
+	h := &Handler{cfg: config.DefaultConfig(), observers: observer.New()}
+	token, err := h.observers.IssueToken("pair", 0)
+	if err != nil {
+		t.Fatalf("failed to issue token: %v", err)
+	}
+
+	const local, remote = "127.0.0.1:5000", "192.0.2.1:5000"
+	tests := []struct {
+		name   string
+		method string
+		target string
+		addr   string
+		token  string
+		want   int
+	}{
+		{"local owner", "POST", "/api/agent/runs", local, "", http.StatusOK},
+		{"remote without token", "POST", "/api/agent/runs", remote, "", http.StatusUnauthorized},
+		{"remote mints observer token", "POST", "/api/observers", remote, "", http.StatusUnauthorized},
+		{"observer read", "GET", "/api/agent/runs", remote, token.Token, http.StatusOK},
+		{"observer stream", "GET", "/api/observe?observer_token=" + token.Token, remote, "", http.StatusOK},
+		{"observer write", "POST", "/api/agent/runs", remote, token.Token, http.StatusForbidden},
+		{"observer mints token", "POST", "/api/observers", remote, token.Token, http.StatusForbidden},
+		{"observer other route", "GET", "/api/files?path=x", remote, token.Token, http.StatusForbidden},
+		{"invalid token", "GET", "/api/agent/runs", local, "bogus", http.StatusUnauthorized},
+	}
+	check := func(name, method, target, addr, token string, want int) {
+		t.Helper()
+		req := httptest.NewRequest(method, target, nil)
+		req.RemoteAddr = addr
+		req.Host = "localhost:8080"
+		if method != "GET" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		ok := h.checkObserver(rec, req, req.URL.Path)
+		if ok != (want == http.StatusOK) || rec.Code != want {
+			t.Errorf("%s: expected %d, got ok=%v code=%d", name, want, ok, rec.Code)
+		}
+	}
+	for _, tt := range tests {
+		check(tt.name, tt.method, tt.target, tt.addr, tt.token, tt.want)
+	}
+
+	// 配置了所有者令牌时本机的请求也需要带令牌，令牌不能通过参数传递
+	h.cfg.Server.Token = "owner-secret"
+	check("remote owner", "POST", "/api/observers", remote, "owner-secret", http.StatusOK)
+	check("local without owner token", "POST", "/api/agent/runs", local, "", http.StatusUnauthorized)
+	check("wrong owner token", "POST", "/api/agent/runs", remote, "guess", http.StatusUnauthorized)
+	check("owner token in query", "POST", "/api/agent/runs?observer_token=owner-secret", remote, "", http.StatusUnauthorized)
+	check("observer with owner token set", "GET", "/api/agent/runs", remote, token.Token, http.StatusOK)
+	check("in-process call", "POST", "/api/agent/runs", "", "", http.StatusOK)
+}
+
+func TestCheckLocal(t *testing.T) {
+	h := &Handler{cfg: config.DefaultConfig(), observers: observer.New()}
+	h.cfg.Server.AllowedHosts = []string{"devbox"}
+	tests := []struct {
+		name        string
+		method      string
+		host        string
+		origin      string
+		contentType string
+		want        int
+	}{
+		{"plugin", "POST", "localhost:8080", "", "application/json", http.StatusOK},
+		{"dashboard", "POST", "127.0.0.1:8080", "http://127.0.0.1:8080", "application/json; charset=utf-8", http.StatusOK},
+		{"upload", "POST", "[::1]:8080", "", "application/gzip", http.StatusOK},
+		{"read", "GET", "localhost:8080", "", "", http.StatusOK},
+		{"allowed host", "POST", "devbox:8080", "", "application/json", http.StatusOK},
+		{"cross-site page", "POST", "localhost:8080", "https://evil.example", "application/json", http.StatusForbidden},
+		{"cross-site read", "GET", "localhost:8080", "https://evil.example", "", http.StatusForbidden},
+		{"dns rebinding", "POST", "evil.example:8080", "", "application/json", http.StatusForbidden},
+		{"text/plain body", "POST", "localhost:8080", "", "text/plain;charset=UTF-8", http.StatusUnsupportedMediaType},
+		{"form body", "POST", "localhost:8080", "", "application/x-www-form-urlencoded", http.StatusUnsupportedMediaType},
+		{"no content type", "DELETE", "localhost:8080", "", "", http.StatusUnsupportedMediaType},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, "/api/execute", nil)
+		req.RemoteAddr = "127.0.0.1:5000"
+		req.Host = tt.host
+		if tt.origin != "" {
+			req.Header.Set("Origin", tt.origin)
+		}
+		if tt.contentType != "" {
+			req.Header.Set("Content-Type", tt.contentType)
+		}
+		rec := httptest.NewRecorder()
+		ok := h.checkObserver(rec, req, "/api/execute")
+		if ok != (tt.want == http.StatusOK) || rec.Code != tt.want {
+			t.Errorf("%s: expected %d, got ok=%v code=%d", tt.name, tt.want, ok, rec.Code)
+		}
+	}
+
+	// 带所有者令牌的请求不受限制，跨站页面无法得到令牌
+	h.cfg.Server.Token = "owner-secret"
+	req := httptest.NewRequest("POST", "/api/execute", nil)
+	req.RemoteAddr = "192.0.2.1:5000"
+	req.Host = "devbox.example"
+	req.Header.Set("Authorization", "Bearer owner-secret")
+	if !h.checkObserver(httptest.NewRecorder(), req, "/api/execute") {
+		t.Error("expected the owner token to be accepted")
+	}
+}
+
+func TestCORS(t *testing.T) {
+	h := &Handler{}
+	for origin, want := range map[string]string{
+		"http://localhost:8080": "http://localhost:8080",
+		"https://evil.example":  "",
+		"":                      "",
+	} {
+		req := httptest.NewRequest("OPTIONS", "/api/execute", nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if got := rec.Header().Get("Access-Control-Allow-Origin"); got != want {
+			t.Errorf("%q: expected allowed origin %q, got %q", origin, want, got)
+		}
+	}
+}
+
+// readFrames 读取事件流直到收到 n 个帧，返回每个帧的字段
+func readFrames(t *testing.T, url, lastEventID string, n int) []map[string]string {
+	t.Helper()
+	req, _ := http.NewRequest("GET", url, nil)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var frames []map[string]string
+	frame := map[string]string{}
+	scanner := bufio.NewScanner(resp.Body)
+	for len(frames) < n && scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			frames = append(frames, frame)
+			frame = map[string]string{}
+			continue
+		}
+		name, value, _ := strings.Cut(line, ": ")
+		frame[name] = value
+	}
+	return frames
+}
+
+func TestStreamEvents(t *testing.T) {
+	bus := events.New(2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		streamEvents(w, r, bus, r.Header.Get("Last-Event-ID"), nil, 20*time.Millisecond)
+	}))
+	defer server.Close()
+
+	bus.Publish(events.TypeRun, "first")
+	bus.Publish(events.TypeRun, "second")
+
+	// 新连接只接收之后的事件，没有事件时收到带续传令牌的心跳
+	frames := readFrames(t, server.URL, "", 2)
+	if len(frames) != 2 || frames[0]["retry"] != "3000" || frames[1]["event"] != "heartbeat" || frames[1]["id"] != bus.Token(2) {
+		t.Fatalf("expected retry and heartbeat frames, got %+v", frames)
+	}
+
+	// 从令牌续传补发错过的事件
+	frames = readFrames(t, server.URL, bus.Token(1), 2)
+	if frames[1]["event"] != "run" || frames[1]["id"] != bus.Token(2) || frames[1]["data"] != `"second"` {
+		t.Errorf("expected the missed event, got %+v", frames)
+	}
+
+	// 错过的事件已被移出历史时先发送 reset
+	bus.Publish(events.TypeRun, "third")
+	frames = readFrames(t, server.URL, bus.Token(0), 3)
+	if frames[1]["event"] != "reset" || !strings.Contains(frames[1]["data"], `"reason":"expired"`) || frames[2]["id"] != bus.Token(2) {
+		t.Errorf("expected expired reset before the retained events, got %+v", frames)
+	}
+}
@@ -0,0 +1,227 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/liangsj/vimcoplit/internal/core/mcp"
+	"github.com/liangsj/vimcoplit/internal/core/stacktrace"
+	"github.com/liangsj/vimcoplit/internal/core/syntax"
+)
+
+// QuickfixEntry 是 Vim quickfix/location list 的一项，字段与 setqflist() 的字典一致
+type QuickfixEntry struct {
+	Filename string `json:"filename"`
+	Lnum     int    `json:"lnum"`
+	Col      int    `json:"col,omitempty"`
+	Text     string `json:"text"`
+	Type     string `json:"type,omitempty"` // E、W 或 I
+}
+
+// quickfixLine 匹配编译器和 grep -n 风格的输出，如 main.go:12:5: message
+var quickfixLine = regexp.MustCompile(`^([^:\s][^:]*):(\d+):(?:(\d+):)?\s*(.*)$`)
+
+// wantQuickfix 判断请求是否要求 quickfix 格式的响应
+func wantQuickfix(r *http.Request) bool {
+	return r.URL.Query().Get("format") == "quickfix"
+}
+
+// writeQuickfix 以 JSON 数组返回 quickfix 条目，插件可以直接传给 setqflist()
+func writeQuickfix(w http.ResponseWriter, entries []QuickfixEntry) {
+	if entries == nil {
+		entries = []QuickfixEntry{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
+
+// quickfixFromText 从文本输出中解析 file:line[:col]: text 格式的行
+func quickfixFromText(text string) []QuickfixEntry {
+	var entries []QuickfixEntry
+	for _, line := range strings.Split(text, "\n") {
+		m := quickfixLine.FindStringSubmatch(strings.TrimRight(line, "\r"))
+		if m == nil {
+			continue
+		}
+		lnum, _ := strconv.Atoi(m[2])
+		col, _ := strconv.Atoi(m[3])
+		entries = append(entries, QuickfixEntry{
+			Filename: m[1],
+			Lnum:     lnum,
+			Col:      col,
+			Text:     m[4],
+			Type:     quickfixType(m[4]),
+		})
+	}
+	return entries
+}
+
+// syntaxQuickfix 将写入文件时发现的语法错误转换为 quickfix 条目
+func syntaxQuickfix(invalid *syntax.InvalidError) []QuickfixEntry {
+	entries := make([]QuickfixEntry, 0, len(invalid.Errors))
+	for _, e := range invalid.Errors {
+		entries = append(entries, QuickfixEntry{
+			Filename: invalid.Path,
+			Lnum:     e.Line,
+			Col:      e.Column,
+			Text:     e.Message,
+			Type:     "E",
+		})
+	}
+	return entries
+}
+
+// locationQuickfix 将错误文本中解析出的位置转换为 quickfix 条目
+func locationQuickfix(locations []stacktrace.Location) []QuickfixEntry {
+	entries := make([]QuickfixEntry, 0, len(locations))
+	for _, loc := range locations {
+		text := loc.Message
+		if text == "" {
+			text = loc.Function
+		}
+		entries = append(entries, QuickfixEntry{
+			Filename: loc.Path,
+			Lnum:     loc.Line,
+			Col:      loc.Column,
+			Text:     text,
+			Type:     "E",
+		})
+	}
+	return entries
+}
+
+// quickfixFromValue 将任意 JSON 结果转换为 quickfix 条目：
+// 带有文件名和行号字段的对象直接转换，字符串按编译器输出解析，其余递归查找
+func quickfixFromValue(v interface{}) []QuickfixEntry {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var generic interface{}
+	if err := json.Unmarshal(data, &generic); err != nil {
+		return nil
+	}
+	return collectQuickfix(generic)
+}
+
+// quickfixFromContent 将工具结果的内容片段转换为 quickfix 条目：
+// 文本片段按编译器输出解析，JSON 片段按 quickfixFromValue 的规则转换，其余片段忽略
+func quickfixFromContent(parts []mcp.Content) []QuickfixEntry {
+	var entries []QuickfixEntry
+	for _, p := range parts {
+		switch p.Type {
+		case mcp.ContentText:
+			entries = append(entries, quickfixFromText(p.Text)...)
+		case mcp.ContentJSON:
+			var generic interface{}
+			if err := json.Unmarshal(p.JSON, &generic); err == nil {
+				entries = append(entries, collectQuickfix(generic)...)
+			}
+		}
+	}
+	return entries
+}
+
+// collectQuickfix 递归收集 quickfix 条目
+func collectQuickfix(v interface{}) []QuickfixEntry {
+	switch v := v.(type) {
+	case string:
+		return quickfixFromText(v)
+	case []interface{}:
+		var entries []QuickfixEntry
+		for _, item := range v {
+			entries = append(entries, collectQuickfix(item)...)
+		}
+		return entries
+	case map[string]interface{}:
+		if entry, ok := quickfixFromObject(v); ok {
+			return []QuickfixEntry{entry}
+		}
+		// 按键名排序，保证输出顺序稳定
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		var entries []QuickfixEntry
+		for _, k := range keys {
+			entries = append(entries, collectQuickfix(v[k])...)
+		}
+		return entries
+	}
+	return nil
+}
+
+// quickfixFromObject 识别常见的位置字段名，如 file/path/filename 和 line/lnum
+func quickfixFromObject(obj map[string]interface{}) (QuickfixEntry, bool) {
+	filename := firstString(obj, "filename", "file", "path", "uri")
+	lnum := firstInt(obj, "lnum", "line", "line_number", "start_line")
+	if filename == "" || lnum <= 0 {
+		return QuickfixEntry{}, false
+	}
+	entry := QuickfixEntry{
+		Filename: strings.TrimPrefix(filename, "file://"),
+		Lnum:     lnum,
+		Col:      firstInt(obj, "col", "column", "start_column"),
+		Text:     firstString(obj, "text", "message", "body", "comment", "preview", "content"),
+	}
+	entry.Type = quickfixSeverity(firstString(obj, "type", "severity", "level"))
+	if entry.Type == "" {
+		entry.Type = quickfixType(entry.Text)
+	}
+	return entry, true
+}
+
+// firstString 返回第一个存在的字符串字段
+func firstString(obj map[string]interface{}, keys ...string) string {
+	for _, k := range keys {
+		if s, ok := obj[k].(string); ok && s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+// firstInt 返回第一个存在的数值字段
+func firstInt(obj map[string]interface{}, keys ...string) int {
+	for _, k := range keys {
+		switch n := obj[k].(type) {
+		case float64:
+			return int(n)
+		case string:
+			if i, err := strconv.Atoi(n); err == nil {
+				return i
+			}
+		}
+	}
+	return 0
+}
+
+// quickfixSeverity 将常见的严重级别名称映射为 quickfix 类型
+func quickfixSeverity(severity string) string {
+	switch strings.ToLower(severity) {
+	case "e", "error", "fatal":
+		return "E"
+	case "w", "warn", "warning":
+		return "W"
+	case "i", "info", "information", "hint", "note":
+		return "I"
+	}
+	return ""
+}
+
+// quickfixType 根据消息前缀推断 quickfix 类型
+func quickfixType(text string) string {
+	lower := strings.ToLower(text)
+	switch {
+	case strings.HasPrefix(lower, "error"):
+		return "E"
+	case strings.HasPrefix(lower, "warning"):
+		return "W"
+	}
+	return ""
+}
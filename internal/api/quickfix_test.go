@@ -0,0 +1,81 @@
+package api
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/liangsj/vimcoplit/internal/core/index"
+	"github.com/liangsj/vimcoplit/internal/core/mcp"
+	"github.com/liangsj/vimcoplit/internal/core/stacktrace"
+)
+
+func TestQuickfixFromText(t *testing.T) {
+	output := "# example\nmain.go:12:5: undefined: foo\npkg/util.go:3: warning: unused variable\nnot a location\n"
+	got := quickfixFromText(output)
+	want := []QuickfixEntry{
+		{Filename: "main.go", Lnum: 12, Col: 5, Text: "undefined: foo"},
+		{Filename: "pkg/util.go", Lnum: 3, Text: "warning: unused variable", Type: "W"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+}
+
+func TestQuickfixFromValue(t *testing.T) {
+	result := map[string]interface{}{
+		"diagnostics": []map[string]interface{}{
+			{"file": "file:///src/a.go", "line": 4, "column": 2, "message": "shadowed err", "severity": "warning"},
+			{"path": "b.go", "line": 0, "message": "no position"},
+		},
+		"output": "c.go:7:1: error: missing return",
+	}
+	got := quickfixFromValue(result)
+	want := []QuickfixEntry{
+		{Filename: "/src/a.go", Lnum: 4, Col: 2, Text: "shadowed err", Type: "W"},
+		{Filename: "c.go", Lnum: 7, Col: 1, Text: "error: missing return", Type: "E"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+}
+
+func TestLocationQuickfix(t *testing.T) {
+	trace := "panic: boom\n\ngoroutine 1 [running]:\nmain.run()\n\t/src/app/main.go:9 +0x1d\n"
+	got := locationQuickfix(stacktrace.Parse(trace))
+	want := []QuickfixEntry{{Filename: "/src/app/main.go", Lnum: 9, Text: "main.run", Type: "E"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+}
+
+func TestSearchQuickfix(t *testing.T) {
+	results := []index.Result{
+		{Chunk: index.Chunk{Path: "a.go", StartLine: 11, Content: "package a\n\nfunc Save() {}\n"}, Matches: []string{"Save"}},
+		{Chunk: index.Chunk{Path: "b.go", StartLine: 1, Content: "  // notes\n"}},
+	}
+	got := searchQuickfix(results)
+	want := []QuickfixEntry{
+		{Filename: "a.go", Lnum: 13, Text: "func Save() {}", Type: "I"},
+		{Filename: "b.go", Lnum: 1, Text: "// notes", Type: "I"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+}
+
+func TestQuickfixFromContent(t *testing.T) {
+	parts := []mcp.Content{
+		mcp.TextContent("a.go:3:5: undefined: x"),
+		{Type: mcp.ContentJSON, JSON: json.RawMessage(`{"diagnostics":[{"file":"b.go","line":2,"message":"unused"}]}`)},
+		mcp.BinaryContent([]byte{1, 2}, "image/png"),
+	}
+	got := quickfixFromContent(parts)
+	want := []QuickfixEntry{
+		{Filename: "a.go", Lnum: 3, Col: 5, Text: "undefined: x"},
+		{Filename: "b.go", Lnum: 2, Text: "unused"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+}
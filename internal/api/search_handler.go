@@ -0,0 +1,318 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/liangsj/vimcoplit/internal/core"
+	"github.com/liangsj/vimcoplit/internal/core/fulltext"
+	"github.com/liangsj/vimcoplit/internal/core/index"
+	"github.com/liangsj/vimcoplit/internal/i18n"
+)
+
+// handleSearch 检索仓库中的代码片段，GET 从查询参数读取 q、regex、path 和 limit，
+// POST 从请求体读取，format=quickfix 时每个片段返回一条 quickfix 条目
+func (h *Handler) handleSearch(w http.ResponseWriter, r *http.Request) {
+	var q index.Query
+	switch r.Method {
+	case "GET":
+		params := r.URL.Query()
+		q.Text, q.Regex, q.Path = params.Get("q"), params.Get("regex"), params.Get("path")
+		if value := params.Get("limit"); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				http.Error(w, i18n.T("api.limit_invalid", err), http.StatusBadRequest)
+				return
+			}
+			q.Limit = n
+		}
+	case "POST":
+		if err := json.NewDecoder(r.Body).Decode(&q); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+		return
+	}
+	if strings.TrimSpace(q.Text) == "" && q.Regex == "" {
+		http.Error(w, i18n.T("api.query_required"), http.StatusBadRequest)
+		return
+	}
+
+	results, err := h.service.SearchCode(r.Context(), &q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if wantQuickfix(r) {
+		writeQuickfix(w, searchQuickfix(results))
+		return
+	}
+	if results == nil {
+		results = []index.Result{}
+	}
+	json.NewEncoder(w).Encode(results)
+}
+
+// handleSearchFiles 按行检索工作区的文件，GET 从查询参数读取 q、regex、ignore_case、path、near 和 limit，
+// POST 从请求体读取。匹配以 SSE 的 match 事件逐条推送，结束时发送带统计的 done 事件；
+// 客户端断开连接即取消检索。format=quickfix 时检索结束后一次返回 quickfix 条目
+func (h *Handler) handleSearchFiles(w http.ResponseWriter, r *http.Request) {
+	var q index.GrepQuery
+	switch r.Method {
+	case "GET":
+		params := r.URL.Query()
+		q.Text, q.Regex, q.Path, q.Near = params.Get("q"), params.Get("regex"), params.Get("path"), params.Get("near")
+		q.IgnoreCase = params.Get("ignore_case") == "true"
+		if value := params.Get("limit"); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				http.Error(w, i18n.T("api.limit_invalid", err), http.StatusBadRequest)
+				return
+			}
+			q.Limit = n
+		}
+	case "POST":
+		if err := json.NewDecoder(r.Body).Decode(&q); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+		return
+	}
+	if q.Text == "" && q.Regex == "" {
+		http.Error(w, i18n.T("api.query_required"), http.StatusBadRequest)
+		return
+	}
+
+	streamMatches(w, r, func(fn func(index.Match) bool) (*index.GrepSummary, error) {
+		return h.service.SearchFiles(r.Context(), &q, fn)
+	})
+}
+
+// handleSearchTranscripts 全文检索对话、任务和命令输出，GET 从查询参数读取 q、kind（逗号分隔）、
+// since、until 和 limit，POST 从请求体读取，结果按相关性排序并带有高亮的摘要
+func (h *Handler) handleSearchTranscripts(w http.ResponseWriter, r *http.Request) {
+	var q fulltext.Query
+	switch r.Method {
+	case "GET":
+		params := r.URL.Query()
+		q.Text = params.Get("q")
+		if kinds := params.Get("kind"); kinds != "" {
+			for _, kind := range strings.Split(kinds, ",") {
+				q.Kinds = append(q.Kinds, fulltext.Kind(strings.TrimSpace(kind)))
+			}
+		}
+		var err error
+		if q.Since, err = parseHistoryTime(params.Get("since")); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if q.Until, err = parseHistoryTime(params.Get("until")); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if value := params.Get("limit"); value != "" {
+			if q.Limit, err = strconv.Atoi(value); err != nil {
+				http.Error(w, i18n.T("api.limit_invalid", err), http.StatusBadRequest)
+				return
+			}
+		}
+	case "POST":
+		if err := json.NewDecoder(r.Body).Decode(&q); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+		return
+	}
+	if strings.TrimSpace(q.Text) == "" {
+		http.Error(w, i18n.T("api.query_required"), http.StatusBadRequest)
+		return
+	}
+
+	results, err := h.service.SearchTranscripts(r.Context(), &q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if results == nil {
+		results = []*fulltext.Result{}
+	}
+	json.NewEncoder(w).Encode(results)
+}
+
+// streamMatches 以 SSE 推送文件检索的匹配，结束时发送带统计的 done 事件，
+// format=quickfix 时检索结束后一次返回 quickfix 条目。开始推送前出错时返回 400
+func streamMatches(w http.ResponseWriter, r *http.Request, search func(fn func(index.Match) bool) (*index.GrepSummary, error)) {
+	if wantQuickfix(r) {
+		entries := []QuickfixEntry{}
+		_, err := search(func(m index.Match) bool {
+			entries = append(entries, QuickfixEntry{Filename: m.Path, Lnum: m.Line, Col: m.Column, Text: m.Text, Type: "I"})
+			return true
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeQuickfix(w, entries)
+		return
+	}
+
+	started := false
+	send := func(event string, v interface{}) bool {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return false
+		}
+		if !started {
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Header().Set("Cache-Control", "no-cache")
+			w.WriteHeader(http.StatusOK)
+			started = true
+		}
+		if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data); err != nil {
+			return false
+		}
+		flush(w)
+		return true
+	}
+	summary, err := search(func(m index.Match) bool {
+		return send("match", m)
+	})
+	if err != nil {
+		if !started {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+	send("done", summary)
+}
+
+// handleSavedSearches 列出（GET）、创建或替换（POST）和删除（DELETE ?name=）工作区的保存检索
+func (h *Handler) handleSavedSearches(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		json.NewEncoder(w).Encode(h.service.ListSavedSearches(r.Context()))
+	case "POST":
+		var search core.SavedSearch
+		if err := json.NewDecoder(r.Body).Decode(&search); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		saved, err := h.service.SaveSearch(r.Context(), &search)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(saved)
+	case "DELETE":
+		if err := h.service.DeleteSavedSearch(r.Context(), r.URL.Query().Get("name")); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+	}
+}
+
+// handleRunSavedSearch 执行名为 name 的保存检索，结果格式与 /api/search/files 相同
+func (h *Handler) handleRunSavedSearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+		return
+	}
+	name := r.URL.Query().Get("name")
+	streamMatches(w, r, func(fn func(index.Match) bool) (*index.GrepSummary, error) {
+		return h.service.RunSavedSearch(r.Context(), name, fn)
+	})
+}
+
+// searchQuickfix 将检索结果转换为 quickfix 条目，定位到片段中第一个精确匹配的标识符所在行
+func searchQuickfix(results []index.Result) []QuickfixEntry {
+	entries := make([]QuickfixEntry, 0, len(results))
+	for _, r := range results {
+		lines := strings.Split(r.Content, "\n")
+		line := 0
+		for i, text := range lines {
+			if len(r.Matches) > 0 && strings.Contains(text, r.Matches[0]) {
+				line = i
+				break
+			}
+		}
+		entries = append(entries, QuickfixEntry{
+			Filename: r.Path,
+			Lnum:     r.StartLine + line,
+			Text:     strings.TrimSpace(lines[line]),
+			Type:     "I",
+		})
+	}
+	return entries
+}
+
+// handleIndex 查询代码检索索引的状态（GET），在后台开始刷新（POST）或取消正在进行的刷新（DELETE）
+func (h *Handler) handleIndex(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		json.NewEncoder(w).Encode(h.service.IndexStatus())
+	case "POST":
+		started := h.service.StartIndexing()
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]bool{"started": started})
+	case "DELETE":
+		json.NewEncoder(w).Encode(map[string]bool{"canceled": h.service.CancelIndexing()})
+	default:
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+	}
+}
+
+// handleIndexProgress 以 SSE 推送索引刷新的进度，刷新结束或没有刷新在进行时发送最后一条进度后关闭
+func (h *Handler) handleIndexProgress(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+		return
+	}
+	current, updates, cancel := h.service.WatchIndexing()
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	for p := current; ; {
+		data, err := json.Marshal(p)
+		if err != nil {
+			return
+		}
+		fmt.Fprintf(w, "event: progress\ndata: %s\n\n", data)
+		flush(w)
+		if !p.Running {
+			return
+		}
+		select {
+		case p = <-updates:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// handleIndexFiles 返回参与代码检索索引的文件，all=true 时同时返回被排除的文件和目录及其原因
+func (h *Handler) handleIndexFiles(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+		return
+	}
+	files, err := h.service.IndexFiles(r.Context(), r.URL.Query().Get("all") == "true")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(files)
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/liangsj/vimcoplit/internal/i18n"
+	"github.com/liangsj/vimcoplit/internal/setup"
+)
+
+// handleSetup 查询配置状态，或执行与 vimcoplit init 相同的配置向导。
+// 写入成功后模型和允许执行的命令立即生效，修改工作区根目录需要重启服务
+func (h *Handler) handleSetup(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		json.NewEncoder(w).Encode(h.service.SetupStatus())
+
+	case "POST":
+		var opts setup.Options
+		if err := json.NewDecoder(r.Body).Decode(&opts); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		result, written, err := setup.Run(r.Context(), h.cfg.Path(), &opts)
+		if err != nil {
+			if result != nil {
+				// 连通性检查失败，返回检查结果供插件展示
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				json.NewEncoder(w).Encode(result)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		restartRequired := written.WorkspaceRoot() != h.cfg.WorkspaceRoot()
+		if err := h.service.ApplySetup(r.Context(), written); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"result":           result,
+			"status":           h.service.SetupStatus(),
+			"restart_required": restartRequired,
+		})
+
+	default:
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+	}
+}
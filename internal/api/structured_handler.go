@@ -0,0 +1,66 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/liangsj/vimcoplit/internal/core"
+	"github.com/liangsj/vimcoplit/internal/core/schema"
+	"github.com/liangsj/vimcoplit/internal/i18n"
+)
+
+// handleGenerateStructured 生成符合 JSON Schema 的输出，供需要机器可读结果的插件功能使用。
+// 修正后仍不符合 schema 时返回 422 以及最后一次的输出和校验错误
+func (h *Handler) handleGenerateStructured(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		core.StructuredRequest
+		Override bool `json:"override"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Prompt) == "" {
+		http.Error(w, i18n.T("api.prompt_required"), http.StatusBadRequest)
+		return
+	}
+	if _, err := schema.Parse(req.Schema); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.service.GenerateStructured(r.Context(), &req.StructuredRequest)
+	var structErr *core.StructuredError
+	if errors.As(err, &structErr) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":    structErr.Error(),
+			"output":   structErr.Output,
+			"errors":   structErr.Errors,
+			"attempts": structErr.Attempts,
+		})
+		return
+	}
+	if err != nil {
+		writeModelError(w, err)
+		return
+	}
+	// 与普通生成一样经过输出过滤，被拦截时需带 override 重新请求
+	findings, err := h.service.CheckOutput(filterContext(r.Context(), req.Override), string(result.Value))
+	if err != nil {
+		http.Error(w, err.Error(), filterStatus(err, http.StatusInternalServerError))
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"value":    result.Value,
+		"attempts": result.Attempts,
+		"findings": findings,
+	})
+}
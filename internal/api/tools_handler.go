@@ -0,0 +1,61 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/liangsj/vimcoplit/internal/core"
+	"github.com/liangsj/vimcoplit/internal/i18n"
+)
+
+// handleGenerateTools 让模型调用已注册的 MCP 工具完成请求，返回最终回复和执行过的工具调用。
+// 达到最多生成轮数时返回 422 以及已执行的调用
+func (h *Handler) handleGenerateTools(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		core.ToolLoopRequest
+		Override bool `json:"override"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Prompt) == "" {
+		http.Error(w, i18n.T("api.prompt_required"), http.StatusBadRequest)
+		return
+	}
+
+	// 模型请求的调用与 agent 的工具步骤一样经过权限检查，override 同时作用于每次调用的输出过滤
+	req.Authorize = h.agent.AuthorizeTool
+	result, err := h.service.GenerateWithTools(filterContext(r.Context(), req.Override), &req.ToolLoopRequest)
+	if errors.Is(err, core.ErrToolRounds) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":  err.Error(),
+			"calls":  result.Calls,
+			"rounds": result.Rounds,
+		})
+		return
+	}
+	if err != nil {
+		writeModelError(w, err)
+		return
+	}
+	findings, err := h.service.CheckOutput(filterContext(r.Context(), req.Override), result.Text)
+	if err != nil {
+		http.Error(w, err.Error(), filterStatus(err, http.StatusInternalServerError))
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"text":     result.Text,
+		"calls":    result.Calls,
+		"rounds":   result.Rounds,
+		"findings": findings,
+	})
+}
@@ -0,0 +1,37 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+)
+
+const (
+	// legacyAPIPrefix 是未版本化的旧路由前缀
+	legacyAPIPrefix = "/api/"
+
+	// versionedAPIPrefix 是当前版本的路由前缀
+	versionedAPIPrefix = "/api/v" + APIVersion + "/"
+
+	// legacySunset 是旧路由计划下线的时间（RFC 8594）
+	legacySunset = "Wed, 30 Jun 2027 00:00:00 GMT"
+)
+
+// normalizeAPIPath 将版本化路径转换为内部路由使用的路径
+// 返回值 legacy 表示请求使用的是未版本化的旧路径
+func normalizeAPIPath(path string) (route string, legacy bool) {
+	if strings.HasPrefix(path, versionedAPIPrefix) {
+		return legacyAPIPrefix + strings.TrimPrefix(path, versionedAPIPrefix), false
+	}
+	if strings.HasPrefix(path, legacyAPIPrefix) {
+		return path, true
+	}
+	return path, false
+}
+
+// setDeprecationHeaders 为旧路由设置弃用相关的响应头
+func setDeprecationHeaders(w http.ResponseWriter, route string) {
+	successor := versionedAPIPrefix + strings.TrimPrefix(route, legacyAPIPrefix)
+	w.Header().Set("Deprecation", "true")
+	w.Header().Set("Sunset", legacySunset)
+	w.Header().Set("Link", "<"+successor+">; rel=\"successor-version\"")
+}
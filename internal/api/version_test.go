@@ -0,0 +1,64 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/liangsj/vimcoplit/internal/analytics"
+	"github.com/liangsj/vimcoplit/internal/config"
+	"github.com/liangsj/vimcoplit/internal/observer"
+)
+
+func TestNormalizeAPIPath(t *testing.T) {
+	tests := []struct {
+		path   string
+		route  string
+		legacy bool
+	}{
+		{"/api/v1/tasks", "/api/tasks", false},
+		{"/api/v1/conversations/messages", "/api/conversations/messages", false},
+		{"/api/tasks", "/api/tasks", true},
+		{"/api/v2/tasks", "/api/v2/tasks", true},
+		{"/dashboard/", "/dashboard/", false},
+	}
+	for _, tt := range tests {
+		route, legacy := normalizeAPIPath(tt.path)
+		if route != tt.route || legacy != tt.legacy {
+			t.Errorf("%s: expected %s %v, got %s %v", tt.path, tt.route, tt.legacy, route, legacy)
+		}
+	}
+}
+
+func TestDeprecationHeaders(t *testing.T) {
+	h := &Handler{
+		cfg:       config.DefaultConfig(),
+		analytics: analytics.New(filepath.Join(t.TempDir(), "analytics.json"), false),
+		observers: observer.New(),
+	}
+	serve := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = "127.0.0.1:5000"
+		req.Host = "localhost:8080"
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve("/api/v1/capabilities")
+	if rec.Code != http.StatusOK || rec.Header().Get("Deprecation") != "" {
+		t.Errorf("expected versioned route without deprecation, got %d %q", rec.Code, rec.Header().Get("Deprecation"))
+	}
+
+	rec = serve("/api/capabilities")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected legacy route to still work, got %d", rec.Code)
+	}
+	if rec.Header().Get("Deprecation") != "true" || rec.Header().Get("Sunset") != legacySunset {
+		t.Errorf("unexpected deprecation headers %v", rec.Header())
+	}
+	if link := rec.Header().Get("Link"); link != `</api/v1/capabilities>; rel="successor-version"` {
+		t.Errorf("unexpected successor link %q", link)
+	}
+}
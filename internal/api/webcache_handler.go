@@ -0,0 +1,26 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/liangsj/vimcoplit/internal/i18n"
+)
+
+// handleWebCache 返回网页缓存的使用情况（GET），或删除所有缓存的网页（DELETE）。
+// 未启用网页缓存时 GET 返回 {"enabled": false}
+func (h *Handler) handleWebCache(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		stats := h.service.WebCacheStats()
+		if stats == nil {
+			json.NewEncoder(w).Encode(map[string]bool{"enabled": false})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"enabled": true, "stats": stats})
+	case "DELETE":
+		json.NewEncoder(w).Encode(map[string]int{"removed": h.service.ClearWebCache()})
+	default:
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/liangsj/vimcoplit/internal/core"
+	"github.com/liangsj/vimcoplit/internal/core/websearch"
+	"github.com/liangsj/vimcoplit/internal/i18n"
+)
+
+// handleWebSearch 搜索网页（POST {"query": "...", "count": 5}），或返回当天的查询次数（GET）。
+// 未启用网页搜索时 GET 返回 {"enabled": false}，POST 返回 503；当天次数用完时返回 429
+func (h *Handler) handleWebSearch(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		usage := h.service.WebSearchUsage()
+		if usage == nil {
+			json.NewEncoder(w).Encode(map[string]bool{"enabled": false})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"enabled": true, "usage": usage})
+	case "POST":
+		var req struct {
+			Query string `json:"query"`
+			Count int    `json:"count"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.Query == "" {
+			http.Error(w, i18n.T("api.search_query_required"), http.StatusBadRequest)
+			return
+		}
+		resp, err := h.service.SearchWeb(r.Context(), req.Query, req.Count)
+		switch {
+		case errors.Is(err, core.ErrWebSearchDisabled):
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		case errors.Is(err, websearch.ErrBudgetExceeded):
+			http.Error(w, err.Error(), http.StatusTooManyRequests)
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusBadGateway)
+		default:
+			json.NewEncoder(w).Encode(resp)
+		}
+	default:
+		http.Error(w, i18n.T("api.method_not_allowed"), http.StatusMethodNotAllowed)
+	}
+}
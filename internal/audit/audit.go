@@ -0,0 +1,211 @@
+// Package audit 提供带哈希链的只追加审计日志：每条记录包含上一条记录的哈希，
+// 任何对历史记录的修改、删除或重排都会在校验时被发现
+package audit
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/liangsj/vimcoplit/internal/events"
+)
+
+// Types 是写入审计日志的事件类型：文件写入、命令执行和工具调用
+var Types = []events.Type{events.TypeFile, events.TypeCommand, events.TypeTool}
+
+// maxLineSize 是读取日志时单条记录的最大字节数
+const maxLineSize = 16 << 20
+
+// Entry 是一条审计记录，Hash 覆盖 PrevHash 和其余字段
+type Entry struct {
+	Seq      int64           `json:"seq"`
+	Time     time.Time       `json:"time"`
+	Type     string          `json:"type"`
+	Data     json.RawMessage `json:"data"`
+	PrevHash string          `json:"prev_hash"`
+	Hash     string          `json:"hash"`
+}
+
+// computeHash 计算记录的哈希
+func (e *Entry) computeHash() string {
+	h := sha256.New()
+	h.Write([]byte(e.PrevHash))
+	h.Write([]byte{'\n'})
+	h.Write([]byte(strconv.FormatInt(e.Seq, 10)))
+	h.Write([]byte{'\n'})
+	h.Write([]byte(e.Time.UTC().Format(time.RFC3339Nano)))
+	h.Write([]byte{'\n'})
+	h.Write([]byte(e.Type))
+	h.Write([]byte{'\n'})
+	h.Write(e.Data)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Report 是完整性校验的结果，Valid 为 false 时 BrokenAt 为第一条无效记录的行号（从 1 开始）
+type Report struct {
+	Valid    bool   `json:"valid"`
+	Entries  int64  `json:"entries"`
+	LastHash string `json:"last_hash,omitempty"`
+	BrokenAt int64  `json:"broken_at,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// Verify 逐条校验 r 中的审计记录，可用于校验导出的日志
+func Verify(r io.Reader) (*Report, error) {
+	report := &Report{Valid: true}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	var line int64
+	for scanner.Scan() {
+		line++
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return report.broken(line, fmt.Sprintf("malformed entry: %v", err)), nil
+		}
+		switch {
+		case e.Seq != report.Entries+1:
+			return report.broken(line, fmt.Sprintf("expected seq %d, got %d", report.Entries+1, e.Seq)), nil
+		case e.PrevHash != report.LastHash:
+			return report.broken(line, "previous hash does not match"), nil
+		case e.Hash != e.computeHash():
+			return report.broken(line, "entry hash does not match its content"), nil
+		}
+		report.Entries++
+		report.LastHash = e.Hash
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// broken 把报告标记为在 line 行失效
+func (r *Report) broken(line int64, reason string) *Report {
+	r.Valid = false
+	r.BrokenAt = line
+	r.Reason = reason
+	return r
+}
+
+// Log 是写入文件的审计日志，并发安全
+type Log struct {
+	mu   sync.Mutex
+	path string
+	seq  int64
+	last string
+}
+
+// Open 打开 path 处的审计日志，已有记录时从最后一条继续哈希链。
+// 已有记录校验失败时返回错误，避免在被篡改的日志后继续追加
+func Open(path string) (*Log, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	l := &Log{path: path}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	report, err := Verify(f)
+	if err != nil {
+		return nil, err
+	}
+	if !report.Valid {
+		return nil, fmt.Errorf("audit log is corrupted at line %d: %s", report.BrokenAt, report.Reason)
+	}
+	l.seq, l.last = report.Entries, report.LastHash
+	return l, nil
+}
+
+// Append 追加一条记录，写入后立即同步到磁盘
+func (l *Log) Append(typ string, t time.Time, data interface{}) (*Entry, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e := &Entry{
+		Seq:      l.seq + 1,
+		Time:     t.UTC(),
+		Type:     typ,
+		Data:     raw,
+		PrevHash: l.last,
+	}
+	e.Hash = e.computeHash()
+	line, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+	l.seq, l.last = e.Seq, e.Hash
+	return e, nil
+}
+
+// Record 把总线事件写入审计日志，用作 events.Bus 的 Hook
+func (l *Log) Record(event events.Event) error {
+	_, err := l.Append(string(event.Type), event.Time, event.Data)
+	return err
+}
+
+// Verify 校验整个审计日志文件
+func (l *Log) Verify() (*Report, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	f, err := os.Open(l.path)
+	if os.IsNotExist(err) {
+		return &Report{Valid: true}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Verify(f)
+}
+
+// Export 把审计日志原样以 JSONL 格式写入 w，导出的内容可以用 Verify 独立校验
+func (l *Log) Export(w io.Writer) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	f, err := os.Open(l.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
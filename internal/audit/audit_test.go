@@ -0,0 +1,89 @@
+package audit
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/liangsj/vimcoplit/internal/events"
+)
+
+func TestAppendAndVerify(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit", "audit.jsonl")
+	l, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	for i, typ := range []string{"file", "command", "tool"} {
+		if _, err := l.Append(typ, time.Now(), map[string]int{"n": i}); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+
+	report, err := l.Verify()
+	if err != nil || !report.Valid || report.Entries != 3 {
+		t.Fatalf("expected valid log with 3 entries, got %+v %v", report, err)
+	}
+
+	// 重新打开后继续哈希链
+	l, err = Open(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	e, err := l.Append("file", time.Now(), "more")
+	if err != nil || e.Seq != 4 || e.PrevHash != report.LastHash {
+		t.Fatalf("expected chain to continue, got %+v %v", e, err)
+	}
+
+	var buf bytes.Buffer
+	if err := l.Export(&buf); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	if report, err := Verify(&buf); err != nil || !report.Valid || report.Entries != 4 {
+		t.Errorf("expected exported log to verify, got %+v %v", report, err)
+	}
+}
+
+func TestVerifyDetectsTampering(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	l, _ := Open(path)
+	for i := 0; i < 3; i++ {
+		l.Append("command", time.Now(), map[string]string{"command": "echo ok"})
+	}
+	data, _ := os.ReadFile(path)
+	lines := strings.SplitAfter(string(data), "\n")
+
+	for name, tampered := range map[string]string{
+		"modified":  lines[0] + strings.Replace(lines[1], "echo ok", "rm -rf", 1) + lines[2],
+		"deleted":   lines[0] + lines[2],
+		"reordered": lines[1] + lines[0] + lines[2],
+	} {
+		report, err := Verify(strings.NewReader(tampered))
+		if err != nil || report.Valid || report.BrokenAt == 0 {
+			t.Errorf("%s: expected broken report, got %+v %v", name, report, err)
+		}
+	}
+
+	os.WriteFile(path, []byte(lines[0]+lines[2]), 0600)
+	if _, err := Open(path); err == nil {
+		t.Error("expected Open to reject a corrupted log")
+	}
+}
+
+func TestRecordFromBus(t *testing.T) {
+	l, _ := Open(filepath.Join(t.TempDir(), "audit.jsonl"))
+	bus := events.New(1)
+	bus.Hook(func(e events.Event) { l.Record(e) }, Types...)
+	for i := 0; i < 100; i++ {
+		bus.Publish(events.TypeTool, i)
+	}
+	bus.Publish(events.TypeChat, "not audited")
+
+	report, err := l.Verify()
+	if err != nil || !report.Valid || report.Entries != 100 {
+		t.Errorf("expected every tool event in the log, got %+v %v", report, err)
+	}
+}
@@ -0,0 +1,303 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// FormatVersion 是备份归档格式的版本
+const FormatVersion = 1
+
+// manifestName 是归档中清单文件的名称
+const manifestName = "manifest.json"
+
+// MaxEntrySize 是归档中单个文件解压后的大小上限
+const MaxEntrySize = 256 << 20
+
+// MaxArchiveSize 是归档中所有文件解压后的总大小上限
+const MaxArchiveSize = 2 << 30
+
+// Manifest 描述一个备份归档的内容
+type Manifest struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	Files     []string  `json:"files"`
+}
+
+// Source 将磁盘上的文件或目录映射为归档中的名称
+// Path 是目录时，目录下的所有文件以 Name/相对路径 的形式保存
+type Source struct {
+	Name string
+	Path string
+	// Filter 在打包前处理每个文件的内容，用于去掉不应写入归档的数据
+	Filter func(data []byte) ([]byte, error)
+	// Merge 在恢复时合并归档中的内容和磁盘上现有的内容，文件不存在时 current 为 nil
+	Merge func(restored, current []byte) ([]byte, error)
+}
+
+// Create 将 sources 中的文件和 blobs 中的内存数据打包为 tar.gz 归档
+// 不存在的文件会被跳过
+func Create(w io.Writer, sources []Source, blobs map[string][]byte) (*Manifest, error) {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	manifest := &Manifest{
+		Version:   FormatVersion,
+		CreatedAt: time.Now(),
+	}
+
+	for _, src := range sources {
+		if src.Path == "" {
+			continue
+		}
+		err := filepath.WalkDir(src.Path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if errors.Is(err, fs.ErrNotExist) {
+					return nil
+				}
+				return err
+			}
+			if !d.Type().IsRegular() {
+				return nil
+			}
+
+			rel, err := filepath.Rel(src.Path, p)
+			if err != nil {
+				return err
+			}
+			name := src.Name
+			if rel != "." {
+				name = path.Join(src.Name, filepath.ToSlash(rel))
+			}
+
+			data, err := os.ReadFile(p)
+			if err != nil {
+				return err
+			}
+			if src.Filter != nil {
+				if data, err = src.Filter(data); err != nil {
+					return err
+				}
+			}
+			if err := writeEntry(tw, name, data); err != nil {
+				return err
+			}
+			manifest.Files = append(manifest.Files, name)
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to archive %s: %v", src.Path, err)
+		}
+	}
+
+	for name, data := range blobs {
+		if err := writeEntry(tw, name, data); err != nil {
+			return nil, err
+		}
+		manifest.Files = append(manifest.Files, name)
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := writeEntry(tw, manifestName, data); err != nil {
+		return nil, err
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// writeEntry 向归档写入一个文件
+func writeEntry(tw *tar.Writer, name string, data []byte) error {
+	hdr := &tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    int64(len(data)),
+		ModTime: time.Now(),
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
+
+// Extract 从归档中恢复文件
+// 名称匹配 targets 的文件先解压到临时目录，确认归档完整、清单与内容一致后才写回对应路径，
+// 其余文件以内存数据的形式返回。单个文件和归档总大小分别受 MaxEntrySize 和 MaxArchiveSize 限制
+func Extract(r io.Reader, targets []Source) (*Manifest, map[string][]byte, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid backup archive: %v", err)
+	}
+	defer gz.Close()
+
+	staging, err := os.MkdirTemp("", "vimcoplit-restore-*")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer os.RemoveAll(staging)
+
+	var manifest *Manifest
+	var staged []string
+	blobs := make(map[string][]byte)
+	seen := make(map[string]bool)
+	var total int64
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read backup archive: %v", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		name := path.Clean(hdr.Name)
+		if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return nil, nil, fmt.Errorf("invalid entry name in backup: %s", hdr.Name)
+		}
+		if seen[name] {
+			return nil, nil, fmt.Errorf("duplicate entry in backup: %s", name)
+		}
+		seen[name] = true
+
+		// 头中的大小可以伪造，以实际读到的数据为准
+		data, err := io.ReadAll(io.LimitReader(tr, MaxEntrySize+1))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read backup archive: %v", err)
+		}
+		if len(data) > MaxEntrySize {
+			return nil, nil, fmt.Errorf("backup entry %s exceeds %d bytes", name, MaxEntrySize)
+		}
+		if total += int64(len(data)); total > MaxArchiveSize {
+			return nil, nil, fmt.Errorf("backup archive exceeds %d bytes", int64(MaxArchiveSize))
+		}
+
+		if name == manifestName {
+			manifest = &Manifest{}
+			if err := json.Unmarshal(data, manifest); err != nil {
+				return nil, nil, fmt.Errorf("invalid backup manifest: %v", err)
+			}
+			if manifest.Version > FormatVersion {
+				return nil, nil, fmt.Errorf("unsupported backup version: %d", manifest.Version)
+			}
+			continue
+		}
+
+		if _, _, ok := resolveTarget(targets, name); !ok {
+			blobs[name] = data
+			continue
+		}
+		dest := filepath.Join(staging, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(dest), 0700); err != nil {
+			return nil, nil, err
+		}
+		if err := os.WriteFile(dest, data, 0600); err != nil {
+			return nil, nil, err
+		}
+		staged = append(staged, name)
+	}
+
+	if manifest == nil {
+		return nil, nil, errors.New("backup manifest not found")
+	}
+	listed := make(map[string]bool)
+	for _, name := range manifest.Files {
+		name = path.Clean(name)
+		if !seen[name] {
+			return nil, nil, fmt.Errorf("backup is incomplete, missing %s", name)
+		}
+		listed[name] = true
+	}
+	for name := range seen {
+		if name != manifestName && !listed[name] {
+			return nil, nil, fmt.Errorf("backup entry %s is not listed in the manifest", name)
+		}
+	}
+
+	for _, name := range staged {
+		target, dest, _ := resolveTarget(targets, name)
+		data, err := os.ReadFile(filepath.Join(staging, filepath.FromSlash(name)))
+		if err != nil {
+			return nil, nil, err
+		}
+		if target.Merge != nil {
+			current, err := os.ReadFile(dest)
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return nil, nil, err
+			}
+			if data, err = target.Merge(data, current); err != nil {
+				return nil, nil, fmt.Errorf("failed to restore %s: %v", name, err)
+			}
+		}
+		if err := replaceFile(dest, data); err != nil {
+			return nil, nil, fmt.Errorf("failed to restore %s: %v", name, err)
+		}
+	}
+	return manifest, blobs, nil
+}
+
+// replaceFile 先写入同目录下的临时文件再重命名，已有文件保留原来的权限，新文件只允许当前用户读写
+func replaceFile(dest string, data []byte) error {
+	mode := os.FileMode(0600)
+	if info, err := os.Stat(dest); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dest), "."+filepath.Base(dest)+".restore-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), dest)
+}
+
+// resolveTarget 根据归档中的名称找到对应的 Source 和磁盘路径
+func resolveTarget(targets []Source, name string) (Source, string, bool) {
+	for _, t := range targets {
+		if t.Path == "" {
+			continue
+		}
+		if name == t.Name {
+			return t, t.Path, true
+		}
+		if rel, ok := strings.CutPrefix(name, t.Name+"/"); ok {
+			return t, filepath.Join(t.Path, filepath.FromSlash(rel)), true
+		}
+	}
+	return Source{}, "", false
+}
@@ -0,0 +1,152 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCreateAndExtract(t *testing.T) {
+	srcDir := t.TempDir()
+	configPath := filepath.Join(srcDir, "config.json")
+	dataDir := filepath.Join(srcDir, "data")
+	if err := os.WriteFile(configPath, []byte(`{"server":{}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dataDir, "sessions"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dataDir, "sessions", "a.json"), []byte("session"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	manifest, err := Create(&buf, []Source{
+		{Name: "config.json", Path: configPath},
+		{Name: "data", Path: dataDir},
+		{Name: "missing.json", Path: filepath.Join(srcDir, "missing.json")},
+	}, map[string][]byte{"context.json": []byte("[]")})
+	if err != nil {
+		t.Fatalf("failed to create backup: %v", err)
+	}
+	if len(manifest.Files) != 3 {
+		t.Errorf("expected 3 files in manifest, got %d: %v", len(manifest.Files), manifest.Files)
+	}
+
+	dstDir := t.TempDir()
+	restored, blobs, err := Extract(&buf, []Source{
+		{Name: "config.json", Path: filepath.Join(dstDir, "config.json")},
+		{Name: "data", Path: filepath.Join(dstDir, "data")},
+	})
+	if err != nil {
+		t.Fatalf("failed to extract backup: %v", err)
+	}
+	if restored.Version != FormatVersion {
+		t.Errorf("expected version %d, got %d", FormatVersion, restored.Version)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dstDir, "data", "sessions", "a.json"))
+	if err != nil {
+		t.Fatalf("expected session file to be restored: %v", err)
+	}
+	if string(data) != "session" {
+		t.Errorf("unexpected session content %q", data)
+	}
+	if string(blobs["context.json"]) != "[]" {
+		t.Errorf("expected context blob to be returned, got %q", blobs["context.json"])
+	}
+}
+
+// writeArchive 按给定的顺序写入归档条目，用于构造损坏或被篡改的归档
+func writeArchive(t *testing.T, entries ...[2]string) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, e := range entries {
+		if err := writeEntry(tw, e[0], []byte(e[1])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &buf
+}
+
+func TestExtractRejectsBeforeWriting(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries [][2]string
+	}{
+		{"no manifest", [][2]string{{"data/a.json", "new"}}},
+		{"unlisted entry", [][2]string{{"data/a.json", "new"}, {manifestName, `{"version":1,"files":[]}`}}},
+		{"missing entry", [][2]string{{"data/a.json", "new"}, {manifestName, `{"version":1,"files":["data/a.json","data/b.json"]}`}}},
+		{"future version", [][2]string{{"data/a.json", "new"}, {manifestName, `{"version":99,"files":["data/a.json"]}`}}},
+		{"duplicate entry", [][2]string{{"data/a.json", "new"}, {"data/a.json", "again"}, {manifestName, `{"version":1,"files":["data/a.json"]}`}}},
+		{"path traversal", [][2]string{{"data/a.json", "new"}, {"../evil", "x"}, {manifestName, `{"version":1,"files":["data/a.json","../evil"]}`}}},
+	}
+	for _, tt := range tests {
+		dataDir := t.TempDir()
+		existing := filepath.Join(dataDir, "a.json")
+		if err := os.WriteFile(existing, []byte("old"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		_, _, err := Extract(writeArchive(t, tt.entries...), []Source{{Name: "data", Path: dataDir}})
+		if err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+		if data, _ := os.ReadFile(existing); string(data) != "old" {
+			t.Errorf("%s: expected existing files to be left untouched, got %q", tt.name, data)
+		}
+	}
+}
+
+func TestExtractEntryLimit(t *testing.T) {
+	big := strings.Repeat("x", MaxEntrySize+1)
+	_, _, err := Extract(writeArchive(t, [2]string{"context.json", big}), nil)
+	if err == nil || !strings.Contains(err.Error(), "exceeds") {
+		t.Errorf("expected the oversized entry to be rejected, got %v", err)
+	}
+}
+
+func TestFilterAndMerge(t *testing.T) {
+	srcDir := t.TempDir()
+	src := filepath.Join(srcDir, "config.json")
+	if err := os.WriteFile(src, []byte("public secret"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	_, err := Create(&buf, []Source{{Name: "config.json", Path: src, Filter: func(data []byte) ([]byte, error) {
+		return bytes.ReplaceAll(data, []byte(" secret"), nil), nil
+	}}}, nil)
+	if err != nil {
+		t.Fatalf("failed to create backup: %v", err)
+	}
+
+	dest := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(dest, []byte("kept"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = Extract(&buf, []Source{{Name: "config.json", Path: dest, Merge: func(restored, current []byte) ([]byte, error) {
+		return append(append(restored, ' '), current...), nil
+	}}})
+	if err != nil {
+		t.Fatalf("failed to extract backup: %v", err)
+	}
+	data, _ := os.ReadFile(dest)
+	if string(data) != "public kept" {
+		t.Errorf("expected the filtered content merged with the current file, got %q", data)
+	}
+	if info, _ := os.Stat(dest); info.Mode().Perm() != 0600 {
+		t.Errorf("expected the file mode to be kept, got %v", info.Mode().Perm())
+	}
+}
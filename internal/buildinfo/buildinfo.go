@@ -0,0 +1,80 @@
+// Package buildinfo 保存构建时通过 ldflags 注入的版本信息
+//
+//	go build -ldflags "-X github.com/liangsj/vimcoplit/internal/buildinfo.Version=v1.2.0 \
+//	  -X github.com/liangsj/vimcoplit/internal/buildinfo.Commit=$(git rev-parse HEAD) \
+//	  -X github.com/liangsj/vimcoplit/internal/buildinfo.Date=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+package buildinfo
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+var (
+	// Version 是当前构建的版本号，未注入时为 dev
+	Version = "dev"
+	// Commit 是构建时的 git 提交，未注入时从 Go 记录的 VCS 信息读取
+	Commit = ""
+	// Date 是构建时间，RFC 3339 格式，未注入时使用提交时间
+	Date = ""
+	// UpdatePublicKey 是校验自更新下载的发布公钥，base64 编码的 ed25519 公钥，由发布构建注入
+	UpdatePublicKey = ""
+)
+
+// Info 是当前构建的版本信息
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	Date      string `json:"date"`
+	Modified  bool   `json:"modified,omitempty"` // 构建时工作区有未提交的修改
+	GoVersion string `json:"go_version"`
+	Platform  string `json:"platform"`
+}
+
+// Get 返回当前构建的版本信息
+func Get() *Info {
+	info := &Info{
+		Version:   Version,
+		Commit:    Commit,
+		Date:      Date,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = s.Value
+				}
+			case "vcs.time":
+				if info.Date == "" {
+					info.Date = s.Value
+				}
+			case "vcs.modified":
+				info.Modified = s.Value == "true"
+			}
+		}
+	}
+	return info
+}
+
+// String 返回 vimcoplit --version 输出的单行版本信息
+func (i *Info) String() string {
+	commit := i.Commit
+	if len(commit) > 12 {
+		commit = commit[:12]
+	}
+	if commit == "" {
+		commit = "unknown"
+	}
+	if i.Modified {
+		commit += "-dirty"
+	}
+	date := i.Date
+	if date == "" {
+		date = "unknown"
+	}
+	return fmt.Sprintf("vimcoplit %s (commit %s, built %s, %s, %s)", i.Version, commit, date, i.GoVersion, i.Platform)
+}
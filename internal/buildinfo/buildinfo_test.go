@@ -0,0 +1,21 @@
+package buildinfo
+
+import "testing"
+
+func TestInfoString(t *testing.T) {
+	info := &Info{
+		Version:   "v1.2.0",
+		Commit:    "0123456789abcdef0123",
+		Date:      "2026-01-02T03:04:05Z",
+		Modified:  true,
+		GoVersion: "go1.24.3",
+		Platform:  "linux/amd64",
+	}
+	want := "vimcoplit v1.2.0 (commit 0123456789ab-dirty, built 2026-01-02T03:04:05Z, go1.24.3, linux/amd64)"
+	if got := info.String(); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+	if got := (&Info{Version: "dev"}).String(); got != "vimcoplit dev (commit unknown, built unknown, , )" {
+		t.Errorf("unexpected string for empty info: %q", got)
+	}
+}
@@ -0,0 +1,197 @@
+// Package chaos 为集成测试提供模型和工具调用的故障注入：按比例返回错误、限流、
+// 截断的流式输出并增加延迟，用于检验重试、备用和插件的错误处理逻辑。
+// 只有以 chaos 构建标签编译时才会从环境变量 VIMCOPLIT_CHAOS 读取配置，正式构建中不会生效
+package chaos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EnvVar 是故障注入配置的环境变量，格式为逗号分隔的 key=value，如
+// error_rate=0.2,rate_limit_rate=0.1,truncate_rate=0.1,latency=200ms,jitter=100ms,targets=model+tool,seed=42
+const EnvVar = "VIMCOPLIT_CHAOS"
+
+// ErrInjected 是注入的调用失败
+var ErrInjected = errors.New("chaos: injected failure")
+
+// ErrTruncated 表示注入的流式输出在中途断开，调用方已收到部分输出
+var ErrTruncated = errors.New("chaos: stream truncated")
+
+// Target 是注入故障的调用类型
+type Target string
+
+const (
+	TargetModel Target = "model"
+	TargetTool  Target = "tool"
+)
+
+// Fault 是一次调用被注入的故障
+type Fault int
+
+const (
+	FaultNone      Fault = iota
+	FaultError           // 调用失败
+	FaultRateLimit       // 被提供商限流，只对模型调用注入
+	FaultTruncate        // 输出在中途断开
+)
+
+// Config 是故障注入配置，各比例在 0 到 1 之间，依次判定，总和不应超过 1
+type Config struct {
+	ErrorRate     float64
+	RateLimitRate float64
+	TruncateRate  float64
+	Latency       time.Duration // 每次调用前增加的延迟
+	Jitter        time.Duration // 在 Latency 之上随机增加的最大延迟
+	Targets       []Target      // 为空时对所有调用注入
+	Seed          int64         // 不为 0 时故障序列可以复现
+}
+
+// Parse 解析 EnvVar 格式的配置
+func Parse(spec string) (Config, error) {
+	var cfg Config
+	for _, field := range strings.Split(spec, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			return Config{}, fmt.Errorf("invalid chaos setting %q", field)
+		}
+		var err error
+		switch key {
+		case "error_rate":
+			cfg.ErrorRate, err = parseRate(value)
+		case "rate_limit_rate":
+			cfg.RateLimitRate, err = parseRate(value)
+		case "truncate_rate":
+			cfg.TruncateRate, err = parseRate(value)
+		case "latency":
+			cfg.Latency, err = time.ParseDuration(value)
+		case "jitter":
+			cfg.Jitter, err = time.ParseDuration(value)
+		case "seed":
+			cfg.Seed, err = strconv.ParseInt(value, 10, 64)
+		case "targets":
+			for _, t := range strings.Split(value, "+") {
+				switch Target(t) {
+				case TargetModel, TargetTool:
+					cfg.Targets = append(cfg.Targets, Target(t))
+				default:
+					err = fmt.Errorf("unknown target %q", t)
+				}
+			}
+		default:
+			err = errors.New("unknown setting")
+		}
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid chaos setting %q: %v", key, err)
+		}
+	}
+	if cfg.ErrorRate+cfg.RateLimitRate+cfg.TruncateRate > 1 {
+		return Config{}, errors.New("chaos rates add up to more than 1")
+	}
+	return cfg, nil
+}
+
+// parseRate 解析 0 到 1 之间的比例
+func parseRate(value string) (float64, error) {
+	rate, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, err
+	}
+	if rate < 0 || rate > 1 {
+		return 0, errors.New("rate must be between 0 and 1")
+	}
+	return rate, nil
+}
+
+// Injector 按配置决定每次调用注入的故障，nil 表示不注入，所有方法都可以在 nil 上调用
+type Injector struct {
+	cfg  Config
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+// New 创建故障注入器
+func New(cfg Config) *Injector {
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &Injector{cfg: cfg, rand: rand.New(rand.NewSource(seed))}
+}
+
+var (
+	defaultInjector *Injector
+	defaultOnce     sync.Once
+)
+
+// Default 返回由环境变量配置的故障注入器，未以 chaos 构建标签编译或未设置环境变量时返回 nil
+func Default() *Injector {
+	defaultOnce.Do(func() {
+		defaultInjector = fromEnv()
+	})
+	return defaultInjector
+}
+
+// Inject 在调用前增加延迟并决定本次调用的故障，ctx 在延迟期间被取消时返回 ctx 的错误
+func (i *Injector) Inject(ctx context.Context, target Target) (Fault, error) {
+	if i == nil || !i.targets(target) {
+		return FaultNone, nil
+	}
+
+	i.mu.Lock()
+	delay := i.cfg.Latency
+	if i.cfg.Jitter > 0 {
+		delay += time.Duration(i.rand.Int63n(int64(i.cfg.Jitter)))
+	}
+	roll := i.rand.Float64()
+	i.mu.Unlock()
+
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return FaultNone, ctx.Err()
+		}
+	}
+
+	switch {
+	case roll < i.cfg.ErrorRate:
+		return FaultError, nil
+	case roll < i.cfg.ErrorRate+i.cfg.RateLimitRate && target == TargetModel:
+		return FaultRateLimit, nil
+	case roll < i.cfg.ErrorRate+i.cfg.RateLimitRate+i.cfg.TruncateRate:
+		return FaultTruncate, nil
+	}
+	return FaultNone, nil
+}
+
+// targets 判断是否对该类调用注入故障
+func (i *Injector) targets(target Target) bool {
+	if len(i.cfg.Targets) == 0 {
+		return true
+	}
+	for _, t := range i.cfg.Targets {
+		if t == target {
+			return true
+		}
+	}
+	return false
+}
+
+// Truncate 返回输出被截断后的部分，保留前一半的字符
+func Truncate(output string) string {
+	runes := []rune(output)
+	return string(runes[:len(runes)/2])
+}
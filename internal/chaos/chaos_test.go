@@ -0,0 +1,94 @@
+package chaos
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	cfg, err := Parse("error_rate=0.2, rate_limit_rate=0.1,truncate_rate=0.3,latency=200ms,jitter=50ms,targets=model+tool,seed=42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ErrorRate != 0.2 || cfg.RateLimitRate != 0.1 || cfg.TruncateRate != 0.3 {
+		t.Errorf("unexpected rates: %+v", cfg)
+	}
+	if cfg.Latency != 200*time.Millisecond || cfg.Jitter != 50*time.Millisecond || cfg.Seed != 42 || len(cfg.Targets) != 2 {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+
+	for _, spec := range []string{
+		"error_rate",
+		"error_rate=2",
+		"latency=soon",
+		"targets=model+disk",
+		"bogus=1",
+		"error_rate=0.6,truncate_rate=0.6",
+	} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("expected error for %q", spec)
+		}
+	}
+}
+
+func TestInject(t *testing.T) {
+	ctx := context.Background()
+	var none *Injector
+	if fault, err := none.Inject(ctx, TargetModel); fault != FaultNone || err != nil {
+		t.Errorf("nil injector should not inject, got %v %v", fault, err)
+	}
+
+	always := New(Config{ErrorRate: 1})
+	if fault, _ := always.Inject(ctx, TargetTool); fault != FaultError {
+		t.Errorf("expected error fault, got %v", fault)
+	}
+
+	// 限流只对模型调用注入，工具调用顺延为截断
+	limited := New(Config{RateLimitRate: 0.5, TruncateRate: 0.5, Seed: 1})
+	for i := 0; i < 20; i++ {
+		if fault, _ := limited.Inject(ctx, TargetTool); fault == FaultRateLimit {
+			t.Fatal("tool calls should not be rate limited")
+		}
+	}
+
+	modelOnly := New(Config{ErrorRate: 1, Targets: []Target{TargetModel}})
+	if fault, _ := modelOnly.Inject(ctx, TargetTool); fault != FaultNone {
+		t.Errorf("expected tool calls to be untouched, got %v", fault)
+	}
+
+	// 相同种子的故障序列相同
+	a, b := New(Config{ErrorRate: 0.3, TruncateRate: 0.3, Seed: 7}), New(Config{ErrorRate: 0.3, TruncateRate: 0.3, Seed: 7})
+	for i := 0; i < 50; i++ {
+		fa, _ := a.Inject(ctx, TargetModel)
+		fb, _ := b.Inject(ctx, TargetModel)
+		if fa != fb {
+			t.Fatalf("fault sequences diverged at %d", i)
+		}
+	}
+}
+
+func TestInjectLatency(t *testing.T) {
+	slow := New(Config{Latency: time.Hour})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := slow.Inject(ctx, TargetModel); err != context.DeadlineExceeded {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	if got := Truncate("你好世界"); got != "你好" {
+		t.Errorf("unexpected truncation %q", got)
+	}
+	if got := Truncate(""); got != "" {
+		t.Errorf("unexpected truncation %q", got)
+	}
+}
+
+func TestDefaultWithoutEnv(t *testing.T) {
+	t.Setenv(EnvVar, "")
+	if Default() != nil {
+		t.Error("expected no injector without configuration")
+	}
+}
@@ -0,0 +1,23 @@
+//go:build chaos
+
+package chaos
+
+import (
+	"log"
+	"os"
+)
+
+// fromEnv 从环境变量读取故障注入配置，配置无效时不注入
+func fromEnv() *Injector {
+	spec := os.Getenv(EnvVar)
+	if spec == "" {
+		return nil
+	}
+	cfg, err := Parse(spec)
+	if err != nil {
+		log.Printf("故障注入配置无效，不注入故障: %v\n", err)
+		return nil
+	}
+	log.Printf("已启用故障注入: %s\n", spec)
+	return New(cfg)
+}
@@ -0,0 +1,8 @@
+//go:build !chaos
+
+package chaos
+
+// fromEnv 在正式构建中不读取环境变量，始终不注入故障
+func fromEnv() *Injector {
+	return nil
+}
@@ -5,25 +5,40 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"sync"
+	"strings"
+	"time"
 
 	"github.com/liangsj/vimcoplit/internal/models"
+	"github.com/liangsj/vimcoplit/internal/units"
 )
 
-// Config 定义了应用程序的配置结构
+// Config 定义了应用程序的配置结构。时长字段使用 "30s"、"5m" 等字符串，
+// 大小字段使用 "512KB"、"10MB" 等字符串，为兼容旧的配置文件，数字分别按秒和字节解析
 type Config struct {
 	// 服务器配置
 	Server struct {
-		Host string `json:"host"`
-		Port int    `json:"port"`
+		Host           string   `json:"host"`
+		Port           int      `json:"port"`
+		AllowedHosts   []string `json:"allowed_hosts"`
+		AllowedSubnets []string `json:"allowed_subnets"`
+		Token          string   `json:"token"` // 所有者令牌，为空时只接受本机的请求（观察者令牌除外）
 	} `json:"server"`
 
 	// AI模型配置
 	Model struct {
-		Type        models.ModelType `json:"type"`
-		APIKey      string           `json:"api_key"`
-		MaxTokens   int              `json:"max_tokens"`
-		Temperature float64          `json:"temperature"`
+		Type             models.ModelType                  `json:"type"`
+		APIKey           string                            `json:"api_key"`
+		MaxTokens        int                               `json:"max_tokens"`
+		Temperature      float64                           `json:"temperature"`
+		TopP             float64                           `json:"top_p"`               // 0 表示使用提供商的默认值
+		Stop             []string                          `json:"stop"`                // 停止序列
+		Profiles         map[models.ModelType]ModelProfile `json:"profiles"`            // 按模型类型覆盖默认生成参数
+		WarmUp           bool                              `json:"warm_up"`             // 启动时执行一次自检
+		MaxRateLimitWait units.Duration                    `json:"max_rate_limit_wait"` // 被限流时自动等待的最长时间，超过后返回 429
+		APIKeys          []string                          `json:"api_keys"`            // 额外的 API Key，与 APIKey 一起轮换使用
+		KeyRotation      string                            `json:"key_rotation"`        // round_robin 或 failover
+		APIKeyFile       string                            `json:"api_key_file"`        // 保存 API Key 的文件，APIKey 为空时从该文件读取
+		BaseURL          string                            `json:"base_url"`            // 提供商 API 的根地址，用于代理或兼容网关，为空时使用官方地址
 	} `json:"model"`
 
 	// 日志配置
@@ -37,41 +52,294 @@ type Config struct {
 
 	// 文件操作配置
 	File struct {
-		MaxFileSize int64    `json:"max_file_size"`
-		AllowedExts []string `json:"allowed_exts"`
+		MaxFileSize units.Size `json:"max_file_size"`
+		AllowedExts []string   `json:"allowed_exts"`
 	} `json:"file"`
 
 	// 命令执行配置
 	Command struct {
-		Timeout     int      `json:"timeout"`
-		AllowedCmds []string `json:"allowed_cmds"`
+		Timeout     units.Duration `json:"timeout"`
+		AllowedCmds []string       `json:"allowed_cmds"`
 	} `json:"command"`
-}
 
-var (
-	config *Config
-	once   sync.Once
-)
+	// 沙箱配置
+	Sandbox struct {
+		Backend   string `json:"backend"`
+		Image     string `json:"image"`
+		Workspace string `json:"workspace"`
+		Network   string `json:"network"`
+	} `json:"sandbox"`
+
+	// Shell 配置，Path 为空时自动检测用户的 shell
+	Shell struct {
+		Path   string `json:"path"`
+		Login  bool   `json:"login"`
+		RCFile string `json:"rc_file"`
+	} `json:"shell"`
+
+	// 进程资源监控配置，上限为 0 表示不限制
+	Monitor struct {
+		Interval      units.Duration `json:"interval"`
+		MaxCPUPercent float64        `json:"max_cpu_percent"`
+		MaxMemory     units.Size     `json:"max_memory"`
+		MaxChildren   int            `json:"max_children"`
+	} `json:"monitor"`
+
+	// Agent 运行配置，单次运行的默认上限，0 表示不限制
+	Agent struct {
+		MaxTokens        int            `json:"max_tokens"`
+		MaxToolCalls     int            `json:"max_tool_calls"`
+		MaxDuration      units.Duration `json:"max_duration"`
+		MaxFilesModified int            `json:"max_files_modified"`
+	} `json:"agent"`
+
+	// 输出过滤配置，Mode 为 block、flag 或 off
+	Filter struct {
+		Mode     string   `json:"mode"`
+		Patterns []string `json:"patterns"`
+		Disabled []string `json:"disabled"`
+	} `json:"filter"`
+
+	// 语法检查配置，写入文件前检查语法，Mode 为 block、flag 或 off
+	Syntax struct {
+		Mode string `json:"mode"`
+	} `json:"syntax"`
+
+	// 密钥扫描配置，发送给模型前会脱敏匹配的内容，Allowlist 用于排除误报
+	Secrets struct {
+		Patterns  []string `json:"patterns"`
+		Allowlist []string `json:"allowlist"`
+	} `json:"secrets"`
+
+	// 使用统计配置，统计只保存在本地，Export 显式开启后才会导出到 ExportURL
+	Analytics struct {
+		Enabled   bool   `json:"enabled"`
+		Export    bool   `json:"export"`
+		ExportURL string `json:"export_url"`
+	} `json:"analytics"`
+
+	// 离线模式配置，Enabled 显式开启离线模式，AutoDetect 时定期探测 ProbeAddress 判断是否联网
+	Offline struct {
+		Enabled       bool           `json:"enabled"`
+		AutoDetect    bool           `json:"auto_detect"`
+		ProbeAddress  string         `json:"probe_address"`
+		ProbeInterval units.Duration `json:"probe_interval"`
+	} `json:"offline"`
+
+	// 行内补全配置，CacheSize 为缓存的补全条数，CacheTTL 为缓存有效期
+	Completion struct {
+		CacheSize int            `json:"cache_size"`
+		CacheTTL  units.Duration `json:"cache_ttl"`
+	} `json:"completion"`
+
+	// 自动上下文配置，RelatedFiles 为自动加入补全和对话提示词的相关文件数，0 表示关闭，
+	// 每个文件最多保留 MaxFileBytes 字节
+	AutoContext struct {
+		RelatedFiles int        `json:"related_files"`
+		MaxFileBytes units.Size `json:"max_file_bytes"`
+	} `json:"auto_context"`
+
+	// 资源上限，避免长期运行的守护进程常驻内存无限增长，0 表示不限制。
+	// MaxSessions 为同时进行的生成会话数，MaxContextBytes 为内存中上下文条目内容的总字节数，
+	// MaxCachedTranscripts 为内存中缓存的对话数，超出后两项时最久未使用的部分只保留在磁盘上
+	Limits struct {
+		MaxSessions          int        `json:"max_sessions"`
+		MaxContextBytes      units.Size `json:"max_context_bytes"`
+		MaxCachedTranscripts int        `json:"max_cached_transcripts"`
+		MaxBatchPrompts      int        `json:"max_batch_prompts"`
+		BatchConcurrency     int        `json:"batch_concurrency"`
+	} `json:"limits"`
+
+	// 调试配置，Port 大于 0 且设置了 Token 时在单独的端口上提供 pprof、运行时指标和状态快照，
+	// 请求需带 Authorization: Bearer <Token>
+	Debug struct {
+		Host  string `json:"host"`
+		Port  int    `json:"port"`
+		Token string `json:"token"`
+	} `json:"debug"`
+
+	// 代码检索索引配置，VectorQuantization 为向量的存储精度：float32、float16 或 int8，
+	// 精度越低索引占用的磁盘和内存越小，检索排序的误差越大
+	Index struct {
+		VectorQuantization string `json:"vector_quantization"`
+	} `json:"index"`
+
+	// 仓库地图配置，启用时将压缩的仓库概览加入 Agent 和对话提示词，MaxTokens 为地图的 token 预算
+	RepoMap struct {
+		Enabled   bool `json:"enabled"`
+		MaxTokens int  `json:"max_tokens"`
+	} `json:"repo_map"`
+
+	// 自动标题配置，启用时根据对话的第一轮消息和 agent 运行的目标生成简短标题，
+	// Model 为生成标题使用的模型，为空时使用当前模型，可以指定更便宜的模型
+	Titles struct {
+		Enabled bool   `json:"enabled"`
+		Model   string `json:"model"`
+	} `json:"titles"`
+
+	// 保留策略，最后更新超过 ArchiveAfterDays 天的对话和已结束的任务移入归档，
+	// 最后更新超过 PurgeAfterDays 天的归档被删除，0 表示不归档或不删除
+	Retention struct {
+		ArchiveAfterDays int `json:"archive_after_days"`
+		PurgeAfterDays   int `json:"purge_after_days"`
+	} `json:"retention"`
+
+	// 附件存储，保存运行中产生的图片、日志和下载的文件，MaxBytes 为总大小配额，
+	// MaxFileBytes 为单个附件的大小上限，0 表示不限制
+	Attachments struct {
+		MaxBytes     units.Size `json:"max_bytes"`
+		MaxFileBytes units.Size `json:"max_file_bytes"`
+	} `json:"attachments"`
+
+	// 网页缓存，@url 引用和 fetch_url 工具获取的网页保存在数据目录的 webcache/ 中，
+	// 响应没有给出有效期时 MaxAge 内视为新鲜，过期后 StaleWhileRevalidate 内先返回缓存再在后台更新
+	WebCache struct {
+		Enabled              bool           `json:"enabled"`
+		MaxAge               units.Duration `json:"max_age"`
+		StaleWhileRevalidate units.Duration `json:"stale_while_revalidate"`
+		MaxBytes             units.Size     `json:"max_bytes"`
+	} `json:"web_cache"`
+
+	// http_request 工具配置，只允许访问 AllowedDomains 中的域名（*.example.com 匹配子域名，* 允许任意域名），
+	// 为空时拒绝所有请求；请求体超过 MaxRequestBytes 时拒绝，响应体超过 MaxResponseBytes 时截断
+	HTTPRequest struct {
+		AllowedDomains   []string       `json:"allowed_domains"`
+		MaxRequestBytes  units.Size     `json:"max_request_bytes"`
+		MaxResponseBytes units.Size     `json:"max_response_bytes"`
+		Timeout          units.Duration `json:"timeout"`
+	} `json:"http_request"`
+
+	// 网页搜索配置，启用后 agent 可以使用 web_search 工具，question 类型的上下文解析为搜索结果。
+	// Backend 为 searxng、bing 或 brave，URL 为 SearxNG 实例地址（Bing 和 Brave 为空时使用官方 API），
+	// DailyBudget 为每天最多的查询次数，0 表示不限制
+	WebSearch struct {
+		Enabled       bool           `json:"enabled"`
+		Backend       string         `json:"backend"`
+		URL           string         `json:"url"`
+		APIKey        string         `json:"api_key"`
+		MaxResults    int            `json:"max_results"`
+		DailyBudget   int            `json:"daily_budget"`
+		SnippetLength int            `json:"snippet_length"`
+		Timeout       units.Duration `json:"timeout"`
+	} `json:"web_search"`
+
+	// 集群和容器检查工具配置，Kubectl 和 Docker 分别启用 kubectl 和 docker 工具，默认都不启用。
+	// 工具只能执行 kubectl get/describe/logs 和 docker ps/logs/inspect，KubeContexts 为允许切换到的 context，
+	// 为空时只使用当前 context；AllowSecrets 为 false 时不能获取 Secret，docker inspect 隐藏环境变量的值
+	OpsTools struct {
+		Kubectl        bool           `json:"kubectl"`
+		Docker         bool           `json:"docker"`
+		KubeContexts   []string       `json:"kube_contexts"`
+		AllowSecrets   bool           `json:"allow_secrets"`
+		Timeout        units.Duration `json:"timeout"`
+		MaxOutputBytes units.Size     `json:"max_output_bytes"`
+	} `json:"ops_tools"`
+
+	// 代码托管平台集成，Provider 为 github 或 gitlab，为空时不启用。Token 为空时读取 GITHUB_TOKEN 或 GITLAB_TOKEN
+	// 环境变量；Repo 为空时从工作区 git 仓库的 Remote 地址推断；URL 为 GitHub Enterprise 或自托管 GitLab 的 API 地址
+	Forge struct {
+		Provider string         `json:"provider"`
+		URL      string         `json:"url"`
+		Token    string         `json:"token"`
+		Repo     string         `json:"repo"`
+		Remote   string         `json:"remote"`
+		Timeout  units.Duration `json:"timeout"`
+	} `json:"forge"`
+
+	// A/B 实验配置，为行内补全按权重随机分配提示词模板或模型，Variants 为空时不进行实验
+	Experiment struct {
+		Name     string `json:"name"`
+		Variants []struct {
+			Name     string  `json:"name"`
+			Template string  `json:"template"` // text/template 格式，可使用 .Path .Language .Prefix .Suffix
+			Model    string  `json:"model"`
+			Weight   float64 `json:"weight"`
+		} `json:"variants"`
+	} `json:"experiment"`
+
+	// 存储配置，DataDir 为空时使用 ~/.vimcoplit/data
+	Storage struct {
+		DataDir string `json:"data_dir"`
+	} `json:"storage"`
+
+	// 审计日志配置，启用时将文件写入、命令执行和工具调用追加到带哈希链的只追加日志，
+	// Path 为空时使用数据目录下的 audit.jsonl
+	Audit struct {
+		Enabled bool   `json:"enabled"`
+		Path    string `json:"path"`
+	} `json:"audit"`
+
+	// 自更新配置，Channel 为 stable 或 beta，发布清单位于 URL/<channel>.json，
+	// PublicKey 为 base64 编码的 ed25519 公钥，设置后要求发布文件带有有效签名
+	Update struct {
+		Channel   string `json:"channel"`
+		URL       string `json:"url"`
+		PublicKey string `json:"public_key"`
+	} `json:"update"`
+
+	// 工作区配置，Root 为空时使用启动时的工作目录
+	Workspace struct {
+		Root string `json:"root"`
+	} `json:"workspace"`
+
+	// 界面语言，支持 zh-CN 和 en-US，用于 API 错误信息和命令行输出
+	Locale string `json:"locale"`
+
+	// 调用监视配置，ModelTimeout 为单次模型调用的期限，0 表示只使用请求本身的期限；
+	// 模型或工具调用的期限过后 Grace 内仍未返回时被强制终止
+	Watchdog struct {
+		ModelTimeout units.Duration `json:"model_timeout"`
+		Grace        units.Duration `json:"grace"`
+	} `json:"watchdog"`
+
+	// 生成内容使用的语言，如 English、Chinese，为空时跟随 Locale。
+	// 例如用中文聊天时仍然让生成的代码注释和提交信息使用英文
+	Generation struct {
+		CommentLanguage string `json:"comment_language"` // 补全、/fix、/test 和 agent 写入的代码中注释的语言
+		CommitLanguage  string `json:"commit_language"`  // /commit 生成的提交信息的语言
+	} `json:"generation"`
+
+	// path 是配置文件所在路径，不参与序列化
+	path string
+	// exists 表示加载时是否存在任何一层配置文件
+	exists bool
+	// origins 记录被覆盖的配置项的来源，键为以 . 连接的 JSON 字段名
+	origins map[string]string
+}
 
 // DefaultConfig 返回默认配置
 func DefaultConfig() *Config {
 	return &Config{
 		Server: struct {
-			Host string `json:"host"`
-			Port int    `json:"port"`
+			Host           string   `json:"host"`
+			Port           int      `json:"port"`
+			AllowedHosts   []string `json:"allowed_hosts"`
+			AllowedSubnets []string `json:"allowed_subnets"`
+			Token          string   `json:"token"`
 		}{
 			Host: "localhost",
 			Port: 8080,
 		},
 		Model: struct {
-			Type        models.ModelType `json:"type"`
-			APIKey      string           `json:"api_key"`
-			MaxTokens   int              `json:"max_tokens"`
-			Temperature float64          `json:"temperature"`
+			Type             models.ModelType                  `json:"type"`
+			APIKey           string                            `json:"api_key"`
+			MaxTokens        int                               `json:"max_tokens"`
+			Temperature      float64                           `json:"temperature"`
+			TopP             float64                           `json:"top_p"`
+			Stop             []string                          `json:"stop"`
+			Profiles         map[models.ModelType]ModelProfile `json:"profiles"`
+			WarmUp           bool                              `json:"warm_up"`
+			MaxRateLimitWait units.Duration                    `json:"max_rate_limit_wait"`
+			APIKeys          []string                          `json:"api_keys"`
+			KeyRotation      string                            `json:"key_rotation"`
+			APIKeyFile       string                            `json:"api_key_file"`
+			BaseURL          string                            `json:"base_url"`
 		}{
-			Type:        models.ModelTypeClaude,
-			MaxTokens:   4096,
-			Temperature: 0.7,
+			Type:             models.ModelTypeClaude,
+			MaxTokens:        4096,
+			Temperature:      0.7,
+			MaxRateLimitWait: units.Duration(30 * time.Second),
+			KeyRotation:      "round_robin",
 		},
 		Log: struct {
 			Level      string `json:"level"`
@@ -87,62 +355,367 @@ func DefaultConfig() *Config {
 			MaxAge:     7,
 		},
 		File: struct {
-			MaxFileSize int64    `json:"max_file_size"`
-			AllowedExts []string `json:"allowed_exts"`
+			MaxFileSize units.Size `json:"max_file_size"`
+			AllowedExts []string   `json:"allowed_exts"`
 		}{
-			MaxFileSize: 10 * 1024 * 1024, // 10MB
+			MaxFileSize: 10 * units.MB,
 			AllowedExts: []string{".go", ".lua", ".md", ".txt"},
 		},
 		Command: struct {
-			Timeout     int      `json:"timeout"`
-			AllowedCmds []string `json:"allowed_cmds"`
+			Timeout     units.Duration `json:"timeout"`
+			AllowedCmds []string       `json:"allowed_cmds"`
 		}{
-			Timeout:     30,
+			Timeout:     units.Duration(30 * time.Second),
 			AllowedCmds: []string{"git", "go", "nvim"},
 		},
+		Sandbox: struct {
+			Backend   string `json:"backend"`
+			Image     string `json:"image"`
+			Workspace string `json:"workspace"`
+			Network   string `json:"network"`
+		}{
+			Backend: "host",
+			Image:   "golang:1.24",
+			Network: "none",
+		},
+		Monitor: struct {
+			Interval      units.Duration `json:"interval"`
+			MaxCPUPercent float64        `json:"max_cpu_percent"`
+			MaxMemory     units.Size     `json:"max_memory"`
+			MaxChildren   int            `json:"max_children"`
+		}{
+			Interval: units.Duration(5 * time.Second),
+		},
+		Agent: struct {
+			MaxTokens        int            `json:"max_tokens"`
+			MaxToolCalls     int            `json:"max_tool_calls"`
+			MaxDuration      units.Duration `json:"max_duration"`
+			MaxFilesModified int            `json:"max_files_modified"`
+		}{
+			MaxTokens:        200000,
+			MaxToolCalls:     50,
+			MaxDuration:      units.Duration(30 * time.Minute),
+			MaxFilesModified: 20,
+		},
+		Filter: struct {
+			Mode     string   `json:"mode"`
+			Patterns []string `json:"patterns"`
+			Disabled []string `json:"disabled"`
+		}{
+			Mode: "block",
+		},
+		Syntax: struct {
+			Mode string `json:"mode"`
+		}{
+			Mode: "block",
+		},
+		Analytics: struct {
+			Enabled   bool   `json:"enabled"`
+			Export    bool   `json:"export"`
+			ExportURL string `json:"export_url"`
+		}{
+			Enabled: true,
+		},
+		Offline: struct {
+			Enabled       bool           `json:"enabled"`
+			AutoDetect    bool           `json:"auto_detect"`
+			ProbeAddress  string         `json:"probe_address"`
+			ProbeInterval units.Duration `json:"probe_interval"`
+		}{
+			AutoDetect:    true,
+			ProbeAddress:  "api.anthropic.com:443",
+			ProbeInterval: units.Duration(30 * time.Second),
+		},
+		Completion: struct {
+			CacheSize int            `json:"cache_size"`
+			CacheTTL  units.Duration `json:"cache_ttl"`
+		}{
+			CacheSize: 256,
+			CacheTTL:  units.Duration(5 * time.Minute),
+		},
+		AutoContext: struct {
+			RelatedFiles int        `json:"related_files"`
+			MaxFileBytes units.Size `json:"max_file_bytes"`
+		}{
+			RelatedFiles: 3,
+			MaxFileBytes: 4000,
+		},
+		Limits: struct {
+			MaxSessions          int        `json:"max_sessions"`
+			MaxContextBytes      units.Size `json:"max_context_bytes"`
+			MaxCachedTranscripts int        `json:"max_cached_transcripts"`
+			MaxBatchPrompts      int        `json:"max_batch_prompts"`
+			BatchConcurrency     int        `json:"batch_concurrency"`
+		}{
+			MaxSessions:          8,
+			MaxContextBytes:      64 * units.MB,
+			MaxCachedTranscripts: 32,
+			MaxBatchPrompts:      32,
+			BatchConcurrency:     4,
+		},
+		Debug: struct {
+			Host  string `json:"host"`
+			Port  int    `json:"port"`
+			Token string `json:"token"`
+		}{
+			Host: "localhost",
+		},
+		Index: struct {
+			VectorQuantization string `json:"vector_quantization"`
+		}{
+			VectorQuantization: "float32",
+		},
+		RepoMap: struct {
+			Enabled   bool `json:"enabled"`
+			MaxTokens int  `json:"max_tokens"`
+		}{
+			Enabled:   true,
+			MaxTokens: 2000,
+		},
+		Titles: struct {
+			Enabled bool   `json:"enabled"`
+			Model   string `json:"model"`
+		}{
+			Enabled: true,
+		},
+		Retention: struct {
+			ArchiveAfterDays int `json:"archive_after_days"`
+			PurgeAfterDays   int `json:"purge_after_days"`
+		}{
+			ArchiveAfterDays: 30,
+		},
+		Attachments: struct {
+			MaxBytes     units.Size `json:"max_bytes"`
+			MaxFileBytes units.Size `json:"max_file_bytes"`
+		}{
+			MaxBytes:     units.GB,
+			MaxFileBytes: 100 * units.MB,
+		},
+		WebCache: struct {
+			Enabled              bool           `json:"enabled"`
+			MaxAge               units.Duration `json:"max_age"`
+			StaleWhileRevalidate units.Duration `json:"stale_while_revalidate"`
+			MaxBytes             units.Size     `json:"max_bytes"`
+		}{
+			Enabled:              true,
+			MaxAge:               units.Duration(time.Hour),
+			StaleWhileRevalidate: units.Duration(24 * time.Hour),
+			MaxBytes:             256 * units.MB,
+		},
+		HTTPRequest: struct {
+			AllowedDomains   []string       `json:"allowed_domains"`
+			MaxRequestBytes  units.Size     `json:"max_request_bytes"`
+			MaxResponseBytes units.Size     `json:"max_response_bytes"`
+			Timeout          units.Duration `json:"timeout"`
+		}{
+			AllowedDomains:   []string{},
+			MaxRequestBytes:  units.MB,
+			MaxResponseBytes: units.MB,
+			Timeout:          units.Duration(30 * time.Second),
+		},
+		WebSearch: struct {
+			Enabled       bool           `json:"enabled"`
+			Backend       string         `json:"backend"`
+			URL           string         `json:"url"`
+			APIKey        string         `json:"api_key"`
+			MaxResults    int            `json:"max_results"`
+			DailyBudget   int            `json:"daily_budget"`
+			SnippetLength int            `json:"snippet_length"`
+			Timeout       units.Duration `json:"timeout"`
+		}{
+			Backend:       "searxng",
+			MaxResults:    5,
+			DailyBudget:   100,
+			SnippetLength: 300,
+			Timeout:       units.Duration(10 * time.Second),
+		},
+		OpsTools: struct {
+			Kubectl        bool           `json:"kubectl"`
+			Docker         bool           `json:"docker"`
+			KubeContexts   []string       `json:"kube_contexts"`
+			AllowSecrets   bool           `json:"allow_secrets"`
+			Timeout        units.Duration `json:"timeout"`
+			MaxOutputBytes units.Size     `json:"max_output_bytes"`
+		}{
+			KubeContexts:   []string{},
+			Timeout:        units.Duration(30 * time.Second),
+			MaxOutputBytes: 256 * units.KB,
+		},
+		Forge: struct {
+			Provider string         `json:"provider"`
+			URL      string         `json:"url"`
+			Token    string         `json:"token"`
+			Repo     string         `json:"repo"`
+			Remote   string         `json:"remote"`
+			Timeout  units.Duration `json:"timeout"`
+		}{
+			Remote:  "origin",
+			Timeout: units.Duration(30 * time.Second),
+		},
+		Update: struct {
+			Channel   string `json:"channel"`
+			URL       string `json:"url"`
+			PublicKey string `json:"public_key"`
+		}{
+			Channel: "stable",
+			URL:     "https://github.com/liangsj/vimcoplit/releases/download/channels",
+		},
+		Watchdog: struct {
+			ModelTimeout units.Duration `json:"model_timeout"`
+			Grace        units.Duration `json:"grace"`
+		}{
+			ModelTimeout: units.Duration(10 * time.Minute),
+			Grace:        units.Duration(10 * time.Second),
+		},
+		Locale: "zh-CN",
+	}
+}
+
+// CommentLanguage 返回生成的代码注释使用的语言
+func (c *Config) CommentLanguage() string {
+	if c.Generation.CommentLanguage != "" {
+		return c.Generation.CommentLanguage
+	}
+	return localeLanguage(c.Locale)
+}
+
+// CommitLanguage 返回生成的提交信息使用的语言
+func (c *Config) CommitLanguage() string {
+	if c.Generation.CommitLanguage != "" {
+		return c.Generation.CommitLanguage
+	}
+	return localeLanguage(c.Locale)
+}
+
+// localeLanguage 返回界面语言对应的自然语言名称，用于提示词
+func localeLanguage(locale string) string {
+	switch {
+	case strings.HasPrefix(locale, "zh"):
+		return "Chinese"
+	case strings.HasPrefix(locale, "en"), locale == "":
+		return "English"
+	}
+	return locale
+}
+
+// Path 返回加载配置时使用的配置文件路径
+func (c *Config) Path() string {
+	if c.path != "" {
+		return c.path
+	}
+	return DefaultPath()
+}
+
+// DefaultPath 返回默认的配置文件路径
+func DefaultPath() string {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "config.json"
+	}
+	return filepath.Join(homeDir, ".vimcoplit", "config.json")
+}
+
+// Exists 判断加载时是否存在任何一层配置文件，都不存在时使用默认配置，需要运行 vimcoplit init 完成配置
+func (c *Config) Exists() bool {
+	return c.exists
+}
+
+// WorkspaceRoot 返回工作区根目录
+func (c *Config) WorkspaceRoot() string {
+	if c.Workspace.Root != "" {
+		return c.Workspace.Root
+	}
+	root, err := os.Getwd()
+	if err != nil {
+		return "."
+	}
+	return root
+}
+
+// DataDir 返回持久化数据目录
+func (c *Config) DataDir() string {
+	if c.Storage.DataDir != "" {
+		return c.Storage.DataDir
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(".vimcoplit", "data")
 	}
+	return filepath.Join(homeDir, ".vimcoplit", "data")
 }
 
-// LoadConfig 从文件加载配置
+// MCPPath 返回 MCP 服务器和工具配置的保存路径，位于数据目录中，
+// 使用不同数据目录的服务各自保存
+func (c *Config) MCPPath() string {
+	return filepath.Join(c.DataDir(), "mcp.json")
+}
+
+// AuditPath 返回审计日志文件路径
+func (c *Config) AuditPath() string {
+	if c.Audit.Path != "" {
+		return c.Audit.Path
+	}
+	return filepath.Join(c.DataDir(), "audit.jsonl")
+}
+
+// LoadConfig 按优先级从低到高叠加默认配置、系统配置 SystemPath、用户配置 configPath
+// （为空时使用 ~/.vimcoplit/config.json）、工作区配置 WorkspacePath 和环境变量，
+// 命令行参数由调用方通过 Set 覆盖。每个配置项的来源可以通过 Origin 和 Values 查询。
+// 每次调用返回新的实例，由调用方传给 core.NewService 等构造函数
 func LoadConfig(configPath string) (*Config, error) {
-	once.Do(func() {
-		config = DefaultConfig()
-	})
+	config := DefaultConfig()
 
 	// 如果配置文件路径为空，使用默认路径
 	if configPath == "" {
-		homeDir, err := os.UserHomeDir()
-		if err != nil {
-			configPath = "config.json"
-		} else {
-			configPath = filepath.Join(homeDir, ".vimcoplit", "config.json")
-		}
+		configPath = DefaultPath()
 	}
+	config.path = configPath
 
-	// 读取配置文件
-	data, err := os.ReadFile(configPath)
-	if err != nil {
-		if os.IsNotExist(err) {
-			// 如果配置文件不存在，创建默认配置文件
-			if err := SaveConfig(configPath, config); err != nil {
-				return nil, fmt.Errorf("failed to create default config: %v", err)
-			}
-			return config, nil
+	// 读取各层配置文件，都不存在时使用默认配置，由 vimcoplit init 创建用户配置文件
+	loaded := make(map[string]bool)
+	// 工作区配置与用户配置是同一个文件时（在主目录下运行）按用户配置加载
+	layers := []string{SystemPath, configPath, WorkspacePath()}
+	for i, path := range layers {
+		if abs, err := filepath.Abs(path); err == nil {
+			path = abs
+		}
+		if loaded[path] {
+			continue
 		}
-		return nil, fmt.Errorf("failed to read config file: %v", err)
+		loaded[path] = true
+		found, err := loadFile(config, path, i == len(layers)-1)
+		if err != nil {
+			return nil, err
+		}
+		config.exists = config.exists || found
 	}
 
-	// 解析配置文件
-	if err := json.Unmarshal(data, config); err != nil {
-		return nil, fmt.Errorf("failed to parse config file: %v", err)
+	// 从 API Key 文件和环境变量加载配置
+	if err := loadAPIKeyFile(config); err != nil {
+		return nil, err
 	}
-
-	// 从环境变量加载配置
 	loadFromEnv(config)
 
+	if err := config.validateModelDefaults(); err != nil {
+		return nil, fmt.Errorf("invalid config: %v", err)
+	}
 	return config, nil
 }
 
+// loadAPIKeyFile 在 APIKey 为空时从 APIKeyFile 读取 API Key
+func loadAPIKeyFile(cfg *Config) error {
+	if cfg.Model.APIKey != "" || cfg.Model.APIKeyFile == "" {
+		return nil
+	}
+	data, err := os.ReadFile(cfg.Model.APIKeyFile)
+	if err != nil {
+		return fmt.Errorf("failed to read API key file: %v", err)
+	}
+	cfg.Model.APIKey = strings.TrimSpace(string(data))
+	cfg.setOrigin("model.api_key", cfg.Model.APIKeyFile)
+	return nil
+}
+
 // SaveConfig 保存配置到文件
 func SaveConfig(configPath string, cfg *Config) error {
 	// 确保配置目录存在
@@ -157,51 +730,10 @@ func SaveConfig(configPath string, cfg *Config) error {
 		return fmt.Errorf("failed to marshal config: %v", err)
 	}
 
-	// 写入文件
-	if err := os.WriteFile(configPath, data, 0644); err != nil {
+	// 写入文件，配置中可能包含 API Key，只允许当前用户读写
+	if err := os.WriteFile(configPath, data, 0600); err != nil {
 		return fmt.Errorf("failed to write config file: %v", err)
 	}
 
 	return nil
 }
-
-// GetConfig 返回当前配置
-func GetConfig() *Config {
-	if config == nil {
-		config = DefaultConfig()
-	}
-	return config
-}
-
-// loadFromEnv 从环境变量加载配置
-func loadFromEnv(cfg *Config) {
-	// 服务器配置
-	if host := os.Getenv("VIMCOPLIT_HOST"); host != "" {
-		cfg.Server.Host = host
-	}
-	if port := os.Getenv("VIMCOPLIT_PORT"); port != "" {
-		fmt.Sscanf(port, "%d", &cfg.Server.Port)
-	}
-
-	// 模型配置
-	if modelType := os.Getenv("VIMCOPLIT_MODEL_TYPE"); modelType != "" {
-		cfg.Model.Type = models.ModelType(modelType)
-	}
-	if apiKey := os.Getenv("VIMCOPLIT_API_KEY"); apiKey != "" {
-		cfg.Model.APIKey = apiKey
-	}
-	if maxTokens := os.Getenv("VIMCOPLIT_MAX_TOKENS"); maxTokens != "" {
-		fmt.Sscanf(maxTokens, "%d", &cfg.Model.MaxTokens)
-	}
-	if temp := os.Getenv("VIMCOPLIT_TEMPERATURE"); temp != "" {
-		fmt.Sscanf(temp, "%f", &cfg.Model.Temperature)
-	}
-
-	// 日志配置
-	if level := os.Getenv("VIMCOPLIT_LOG_LEVEL"); level != "" {
-		cfg.Log.Level = level
-	}
-	if file := os.Getenv("VIMCOPLIT_LOG_FILE"); file != "" {
-		cfg.Log.File = file
-	}
-}
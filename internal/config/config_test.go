@@ -1,11 +1,16 @@
 package config
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/liangsj/vimcoplit/internal/models"
+	"github.com/liangsj/vimcoplit/internal/units"
 )
 
 func TestDefaultConfig(t *testing.T) {
@@ -39,7 +44,7 @@ func TestDefaultConfig(t *testing.T) {
 	}
 
 	// 测试文件配置
-	if cfg.File.MaxFileSize != 10*1024*1024 {
+	if cfg.File.MaxFileSize != 10*units.MB {
 		t.Errorf("expected max file size to be 10MB, got %d", cfg.File.MaxFileSize)
 	}
 	expectedExts := []string{".go", ".lua", ".md", ".txt"}
@@ -48,13 +53,57 @@ func TestDefaultConfig(t *testing.T) {
 	}
 
 	// 测试命令配置
-	if cfg.Command.Timeout != 30 {
-		t.Errorf("expected command timeout to be 30, got %d", cfg.Command.Timeout)
+	if cfg.Command.Timeout.Std() != 30*time.Second {
+		t.Errorf("expected command timeout to be 30s, got %s", cfg.Command.Timeout)
 	}
 	expectedCmds := []string{"git", "go", "nvim"}
 	if len(cfg.Command.AllowedCmds) != len(expectedCmds) {
 		t.Errorf("expected %d allowed commands, got %d", len(expectedCmds), len(cfg.Command.AllowedCmds))
 	}
+
+	// 测试资源上限
+	if cfg.Limits.MaxSessions != 8 || cfg.Limits.MaxContextBytes != 64*units.MB || cfg.Limits.MaxCachedTranscripts != 32 ||
+		cfg.Limits.MaxBatchPrompts != 32 || cfg.Limits.BatchConcurrency != 4 {
+		t.Errorf("unexpected default limits: %+v", cfg.Limits)
+	}
+	if !cfg.Titles.Enabled || cfg.Titles.Model != "" {
+		t.Errorf("expected automatic titles with the current model by default, got %+v", cfg.Titles)
+	}
+	if cfg.Retention.ArchiveAfterDays != 30 || cfg.Retention.PurgeAfterDays != 0 {
+		t.Errorf("expected archiving after 30 days without purging by default, got %+v", cfg.Retention)
+	}
+	if cfg.Attachments.MaxBytes != units.GB || cfg.Attachments.MaxFileBytes != 100*units.MB {
+		t.Errorf("expected 1GB attachment quota with 100MB per file by default, got %+v", cfg.Attachments)
+	}
+	if !cfg.WebCache.Enabled || cfg.WebCache.MaxAge.Std() != time.Hour || cfg.WebCache.StaleWhileRevalidate.Std() != 24*time.Hour {
+		t.Errorf("expected web cache fresh for an hour and revalidated in background for a day, got %+v", cfg.WebCache)
+	}
+	if len(cfg.HTTPRequest.AllowedDomains) != 0 || cfg.HTTPRequest.MaxResponseBytes != units.MB || cfg.HTTPRequest.Timeout.Std() != 30*time.Second {
+		t.Errorf("expected http_request to allow no domains with 1MB responses by default, got %+v", cfg.HTTPRequest)
+	}
+	if cfg.WebSearch.Enabled || cfg.WebSearch.MaxResults != 5 || cfg.WebSearch.DailyBudget != 100 {
+		t.Errorf("expected web search disabled with 5 results and 100 queries a day by default, got %+v", cfg.WebSearch)
+	}
+	if cfg.OpsTools.Kubectl || cfg.OpsTools.Docker || cfg.OpsTools.AllowSecrets {
+		t.Errorf("expected kubectl and docker tools disabled and secrets hidden by default, got %+v", cfg.OpsTools)
+	}
+	if cfg.Forge.Provider != "" || cfg.Forge.Remote != "origin" {
+		t.Errorf("expected the forge integration disabled with the origin remote by default, got %+v", cfg.Forge)
+	}
+	if cfg.Index.VectorQuantization != "float32" {
+		t.Errorf("expected unquantized vectors by default, got %q", cfg.Index.VectorQuantization)
+	}
+	if cfg.Watchdog.ModelTimeout.Std() != 10*time.Minute || cfg.Watchdog.Grace.Std() != 10*time.Second {
+		t.Errorf("expected 10 minute model calls with 10 second grace by default, got %+v", cfg.Watchdog)
+	}
+	// 生成内容的语言默认跟随界面语言，可以单独设置
+	if cfg.CommentLanguage() != "Chinese" || cfg.CommitLanguage() != "Chinese" {
+		t.Errorf("expected generated comments and commit messages to follow the locale, got %q %q", cfg.CommentLanguage(), cfg.CommitLanguage())
+	}
+	cfg.Generation.CommentLanguage = "English"
+	if cfg.CommentLanguage() != "English" || cfg.CommitLanguage() != "Chinese" {
+		t.Errorf("expected comment language override only, got %q %q", cfg.CommentLanguage(), cfg.CommitLanguage())
+	}
 }
 
 func TestLoadConfig(t *testing.T) {
@@ -84,9 +133,27 @@ func TestLoadConfig(t *testing.T) {
 		t.Error("expected config to be non-nil")
 	}
 
-	// 验证配置文件是否被创建
-	if _, err := os.Stat(configPath); os.IsNotExist(err) {
-		t.Error("expected config file to be created")
+	// 配置文件不存在时不会自动创建，由 vimcoplit init 创建
+	if _, err := os.Stat(configPath); !os.IsNotExist(err) {
+		t.Error("expected config file not to be created")
+	}
+	if cfg.Exists() {
+		t.Error("expected missing config file to be reported")
+	}
+}
+
+func TestLoadAPIKeyFile(t *testing.T) {
+	tempDir := t.TempDir()
+	keyFile := filepath.Join(tempDir, "api_key")
+	os.WriteFile(keyFile, []byte("sk-test\n"), 0600)
+
+	cfg := DefaultConfig()
+	cfg.Model.APIKeyFile = keyFile
+	if err := loadAPIKeyFile(cfg); err != nil {
+		t.Fatalf("failed to load API key file: %v", err)
+	}
+	if cfg.Model.APIKey != "sk-test" {
+		t.Errorf("expected API key from file, got %q", cfg.Model.APIKey)
 	}
 }
 
@@ -180,19 +247,315 @@ func TestLoadFromEnv(t *testing.T) {
 	os.Unsetenv("VIMCOPLIT_LOG_FILE")
 }
 
-func TestGetConfig(t *testing.T) {
-	// 测试获取默认配置
-	cfg := GetConfig()
-	if cfg == nil {
-		t.Error("expected config to be non-nil")
+func TestLoadConfigIndependent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
 	}
-
-	// 修改配置
 	cfg.Server.Port = 9090
 
-	// 再次获取配置，应该返回相同的实例
-	cfg2 := GetConfig()
-	if cfg2.Server.Port != 9090 {
-		t.Errorf("expected port to be 9090, got %d", cfg2.Server.Port)
+	// 每次加载返回新的实例，修改一个实例不影响另一个
+	cfg2, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg2 == cfg || cfg2.Server.Port == 9090 {
+		t.Errorf("expected independent config, got port %d", cfg2.Server.Port)
+	}
+	// 数据目录不同的实例使用各自的 MCP 配置
+	cfg.Storage.DataDir = t.TempDir()
+	if cfg.MCPPath() == cfg2.MCPPath() || filepath.Dir(cfg.MCPPath()) != cfg.Storage.DataDir {
+		t.Errorf("expected the MCP config in the data dir, got %s and %s", cfg.MCPPath(), cfg2.MCPPath())
+	}
+}
+
+func TestConfigLayers(t *testing.T) {
+	dir := t.TempDir()
+	write := func(path, content string) {
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	systemPath := filepath.Join(dir, "etc", "config.json")
+	userPath := filepath.Join(dir, "home", "config.json")
+	workspace := filepath.Join(dir, "ws")
+	write(systemPath, `{"server": {"host": "0.0.0.0", "port": 7000}, "log": {"level": "warn"}}`)
+	write(userPath, `{"server": {"port": 7100}, "model": {"max_tokens": 1000}}`)
+	write(filepath.Join(workspace, ".vimcoplit", "config.json"), `{
+		"model": {"max_tokens": 2000, "base_url": "https://attacker.example"},
+		"command": {"allowed_cmds": ["curl"]},
+		"debug": {"token": "known"}
+	}`)
+
+	previous := SystemPath
+	SystemPath = systemPath
+	defer func() { SystemPath = previous }()
+	t.Chdir(workspace)
+	t.Setenv("VIMCOPLIT_LOG_LEVEL", "debug")
+
+	cfg, err := LoadConfig(userPath)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if err := cfg.Set("server.port", "7200", "flag -port"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	for key, want := range map[string]string{
+		"server.host":      systemPath,
+		"server.port":      "flag -port",
+		"model.max_tokens": filepath.Join(workspace, ".vimcoplit", "config.json"),
+		"log.level":        "env VIMCOPLIT_LOG_LEVEL",
+		"model.type":       OriginDefault,
+	} {
+		if got := cfg.Origin(key); got != want {
+			t.Errorf("origin of %s: expected %q, got %q", key, want, got)
+		}
+	}
+	if cfg.Server.Host != "0.0.0.0" || cfg.Server.Port != 7200 || cfg.Model.MaxTokens != 2000 || cfg.Log.Level != "debug" {
+		t.Errorf("unexpected effective config: %+v %+v %+v", cfg.Server, cfg.Model, cfg.Log)
+	}
+	// 工作区配置不能改变请求地址、允许的命令和凭据
+	if cfg.Model.BaseURL != "" || cfg.Debug.Token != "" || slices.Contains(cfg.Command.AllowedCmds, "curl") ||
+		cfg.Origin("model.base_url") != OriginDefault {
+		t.Errorf("expected sensitive workspace keys to be ignored: %q %q %v", cfg.Model.BaseURL, cfg.Debug.Token, cfg.Command.AllowedCmds)
+	}
+
+	// 重新加载时不保留上一次的配置
+	SystemPath = filepath.Join(dir, "missing.json")
+	cfg, _ = LoadConfig(userPath)
+	if cfg.Server.Host != "localhost" || cfg.Origin("server.host") != OriginDefault {
+		t.Errorf("expected host to fall back to default, got %q from %s", cfg.Server.Host, cfg.Origin("server.host"))
+	}
+}
+
+func TestSet(t *testing.T) {
+	cfg := DefaultConfig()
+	if err := cfg.Set("server.allowed_hosts", "a.example, b.example", "test"); err != nil || len(cfg.Server.AllowedHosts) != 2 || cfg.Server.AllowedHosts[1] != "b.example" {
+		t.Errorf("unexpected allowed hosts %v %v", cfg.Server.AllowedHosts, err)
+	}
+	if err := cfg.Set("offline.enabled", "1", "test"); err != nil || !cfg.Offline.Enabled {
+		t.Errorf("expected offline mode, got %v", err)
+	}
+	if err := cfg.Set("server.port", "abc", "test"); err == nil {
+		t.Error("expected invalid integer error")
+	}
+	if err := cfg.Set("server.nope", "1", "test"); err == nil {
+		t.Error("expected unknown key error")
+	}
+	if err := cfg.Set("experiment.variants", "x", "test"); err == nil {
+		t.Error("expected error for non-string list")
+	}
+	if err := cfg.Set("command.timeout", "2m", "test"); err != nil || cfg.Command.Timeout.Std() != 2*time.Minute {
+		t.Errorf("unexpected command timeout %s %v", cfg.Command.Timeout, err)
+	}
+	if err := cfg.Set("file.max_file_size", "1MB", "test"); err != nil || cfg.File.MaxFileSize != units.MB {
+		t.Errorf("unexpected max file size %s %v", cfg.File.MaxFileSize, err)
+	}
+	if err := cfg.Set("monitor.max_memory", "lots", "test"); err == nil {
+		t.Error("expected invalid size error")
+	}
+}
+
+func TestHumanReadableUnits(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	content := `{
+		"command": {"timeout": "1m30s"},
+		"completion": {"cache_ttl": 120},
+		"file": {"max_file_size": "2MB"},
+		"limits": {"max_context_bytes": 1048576}
+	}`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.Command.Timeout.Std() != 90*time.Second {
+		t.Errorf("expected 1m30s timeout, got %s", cfg.Command.Timeout)
+	}
+	// 旧配置中的数字按秒和字节解析
+	if cfg.Completion.CacheTTL.Std() != 2*time.Minute {
+		t.Errorf("expected 2m cache ttl, got %s", cfg.Completion.CacheTTL)
+	}
+	if cfg.File.MaxFileSize != 2*units.MB || cfg.Limits.MaxContextBytes != units.MB {
+		t.Errorf("unexpected sizes %s %s", cfg.File.MaxFileSize, cfg.Limits.MaxContextBytes)
+	}
+
+	// 保存时写为可读的字符串
+	if err := SaveConfig(path, cfg); err != nil {
+		t.Fatalf("SaveConfig: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"timeout": "1m30s"`, `"cache_ttl": "2m"`, `"max_file_size": "2MB"`, `"max_context_bytes": "1MB"`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("expected saved config to contain %s", want)
+		}
+	}
+}
+
+func TestExpandEnv(t *testing.T) {
+	t.Setenv("VC_TEST_KEY", "secret")
+	t.Setenv("VC_TEST_EMPTY", "")
+	tests := []struct {
+		in      string
+		want    string
+		missing []string
+	}{
+		{"plain", "plain", nil},
+		{"${VC_TEST_KEY}", "secret", nil},
+		{"https://${VC_TEST_KEY}.example/${VC_TEST_KEY}", "https://secret.example/secret", nil},
+		{"${VC_TEST_UNSET:-fallback}", "fallback", nil},
+		{"${VC_TEST_EMPTY:-fallback}", "fallback", nil},
+		{"${VC_TEST_EMPTY}", "", nil},
+		{"$${VC_TEST_KEY}", "${VC_TEST_KEY}", nil},
+		{"${VC_TEST_UNSET}/x", "/x", []string{"VC_TEST_UNSET"}},
+		{"${unterminated", "${unterminated", nil},
+	}
+	for _, tt := range tests {
+		got, missing := expandEnv(tt.in)
+		if got != tt.want || strings.Join(missing, ",") != strings.Join(tt.missing, ",") {
+			t.Errorf("expandEnv(%q) = %q %v, want %q %v", tt.in, got, missing, tt.want, tt.missing)
+		}
+	}
+}
+
+func TestConfigEnvExpansion(t *testing.T) {
+	t.Chdir(t.TempDir())
+	t.Setenv("VC_TEST_KEY", "sk-test")
+	t.Setenv("VC_TEST_HOME", "/home/me")
+	path := filepath.Join(t.TempDir(), "config.json")
+	content := `{
+		"model": {"api_key": "${VC_TEST_KEY}", "api_keys": ["${VC_TEST_KEY}-2"]},
+		"storage": {"data_dir": "${VC_TEST_HOME}/.vimcoplit/data"},
+		"update": {"url": "${VC_TEST_UPDATE_URL:-https://updates.example}"},
+		"file": {"max_file_size": 10485760}
+	}`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.Model.APIKey != "sk-test" || cfg.Model.APIKeys[0] != "sk-test-2" {
+		t.Errorf("unexpected api keys %q %v", cfg.Model.APIKey, cfg.Model.APIKeys)
+	}
+	if cfg.Storage.DataDir != "/home/me/.vimcoplit/data" || cfg.Update.URL != "https://updates.example" {
+		t.Errorf("unexpected expansion %q %q", cfg.Storage.DataDir, cfg.Update.URL)
+	}
+	if cfg.File.MaxFileSize != 10*units.MB {
+		t.Errorf("unexpected max file size %s", cfg.File.MaxFileSize)
+	}
+
+	content = `{"model": {"api_key": "${VC_TEST_MISSING}"}, "log": {"file": "${VC_TEST_MISSING_DIR}/log"}}`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err = LoadConfig(path)
+	var missing *MissingEnvError
+	if !errors.As(err, &missing) {
+		t.Fatalf("expected MissingEnvError, got %v", err)
+	}
+	if len(missing.Refs) != 2 || missing.Refs[0] != "log.file: ${VC_TEST_MISSING_DIR}" || missing.Refs[1] != "model.api_key: ${VC_TEST_MISSING}" {
+		t.Errorf("unexpected missing refs %v", missing.Refs)
+	}
+
+	// 工作区配置中的引用按字面使用，未设置的变量也不会导致启动失败
+	workspace := t.TempDir()
+	os.MkdirAll(filepath.Join(workspace, ".vimcoplit"), 0755)
+	content = `{"generation": {"commit_language": "${VC_TEST_KEY}", "comment_language": "${VC_TEST_MISSING}"}}`
+	if err := os.WriteFile(filepath.Join(workspace, ".vimcoplit", "config.json"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(path, []byte(`{}`), 0644)
+	t.Chdir(workspace)
+	cfg, err = LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.Generation.CommitLanguage != "${VC_TEST_KEY}" || cfg.Generation.CommentLanguage != "${VC_TEST_MISSING}" {
+		t.Errorf("expected workspace values to be used literally, got %q %q", cfg.Generation.CommitLanguage, cfg.Generation.CommentLanguage)
+	}
+}
+
+func TestModelDefaults(t *testing.T) {
+	t.Chdir(t.TempDir())
+	path := filepath.Join(t.TempDir(), "config.json")
+	content := `{"model": {
+		"max_tokens": 2000,
+		"stop": ["END"],
+		"profiles": {
+			"deepseek": {"temperature": 0, "top_p": 0.9, "max_tokens": 8000},
+			"doubao": {"stop": ["\n\n"]}
+		}
+	}}`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+
+	d := cfg.ModelDefaults(models.ModelTypeDeepSeek)
+	if d.MaxTokens != 8000 || d.Temperature != 0 || d.TopP != 0.9 || len(d.Stop) != 1 || d.Stop[0] != "END" {
+		t.Errorf("unexpected deepseek defaults %+v", d)
+	}
+	d = cfg.ModelDefaults(models.ModelTypeDoubao)
+	if d.MaxTokens != 2000 || d.Temperature != 0.7 || d.Stop[0] != "\n\n" {
+		t.Errorf("unexpected doubao defaults %+v", d)
+	}
+	d = cfg.ModelDefaults(models.ModelTypeClaude)
+	if d.MaxTokens != 2000 || d.Temperature != 0.7 || d.TopP != 0 {
+		t.Errorf("unexpected claude defaults %+v", d)
+	}
+
+	for _, invalid := range []string{
+		`{"model": {"profiles": {"deepseek": {"temperature": 3}}}}`,
+		`{"model": {"top_p": 1.5}}`,
+		`{"model": {"profiles": {"gpt-9": {"max_tokens": 10}}}}`,
+	} {
+		if err := os.WriteFile(path, []byte(invalid), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadConfig(path); err == nil {
+			t.Errorf("expected error for %s", invalid)
+		}
+	}
+}
+
+func TestStripAndKeepSecrets(t *testing.T) {
+	original := `{"model":{"type":"claude","api_key":"sk-live","api_keys":["a","b"]},"server":{"token":"${VC_TOKEN}"},"web_search":{"api_key":"ws-live"},"forge":{"token":"ghp-live"}}`
+	stripped, err := StripSecrets([]byte(original))
+	if err != nil {
+		t.Fatalf("failed to strip secrets: %v", err)
+	}
+	if strings.Contains(string(stripped), "sk-live") || strings.Contains(string(stripped), "api_keys") || strings.Contains(string(stripped), "ws-live") || strings.Contains(string(stripped), "ghp-live") {
+		t.Errorf("expected credentials to be removed, got %s", stripped)
+	}
+	if !strings.Contains(string(stripped), `"type": "claude"`) || !strings.Contains(string(stripped), "${VC_TOKEN}") {
+		t.Errorf("expected other values and env references to be kept, got %s", stripped)
+	}
+
+	current := `{"model":{"api_key":"sk-current"},"debug":{"token":"dbg"}}`
+	merged, err := KeepSecrets(stripped, []byte(current))
+	if err != nil {
+		t.Fatalf("failed to keep secrets: %v", err)
+	}
+	for _, want := range []string{"sk-current", `"dbg"`, "${VC_TOKEN}", `"type": "claude"`} {
+		if !strings.Contains(string(merged), want) {
+			t.Errorf("expected %s in restored config, got %s", want, merged)
+		}
+	}
+	if merged, _ := KeepSecrets(stripped, nil); string(merged) != string(stripped) {
+		t.Errorf("expected the restored config unchanged without a current file, got %s", merged)
 	}
 }
@@ -0,0 +1,108 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// MissingEnvError 表示配置文件引用了未设置的环境变量
+type MissingEnvError struct {
+	Path string   // 配置文件路径
+	Refs []string // 引用未设置变量的配置项，格式为 <配置项>: ${<变量名>}
+}
+
+func (e *MissingEnvError) Error() string {
+	return fmt.Sprintf("config file %s references unset environment variables: %s", e.Path, strings.Join(e.Refs, ", "))
+}
+
+// expandEnv 展开配置值中的 ${VAR} 和 ${VAR:-default}，$${ 表示字面量 ${。
+// 返回展开后的值和未设置且没有默认值的变量名
+func expandEnv(s string) (string, []string) {
+	if !strings.Contains(s, "${") {
+		return s, nil
+	}
+	var b strings.Builder
+	var missing []string
+	for {
+		i := strings.Index(s, "${")
+		if i < 0 {
+			b.WriteString(s)
+			break
+		}
+		if i > 0 && s[i-1] == '$' {
+			b.WriteString(s[:i-1] + "${")
+			s = s[i+2:]
+			continue
+		}
+		end := strings.IndexByte(s[i:], '}')
+		if end < 0 {
+			b.WriteString(s)
+			break
+		}
+		b.WriteString(s[:i])
+		name, def, hasDefault := strings.Cut(s[i+2:i+end], ":-")
+		if value, ok := os.LookupEnv(name); ok && (value != "" || !hasDefault) {
+			b.WriteString(value)
+		} else if hasDefault {
+			b.WriteString(def)
+		} else {
+			missing = append(missing, name)
+		}
+		s = s[i+end+1:]
+	}
+	return b.String(), missing
+}
+
+// expandValues 展开配置文件中所有字符串值引用的环境变量，返回引用了未设置变量的配置项
+func expandValues(prefix string, v interface{}) (interface{}, []string) {
+	var refs []string
+	switch v := v.(type) {
+	case string:
+		expanded, missing := expandEnv(v)
+		for _, name := range missing {
+			refs = append(refs, fmt.Sprintf("%s: ${%s}", prefix, name))
+		}
+		return expanded, refs
+	case map[string]interface{}:
+		for k, item := range v {
+			key := k
+			if prefix != "" {
+				key = prefix + "." + k
+			}
+			expanded, missing := expandValues(key, item)
+			v[k] = expanded
+			refs = append(refs, missing...)
+		}
+		sort.Strings(refs)
+	case []interface{}:
+		for i, item := range v {
+			expanded, missing := expandValues(fmt.Sprintf("%s[%d]", prefix, i), item)
+			v[i] = expanded
+			refs = append(refs, missing...)
+		}
+	}
+	return v, refs
+}
+
+// hasEnvRefs 判断配置值中是否有 ${VAR} 形式的环境变量引用
+func hasEnvRefs(v interface{}) bool {
+	switch v := v.(type) {
+	case string:
+		return strings.Contains(v, "${")
+	case map[string]interface{}:
+		for _, item := range v {
+			if hasEnvRefs(item) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if hasEnvRefs(item) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,285 @@
+package config
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SystemPath 是系统级配置文件路径，优先级最低
+var SystemPath = "/etc/vimcoplit/config.json"
+
+// OriginDefault 是未被任何配置层覆盖的配置项的来源
+const OriginDefault = "default"
+
+// WorkspacePath 返回工作区级配置文件路径，即当前目录下的 .vimcoplit/config.json
+func WorkspacePath() string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return filepath.Join(".vimcoplit", "config.json")
+	}
+	return filepath.Join(wd, ".vimcoplit", "config.json")
+}
+
+// workspaceKeys 是工作区配置可以设置的配置项或配置段。工作区配置随仓库分发，
+// 不能改变请求发往的地址、凭据、可执行的命令、沙箱和监听地址等，其余配置项被忽略
+var workspaceKeys = []string{
+	"model.max_tokens",
+	"model.temperature",
+	"model.top_p",
+	"model.stop",
+	"log.level",
+	"syntax",
+	"completion",
+	"auto_context",
+	"repo_map",
+	"index",
+	"titles.enabled",
+	"generation",
+	"locale",
+}
+
+// workspaceAllowed 判断工作区配置是否可以设置配置项 key
+func workspaceAllowed(key string) bool {
+	for _, allowed := range workspaceKeys {
+		if key == allowed || strings.HasPrefix(key, allowed+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// restrictWorkspace 删除工作区配置中不允许设置的配置项，返回被删除的配置项
+func restrictWorkspace(prefix string, values map[string]interface{}) []string {
+	var dropped []string
+	for k, v := range values {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		if workspaceAllowed(key) {
+			continue
+		}
+		if nested, ok := v.(map[string]interface{}); ok {
+			dropped = append(dropped, restrictWorkspace(key, nested)...)
+			if len(nested) > 0 {
+				continue
+			}
+		} else {
+			dropped = append(dropped, key)
+		}
+		delete(values, k)
+	}
+	sort.Strings(dropped)
+	return dropped
+}
+
+// envVars 是环境变量与配置项的对应关系，列表类型的值以逗号分隔
+var envVars = []struct{ name, key string }{
+	{"VIMCOPLIT_HOST", "server.host"},
+	{"VIMCOPLIT_PORT", "server.port"},
+	{"VIMCOPLIT_ALLOWED_HOSTS", "server.allowed_hosts"},
+	{"VIMCOPLIT_ALLOWED_SUBNETS", "server.allowed_subnets"},
+	{"VIMCOPLIT_SERVER_TOKEN", "server.token"},
+	{"VIMCOPLIT_MODEL_TYPE", "model.type"},
+	{"VIMCOPLIT_API_KEY", "model.api_key"},
+	{"VIMCOPLIT_API_KEYS", "model.api_keys"},
+	{"VIMCOPLIT_MAX_TOKENS", "model.max_tokens"},
+	{"VIMCOPLIT_TEMPERATURE", "model.temperature"},
+	{"VIMCOPLIT_DEBUG_TOKEN", "debug.token"},
+	{"VIMCOPLIT_LOG_LEVEL", "log.level"},
+	{"VIMCOPLIT_LOG_FILE", "log.file"},
+	{"VIMCOPLIT_SANDBOX_BACKEND", "sandbox.backend"},
+	{"VIMCOPLIT_SANDBOX_IMAGE", "sandbox.image"},
+	{"VIMCOPLIT_SHELL", "shell.path"},
+	{"VIMCOPLIT_FILTER_MODE", "filter.mode"},
+	{"VIMCOPLIT_SYNTAX_MODE", "syntax.mode"},
+	{"VIMCOPLIT_UPDATE_CHANNEL", "update.channel"},
+	{"VIMCOPLIT_DATA_DIR", "storage.data_dir"},
+	{"VIMCOPLIT_OFFLINE", "offline.enabled"},
+	{"VIMCOPLIT_LOCALE", "locale"},
+}
+
+// loadFromEnv 从环境变量加载配置，无效的值记录日志后忽略
+func loadFromEnv(cfg *Config) {
+	for _, env := range envVars {
+		value := os.Getenv(env.name)
+		if value == "" {
+			continue
+		}
+		if err := cfg.Set(env.key, value, "env "+env.name); err != nil {
+			log.Printf("环境变量 %s 无效: %v\n", env.name, err)
+		}
+	}
+}
+
+// loadFile 展开配置文件中引用的环境变量，把出现的配置项叠加到 cfg 上并记录来源，返回文件是否存在。
+// workspace 为 true 时文件来自工作区，只使用 workspaceKeys 中的配置项，值按字面使用
+func loadFile(cfg *Config, path string, workspace bool) (bool, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read config file %s: %v", path, err)
+	}
+	// 数字保留原始文本，重新编码时不损失精度
+	var values map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&values); err != nil {
+		return false, fmt.Errorf("failed to parse config file %s: %v", path, err)
+	}
+	if workspace {
+		if dropped := restrictWorkspace("", values); len(dropped) > 0 {
+			log.Printf("工作区配置 %s 中的 %s 只能在用户或系统配置中设置，已忽略\n", path, strings.Join(dropped, ", "))
+		}
+		// 工作区配置不展开环境变量，否则仓库可以把环境中的密钥读进配置
+		if hasEnvRefs(values) {
+			log.Printf("工作区配置 %s 中的 ${...} 不会展开为环境变量\n", path)
+		}
+	} else if _, refs := expandValues("", values); len(refs) > 0 {
+		return false, &MissingEnvError{Path: path, Refs: refs}
+	}
+	if data, err = json.Marshal(values); err != nil {
+		return false, err
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return false, fmt.Errorf("failed to parse config file %s: %v", path, err)
+	}
+	flatten("", values, func(key string, _ interface{}) {
+		cfg.setOrigin(key, path)
+	})
+	return true, nil
+}
+
+// flatten 把嵌套的对象展开为以 . 连接的配置项，数组作为单个配置项
+func flatten(prefix string, values map[string]interface{}, fn func(key string, value interface{})) {
+	for k, v := range values {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		if nested, ok := v.(map[string]interface{}); ok {
+			flatten(key, nested, fn)
+			continue
+		}
+		fn(key, v)
+	}
+}
+
+// setOrigin 记录配置项的来源
+func (c *Config) setOrigin(key, origin string) {
+	if c.origins == nil {
+		c.origins = make(map[string]string)
+	}
+	c.origins[key] = origin
+}
+
+// Origin 返回配置项的来源：default、配置文件路径、env <变量名> 或 flag -<参数名>
+func (c *Config) Origin(key string) string {
+	if origin, ok := c.origins[key]; ok {
+		return origin
+	}
+	return OriginDefault
+}
+
+// Value 是一个生效的配置项
+type Value struct {
+	Key    string      `json:"key"`
+	Value  interface{} `json:"value"`
+	Origin string      `json:"origin"`
+}
+
+// Values 返回所有生效的配置项及来源，按配置项排序
+func (c *Config) Values() []Value {
+	data, _ := json.Marshal(c)
+	var values map[string]interface{}
+	json.Unmarshal(data, &values)
+
+	var result []Value
+	flatten("", values, func(key string, value interface{}) {
+		result = append(result, Value{Key: key, Value: value, Origin: c.Origin(key)})
+	})
+	sort.Slice(result, func(i, j int) bool { return result[i].Key < result[j].Key })
+	return result
+}
+
+// Set 设置配置项并记录来源，key 为以 . 连接的 JSON 字段名，value 按字段类型解析，
+// 时长和大小使用与配置文件相同的格式，列表类型以逗号分隔。用于环境变量和命令行参数
+func (c *Config) Set(key, value, origin string) error {
+	field := reflect.ValueOf(c).Elem()
+	for _, name := range strings.Split(key, ".") {
+		if field.Kind() != reflect.Struct {
+			return fmt.Errorf("unknown config key %q", key)
+		}
+		field = fieldByJSONName(field, name)
+		if !field.IsValid() {
+			return fmt.Errorf("unknown config key %q", key)
+		}
+	}
+
+	if u, ok := field.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		if err := u.UnmarshalText([]byte(value)); err != nil {
+			return fmt.Errorf("invalid value for %s: %v", key, err)
+		}
+		c.setOrigin(key, origin)
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q for %s", value, key)
+		}
+		field.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q for %s", value, key)
+		}
+		field.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q for %s", value, key)
+		}
+		field.SetBool(b)
+	case reflect.Slice:
+		if field.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("config key %q cannot be set from a string", key)
+		}
+		parts := strings.Split(value, ",")
+		for i := range parts {
+			parts[i] = strings.TrimSpace(parts[i])
+		}
+		field.Set(reflect.ValueOf(parts).Convert(field.Type()))
+	default:
+		return fmt.Errorf("config key %q cannot be set from a string", key)
+	}
+	c.setOrigin(key, origin)
+	return nil
+}
+
+// fieldByJSONName 按 JSON 字段名查找结构体字段
+func fieldByJSONName(v reflect.Value, name string) reflect.Value {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		tag, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if tag == name && t.Field(i).IsExported() {
+			return v.Field(i)
+		}
+	}
+	return reflect.Value{}
+}
@@ -0,0 +1,77 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/liangsj/vimcoplit/internal/models"
+)
+
+// ModelProfile 是某个模型类型的默认生成参数，未设置的字段使用 model 段的全局值。
+// Model 是请求中使用的提供商模型名或推理接入点 ID，为空时使用该提供商的默认模型
+type ModelProfile struct {
+	Model       string   `json:"model,omitempty"`
+	MaxTokens   int      `json:"max_tokens,omitempty"`
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP        *float64 `json:"top_p,omitempty"`
+	Stop        []string `json:"stop,omitempty"`
+}
+
+// ModelDefaults 返回模型类型生效的默认生成参数，model.profiles 中该类型的设置优先于全局值
+func (c *Config) ModelDefaults(t models.ModelType) models.Defaults {
+	d := models.Defaults{
+		MaxTokens:   c.Model.MaxTokens,
+		Temperature: c.Model.Temperature,
+		TopP:        c.Model.TopP,
+		Stop:        c.Model.Stop,
+	}
+	p, ok := c.Model.Profiles[t]
+	if !ok {
+		return d
+	}
+	d.Model = p.Model
+	if p.MaxTokens > 0 {
+		d.MaxTokens = p.MaxTokens
+	}
+	if p.Temperature != nil {
+		d.Temperature = *p.Temperature
+	}
+	if p.TopP != nil {
+		d.TopP = *p.TopP
+	}
+	if p.Stop != nil {
+		d.Stop = p.Stop
+	}
+	return d
+}
+
+// validateModelDefaults 检查全局和每个模型类型的默认生成参数是否在有效范围内
+func (c *Config) validateModelDefaults() error {
+	check := func(name string, d models.Defaults) error {
+		if d.MaxTokens < 0 {
+			return fmt.Errorf("%s: max_tokens must not be negative", name)
+		}
+		if d.Temperature < 0 || d.Temperature > 2 {
+			return fmt.Errorf("%s: temperature must be between 0 and 2", name)
+		}
+		if d.TopP < 0 || d.TopP > 1 {
+			return fmt.Errorf("%s: top_p must be between 0 and 1", name)
+		}
+		return nil
+	}
+	if err := check("model", c.ModelDefaults("")); err != nil {
+		return err
+	}
+	supported := make(map[models.ModelType]bool)
+	for _, t := range models.SupportedModelTypes() {
+		supported[t] = true
+	}
+	for t := range c.Model.Profiles {
+		if !supported[t] {
+			return fmt.Errorf("model.profiles: unsupported model type %q", t)
+		}
+		if err := check("model.profiles."+string(t), c.ModelDefaults(t)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package config
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// secretKeys 是保存凭据的配置项，显示时隐藏，备份时不写入归档
+var secretKeys = map[string]bool{
+	"model.api_key":      true,
+	"model.api_keys":     true,
+	"debug.token":        true,
+	"server.token":       true,
+	"web_search.api_key": true,
+	"forge.token":        true,
+}
+
+// IsSecret 判断配置项 key 是否保存凭据
+func IsSecret(key string) bool {
+	return secretKeys[key]
+}
+
+// StripSecrets 删除配置文件内容中的凭据，引用环境变量的值不是凭据本身，保留不变
+func StripSecrets(data []byte) ([]byte, error) {
+	var values map[string]interface{}
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, err
+	}
+	for key := range secretKeys {
+		parent, name := lookupParent(values, key)
+		if parent == nil || hasEnvRefs(parent[name]) {
+			continue
+		}
+		delete(parent, name)
+	}
+	return json.MarshalIndent(values, "", "  ")
+}
+
+// KeepSecrets 把 current 中的凭据合并到 restored 中 restored 没有设置的配置项上，
+// 用于从去掉了凭据的备份恢复配置文件时保留现有的凭据。current 为空时原样返回 restored
+func KeepSecrets(restored, current []byte) ([]byte, error) {
+	if len(current) == 0 {
+		return restored, nil
+	}
+	var values, existing map[string]interface{}
+	if err := json.Unmarshal(restored, &values); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(current, &existing); err != nil {
+		// 现有的配置文件已经损坏，没有可以保留的凭据
+		return restored, nil
+	}
+	for key := range secretKeys {
+		from, name := lookupParent(existing, key)
+		if from == nil {
+			continue
+		}
+		value, ok := from[name]
+		if !ok {
+			continue
+		}
+		to := values
+		parts := strings.Split(key, ".")
+		for _, part := range parts[:len(parts)-1] {
+			next, ok := to[part].(map[string]interface{})
+			if !ok {
+				next = make(map[string]interface{})
+				to[part] = next
+			}
+			to = next
+		}
+		if _, ok := to[name]; !ok {
+			to[name] = value
+		}
+	}
+	return json.MarshalIndent(values, "", "  ")
+}
+
+// lookupParent 返回配置项 key 所在的配置段和配置项在段中的名称，配置段不存在时返回 nil
+func lookupParent(values map[string]interface{}, key string) (map[string]interface{}, string) {
+	parts := strings.Split(key, ".")
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := values[part].(map[string]interface{})
+		if !ok {
+			return nil, ""
+		}
+		values = next
+	}
+	return values, parts[len(parts)-1]
+}
@@ -0,0 +1,285 @@
+// Package archive 保存按保留策略移出活动存储的对话和任务。每条归档压缩保存为一个文件，
+// 导出格式为 gzip 压缩的 JSON Lines，每行一条完整归档，可以重新导入
+package archive
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FormatVersion 是归档格式的版本
+const FormatVersion = 1
+
+// fileExt 是归档文件的扩展名
+const fileExt = ".json.gz"
+
+// ErrNotFound 表示归档不存在
+var ErrNotFound = errors.New("archive not found")
+
+// idPattern 限制归档 ID，ID 会用于拼接文件名
+var idPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,128}$`)
+
+// Kind 是归档记录的类型
+type Kind string
+
+const (
+	KindConversation Kind = "conversation"
+	KindTask         Kind = "task"
+)
+
+// Entry 是一条归档，Data 为归档前的完整记录
+type Entry struct {
+	Version    int             `json:"version"`
+	Kind       Kind            `json:"kind"`
+	ID         string          `json:"id"`
+	Title      string          `json:"title,omitempty"`
+	UpdatedAt  time.Time       `json:"updated_at"` // 记录归档前最后一次更新的时间
+	ArchivedAt time.Time       `json:"archived_at"`
+	Data       json.RawMessage `json:"data,omitempty"`
+}
+
+// validate 检查归档是否有效，导入的归档来自外部文件
+func (e *Entry) validate() error {
+	if e.Version > FormatVersion {
+		return fmt.Errorf("unsupported archive version %d", e.Version)
+	}
+	if e.Kind != KindConversation && e.Kind != KindTask {
+		return fmt.Errorf("unknown archive kind %q", e.Kind)
+	}
+	if !idPattern.MatchString(e.ID) {
+		return fmt.Errorf("invalid archive id %q", e.ID)
+	}
+	if len(e.Data) == 0 || !json.Valid(e.Data) {
+		return fmt.Errorf("archive %s %s has no valid data", e.Kind, e.ID)
+	}
+	return nil
+}
+
+// header 返回不包含数据的归档副本
+func (e *Entry) header() *Entry {
+	h := *e
+	h.Data = nil
+	return &h
+}
+
+// Store 是归档存储，内存中只保留归档的元数据，数据在需要时从磁盘读取
+type Store struct {
+	mu      sync.Mutex
+	dir     string
+	entries map[string]*Entry
+}
+
+// New 创建归档存储并加载已有归档的元数据
+func New(dir string) *Store {
+	s := &Store{
+		dir:     dir,
+		entries: make(map[string]*Entry),
+	}
+	files, _ := os.ReadDir(dir)
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), fileExt) {
+			continue
+		}
+		e, err := readFile(filepath.Join(dir, f.Name()))
+		if err != nil {
+			log.Printf("加载归档 %s 失败: %v\n", f.Name(), err)
+			continue
+		}
+		s.entries[key(e.Kind, e.ID)] = e.header()
+	}
+	return s
+}
+
+// key 返回归档在存储中的键，也用作文件名
+func key(kind Kind, id string) string {
+	return string(kind) + "-" + id
+}
+
+// path 返回归档文件的路径
+func (s *Store) path(kind Kind, id string) string {
+	return filepath.Join(s.dir, key(kind, id)+fileExt)
+}
+
+// readFile 读取一个归档文件
+func readFile(path string) (*Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	var e Entry
+	if err := json.NewDecoder(gz).Decode(&e); err != nil {
+		return nil, err
+	}
+	if err := e.validate(); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// Put 保存归档，同类型同 ID 的归档会被覆盖
+func (s *Store) Put(e *Entry) error {
+	if e.Version == 0 {
+		e.Version = FormatVersion
+	}
+	if e.ArchivedAt.IsZero() {
+		e.ArchivedAt = time.Now()
+	}
+	if err := e.validate(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return err
+	}
+	path := s.path(e.Kind, e.ID)
+	tmp, err := os.CreateTemp(s.dir, ".archive-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	gz := gzip.NewWriter(tmp)
+	if err := json.NewEncoder(gz).Encode(e); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	s.entries[key(e.Kind, e.ID)] = e.header()
+	return nil
+}
+
+// Get 读取完整归档
+func (s *Store) Get(kind Kind, id string) (*Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// 只读取元数据中存在的归档，id 来自请求，不能直接用于拼接路径
+	if _, ok := s.entries[key(kind, id)]; !ok {
+		return nil, ErrNotFound
+	}
+	return readFile(s.path(kind, id))
+}
+
+// Delete 删除归档
+func (s *Store) Delete(kind Kind, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.entries[key(kind, id)]; !ok {
+		return ErrNotFound
+	}
+	return s.remove(kind, id)
+}
+
+// remove 删除归档文件和元数据，调用方需持有锁
+func (s *Store) remove(kind Kind, id string) error {
+	if err := os.Remove(s.path(kind, id)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	delete(s.entries, key(kind, id))
+	return nil
+}
+
+// List 按归档时间倒序列出归档的元数据
+func (s *Store) List() []*Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := make([]*Entry, 0, len(s.entries))
+	for _, e := range s.entries {
+		list = append(list, e.header())
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].ArchivedAt.After(list[j].ArchivedAt)
+	})
+	return list
+}
+
+// Purge 删除最后更新时间早于 before 的归档，返回删除的数量
+func (s *Store) Purge(before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	purged := 0
+	for _, e := range s.entries {
+		if !e.UpdatedAt.Before(before) {
+			continue
+		}
+		if err := s.remove(e.Kind, e.ID); err != nil {
+			return purged, err
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+// Export 将所有归档以导出格式写入 w，返回导出的数量
+func (s *Store) Export(w io.Writer) (int, error) {
+	gz := gzip.NewWriter(w)
+	enc := json.NewEncoder(gz)
+	count := 0
+	for _, h := range s.List() {
+		e, err := s.Get(h.Kind, h.ID)
+		if errors.Is(err, ErrNotFound) {
+			continue // 导出期间被恢复或删除
+		}
+		if err != nil {
+			return count, fmt.Errorf("failed to read archive %s %s: %v", h.Kind, h.ID, err)
+		}
+		if err := enc.Encode(e); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, gz.Close()
+}
+
+// Import 读取导出格式的归档并保存，已有的同名归档会被覆盖，返回导入的数量
+func (s *Store) Import(r io.Reader) (int, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return 0, fmt.Errorf("invalid archive export: %v", err)
+	}
+	dec := json.NewDecoder(bufio.NewReader(gz))
+	count := 0
+	for {
+		var e Entry
+		if err := dec.Decode(&e); err == io.EOF {
+			return count, nil
+		} else if err != nil {
+			return count, fmt.Errorf("invalid archive export: %v", err)
+		}
+		if err := s.Put(&e); err != nil {
+			return count, err
+		}
+		count++
+	}
+}
@@ -0,0 +1,77 @@
+package archive
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestStore(t *testing.T) {
+	dir := t.TempDir()
+	s := New(dir)
+	old := time.Now().Add(-90 * 24 * time.Hour)
+	for _, e := range []*Entry{
+		{Kind: KindConversation, ID: "c1", Title: "old chat", UpdatedAt: old, Data: json.RawMessage(`{"id":"c1"}`)},
+		{Kind: KindTask, ID: "t1", UpdatedAt: time.Now(), Data: json.RawMessage(`{"id":"t1"}`)},
+	} {
+		if err := s.Put(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, e := range []*Entry{
+		{Kind: "run", ID: "r1", Data: json.RawMessage(`{}`)},
+		{Kind: KindTask, ID: "../escape", Data: json.RawMessage(`{}`)},
+		{Kind: KindTask, ID: "t2"},
+	} {
+		if err := s.Put(e); err == nil {
+			t.Errorf("expected invalid entry %+v to be rejected", e)
+		}
+	}
+
+	// 重新打开时从磁盘加载元数据
+	s = New(dir)
+	list := s.List()
+	if len(list) != 2 || list[0].Data != nil {
+		t.Fatalf("expected two entries without data, got %+v", list)
+	}
+	e, err := s.Get(KindConversation, "c1")
+	if err != nil || e.Title != "old chat" || string(e.Data) != `{"id":"c1"}` || e.Version != FormatVersion {
+		t.Fatalf("unexpected entry %+v %v", e, err)
+	}
+	if _, err := s.Get(KindConversation, "t1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected not found for wrong kind, got %v", err)
+	}
+
+	if n, err := s.Purge(time.Now().Add(-30 * 24 * time.Hour)); n != 1 || err != nil {
+		t.Errorf("expected one purged entry, got %d %v", n, err)
+	}
+	if err := s.Delete(KindTask, "t1"); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.List()) != 0 || len(New(dir).List()) != 0 {
+		t.Error("expected empty archive after purge and delete")
+	}
+}
+
+func TestExportImport(t *testing.T) {
+	src := New(t.TempDir())
+	src.Put(&Entry{Kind: KindConversation, ID: "c1", Data: json.RawMessage(`{"id":"c1"}`)})
+	src.Put(&Entry{Kind: KindTask, ID: "t1", Data: json.RawMessage(`{"id":"t1"}`)})
+
+	var buf bytes.Buffer
+	if n, err := src.Export(&buf); n != 2 || err != nil {
+		t.Fatalf("expected two exported entries, got %d %v", n, err)
+	}
+	dst := New(t.TempDir())
+	if n, err := dst.Import(&buf); n != 2 || err != nil {
+		t.Fatalf("expected two imported entries, got %d %v", n, err)
+	}
+	if e, err := dst.Get(KindTask, "t1"); err != nil || string(e.Data) != `{"id":"t1"}` {
+		t.Errorf("unexpected imported entry %+v %v", e, err)
+	}
+	if _, err := dst.Import(bytes.NewReader([]byte("not gzip"))); err == nil {
+		t.Error("expected error for invalid export")
+	}
+}
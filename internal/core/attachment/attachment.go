@@ -0,0 +1,317 @@
+// Package attachment 保存运行中产生的附件，例如生成的图片、较大的日志和下载的文件。
+// 附件按内容的 SHA-256 寻址，相同内容只保存一份，对话记录和工具结果中以 attachment:<id> 引用。
+// 总大小和单个附件的大小受配额限制，不再被引用的附件由 Collect 回收
+package attachment
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Scheme 是引用附件的 URI 前缀
+const Scheme = "attachment:"
+
+// indexFile 是附件元数据文件的名称
+const indexFile = "index.json"
+
+// sniffLen 是未指定类型时用于检测内容类型的字节数
+const sniffLen = 512
+
+var (
+	// ErrNotFound 表示附件不存在
+	ErrNotFound = errors.New("attachment not found")
+	// ErrQuotaExceeded 表示保存附件会超过总大小配额
+	ErrQuotaExceeded = errors.New("attachment quota exceeded")
+	// ErrTooLarge 表示附件超过单个附件的大小上限
+	ErrTooLarge = errors.New("attachment too large")
+)
+
+// idPattern 匹配附件 ID，ID 会用于拼接文件路径
+var idPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// refPattern 匹配文本中对附件的引用
+var refPattern = regexp.MustCompile(Scheme + `([0-9a-f]{64})`)
+
+// Attachment 是附件的元数据，ID 为内容的 SHA-256
+type Attachment struct {
+	ID        string    `json:"id"`
+	Size      int64     `json:"size"`
+	MediaType string    `json:"media_type"`
+	Name      string    `json:"name,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// URI 返回引用附件的 URI
+func (a *Attachment) URI() string {
+	return URI(a.ID)
+}
+
+// Usage 是附件存储的占用情况，配额为 0 表示不限制
+type Usage struct {
+	Count    int   `json:"count"`
+	Bytes    int64 `json:"bytes"`
+	MaxBytes int64 `json:"max_bytes,omitempty"`
+	MaxSize  int64 `json:"max_size,omitempty"`
+}
+
+// Collection 是一次回收的结果
+type Collection struct {
+	Removed int   `json:"removed"`
+	Freed   int64 `json:"freed"`
+}
+
+// URI 返回引用 ID 为 id 的附件的 URI
+func URI(id string) string {
+	return Scheme + id
+}
+
+// Refs 返回 data 中引用的附件 ID
+func Refs(data []byte) []string {
+	var ids []string
+	for _, m := range refPattern.FindAllSubmatch(data, -1) {
+		ids = append(ids, string(m[1]))
+	}
+	return ids
+}
+
+// Store 是附件存储，内容保存在 objects/ 下按 ID 前两位分目录的文件中，元数据保存在 index.json 中
+type Store struct {
+	mu       sync.Mutex
+	dir      string
+	maxBytes int64 // 总大小配额，不大于 0 时不限制
+	maxSize  int64 // 单个附件的大小上限，不大于 0 时不限制
+	items    map[string]*Attachment
+	used     int64
+}
+
+// New 创建附件存储并加载已有附件的元数据
+func New(dir string, maxBytes, maxSize int64) *Store {
+	s := &Store{
+		dir:      dir,
+		maxBytes: maxBytes,
+		maxSize:  maxSize,
+		items:    make(map[string]*Attachment),
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, indexFile)); err == nil {
+		json.Unmarshal(data, &s.items)
+	}
+	for id, a := range s.items {
+		if !idPattern.MatchString(id) {
+			delete(s.items, id)
+			continue
+		}
+		s.used += a.Size
+	}
+	return s
+}
+
+// Dir 返回存储所在的目录
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// path 返回附件内容的文件路径
+func (s *Store) path(id string) string {
+	return filepath.Join(s.dir, "objects", id[:2], id)
+}
+
+// save 保存元数据，调用方需持有锁
+func (s *Store) save() error {
+	data, err := json.MarshalIndent(s.items, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(s.dir, indexFile), data, 0644)
+}
+
+// Put 保存 r 的内容，mediaType 为空时按内容检测。内容已存在时返回已有的附件，不重复计入配额
+func (s *Store) Put(r io.Reader, mediaType, name string) (*Attachment, error) {
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return nil, err
+	}
+	tmp, err := os.CreateTemp(s.dir, "upload-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if s.maxSize > 0 {
+		r = io.LimitReader(r, s.maxSize+1)
+	}
+	hash := sha256.New()
+	head := &prefixWriter{limit: sniffLen}
+	size, err := io.Copy(io.MultiWriter(tmp, hash, head), r)
+	if err != nil {
+		return nil, err
+	}
+	if s.maxSize > 0 && size > s.maxSize {
+		return nil, fmt.Errorf("%w: exceeds %d bytes", ErrTooLarge, s.maxSize)
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, err
+	}
+	if mediaType == "" {
+		mediaType = http.DetectContentType(head.data)
+	}
+	id := hex.EncodeToString(hash.Sum(nil))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if a, ok := s.items[id]; ok {
+		c := *a
+		return &c, nil
+	}
+	if s.maxBytes > 0 && s.used+size > s.maxBytes {
+		return nil, fmt.Errorf("%w: %d of %d bytes used", ErrQuotaExceeded, s.used, s.maxBytes)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path(id)), 0755); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp.Name(), s.path(id)); err != nil {
+		return nil, err
+	}
+	a := &Attachment{ID: id, Size: size, MediaType: mediaType, Name: name, CreatedAt: time.Now()}
+	s.items[id] = a
+	s.used += size
+	if err := s.save(); err != nil {
+		return nil, err
+	}
+	c := *a
+	return &c, nil
+}
+
+// Attach 保存二进制数据并返回引用它的 URI，用于把工具结果中的数据移出对话记录
+func (s *Store) Attach(data []byte, mediaType string) (string, error) {
+	a, err := s.Put(bytes.NewReader(data), mediaType, "")
+	if err != nil {
+		return "", err
+	}
+	return a.URI(), nil
+}
+
+// Get 返回附件的元数据
+func (s *Store) Get(id string) (*Attachment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, ok := s.items[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	c := *a
+	return &c, nil
+}
+
+// Open 打开附件的内容，调用方负责关闭
+func (s *Store) Open(id string) (io.ReadCloser, *Attachment, error) {
+	a, err := s.Get(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	f, err := os.Open(s.path(id))
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, a, nil
+}
+
+// Delete 删除附件
+func (s *Store) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.items[id]; !ok {
+		return ErrNotFound
+	}
+	if err := s.remove(id); err != nil {
+		return err
+	}
+	return s.save()
+}
+
+// remove 删除附件的内容并移出元数据，调用方需持有锁
+func (s *Store) remove(id string) error {
+	if err := os.Remove(s.path(id)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	s.used -= s.items[id].Size
+	delete(s.items, id)
+	return nil
+}
+
+// List 按创建时间倒序列出附件
+func (s *Store) List() []*Attachment {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]*Attachment, 0, len(s.items))
+	for _, a := range s.items {
+		c := *a
+		list = append(list, &c)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].CreatedAt.After(list[j].CreatedAt)
+	})
+	return list
+}
+
+// Usage 返回附件存储的占用情况
+func (s *Store) Usage() Usage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return Usage{Count: len(s.items), Bytes: s.used, MaxBytes: s.maxBytes, MaxSize: s.maxSize}
+}
+
+// Collect 删除创建早于 before 且不在 referenced 中的附件。
+// 较新的附件可能已经上传但还没有写入引用它的消息，所以保留
+func (s *Store) Collect(referenced map[string]bool, before time.Time) (*Collection, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := &Collection{}
+	var err error
+	for id, a := range s.items {
+		if referenced[id] || !a.CreatedAt.Before(before) {
+			continue
+		}
+		size := a.Size
+		if err = s.remove(id); err != nil {
+			break
+		}
+		result.Removed++
+		result.Freed += size
+	}
+	if result.Removed > 0 {
+		if saveErr := s.save(); err == nil {
+			err = saveErr
+		}
+	}
+	return result, err
+}
+
+// prefixWriter 保留写入内容的前 limit 个字节
+type prefixWriter struct {
+	limit int
+	data  []byte
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	if n := w.limit - len(w.data); n > 0 {
+		w.data = append(w.data, p[:min(n, len(p))]...)
+	}
+	return len(p), nil
+}
@@ -0,0 +1,103 @@
+package attachment
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStore(t *testing.T) {
+	dir := t.TempDir()
+	s := New(dir, 20, 16)
+
+	a, err := s.Put(strings.NewReader("hello world"), "", "greeting.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(a.ID) != 64 || a.Size != 11 || !strings.HasPrefix(a.MediaType, "text/plain") || a.Name != "greeting.txt" {
+		t.Errorf("unexpected attachment %+v", a)
+	}
+	// 相同内容只保存一份，不重复计入配额
+	again, err := s.Put(strings.NewReader("hello world"), "text/markdown", "")
+	if err != nil || again.ID != a.ID || again.Name != "greeting.txt" {
+		t.Errorf("expected existing attachment, got %+v %v", again, err)
+	}
+	if _, err := s.Put(strings.NewReader(strings.Repeat("x", 17)), "", ""); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("expected ErrTooLarge, got %v", err)
+	}
+	if _, err := s.Put(strings.NewReader(strings.Repeat("y", 10)), "", ""); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("expected ErrQuotaExceeded, got %v", err)
+	}
+	if u := s.Usage(); u.Count != 1 || u.Bytes != 11 {
+		t.Errorf("unexpected usage %+v", u)
+	}
+
+	// 重新打开时从磁盘加载元数据
+	s = New(dir, 20, 16)
+	r, got, err := s.Open(a.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(r)
+	r.Close()
+	if string(data) != "hello world" || got.Name != "greeting.txt" {
+		t.Errorf("unexpected content %q of %+v", data, got)
+	}
+	if _, _, err := s.Open("../index.json"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for invalid id, got %v", err)
+	}
+
+	uri, err := s.Attach([]byte{0x89, 'P', 'N', 'G', '\r', '\n', 0x1a, '\n'}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := Refs([]byte(`{"content": "see [image image/png: ` + uri + `]"}`))
+	if len(ids) != 1 || URI(ids[0]) != uri {
+		t.Errorf("expected reference to %s, got %v", uri, ids)
+	}
+	if png, _ := s.Get(ids[0]); png.MediaType != "image/png" {
+		t.Errorf("expected detected image type, got %+v", png)
+	}
+
+	if err := s.Delete(a.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete(a.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound after delete, got %v", err)
+	}
+}
+
+func TestCollect(t *testing.T) {
+	s := New(t.TempDir(), 0, 0)
+	var ids []string
+	for _, content := range []string{"referenced", "orphan", "recent"} {
+		a, err := s.Put(bytes.NewReader([]byte(content)), "", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, a.ID)
+	}
+	// 前两个附件视为一天前创建
+	s.mu.Lock()
+	for _, id := range ids[:2] {
+		s.items[id].CreatedAt = time.Now().Add(-24 * time.Hour)
+	}
+	s.mu.Unlock()
+
+	result, err := s.Collect(map[string]bool{ids[0]: true}, time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Removed != 1 || result.Freed != int64(len("orphan")) {
+		t.Errorf("expected only the old unreferenced attachment collected, got %+v", result)
+	}
+	if _, err := s.Get(ids[1]); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected orphan removed, got %v", err)
+	}
+	if u := s.Usage(); u.Count != 2 || u.Bytes != int64(len("referenced")+len("recent")) {
+		t.Errorf("unexpected usage after collection %+v", u)
+	}
+}
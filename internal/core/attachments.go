@@ -0,0 +1,92 @@
+package core
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/liangsj/vimcoplit/internal/core/attachment"
+)
+
+// attachmentGrace 是新附件在没有被引用时保留的时间，上传后引用它的消息可能还没有写入
+const attachmentGrace = 24 * time.Hour
+
+// PutAttachment 保存附件，mediaType 为空时按内容检测
+func (s *serviceImpl) PutAttachment(ctx context.Context, r io.Reader, mediaType, name string) (*attachment.Attachment, error) {
+	return s.attachments.Put(r, mediaType, name)
+}
+
+// OpenAttachment 打开附件的内容，调用方负责关闭
+func (s *serviceImpl) OpenAttachment(ctx context.Context, id string) (io.ReadCloser, *attachment.Attachment, error) {
+	return s.attachments.Open(id)
+}
+
+// ListAttachments 按创建时间倒序列出附件，并返回存储的占用情况
+func (s *serviceImpl) ListAttachments(ctx context.Context) ([]*attachment.Attachment, attachment.Usage) {
+	return s.attachments.List(), s.attachments.Usage()
+}
+
+// DeleteAttachment 删除附件，引用它的记录中的 URI 不会被修改
+func (s *serviceImpl) DeleteAttachment(ctx context.Context, id string) error {
+	return s.attachments.Delete(id)
+}
+
+// CollectAttachments 删除不再被任何记录引用的附件，最近 attachmentGrace 内保存的附件保留
+func (s *serviceImpl) CollectAttachments(ctx context.Context) (*attachment.Collection, error) {
+	referenced, err := s.attachmentRefs()
+	if err != nil {
+		return nil, err
+	}
+	return s.attachments.Collect(referenced, time.Now().Add(-attachmentGrace))
+}
+
+// attachmentRefs 返回数据目录中所有记录引用的附件。对话、任务、命令历史、agent 运行和归档
+// 都保存在数据目录中，逐个文件查找附件 URI，不依赖各存储的格式；压缩的归档解压后查找
+func (s *serviceImpl) attachmentRefs() (map[string]bool, error) {
+	referenced := make(map[string]bool)
+	err := filepath.WalkDir(s.cfg.DataDir(), func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			if path == s.attachments.Dir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		data, err := readRecordFile(path)
+		if err != nil {
+			return err
+		}
+		for _, id := range attachment.Refs(data) {
+			referenced[id] = true
+		}
+		return nil
+	})
+	return referenced, err
+}
+
+// readRecordFile 读取文件内容，gzip 压缩的文件返回解压后的内容
+func readRecordFile(path string) ([]byte, error) {
+	if !strings.HasSuffix(path, ".gz") {
+		return os.ReadFile(path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(gz)
+}
@@ -0,0 +1,172 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/liangsj/vimcoplit/internal/config"
+	"github.com/liangsj/vimcoplit/internal/core/index"
+	"github.com/liangsj/vimcoplit/internal/core/related"
+	"github.com/liangsj/vimcoplit/internal/core/repomap"
+	"github.com/liangsj/vimcoplit/internal/models"
+)
+
+// RelatedFiles 返回当前文件的相关文件：同包、导入、测试与实现配对和最近共同修改的文件，
+// 匹配工作区忽略规则的文件不会返回
+func (s *serviceImpl) RelatedFiles(ctx context.Context, path string, limit int) ([]related.Candidate, error) {
+	candidates, err := s.related.Find(ctx, path, 0)
+	if err != nil {
+		return nil, err
+	}
+	settings := s.settings.get()
+	filtered := candidates[:0]
+	for _, c := range candidates {
+		if !settings.Ignored(c.Path) {
+			filtered = append(filtered, c)
+		}
+	}
+	if limit > 0 && len(filtered) > limit {
+		filtered = filtered[:limit]
+	}
+	return filtered, nil
+}
+
+// relatedContext 收集得分最高的相关文件作为自动上下文，每个文件截断到配置的长度，
+// budget 大于 0 时总长度不超过 budget 个 token
+func (s *serviceImpl) relatedContext(ctx context.Context, path string, budget int) string {
+	cfg := s.cfg.AutoContext
+	if path == "" || cfg.RelatedFiles <= 0 {
+		return ""
+	}
+	candidates, err := s.RelatedFiles(ctx, path, cfg.RelatedFiles)
+	if err != nil {
+		log.Printf("查找相关文件失败: %v\n", err)
+		return ""
+	}
+
+	var b strings.Builder
+	for _, c := range candidates {
+		file := c.Path
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(s.related.Root(), file)
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		if max := int(cfg.MaxFileBytes); max > 0 && len(data) > max {
+			data = append(data[:max:max], "\n..."...)
+		}
+		entry := fmt.Sprintf("--- %s\n%s\n", c.Path, data)
+		if budget > 0 && models.EstimateTokens(b.String()+entry) > budget {
+			break
+		}
+		b.WriteString(entry)
+	}
+	return b.String()
+}
+
+// repoMapInterval 是仓库地图自动刷新的最短间隔
+const repoMapInterval = 30 * time.Second
+
+// RepoMap 返回仓库地图，refresh 为 true 或距上次刷新超过 repoMapInterval 时重新扫描修改过的文件
+func (s *serviceImpl) RepoMap(ctx context.Context, refresh bool) (*repomap.Map, error) {
+	s.mu.Lock()
+	stale := refresh || time.Since(s.repoMapAt) > repoMapInterval
+	if stale {
+		s.repoMapAt = time.Now()
+	}
+	s.mu.Unlock()
+
+	if stale {
+		return s.repoMap.Refresh()
+	}
+	return s.repoMap.Current()
+}
+
+// SearchCode 在仓库和只读上下文源中检索代码片段，距上次刷新超过 repoMapInterval 时重新索引修改过的文件：
+// 索引为空时等待刷新完成，否则在后台刷新并先用已有的索引检索
+func (s *serviceImpl) SearchCode(ctx context.Context, q *index.Query) ([]index.Result, error) {
+	s.mu.Lock()
+	stale := time.Since(s.codeIndexAt) > repoMapInterval
+	s.mu.Unlock()
+
+	if stale && s.codeIndex.Stats().Files == 0 {
+		s.mu.Lock()
+		s.codeIndexAt = time.Now()
+		s.mu.Unlock()
+		if err := s.codeIndex.Refresh(ctx); err != nil {
+			return nil, err
+		}
+	} else if stale {
+		s.StartIndexing()
+	}
+	results, err := s.codeIndex.Search(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	lists, err := s.sources.search(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	return index.Merge(q.Limit, append([][]index.Result{results}, lists...)...), nil
+}
+
+// SearchFiles 按行检索工作区的文件，每找到一行匹配就调用 fn，不等待索引刷新。
+// q.Near 可以是绝对路径，会转换为相对工作区的路径
+func (s *serviceImpl) SearchFiles(ctx context.Context, q *index.GrepQuery, fn func(index.Match) bool) (*index.GrepSummary, error) {
+	query := *q
+	if filepath.IsAbs(query.Near) {
+		if rel, err := filepath.Rel(s.cfg.WorkspaceRoot(), query.Near); err == nil {
+			query.Near = filepath.ToSlash(rel)
+		}
+	}
+	return s.codeIndex.Grep(ctx, &query, fn)
+}
+
+// codeIndexDir 返回代码检索索引的段文件目录
+func codeIndexDir(cfg *config.Config) string {
+	return filepath.Join(cfg.DataDir(), "index")
+}
+
+// codeIndexQuantization 返回配置的向量存储精度，配置无效时不量化
+func codeIndexQuantization(cfg *config.Config) index.Quantization {
+	q, err := index.ParseQuantization(cfg.Index.VectorQuantization)
+	if err != nil {
+		log.Printf("向量存储精度配置无效，不量化: %v\n", err)
+		return index.Float32
+	}
+	return q
+}
+
+// OpenCodeIndex 打开工作区的代码检索索引并应用工作区设置中的忽略规则、索引范围和向量存储精度，供命令行工具使用
+func OpenCodeIndex(cfg *config.Config) *index.Index {
+	root := cfg.WorkspaceRoot()
+	x := index.New(root, codeIndexDir(cfg))
+	x.SetQuantization(codeIndexQuantization(cfg))
+	settings := newSettingsStore(filepath.Join(root, ".vimcoplit", "settings.json"), "").get()
+	x.SetIgnore(settings.Ignored)
+	x.SetScope(settings.IndexScope)
+	return x
+}
+
+// repoMapContext 返回加入提示词的仓库地图，未启用、超出工作区的上下文预算或生成失败时返回空字符串
+func (s *serviceImpl) repoMapContext(ctx context.Context) string {
+	if !s.cfg.RepoMap.Enabled {
+		return ""
+	}
+	m, err := s.RepoMap(ctx, false)
+	if err != nil {
+		log.Printf("生成仓库地图失败: %v\n", err)
+		return ""
+	}
+	if budget := s.settings.get().ContextBudget; budget > 0 && m.Tokens > budget {
+		return ""
+	}
+	return m.Text
+}
@@ -0,0 +1,61 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/liangsj/vimcoplit/internal/backup"
+	"github.com/liangsj/vimcoplit/internal/config"
+)
+
+// contextBackupName 是上下文条目在备份归档中的名称
+const contextBackupName = "context.json"
+
+// BackupSources 返回需要备份的文件：配置文件、MCP 定义和数据目录
+// 数据目录中包含任务、会话等所有持久化状态。配置文件中的凭据不写入归档，恢复时保留现有配置文件中的凭据
+func BackupSources(cfg *config.Config) []backup.Source {
+	return []backup.Source{
+		{Name: "config.json", Path: cfg.Path(), Filter: config.StripSecrets, Merge: config.KeepSecrets},
+		{Name: "mcp.json", Path: cfg.MCPPath()},
+		{Name: "data", Path: cfg.DataDir()},
+	}
+}
+
+// Backup 将服务的完整状态打包写入 w，包括内存中的上下文条目
+func (s *serviceImpl) Backup(ctx context.Context, w io.Writer) (*backup.Manifest, error) {
+	items := s.contextManager.ListItems()
+	data, err := json.Marshal(items)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal context items: %v", err)
+	}
+
+	return backup.Create(w, BackupSources(s.cfg), map[string][]byte{
+		contextBackupName: data,
+	})
+}
+
+// Restore 从归档恢复服务状态，并重新加载任务和 MCP 服务器
+// 配置文件的变更需要重启服务后生效
+func (s *serviceImpl) Restore(ctx context.Context, r io.Reader) (*backup.Manifest, error) {
+	manifest, blobs, err := backup.Extract(r, BackupSources(s.cfg))
+	if err != nil {
+		return nil, err
+	}
+
+	if data, ok := blobs[contextBackupName]; ok {
+		var items []*BaseContextItem
+		if err := json.Unmarshal(data, &items); err != nil {
+			return nil, fmt.Errorf("failed to parse context items: %v", err)
+		}
+		for _, item := range items {
+			s.contextManager.AddItem(item)
+		}
+	}
+
+	if _, err := s.Recover(ctx); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
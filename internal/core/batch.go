@@ -0,0 +1,90 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// BatchPrompt 是批量生成中的一个提示词，ID 为空时使用其在批次中的序号
+type BatchPrompt struct {
+	ID     string `json:"id"`
+	Prompt string `json:"prompt"`
+}
+
+// BatchResult 是批量生成中一个提示词的结果，单个提示词失败不影响其他提示词
+type BatchResult struct {
+	ID       string `json:"id"`
+	Response string `json:"response,omitempty"`
+	Error    string `json:"error,omitempty"`
+	err      error
+}
+
+// Err 返回生成失败的原始错误
+func (r *BatchResult) Err() error {
+	return r.err
+}
+
+// GenerateBatch 并发生成多个提示词的响应，同时进行的请求数不超过 limits.batch_concurrency，
+// 整个批次只占用一个会话。返回的结果按 ID 索引，ctx 取消后尚未开始的提示词以取消错误结束
+func (s *serviceImpl) GenerateBatch(ctx context.Context, prompts []BatchPrompt) (map[string]*BatchResult, error) {
+	if len(prompts) == 0 {
+		return nil, errors.New("at least one prompt is required")
+	}
+	if limit := s.cfg.Limits.MaxBatchPrompts; limit > 0 && len(prompts) > limit {
+		return nil, fmt.Errorf("at most %d prompts are allowed in a batch", limit)
+	}
+	results := make(map[string]*BatchResult, len(prompts))
+	for i := range prompts {
+		p := &prompts[i]
+		if p.ID == "" {
+			p.ID = strconv.Itoa(i)
+		}
+		if strings.TrimSpace(p.Prompt) == "" {
+			return nil, fmt.Errorf("prompt %q is empty", p.ID)
+		}
+		if _, ok := results[p.ID]; ok {
+			return nil, fmt.Errorf("duplicate prompt id %q", p.ID)
+		}
+		results[p.ID] = &BatchResult{ID: p.ID}
+	}
+	if _, err := s.generationParams(ctx); err != nil {
+		return nil, err
+	}
+	end, err := s.beginSession()
+	if err != nil {
+		return nil, err
+	}
+	defer end()
+
+	concurrency := s.cfg.Limits.BatchConcurrency
+	if concurrency <= 0 || concurrency > len(prompts) {
+		concurrency = len(prompts)
+	}
+	slots := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for _, p := range prompts {
+		result := results[p.ID]
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			result.err = ctx.Err()
+			result.Error = result.err.Error()
+			continue
+		}
+		wg.Add(1)
+		go func(prompt string) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			result.Response, result.err = s.GenerateResponse(ctx, prompt)
+			if result.err != nil {
+				result.Error = result.err.Error()
+			}
+		}(p.Prompt)
+	}
+	wg.Wait()
+	return results, nil
+}
@@ -0,0 +1,215 @@
+// Package codeblock 从 markdown 文本或 Jupyter 笔记本中提取代码块，并给出执行各语言代码块的命令
+package codeblock
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/liangsj/vimcoplit/internal/core/langdetect"
+)
+
+// Block 是一个代码块，Language 已统一为 langdetect 的语言名，围栏没有标注语言时按内容识别
+type Block struct {
+	Language string `json:"language"`
+	Info     string `json:"info,omitempty"` // 围栏开始行中的完整信息字符串
+	Code     string `json:"code"`
+	Line     int    `json:"line,omitempty"` // 围栏开始行的行号，从 1 开始
+	Cell     int    `json:"cell,omitempty"` // 笔记本中单元格的序号，从 1 开始
+}
+
+// fenceOpen 匹配围栏代码块的开始行：最多缩进 3 个空格，至少 3 个反引号或波浪号，反引号围栏的信息字符串不能包含反引号
+var fenceOpen = regexp.MustCompile("^( {0,3})(`{3,}|~{3,})(.*)$")
+
+// Extract 提取 markdown 中的围栏代码块，未闭合的代码块延续到文本结尾
+func Extract(markdown string) []Block {
+	var blocks []Block
+	var current *Block
+	var code strings.Builder
+	var fence string
+	indent := 0
+	for i, line := range strings.Split(strings.TrimSuffix(markdown, "\n"), "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if current == nil {
+			m := fenceOpen.FindStringSubmatch(line)
+			if m == nil || (m[2][0] == '`' && strings.Contains(m[3], "`")) {
+				continue
+			}
+			info := strings.TrimSpace(m[3])
+			current = &Block{Info: info, Line: i + 1, Language: infoLanguage(info)}
+			fence, indent = m[2], len(m[1])
+			code.Reset()
+			continue
+		}
+		if isClosingFence(line, fence) {
+			blocks = append(blocks, finish(current, code.String()))
+			current = nil
+			continue
+		}
+		// 去掉与开始围栏相同的缩进
+		for n := 0; n < indent && strings.HasPrefix(line, " "); n++ {
+			line = line[1:]
+		}
+		code.WriteString(line)
+		code.WriteString("\n")
+	}
+	if current != nil {
+		blocks = append(blocks, finish(current, code.String()))
+	}
+	return blocks
+}
+
+// isClosingFence 判断是否为闭合围栏：同一种字符，长度不小于开始围栏，后面只有空白
+func isClosingFence(line, fence string) bool {
+	trimmed := strings.TrimLeft(line, " ")
+	if len(line)-len(trimmed) > 3 {
+		return false
+	}
+	rest := strings.TrimLeft(trimmed, fence[:1])
+	return len(trimmed)-len(rest) >= len(fence) && strings.TrimSpace(rest) == ""
+}
+
+// infoLanguage 返回信息字符串中的语言，支持 {python} 和 python title="x" 这类写法
+func infoLanguage(info string) string {
+	fields := strings.Fields(strings.Trim(info, "{}"))
+	if len(fields) == 0 {
+		return ""
+	}
+	name := strings.TrimPrefix(strings.Trim(fields[0], "{},"), ".")
+	return langdetect.Normalize(name)
+}
+
+// finish 设置代码块的内容，没有标注语言时按内容识别
+func finish(b *Block, code string) Block {
+	b.Code = code
+	if b.Language == "" {
+		b.Language = langdetect.Detect("", []byte(code))
+	}
+	return *b
+}
+
+// notebook 是 Jupyter 笔记本（nbformat 4）中提取代码需要的部分
+type notebook struct {
+	Metadata struct {
+		Kernelspec struct {
+			Language string `json:"language"`
+		} `json:"kernelspec"`
+		LanguageInfo struct {
+			Name string `json:"name"`
+		} `json:"language_info"`
+	} `json:"metadata"`
+	Cells []struct {
+		CellType string          `json:"cell_type"`
+		Source   json.RawMessage `json:"source"` // 字符串或按行拆分的字符串数组
+	} `json:"cells"`
+}
+
+// magicLine 匹配 IPython 的魔法命令和 shell 转义，如 %pip install 和 !ls
+var magicLine = regexp.MustCompile(`(?m)^(\s*)([%!].*)$`)
+
+// ExtractNotebook 提取 Jupyter 笔记本中的代码单元格，语言取自笔记本的内核。
+// Python 单元格中的魔法命令和 shell 转义会被注释掉，以便用普通解释器执行
+func ExtractNotebook(data []byte) ([]Block, error) {
+	var nb notebook
+	if err := json.Unmarshal(data, &nb); err != nil {
+		return nil, err
+	}
+	if nb.Cells == nil {
+		return nil, errors.New("not a jupyter notebook: missing cells")
+	}
+	language := nb.Metadata.LanguageInfo.Name
+	if language == "" {
+		language = nb.Metadata.Kernelspec.Language
+	}
+	language = langdetect.Normalize(language)
+
+	var blocks []Block
+	for i, cell := range nb.Cells {
+		if cell.CellType != "code" {
+			continue
+		}
+		code, err := cellSource(cell.Source)
+		if err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(code) == "" {
+			continue
+		}
+		if language == "python" {
+			code = magicLine.ReplaceAllString(code, "$1# $2")
+		}
+		if !strings.HasSuffix(code, "\n") {
+			code += "\n"
+		}
+		blocks = append(blocks, Block{Language: language, Code: code, Cell: i + 1})
+	}
+	return blocks, nil
+}
+
+// cellSource 解析单元格的源码
+func cellSource(raw json.RawMessage) (string, error) {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s, nil
+	}
+	var lines []string
+	if err := json.Unmarshal(raw, &lines); err != nil {
+		return "", err
+	}
+	return strings.Join(lines, ""), nil
+}
+
+// Parse 提取文本中的代码块，文本是 Jupyter 笔记本的 JSON 时提取代码单元格，否则按 markdown 处理
+func Parse(text string) ([]Block, error) {
+	if trimmed := bytes.TrimSpace([]byte(text)); len(trimmed) > 0 && trimmed[0] == '{' && bytes.Contains(trimmed, []byte(`"cells"`)) {
+		return ExtractNotebook(trimmed)
+	}
+	return Extract(text), nil
+}
+
+// Runner 是执行一种语言的代码块的命令：代码写入 File 后执行 Command Args... 文件路径
+type Runner struct {
+	Command string   `json:"command"`
+	Args    []string `json:"args,omitempty"`
+	File    string   `json:"file"`
+
+	prepare func(code string) string
+}
+
+// Source 返回写入文件的代码
+func (r Runner) Source(code string) string {
+	if r.prepare != nil {
+		return r.prepare(code)
+	}
+	return code
+}
+
+// goPackage 匹配 Go 的包声明
+var goPackage = regexp.MustCompile(`(?m)^package \w+`)
+
+// runners 是可以执行的语言
+var runners = map[string]Runner{
+	"sh":         {Command: "sh", File: "main.sh"},
+	"python":     {Command: "python3", File: "main.py"},
+	"javascript": {Command: "node", File: "main.js"},
+	"typescript": {Command: "deno", Args: []string{"run"}, File: "main.ts"},
+	"ruby":       {Command: "ruby", File: "main.rb"},
+	"perl":       {Command: "perl", File: "main.pl"},
+	"lua":        {Command: "lua", File: "main.lua"},
+	"php":        {Command: "php", File: "main.php"},
+	"go": {Command: "go", Args: []string{"run"}, File: "main.go", prepare: func(code string) string {
+		// 聊天中的 Go 示例经常省略包声明
+		if !goPackage.MatchString(code) {
+			return "package main\n\n" + code
+		}
+		return code
+	}},
+}
+
+// RunnerFor 返回执行该语言代码块的命令，语言不支持执行时返回 false
+func RunnerFor(language string) (Runner, bool) {
+	r, ok := runners[language]
+	return r, ok
+}
@@ -0,0 +1,74 @@
+package codeblock
+
+import (
+	"testing"
+)
+
+func TestExtract(t *testing.T) {
+	markdown := "Here is an example:\n\n" +
+		"```py\nprint('hi')\n```\n\n" +
+		"  ~~~~ {.bash}\n  echo one\n  ```\n  echo two\n  ~~~~\n\n" +
+		"```\n#!/usr/bin/env ruby\nputs 1\n```\n" +
+		"Inline ```not a fence``` text\n" +
+		"```go\nfunc main() {}\n"
+	blocks := Extract(markdown)
+	if len(blocks) != 4 {
+		t.Fatalf("expected 4 blocks, got %+v", blocks)
+	}
+	want := []Block{
+		{Language: "python", Info: "py", Code: "print('hi')\n", Line: 3},
+		{Language: "sh", Info: "{.bash}", Code: "echo one\n```\necho two\n", Line: 7},
+		{Language: "ruby", Code: "#!/usr/bin/env ruby\nputs 1\n", Line: 13},
+		{Language: "go", Info: "go", Code: "func main() {}\n", Line: 18},
+	}
+	for i, w := range want {
+		if blocks[i] != w {
+			t.Errorf("block %d: expected %+v, got %+v", i, w, blocks[i])
+		}
+	}
+}
+
+func TestExtractNotebook(t *testing.T) {
+	data := `{
+		"metadata": {"kernelspec": {"language": "python"}},
+		"cells": [
+			{"cell_type": "markdown", "source": ["# Title\n"]},
+			{"cell_type": "code", "source": ["%pip install numpy\n", "x = 1\n", "print(x)"]},
+			{"cell_type": "code", "source": ""},
+			{"cell_type": "code", "source": "  !ls\n"}
+		]
+	}`
+	blocks, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blocks) != 2 {
+		t.Fatalf("expected 2 code cells, got %+v", blocks)
+	}
+	if b := blocks[0]; b.Language != "python" || b.Cell != 2 || b.Code != "# %pip install numpy\nx = 1\nprint(x)\n" {
+		t.Errorf("unexpected first cell %+v", b)
+	}
+	if b := blocks[1]; b.Cell != 4 || b.Code != "  # !ls\n" {
+		t.Errorf("unexpected second cell %+v", b)
+	}
+
+	if _, err := ExtractNotebook([]byte(`{"metadata": {}}`)); err == nil {
+		t.Error("expected error for JSON without cells")
+	}
+}
+
+func TestRunnerFor(t *testing.T) {
+	r, ok := RunnerFor("go")
+	if !ok || r.Command != "go" {
+		t.Fatalf("expected go runner, got %+v %v", r, ok)
+	}
+	if got := r.Source("func main() {}\n"); got != "package main\n\nfunc main() {}\n" {
+		t.Errorf("expected package clause to be added, got %q", got)
+	}
+	if got := r.Source("package demo\n"); got != "package demo\n" {
+		t.Errorf("expected source to be unchanged, got %q", got)
+	}
+	if _, ok := RunnerFor("markdown"); ok {
+		t.Error("expected markdown to have no runner")
+	}
+}
@@ -0,0 +1,198 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/liangsj/vimcoplit/internal/core/codeblock"
+	"github.com/liangsj/vimcoplit/internal/core/mcp"
+)
+
+// CodeBlockResult 是一个代码块的执行结果，Skipped 不为空时没有执行
+type CodeBlockResult struct {
+	codeblock.Block
+	Index    int    `json:"index"` // 代码块在文本中的序号，从 1 开始
+	Command  string `json:"command,omitempty"`
+	ExitCode int    `json:"exit_code"`
+	Stdout   string `json:"stdout,omitempty"`
+	Stderr   string `json:"stderr,omitempty"`
+	Error    string `json:"error,omitempty"`   // 命令无法执行的原因，如不在允许的命令列表中
+	Skipped  string `json:"skipped,omitempty"` // 跳过的原因，如语言不支持执行
+	Duration string `json:"duration,omitempty"`
+}
+
+// CodeBlockRun 是执行文本中代码块的结果，Markdown 是每个代码块后紧跟其输出的文本
+type CodeBlockRun struct {
+	Results  []*CodeBlockResult `json:"results"`
+	Markdown string             `json:"markdown"`
+}
+
+// runCodeBlocks 依次执行文本中的代码块，index 大于 0 时只执行该序号的代码块。
+// 每个代码块写入工作区下的临时目录，在工作区根目录以配置的沙箱后端执行，受输出过滤、允许的命令列表和命令超时限制
+func (s *serviceImpl) runCodeBlocks(ctx context.Context, text string, index int, timeout int64) (*CodeBlockRun, error) {
+	blocks, err := codeblock.Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	if index > len(blocks) {
+		return nil, fmt.Errorf("code block %d not found, the text has %d", index, len(blocks))
+	}
+	run := &CodeBlockRun{Results: []*CodeBlockResult{}}
+	for i, block := range blocks {
+		if index > 0 && i+1 != index {
+			continue
+		}
+		result := &CodeBlockResult{Block: block, Index: i + 1}
+		run.Results = append(run.Results, result)
+		if ctx.Err() != nil {
+			result.Skipped = ctx.Err().Error()
+			continue
+		}
+		runner, ok := codeblock.RunnerFor(block.Language)
+		if !ok {
+			result.Skipped = fmt.Sprintf("language %q cannot be executed", block.Language)
+			continue
+		}
+		s.runCodeBlock(ctx, runner, result, timeout)
+	}
+	run.Markdown = renderCodeBlockRun(run.Results)
+	return run, nil
+}
+
+// runCodeBlock 执行一个代码块，执行失败的原因记录在结果中
+func (s *serviceImpl) runCodeBlock(ctx context.Context, runner codeblock.Runner, result *CodeBlockResult, timeout int64) {
+	// 命令行只有解释器和临时文件名，代码块的内容需要单独经过输出过滤
+	if _, err := s.CheckOutput(ctx, result.Code); err != nil {
+		result.Error = err.Error()
+		return
+	}
+	root := s.cfg.WorkspaceRoot()
+	// 临时目录放在工作区内，容器后端只挂载工作区；以点开头的目录不会被索引
+	dir, err := os.MkdirTemp(root, ".vimcoplit-run-")
+	if err != nil {
+		result.Error = err.Error()
+		return
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, runner.File)
+	if err := os.WriteFile(file, []byte(runner.Source(result.Code)), 0o600); err != nil {
+		result.Error = err.Error()
+		return
+	}
+
+	args := append(slices.Clone(runner.Args), file)
+	result.Command = strings.Join(append([]string{runner.Command}, args...), " ")
+	start := time.Now()
+	out, err := s.ExecuteCommand(ctx, &Command{
+		Command:  runner.Command,
+		Args:     args,
+		WorkDir:  root,
+		Timeout:  timeout,
+		Metadata: map[string]string{"source": "code_block"},
+	})
+	result.Duration = time.Since(start).Round(time.Millisecond).String()
+	if err != nil {
+		result.Error = err.Error()
+		return
+	}
+	result.ExitCode = out.ExitCode
+	result.Stdout = truncateOutput(out.Stdout)
+	result.Stderr = truncateOutput(out.Stderr)
+}
+
+// renderCodeBlockRun 把执行结果渲染为 markdown，每个代码块后紧跟输出
+func renderCodeBlockRun(results []*CodeBlockResult) string {
+	var b strings.Builder
+	for i, r := range results {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fence := codeFence(r.Code)
+		fmt.Fprintf(&b, "%s%s\n%s%s\n", fence, r.Language, r.Code, fence)
+		switch {
+		case r.Skipped != "":
+			fmt.Fprintf(&b, "\n_Skipped: %s_\n", r.Skipped)
+		case r.Error != "":
+			fmt.Fprintf(&b, "\n_Error: %s_\n", r.Error)
+		default:
+			output := r.Stdout + r.Stderr
+			if output != "" && !strings.HasSuffix(output, "\n") {
+				output += "\n"
+			}
+			fence := codeFence(output)
+			fmt.Fprintf(&b, "\nOutput (exit code %d, %s):\n%stext\n%s%s\n", r.ExitCode, r.Duration, fence, output, fence)
+		}
+	}
+	return b.String()
+}
+
+// codeFence 返回比内容中最长的反引号序列更长的围栏
+func codeFence(content string) string {
+	longest, n := 0, 0
+	for _, c := range content {
+		if c == '`' {
+			n++
+			longest = max(longest, n)
+		} else {
+			n = 0
+		}
+	}
+	return strings.Repeat("`", max(3, longest+1))
+}
+
+// registerCodeBlockTool 注册执行 markdown、聊天消息或 Jupyter 笔记本中代码块的内置工具
+func registerCodeBlockTool(manager *mcp.Manager, s *serviceImpl) {
+	manager.RegisterBuiltinTool(&mcp.Tool{
+		ID:   "run_code_blocks",
+		Name: "run_code_blocks",
+		Description: "Extract fenced code blocks from markdown, a chat message or a Jupyter notebook and execute them " +
+			"(sh, python, javascript, typescript, ruby, perl, lua, php, go) in the command sandbox, returning each block followed by its output",
+		Parameters: []mcp.ToolParameter{{
+			Name:        "text",
+			Type:        "string",
+			Description: "markdown text or notebook JSON containing the code blocks",
+		}, {
+			Name:        "conversation_id",
+			Type:        "string",
+			Description: "conversation of the chat message to run, used with message_id instead of text",
+		}, {
+			Name:        "message_id",
+			Type:        "string",
+			Description: "chat message whose code blocks to run",
+		}, {
+			Name:        "index",
+			Type:        "number",
+			Description: "only run the code block with this 1-based index",
+		}, {
+			Name:        "timeout",
+			Type:        "number",
+			Description: "timeout per block in seconds, defaults to the command timeout",
+		}},
+	}, func(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+		text, _ := params["text"].(string)
+		if messageID, _ := params["message_id"].(string); messageID != "" {
+			conversationID, _ := params["conversation_id"].(string)
+			conv, err := s.GetConversation(ctx, conversationID)
+			if err != nil {
+				return nil, err
+			}
+			i := slices.IndexFunc(conv.Messages, func(m *Message) bool { return m.ID == messageID })
+			if i < 0 {
+				return nil, fmt.Errorf("message %s not found in conversation %s", messageID, conversationID)
+			}
+			text = conv.Messages[i].Content
+		}
+		if text == "" {
+			return nil, errors.New("text or message_id is required")
+		}
+		index, _ := params["index"].(float64)
+		timeout, _ := params["timeout"].(float64)
+		return s.runCodeBlocks(ctx, text, int(index), int64(timeout))
+	})
+}
@@ -0,0 +1,197 @@
+// Package command 实现聊天中的斜杠命令：内置的 /explain、/fix、/test、/commit，
+// 以及用户在工作区设置中定义、映射到提示词模板或 MCP 工具的命令
+package command
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+// namePattern 限制命令名称，避免把以 / 开头的路径当作命令
+var namePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]*$`)
+
+// targetTemplate 是内置命令的操作对象：命令参数，没有参数时引用当前编辑的文件
+const targetTemplate = `{{if .Args}}{{.Args}}{{else if .Path}}@file:"{{.Path}}"{{end}}`
+
+// commentTemplate 要求生成的代码注释使用配置的语言
+const commentTemplate = `{{with .CommentLanguage}} Write code comments in {{.}}.{{end}}`
+
+// Command 是一个斜杠命令，Template 和 Tool 必须且只能设置一个
+type Command struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description,omitempty"`
+	Template    string            `json:"template,omitempty"` // text/template 格式的提示词模板，可使用 .Args .Path .StagedDiff .CommentLanguage .CommitLanguage
+	Tool        string            `json:"tool,omitempty"`     // 直接执行的 MCP 工具 ID，工具结果作为回复
+	Params      map[string]string `json:"params,omitempty"`   // 工具参数，值为 text/template 格式，为空时传入 {"args": 命令参数}
+	Builtin     bool              `json:"builtin,omitempty"`
+}
+
+// builtins 是内置命令，可以被同名的用户命令覆盖
+var builtins = []Command{
+	{
+		Name:        "explain",
+		Description: "Explain code or a question",
+		Template: "Explain the following clearly: what it does, how it works and anything surprising.\n\n" +
+			targetTemplate,
+	},
+	{
+		Name:        "fix",
+		Description: "Find and fix a bug",
+		Template: "Find and fix the bug in the following. Briefly explain the cause, then give the fix as a unified diff." +
+			commentTemplate + "\n\n" + targetTemplate,
+	},
+	{
+		Name:        "test",
+		Description: "Write unit tests",
+		Template: "Write unit tests for the following, following the project's existing test conventions " +
+			"and covering edge cases and error paths." + commentTemplate + "\n\n" + targetTemplate,
+	},
+	{
+		Name:        "commit",
+		Description: "Write a commit message for the staged changes",
+		Template: "Write a git commit message for the following staged changes: a short imperative subject line " +
+			"under 72 characters, a blank line, then a brief body explaining what changed and why." +
+			"{{with .CommitLanguage}} Write the message in {{.}}.{{end}}{{with .Args}}\nAdditional instructions: {{.}}{{end}}\n\n{{.StagedDiff}}",
+	},
+}
+
+// Builtins 返回内置命令
+func Builtins() []Command {
+	list := make([]Command, len(builtins))
+	for i, c := range builtins {
+		c.Builtin = true
+		list[i] = c
+	}
+	return list
+}
+
+// Validate 检查命令是否有效
+func (c *Command) Validate() error {
+	if !namePattern.MatchString(c.Name) {
+		return fmt.Errorf("invalid command name %q", c.Name)
+	}
+	if (c.Template == "") == (c.Tool == "") {
+		return fmt.Errorf("command %s: exactly one of template and tool is required", c.Name)
+	}
+	if _, err := template.New(c.Name).Parse(c.Template); err != nil {
+		return fmt.Errorf("command %s: %v", c.Name, err)
+	}
+	for key, value := range c.Params {
+		if _, err := template.New(key).Parse(value); err != nil {
+			return fmt.Errorf("command %s: param %s: %v", c.Name, key, err)
+		}
+	}
+	return nil
+}
+
+// Data 是渲染命令模板的数据
+type Data struct {
+	Args string // 命令名称后的文本
+	Path string // 当前编辑的文件
+	Diff func() (string, error)
+
+	CommentLanguage string // 生成的代码注释使用的语言
+	CommitLanguage  string // 生成的提交信息使用的语言
+}
+
+// StagedDiff 返回工作区 git 仓库暂存的修改，只在模板使用时执行
+func (d *Data) StagedDiff() (string, error) {
+	if d.Diff == nil {
+		return "", errors.New("staged diff is unavailable")
+	}
+	return d.Diff()
+}
+
+// Render 渲染命令的提示词模板
+func (c *Command) Render(data *Data) (string, error) {
+	return render(c.Name, c.Template, data)
+}
+
+// ToolParams 渲染命令的工具参数
+func (c *Command) ToolParams(data *Data) (map[string]interface{}, error) {
+	if len(c.Params) == 0 {
+		return map[string]interface{}{"args": data.Args}, nil
+	}
+	params := make(map[string]interface{}, len(c.Params))
+	for key, value := range c.Params {
+		text, err := render(c.Name+"."+key, value, data)
+		if err != nil {
+			return nil, err
+		}
+		params[key] = text
+	}
+	return params, nil
+}
+
+// render 使用数据渲染模板
+func render(name, text string, data *Data) (string, error) {
+	tmpl, err := template.New(name).Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render command %s: %v", name, err)
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// Parse 拆分以斜杠命令开头的消息，返回命令名称和参数，不是命令时返回 false
+func Parse(text string) (string, string, bool) {
+	text = strings.TrimSpace(text)
+	rest, ok := strings.CutPrefix(text, "/")
+	if !ok {
+		return "", "", false
+	}
+	name, args := rest, ""
+	if i := strings.IndexFunc(rest, unicode.IsSpace); i >= 0 {
+		name, args = rest[:i], rest[i:]
+	}
+	if !namePattern.MatchString(name) {
+		return "", "", false
+	}
+	return name, strings.TrimSpace(args), true
+}
+
+// Registry 是可用的命令，同名时用户命令覆盖内置命令
+type Registry struct {
+	commands map[string]Command
+}
+
+// NewRegistry 合并内置命令和用户命令
+func NewRegistry(custom []Command) *Registry {
+	r := &Registry{commands: make(map[string]Command)}
+	for _, c := range Builtins() {
+		r.commands[c.Name] = c
+	}
+	for _, c := range custom {
+		c.Builtin = false
+		r.commands[c.Name] = c
+	}
+	return r
+}
+
+// Lookup 按名称查找命令
+func (r *Registry) Lookup(name string) (*Command, bool) {
+	c, ok := r.commands[name]
+	if !ok {
+		return nil, false
+	}
+	return &c, true
+}
+
+// List 按名称排序返回所有命令
+func (r *Registry) List() []Command {
+	list := make([]Command, 0, len(r.commands))
+	for _, c := range r.commands {
+		list = append(list, c)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
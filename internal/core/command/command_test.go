@@ -0,0 +1,122 @@
+package command
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	for text, want := range map[string][2]string{
+		"/test":                    {"test", ""},
+		"  /fix the nil check\n":   {"fix", "the nil check"},
+		"/explain\n@file:main.go":  {"explain", "@file:main.go"},
+		"/my-cmd_2 a  b":           {"my-cmd_2", "a  b"},
+		"/etc/passwd is readable?": {},
+		"/Test":                    {},
+		"explain /test":            {},
+		"/":                        {},
+	} {
+		name, args, ok := Parse(text)
+		if ok != (want[0] != "") || name != want[0] || args != want[1] {
+			t.Errorf("%q: expected %q %q, got %q %q %v", text, want[0], want[1], name, args, ok)
+		}
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry([]Command{
+		{Name: "commit", Template: "custom {{.Args}}"},
+		{Name: "lint", Tool: "golangci"},
+	})
+	list := r.List()
+	names := make([]string, len(list))
+	for i, c := range list {
+		names[i] = c.Name
+	}
+	if strings.Join(names, ",") != "commit,explain,fix,lint,test" {
+		t.Errorf("unexpected commands %v", names)
+	}
+	if c, _ := r.Lookup("commit"); c.Builtin || c.Template != "custom {{.Args}}" {
+		t.Errorf("expected user command to override builtin, got %+v", c)
+	}
+	if c, _ := r.Lookup("test"); !c.Builtin {
+		t.Errorf("expected builtin command, got %+v", c)
+	}
+	if _, ok := r.Lookup("missing"); ok {
+		t.Error("expected missing command not to be found")
+	}
+}
+
+func TestRender(t *testing.T) {
+	test, _ := NewRegistry(nil).Lookup("test")
+	prompt, err := test.Render(&Data{Path: "my dir/a.go"})
+	if err != nil || !strings.HasSuffix(prompt, `@file:"my dir/a.go"`) {
+		t.Errorf("expected current file mention without args, got %q %v", prompt, err)
+	}
+	prompt, _ = test.Render(&Data{Args: "@Parse", Path: "a.go"})
+	if !strings.HasSuffix(prompt, "\n\n@Parse") {
+		t.Errorf("expected args to replace the current file, got %q", prompt)
+	}
+
+	commit, _ := NewRegistry(nil).Lookup("commit")
+	calls := 0
+	diff := func() (string, error) {
+		calls++
+		return "diff --git a/a.go b/a.go", nil
+	}
+	prompt, err = commit.Render(&Data{Args: "mention the issue", Diff: diff})
+	if err != nil || calls != 1 || !strings.Contains(prompt, "Additional instructions: mention the issue\n\ndiff --git") {
+		t.Errorf("unexpected commit prompt %q %v", prompt, err)
+	}
+	if _, err := commit.Render(&Data{Diff: func() (string, error) { return "", errors.New("no staged changes") }}); err == nil ||
+		!strings.Contains(err.Error(), "no staged changes") {
+		t.Errorf("expected diff error, got %v", err)
+	}
+	if _, err := test.Render(&Data{}); err != nil {
+		t.Errorf("expected template without diff not to need it, got %v", err)
+	}
+
+	// 配置的生成语言分别约束代码注释和提交信息
+	data := &Data{Path: "a.go", Diff: diff, CommentLanguage: "English", CommitLanguage: "Chinese"}
+	prompt, _ = test.Render(data)
+	if !strings.Contains(prompt, "Write code comments in English.") || strings.Contains(prompt, "Chinese") {
+		t.Errorf("expected comment language instruction, got %q", prompt)
+	}
+	prompt, _ = commit.Render(data)
+	if !strings.Contains(prompt, "Write the message in Chinese.") || strings.Contains(prompt, "English") {
+		t.Errorf("expected commit language instruction, got %q", prompt)
+	}
+}
+
+func TestToolParams(t *testing.T) {
+	c := &Command{Name: "grep", Tool: "search"}
+	params, _ := c.ToolParams(&Data{Args: "TODO"})
+	if params["args"] != "TODO" || len(params) != 1 {
+		t.Errorf("expected args param by default, got %v", params)
+	}
+	c.Params = map[string]string{"query": "{{.Args}}", "path": "{{.Path}}"}
+	params, _ = c.ToolParams(&Data{Args: "TODO", Path: "a.go"})
+	if params["query"] != "TODO" || params["path"] != "a.go" {
+		t.Errorf("expected rendered params, got %v", params)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, c := range []Command{
+		{Name: "Bad", Template: "x"},
+		{Name: "both", Template: "x", Tool: "y"},
+		{Name: "neither"},
+		{Name: "broken", Template: "{{.Args"},
+		{Name: "params", Tool: "y", Params: map[string]string{"a": "{{"}},
+	} {
+		if err := c.Validate(); err == nil {
+			t.Errorf("expected %+v to be invalid", c)
+		}
+	}
+	for _, c := range Builtins() {
+		if err := c.Validate(); err != nil {
+			t.Errorf("builtin %s: %v", c.Name, err)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/liangsj/vimcoplit/internal/core/command"
+)
+
+// ErrInvalidCommand 表示斜杠命令无法展开，如模板渲染失败或没有暂存的修改
+var ErrInvalidCommand = errors.New("invalid command")
+
+// ListCommands 返回可用的聊天斜杠命令，包括内置命令和工作区设置中的用户命令
+func (s *serviceImpl) ListCommands(ctx context.Context) []command.Command {
+	return command.NewRegistry(s.settings.get().Commands).List()
+}
+
+// expandCommand 识别用户消息开头的斜杠命令，模板命令展开为提示词并记录原始输入，
+// 不是已注册的命令时原样发送，返回 nil
+func (s *serviceImpl) expandCommand(ctx context.Context, user *Message, path string) (*command.Command, *command.Data, error) {
+	name, args, ok := command.Parse(user.Content)
+	if !ok {
+		return nil, nil, nil
+	}
+	cmd, ok := command.NewRegistry(s.settings.get().Commands).Lookup(name)
+	if !ok {
+		return nil, nil, nil
+	}
+	data := &command.Data{
+		Args: args,
+		Path: path,
+		Diff: func() (string, error) { return s.stagedDiff(ctx) },
+
+		CommentLanguage: s.cfg.CommentLanguage(),
+		CommitLanguage:  s.cfg.CommitLanguage(),
+	}
+	user.Command = strings.TrimSpace(user.Content)
+	if cmd.Tool != "" {
+		return cmd, data, nil
+	}
+	content, err := cmd.Render(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidCommand, err)
+	}
+	user.Content = content
+	return cmd, data, nil
+}
+
+// runToolCommand 执行工具命令，工具结果作为唯一的回复
+func (s *serviceImpl) runToolCommand(ctx context.Context, cmd *command.Command, data *command.Data, parentID string) ([]*Message, error) {
+	params, err := cmd.ToolParams(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCommand, err)
+	}
+	result, err := s.GetMCPManager().ExecuteTool(ctx, cmd.Tool, params)
+	if err != nil {
+		return nil, err
+	}
+	if result.Error != "" {
+		return nil, fmt.Errorf("tool %s failed: %s", cmd.Tool, result.Error)
+	}
+	return []*Message{{
+		ID:        uuid.New().String(),
+		ParentID:  parentID,
+		Role:      MessageRoleAssistant,
+		Content:   result.Text(),
+		CreatedAt: time.Now(),
+	}}, nil
+}
+
+// stagedDiff 返回工作区 git 仓库暂存的修改
+func (s *serviceImpl) stagedDiff(ctx context.Context) (string, error) {
+	diff, err := runGitDiff(ctx, s.cfg.WorkspaceRoot(), "--cached")
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(diff) == "" {
+		return "", errors.New("no staged changes")
+	}
+	return diff, nil
+}
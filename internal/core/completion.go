@@ -0,0 +1,146 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/liangsj/vimcoplit/internal/core/completion"
+	"github.com/liangsj/vimcoplit/internal/core/langdetect"
+	"github.com/liangsj/vimcoplit/internal/core/window"
+	"github.com/liangsj/vimcoplit/internal/models"
+)
+
+// CompletionRequest 是行内补全（ghost text）请求
+type CompletionRequest struct {
+	Path     string `json:"path"`
+	Prefix   string `json:"prefix"`             // 光标前的内容
+	Suffix   string `json:"suffix"`             // 光标后的内容
+	Language string `json:"language,omitempty"` // 为空时按路径和内容识别
+
+	// 插件也可以发送整个缓冲区和光标位置，此时只取导入语句和光标所在的函数或类作为上下文
+	Content string `json:"content,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+
+	// Related 是自动加入的相关文件内容，模板中可以通过 .Related 使用
+	Related string `json:"-"`
+	// CommentLanguage 是补全的代码中注释使用的语言，模板中可以通过 .CommentLanguage 使用
+	CommentLanguage string `json:"-"`
+}
+
+// completionTemplate 是行内补全提示词模板的名称，用于按模板统计接受率
+const completionTemplate = "inline-v1"
+
+// Completion 是行内补全结果
+type Completion struct {
+	ID     string `json:"id"` // 上报接受或拒绝时使用的建议 ID
+	Text   string `json:"text"`
+	Cached bool   `json:"cached"` // 是否由缓存的补全裁剪得到，没有调用模型
+}
+
+// Complete 生成光标处的行内补全，继续输入与上次补全一致时复用缓存的结果
+func (s *serviceImpl) Complete(ctx context.Context, req *CompletionRequest) (*Completion, error) {
+	if req.Language == "" {
+		source := req.Content
+		if source == "" {
+			source = req.Prefix + req.Suffix
+		}
+		if lang := langdetect.Detect(req.Path, []byte(source)); lang != "" {
+			r := *req
+			r.Language = lang
+			req = &r
+		}
+	}
+	if req.Content != "" && req.Line > 0 {
+		w, err := window.Extract(req.Path, []byte(req.Content), req.Line, req.Column)
+		if err != nil {
+			return nil, err
+		}
+		r := *req
+		r.Prefix, r.Suffix, r.Content = w.Before, w.After, ""
+		if w.Imports != "" {
+			r.Prefix = w.Imports + "\n\n" + w.Before
+		}
+		req = &r
+	}
+
+	meta := SuggestionMeta{
+		Kind:     "completion",
+		Model:    string(s.GetCurrentModel()),
+		Template: completionTemplate,
+		Language: req.Language,
+	}
+
+	// 进行实验时按分组替换提示词模板或模型
+	assignment := s.experiments.Assign()
+	if assignment != nil {
+		meta.Experiment, meta.Variant = assignment.Experiment, assignment.Variant
+		if assignment.Model != "" {
+			meta.Model = assignment.Model
+		}
+		if assignment.HasTemplate() {
+			meta.Template = assignment.Experiment + "/" + assignment.Variant
+		}
+	}
+
+	key := completion.Key(meta.Model+":"+meta.Template+":"+req.Path, req.Suffix)
+	if text, ok := s.completions.Lookup(key, req.Prefix); ok {
+		meta.Cached = true
+		return &Completion{ID: s.feedback.track(meta), Text: text, Cached: true}, nil
+	}
+
+	// 只有需要调用模型时才收集相关文件
+	r := *req
+	r.Related = s.relatedContext(ctx, req.Path, s.settings.get().ContextBudget)
+	r.CommentLanguage = s.cfg.CommentLanguage()
+	req = &r
+	prompt := completionPrompt(req)
+	if rendered, ok, err := assignment.Render(req); err != nil {
+		return nil, err
+	} else if ok {
+		prompt = rendered
+	}
+	meta.PromptTokens = models.EstimateTokens(prompt)
+
+	start := time.Now()
+	var modelName string
+	if assignment != nil {
+		modelName = assignment.Model
+	}
+	output, err := s.generateWithModel(ctx, modelName, prompt)
+	if assignment != nil {
+		s.experiments.RecordRequest(assignment.Variant, time.Since(start), err != nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	text := strings.TrimSuffix(output, "\n")
+	s.completions.Store(key, req.Prefix, text)
+	return &Completion{ID: s.feedback.track(meta), Text: text}, nil
+}
+
+// completionPrompt 构造行内补全的提示词
+func completionPrompt(req *CompletionRequest) string {
+	var b strings.Builder
+	b.WriteString("Complete the code at <CURSOR>. Reply with only the text to insert, without explanation or code fences.\n")
+	if req.Path != "" {
+		fmt.Fprintf(&b, "File: %s\n", req.Path)
+	}
+	if req.Language != "" {
+		fmt.Fprintf(&b, "Language: %s\n", req.Language)
+	}
+	if req.CommentLanguage != "" {
+		fmt.Fprintf(&b, "Write any comments in %s.\n", req.CommentLanguage)
+	}
+	if req.Related != "" {
+		b.WriteString("\nRelated files:\n")
+		b.WriteString(req.Related)
+	}
+	b.WriteString("\n")
+	b.WriteString(req.Prefix)
+	b.WriteString("<CURSOR>")
+	b.WriteString(req.Suffix)
+	return b.String()
+}
@@ -0,0 +1,117 @@
+package completion
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cache 缓存最近的行内补全结果，键为缓冲区哈希，值为生成补全时光标前的内容和补全文本。
+// 用户继续输入与补全一致的内容时，直接裁剪缓存的补全返回，不必每次按键都调用模型
+type Cache struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+// entry 是一条缓存的补全
+type entry struct {
+	key        string
+	prefix     string
+	suggestion string
+	createdAt  time.Time
+}
+
+// NewCache 创建补全缓存，size 不大于 0 时不缓存，ttl 为 0 时不过期
+func NewCache(size int, ttl time.Duration) *Cache {
+	return &Cache{
+		size:    size,
+		ttl:     ttl,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// Key 根据文件路径和光标后的内容计算缓冲区哈希。
+// 继续输入只会改变光标前的内容，因此同一位置的连续请求得到相同的键
+func Key(path, suffix string) string {
+	sum := sha256.Sum256([]byte(path + "\x00" + suffix))
+	return hex.EncodeToString(sum[:])
+}
+
+// Lookup 查找可以复用的补全，返回与当前光标前内容衔接的剩余补全文本
+func (c *Cache) Lookup(key, prefix string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return "", false
+	}
+	e := elem.Value.(*entry)
+	if c.ttl > 0 && time.Since(e.createdAt) > c.ttl {
+		c.remove(elem)
+		return "", false
+	}
+
+	var remaining string
+	switch {
+	case strings.HasPrefix(prefix, e.prefix):
+		// 继续输入：已输入的部分必须与补全开头一致
+		typed := prefix[len(e.prefix):]
+		if !strings.HasPrefix(e.suggestion, typed) {
+			return "", false
+		}
+		remaining = e.suggestion[len(typed):]
+	case strings.HasPrefix(e.prefix, prefix):
+		// 退格：删除的内容重新作为补全的一部分
+		remaining = e.prefix[len(prefix):] + e.suggestion
+	default:
+		return "", false
+	}
+	if remaining == "" {
+		return "", false
+	}
+	c.order.MoveToFront(elem)
+	return remaining, true
+}
+
+// Store 缓存一条补全，超出容量时淘汰最久未使用的条目
+func (c *Cache) Store(key, prefix, suggestion string) {
+	if c.size <= 0 || suggestion == "" {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		c.remove(elem)
+	}
+	c.entries[key] = c.order.PushFront(&entry{
+		key:        key,
+		prefix:     prefix,
+		suggestion: suggestion,
+		createdAt:  time.Now(),
+	})
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+}
+
+// Len 返回缓存的条目数
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// remove 删除一条缓存，调用方需持有锁
+func (c *Cache) remove(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.entries, elem.Value.(*entry).key)
+}
@@ -0,0 +1,59 @@
+package completion
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCacheReusesPrefix(t *testing.T) {
+	c := NewCache(8, time.Minute)
+	key := Key("main.go", "\n}\n")
+	c.Store(key, "func main() {\n\tfmt.", "Println(\"hello\")")
+
+	tests := []struct {
+		prefix string
+		want   string
+		hit    bool
+	}{
+		{"func main() {\n\tfmt.", "Println(\"hello\")", true},
+		{"func main() {\n\tfmt.Print", "ln(\"hello\")", true},
+		{"func main() {\n\tfmt", ".Println(\"hello\")", true},
+		{"func main() {\n\tfmt.Sprint", "", false},
+		{"func main() {\n\tfmt.Println(\"hello\")", "", false},
+	}
+	for _, tt := range tests {
+		got, hit := c.Lookup(key, tt.prefix)
+		if hit != tt.hit || got != tt.want {
+			t.Errorf("Lookup(%q) = %q, %v; want %q, %v", tt.prefix, got, hit, tt.want, tt.hit)
+		}
+	}
+
+	if _, hit := c.Lookup(Key("other.go", "\n}\n"), "func main() {\n\tfmt."); hit {
+		t.Error("expected a different buffer to miss")
+	}
+}
+
+func TestCacheEviction(t *testing.T) {
+	c := NewCache(2, 0)
+	c.Store("a", "x", "1")
+	c.Store("b", "x", "2")
+	c.Lookup("a", "x")
+	c.Store("c", "x", "3")
+
+	if c.Len() != 2 {
+		t.Fatalf("expected 2 entries, got %d", c.Len())
+	}
+	if _, hit := c.Lookup("b", "x"); hit {
+		t.Error("expected least recently used entry to be evicted")
+	}
+	if _, hit := c.Lookup("a", "x"); !hit {
+		t.Error("expected recently used entry to be kept")
+	}
+
+	c = NewCache(2, time.Nanosecond)
+	c.Store("a", "x", "1")
+	time.Sleep(time.Millisecond)
+	if _, hit := c.Lookup("a", "x"); hit {
+		t.Error("expected expired entry to miss")
+	}
+}
@@ -0,0 +1,305 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/liangsj/vimcoplit/internal/core/merge"
+	"github.com/liangsj/vimcoplit/internal/core/textedit"
+	"github.com/liangsj/vimcoplit/internal/events"
+)
+
+// snapshotTTL 是文件快照的保留时间，启动时清理过期的快照
+const snapshotTTL = 7 * 24 * time.Hour
+
+// FileEdit 是基于某个版本计划的文件修改，BaseHash 为计划时文件内容的摘要，为空时直接写入。
+// Edits 不为空时忽略 Content，把多处范围修改一次应用到计划时的内容上
+type FileEdit struct {
+	Path     string          `json:"path"`
+	BaseHash string          `json:"base_hash"`
+	Content  string          `json:"content"`
+	Edits    []textedit.Edit `json:"edits,omitempty"`
+}
+
+// EditResult 是应用文件修改的结果
+type EditResult struct {
+	Path   string `json:"path"`
+	Hash   string `json:"hash"`   // 写入后文件内容的摘要
+	Merged bool   `json:"merged"` // 文件在计划后被修改过，已自动合并
+	Diff   string `json:"diff"`   // 写入前后内容的统一 diff
+}
+
+// FileConflict 是无法自动合并的文件修改，保存后等待用户处理
+type FileConflict struct {
+	ID        string           `json:"id"`
+	Path      string           `json:"path"`
+	BaseHash  string           `json:"base_hash"`
+	Current   string           `json:"current"` // 应用修改时磁盘上的内容
+	Edit      string           `json:"edit"`    // 计划写入的内容
+	Merged    string           `json:"merged"`  // 带冲突标记的合并结果
+	Hunks     []merge.Conflict `json:"hunks"`
+	CreatedAt time.Time        `json:"created_at"`
+}
+
+// ConflictError 表示文件在计划修改后被改动且无法自动合并，文件内容保持不变
+type ConflictError struct {
+	Conflict *FileConflict
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%s changed since the edit was planned: %d unresolved conflict(s), conflict id %s",
+		e.Conflict.Path, len(e.Conflict.Hunks), e.Conflict.ID)
+}
+
+// snapshotStore 按内容摘要保存文件快照，作为三方合并的共同祖先
+type snapshotStore struct {
+	dir string
+}
+
+// newSnapshotStore 创建快照存储，并清理过期的快照
+func newSnapshotStore(dir string) *snapshotStore {
+	s := &snapshotStore{dir: dir}
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		if info, err := e.Info(); err == nil && time.Since(info.ModTime()) > snapshotTTL {
+			os.Remove(filepath.Join(dir, e.Name()))
+		}
+	}
+	return s
+}
+
+// put 保存快照并返回摘要，相同内容只保存一次
+func (s *snapshotStore) put(content []byte) (string, error) {
+	hash := merge.Hash(content)
+	if len(content) == 0 {
+		return hash, nil
+	}
+	path := filepath.Join(s.dir, hash)
+	if _, err := os.Stat(path); err == nil {
+		now := time.Now()
+		os.Chtimes(path, now, now)
+		return hash, nil
+	}
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return "", err
+	}
+	return hash, os.WriteFile(path, content, 0644)
+}
+
+// get 读取快照
+func (s *snapshotStore) get(hash string) ([]byte, error) {
+	if hash == merge.Hash(nil) {
+		return nil, nil
+	}
+	data, err := os.ReadFile(filepath.Join(s.dir, filepath.Base(hash)))
+	if err != nil {
+		return nil, fmt.Errorf("snapshot %s not found", hash)
+	}
+	return data, nil
+}
+
+// conflictStore 是未解决冲突的持久化存储
+type conflictStore struct {
+	mu        sync.Mutex
+	path      string
+	conflicts map[string]*FileConflict
+}
+
+// newConflictStore 创建冲突存储，并加载未解决的冲突
+func newConflictStore(path string) *conflictStore {
+	s := &conflictStore{
+		path:      path,
+		conflicts: make(map[string]*FileConflict),
+	}
+	if data, err := os.ReadFile(path); err == nil {
+		json.Unmarshal(data, &s.conflicts)
+	}
+	return s
+}
+
+// save 保存到文件，调用方需持有锁
+func (s *conflictStore) save() error {
+	data, err := json.MarshalIndent(s.conflicts, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(s.path, data, 0644)
+}
+
+func (s *conflictStore) put(conflict *FileConflict) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.conflicts[conflict.ID] = conflict
+	return s.save()
+}
+
+func (s *conflictStore) get(id string) (*FileConflict, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	conflict, exists := s.conflicts[id]
+	if !exists {
+		return nil, errors.New("conflict not found")
+	}
+	return conflict, nil
+}
+
+func (s *conflictStore) delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.conflicts, id)
+	return s.save()
+}
+
+func (s *conflictStore) list() []*FileConflict {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := make([]*FileConflict, 0, len(s.conflicts))
+	for _, conflict := range s.conflicts {
+		list = append(list, conflict)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].CreatedAt.Before(list[j].CreatedAt)
+	})
+	return list
+}
+
+// TrackFile 读取文件并保存快照，返回内容和摘要，计划修改前调用，应用时据此检测冲突
+func (s *serviceImpl) TrackFile(ctx context.Context, path string) ([]byte, string, error) {
+	content, err := s.ReadFile(ctx, path)
+	if err != nil {
+		return nil, "", err
+	}
+	hash, err := s.snapshots.put(content)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to save snapshot: %v", err)
+	}
+	return content, hash, nil
+}
+
+// ReadSnapshot 返回 TrackFile 保存的摘要对应的内容，用于预览计划的修改相对计划时版本的 diff
+func (s *serviceImpl) ReadSnapshot(ctx context.Context, hash string) ([]byte, error) {
+	return s.snapshots.get(hash)
+}
+
+// EditFile 应用基于 BaseHash 版本计划的修改。文件在此之后被改动时以快照为共同祖先进行三方合并，
+// 合并干净则写入合并结果，否则保存冲突并返回 *ConflictError，不覆盖磁盘上的修改。只读上下文源中的文件不能修改
+func (s *serviceImpl) EditFile(ctx context.Context, edit *FileEdit) (*EditResult, error) {
+	if err := s.checkWritable(edit.Path); err != nil {
+		return nil, err
+	}
+	current, err := os.ReadFile(edit.Path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if len(edit.Edits) > 0 {
+		if edit, err = s.applyRanges(edit, current); err != nil {
+			return nil, err
+		}
+	}
+	content := []byte(edit.Content)
+	result := &EditResult{Path: edit.Path}
+	if edit.BaseHash != "" {
+		if merge.Hash(current) != edit.BaseHash {
+			base, err := s.snapshots.get(edit.BaseHash)
+			if err != nil {
+				return nil, err
+			}
+			merged := merge.Merge(string(base), string(current), edit.Content)
+			if len(merged.Conflicts) > 0 {
+				conflict := &FileConflict{
+					ID:        uuid.New().String(),
+					Path:      edit.Path,
+					BaseHash:  edit.BaseHash,
+					Current:   string(current),
+					Edit:      edit.Content,
+					Merged:    merged.Content,
+					Hunks:     merged.Conflicts,
+					CreatedAt: time.Now(),
+				}
+				if err := s.conflicts.put(conflict); err != nil {
+					return nil, err
+				}
+				return nil, &ConflictError{Conflict: conflict}
+			}
+			content = []byte(merged.Content)
+			result.Merged = true
+		}
+	}
+
+	if err := s.WriteFile(ctx, edit.Path, content); err != nil {
+		return nil, err
+	}
+	result.Hash = merge.Hash(content)
+	result.Diff = merge.Diff(diffPath(s.cfg.WorkspaceRoot(), edit.Path), string(current), string(content))
+	s.events.Publish(events.TypeDiff, result)
+	return result, nil
+}
+
+// applyRanges 把范围修改应用到计划时的内容上，返回等价的整文件修改，
+// 之后按整文件修改与计划后的改动合并
+func (s *serviceImpl) applyRanges(edit *FileEdit, current []byte) (*FileEdit, error) {
+	base := current
+	if edit.BaseHash != "" && merge.Hash(current) != edit.BaseHash {
+		var err error
+		if base, err = s.snapshots.get(edit.BaseHash); err != nil {
+			return nil, err
+		}
+	}
+	content, err := textedit.Apply(string(base), edit.Edits)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", edit.Path, err)
+	}
+	return &FileEdit{Path: edit.Path, BaseHash: edit.BaseHash, Content: content}, nil
+}
+
+// diffPath 返回 diff 中使用的文件名，工作区 root 内的文件使用相对路径
+func diffPath(root, path string) string {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+		return filepath.ToSlash(path)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// ListConflicts 列出未解决的冲突
+func (s *serviceImpl) ListConflicts(ctx context.Context) ([]*FileConflict, error) {
+	return s.conflicts.list(), nil
+}
+
+// GetConflict 获取冲突详情
+func (s *serviceImpl) GetConflict(ctx context.Context, id string) (*FileConflict, error) {
+	return s.conflicts.get(id)
+}
+
+// ResolveConflict 用用户确认的内容解决冲突，content 为 nil 时保留磁盘上的内容
+func (s *serviceImpl) ResolveConflict(ctx context.Context, id string, content *string) (*FileConflict, error) {
+	conflict, err := s.conflicts.get(id)
+	if err != nil {
+		return nil, err
+	}
+	if content != nil {
+		if err := s.WriteFile(ctx, conflict.Path, []byte(*content)); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.conflicts.delete(id); err != nil {
+		return nil, err
+	}
+	return conflict, nil
+}
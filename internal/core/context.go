@@ -1,7 +1,13 @@
 package core
 
 import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"log"
+	"os"
+	"path/filepath"
 	"sync"
 	"time"
 )
@@ -55,16 +61,37 @@ type ContextManager interface {
 	ListItems() []ContextItem
 }
 
-// Manager 是 ContextManager 接口的具体实现
+// Manager 是 ContextManager 接口的具体实现。设置了内容字节数上限时，
+// 超出上限后最久未使用的条目内容写入磁盘，再次读取时重新加载
 type Manager struct {
-	mu    sync.RWMutex
-	items map[string]ContextItem // key: id
+	mu       sync.Mutex
+	items    map[string]ContextItem // key: id，内容在内存中的条目
+	order    *list.List             // 内存中条目的 ID，最近使用的在前
+	elems    map[string]*list.Element
+	bytes    int64                       // 内存中条目内容的总字节数
+	spilled  map[string]*BaseContextItem // 内容已写入磁盘的条目，Value 为空
+	dir      string
+	maxBytes int64 // 不大于 0 时不限制
 }
 
 // NewManager 创建一个新的上下文管理器
 func NewManager() ContextManager {
+	return NewLimitedManager("", 0)
+}
+
+// NewLimitedManager 创建内存中条目内容不超过 maxBytes 字节的上下文管理器，
+// 超出的条目内容写入 dir。条目只在进程内有效，创建时清理 dir 中上次运行留下的内容
+func NewLimitedManager(dir string, maxBytes int64) *Manager {
+	if dir != "" && maxBytes > 0 {
+		os.RemoveAll(dir)
+	}
 	return &Manager{
-		items: make(map[string]ContextItem),
+		items:    make(map[string]ContextItem),
+		order:    list.New(),
+		elems:    make(map[string]*list.Element),
+		spilled:  make(map[string]*BaseContextItem),
+		dir:      dir,
+		maxBytes: maxBytes,
 	}
 }
 
@@ -72,38 +99,129 @@ func NewManager() ContextManager {
 func (m *Manager) AddItem(item ContextItem) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	m.items[item.GetID()] = item
+	m.remove(item.GetID())
+	m.admit(item)
+	m.evict()
 }
 
 // RemoveItem 删除一个上下文项
 func (m *Manager) RemoveItem(id string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	if _, ok := m.items[id]; !ok {
+	if !m.remove(id) {
 		return errors.New("context item not found")
 	}
-	delete(m.items, id)
 	return nil
 }
 
-// GetItem 查询一个上下文项
+// GetItem 查询一个上下文项，内容已写入磁盘时重新加载到内存
 func (m *Manager) GetItem(id string) (ContextItem, error) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-	item, ok := m.items[id]
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if item, ok := m.items[id]; ok {
+		m.order.MoveToFront(m.elems[id])
+		return item, nil
+	}
+	stub, ok := m.spilled[id]
 	if !ok {
 		return nil, errors.New("context item not found")
 	}
+	item, err := m.reload(stub)
+	if err != nil {
+		return nil, err
+	}
+	m.remove(id)
+	m.admit(item)
+	m.evict()
 	return item, nil
 }
 
-// ListItems 列出所有上下文项
+// ListItems 列出所有上下文项，内容在磁盘上的条目临时读取，不重新加载到内存
 func (m *Manager) ListItems() []ContextItem {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-	result := make([]ContextItem, 0, len(m.items))
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	result := make([]ContextItem, 0, len(m.items)+len(m.spilled))
 	for _, item := range m.items {
 		result = append(result, item)
 	}
+	for _, stub := range m.spilled {
+		item, err := m.reload(stub)
+		if err != nil {
+			log.Printf("读取上下文条目 %s 失败: %v\n", stub.ID, err)
+			continue
+		}
+		result = append(result, item)
+	}
 	return result
 }
+
+// LoadedBytes 返回内存中条目内容的总字节数和内容在磁盘上的条目数
+func (m *Manager) LoadedBytes() (bytes int64, spilled int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.bytes, len(m.spilled)
+}
+
+// admit 将条目加入内存，调用方需持有锁
+func (m *Manager) admit(item ContextItem) {
+	id := item.GetID()
+	m.items[id] = item
+	m.elems[id] = m.order.PushFront(id)
+	m.bytes += int64(len(item.GetValue()))
+}
+
+// remove 删除条目及其磁盘上的内容，条目不存在时返回 false，调用方需持有锁
+func (m *Manager) remove(id string) bool {
+	if item, ok := m.items[id]; ok {
+		m.bytes -= int64(len(item.GetValue()))
+		m.order.Remove(m.elems[id])
+		delete(m.elems, id)
+		delete(m.items, id)
+		return true
+	}
+	if _, ok := m.spilled[id]; ok {
+		os.Remove(m.spillPath(id))
+		delete(m.spilled, id)
+		return true
+	}
+	return false
+}
+
+// evict 在超出上限时将最久未使用的条目内容写入磁盘，调用方需持有锁
+func (m *Manager) evict() {
+	for m.maxBytes > 0 && m.bytes > m.maxBytes && m.order.Len() > 0 {
+		id := m.order.Back().Value.(string)
+		item := m.items[id]
+		if err := m.spill(item); err != nil {
+			log.Printf("上下文条目 %s 写入磁盘失败，保留在内存中: %v\n", id, err)
+			return
+		}
+		m.remove(id)
+		m.spilled[id] = &BaseContextItem{ID: id, Type: item.GetType(), CreatedAt: item.GetCreatedAt()}
+	}
+}
+
+// spill 将条目内容写入磁盘
+func (m *Manager) spill(item ContextItem) error {
+	if err := os.MkdirAll(m.dir, 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(m.spillPath(item.GetID()), []byte(item.GetValue()), 0600)
+}
+
+// reload 从磁盘读取条目内容，返回完整的条目
+func (m *Manager) reload(stub *BaseContextItem) (ContextItem, error) {
+	data, err := os.ReadFile(m.spillPath(stub.ID))
+	if err != nil {
+		return nil, err
+	}
+	item := *stub
+	item.Value = string(data)
+	return &item, nil
+}
+
+// spillPath 返回条目内容在磁盘上的路径，ID 来自请求，使用哈希作为文件名
+func (m *Manager) spillPath(id string) string {
+	sum := sha256.Sum256([]byte(id))
+	return filepath.Join(m.dir, hex.EncodeToString(sum[:]))
+}
@@ -0,0 +1,627 @@
+package core
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/liangsj/vimcoplit/internal/core/mention"
+	"github.com/liangsj/vimcoplit/internal/events"
+	"github.com/liangsj/vimcoplit/internal/models"
+)
+
+// maxAlternatives 是一次请求最多生成的候选回复数
+const maxAlternatives = 8
+
+// MessageRole 表示对话消息的角色
+type MessageRole string
+
+const (
+	MessageRoleUser      MessageRole = "user"
+	MessageRoleAssistant MessageRole = "assistant"
+)
+
+// Message 是对话中的一条消息，ParentID 为空表示根消息，同一父消息下的多条消息构成分支
+type Message struct {
+	ID        string                `json:"id"`
+	ParentID  string                `json:"parent_id,omitempty"`
+	Role      MessageRole           `json:"role"`
+	Content   string                `json:"content"`
+	Params    *GenerationParams     `json:"params,omitempty"`   // 助手消息生成时使用的参数
+	Mentions  []*mention.Attachment `json:"mentions,omitempty"` // 用户消息中的 @ 引用及其解析结果
+	Command   string                `json:"command,omitempty"`  // 用户消息由斜杠命令展开时的原始输入，Content 为展开后的提示词
+	CreatedAt time.Time             `json:"created_at"`
+}
+
+// GenerationParams 是一次生成实际使用的完整参数，随回复一起记录，
+// 用于复现满意的回复或比较不同参数下的输出
+type GenerationParams struct {
+	Model     models.ModelType `json:"model"`
+	MaxTokens int              `json:"max_tokens,omitempty"`
+	TopP      float64          `json:"top_p,omitempty"`
+	Stop      []string         `json:"stop,omitempty"`
+	models.Params
+}
+
+// Conversation 是以消息树保存的对话，Head 为主线末端的消息
+type Conversation struct {
+	ID        string     `json:"id"`
+	Title     string     `json:"title"`
+	Head      string     `json:"head,omitempty"`
+	Messages  []*Message `json:"messages"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// Branch 是从根消息到某个叶子消息的一条路径
+type Branch struct {
+	LeafID   string     `json:"leaf_id"`
+	Main     bool       `json:"main"` // 是否为主线
+	Messages []*Message `json:"messages"`
+}
+
+// MessageRequest 是在对话中发送消息的请求
+type MessageRequest struct {
+	ParentID    string `json:"parent_id"` // 为空时接在主线末端，指定更早的消息时创建分支
+	Content     string `json:"content"`
+	N           int    `json:"n"`                      // 候选回复数，默认 1
+	Path        string `json:"path,omitempty"`         // 当前编辑的文件，其相关文件会自动加入上下文
+	RawMentions bool   `json:"raw_mentions,omitempty"` // 为 true 时不解析 @ 引用，用于插件已自行展开引用的情况
+	models.Params
+}
+
+// message 根据 ID 查找消息
+func (c *Conversation) message(id string) *Message {
+	for _, m := range c.Messages {
+		if m.ID == id {
+			return m
+		}
+	}
+	return nil
+}
+
+// path 返回从根消息到指定消息的路径
+func (c *Conversation) path(id string) []*Message {
+	var path []*Message
+	for m := c.message(id); m != nil; m = c.message(m.ParentID) {
+		path = append([]*Message{m}, path...)
+	}
+	return path
+}
+
+// onMainLine 判断消息是否在主线上
+func (c *Conversation) onMainLine(id string) bool {
+	for _, m := range c.path(c.Head) {
+		if m.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// branches 返回所有分支，主线排在最前
+func (c *Conversation) branches() []*Branch {
+	parents := make(map[string]bool)
+	for _, m := range c.Messages {
+		parents[m.ParentID] = true
+	}
+
+	var branches []*Branch
+	for _, m := range c.Messages {
+		if parents[m.ID] {
+			continue
+		}
+		branches = append(branches, &Branch{
+			LeafID:   m.ID,
+			Main:     c.onMainLine(m.ID),
+			Messages: c.path(m.ID),
+		})
+	}
+	sort.SliceStable(branches, func(i, j int) bool {
+		return branches[i].Main && !branches[j].Main
+	})
+	return branches
+}
+
+// conversationPrompt 将对话路径拼接为提示词
+func conversationPrompt(path []*Message) string {
+	var b strings.Builder
+	for _, m := range path {
+		switch m.Role {
+		case MessageRoleUser:
+			fmt.Fprintf(&b, "User: %s\n\n", m.Content)
+		case MessageRoleAssistant:
+			fmt.Fprintf(&b, "Assistant: %s\n\n", m.Content)
+		}
+	}
+	b.WriteString("Assistant:")
+	return b.String()
+}
+
+// conversationStore 是对话的持久化存储，每个对话保存为目录中的一个 JSON 文件。
+// 内存中保留所有对话的摘要，完整对话只缓存最近使用的 maxCached 个，其余在需要时从磁盘加载
+type conversationStore struct {
+	mu        sync.Mutex
+	dir       string
+	maxCached int                      // 不大于 0 时不限制
+	summaries map[string]*Conversation // 不包含消息
+	order     *list.List               // 缓存的完整对话，最近使用的在前
+	cached    map[string]*list.Element
+}
+
+// newConversationStore 创建对话存储并加载已有对话的摘要。
+// legacy 是旧版本保存所有对话的单个文件，存在时拆分为每个对话一个文件
+func newConversationStore(dir, legacy string, maxCached int) *conversationStore {
+	s := &conversationStore{
+		dir:       dir,
+		maxCached: maxCached,
+		summaries: make(map[string]*Conversation),
+		order:     list.New(),
+		cached:    make(map[string]*list.Element),
+	}
+	s.migrate(legacy)
+
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".json" {
+			continue
+		}
+		conv, err := s.read(strings.TrimSuffix(e.Name(), ".json"))
+		if err != nil {
+			log.Printf("加载对话 %s 失败: %v\n", e.Name(), err)
+			continue
+		}
+		s.summaries[conv.ID] = summary(conv)
+	}
+	return s
+}
+
+// migrate 将旧版本单个文件中的对话拆分保存，全部成功后删除旧文件
+func (s *conversationStore) migrate(legacy string) {
+	data, err := os.ReadFile(legacy)
+	if err != nil {
+		return
+	}
+	var conversations map[string]*Conversation
+	if err := json.Unmarshal(data, &conversations); err != nil {
+		log.Printf("迁移对话失败: %v\n", err)
+		return
+	}
+	for _, conv := range conversations {
+		if err := s.write(conv); err != nil {
+			log.Printf("迁移对话失败: %v\n", err)
+			return
+		}
+	}
+	if err := os.Remove(legacy); err != nil {
+		log.Printf("删除旧的对话文件失败: %v\n", err)
+	}
+}
+
+// path 返回对话文件的路径
+func (s *conversationStore) path(id string) string {
+	return filepath.Join(s.dir, id+".json")
+}
+
+// read 从磁盘读取完整对话
+func (s *conversationStore) read(id string) (*Conversation, error) {
+	data, err := os.ReadFile(s.path(id))
+	if err != nil {
+		return nil, err
+	}
+	var conv Conversation
+	if err := json.Unmarshal(data, &conv); err != nil {
+		return nil, err
+	}
+	return &conv, nil
+}
+
+// write 将完整对话写入磁盘
+func (s *conversationStore) write(conv *Conversation) error {
+	data, err := json.MarshalIndent(conv, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path(conv.ID), data)
+}
+
+// load 返回完整对话，不在缓存中时从磁盘加载，调用方需持有锁且不能修改返回的对话
+func (s *conversationStore) load(id string) (*Conversation, error) {
+	if elem, ok := s.cached[id]; ok {
+		s.order.MoveToFront(elem)
+		return elem.Value.(*Conversation), nil
+	}
+	// 只加载摘要中存在的对话，id 来自请求，不能直接用于拼接路径
+	if _, ok := s.summaries[id]; !ok {
+		return nil, errors.New("conversation not found")
+	}
+	conv, err := s.read(id)
+	if err != nil {
+		return nil, err
+	}
+	s.cache(conv)
+	return conv, nil
+}
+
+// put 保存对话并放入缓存，调用方需持有锁
+func (s *conversationStore) put(conv *Conversation) error {
+	if err := s.write(conv); err != nil {
+		return err
+	}
+	s.summaries[conv.ID] = summary(conv)
+	s.cache(conv)
+	return nil
+}
+
+// cache 将对话放入缓存，超出容量时淘汰最久未使用的对话，调用方需持有锁
+func (s *conversationStore) cache(conv *Conversation) {
+	if elem, ok := s.cached[conv.ID]; ok {
+		elem.Value = conv
+		s.order.MoveToFront(elem)
+	} else {
+		s.cached[conv.ID] = s.order.PushFront(conv)
+	}
+	for s.maxCached > 0 && s.order.Len() > s.maxCached {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.cached, oldest.Value.(*Conversation).ID)
+	}
+}
+
+// get 返回对话的副本
+func (s *conversationStore) get(id string) (*Conversation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	conv, err := s.load(id)
+	if err != nil {
+		return nil, err
+	}
+	return copyConversation(conv), nil
+}
+
+// usage 返回对话总数和内存中缓存的完整对话数
+func (s *conversationStore) usage() (total, cached int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.summaries), s.order.Len()
+}
+
+// archive 将最后更新早于 before 的对话交给 put 归档，归档成功的对话从存储中删除
+func (s *conversationStore) archive(before time.Time, put func(conv *Conversation) error) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	archived := 0
+	for id, sum := range s.summaries {
+		if !sum.UpdatedAt.Before(before) {
+			continue
+		}
+		conv, err := s.load(id)
+		if err != nil {
+			return archived, err
+		}
+		if err := put(conv); err != nil {
+			return archived, err
+		}
+		if err := s.remove(id); err != nil {
+			return archived, err
+		}
+		archived++
+	}
+	return archived, nil
+}
+
+// remove 删除对话文件并移出摘要和缓存，调用方需持有锁
+func (s *conversationStore) remove(id string) error {
+	if err := os.Remove(s.path(id)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	delete(s.summaries, id)
+	if elem, ok := s.cached[id]; ok {
+		s.order.Remove(elem)
+		delete(s.cached, id)
+	}
+	return nil
+}
+
+// restore 放回归档的对话，更新时间设为现在，避免再次被归档
+func (s *conversationStore) restore(conv *Conversation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.summaries[conv.ID]; ok {
+		return errors.New("conversation already exists")
+	}
+	conv.UpdatedAt = time.Now()
+	return s.put(conv)
+}
+
+// summary 返回不包含消息的对话摘要
+func summary(conv *Conversation) *Conversation {
+	c := *conv
+	c.Messages = nil
+	return &c
+}
+
+// copyConversation 复制对话，消息本身创建后不再修改，可以共享
+func copyConversation(conv *Conversation) *Conversation {
+	c := *conv
+	c.Messages = append([]*Message(nil), conv.Messages...)
+	return &c
+}
+
+// CreateConversation 创建对话
+func (s *serviceImpl) CreateConversation(ctx context.Context, title string) (*Conversation, error) {
+	store := s.conversations
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	now := time.Now()
+	conv := &Conversation{
+		ID:        uuid.New().String(),
+		Title:     title,
+		Messages:  []*Message{},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := store.put(conv); err != nil {
+		return nil, err
+	}
+	return copyConversation(conv), nil
+}
+
+// GetConversation 获取对话
+func (s *serviceImpl) GetConversation(ctx context.Context, id string) (*Conversation, error) {
+	return s.conversations.get(id)
+}
+
+// ListConversations 按更新时间倒序列出对话，不包含消息
+func (s *serviceImpl) ListConversations(ctx context.Context) ([]*Conversation, error) {
+	store := s.conversations
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	result := make([]*Conversation, 0, len(store.summaries))
+	for _, conv := range store.summaries {
+		c := *conv
+		result = append(result, &c)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].UpdatedAt.After(result[j].UpdatedAt)
+	})
+	return result, nil
+}
+
+// SendMessage 在 ParentID 之后添加用户消息并生成 N 条候选回复，返回生成的回复。
+// 接在主线末端时主线前进到第一条回复，否则作为新分支保存，主线不变
+func (s *serviceImpl) SendMessage(ctx context.Context, convID string, req *MessageRequest) ([]*Message, error) {
+	conv, err := s.conversations.get(convID)
+	if err != nil {
+		return nil, err
+	}
+	parentID := req.ParentID
+	if parentID == "" {
+		parentID = conv.Head
+	} else if conv.message(parentID) == nil {
+		return nil, fmt.Errorf("message %s not found in conversation", parentID)
+	}
+
+	user := &Message{
+		ID:        uuid.New().String(),
+		ParentID:  parentID,
+		Role:      MessageRoleUser,
+		Content:   req.Content,
+		CreatedAt: time.Now(),
+	}
+	cmd, data, err := s.expandCommand(ctx, user, req.Path)
+	if err != nil {
+		return nil, err
+	}
+	var replies []*Message
+	if cmd != nil && cmd.Tool != "" {
+		replies, err = s.runToolCommand(ctx, cmd, data, user.ID)
+	} else {
+		replies, err = s.conversationReplies(ctx, conv, user, req)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	store := s.conversations
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	loaded, err := store.load(convID)
+	if err != nil {
+		return nil, err
+	}
+	current := copyConversation(loaded)
+	current.Messages = append(current.Messages, user)
+	current.Messages = append(current.Messages, replies...)
+	if parentID == current.Head {
+		current.Head = replies[0].ID
+	}
+	current.UpdatedAt = time.Now()
+	if err := store.put(current); err != nil {
+		return nil, err
+	}
+	s.events.Publish(events.TypeChat, map[string]interface{}{
+		"conversation_id": convID,
+		"request":         req,
+		"mentions":        user.Mentions,
+		"replies":         replies,
+	})
+	if current.Title == "" {
+		go s.titleConversation(convID, user.Content)
+	}
+	return replies, nil
+}
+
+// conversationReplies 为用户消息生成候选回复，提示词依次包含仓库地图、相关文件、引用的内容和对话历史
+func (s *serviceImpl) conversationReplies(ctx context.Context, conv *Conversation, user *Message, req *MessageRequest) ([]*Message, error) {
+	prompt := conversationPrompt(append(conv.path(user.ParentID), user))
+	repoMap := s.repoMapContext(ctx)
+	budget := s.settings.get().ContextBudget
+	if budget > 0 {
+		// 仓库地图占用的部分从上下文预算中扣除，剩余的留给相关文件
+		budget = max(budget-models.EstimateTokens(repoMap), 1)
+	}
+	// 显式引用的内容优先于自动选择的相关文件
+	var mentioned string
+	if !req.RawMentions {
+		if mentions := mention.Parse(user.Content); len(mentions) > 0 {
+			mentioned, user.Mentions = s.mentionResolver().Resolve(ctx, mentions, budget)
+			if budget > 0 {
+				budget = max(budget-models.EstimateTokens(mentioned), 1)
+			}
+		}
+	}
+	if mentioned != "" {
+		prompt = "Mentioned context:\n" + mentioned + "\n" + prompt
+	}
+	if related := s.relatedContext(ctx, req.Path, budget); related != "" {
+		prompt = "Related files:\n" + related + "\n" + prompt
+	}
+	if repoMap != "" {
+		prompt = "Repository map:\n" + repoMap + "\n" + prompt
+	}
+	ctx, done := s.BeginGeneration(models.WithParams(ctx, req.Params), conversationGenerationID(conv.ID), "conversation")
+	outputs, params, err := s.GenerateAlternatives(ctx, prompt, req.N)
+	done(err)
+	if err != nil {
+		return nil, err
+	}
+	replies := make([]*Message, 0, len(outputs))
+	for i, output := range outputs {
+		replies = append(replies, &Message{
+			ID:        uuid.New().String(),
+			ParentID:  user.ID,
+			Role:      MessageRoleAssistant,
+			Content:   output,
+			Params:    params[i],
+			CreatedAt: time.Now(),
+		})
+	}
+	return replies, nil
+}
+
+// ListBranches 列出对话的所有分支，主线排在最前
+func (s *serviceImpl) ListBranches(ctx context.Context, convID string) ([]*Branch, error) {
+	conv, err := s.conversations.get(convID)
+	if err != nil {
+		return nil, err
+	}
+	return conv.branches(), nil
+}
+
+// PromoteBranch 将指定消息设为主线末端，之后的消息默认接在它后面
+func (s *serviceImpl) PromoteBranch(ctx context.Context, convID, messageID string) (*Conversation, error) {
+	store := s.conversations
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	loaded, err := store.load(convID)
+	if err != nil {
+		return nil, err
+	}
+	if loaded.message(messageID) == nil {
+		return nil, fmt.Errorf("message %s not found in conversation", messageID)
+	}
+	conv := copyConversation(loaded)
+	conv.Head = messageID
+	conv.UpdatedAt = time.Now()
+	if err := store.put(conv); err != nil {
+		return nil, err
+	}
+	return copyConversation(conv), nil
+}
+
+// CancelMessage 取消对话中正在生成的回复
+func (s *serviceImpl) CancelMessage(ctx context.Context, convID string) (*GenerationRecord, error) {
+	return s.CancelGeneration(ctx, conversationGenerationID(convID))
+}
+
+// conversationGenerationID 返回对话回复生成请求的 ID，每个对话同时只有一个进行中的回复
+func conversationGenerationID(convID string) string {
+	return "conversation:" + convID
+}
+
+// GenerateAlternatives 对同一提示词重复采样，生成 n 条候选回复并返回每条回复使用的参数。
+// 采样参数从 ctx 中读取，指定种子时第 i 条候选使用种子加 i，整组候选可以完整复现
+func (s *serviceImpl) GenerateAlternatives(ctx context.Context, prompt string, n int) ([]string, []*GenerationParams, error) {
+	if n <= 0 {
+		n = 1
+	}
+	if n > maxAlternatives {
+		return nil, nil, fmt.Errorf("at most %d alternatives are allowed", maxAlternatives)
+	}
+	base, err := s.generationParams(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	end, err := s.beginSession()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer end()
+
+	outputs := make([]string, n)
+	params := make([]*GenerationParams, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		p := *base
+		p.Params = base.Params.WithOffset(int64(i))
+		params[i] = &p
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			outputs[i], errs[i] = s.GenerateResponse(models.WithParams(ctx, params[i].Params), prompt)
+		}(i)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return outputs, params, nil
+}
+
+// generationParams 用当前模型和配置中该模型的默认值补全 ctx 中的采样参数
+func (s *serviceImpl) generationParams(ctx context.Context) (*GenerationParams, error) {
+	s.mu.RLock()
+	model := s.model
+	s.mu.RUnlock()
+	if model == nil {
+		return nil, errors.New("no AI model configured")
+	}
+
+	defaults := s.cfg.ModelDefaults(model.GetModelType())
+	params, err := models.ParamsFrom(ctx).Resolve(model.GetModelType(), defaults.Temperature)
+	if err != nil {
+		return nil, err
+	}
+	return &GenerationParams{
+		Model:     model.GetModelType(),
+		MaxTokens: defaults.MaxTokens,
+		TopP:      defaults.TopP,
+		Stop:      defaults.Stop,
+		Params:    params,
+	}, nil
+}
@@ -0,0 +1,124 @@
+package core
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/liangsj/vimcoplit/internal/models"
+)
+
+func TestConversationBranches(t *testing.T) {
+	// u1 ─ a1 ─ u2 ─ a2        主线
+	//         └ u3 ─ a3 / a4   从 a1 分出的分支，两条候选回复
+	conv := &Conversation{Head: "a2", Messages: []*Message{
+		{ID: "u1", Role: MessageRoleUser},
+		{ID: "a1", ParentID: "u1", Role: MessageRoleAssistant},
+		{ID: "u2", ParentID: "a1", Role: MessageRoleUser},
+		{ID: "a2", ParentID: "u2", Role: MessageRoleAssistant},
+		{ID: "u3", ParentID: "a1", Role: MessageRoleUser},
+		{ID: "a3", ParentID: "u3", Role: MessageRoleAssistant},
+		{ID: "a4", ParentID: "u3", Role: MessageRoleAssistant},
+	}}
+
+	tests := []struct {
+		leaf string
+		main bool
+		path string
+	}{
+		{"a2", true, "u1 a1 u2 a2"},
+		{"a3", false, "u1 a1 u3 a3"},
+		{"a4", false, "u1 a1 u3 a4"},
+	}
+	branches := conv.branches()
+	if len(branches) != len(tests) {
+		t.Fatalf("expected %d branches, got %d", len(tests), len(branches))
+	}
+	for i, tt := range tests {
+		b := branches[i]
+		if b.LeafID != tt.leaf || b.Main != tt.main || messageIDs(b.Messages) != tt.path {
+			t.Errorf("branch %d: expected %s main=%v %q, got %s main=%v %q",
+				i, tt.leaf, tt.main, tt.path, b.LeafID, b.Main, messageIDs(b.Messages))
+		}
+	}
+
+	for id, want := range map[string]bool{"u1": true, "a1": true, "a2": true, "u3": false, "a4": false, "missing": false} {
+		if got := conv.onMainLine(id); got != want {
+			t.Errorf("onMainLine(%s) = %v, want %v", id, got, want)
+		}
+	}
+}
+
+func TestSendMessageBranching(t *testing.T) {
+	s, model := newTestService(t,
+		models.MockResponse{Output: "first"},
+		models.MockResponse{Output: "second"},
+		models.MockResponse{Output: "alt one"},
+		models.MockResponse{Output: "alt two"},
+	)
+	ctx := context.Background()
+	conv, err := s.CreateConversation(ctx, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	send := func(req *MessageRequest) []*Message {
+		t.Helper()
+		replies, err := s.SendMessage(ctx, conv.ID, req)
+		if err != nil {
+			t.Fatalf("SendMessage: %v", err)
+		}
+		return replies
+	}
+	first := send(&MessageRequest{Content: "hello"})
+	second := send(&MessageRequest{Content: "again"})
+
+	// 从第一条回复分出分支，请求两条候选回复，主线不变
+	alternatives := send(&MessageRequest{ParentID: first[0].ID, Content: "instead", N: 2})
+	// 候选回复并发生成，顺序不固定
+	if len(alternatives) != 2 || alternatives[0].Content+alternatives[1].Content != "alt onealt two" && alternatives[0].Content+alternatives[1].Content != "alt twoalt one" {
+		t.Fatalf("unexpected alternatives %+v", alternatives)
+	}
+	if alternatives[0].ParentID != alternatives[1].ParentID {
+		t.Error("expected the alternatives to answer the same message")
+	}
+	if prompts := model.Prompts(); len(prompts) != 4 || strings.Contains(prompts[2], "again") {
+		t.Errorf("expected the branch prompt to leave out the main line after its parent, got %q", prompts[2])
+	}
+	got, _ := s.GetConversation(ctx, conv.ID)
+	if got.Head != second[0].ID {
+		t.Errorf("expected the head to stay on the main line, got %s", got.Head)
+	}
+
+	branches, err := s.ListBranches(ctx, conv.ID)
+	if err != nil || len(branches) != 3 || branches[0].LeafID != second[0].ID {
+		t.Fatalf("unexpected branches %+v %v", branches, err)
+	}
+
+	// 提升分支后新消息接在分支末端
+	if _, err := s.PromoteBranch(ctx, conv.ID, alternatives[1].ID); err != nil {
+		t.Fatal(err)
+	}
+	model.Push(models.MockResponse{Output: "continued"})
+	next := send(&MessageRequest{Content: "go on"})
+	got, _ = s.GetConversation(ctx, conv.ID)
+	if got.Head != next[0].ID || !got.onMainLine(alternatives[1].ID) || got.onMainLine(second[0].ID) {
+		t.Errorf("expected the promoted branch to become the main line, head %s", got.Head)
+	}
+
+	if _, err := s.PromoteBranch(ctx, conv.ID, "missing"); err == nil {
+		t.Error("expected promoting an unknown message to fail")
+	}
+	if _, err := s.SendMessage(ctx, conv.ID, &MessageRequest{ParentID: "missing", Content: "x"}); err == nil {
+		t.Error("expected branching from an unknown message to fail")
+	}
+}
+
+// messageIDs 以空格连接消息 ID
+func messageIDs(messages []*Message) string {
+	ids := make([]string, len(messages))
+	for i, m := range messages {
+		ids[i] = m.ID
+	}
+	return strings.Join(ids, " ")
+}
@@ -0,0 +1,74 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/liangsj/vimcoplit/internal/core/dbquery"
+	"github.com/liangsj/vimcoplit/internal/core/mcp"
+)
+
+// registerDatabaseTools 注册查询工作区数据库的内置工具：sql_schema 查看表结构，sql_query 执行语句。
+// 数据库在工作区设置的 databases 中配置，默认只读
+func registerDatabaseTools(manager *mcp.Manager, pool *dbquery.Pool) {
+	databaseParam := mcp.ToolParameter{
+		Name:        "database",
+		Type:        "string",
+		Description: "name of a database configured in the workspace settings",
+		Required:    true,
+	}
+	database := func(params map[string]interface{}) (string, error) {
+		name, _ := params["database"].(string)
+		if name == "" {
+			return "", errors.New("database is required, configured databases: " + strings.Join(pool.Names(), ", "))
+		}
+		return name, nil
+	}
+
+	manager.RegisterBuiltinTool(&mcp.Tool{
+		ID:          "sql_schema",
+		Name:        "sql_schema",
+		Description: "List the tables of a workspace database, or the columns of one table",
+		Parameters: []mcp.ToolParameter{databaseParam, {
+			Name:        "table",
+			Type:        "string",
+			Description: "table whose columns to list, omit to list tables",
+		}},
+	}, func(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+		name, err := database(params)
+		if err != nil {
+			return nil, err
+		}
+		table, _ := params["table"].(string)
+		return pool.Schema(ctx, name, table)
+	})
+
+	manager.RegisterBuiltinTool(&mcp.Tool{
+		ID:   "sql_query",
+		Name: "sql_query",
+		Description: "Run a single SQL statement against a workspace database and return the columns and rows. " +
+			"Databases are read-only unless configured otherwise: only SELECT, WITH, EXPLAIN, SHOW, DESCRIBE and read-only PRAGMA statements are accepted",
+		Parameters: []mcp.ToolParameter{databaseParam, {
+			Name:        "query",
+			Type:        "string",
+			Description: "SQL statement in the database's dialect",
+			Required:    true,
+		}, {
+			Name:        "max_rows",
+			Type:        "number",
+			Description: "maximum number of rows to return, defaults to the database's max_rows",
+		}},
+	}, func(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+		name, err := database(params)
+		if err != nil {
+			return nil, err
+		}
+		query, _ := params["query"].(string)
+		if query == "" {
+			return nil, errors.New("query is required")
+		}
+		maxRows, _ := params["max_rows"].(float64)
+		return pool.Query(ctx, name, query, int(maxRows))
+	})
+}
@@ -0,0 +1,308 @@
+// Package dbquery 让 agent 查询工作区配置的数据库（PostgreSQL、MySQL、SQLite），用于编写数据访问代码时查看表结构和数据。
+// 连接串从环境变量或文件读取，不保存在工作区设置中；数据库默认只读，语句先经过解析，只有单条只读语句才会执行，
+// 并在只读事务（SQLite 为 query_only）中执行。驱动通过 database/sql 注册，默认构建不包含驱动，由编译 vimcoplit 或嵌入它的程序导入需要的驱动
+package dbquery
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrDriverUnavailable 表示当前构建没有注册对应数据库的驱动
+var ErrDriverUnavailable = errors.New("database driver is not available")
+
+// 查询的限制
+const (
+	DefaultMaxRows = 100
+	MaxRows        = 1000
+	DefaultTimeout = 30 * time.Second
+)
+
+// driverNames 是每种数据库可以使用的 database/sql 驱动名，按顺序使用第一个已注册的驱动
+var driverNames = map[string][]string{
+	"postgres": {"pgx", "postgres"},
+	"mysql":    {"mysql"},
+	"sqlite":   {"sqlite", "sqlite3"},
+}
+
+// namePattern 是数据库名称的格式
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Database 是工作区配置的一个数据库，DSNEnv 和 DSNFile 只能设置一个
+type Database struct {
+	Name      string `json:"name"`
+	Driver    string `json:"driver"`               // postgres、mysql 或 sqlite
+	DSNEnv    string `json:"dsn_env,omitempty"`    // 保存连接串的环境变量
+	DSNFile   string `json:"dsn_file,omitempty"`   // 保存连接串的文件，相对路径相对工作区根目录
+	ReadWrite bool   `json:"read_write,omitempty"` // 允许执行修改数据的语句，默认只读
+	MaxRows   int    `json:"max_rows,omitempty"`   // 每次查询最多返回的行数，0 表示 100
+}
+
+// Validate 检查数据库配置是否有效，不检查连接串是否存在
+func (d *Database) Validate() error {
+	if !namePattern.MatchString(d.Name) {
+		return fmt.Errorf("invalid database name %q", d.Name)
+	}
+	if _, ok := driverNames[d.Driver]; !ok {
+		return fmt.Errorf("database %q: unsupported driver %q, expected postgres, mysql or sqlite", d.Name, d.Driver)
+	}
+	if (d.DSNEnv == "") == (d.DSNFile == "") {
+		return fmt.Errorf("database %q: exactly one of dsn_env and dsn_file is required", d.Name)
+	}
+	if d.MaxRows < 0 || d.MaxRows > MaxRows {
+		return fmt.Errorf("database %q: max_rows must be between 0 and %d", d.Name, MaxRows)
+	}
+	return nil
+}
+
+// dsn 读取连接串，root 为工作区根目录
+func (d *Database) dsn(root string) (string, error) {
+	if d.DSNEnv != "" {
+		dsn := os.Getenv(d.DSNEnv)
+		if dsn == "" {
+			return "", fmt.Errorf("database %q: environment variable %s is not set", d.Name, d.DSNEnv)
+		}
+		return dsn, nil
+	}
+	path := d.DSNFile
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(root, path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("database %q: cannot read dsn_file: %v", d.Name, err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// driverName 返回已注册的驱动名
+func driverName(dialect string) (string, error) {
+	registered := sql.Drivers()
+	for _, name := range driverNames[dialect] {
+		if slices.Contains(registered, name) {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("%w: no %s driver is compiled in", ErrDriverUnavailable, dialect)
+}
+
+// Result 是查询的结果，Truncated 表示还有更多的行没有返回
+type Result struct {
+	Columns   []string        `json:"columns"`
+	Rows      [][]interface{} `json:"rows"`
+	Truncated bool            `json:"truncated,omitempty"`
+	Duration  string          `json:"duration"`
+}
+
+// conn 是一个已打开的数据库
+type conn struct {
+	config Database
+	db     *sql.DB
+}
+
+// Pool 按名称管理工作区数据库的连接，连接在第一次查询时打开
+type Pool struct {
+	mu        sync.Mutex
+	root      string
+	databases map[string]Database
+	conns     map[string]*conn
+}
+
+// NewPool 创建连接池，root 为工作区根目录
+func NewPool(root string) *Pool {
+	return &Pool{root: root, databases: make(map[string]Database), conns: make(map[string]*conn)}
+}
+
+// Set 替换配置的数据库，关闭已删除或配置改变的数据库的连接
+func (p *Pool) Set(databases []Database) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.databases = make(map[string]Database, len(databases))
+	for _, d := range databases {
+		p.databases[d.Name] = d
+	}
+	for name, c := range p.conns {
+		if d, ok := p.databases[name]; !ok || d != c.config {
+			c.db.Close()
+			delete(p.conns, name)
+		}
+	}
+}
+
+// Names 返回配置的数据库名称
+func (p *Pool) Names() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	names := make([]string, 0, len(p.databases))
+	for name := range p.databases {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Close 关闭所有连接
+func (p *Pool) Close() {
+	p.Set(nil)
+}
+
+// open 返回数据库的配置和连接，没有打开时打开
+func (p *Pool) open(name string) (Database, *sql.DB, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	d, ok := p.databases[name]
+	if !ok {
+		return Database{}, nil, fmt.Errorf("database %q is not configured", name)
+	}
+	if c, ok := p.conns[name]; ok {
+		return d, c.db, nil
+	}
+	driver, err := driverName(d.Driver)
+	if err != nil {
+		return d, nil, err
+	}
+	dsn, err := d.dsn(p.root)
+	if err != nil {
+		return d, nil, err
+	}
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		// 驱动的错误可能包含连接串，不原样返回
+		return d, nil, fmt.Errorf("database %q: cannot open connection", name)
+	}
+	db.SetMaxOpenConns(2)
+	p.conns[name] = &conn{config: d, db: db}
+	return d, db, nil
+}
+
+// Query 在数据库中执行语句，maxRows 不大于 0 时使用数据库配置的行数。
+// 只读的数据库只执行通过 CheckReadOnly 的语句
+func (p *Pool) Query(ctx context.Context, name, query string, maxRows int) (*Result, error) {
+	d, db, err := p.open(name)
+	if err != nil {
+		return nil, err
+	}
+	if !d.ReadWrite {
+		if err := CheckReadOnly(d.Driver, query); err != nil {
+			return nil, err
+		}
+	}
+	if maxRows <= 0 {
+		maxRows = d.MaxRows
+	}
+	if maxRows <= 0 {
+		maxRows = DefaultMaxRows
+	}
+	return run(ctx, db, d, min(maxRows, MaxRows), query)
+}
+
+// Schema 列出数据库中的表，table 不为空时列出该表的列
+func (p *Pool) Schema(ctx context.Context, name, table string) (*Result, error) {
+	d, db, err := p.open(name)
+	if err != nil {
+		return nil, err
+	}
+	query, args := schemaQuery(d.Driver, table)
+	return run(ctx, db, d, MaxRows, query, args...)
+}
+
+// schemaQuery 返回列出表或列的查询
+func schemaQuery(dialect, table string) (string, []interface{}) {
+	switch {
+	case dialect == "sqlite" && table == "":
+		return "SELECT name, type FROM sqlite_master WHERE type IN ('table', 'view') AND name NOT LIKE 'sqlite_%' ORDER BY name", nil
+	case dialect == "sqlite":
+		return `SELECT name, type, "notnull" = 0 AS nullable, dflt_value AS "default", pk FROM pragma_table_info(?) ORDER BY cid`, []interface{}{table}
+	case dialect == "postgres" && table == "":
+		return "SELECT table_schema, table_name, table_type FROM information_schema.tables " +
+			"WHERE table_schema NOT IN ('pg_catalog', 'information_schema') ORDER BY table_schema, table_name", nil
+	case dialect == "postgres":
+		return "SELECT column_name, data_type, is_nullable, column_default FROM information_schema.columns " +
+			"WHERE table_name = $1 AND table_schema NOT IN ('pg_catalog', 'information_schema') ORDER BY ordinal_position", []interface{}{table}
+	case table == "":
+		return "SELECT table_name, table_type FROM information_schema.tables WHERE table_schema = DATABASE() ORDER BY table_name", nil
+	default:
+		return "SELECT column_name, column_type, is_nullable, column_default, column_key FROM information_schema.columns " +
+			"WHERE table_schema = DATABASE() AND table_name = ? ORDER BY ordinal_position", []interface{}{table}
+	}
+}
+
+// run 执行查询并读取最多 maxRows 行。只读的数据库在只读事务中执行并在结束后回滚，
+// SQLite 不支持只读事务，改为在连接上打开 query_only
+func run(ctx context.Context, db *sql.DB, d Database, maxRows int, query string, args ...interface{}) (*Result, error) {
+	ctx, cancel := context.WithTimeout(ctx, DefaultTimeout)
+	defer cancel()
+	start := time.Now()
+
+	c, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	if d.Driver == "sqlite" {
+		if _, err := c.ExecContext(ctx, fmt.Sprintf("PRAGMA query_only = %t", !d.ReadWrite)); err != nil {
+			return nil, err
+		}
+	}
+	tx, err := c.BeginTx(ctx, &sql.TxOptions{ReadOnly: !d.ReadWrite && d.Driver != "sqlite"})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	result, err := readRows(rows, maxRows)
+	if err != nil {
+		return nil, err
+	}
+	if d.ReadWrite {
+		if err := tx.Commit(); err != nil {
+			return nil, err
+		}
+	}
+	result.Duration = time.Since(start).Round(time.Millisecond).String()
+	return result, nil
+}
+
+// readRows 读取最多 maxRows 行并关闭 rows，[]byte 转换为字符串
+func readRows(rows *sql.Rows, maxRows int) (*Result, error) {
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	result := &Result{Columns: columns, Rows: [][]interface{}{}}
+	for rows.Next() {
+		if len(result.Rows) == maxRows {
+			result.Truncated = true
+			break
+		}
+		values := make([]interface{}, len(columns))
+		ptrs := make([]interface{}, len(columns))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+		for i, v := range values {
+			if b, ok := v.([]byte); ok {
+				values[i] = string(b)
+			}
+		}
+		result.Rows = append(result.Rows, values)
+	}
+	return result, rows.Err()
+}
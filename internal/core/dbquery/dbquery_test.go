@@ -0,0 +1,160 @@
+package dbquery
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeDriver 记录收到的语句和事务，查询返回三行 (id, name)
+type fakeDriver struct {
+	mu  sync.Mutex
+	log []string
+}
+
+func (d *fakeDriver) record(format string, args ...interface{}) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.log = append(d.log, fmt.Sprintf(format, args...))
+}
+
+func (d *fakeDriver) take() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	log := strings.Join(d.log, "; ")
+	d.log = nil
+	return log
+}
+
+func (d *fakeDriver) Open(dsn string) (driver.Conn, error) {
+	d.record("open %s", dsn)
+	return &fakeConn{d}, nil
+}
+
+type fakeConn struct{ d *fakeDriver }
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("not supported")
+}
+func (c *fakeConn) Close() error { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *fakeConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	c.d.record("begin read_only=%t", opts.ReadOnly)
+	return c, nil
+}
+
+func (c *fakeConn) Commit() error   { c.d.record("commit"); return nil }
+func (c *fakeConn) Rollback() error { c.d.record("rollback"); return nil }
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.d.record("exec %s", query)
+	return driver.RowsAffected(0), nil
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.d.record("query %s", query)
+	return &fakeRows{}, nil
+}
+
+type fakeRows struct{ n int }
+
+func (r *fakeRows) Columns() []string { return []string{"id", "name"} }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.n == 3 {
+		return io.EOF
+	}
+	r.n++
+	dest[0], dest[1] = int64(r.n), []byte(fmt.Sprintf("user%d", r.n))
+	return nil
+}
+
+var testDriver = &fakeDriver{}
+
+func init() {
+	sql.Register("postgres", testDriver)
+	sql.Register("sqlite", testDriver)
+}
+
+func TestPoolQuery(t *testing.T) {
+	root := t.TempDir()
+	t.Setenv("VC_TEST_DSN", "postgres://secret@db/app")
+	os.WriteFile(filepath.Join(root, "dsn"), []byte("app.db\n"), 0o600)
+
+	pool := NewPool(root)
+	defer pool.Close()
+	pool.Set([]Database{
+		{Name: "app", Driver: "postgres", DSNEnv: "VC_TEST_DSN", MaxRows: 2},
+		{Name: "local", Driver: "sqlite", DSNFile: "dsn", ReadWrite: true},
+	})
+
+	result, err := pool.Query(context.Background(), "app", "SELECT id, name FROM users", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Rows) != 2 || !result.Truncated || result.Rows[1][1] != "user2" || result.Columns[0] != "id" {
+		t.Errorf("unexpected result %+v", result)
+	}
+	if log := testDriver.take(); log != "open postgres://secret@db/app; begin read_only=true; query SELECT id, name FROM users; rollback" {
+		t.Errorf("unexpected driver calls %q", log)
+	}
+
+	if _, err := pool.Query(context.Background(), "app", "DELETE FROM users", 0); !errors.Is(err, ErrNotReadOnly) {
+		t.Errorf("expected ErrNotReadOnly, got %v", err)
+	}
+	if log := testDriver.take(); log != "" {
+		t.Errorf("expected rejected statement not to reach the driver, got %q", log)
+	}
+
+	if _, err := pool.Query(context.Background(), "local", "DELETE FROM users", 0); err != nil {
+		t.Fatal(err)
+	}
+	if log := testDriver.take(); log != "open app.db; exec PRAGMA query_only = false; begin read_only=false; query DELETE FROM users; commit" {
+		t.Errorf("unexpected driver calls %q", log)
+	}
+
+	if _, err := pool.Schema(context.Background(), "local", "users"); err != nil {
+		t.Fatal(err)
+	}
+	if log := testDriver.take(); !strings.Contains(log, "pragma_table_info") {
+		t.Errorf("expected sqlite schema query, got %q", log)
+	}
+
+	if _, err := pool.Query(context.Background(), "missing", "SELECT 1", 0); err == nil {
+		t.Error("expected unknown database to fail")
+	}
+	pool.Set([]Database{{Name: "my", Driver: "mysql", DSNEnv: "VC_TEST_DSN"}})
+	if _, err := pool.Query(context.Background(), "my", "SELECT 1", 0); !errors.Is(err, ErrDriverUnavailable) {
+		t.Errorf("expected ErrDriverUnavailable, got %v", err)
+	}
+}
+
+func TestDatabaseValidate(t *testing.T) {
+	valid := Database{Name: "app", Driver: "postgres", DSNEnv: "DSN"}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("expected valid database, got %v", err)
+	}
+	for _, d := range []Database{
+		{Name: "bad name", Driver: "postgres", DSNEnv: "DSN"},
+		{Name: "app", Driver: "oracle", DSNEnv: "DSN"},
+		{Name: "app", Driver: "postgres"},
+		{Name: "app", Driver: "postgres", DSNEnv: "DSN", DSNFile: "dsn"},
+		{Name: "app", Driver: "postgres", DSNEnv: "DSN", MaxRows: MaxRows + 1},
+	} {
+		if err := d.Validate(); err == nil {
+			t.Errorf("expected %+v to be invalid", d)
+		}
+	}
+}
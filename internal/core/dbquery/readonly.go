@@ -0,0 +1,293 @@
+package dbquery
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrNotReadOnly 表示语句可能修改数据库，只读的数据库拒绝执行
+var ErrNotReadOnly = errors.New("statement is not read-only")
+
+// readStatements 是只读数据库允许的语句开头
+var readStatements = map[string]bool{
+	"SELECT": true, "WITH": true, "EXPLAIN": true, "SHOW": true, "DESCRIBE": true, "DESC": true,
+	"VALUES": true, "TABLE": true, "PRAGMA": true,
+}
+
+// writeKeywords 是出现在只读语句任意位置时也会修改数据或加锁的关键字，
+// 如 PostgreSQL 中修改数据的 CTE、SELECT INTO、SELECT ... INTO OUTFILE 和 FOR UPDATE
+var writeKeywords = map[string]bool{
+	"INSERT": true, "UPDATE": true, "DELETE": true, "MERGE": true, "UPSERT": true, "INTO": true, "LOCK": true,
+}
+
+// writeFunctions 是有副作用或读取服务器文件的函数
+var writeFunctions = map[string]bool{
+	"NEXTVAL": true, "SETVAL": true, "SET_CONFIG": true, "PG_TERMINATE_BACKEND": true, "PG_CANCEL_BACKEND": true,
+	"PG_RELOAD_CONF": true, "PG_ROTATE_LOGFILE": true, "PG_READ_FILE": true, "PG_READ_BINARY_FILE": true,
+	"PG_LS_DIR": true, "LO_IMPORT": true, "LO_EXPORT": true, "LO_UNLINK": true, "DBLINK_EXEC": true,
+	"LOAD_FILE": true, "LOAD_EXTENSION": true, "GET_LOCK": true,
+}
+
+// readPragmas 是 SQLite 中只读的 PRAGMA，只能以查询形式使用，不能赋值
+var readPragmas = map[string]bool{
+	"TABLE_INFO": true, "TABLE_XINFO": true, "TABLE_LIST": true, "INDEX_LIST": true, "INDEX_INFO": true,
+	"INDEX_XINFO": true, "FOREIGN_KEY_LIST": true, "FOREIGN_KEY_CHECK": true, "DATABASE_LIST": true,
+	"COLLATION_LIST": true, "FUNCTION_LIST": true, "MODULE_LIST": true, "PRAGMA_LIST": true,
+	"COMPILE_OPTIONS": true, "INTEGRITY_CHECK": true, "QUICK_CHECK": true,
+}
+
+// CheckReadOnly 解析语句，只有单条只读语句（SELECT、WITH、EXPLAIN、SHOW、DESCRIBE 和只读的 PRAGMA）通过检查。
+// 字符串、引号中的标识符和注释不参与判断，不是只读时返回包装 ErrNotReadOnly 的错误
+func CheckReadOnly(dialect, query string) error {
+	tokens, err := tokenize(dialect, query)
+	if err != nil {
+		return err
+	}
+	var statements [][]token
+	start := 0
+	for i, t := range tokens {
+		if t.kind == tokenSymbol && t.text == ";" {
+			if i > start {
+				statements = append(statements, tokens[start:i])
+			}
+			start = i + 1
+		}
+	}
+	if start < len(tokens) {
+		statements = append(statements, tokens[start:])
+	}
+	switch len(statements) {
+	case 0:
+		return errors.New("query is empty")
+	case 1:
+	default:
+		return fmt.Errorf("%w: only a single statement is allowed", ErrNotReadOnly)
+	}
+
+	stmt := statements[0]
+	first := stmt[0]
+	if first.kind == tokenSymbol && first.text == "(" {
+		// (SELECT ...) UNION (SELECT ...)
+		for _, t := range stmt {
+			if t.kind == tokenWord {
+				first = t
+				break
+			}
+		}
+	}
+	keyword := strings.ToUpper(first.text)
+	if first.kind != tokenWord || !readStatements[keyword] {
+		return fmt.Errorf("%w: %s statements are not allowed", ErrNotReadOnly, keyword)
+	}
+	for i, t := range stmt {
+		if t.kind != tokenWord {
+			continue
+		}
+		word := strings.ToUpper(t.text)
+		if writeKeywords[word] {
+			return fmt.Errorf("%w: %s is not allowed", ErrNotReadOnly, word)
+		}
+		if writeFunctions[word] && i+1 < len(stmt) && stmt[i+1].text == "(" {
+			return fmt.Errorf("%w: function %s is not allowed", ErrNotReadOnly, strings.ToLower(word))
+		}
+	}
+	if keyword == "PRAGMA" {
+		return checkPragma(stmt)
+	}
+	return nil
+}
+
+// checkPragma 只允许查询形式的只读 PRAGMA，如 PRAGMA table_info(users)
+func checkPragma(stmt []token) error {
+	name := ""
+	for _, t := range stmt[1:] {
+		if t.kind == tokenSymbol && t.text == "=" {
+			return fmt.Errorf("%w: PRAGMA assignments are not allowed", ErrNotReadOnly)
+		}
+		if t.kind == tokenWord && name == "" {
+			name = strings.ToUpper(t.text)
+		}
+	}
+	// PRAGMA schema.name 的第一个词是数据库名
+	if len(stmt) > 3 && stmt[2].text == "." {
+		name = strings.ToUpper(stmt[3].text)
+	}
+	if !readPragmas[name] {
+		return fmt.Errorf("%w: PRAGMA %s is not allowed", ErrNotReadOnly, strings.ToLower(name))
+	}
+	return nil
+}
+
+// tokenKind 是词法单元的类型
+type tokenKind int
+
+const (
+	tokenWord   tokenKind = iota // 关键字或未加引号的标识符
+	tokenQuoted                  // 字符串或加引号的标识符
+	tokenSymbol                  // 运算符和标点
+	tokenNumber
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+// tokenize 把语句切分为词法单元并丢弃注释，dialect 决定引号、注释和转义的规则
+func tokenize(dialect, s string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f':
+			i++
+		case c == '-' && strings.HasPrefix(s[i:], "--"), c == '#' && dialect == "mysql":
+			end := strings.IndexByte(s[i:], '\n')
+			if end < 0 {
+				return tokens, nil
+			}
+			i += end + 1
+		case c == '/' && strings.HasPrefix(s[i:], "/*"):
+			// MySQL 会执行 /*! ... */ 中的内容
+			if dialect == "mysql" && strings.HasPrefix(s[i:], "/*!") {
+				return nil, fmt.Errorf("%w: mysql executable comments are not allowed", ErrNotReadOnly)
+			}
+			end, err := skipComment(s, i, dialect == "postgres")
+			if err != nil {
+				return nil, err
+			}
+			i = end
+		case c == '\'':
+			end, err := skipQuoted(s, i, '\'', dialect == "mysql")
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{tokenQuoted, s[i:end]})
+			i = end
+		case (c == 'E' || c == 'e') && dialect == "postgres" && i+1 < len(s) && s[i+1] == '\'':
+			end, err := skipQuoted(s, i+1, '\'', true)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{tokenQuoted, s[i:end]})
+			i = end
+		case c == '"', c == '`' && dialect != "postgres":
+			end, err := skipQuoted(s, i, c, c == '"' && dialect == "mysql")
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{tokenQuoted, s[i:end]})
+			i = end
+		case c == '[' && dialect == "sqlite":
+			end := strings.IndexByte(s[i:], ']')
+			if end < 0 {
+				return nil, errors.New("unterminated quoted identifier")
+			}
+			tokens = append(tokens, token{tokenQuoted, s[i : i+end+1]})
+			i += end + 1
+		case c == '$' && dialect == "postgres":
+			end, err := skipDollarQuoted(s, i)
+			if err != nil {
+				return nil, err
+			}
+			if end == i {
+				tokens = append(tokens, token{tokenSymbol, "$"})
+				i++
+				continue
+			}
+			kind := tokenQuoted
+			if isDigit(s[i+1]) {
+				kind = tokenNumber // $1 参数
+			}
+			tokens = append(tokens, token{kind, s[i:end]})
+			i = end
+		case isWordStart(c):
+			end := i + 1
+			for end < len(s) && (isWordStart(s[end]) || isDigit(s[end]) || s[end] == '$') {
+				end++
+			}
+			tokens = append(tokens, token{tokenWord, s[i:end]})
+			i = end
+		case isDigit(c):
+			end := i + 1
+			for end < len(s) && (isDigit(s[end]) || s[end] == '.' || isWordStart(s[end])) {
+				end++
+			}
+			tokens = append(tokens, token{tokenNumber, s[i:end]})
+			i = end
+		default:
+			tokens = append(tokens, token{tokenSymbol, string(c)})
+			i++
+		}
+	}
+	return tokens, nil
+}
+
+// skipComment 返回从 i 开始的块注释之后的位置，PostgreSQL 的块注释可以嵌套
+func skipComment(s string, i int, nested bool) (int, error) {
+	depth := 0
+	for j := i; j+1 < len(s); j++ {
+		switch {
+		case s[j] == '/' && s[j+1] == '*' && (nested || depth == 0):
+			depth++
+			j++
+		case s[j] == '*' && s[j+1] == '/':
+			depth--
+			j++
+			if depth == 0 {
+				return j + 1, nil
+			}
+		}
+	}
+	return 0, errors.New("unterminated block comment")
+}
+
+// skipQuoted 返回从 i 开始、以 quote 括起的内容之后的位置，两个连续的引号表示引号本身，
+// backslash 为 true 时反斜杠转义下一个字符
+func skipQuoted(s string, i int, quote byte, backslash bool) (int, error) {
+	for j := i + 1; j < len(s); j++ {
+		switch {
+		case backslash && s[j] == '\\':
+			j++
+		case s[j] == quote:
+			if j+1 < len(s) && s[j+1] == quote {
+				j++
+				continue
+			}
+			return j + 1, nil
+		}
+	}
+	return 0, errors.New("unterminated quoted string")
+}
+
+// skipDollarQuoted 返回从 i 开始的 $tag$...$tag$ 字符串或 $1 参数之后的位置，都不是时返回 i
+func skipDollarQuoted(s string, i int) (int, error) {
+	j := i + 1
+	if j < len(s) && isDigit(s[j]) {
+		for j < len(s) && isDigit(s[j]) {
+			j++
+		}
+		return j, nil
+	}
+	for j < len(s) && (isWordStart(s[j]) || isDigit(s[j])) {
+		j++
+	}
+	if j >= len(s) || s[j] != '$' {
+		return i, nil
+	}
+	tag := s[i : j+1]
+	end := strings.Index(s[j+1:], tag)
+	if end < 0 {
+		return 0, errors.New("unterminated dollar-quoted string")
+	}
+	return j + 1 + end + len(tag), nil
+}
+
+func isWordStart(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
@@ -0,0 +1,50 @@
+package dbquery
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheckReadOnly(t *testing.T) {
+	tests := []struct {
+		dialect string
+		query   string
+		ok      bool
+	}{
+		{"postgres", "SELECT * FROM users WHERE id = $1;", true},
+		{"postgres", "with recent as (select * from orders) select count(*) from recent", true},
+		{"postgres", "(SELECT 1) UNION (SELECT 2)", true},
+		{"postgres", "SELECT 'DELETE FROM users; DROP TABLE x' AS s -- update\n", true},
+		{"postgres", "SELECT $$ insert into t $$, \"update\" FROM t /* delete /* nested */ */", true},
+		{"postgres", "EXPLAIN ANALYZE SELECT * FROM t", true},
+		{"mysql", "SHOW TABLES", true},
+		{"mysql", "DESCRIBE users", true},
+		{"mysql", "SELECT 'it\\'s; DELETE' FROM t # delete\n", true},
+		{"sqlite", "PRAGMA table_info(users)", true},
+		{"sqlite", "PRAGMA main.index_list('users')", true},
+		{"sqlite", "SELECT [delete] FROM t", true},
+		{"postgres", "DELETE FROM users", false},
+		{"postgres", "SELECT 1; DROP TABLE users", false},
+		{"postgres", "WITH d AS (DELETE FROM users RETURNING *) SELECT * FROM d", false},
+		{"postgres", "SELECT * INTO backup FROM users", false},
+		{"postgres", "SELECT * FROM users FOR UPDATE", false},
+		{"postgres", "SELECT nextval('seq')", false},
+		{"postgres", "SELECT E'\\' ; DELETE FROM t --'", true},
+		{"postgres", "COPY users TO '/tmp/x'", false},
+		{"mysql", "SELECT * FROM t INTO OUTFILE '/tmp/x'", false},
+		{"mysql", "SELECT 1 /*!50000 ; DROP TABLE t */", false},
+		{"sqlite", "PRAGMA journal_mode = WAL", false},
+		{"sqlite", "PRAGMA writable_schema", false},
+		{"postgres", "  ", false},
+		{"postgres", "SELECT 'unterminated", false},
+	}
+	for _, tt := range tests {
+		err := CheckReadOnly(tt.dialect, tt.query)
+		if (err == nil) != tt.ok {
+			t.Errorf("CheckReadOnly(%s, %q) = %v, want ok=%v", tt.dialect, tt.query, err, tt.ok)
+		}
+	}
+	if err := CheckReadOnly("postgres", "UPDATE t SET a = 1"); !errors.Is(err, ErrNotReadOnly) {
+		t.Errorf("expected ErrNotReadOnly, got %v", err)
+	}
+}
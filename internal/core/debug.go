@@ -0,0 +1,64 @@
+package core
+
+import (
+	"sort"
+
+	"github.com/liangsj/vimcoplit/internal/core/watchdog"
+)
+
+// DebugState 是诊断卡住问题用的服务内部状态快照
+type DebugState struct {
+	Generations   []GenerationRecord `json:"generations"`    // 进行中的生成请求，Partial 为目前已生成的输出
+	Commands      []string           `json:"commands"`       // 正在执行的命令 ID
+	Indexing      bool               `json:"indexing"`       // 是否有后台索引刷新在进行
+	DeferredQueue int                `json:"deferred_queue"` // 等待恢复联网后执行的生成请求数
+	Calls         []watchdog.Call    `json:"calls"`          // 进行中的模型和工具调用，Hung 为已放弃但仍未返回的调用
+	// ServiceLockBusy 表示服务的读写锁被写锁占用或有写锁在等待，此时不读取命令和索引状态，
+	// 通常说明有模型调用持有读锁时间过长，切换模型等操作在等待
+	ServiceLockBusy bool           `json:"service_lock_busy"`
+	Resources       *ResourceUsage `json:"resources"`
+}
+
+// DebugState 返回服务内部状态快照，不会因为服务锁被占用而阻塞
+func (s *serviceImpl) DebugState() *DebugState {
+	state := &DebugState{
+		Generations:   s.generations.active(),
+		DeferredQueue: len(s.deferred.queued()),
+		Calls:         s.watchdog.Active(),
+		Resources:     s.GetResourceUsage(),
+	}
+	if s.mu.TryRLock() {
+		for id := range s.commands {
+			state.Commands = append(state.Commands, id)
+		}
+		state.Indexing = s.indexCancel != nil
+		s.mu.RUnlock()
+		sort.Strings(state.Commands)
+	} else {
+		state.ServiceLockBusy = true
+	}
+	return state
+}
+
+// active 返回进行中的生成请求，按开始时间排序
+func (t *generationTracker) active() []GenerationRecord {
+	t.mu.Lock()
+	inflight := make([]*inflightGeneration, 0, len(t.inflight))
+	for _, g := range t.inflight {
+		inflight = append(inflight, g)
+	}
+	t.mu.Unlock()
+
+	records := make([]GenerationRecord, 0, len(inflight))
+	for _, g := range inflight {
+		g.mu.Lock()
+		record := g.record
+		record.Partial = g.partial.String()
+		g.mu.Unlock()
+		records = append(records, record)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].StartedAt.Before(records[j].StartedAt)
+	})
+	return records
+}
@@ -0,0 +1,144 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DeferredStatus 表示延后生成请求的状态
+type DeferredStatus string
+
+const (
+	DeferredStatusQueued    DeferredStatus = "queued"
+	DeferredStatusCompleted DeferredStatus = "completed"
+	DeferredStatusFailed    DeferredStatus = "failed"
+)
+
+// DeferredGeneration 是离线时排队、恢复联网后再执行的非紧急生成请求
+type DeferredGeneration struct {
+	ID          string         `json:"id"`
+	Prompt      string         `json:"prompt"`
+	Status      DeferredStatus `json:"status"`
+	Response    string         `json:"response,omitempty"`
+	Error       string         `json:"error,omitempty"`
+	CreatedAt   time.Time      `json:"created_at"`
+	CompletedAt time.Time      `json:"completed_at,omitempty"`
+}
+
+// deferredStore 是延后生成请求的持久化存储
+type deferredStore struct {
+	mu    sync.RWMutex
+	path  string
+	items map[string]*DeferredGeneration
+}
+
+// newDeferredStore 创建延后生成请求存储，并加载已有记录
+func newDeferredStore(path string) *deferredStore {
+	s := &deferredStore{
+		path:  path,
+		items: make(map[string]*DeferredGeneration),
+	}
+	if data, err := os.ReadFile(path); err == nil {
+		json.Unmarshal(data, &s.items)
+	}
+	return s
+}
+
+// save 保存到文件，调用方需持有锁
+func (s *deferredStore) save() error {
+	data, err := json.MarshalIndent(s.items, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(s.path, data, 0644)
+}
+
+// add 将生成请求加入队列
+func (s *deferredStore) add(prompt string) (*DeferredGeneration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item := &DeferredGeneration{
+		ID:        uuid.New().String(),
+		Prompt:    prompt,
+		Status:    DeferredStatusQueued,
+		CreatedAt: time.Now(),
+	}
+	s.items[item.ID] = item
+	if err := s.save(); err != nil {
+		delete(s.items, item.ID)
+		return nil, err
+	}
+	c := *item
+	return &c, nil
+}
+
+// get 获取生成请求
+func (s *deferredStore) get(id string) (*DeferredGeneration, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	item, exists := s.items[id]
+	if !exists {
+		return nil, errors.New("deferred generation not found")
+	}
+	c := *item
+	return &c, nil
+}
+
+// list 按创建时间列出所有生成请求
+func (s *deferredStore) list() []*DeferredGeneration {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	items := make([]*DeferredGeneration, 0, len(s.items))
+	for _, item := range s.items {
+		c := *item
+		items = append(items, &c)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].CreatedAt.Before(items[j].CreatedAt)
+	})
+	return items
+}
+
+// queued 按创建时间列出仍在排队的生成请求
+func (s *deferredStore) queued() []*DeferredGeneration {
+	var queued []*DeferredGeneration
+	for _, item := range s.list() {
+		if item.Status == DeferredStatusQueued {
+			queued = append(queued, item)
+		}
+	}
+	return queued
+}
+
+// complete 记录生成结果
+func (s *deferredStore) complete(id, response string, err error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, exists := s.items[id]
+	if !exists {
+		return errors.New("deferred generation not found")
+	}
+	item.CompletedAt = time.Now()
+	if err != nil {
+		item.Status = DeferredStatusFailed
+		item.Error = err.Error()
+	} else {
+		item.Status = DeferredStatusCompleted
+		item.Response = response
+	}
+	return s.save()
+}
@@ -0,0 +1,293 @@
+// Package diffsum 对超出单次调用上下文的大型 diff 做分块摘要：按文件和 hunk 切分为不超过
+// token 预算的块，逐块生成摘要（map），再逐层合并摘要直到得到整体概述（reduce）
+package diffsum
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/liangsj/vimcoplit/internal/models"
+)
+
+// truncatedNote 是截断超出预算的单个 hunk 时追加的说明
+const truncatedNote = "\n... (hunk truncated)\n"
+
+// File 是 diff 中一个文件的修改
+type File struct {
+	Path    string   `json:"path"`
+	Added   int      `json:"added"`
+	Deleted int      `json:"deleted"`
+	Binary  bool     `json:"binary,omitempty"`
+	header  string   // diff --git 到第一个 @@ 之间的内容
+	hunks   []string // 每个 hunk 包含 @@ 行
+}
+
+// Chunk 是一次 map 调用处理的 diff 片段，大文件按 hunk 拆分到多个块中，此时 Part 和 Parts 从 1 开始编号
+type Chunk struct {
+	Files     []string `json:"files"`
+	Part      int      `json:"part,omitempty"`
+	Parts     int      `json:"parts,omitempty"`
+	Truncated bool     `json:"truncated,omitempty"` // 单个 hunk 超出预算被截断
+	Tokens    int      `json:"tokens"`
+	text      string
+}
+
+// Section 是一个块的摘要
+type Section struct {
+	Chunk
+	Summary string `json:"summary"`
+}
+
+// Summary 是分层的 diff 摘要：整体概述、每个块的摘要和每个文件的修改统计
+type Summary struct {
+	Overview string     `json:"overview"`
+	Sections []*Section `json:"sections"`
+	Files    []*File    `json:"files"`
+	Calls    int        `json:"calls"`  // 调用模型的次数
+	Levels   int        `json:"levels"` // reduce 的层数，只有一块时为 0，块摘要能一次合并时为 1
+}
+
+// Generate 为每个提示词生成回复，返回的回复与提示词一一对应，可以并发执行
+type Generate func(ctx context.Context, prompts []string) ([]string, error)
+
+// Parse 解析 git diff 的统一格式输出
+func Parse(diff string) []*File {
+	var files []*File
+	var f *File
+	var hunk *strings.Builder
+	flush := func() {
+		if f != nil && hunk != nil {
+			f.hunks = append(f.hunks, hunk.String())
+		}
+		hunk = nil
+	}
+	for _, line := range strings.SplitAfter(diff, "\n") {
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			flush()
+			f = &File{Path: diffPath(line)}
+			files = append(files, f)
+			f.header = line
+		case f == nil:
+			continue
+		case strings.HasPrefix(line, "@@"):
+			flush()
+			hunk = &strings.Builder{}
+			hunk.WriteString(line)
+		case hunk != nil:
+			hunk.WriteString(line)
+			if strings.HasPrefix(line, "+") {
+				f.Added++
+			} else if strings.HasPrefix(line, "-") {
+				f.Deleted++
+			}
+		default:
+			f.header += line
+			if strings.HasPrefix(line, "+++ b/") {
+				f.Path = strings.TrimSpace(strings.TrimPrefix(line, "+++ b/"))
+			} else if strings.HasPrefix(line, "Binary files ") || strings.HasPrefix(line, "GIT binary patch") {
+				f.Binary = true
+			}
+		}
+	}
+	flush()
+	return files
+}
+
+// diffPath 从 diff --git a/x b/x 行中取出新文件的路径
+func diffPath(line string) string {
+	line = strings.TrimSpace(strings.TrimPrefix(line, "diff --git "))
+	if i := strings.LastIndex(line, " b/"); i >= 0 {
+		return line[i+3:]
+	}
+	return line
+}
+
+// Split 把文件切分为不超过 budget 个 token 的块：小文件合并到同一块，
+// 超出预算的文件按 hunk 拆分，单个超出预算的 hunk 截断
+func Split(files []*File, budget int) []*Chunk {
+	var chunks []*Chunk
+	var current *Chunk
+	add := func(c *Chunk, path, text string) {
+		c.text += text
+		c.Tokens += models.EstimateTokens(text)
+		if len(c.Files) == 0 || c.Files[len(c.Files)-1] != path {
+			c.Files = append(c.Files, path)
+		}
+	}
+	for _, f := range files {
+		text := f.header + strings.Join(f.hunks, "")
+		tokens := models.EstimateTokens(text)
+		if tokens <= budget {
+			if current == nil || current.Tokens+tokens > budget {
+				current = &Chunk{}
+				chunks = append(chunks, current)
+			}
+			add(current, f.Path, text)
+			continue
+		}
+
+		// 大文件单独成块，每块都带上文件头
+		var parts []*Chunk
+		part := &Chunk{}
+		add(part, f.Path, f.header)
+		for _, hunk := range f.hunks {
+			tokens := models.EstimateTokens(hunk)
+			if part.Tokens+tokens > budget && len(part.text) > len(f.header) {
+				parts = append(parts, part)
+				part = &Chunk{}
+				add(part, f.Path, f.header)
+			}
+			if part.Tokens+tokens > budget {
+				hunk = truncate(hunk, budget-part.Tokens)
+				part.Truncated = true
+			}
+			add(part, f.Path, hunk)
+		}
+		parts = append(parts, part)
+		for i, p := range parts {
+			p.Part, p.Parts = i+1, len(parts)
+		}
+		chunks = append(chunks, parts...)
+		current = nil
+	}
+	return chunks
+}
+
+// truncate 按行截断文本，使其连同截断说明不超过 budget 个 token
+func truncate(text string, budget int) string {
+	budget -= models.EstimateTokens(truncatedNote)
+	var b strings.Builder
+	tokens := 0
+	for _, line := range strings.SplitAfter(text, "\n") {
+		n := models.EstimateTokens(line)
+		if tokens+n > budget && b.Len() > 0 {
+			break
+		}
+		b.WriteString(line)
+		tokens += n
+	}
+	return b.String() + truncatedNote
+}
+
+// Summarize 对 diff 做分块摘要，budget 为每次调用中 diff 或摘要部分的 token 上限
+func Summarize(ctx context.Context, diff string, budget int, generate Generate) (*Summary, error) {
+	files := Parse(diff)
+	if len(files) == 0 {
+		return nil, errors.New("diff is empty")
+	}
+	if budget <= 0 {
+		return nil, errors.New("budget must be positive")
+	}
+	summary := &Summary{Files: files}
+	call := func(prompts []string) ([]string, error) {
+		outputs, err := generate(ctx, prompts)
+		if err != nil {
+			return nil, err
+		}
+		if len(outputs) != len(prompts) {
+			return nil, fmt.Errorf("expected %d summaries, got %d", len(prompts), len(outputs))
+		}
+		summary.Calls += len(prompts)
+		return outputs, nil
+	}
+
+	chunks := Split(files, budget)
+	prompts := make([]string, len(chunks))
+	for i, c := range chunks {
+		prompts[i] = mapPrompt(c)
+	}
+	outputs, err := call(prompts)
+	if err != nil {
+		return nil, err
+	}
+	texts := make([]string, len(chunks))
+	for i, c := range chunks {
+		summary.Sections = append(summary.Sections, &Section{Chunk: *c, Summary: strings.TrimSpace(outputs[i])})
+		texts[i] = sectionText(summary.Sections[i])
+	}
+
+	// 只有一块时块摘要就是整体概述，否则逐层合并，直到所有摘要能放进一次调用
+	if len(chunks) == 1 {
+		summary.Overview = summary.Sections[0].Summary
+		return summary, nil
+	}
+	for {
+		summary.Levels++
+		groups := group(texts, budget)
+		prompts = make([]string, 0, len(groups))
+		for _, g := range groups {
+			prompts = append(prompts, reducePrompt(g, len(groups) == 1))
+		}
+		outputs, err := call(prompts)
+		if err != nil {
+			return nil, err
+		}
+		if len(groups) == 1 {
+			summary.Overview = strings.TrimSpace(outputs[0])
+			return summary, nil
+		}
+		if len(groups) == len(texts) {
+			return nil, fmt.Errorf("budget of %d tokens is too small to combine summaries", budget)
+		}
+		texts = outputs
+	}
+}
+
+// group 把摘要依次分组，每组不超过 budget 个 token，单条超出预算的摘要单独成组
+func group(texts []string, budget int) [][]string {
+	var groups [][]string
+	tokens := 0
+	for _, text := range texts {
+		n := models.EstimateTokens(text)
+		if len(groups) == 0 || tokens+n > budget {
+			groups = append(groups, nil)
+			tokens = 0
+		}
+		groups[len(groups)-1] = append(groups[len(groups)-1], text)
+		tokens += n
+	}
+	return groups
+}
+
+// sectionText 返回 reduce 时使用的块摘要，带上涉及的文件
+func sectionText(s *Section) string {
+	label := strings.Join(s.Files, ", ")
+	if s.Parts > 0 {
+		label = fmt.Sprintf("%s (part %d of %d)", label, s.Part, s.Parts)
+	}
+	return fmt.Sprintf("[%s]\n%s", label, s.Summary)
+}
+
+// mapPrompt 返回摘要一个块的提示词
+func mapPrompt(c *Chunk) string {
+	var b strings.Builder
+	b.WriteString("Summarize the following part of a code change. Describe what changed and why it matters, " +
+		"grouped by file, in a few concise bullet points. Do not restate the diff line by line.\n")
+	if c.Parts > 0 {
+		fmt.Fprintf(&b, "This is part %d of %d of the changes to %s.\n", c.Part, c.Parts, c.Files[0])
+	}
+	if c.Truncated {
+		b.WriteString("Some hunks were truncated to fit; mention that the summary may be incomplete.\n")
+	}
+	b.WriteString("\n")
+	b.WriteString(c.text)
+	return b.String()
+}
+
+// reducePrompt 返回合并多条摘要的提示词，final 为 true 时要求给出整体概述
+func reducePrompt(texts []string, final bool) string {
+	var b strings.Builder
+	if final {
+		b.WriteString("Below are summaries of the parts of one code change. Write an overview of the whole change: " +
+			"start with one or two sentences on its purpose, then list the main changes by area. " +
+			"Call out breaking changes and risky edits.\n\n")
+	} else {
+		b.WriteString("Below are summaries of parts of one code change. Merge them into a single concise summary " +
+			"that keeps file names and every notable change.\n\n")
+	}
+	b.WriteString(strings.Join(texts, "\n\n"))
+	return b.String()
+}
@@ -0,0 +1,141 @@
+package diffsum
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// fileDiff 生成一个文件的 diff，每个 hunk 增加 lines 行
+func fileDiff(path string, hunks, lines int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "diff --git a/%s b/%s\nindex 1111111..2222222 100644\n--- a/%s\n+++ b/%s\n", path, path, path, path)
+	for h := 0; h < hunks; h++ {
+		fmt.Fprintf(&b, "@@ -%d,1 +%d,%d @@ func f%d() {\n", h*100+1, h*100+1, lines+1, h)
+		b.WriteString(" context line\n")
+		for i := 0; i < lines; i++ {
+			fmt.Fprintf(&b, "+\tvalue%d := compute(%d)\n", i, i)
+		}
+	}
+	return b.String()
+}
+
+func TestParse(t *testing.T) {
+	diff := fileDiff("a.go", 2, 3) +
+		"diff --git a/old.go b/old.go\ndeleted file mode 100644\n--- a/old.go\n+++ /dev/null\n@@ -1,2 +0,0 @@\n-package old\n-func F() {}\n" +
+		"diff --git a/logo.png b/logo.png\nBinary files a/logo.png and b/logo.png differ\n"
+	files := Parse(diff)
+	if len(files) != 3 {
+		t.Fatalf("expected 3 files, got %d", len(files))
+	}
+	if f := files[0]; f.Path != "a.go" || f.Added != 6 || f.Deleted != 0 || len(f.hunks) != 2 {
+		t.Errorf("unexpected first file %+v", f)
+	}
+	if f := files[1]; f.Path != "old.go" || f.Deleted != 2 {
+		t.Errorf("unexpected deleted file %+v", f)
+	}
+	if f := files[2]; f.Path != "logo.png" || !f.Binary || len(f.hunks) != 0 {
+		t.Errorf("unexpected binary file %+v", f)
+	}
+}
+
+func TestSplit(t *testing.T) {
+	files := Parse(fileDiff("a.go", 1, 2) + fileDiff("b.go", 1, 2) + fileDiff("big.go", 4, 30) + fileDiff("huge.go", 1, 200))
+	chunks := Split(files, 300)
+
+	if len(chunks[0].Files) != 2 || chunks[0].Files[0] != "a.go" || chunks[0].Files[1] != "b.go" {
+		t.Errorf("expected small files in one chunk, got %+v", chunks[0])
+	}
+	var big, huge []*Chunk
+	for _, c := range chunks {
+		if c.Tokens > 300 {
+			t.Errorf("chunk over budget: %d tokens", c.Tokens)
+		}
+		switch c.Files[0] {
+		case "big.go":
+			big = append(big, c)
+		case "huge.go":
+			huge = append(huge, c)
+		}
+	}
+	if len(big) < 2 || big[0].Part != 1 || big[0].Parts != len(big) {
+		t.Errorf("expected big file to be split by hunk, got %+v", big)
+	}
+	for _, c := range big {
+		if !strings.HasPrefix(c.text, "diff --git a/big.go") || c.Truncated {
+			t.Errorf("expected every part to start with the file header, got %q", c.text[:40])
+		}
+	}
+	if len(huge) != 1 || !huge[0].Truncated || !strings.HasSuffix(huge[0].text, truncatedNote) {
+		t.Errorf("expected oversized hunk to be truncated, got %+v", huge)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	diff := fileDiff("a.go", 1, 2) + fileDiff("big.go", 6, 30) + fileDiff("c.go", 1, 2)
+	var rounds [][]string
+	generate := func(ctx context.Context, prompts []string) ([]string, error) {
+		rounds = append(rounds, prompts)
+		out := make([]string, len(prompts))
+		for i := range prompts {
+			out[i] = fmt.Sprintf("summary %d.%d", len(rounds), i)
+		}
+		return out, nil
+	}
+
+	summary, err := Summarize(context.Background(), diff, 300, generate)
+	if err != nil {
+		t.Fatalf("failed to summarize: %v", err)
+	}
+	if len(summary.Sections) != len(rounds[0]) || len(summary.Sections) < 3 {
+		t.Fatalf("expected one map call per section, got %d sections and %d prompts", len(summary.Sections), len(rounds[0]))
+	}
+	if summary.Overview != fmt.Sprintf("summary %d.0", len(rounds)) || len(rounds[len(rounds)-1]) != 1 {
+		t.Errorf("expected overview from the final reduce call, got %q", summary.Overview)
+	}
+	if !strings.Contains(rounds[1][0], "[big.go (part 1 of") {
+		t.Errorf("expected reduce prompt to label sections, got %q", rounds[1][0])
+	}
+	calls := 0
+	for _, r := range rounds {
+		calls += len(r)
+	}
+	if summary.Calls != calls || summary.Levels != len(rounds)-1 || len(summary.Files) != 3 {
+		t.Errorf("unexpected summary stats %+v", summary)
+	}
+
+	// 只有一块时不需要合并
+	rounds = nil
+	summary, _ = Summarize(context.Background(), fileDiff("a.go", 1, 2), 300, generate)
+	if len(rounds) != 1 || summary.Levels != 0 || summary.Overview != "summary 1.0" {
+		t.Errorf("expected a single call for a small diff, got %d rounds %+v", len(rounds), summary)
+	}
+
+	// 块摘要放不进一次调用时逐层合并
+	rounds = nil
+	verbose := func(ctx context.Context, prompts []string) ([]string, error) {
+		rounds = append(rounds, prompts)
+		out := make([]string, len(prompts))
+		for i := range prompts {
+			out[i] = strings.Repeat("long summary ", 40)
+		}
+		if len(prompts) == 1 {
+			out[0] = "overview"
+		}
+		return out, nil
+	}
+	summary, err = Summarize(context.Background(), diff, 300, verbose)
+	if err != nil || summary.Overview != "overview" || summary.Levels < 2 || len(rounds[1]) < 2 {
+		t.Errorf("expected hierarchical reduce, got %d rounds %+v %v", len(rounds), summary, err)
+	}
+
+	if _, err := Summarize(context.Background(), "", 300, generate); err == nil {
+		t.Error("expected error for empty diff")
+	}
+	failing := func(ctx context.Context, prompts []string) ([]string, error) { return nil, errors.New("down") }
+	if _, err := Summarize(context.Background(), diff, 300, failing); err == nil || err.Error() != "down" {
+		t.Errorf("expected generation error, got %v", err)
+	}
+}
@@ -0,0 +1,94 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/liangsj/vimcoplit/internal/core/diffsum"
+)
+
+// defaultDiffBudget 是 diff 摘要每次调用中 diff 部分的默认 token 上限
+const defaultDiffBudget = 6000
+
+// ErrInvalidDiff 表示 diff 摘要请求的 diff 为空或修订范围无效
+var ErrInvalidDiff = errors.New("invalid diff")
+
+// DiffSummaryRequest 是 diff 摘要请求，Diff 和 Range 至少设置一个，都设置时使用 Diff
+type DiffSummaryRequest struct {
+	Diff   string `json:"diff,omitempty"`
+	Range  string `json:"range,omitempty"`  // 工作区 git 仓库中的修订范围，如 main..HEAD
+	Budget int    `json:"budget,omitempty"` // 每次调用中 diff 或摘要部分的 token 上限，0 表示按当前模型的上下文窗口计算
+}
+
+// SummarizeDiff 对大型 diff 分块摘要：按文件和 hunk 切分后并发摘要每一块，再逐层合并为整体概述
+func (s *serviceImpl) SummarizeDiff(ctx context.Context, req *DiffSummaryRequest) (*diffsum.Summary, error) {
+	diff := req.Diff
+	if diff == "" {
+		if req.Range == "" {
+			return nil, fmt.Errorf("%w: diff or range is required", ErrInvalidDiff)
+		}
+		var err error
+		if diff, err = gitDiff(ctx, s.cfg.WorkspaceRoot(), req.Range); err != nil {
+			return nil, err
+		}
+	}
+	if len(diffsum.Parse(diff)) == 0 {
+		return nil, fmt.Errorf("%w: no file changes", ErrInvalidDiff)
+	}
+
+	budget := req.Budget
+	if budget <= 0 {
+		budget = defaultDiffBudget
+		if window := s.GetCurrentModel().Info().MaxContext; window > 0 {
+			budget = min(budget, window/4)
+		}
+	}
+	return diffsum.Summarize(ctx, diff, budget, func(ctx context.Context, prompts []string) ([]string, error) {
+		batch := make([]BatchPrompt, len(prompts))
+		for i, prompt := range prompts {
+			batch[i] = BatchPrompt{ID: strconv.Itoa(i), Prompt: prompt}
+		}
+		results, err := s.GenerateBatch(ctx, batch)
+		if err != nil {
+			return nil, err
+		}
+		outputs := make([]string, len(prompts))
+		for i := range prompts {
+			result := results[strconv.Itoa(i)]
+			if result.Err() != nil {
+				return nil, result.Err()
+			}
+			outputs[i] = result.Response
+		}
+		return outputs, nil
+	})
+}
+
+// gitDiff 返回工作区 git 仓库中修订范围的 diff
+func gitDiff(ctx context.Context, root, rev string) (string, error) {
+	if strings.HasPrefix(rev, "-") || strings.ContainsAny(rev, " \t\n") {
+		return "", fmt.Errorf("%w: invalid revision range %q", ErrInvalidDiff, rev)
+	}
+	return runGitDiff(ctx, root, rev)
+}
+
+// runGitDiff 在工作区 git 仓库中执行 git diff，git 报错时返回 ErrInvalidDiff
+func runGitDiff(ctx context.Context, root string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", append(append([]string{"diff", "--no-color", "--no-ext-diff"}, args...), "--")...)
+	cmd.Dir = root
+	output, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			// git 在参数错误时会输出完整的用法说明，只保留第一行
+			message, _, _ := strings.Cut(strings.TrimSpace(string(exitErr.Stderr)), "\n")
+			return "", fmt.Errorf("%w: git diff %s failed: %s", ErrInvalidDiff, strings.Join(args, " "), message)
+		}
+		return "", err
+	}
+	return string(output), nil
+}
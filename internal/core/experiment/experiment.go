@@ -0,0 +1,260 @@
+package experiment
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// Variant 是实验的一个分组，Template 或 Model 为空时沿用默认值
+type Variant struct {
+	Name     string  `json:"name"`
+	Template string  `json:"template,omitempty"` // text/template 格式的提示词模板
+	Model    string  `json:"model,omitempty"`
+	Weight   float64 `json:"weight"`
+}
+
+// Config 是实验配置
+type Config struct {
+	Name     string    `json:"name"`
+	Variants []Variant `json:"variants"`
+}
+
+// Assignment 是一次请求分配到的分组
+type Assignment struct {
+	Experiment string `json:"experiment"`
+	Variant    string `json:"variant"`
+	Model      string `json:"model,omitempty"`
+	template   *template.Template
+}
+
+// HasTemplate 判断分组是否指定了提示词模板
+func (a *Assignment) HasTemplate() bool {
+	return a != nil && a.template != nil
+}
+
+// Render 使用分组的模板渲染提示词，没有模板时返回 false
+func (a *Assignment) Render(data interface{}) (string, bool, error) {
+	if a == nil || a.template == nil {
+		return "", false, nil
+	}
+	var buf bytes.Buffer
+	if err := a.template.Execute(&buf, data); err != nil {
+		return "", false, fmt.Errorf("failed to render template for variant %s: %v", a.Variant, err)
+	}
+	return buf.String(), true, nil
+}
+
+// VariantStats 是单个分组的请求、延迟和接受率统计
+type VariantStats struct {
+	Variant        string  `json:"variant"`
+	Requests       int64   `json:"requests"`
+	Errors         int64   `json:"errors"`
+	LatencySumMs   float64 `json:"latency_sum_ms"`
+	AvgLatencyMs   float64 `json:"avg_latency_ms"`
+	Accepted       int64   `json:"accepted"`
+	Partial        int64   `json:"partial"`
+	Rejected       int64   `json:"rejected"`
+	AcceptanceRate float64 `json:"acceptance_rate"`
+}
+
+// Report 是实验的汇总结果
+type Report struct {
+	Experiment string          `json:"experiment"`
+	Variants   []*VariantStats `json:"variants"`
+}
+
+// Runner 按权重随机分配分组并汇总各分组的指标，统计保存在本地文件
+type Runner struct {
+	mu        sync.Mutex
+	name      string
+	variants  []Variant
+	templates map[string]*template.Template
+	total     float64
+	stats     map[string]*VariantStats
+	path      string
+	rand      *rand.Rand
+}
+
+// New 创建实验，没有分组时返回 nil，表示不进行实验
+func New(cfg Config, path string) (*Runner, error) {
+	if len(cfg.Variants) == 0 {
+		return nil, nil
+	}
+	if cfg.Name == "" {
+		return nil, errors.New("experiment name is required")
+	}
+
+	r := &Runner{
+		name:      cfg.Name,
+		variants:  cfg.Variants,
+		templates: make(map[string]*template.Template),
+		stats:     make(map[string]*VariantStats),
+		path:      path,
+		rand:      rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	for _, v := range cfg.Variants {
+		if v.Name == "" {
+			return nil, errors.New("variant name is required")
+		}
+		if _, exists := r.stats[v.Name]; exists {
+			return nil, fmt.Errorf("duplicate variant %s", v.Name)
+		}
+		if v.Weight < 0 {
+			return nil, fmt.Errorf("invalid weight for variant %s: %v", v.Name, v.Weight)
+		}
+		if v.Template != "" {
+			tmpl, err := template.New(v.Name).Parse(v.Template)
+			if err != nil {
+				return nil, fmt.Errorf("invalid template for variant %s: %v", v.Name, err)
+			}
+			r.templates[v.Name] = tmpl
+		}
+		r.total += v.Weight
+		r.stats[v.Name] = &VariantStats{Variant: v.Name}
+	}
+	if r.total == 0 {
+		return nil, errors.New("at least one variant must have a positive weight")
+	}
+	r.load()
+	return r, nil
+}
+
+// load 加载同名实验已有的统计
+func (r *Runner) load() {
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		return
+	}
+	var report Report
+	if json.Unmarshal(data, &report) != nil || report.Experiment != r.name {
+		return
+	}
+	for _, st := range report.Variants {
+		if _, ok := r.stats[st.Variant]; ok {
+			r.stats[st.Variant] = st
+		}
+	}
+}
+
+// Name 返回实验名称，没有实验时返回空字符串
+func (r *Runner) Name() string {
+	if r == nil {
+		return ""
+	}
+	return r.name
+}
+
+// Assign 按权重随机选择一个分组
+func (r *Runner) Assign() *Assignment {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n := r.rand.Float64() * r.total
+	chosen := r.variants[len(r.variants)-1]
+	for _, v := range r.variants {
+		if n < v.Weight {
+			chosen = v
+			break
+		}
+		n -= v.Weight
+	}
+	return &Assignment{
+		Experiment: r.name,
+		Variant:    chosen.Name,
+		Model:      chosen.Model,
+		template:   r.templates[chosen.Name],
+	}
+}
+
+// RecordRequest 记录一次请求的延迟和是否失败
+func (r *Runner) RecordRequest(variant string, latency time.Duration, failed bool) {
+	r.update(variant, func(st *VariantStats) {
+		st.Requests++
+		st.LatencySumMs += float64(latency) / float64(time.Millisecond)
+		if failed {
+			st.Errors++
+		}
+	})
+}
+
+// RecordOutcome 记录用户对分组生成结果的处理：accepted、partial 或 rejected
+func (r *Runner) RecordOutcome(variant, outcome string) {
+	r.update(variant, func(st *VariantStats) {
+		switch outcome {
+		case "accepted":
+			st.Accepted++
+		case "partial":
+			st.Partial++
+		case "rejected":
+			st.Rejected++
+		}
+	})
+}
+
+// update 更新分组统计并保存
+func (r *Runner) update(variant string, fn func(st *VariantStats)) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	st, ok := r.stats[variant]
+	if !ok {
+		return
+	}
+	fn(st)
+	if err := r.save(); err != nil {
+		log.Printf("保存实验统计失败: %v\n", err)
+	}
+}
+
+// save 保存统计，调用方需持有锁
+func (r *Runner) save() error {
+	data, err := json.MarshalIndent(r.report(), "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(r.path, data, 0644)
+}
+
+// Report 返回实验各分组的统计
+func (r *Runner) Report() *Report {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.report()
+}
+
+// report 按配置顺序汇总统计，调用方需持有锁
+func (r *Runner) report() *Report {
+	report := &Report{Experiment: r.name}
+	for _, v := range r.variants {
+		st := *r.stats[v.Name]
+		if st.Requests > 0 {
+			st.AvgLatencyMs = st.LatencySumMs / float64(st.Requests)
+		}
+		if outcomes := st.Accepted + st.Partial + st.Rejected; outcomes > 0 {
+			st.AcceptanceRate = float64(st.Accepted+st.Partial) / float64(outcomes)
+		}
+		report.Variants = append(report.Variants, &st)
+	}
+	return report
+}
@@ -0,0 +1,71 @@
+package experiment
+
+import (
+	"math/rand"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRunnerAssign(t *testing.T) {
+	r, err := New(Config{
+		Name: "prompt",
+		Variants: []Variant{
+			{Name: "control", Weight: 3},
+			{Name: "terse", Template: "Complete {{.Path}}: {{.Prefix}}", Weight: 1},
+			{Name: "disabled", Weight: 0},
+		},
+	}, filepath.Join(t.TempDir(), "experiments.json"))
+	if err != nil {
+		t.Fatalf("failed to create experiment: %v", err)
+	}
+	r.rand = rand.New(rand.NewSource(1))
+
+	counts := make(map[string]int)
+	for i := 0; i < 4000; i++ {
+		counts[r.Assign().Variant]++
+	}
+	if counts["disabled"] != 0 {
+		t.Errorf("expected zero-weight variant to never be assigned, got %d", counts["disabled"])
+	}
+	if ratio := float64(counts["control"]) / float64(counts["terse"]); ratio < 2.5 || ratio > 3.5 {
+		t.Errorf("expected roughly 3:1 assignment, got %v", counts)
+	}
+
+	a := &Assignment{Variant: "terse", template: r.templates["terse"]}
+	prompt, ok, err := a.Render(struct{ Path, Prefix string }{"main.go", "fmt."})
+	if err != nil || !ok || prompt != "Complete main.go: fmt." {
+		t.Errorf("unexpected render result %q %v %v", prompt, ok, err)
+	}
+	if _, ok, _ := (&Assignment{Variant: "control"}).Render(nil); ok {
+		t.Error("expected variant without template to fall back")
+	}
+}
+
+func TestRunnerReport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "experiments.json")
+	cfg := Config{Name: "prompt", Variants: []Variant{{Name: "a", Weight: 1}, {Name: "b", Weight: 1}}}
+	r, err := New(cfg, path)
+	if err != nil {
+		t.Fatalf("failed to create experiment: %v", err)
+	}
+	r.RecordRequest("a", 100*time.Millisecond, false)
+	r.RecordRequest("a", 300*time.Millisecond, true)
+	r.RecordOutcome("a", "accepted")
+	r.RecordOutcome("a", "rejected")
+	r.RecordOutcome("unknown", "accepted")
+
+	// 重新加载后统计保留
+	r, err = New(cfg, path)
+	if err != nil {
+		t.Fatalf("failed to reload experiment: %v", err)
+	}
+	st := r.Report().Variants[0]
+	if st.Requests != 2 || st.Errors != 1 || st.AvgLatencyMs != 200 || st.AcceptanceRate != 0.5 {
+		t.Errorf("unexpected stats: %+v", st)
+	}
+
+	if _, err := New(Config{Name: "bad", Variants: []Variant{{Name: "a", Template: "{{"}}}, path); err == nil {
+		t.Error("expected invalid template to be rejected")
+	}
+}
@@ -0,0 +1,260 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/liangsj/vimcoplit/internal/core/merge"
+	"github.com/liangsj/vimcoplit/internal/core/stacktrace"
+	"github.com/liangsj/vimcoplit/internal/core/textedit"
+)
+
+const (
+	// defaultExplainContext 是错误位置前后默认加入上下文的行数
+	defaultExplainContext = 10
+
+	// maxExplainRegions 是加入上下文的代码片段的最大数量，堆栈中靠前的位置优先
+	maxExplainRegions = 5
+)
+
+// ExplainRequest 是解释错误的请求，Error 为编译器输出或运行时堆栈
+type ExplainRequest struct {
+	Error        string `json:"error"`
+	ContextLines int    `json:"context_lines,omitempty"` // 错误位置前后加入上下文的行数
+}
+
+// CodeRegion 是加入上下文的代码片段，Path 相对工作区根目录
+type CodeRegion struct {
+	Path      string `json:"path"`
+	StartLine int    `json:"start_line"`
+	EndLine   int    `json:"end_line"`
+	Content   string `json:"content"`
+}
+
+// FixEdit 是建议的修改：用 Replacement 替换 Path 中 StartLine 到 EndLine 的行，
+// EndLine 为 StartLine-1 时表示在 StartLine 之前插入，设置列时只替换行内的范围
+type FixEdit struct {
+	Path string `json:"path"`
+	textedit.Edit
+	Diff string `json:"diff"` // 应用修改后的统一 diff，供插件预览
+}
+
+// ErrorExplanation 是错误的解释和建议的修改
+type ErrorExplanation struct {
+	Explanation string                `json:"explanation"`
+	Locations   []stacktrace.Location `json:"locations"`
+	Regions     []CodeRegion          `json:"regions"`
+	Edits       []FixEdit             `json:"edits"`
+}
+
+// ExplainError 解析错误文本中的文件和行号，把对应的代码片段加入上下文，
+// 由模型给出解释和修改建议
+func (s *serviceImpl) ExplainError(ctx context.Context, req *ExplainRequest) (*ErrorExplanation, error) {
+	if strings.TrimSpace(req.Error) == "" {
+		return nil, errors.New("error text is required")
+	}
+	lines := req.ContextLines
+	if lines <= 0 {
+		lines = defaultExplainContext
+	}
+
+	result := &ErrorExplanation{
+		Locations: stacktrace.Parse(req.Error),
+		Regions:   []CodeRegion{},
+		Edits:     []FixEdit{},
+	}
+	for _, loc := range result.Locations {
+		if len(result.Regions) == maxExplainRegions {
+			break
+		}
+		region, ok := s.codeRegion(loc, lines)
+		if ok {
+			result.Regions = mergeRegion(result.Regions, region)
+		}
+	}
+
+	generated, err := s.GenerateStructured(ctx, &StructuredRequest{
+		Prompt: explainPrompt(req.Error, result.Regions),
+		Schema: json.RawMessage(explainSchema),
+	})
+	var structErr *StructuredError
+	if errors.As(err, &structErr) {
+		// 修正后仍没有按格式返回时把最后一次的输出作为解释
+		result.Explanation = strings.TrimSpace(structErr.Output)
+		return result, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var parsed struct {
+		Explanation string    `json:"explanation"`
+		Edits       []FixEdit `json:"edits"`
+	}
+	if err := json.Unmarshal(generated.Value, &parsed); err != nil {
+		return nil, err
+	}
+	result.Explanation = parsed.Explanation
+	for _, edit := range parsed.Edits {
+		if err := s.previewEdit(&edit, result.Regions); err != nil {
+			log.Printf("忽略无效的修改建议: %v\n", err)
+			continue
+		}
+		result.Edits = append(result.Edits, edit)
+	}
+	return result, nil
+}
+
+// codeRegion 读取位置前后 lines 行的代码，工作区以外、被忽略或不存在的文件返回 false
+func (s *serviceImpl) codeRegion(loc stacktrace.Location, lines int) (CodeRegion, bool) {
+	root := s.related.Root()
+	path := loc.Path
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(root, path)
+	}
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return CodeRegion{}, false
+	}
+	rel = filepath.ToSlash(rel)
+	if s.settings.get().Ignored(rel) {
+		return CodeRegion{}, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return CodeRegion{}, false
+	}
+
+	fileLines := strings.SplitAfter(string(data), "\n")
+	if fileLines[len(fileLines)-1] == "" {
+		fileLines = fileLines[:len(fileLines)-1]
+	}
+	if loc.Line > len(fileLines) {
+		return CodeRegion{}, false
+	}
+	start := max(loc.Line-lines, 1)
+	end := min(loc.Line+lines, len(fileLines))
+	return CodeRegion{
+		Path:      rel,
+		StartLine: start,
+		EndLine:   end,
+		Content:   strings.Join(fileLines[start-1:end], ""),
+	}, true
+}
+
+// mergeRegion 加入代码片段，与同一文件中已有的片段重叠时合并为一段
+func mergeRegion(regions []CodeRegion, region CodeRegion) []CodeRegion {
+	for i, r := range regions {
+		if r.Path != region.Path || region.StartLine > r.EndLine+1 || region.EndLine < r.StartLine-1 {
+			continue
+		}
+		if region.StartLine < r.StartLine {
+			r.Content = firstLines(region.Content, r.StartLine-region.StartLine) + r.Content
+			r.StartLine = region.StartLine
+		}
+		if region.EndLine > r.EndLine {
+			r.Content += lastLines(region.Content, region.EndLine-r.EndLine)
+			r.EndLine = region.EndLine
+		}
+		regions[i] = r
+		return regions
+	}
+	return append(regions, region)
+}
+
+// firstLines 返回内容的前 n 行
+func firstLines(content string, n int) string {
+	lines := strings.SplitAfter(content, "\n")
+	return strings.Join(lines[:min(n, len(lines))], "")
+}
+
+// lastLines 返回内容的后 n 行，内容以换行结尾时不计最后的空行
+func lastLines(content string, n int) string {
+	lines := strings.SplitAfter(content, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines[max(len(lines)-n, 0):], "")
+}
+
+// previewEdit 校验修改建议只涉及加入上下文的文件，并计算应用后的 diff
+func (s *serviceImpl) previewEdit(edit *FixEdit, regions []CodeRegion) error {
+	known := false
+	for _, r := range regions {
+		if r.Path == edit.Path {
+			known = true
+			break
+		}
+	}
+	if !known {
+		return fmt.Errorf("edit for %s is outside the explained code", edit.Path)
+	}
+
+	data, err := os.ReadFile(filepath.Join(s.related.Root(), filepath.FromSlash(edit.Path)))
+	if err != nil {
+		return err
+	}
+	updated, err := edit.Apply(string(data))
+	if err != nil {
+		return err
+	}
+	edit.Diff = merge.Diff(edit.Path, string(data), updated)
+	return nil
+}
+
+// Apply 将修改应用到文件内容上，返回修改后的内容
+func (e *FixEdit) Apply(content string) (string, error) {
+	updated, err := textedit.Apply(content, []textedit.Edit{e.Edit})
+	if err != nil {
+		return "", fmt.Errorf("edit for %s: %v", e.Path, err)
+	}
+	return updated, nil
+}
+
+// explainSchema 是错误解释需要符合的 JSON Schema
+const explainSchema = `{
+  "type": "object",
+  "required": ["explanation", "edits"],
+  "properties": {
+    "explanation": {"type": "string", "description": "what went wrong and why"},
+    "edits": {
+      "type": "array",
+      "description": "leave empty if no code change is needed",
+      "items": {
+        "type": "object",
+        "required": ["path", "start_line", "end_line", "replacement"],
+        "properties": {
+          "path": {"type": "string", "description": "file path as shown in the prompt"},
+          "start_line": {"type": "integer"},
+          "end_line": {"type": "integer"},
+          "replacement": {"type": "string", "description": "new content for these lines"}
+        }
+      }
+    }
+  }
+}`
+
+// explainPrompt 构造解释错误的提示词，代码片段带行号以便模型给出准确的修改范围
+func explainPrompt(errText string, regions []CodeRegion) string {
+	var b strings.Builder
+	b.WriteString(`Explain the error below and suggest a fix.
+Only edit the files shown below. Leave "edits" empty if no code change is needed.
+
+Error:
+`)
+	b.WriteString(strings.TrimSpace(errText))
+	b.WriteString("\n")
+	for _, r := range regions {
+		fmt.Fprintf(&b, "\n--- %s (lines %d-%d)\n", r.Path, r.StartLine, r.EndLine)
+		for i, line := range strings.SplitAfter(strings.TrimSuffix(r.Content, "\n"), "\n") {
+			fmt.Fprintf(&b, "%5d| %s", r.StartLine+i, strings.TrimSuffix(line, "\n")+"\n")
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,186 @@
+package core
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// FeedbackOutcome 表示用户对建议的处理结果
+type FeedbackOutcome string
+
+const (
+	FeedbackAccepted FeedbackOutcome = "accepted"
+	FeedbackPartial  FeedbackOutcome = "partial"
+	FeedbackRejected FeedbackOutcome = "rejected"
+)
+
+// maxRecentSuggestions 是保留提示词元数据的最近建议条数
+const maxRecentSuggestions = 1024
+
+// SuggestionMeta 是生成建议时的提示词元数据
+type SuggestionMeta struct {
+	Kind         string `json:"kind"` // completion 或 edit
+	Model        string `json:"model"`
+	Template     string `json:"template"`
+	Language     string `json:"language,omitempty"`
+	PromptTokens int    `json:"prompt_tokens"`
+	Cached       bool   `json:"cached"`
+	Experiment   string `json:"experiment,omitempty"`
+	Variant      string `json:"variant,omitempty"`
+}
+
+// Feedback 是插件上报的一条建议处理结果
+type Feedback struct {
+	ID              string          `json:"id"`
+	SuggestionID    string          `json:"suggestion_id"`
+	Outcome         FeedbackOutcome `json:"outcome"`
+	AcceptedChars   int             `json:"accepted_chars,omitempty"` // 部分接受时接受的字符数
+	SuggestionChars int             `json:"suggestion_chars,omitempty"`
+	SuggestionMeta
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// FeedbackStats 是按模型和模板汇总的接受率
+type FeedbackStats struct {
+	Model          string  `json:"model"`
+	Template       string  `json:"template"`
+	Total          int     `json:"total"`
+	Accepted       int     `json:"accepted"`
+	Partial        int     `json:"partial"`
+	Rejected       int     `json:"rejected"`
+	AcceptanceRate float64 `json:"acceptance_rate"` // 完全或部分接受的比例
+}
+
+// feedbackStore 保存建议反馈，以 JSONL 格式追加，并在内存中保留最近建议的元数据
+type feedbackStore struct {
+	mu     sync.Mutex
+	path   string
+	recent map[string]SuggestionMeta
+	order  []string
+}
+
+// newFeedbackStore 创建建议反馈存储
+func newFeedbackStore(path string) *feedbackStore {
+	return &feedbackStore{
+		path:   path,
+		recent: make(map[string]SuggestionMeta),
+	}
+}
+
+// track 记录一条新建议的元数据，返回建议 ID
+func (s *feedbackStore) track(meta SuggestionMeta) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := uuid.New().String()
+	s.recent[id] = meta
+	s.order = append(s.order, id)
+	if len(s.order) > maxRecentSuggestions {
+		delete(s.recent, s.order[0])
+		s.order = s.order[1:]
+	}
+	return id
+}
+
+// record 保存一条反馈，已知建议的元数据会覆盖插件上报的值
+func (s *feedbackStore) record(fb *Feedback) (*Feedback, error) {
+	switch fb.Outcome {
+	case FeedbackAccepted, FeedbackPartial, FeedbackRejected:
+	default:
+		return nil, fmt.Errorf("invalid feedback outcome: %q", fb.Outcome)
+	}
+	if fb.SuggestionID == "" {
+		return nil, errors.New("suggestion_id is required")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry := *fb
+	entry.ID = uuid.New().String()
+	entry.CreatedAt = time.Now()
+	if meta, ok := s.recent[fb.SuggestionID]; ok {
+		entry.SuggestionMeta = meta
+	}
+
+	data, err := json.Marshal(&entry)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// stats 按模型和模板汇总所有反馈
+func (s *feedbackStore) stats() ([]*FeedbackStats, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.Open(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []*FeedbackStats{}, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	groups := make(map[[2]string]*FeedbackStats)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var fb Feedback
+		if err := json.Unmarshal(scanner.Bytes(), &fb); err != nil {
+			continue
+		}
+		key := [2]string{fb.Model, fb.Template}
+		st, ok := groups[key]
+		if !ok {
+			st = &FeedbackStats{Model: fb.Model, Template: fb.Template}
+			groups[key] = st
+		}
+		st.Total++
+		switch fb.Outcome {
+		case FeedbackAccepted:
+			st.Accepted++
+		case FeedbackPartial:
+			st.Partial++
+		case FeedbackRejected:
+			st.Rejected++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read feedback: %v", err)
+	}
+
+	stats := make([]*FeedbackStats, 0, len(groups))
+	for _, st := range groups {
+		st.AcceptanceRate = float64(st.Accepted+st.Partial) / float64(st.Total)
+		stats = append(stats, st)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Model != stats[j].Model {
+			return stats[i].Model < stats[j].Model
+		}
+		return stats[i].Template < stats[j].Template
+	})
+	return stats, nil
+}
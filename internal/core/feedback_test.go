@@ -0,0 +1,78 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFeedbackStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "feedback.jsonl")
+	s := newFeedbackStore(path)
+
+	if stats, err := s.stats(); err != nil || len(stats) != 0 {
+		t.Fatalf("expected no stats before feedback, got %v %v", stats, err)
+	}
+	if _, err := s.record(&Feedback{SuggestionID: "x", Outcome: "maybe"}); err == nil {
+		t.Error("expected an invalid outcome to fail")
+	}
+	if _, err := s.record(&Feedback{Outcome: FeedbackAccepted}); err == nil {
+		t.Error("expected a missing suggestion ID to fail")
+	}
+
+	// 已知建议的元数据覆盖插件上报的值
+	id := s.track(SuggestionMeta{Kind: "completion", Model: "claude", Template: "fim"})
+	entry, err := s.record(&Feedback{SuggestionID: id, Outcome: FeedbackPartial, SuggestionMeta: SuggestionMeta{Model: "spoofed"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.ID == "" || entry.Model != "claude" || entry.Template != "fim" || entry.CreatedAt.IsZero() {
+		t.Errorf("unexpected entry %+v", entry)
+	}
+
+	for _, fb := range []*Feedback{
+		{SuggestionID: s.track(SuggestionMeta{Model: "claude", Template: "fim"}), Outcome: FeedbackAccepted},
+		{SuggestionID: s.track(SuggestionMeta{Model: "claude", Template: "fim"}), Outcome: FeedbackRejected},
+		{SuggestionID: s.track(SuggestionMeta{Model: "claude", Template: "fim"}), Outcome: FeedbackRejected},
+		// 未知建议使用插件上报的元数据
+		{SuggestionID: "unknown", Outcome: FeedbackAccepted, SuggestionMeta: SuggestionMeta{Model: "deepseek", Template: "edit"}},
+	} {
+		if _, err := s.record(fb); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 损坏的行被跳过
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("{broken\n")
+	f.Close()
+
+	stats, err := newFeedbackStore(path).stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 2 {
+		t.Fatalf("expected 2 groups, got %d", len(stats))
+	}
+	claude, deepseek := stats[0], stats[1]
+	if claude.Model != "claude" || claude.Total != 4 || claude.Accepted != 1 || claude.Partial != 1 || claude.Rejected != 2 || claude.AcceptanceRate != 0.5 {
+		t.Errorf("unexpected claude stats %+v", claude)
+	}
+	if deepseek.Model != "deepseek" || deepseek.Total != 1 || deepseek.AcceptanceRate != 1 {
+		t.Errorf("unexpected deepseek stats %+v", deepseek)
+	}
+}
+
+func TestFeedbackStoreEvictsOldSuggestions(t *testing.T) {
+	s := newFeedbackStore(filepath.Join(t.TempDir(), "feedback.jsonl"))
+	first := s.track(SuggestionMeta{Model: "claude"})
+	for i := 0; i < maxRecentSuggestions; i++ {
+		s.track(SuggestionMeta{Model: "claude"})
+	}
+	if _, ok := s.recent[first]; ok || len(s.recent) != maxRecentSuggestions {
+		t.Errorf("expected the oldest suggestion to be evicted, kept %d", len(s.recent))
+	}
+}
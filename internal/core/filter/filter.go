@@ -0,0 +1,173 @@
+package filter
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Mode 表示过滤器命中后的处理方式
+type Mode string
+
+const (
+	ModeBlock Mode = "block" // 拦截，需要显式覆盖才能放行
+	ModeFlag  Mode = "flag"  // 放行但标记命中的规则
+	ModeOff   Mode = "off"   // 关闭过滤
+)
+
+// Rule 是一条危险内容匹配规则
+type Rule struct {
+	Name        string
+	Description string
+	Pattern     *regexp.Regexp
+}
+
+// Finding 表示一次规则命中
+type Finding struct {
+	Rule        string `json:"rule"`
+	Description string `json:"description"`
+	Match       string `json:"match"`
+}
+
+// Config 定义了过滤器配置
+type Config struct {
+	Mode     Mode
+	Patterns []string // 额外的正则规则
+	Disabled []string // 禁用的内置规则名
+}
+
+// BlockedError 表示内容因命中规则被拦截
+type BlockedError struct {
+	Findings []Finding
+}
+
+func (e *BlockedError) Error() string {
+	rules := make([]string, 0, len(e.Findings))
+	for _, f := range e.Findings {
+		rules = append(rules, f.Rule)
+	}
+	return fmt.Sprintf("blocked by output filter: %s", strings.Join(rules, ", "))
+}
+
+// builtinRules 是内置的危险命令规则
+var builtinRules = []Rule{
+	{
+		Name:        "rm_root",
+		Description: "recursive delete of root, home or all files",
+		Pattern:     regexp.MustCompile(`\brm\s+(-[a-zA-Z]*\s+)*-[a-zA-Z]*[rR][a-zA-Z]*\s+(-[a-zA-Z-]+\s+)*(/|~|\$HOME|/\*|\*)(\s|$|;|&|\|)`),
+	},
+	{
+		Name:        "pipe_to_shell",
+		Description: "downloaded script piped into a shell",
+		Pattern:     regexp.MustCompile(`\b(curl|wget)\b[^|\n]*\|\s*(sudo\s+)?(sh|bash|zsh|dash|python[0-9.]*|perl)\b`),
+	},
+	{
+		Name:        "credential_exfiltration",
+		Description: "credential files or environment sent over the network",
+		Pattern:     regexp.MustCompile(`(\.ssh/id_[a-z0-9]+|\.aws/credentials|\.netrc|\.docker/config\.json|\bprintenv\b|\benv\b)[^\n]*\|\s*(curl|wget|nc|ncat)\b|\b(curl|wget)\b[^\n]*(-d|--data[a-z-]*|-F|--upload-file|-T)\s+@?\S*(\.ssh/id_|\.aws/credentials|\.netrc)`),
+	},
+	{
+		Name:        "fork_bomb",
+		Description: "shell fork bomb",
+		Pattern:     regexp.MustCompile(`:\(\)\s*\{\s*:\s*\|\s*:\s*&\s*\}\s*;\s*:`),
+	},
+	{
+		Name:        "disk_wipe",
+		Description: "formatting or overwriting a block device",
+		Pattern:     regexp.MustCompile(`\bmkfs(\.[a-z0-9]+)?\s+/dev/|\bdd\b[^\n]*\bof=/dev/(sd|nvme|hd|disk|mmcblk)|>\s*/dev/(sd|nvme|hd)[a-z0-9]*\b`),
+	},
+	{
+		Name:        "chmod_root",
+		Description: "recursive permission change on root",
+		Pattern:     regexp.MustCompile(`\bchmod\s+-R\s+[0-7]{3,4}\s+/(\s|$)`),
+	},
+}
+
+// Filter 在模型输出到达执行器或用户之前扫描危险内容
+type Filter struct {
+	mode  Mode
+	rules []Rule
+}
+
+// New 根据配置创建过滤器
+func New(cfg Config) (*Filter, error) {
+	mode := cfg.Mode
+	switch mode {
+	case "":
+		mode = ModeBlock
+	case ModeBlock, ModeFlag, ModeOff:
+	default:
+		return nil, fmt.Errorf("unsupported filter mode: %s", cfg.Mode)
+	}
+
+	disabled := make(map[string]bool, len(cfg.Disabled))
+	for _, name := range cfg.Disabled {
+		disabled[name] = true
+	}
+
+	f := &Filter{mode: mode}
+	for _, rule := range builtinRules {
+		if !disabled[rule.Name] {
+			f.rules = append(f.rules, rule)
+		}
+	}
+	for i, pattern := range cfg.Patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid filter pattern %q: %v", pattern, err)
+		}
+		f.rules = append(f.rules, Rule{
+			Name:        fmt.Sprintf("custom_%d", i+1),
+			Description: "custom pattern " + pattern,
+			Pattern:     re,
+		})
+	}
+	return f, nil
+}
+
+// Mode 返回过滤器的处理方式
+func (f *Filter) Mode() Mode {
+	return f.mode
+}
+
+// Scan 返回文本命中的所有规则
+func (f *Filter) Scan(text string) []Finding {
+	if f.mode == ModeOff {
+		return nil
+	}
+	var findings []Finding
+	for _, rule := range f.rules {
+		if match := rule.Pattern.FindString(text); match != "" {
+			findings = append(findings, Finding{
+				Rule:        rule.Name,
+				Description: rule.Description,
+				Match:       strings.TrimSpace(match),
+			})
+		}
+	}
+	return findings
+}
+
+// Check 扫描文本，拦截模式下有命中且 ctx 未声明覆盖时返回 *BlockedError
+// 无论是否拦截都会返回命中的规则，供调用方标记给用户
+func (f *Filter) Check(ctx context.Context, text string) ([]Finding, error) {
+	findings := f.Scan(text)
+	if len(findings) > 0 && f.mode == ModeBlock && !IsOverridden(ctx) {
+		return findings, &BlockedError{Findings: findings}
+	}
+	return findings, nil
+}
+
+type overrideKey struct{}
+
+// WithOverride 返回声明覆盖过滤器的 context，用户明确确认后才应使用
+func WithOverride(ctx context.Context) context.Context {
+	return context.WithValue(ctx, overrideKey{}, true)
+}
+
+// IsOverridden 判断 ctx 是否声明了覆盖过滤器
+func IsOverridden(ctx context.Context) bool {
+	overridden, _ := ctx.Value(overrideKey{}).(bool)
+	return overridden
+}
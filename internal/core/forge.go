@@ -0,0 +1,354 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/liangsj/vimcoplit/internal/config"
+	"github.com/liangsj/vimcoplit/internal/core/forge"
+	"github.com/liangsj/vimcoplit/internal/core/mcp"
+)
+
+// ErrForgeDisabled 表示没有配置代码托管平台或配置无效
+var ErrForgeDisabled = errors.New("forge integration is not configured")
+
+// ForgeStatus 是代码托管平台集成的状态
+type ForgeStatus struct {
+	Enabled  bool   `json:"enabled"`
+	Provider string `json:"provider,omitempty"`
+	Repo     string `json:"repo,omitempty"`
+}
+
+// PullRequestRequest 是创建 PR 的请求。Head 为空时使用工作区当前分支，Base 为空时使用仓库的默认分支，
+// Push 为 true 时先把 Head 推送到配置的远程仓库
+type PullRequestRequest struct {
+	forge.NewPullRequest
+	Push bool `json:"push"`
+}
+
+// newForge 按配置创建代码托管平台客户端，未配置或配置无效时返回 nil
+func newForge(cfg *config.Config) forge.Provider {
+	provider := cfg.Forge.Provider
+	if provider == "" {
+		return nil
+	}
+	token := cfg.Forge.Token
+	if token == "" {
+		token = os.Getenv(strings.ToUpper(provider) + "_TOKEN")
+	}
+	repo := cfg.Forge.Repo
+	if repo == "" {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		var err error
+		if repo, err = inferForgeRepo(ctx, cfg); err != nil {
+			log.Printf("forge integration disabled: %v", err)
+			return nil
+		}
+	}
+	p, err := forge.New(forge.Options{
+		Provider: provider,
+		URL:      cfg.Forge.URL,
+		Token:    token,
+		Repo:     repo,
+		Timeout:  cfg.Forge.Timeout.Std(),
+	}, nil)
+	if err != nil {
+		log.Printf("forge integration disabled: %v", err)
+		return nil
+	}
+	return p
+}
+
+// inferForgeRepo 从工作区远程仓库的地址推断仓库路径，地址的主机需要与平台一致
+func inferForgeRepo(ctx context.Context, cfg *config.Config) (string, error) {
+	remote, err := runGit(ctx, cfg.WorkspaceRoot(), "remote", "get-url", cfg.Forge.Remote)
+	if err != nil {
+		return "", fmt.Errorf("repo is not configured and %w", err)
+	}
+	host, repo, ok := forge.ParseRemote(remote)
+	if !ok {
+		return "", fmt.Errorf("cannot infer the repository from remote %q", remote)
+	}
+	want := cfg.Forge.Provider + ".com"
+	if cfg.Forge.URL != "" {
+		if u, err := url.Parse(cfg.Forge.URL); err == nil {
+			want = u.Hostname()
+		}
+	}
+	if host != want {
+		return "", fmt.Errorf("remote %s is on %s, not %s; set forge.repo", cfg.Forge.Remote, host, want)
+	}
+	return repo, nil
+}
+
+// runGit 在工作区执行 git 命令并返回去掉首尾空白的输出，失败时错误中带有 git 的报错
+func runGit(ctx context.Context, root string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = root
+	output, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			message, _, _ := strings.Cut(strings.TrimSpace(string(exitErr.Stderr)), "\n")
+			return "", fmt.Errorf("git %s failed: %s", strings.Join(args, " "), message)
+		}
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// forgeProvider 返回可用的平台客户端，未配置或离线时返回错误
+func (s *serviceImpl) forgeProvider() (forge.Provider, error) {
+	if s.forge == nil {
+		return nil, ErrForgeDisabled
+	}
+	if s.offline.Offline() {
+		return nil, errors.New("forge integration is unavailable while offline")
+	}
+	return s.forge, nil
+}
+
+// ForgeStatus 返回代码托管平台集成的状态
+func (s *serviceImpl) ForgeStatus() *ForgeStatus {
+	if s.forge == nil {
+		return &ForgeStatus{}
+	}
+	return &ForgeStatus{Enabled: true, Provider: s.forge.Name(), Repo: s.forge.Repo()}
+}
+
+// ListIssues 列出仓库的 issue
+func (s *serviceImpl) ListIssues(ctx context.Context, opts *forge.ListOptions) ([]*forge.Issue, error) {
+	p, err := s.forgeProvider()
+	if err != nil {
+		return nil, err
+	}
+	return p.ListIssues(ctx, opts)
+}
+
+// GetIssue 返回 issue 及其评论
+func (s *serviceImpl) GetIssue(ctx context.Context, number int) (*forge.Issue, error) {
+	p, err := s.forgeProvider()
+	if err != nil {
+		return nil, err
+	}
+	return p.GetIssue(ctx, number)
+}
+
+// ListPullRequests 列出仓库的 PR
+func (s *serviceImpl) ListPullRequests(ctx context.Context, opts *forge.ListOptions) ([]*forge.PullRequest, error) {
+	p, err := s.forgeProvider()
+	if err != nil {
+		return nil, err
+	}
+	return p.ListPullRequests(ctx, opts)
+}
+
+// GetPullRequest 返回 PR 及其评论
+func (s *serviceImpl) GetPullRequest(ctx context.Context, number int) (*forge.PullRequest, error) {
+	p, err := s.forgeProvider()
+	if err != nil {
+		return nil, err
+	}
+	return p.GetPullRequest(ctx, number)
+}
+
+// PostComment 在 issue 或 PR 下发表评论
+func (s *serviceImpl) PostComment(ctx context.Context, kind forge.Kind, number int, body string) (*forge.Comment, error) {
+	p, err := s.forgeProvider()
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(body) == "" {
+		return nil, errors.New("comment body is required")
+	}
+	return p.Comment(ctx, kind, number, body)
+}
+
+// CreatePullRequest 为工作区中 agent 生成的分支创建 PR，需要时先推送分支
+func (s *serviceImpl) CreatePullRequest(ctx context.Context, req *PullRequestRequest) (*forge.PullRequest, error) {
+	p, err := s.forgeProvider()
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(req.Title) == "" {
+		return nil, errors.New("pull request title is required")
+	}
+	root := s.cfg.WorkspaceRoot()
+	pr := req.NewPullRequest
+	if pr.Head == "" {
+		if pr.Head, err = runGit(ctx, root, "rev-parse", "--abbrev-ref", "HEAD"); err != nil {
+			return nil, err
+		}
+		if pr.Head == "HEAD" {
+			return nil, errors.New("the workspace is on a detached HEAD, check out a branch or set head")
+		}
+	}
+	if strings.HasPrefix(pr.Head, "-") {
+		return nil, fmt.Errorf("invalid branch name %q", pr.Head)
+	}
+	if pr.Base == "" {
+		if pr.Base, err = p.DefaultBranch(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if pr.Head == pr.Base {
+		return nil, fmt.Errorf("head and base are both %q, create a branch for the change first", pr.Head)
+	}
+	if req.Push {
+		if _, err := runGit(ctx, root, "check-ref-format", "--branch", pr.Head); err != nil {
+			return nil, err
+		}
+		if _, err := runGit(ctx, root, "push", "--set-upstream", s.cfg.Forge.Remote, pr.Head); err != nil {
+			return nil, err
+		}
+	}
+	return p.CreatePullRequest(ctx, &pr)
+}
+
+// registerForgeTools 注册访问 GitHub 或 GitLab 上 issue 和 PR 的内置工具，
+// 支持“修复 issue #123”的完整流程：阅读 issue、在分支上修改并提交、创建 PR 和回复评论
+func registerForgeTools(manager *mcp.Manager, s *serviceImpl) {
+	kindParam := mcp.ToolParameter{
+		Name:        "kind",
+		Type:        "string",
+		Description: "issue or pull_request",
+		Required:    true,
+	}
+	numberParam := mcp.ToolParameter{
+		Name:        "number",
+		Type:        "number",
+		Description: "issue or pull request number as shown on the site (the iid on GitLab)",
+		Required:    true,
+	}
+	target := func(params map[string]interface{}) (forge.Kind, int, error) {
+		kind, _ := params["kind"].(string)
+		if kind != string(forge.KindIssue) && kind != string(forge.KindPullRequest) {
+			return "", 0, fmt.Errorf("invalid kind %q, expected issue or pull_request", kind)
+		}
+		number, _ := params["number"].(float64)
+		if number <= 0 {
+			return "", 0, errors.New("number is required")
+		}
+		return forge.Kind(kind), int(number), nil
+	}
+
+	manager.RegisterBuiltinTool(&mcp.Tool{
+		ID:          "forge_list",
+		Name:        "forge_list",
+		Description: "List issues or pull requests (merge requests on GitLab) of the workspace repository on GitHub or GitLab",
+		Parameters: []mcp.ToolParameter{kindParam, {
+			Name:        "state",
+			Type:        "string",
+			Description: "open (default), closed, merged (pull requests only) or all",
+		}, {
+			Name:        "labels",
+			Type:        "string",
+			Description: "comma-separated labels that every result must have",
+		}, {
+			Name:        "limit",
+			Type:        "number",
+			Description: "maximum number of results, defaults to 30",
+		}},
+	}, func(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+		opts := &forge.ListOptions{}
+		opts.State, _ = params["state"].(string)
+		if labels, _ := params["labels"].(string); labels != "" {
+			opts.Labels = strings.Split(labels, ",")
+		}
+		limit, _ := params["limit"].(float64)
+		opts.Limit = int(limit)
+		switch kind, _ := params["kind"].(string); kind {
+		case string(forge.KindIssue):
+			return s.ListIssues(ctx, opts)
+		case string(forge.KindPullRequest):
+			return s.ListPullRequests(ctx, opts)
+		default:
+			return nil, fmt.Errorf("invalid kind %q, expected issue or pull_request", kind)
+		}
+	})
+
+	manager.RegisterBuiltinTool(&mcp.Tool{
+		ID:          "forge_read",
+		Name:        "forge_read",
+		Description: "Read an issue or pull request with its description and comments",
+		Parameters:  []mcp.ToolParameter{kindParam, numberParam},
+	}, func(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+		kind, number, err := target(params)
+		if err != nil {
+			return nil, err
+		}
+		if kind == forge.KindIssue {
+			return s.GetIssue(ctx, number)
+		}
+		return s.GetPullRequest(ctx, number)
+	})
+
+	manager.RegisterBuiltinTool(&mcp.Tool{
+		ID:          "forge_comment",
+		Name:        "forge_comment",
+		Description: "Post a comment on an issue or pull request. The comment is public on the repository",
+		Parameters: []mcp.ToolParameter{kindParam, numberParam, {
+			Name:        "body",
+			Type:        "string",
+			Description: "comment text in Markdown",
+			Required:    true,
+		}},
+	}, func(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+		kind, number, err := target(params)
+		if err != nil {
+			return nil, err
+		}
+		body, _ := params["body"].(string)
+		return s.PostComment(ctx, kind, number, body)
+	})
+
+	manager.RegisterBuiltinTool(&mcp.Tool{
+		ID:   "forge_create_pr",
+		Name: "forge_create_pr",
+		Description: "Open a pull request (merge request on GitLab) from a branch with committed changes. " +
+			"Mention the issue in the body, e.g. \"Closes #123\", to link it",
+		Parameters: []mcp.ToolParameter{{
+			Name:        "title",
+			Type:        "string",
+			Description: "pull request title",
+			Required:    true,
+		}, {
+			Name:        "body",
+			Type:        "string",
+			Description: "pull request description in Markdown",
+		}, {
+			Name:        "head",
+			Type:        "string",
+			Description: "branch with the changes, defaults to the current branch of the workspace",
+		}, {
+			Name:        "base",
+			Type:        "string",
+			Description: "branch to merge into, defaults to the repository's default branch",
+		}, {
+			Name:        "draft",
+			Type:        "boolean",
+			Description: "open as a draft",
+		}, {
+			Name:        "push",
+			Type:        "boolean",
+			Description: "push the head branch to the remote before opening the pull request",
+		}},
+	}, func(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+		req := &PullRequestRequest{}
+		req.Title, _ = params["title"].(string)
+		req.Body, _ = params["body"].(string)
+		req.Head, _ = params["head"].(string)
+		req.Base, _ = params["base"].(string)
+		req.Draft, _ = params["draft"].(bool)
+		req.Push, _ = params["push"].(bool)
+		return s.CreatePullRequest(ctx, req)
+	})
+}
@@ -0,0 +1,288 @@
+// Package forge 访问代码托管平台（GitHub、GitLab）上仓库的 issue 和 PR（GitLab 中为 merge request），
+// 用于列出和阅读 issue、发表评论以及为 agent 生成的分支创建 PR。两个平台的差异在这里抹平，
+// 状态统一为 open、closed 和 merged，编号使用平台上显示的编号（GitLab 的 iid）
+package forge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrNotFound 表示仓库、issue 或 PR 不存在，私有仓库在 Token 没有权限时也返回该错误
+	ErrNotFound = errors.New("not found")
+	// ErrUnauthorized 表示没有配置 Token 或 Token 无效、权限不足
+	ErrUnauthorized = errors.New("forge token is missing or lacks permission")
+)
+
+// 默认限制
+const (
+	DefaultTimeout = 30 * time.Second
+	DefaultLimit   = 30
+	maxLimit       = 100
+	maxComments    = 100
+)
+
+// maxErrorBody 是请求失败时错误信息中保留的响应体长度
+const maxErrorBody = 300
+
+// Kind 区分评论的对象
+type Kind string
+
+const (
+	KindIssue       Kind = "issue"
+	KindPullRequest Kind = "pull_request"
+)
+
+// Options 是平台的配置
+type Options struct {
+	Provider string        // github 或 gitlab
+	URL      string        // API 地址，为空时使用 https://api.github.com 或 https://gitlab.com/api/v4
+	Token    string        // 访问令牌，GitHub 需要 repo（或 issues、pull_requests）权限，GitLab 需要 api 权限
+	Repo     string        // 仓库，GitHub 为 owner/name，GitLab 为项目的完整路径，如 group/subgroup/name
+	Timeout  time.Duration // 单次请求的超时
+}
+
+// Comment 是 issue 或 PR 下的一条评论
+type Comment struct {
+	ID        int64     `json:"id"`
+	Author    string    `json:"author"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+	URL       string    `json:"url,omitempty"`
+}
+
+// Issue 是一个 issue，列表中不包含 Comments
+type Issue struct {
+	Number    int       `json:"number"`
+	Title     string    `json:"title"`
+	Body      string    `json:"body"`
+	State     string    `json:"state"` // open、closed，PR 还可能是 merged
+	Author    string    `json:"author"`
+	Labels    []string  `json:"labels"`
+	URL       string    `json:"url"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Comments  []Comment `json:"comments,omitempty"`
+}
+
+// PullRequest 是一个 PR 或 merge request
+type PullRequest struct {
+	Issue
+	Head  string `json:"head"` // 源分支
+	Base  string `json:"base"` // 目标分支
+	Draft bool   `json:"draft"`
+}
+
+// ListOptions 是列出 issue 或 PR 的条件
+type ListOptions struct {
+	State  string   `json:"state"`  // open（默认）、closed、merged（只对 PR 有效）或 all
+	Labels []string `json:"labels"` // 同时带有这些标签
+	Limit  int      `json:"limit"`  // 最多返回的数量，默认 30，上限 100
+}
+
+// NewPullRequest 是创建 PR 的参数
+type NewPullRequest struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+	Head  string `json:"head"` // 源分支，需要已经推送到远程仓库
+	Base  string `json:"base"` // 目标分支
+	Draft bool   `json:"draft"`
+}
+
+// Provider 是一个平台上的仓库
+type Provider interface {
+	Name() string
+	Repo() string
+	DefaultBranch(ctx context.Context) (string, error)
+	ListIssues(ctx context.Context, opts *ListOptions) ([]*Issue, error)
+	GetIssue(ctx context.Context, number int) (*Issue, error) // 包括评论
+	ListPullRequests(ctx context.Context, opts *ListOptions) ([]*PullRequest, error)
+	GetPullRequest(ctx context.Context, number int) (*PullRequest, error) // 包括评论
+	Comment(ctx context.Context, kind Kind, number int, body string) (*Comment, error)
+	CreatePullRequest(ctx context.Context, req *NewPullRequest) (*PullRequest, error)
+}
+
+// Providers 返回支持的平台
+func Providers() []string {
+	return []string{"github", "gitlab"}
+}
+
+// New 创建平台客户端，client 为 nil 时使用 http.DefaultClient。平台未知或缺少 Token、仓库时返回错误
+func New(opts Options, client *http.Client) (Provider, error) {
+	if opts.Token == "" {
+		return nil, fmt.Errorf("%w: %s requires a token", ErrUnauthorized, opts.Provider)
+	}
+	if strings.Count(strings.Trim(opts.Repo, "/"), "/") < 1 {
+		return nil, fmt.Errorf("invalid repository %q, expected owner/name", opts.Repo)
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	c := &apiClient{provider: opts.Provider, timeout: opts.Timeout, client: client, header: http.Header{}}
+	repo := strings.Trim(opts.Repo, "/")
+	switch opts.Provider {
+	case "github":
+		if strings.Count(repo, "/") != 1 {
+			return nil, fmt.Errorf("invalid GitHub repository %q, expected owner/name", opts.Repo)
+		}
+		c.baseURL = "https://api.github.com"
+		c.header.Set("Authorization", "Bearer "+opts.Token)
+		c.header.Set("Accept", "application/vnd.github+json")
+		c.header.Set("X-GitHub-Api-Version", "2022-11-28")
+		c.setURL(opts.URL)
+		return &github{api: c, repo: repo}, nil
+	case "gitlab":
+		c.baseURL = "https://gitlab.com/api/v4"
+		c.header.Set("PRIVATE-TOKEN", opts.Token)
+		c.setURL(opts.URL)
+		return &gitlab{api: c, repo: repo}, nil
+	}
+	return nil, fmt.Errorf("unknown forge provider %q, expected one of %s", opts.Provider, strings.Join(Providers(), ", "))
+}
+
+// ParseRemote 从 git 远程仓库地址中解析主机和仓库路径，支持 https://、ssh:// 和 git@host:path 格式
+func ParseRemote(remote string) (host, repo string, ok bool) {
+	remote = strings.TrimSpace(remote)
+	if u, err := url.Parse(remote); err == nil && u.Host != "" {
+		host, repo = u.Hostname(), u.Path
+	} else if at, rest, found := strings.Cut(remote, "@"); found && !strings.Contains(at, "/") {
+		host, repo, _ = strings.Cut(rest, ":")
+	} else {
+		return "", "", false
+	}
+	repo = strings.TrimSuffix(strings.Trim(repo, "/"), ".git")
+	if host == "" || !strings.Contains(repo, "/") {
+		return "", "", false
+	}
+	return host, repo, true
+}
+
+// limit 返回列表的数量
+func (o *ListOptions) limit() int {
+	if o == nil || o.Limit <= 0 {
+		return DefaultLimit
+	}
+	return min(o.Limit, maxLimit)
+}
+
+// state 返回列表的状态条件
+func (o *ListOptions) state() (string, error) {
+	if o == nil || o.State == "" {
+		return "open", nil
+	}
+	switch o.State {
+	case "open", "closed", "merged", "all":
+		return o.State, nil
+	}
+	return "", fmt.Errorf("invalid state %q, expected open, closed, merged or all", o.State)
+}
+
+// labels 返回标签条件
+func (o *ListOptions) labels() []string {
+	if o == nil {
+		return nil
+	}
+	return o.Labels
+}
+
+// hasLabels 判断 have 是否包含 want 中的每个标签
+func hasLabels(have, want []string) bool {
+	for _, w := range want {
+		found := false
+		for _, h := range have {
+			if strings.EqualFold(h, w) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// apiClient 发送 JSON 请求并把错误状态码转换为 ErrNotFound、ErrUnauthorized
+type apiClient struct {
+	provider string
+	baseURL  string
+	header   http.Header
+	timeout  time.Duration
+	client   *http.Client
+}
+
+// setURL 使用配置的 API 地址，用于 GitHub Enterprise 和自托管的 GitLab
+func (c *apiClient) setURL(u string) {
+	if u != "" {
+		c.baseURL = strings.TrimSuffix(u, "/")
+	}
+}
+
+// do 发送请求，path 中的参数需要由调用方转义。in 不为 nil 时作为 JSON 请求体，响应解析到 out 中
+func (c *apiClient) do(ctx context.Context, method, path string, query url.Values, in, out interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	u := c.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return err
+	}
+	req.Header = c.header.Clone()
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s request failed: %w", c.provider, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 8<<20))
+	if err != nil {
+		return fmt.Errorf("%s request failed: %w", c.provider, err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message := strings.TrimSpace(string(data))
+		if len(message) > maxErrorBody {
+			message = strings.ToValidUTF8(message[:maxErrorBody], "") + "..."
+		}
+		switch resp.StatusCode {
+		case http.StatusUnauthorized, http.StatusForbidden:
+			return fmt.Errorf("%w: %s %s: %s", ErrUnauthorized, c.provider, resp.Status, message)
+		case http.StatusNotFound:
+			return fmt.Errorf("%s %s %w: %s", c.provider, path, ErrNotFound, message)
+		}
+		return fmt.Errorf("%s request failed: %s: %s", c.provider, resp.Status, message)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("%s returned an invalid response: %w", c.provider, err)
+	}
+	return nil
+}
@@ -0,0 +1,238 @@
+package forge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// fakeAPI 按 "方法 路径" 返回固定的 JSON，记录收到的请求
+type fakeAPI struct {
+	t         *testing.T
+	responses map[string]string
+	requests  []*http.Request
+	bodies    []map[string]interface{}
+}
+
+func (f *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.requests = append(f.requests, r)
+	var body map[string]interface{}
+	if data, _ := io.ReadAll(r.Body); len(data) > 0 {
+		if err := json.Unmarshal(data, &body); err != nil {
+			f.t.Errorf("invalid request body %q", data)
+		}
+	}
+	f.bodies = append(f.bodies, body)
+	resp, ok := f.responses[r.Method+" "+r.URL.EscapedPath()]
+	if !ok {
+		http.Error(w, `{"message":"Not Found"}`, http.StatusNotFound)
+		return
+	}
+	io.WriteString(w, resp)
+}
+
+func newFake(t *testing.T, provider, repo string, responses map[string]string) (Provider, *fakeAPI) {
+	t.Helper()
+	fake := &fakeAPI{t: t, responses: responses}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	p, err := New(Options{Provider: provider, URL: server.URL, Token: "secret", Repo: repo}, server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p, fake
+}
+
+func TestGitHubIssues(t *testing.T) {
+	p, fake := newFake(t, "github", "acme/app", map[string]string{
+		"GET /repos/acme/app/issues": `[
+			{"number": 123, "title": "Crash on save", "state": "open", "user": {"login": "ann"}, "labels": [{"name": "bug"}], "html_url": "https://github.com/acme/app/issues/123"},
+			{"number": 124, "title": "Fix crash", "state": "open", "user": {"login": "bob"}, "pull_request": {}}
+		]`,
+		"GET /repos/acme/app/issues/123":          `{"number": 123, "title": "Crash on save", "body": "Steps...", "state": "open", "user": {"login": "ann"}, "labels": []}`,
+		"GET /repos/acme/app/issues/123/comments": `[{"id": 9, "user": {"login": "bob"}, "body": "Reproduced", "html_url": "https://github.com/acme/app/issues/123#issuecomment-9"}]`,
+	})
+
+	issues, err := p.ListIssues(context.Background(), &ListOptions{Labels: []string{"bug"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// issues 接口返回的 PR 被过滤掉
+	if len(issues) != 1 || issues[0].Number != 123 || issues[0].Author != "ann" || issues[0].Labels[0] != "bug" {
+		t.Errorf("unexpected issues %+v", issues)
+	}
+	req := fake.requests[0]
+	if req.Header.Get("Authorization") != "Bearer secret" || req.URL.Query().Get("state") != "open" || req.URL.Query().Get("labels") != "bug" {
+		t.Errorf("unexpected request %s %v", req.URL, req.Header)
+	}
+
+	issue, err := p.GetIssue(context.Background(), 123)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if issue.Body != "Steps..." || len(issue.Comments) != 1 || issue.Comments[0].Author != "bob" {
+		t.Errorf("unexpected issue %+v", issue)
+	}
+
+	if _, err := p.GetIssue(context.Background(), 999); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if _, err := p.ListIssues(context.Background(), &ListOptions{State: "merged"}); err == nil {
+		t.Error("expected merged to be rejected for issues")
+	}
+}
+
+func TestGitHubPullRequests(t *testing.T) {
+	p, fake := newFake(t, "github", "acme/app", map[string]string{
+		"GET /repos/acme/app": `{"default_branch": "main"}`,
+		"GET /repos/acme/app/pulls": `[
+			{"number": 7, "title": "Old", "state": "closed", "merged_at": "2024-01-02T00:00:00Z", "head": {"ref": "old"}, "base": {"ref": "main"}},
+			{"number": 8, "title": "Rejected", "state": "closed", "merged_at": null, "head": {"ref": "no"}, "base": {"ref": "main"}}
+		]`,
+		"POST /repos/acme/app/pulls":             `{"number": 9, "title": "Fix #123", "state": "open", "draft": true, "head": {"ref": "fix-123"}, "base": {"ref": "main"}, "html_url": "https://github.com/acme/app/pull/9"}`,
+		"POST /repos/acme/app/issues/9/comments": `{"id": 1, "user": {"login": "bot"}, "body": "Ready"}`,
+	})
+
+	branch, err := p.DefaultBranch(context.Background())
+	if err != nil || branch != "main" {
+		t.Fatalf("expected main, got %q %v", branch, err)
+	}
+	pulls, err := p.ListPullRequests(context.Background(), &ListOptions{State: "merged"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pulls) != 1 || pulls[0].Number != 7 || pulls[0].State != "merged" || pulls[0].Head != "old" {
+		t.Errorf("expected only the merged pull request, got %+v", pulls)
+	}
+	if q := fake.requests[1].URL.Query(); q.Get("state") != "closed" {
+		t.Errorf("expected merged to be queried as closed, got %v", q)
+	}
+
+	pr, err := p.CreatePullRequest(context.Background(), &NewPullRequest{Title: "Fix #123", Body: "Closes #123", Head: "fix-123", Base: "main", Draft: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pr.Number != 9 || !pr.Draft || pr.URL == "" {
+		t.Errorf("unexpected pull request %+v", pr)
+	}
+	body := fake.bodies[2]
+	if body["head"] != "fix-123" || body["base"] != "main" || body["body"] != "Closes #123" || body["draft"] != true {
+		t.Errorf("unexpected create body %v", body)
+	}
+
+	comment, err := p.Comment(context.Background(), KindPullRequest, 9, "Ready")
+	if err != nil || comment.ID != 1 || fake.bodies[3]["body"] != "Ready" {
+		t.Errorf("unexpected comment %+v %v", comment, err)
+	}
+}
+
+func TestGitLab(t *testing.T) {
+	p, fake := newFake(t, "gitlab", "group/sub/app", map[string]string{
+		"GET /projects/group%2Fsub%2Fapp/issues":           `[{"iid": 5, "title": "Slow", "state": "opened", "author": {"username": "ann"}, "labels": ["perf"]}]`,
+		"GET /projects/group%2Fsub%2Fapp/merge_requests/3": `{"iid": 3, "title": "Speed up", "state": "merged", "source_branch": "fast", "target_branch": "main", "draft": false}`,
+		"GET /projects/group%2Fsub%2Fapp/merge_requests/3/notes": `[
+			{"id": 1, "author": {"username": "bot"}, "body": "merged", "system": true},
+			{"id": 2, "author": {"username": "bob"}, "body": "LGTM"}
+		]`,
+		"POST /projects/group%2Fsub%2Fapp/issues/5/notes": `{"id": 3, "author": {"username": "bot"}, "body": "On it"}`,
+		"POST /projects/group%2Fsub%2Fapp/merge_requests": `{"iid": 4, "title": "Draft: Fix #5", "state": "opened", "source_branch": "fix-5", "target_branch": "main", "draft": true}`,
+	})
+
+	issues, err := p.ListIssues(context.Background(), &ListOptions{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(issues) != 1 || issues[0].Number != 5 || issues[0].State != "open" {
+		t.Errorf("unexpected issues %+v", issues)
+	}
+	req := fake.requests[0]
+	if req.Header.Get("PRIVATE-TOKEN") != "secret" || req.URL.Query().Get("state") != "opened" || req.URL.Query().Get("per_page") != "10" {
+		t.Errorf("unexpected request %s %v", req.URL, req.Header)
+	}
+
+	mr, err := p.GetPullRequest(context.Background(), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 系统生成的记录不作为评论
+	if mr.State != "merged" || mr.Head != "fast" || len(mr.Comments) != 1 || mr.Comments[0].Body != "LGTM" {
+		t.Errorf("unexpected merge request %+v", mr)
+	}
+
+	if _, err := p.Comment(context.Background(), KindIssue, 5, "On it"); err != nil {
+		t.Fatal(err)
+	}
+	// merge request 与 issue 分别编号，评论 issue 不能落到同号的 merge request 上
+	if _, err := p.Comment(context.Background(), KindPullRequest, 5, "On it"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected merge request 5 to be missing, got %v", err)
+	}
+
+	pr, err := p.CreatePullRequest(context.Background(), &NewPullRequest{Title: "Fix #5", Head: "fix-5", Base: "main", Draft: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := fake.bodies[len(fake.bodies)-1]
+	if body["title"] != "Draft: Fix #5" || body["source_branch"] != "fix-5" || body["target_branch"] != "main" {
+		t.Errorf("unexpected create body %v", body)
+	}
+	if pr.Number != 4 || !pr.Draft {
+		t.Errorf("unexpected merge request %+v", pr)
+	}
+}
+
+func TestUnauthorized(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"message":"Bad credentials"}`, http.StatusUnauthorized)
+	}))
+	defer server.Close()
+	p, err := New(Options{Provider: "github", URL: server.URL, Token: "bad", Repo: "acme/app"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.ListIssues(context.Background(), nil); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("expected ErrUnauthorized, got %v", err)
+	}
+}
+
+func TestNewValidates(t *testing.T) {
+	tests := []Options{
+		{Provider: "github", Repo: "acme/app"},
+		{Provider: "github", Token: "t", Repo: "app"},
+		{Provider: "github", Token: "t", Repo: "group/sub/app"},
+		{Provider: "bitbucket", Token: "t", Repo: "acme/app"},
+	}
+	for _, opts := range tests {
+		if _, err := New(opts, nil); err == nil {
+			t.Errorf("%+v: expected an error", opts)
+		}
+	}
+	if _, err := New(Options{Provider: "gitlab", Token: "t", Repo: "group/sub/app"}, nil); err != nil {
+		t.Errorf("expected nested GitLab groups, got %v", err)
+	}
+}
+
+func TestParseRemote(t *testing.T) {
+	tests := []struct {
+		remote, host, repo string
+	}{
+		{"https://github.com/acme/app.git", "github.com", "acme/app"},
+		{"https://token@gitlab.example.com:8443/group/sub/app", "gitlab.example.com", "group/sub/app"},
+		{"git@github.com:acme/app.git", "github.com", "acme/app"},
+		{"ssh://git@gitlab.com:2222/group/app.git", "gitlab.com", "group/app"},
+	}
+	for _, tt := range tests {
+		host, repo, ok := ParseRemote(tt.remote)
+		if !ok || host != tt.host || repo != tt.repo {
+			t.Errorf("%s: expected %s %s, got %s %s %v", tt.remote, tt.host, tt.repo, host, repo, ok)
+		}
+	}
+	for _, remote := range []string{"", "/srv/git/app.git", "https://github.com/app"} {
+		if _, _, ok := ParseRemote(remote); ok {
+			t.Errorf("%q: expected no match", remote)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package forge
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// github 通过 REST API v3 访问 GitHub 和 GitHub Enterprise
+type github struct {
+	api  *apiClient
+	repo string
+}
+
+// githubUser、githubLabel 等是 GitHub 响应中用到的字段
+type githubUser struct {
+	Login string `json:"login"`
+}
+
+type githubLabel struct {
+	Name string `json:"name"`
+}
+
+type githubIssue struct {
+	Number      int           `json:"number"`
+	Title       string        `json:"title"`
+	Body        string        `json:"body"`
+	State       string        `json:"state"`
+	User        githubUser    `json:"user"`
+	Labels      []githubLabel `json:"labels"`
+	HTMLURL     string        `json:"html_url"`
+	CreatedAt   time.Time     `json:"created_at"`
+	UpdatedAt   time.Time     `json:"updated_at"`
+	PullRequest *struct{}     `json:"pull_request"` // issues 接口也会返回 PR，用这个字段区分
+}
+
+type githubPull struct {
+	githubIssue
+	Head     struct{ Ref string } `json:"head"`
+	Base     struct{ Ref string } `json:"base"`
+	Draft    bool                 `json:"draft"`
+	MergedAt *time.Time           `json:"merged_at"`
+}
+
+type githubComment struct {
+	ID        int64      `json:"id"`
+	User      githubUser `json:"user"`
+	Body      string     `json:"body"`
+	CreatedAt time.Time  `json:"created_at"`
+	HTMLURL   string     `json:"html_url"`
+}
+
+func (i *githubIssue) convert() *Issue {
+	issue := &Issue{
+		Number:    i.Number,
+		Title:     i.Title,
+		Body:      i.Body,
+		State:     i.State,
+		Author:    i.User.Login,
+		Labels:    []string{},
+		URL:       i.HTMLURL,
+		CreatedAt: i.CreatedAt,
+		UpdatedAt: i.UpdatedAt,
+	}
+	for _, l := range i.Labels {
+		issue.Labels = append(issue.Labels, l.Name)
+	}
+	return issue
+}
+
+func (p *githubPull) convert() *PullRequest {
+	pr := &PullRequest{Issue: *p.githubIssue.convert(), Head: p.Head.Ref, Base: p.Base.Ref, Draft: p.Draft}
+	if p.MergedAt != nil {
+		pr.State = "merged"
+	}
+	return pr
+}
+
+func (c *githubComment) convert() Comment {
+	return Comment{ID: c.ID, Author: c.User.Login, Body: c.Body, CreatedAt: c.CreatedAt, URL: c.HTMLURL}
+}
+
+func (g *github) Name() string { return "github" }
+func (g *github) Repo() string { return g.repo }
+
+// path 返回仓库下的 API 路径
+func (g *github) path(format string, args ...interface{}) string {
+	return "/repos/" + g.repo + fmt.Sprintf(format, args...)
+}
+
+func (g *github) DefaultBranch(ctx context.Context) (string, error) {
+	var repo struct {
+		DefaultBranch string `json:"default_branch"`
+	}
+	if err := g.api.do(ctx, http.MethodGet, g.path(""), nil, nil, &repo); err != nil {
+		return "", err
+	}
+	return repo.DefaultBranch, nil
+}
+
+func (g *github) ListIssues(ctx context.Context, opts *ListOptions) ([]*Issue, error) {
+	state, err := opts.state()
+	if err != nil {
+		return nil, err
+	}
+	if state == "merged" {
+		return nil, fmt.Errorf("invalid state %q for issues", state)
+	}
+	limit := opts.limit()
+	query := url.Values{"state": {state}, "per_page": {strconv.Itoa(maxLimit)}}
+	if labels := opts.labels(); len(labels) > 0 {
+		query.Set("labels", strings.Join(labels, ","))
+	}
+	var items []githubIssue
+	if err := g.api.do(ctx, http.MethodGet, g.path("/issues"), query, nil, &items); err != nil {
+		return nil, err
+	}
+	issues := []*Issue{}
+	for i := range items {
+		if items[i].PullRequest == nil && len(issues) < limit {
+			issues = append(issues, items[i].convert())
+		}
+	}
+	return issues, nil
+}
+
+func (g *github) GetIssue(ctx context.Context, number int) (*Issue, error) {
+	var item githubIssue
+	if err := g.api.do(ctx, http.MethodGet, g.path("/issues/%d", number), nil, nil, &item); err != nil {
+		return nil, err
+	}
+	if item.PullRequest != nil {
+		return nil, fmt.Errorf("#%d is a pull request, not an issue", number)
+	}
+	issue := item.convert()
+	comments, err := g.comments(ctx, number)
+	if err != nil {
+		return nil, err
+	}
+	issue.Comments = comments
+	return issue, nil
+}
+
+func (g *github) ListPullRequests(ctx context.Context, opts *ListOptions) ([]*PullRequest, error) {
+	state, err := opts.state()
+	if err != nil {
+		return nil, err
+	}
+	// pulls 接口没有 merged 状态，也不支持按标签过滤，取回后再过滤
+	query := url.Values{"state": {state}, "per_page": {strconv.Itoa(maxLimit)}}
+	if state == "merged" {
+		query.Set("state", "closed")
+	}
+	var items []githubPull
+	if err := g.api.do(ctx, http.MethodGet, g.path("/pulls"), query, nil, &items); err != nil {
+		return nil, err
+	}
+	limit := opts.limit()
+	pulls := []*PullRequest{}
+	for i := range items {
+		pr := items[i].convert()
+		if (state == "merged" && pr.State != "merged") || !hasLabels(pr.Labels, opts.labels()) || len(pulls) >= limit {
+			continue
+		}
+		pulls = append(pulls, pr)
+	}
+	return pulls, nil
+}
+
+func (g *github) GetPullRequest(ctx context.Context, number int) (*PullRequest, error) {
+	var item githubPull
+	if err := g.api.do(ctx, http.MethodGet, g.path("/pulls/%d", number), nil, nil, &item); err != nil {
+		return nil, err
+	}
+	pr := item.convert()
+	comments, err := g.comments(ctx, number)
+	if err != nil {
+		return nil, err
+	}
+	pr.Comments = comments
+	return pr, nil
+}
+
+// comments 返回 issue 或 PR 对话中的评论，PR 的行内评审意见不包括在内
+func (g *github) comments(ctx context.Context, number int) ([]Comment, error) {
+	var items []githubComment
+	query := url.Values{"per_page": {strconv.Itoa(maxComments)}}
+	if err := g.api.do(ctx, http.MethodGet, g.path("/issues/%d/comments", number), query, nil, &items); err != nil {
+		return nil, err
+	}
+	comments := make([]Comment, 0, len(items))
+	for i := range items {
+		comments = append(comments, items[i].convert())
+	}
+	return comments, nil
+}
+
+// Comment 发表评论，GitHub 的 issue 和 PR 共用编号和评论接口
+func (g *github) Comment(ctx context.Context, kind Kind, number int, body string) (*Comment, error) {
+	if kind != KindIssue && kind != KindPullRequest {
+		return nil, fmt.Errorf("invalid comment target %q, expected %s or %s", kind, KindIssue, KindPullRequest)
+	}
+	var item githubComment
+	in := map[string]string{"body": body}
+	if err := g.api.do(ctx, http.MethodPost, g.path("/issues/%d/comments", number), nil, in, &item); err != nil {
+		return nil, err
+	}
+	comment := item.convert()
+	return &comment, nil
+}
+
+func (g *github) CreatePullRequest(ctx context.Context, req *NewPullRequest) (*PullRequest, error) {
+	in := map[string]interface{}{
+		"title": req.Title,
+		"body":  req.Body,
+		"head":  req.Head,
+		"base":  req.Base,
+		"draft": req.Draft,
+	}
+	var item githubPull
+	if err := g.api.do(ctx, http.MethodPost, g.path("/pulls"), nil, in, &item); err != nil {
+		return nil, err
+	}
+	return item.convert(), nil
+}
@@ -0,0 +1,235 @@
+package forge
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// gitlab 通过 REST API v4 访问 GitLab.com 和自托管的 GitLab，PR 对应 merge request
+type gitlab struct {
+	api  *apiClient
+	repo string
+}
+
+// gitlabIssue、gitlabNote 等是 GitLab 响应中用到的字段
+type gitlabUser struct {
+	Username string `json:"username"`
+}
+
+type gitlabIssue struct {
+	IID         int        `json:"iid"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	State       string     `json:"state"` // opened、closed，merge request 还有 merged 和 locked
+	Author      gitlabUser `json:"author"`
+	Labels      []string   `json:"labels"`
+	WebURL      string     `json:"web_url"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+type gitlabMergeRequest struct {
+	gitlabIssue
+	SourceBranch string `json:"source_branch"`
+	TargetBranch string `json:"target_branch"`
+	Draft        bool   `json:"draft"`
+}
+
+type gitlabNote struct {
+	ID        int64      `json:"id"`
+	Author    gitlabUser `json:"author"`
+	Body      string     `json:"body"`
+	CreatedAt time.Time  `json:"created_at"`
+	System    bool       `json:"system"` // 状态变化等系统生成的记录
+}
+
+func (i *gitlabIssue) convert() *Issue {
+	state := i.State
+	switch state {
+	case "opened", "locked":
+		state = "open"
+	}
+	labels := i.Labels
+	if labels == nil {
+		labels = []string{}
+	}
+	return &Issue{
+		Number:    i.IID,
+		Title:     i.Title,
+		Body:      i.Description,
+		State:     state,
+		Author:    i.Author.Username,
+		Labels:    labels,
+		URL:       i.WebURL,
+		CreatedAt: i.CreatedAt,
+		UpdatedAt: i.UpdatedAt,
+	}
+}
+
+func (m *gitlabMergeRequest) convert() *PullRequest {
+	return &PullRequest{Issue: *m.gitlabIssue.convert(), Head: m.SourceBranch, Base: m.TargetBranch, Draft: m.Draft}
+}
+
+func (n *gitlabNote) convert() Comment {
+	return Comment{ID: n.ID, Author: n.Author.Username, Body: n.Body, CreatedAt: n.CreatedAt}
+}
+
+func (g *gitlab) Name() string { return "gitlab" }
+func (g *gitlab) Repo() string { return g.repo }
+
+// path 返回项目下的 API 路径，项目路径转义后作为 ID
+func (g *gitlab) path(format string, args ...interface{}) string {
+	return "/projects/" + url.PathEscape(g.repo) + fmt.Sprintf(format, args...)
+}
+
+// listQuery 把列表条件转换为 GitLab 的查询参数
+func (g *gitlab) listQuery(opts *ListOptions) (url.Values, error) {
+	state, err := opts.state()
+	if err != nil {
+		return nil, err
+	}
+	query := url.Values{"per_page": {strconv.Itoa(opts.limit())}}
+	switch state {
+	case "open":
+		query.Set("state", "opened")
+	case "closed", "merged":
+		query.Set("state", state)
+	}
+	if labels := opts.labels(); len(labels) > 0 {
+		query.Set("labels", strings.Join(labels, ","))
+	}
+	return query, nil
+}
+
+func (g *gitlab) DefaultBranch(ctx context.Context) (string, error) {
+	var project struct {
+		DefaultBranch string `json:"default_branch"`
+	}
+	if err := g.api.do(ctx, http.MethodGet, g.path(""), nil, nil, &project); err != nil {
+		return "", err
+	}
+	return project.DefaultBranch, nil
+}
+
+func (g *gitlab) ListIssues(ctx context.Context, opts *ListOptions) ([]*Issue, error) {
+	query, err := g.listQuery(opts)
+	if err != nil {
+		return nil, err
+	}
+	if query.Get("state") == "merged" {
+		return nil, fmt.Errorf("invalid state %q for issues", "merged")
+	}
+	var items []gitlabIssue
+	if err := g.api.do(ctx, http.MethodGet, g.path("/issues"), query, nil, &items); err != nil {
+		return nil, err
+	}
+	issues := make([]*Issue, 0, len(items))
+	for i := range items {
+		issues = append(issues, items[i].convert())
+	}
+	return issues, nil
+}
+
+func (g *gitlab) GetIssue(ctx context.Context, number int) (*Issue, error) {
+	var item gitlabIssue
+	if err := g.api.do(ctx, http.MethodGet, g.path("/issues/%d", number), nil, nil, &item); err != nil {
+		return nil, err
+	}
+	issue := item.convert()
+	comments, err := g.notes(ctx, "issues", number)
+	if err != nil {
+		return nil, err
+	}
+	issue.Comments = comments
+	return issue, nil
+}
+
+func (g *gitlab) ListPullRequests(ctx context.Context, opts *ListOptions) ([]*PullRequest, error) {
+	query, err := g.listQuery(opts)
+	if err != nil {
+		return nil, err
+	}
+	var items []gitlabMergeRequest
+	if err := g.api.do(ctx, http.MethodGet, g.path("/merge_requests"), query, nil, &items); err != nil {
+		return nil, err
+	}
+	pulls := make([]*PullRequest, 0, len(items))
+	for i := range items {
+		pulls = append(pulls, items[i].convert())
+	}
+	return pulls, nil
+}
+
+func (g *gitlab) GetPullRequest(ctx context.Context, number int) (*PullRequest, error) {
+	var item gitlabMergeRequest
+	if err := g.api.do(ctx, http.MethodGet, g.path("/merge_requests/%d", number), nil, nil, &item); err != nil {
+		return nil, err
+	}
+	pr := item.convert()
+	comments, err := g.notes(ctx, "merge_requests", number)
+	if err != nil {
+		return nil, err
+	}
+	pr.Comments = comments
+	return pr, nil
+}
+
+// notes 返回 issue 或 merge request 下用户发表的评论，按时间顺序排列
+func (g *gitlab) notes(ctx context.Context, kind string, number int) ([]Comment, error) {
+	var items []gitlabNote
+	query := url.Values{"sort": {"asc"}, "order_by": {"created_at"}, "per_page": {strconv.Itoa(maxComments)}}
+	if err := g.api.do(ctx, http.MethodGet, g.path("/%s/%d/notes", kind, number), query, nil, &items); err != nil {
+		return nil, err
+	}
+	comments := make([]Comment, 0, len(items))
+	for i := range items {
+		if !items[i].System {
+			comments = append(comments, items[i].convert())
+		}
+	}
+	return comments, nil
+}
+
+// Comment 发表评论，GitLab 的 issue 和 merge request 分别编号
+func (g *gitlab) Comment(ctx context.Context, kind Kind, number int, body string) (*Comment, error) {
+	var collection string
+	switch kind {
+	case KindIssue:
+		collection = "issues"
+	case KindPullRequest:
+		collection = "merge_requests"
+	default:
+		return nil, fmt.Errorf("invalid comment target %q, expected %s or %s", kind, KindIssue, KindPullRequest)
+	}
+	var item gitlabNote
+	in := map[string]string{"body": body}
+	if err := g.api.do(ctx, http.MethodPost, g.path("/%s/%d/notes", collection, number), nil, in, &item); err != nil {
+		return nil, err
+	}
+	comment := item.convert()
+	return &comment, nil
+}
+
+// CreatePullRequest 创建 merge request，草稿通过标题的 Draft: 前缀标记
+func (g *gitlab) CreatePullRequest(ctx context.Context, req *NewPullRequest) (*PullRequest, error) {
+	title := req.Title
+	if req.Draft && !strings.HasPrefix(title, "Draft:") {
+		title = "Draft: " + title
+	}
+	in := map[string]interface{}{
+		"title":         title,
+		"description":   req.Body,
+		"source_branch": req.Head,
+		"target_branch": req.Base,
+	}
+	var item gitlabMergeRequest
+	if err := g.api.do(ctx, http.MethodPost, g.path("/merge_requests"), nil, in, &item); err != nil {
+		return nil, err
+	}
+	return item.convert(), nil
+}
@@ -0,0 +1,635 @@
+// Package fulltext 是对话记录、任务和命令输出的全文索引，用于在几周后按内容找回某次会话。
+// 索引由调用方按记录的更新时间增量同步，文档保存在数据目录中，重启后只需同步变化的记录。
+//
+// 没有使用 SQLite FTS5：默认构建不包含 SQLite 驱动（见 dbquery），而且 modernc.org/sqlite
+// 会让二进制大上不少，所以这里是纯 Go 的 BM25 实现，倒排表在加载时由保存的文档重建。
+//
+// 查询语法与 SQLite FTS5 相近：空格分隔的词都必须出现，"..." 为短语，
+// 词尾的 * 按前缀匹配，以 - 开头的词或短语不能出现
+package fulltext
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// defaultLimit 是查询未指定数量时返回的结果数
+	defaultLimit = 20
+
+	// maxLimit 是一次查询最多返回的结果数
+	maxLimit = 200
+
+	// snippetRadius 是摘要在第一个匹配前后保留的字节数
+	snippetRadius = 80
+
+	// partGap 是相邻部分之间空出的位置数，短语不会跨部分匹配
+	partGap = 1
+)
+
+// BM25 参数
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// ErrEmptyQuery 表示查询中没有需要出现的词
+var ErrEmptyQuery = errors.New("query has no search terms")
+
+// Kind 是被索引记录的类型
+type Kind string
+
+const (
+	KindConversation Kind = "conversation"
+	KindTask         Kind = "task"
+	KindCommand      Kind = "command" // 命令历史中的一次执行及其输出
+)
+
+// Part 是文档中单独定位的一段文本，Ref 标识它在记录中的位置：
+// 对话为消息 ID，任务和命令为字段名
+type Part struct {
+	Ref  string `json:"ref"`
+	Text string `json:"text"`
+}
+
+// Document 是一条被索引的记录，UpdatedAt 和 Archived 用于判断索引是否需要更新
+type Document struct {
+	Kind      Kind      `json:"kind"`
+	ID        string    `json:"id"`
+	Title     string    `json:"title,omitempty"`
+	Archived  bool      `json:"archived,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Parts     []Part    `json:"parts"`
+}
+
+// Query 是全文检索请求，零值的过滤字段不参与过滤
+type Query struct {
+	Text  string    `json:"query"`
+	Kinds []Kind    `json:"kinds,omitempty"`
+	Since time.Time `json:"since,omitempty"` // 最后更新不早于
+	Until time.Time `json:"until,omitempty"` // 最后更新早于
+	Limit int       `json:"limit,omitempty"`
+}
+
+// Result 是一条检索结果，Snippet 取自匹配最多的部分，
+// Highlights 为匹配在 Snippet 中的字节区间 [start, end)
+type Result struct {
+	Kind       Kind      `json:"kind"`
+	ID         string    `json:"id"`
+	Title      string    `json:"title,omitempty"`
+	Ref        string    `json:"ref,omitempty"`
+	Archived   bool      `json:"archived,omitempty"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	Score      float64   `json:"score"`
+	Snippet    string    `json:"snippet"`
+	Highlights [][2]int  `json:"highlights,omitempty"`
+}
+
+// doc 是已索引的文档及其每个词出现的位置
+type doc struct {
+	Document
+	starts []int // 每个部分第一个词的位置
+	length int
+	terms  map[string][]int
+}
+
+// Index 是全文索引
+type Index struct {
+	mu       sync.RWMutex
+	docs     map[string]*doc
+	postings map[string]map[string]*doc // 词 -> 文档键 -> 文档
+	totalLen int
+	path     string // 保存文档的文件，为空时只保存在内存中
+	dirty    bool   // 上次保存后文档有变化
+}
+
+// New 创建全文索引并加载 path 中保存的文档，path 为空时索引只保存在内存中。
+// 文件不存在或损坏时从空索引开始，由调用方重新同步
+func New(path string) *Index {
+	x := &Index{
+		docs:     make(map[string]*doc),
+		postings: make(map[string]map[string]*doc),
+		path:     path,
+	}
+	if path == "" {
+		return x
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return x
+	}
+	var docs []*Document
+	if err := json.Unmarshal(data, &docs); err != nil {
+		return x
+	}
+	for _, d := range docs {
+		x.Put(d)
+	}
+	x.dirty = false
+	return x
+}
+
+// Save 在文档有变化时写入文件，先写临时文件再改名，写入中断时保留上次的内容
+func (x *Index) Save() error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if x.path == "" || !x.dirty {
+		return nil
+	}
+	keys := make([]string, 0, len(x.docs))
+	for k := range x.docs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	docs := make([]*Document, len(keys))
+	for i, k := range keys {
+		docs[i] = &x.docs[k].Document
+	}
+	data, err := json.Marshal(docs)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(x.path), 0755); err != nil {
+		return err
+	}
+	tmp := x.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, x.path); err != nil {
+		return err
+	}
+	x.dirty = false
+	return nil
+}
+
+// key 返回文档在索引中的键
+func key(kind Kind, id string) string {
+	return string(kind) + "/" + id
+}
+
+// Len 返回索引中的文档数
+func (x *Index) Len() int {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	return len(x.docs)
+}
+
+// Current 判断索引中的文档是否与给定的更新时间和归档状态一致，不一致或不存在时需要重新加入
+func (x *Index) Current(kind Kind, id string, updatedAt time.Time, archived bool) bool {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	d, ok := x.docs[key(kind, id)]
+	return ok && d.UpdatedAt.Equal(updatedAt) && d.Archived == archived
+}
+
+// Put 加入文档，同类型同 ID 的文档会被替换
+func (x *Index) Put(document *Document) {
+	d := &doc{Document: *document, terms: make(map[string][]int)}
+	pos := 0
+	for _, part := range d.Parts {
+		d.starts = append(d.starts, pos)
+		n := 0
+		for _, tok := range tokenize(part.Text) {
+			d.terms[tok.term] = append(d.terms[tok.term], pos+tok.pos)
+			n = tok.pos + 1
+		}
+		pos += n + partGap
+	}
+	d.length = pos
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	k := key(d.Kind, d.ID)
+	x.remove(k)
+	x.docs[k] = d
+	x.totalLen += d.length
+	x.dirty = true
+	for term := range d.terms {
+		docs := x.postings[term]
+		if docs == nil {
+			docs = make(map[string]*doc)
+			x.postings[term] = docs
+		}
+		docs[k] = d
+	}
+}
+
+// Remove 删除文档
+func (x *Index) Remove(kind Kind, id string) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.remove(key(kind, id))
+}
+
+// Prune 删除 keep 返回 false 的文档，返回删除的数量
+func (x *Index) Prune(keep func(kind Kind, id string) bool) int {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	removed := 0
+	for k, d := range x.docs {
+		if !keep(d.Kind, d.ID) {
+			x.remove(k)
+			removed++
+		}
+	}
+	return removed
+}
+
+// remove 删除文档及其倒排记录，调用方需持有锁
+func (x *Index) remove(k string) {
+	d, ok := x.docs[k]
+	if !ok {
+		return
+	}
+	for term := range d.terms {
+		docs := x.postings[term]
+		delete(docs, k)
+		if len(docs) == 0 {
+			delete(x.postings, term)
+		}
+	}
+	x.totalLen -= d.length
+	delete(x.docs, k)
+	x.dirty = true
+}
+
+// Search 按 BM25 得分倒序返回满足查询的文档，得分相同时最近更新的在前
+func (x *Index) Search(q *Query) ([]*Result, error) {
+	clauses, err := parseQuery(q.Text)
+	if err != nil {
+		return nil, err
+	}
+	limit := q.Limit
+	if limit <= 0 {
+		limit = defaultLimit
+	}
+	limit = min(limit, maxLimit)
+	kinds := make(map[Kind]bool, len(q.Kinds))
+	for _, kind := range q.Kinds {
+		kinds[kind] = true
+	}
+
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	// 每个条件展开前缀后的词，用于取候选文档和计算得分
+	expanded := make([][][]string, len(clauses))
+	for i, c := range clauses {
+		expanded[i] = x.expand(c)
+	}
+
+	type scored struct {
+		doc   *doc
+		score float64
+	}
+	var hits []scored
+	for _, d := range x.candidates(clauses, expanded) {
+		if len(kinds) > 0 && !kinds[d.Kind] {
+			continue
+		}
+		if !q.Since.IsZero() && d.UpdatedAt.Before(q.Since) {
+			continue
+		}
+		if !q.Until.IsZero() && !d.UpdatedAt.Before(q.Until) {
+			continue
+		}
+		matched := true
+		for i, c := range clauses {
+			if (len(matchPhrase(d, expanded[i])) > 0) == c.exclude {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			hits = append(hits, scored{doc: d, score: x.bm25(d, clauses, expanded)})
+		}
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].score != hits[j].score {
+			return hits[i].score > hits[j].score
+		}
+		return hits[i].doc.UpdatedAt.After(hits[j].doc.UpdatedAt)
+	})
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+
+	results := make([]*Result, len(hits))
+	for i, h := range hits {
+		d := h.doc
+		r := &Result{
+			Kind:      d.Kind,
+			ID:        d.ID,
+			Title:     d.Title,
+			Archived:  d.Archived,
+			UpdatedAt: d.UpdatedAt,
+			Score:     h.score,
+		}
+		r.Ref, r.Snippet, r.Highlights = snippet(d, clauses, expanded)
+		results[i] = r
+	}
+	return results, nil
+}
+
+// candidates 返回包含第一个需要出现的条件首词的文档，调用方需持有锁
+func (x *Index) candidates(clauses []clause, expanded [][][]string) map[string]*doc {
+	for i, c := range clauses {
+		if c.exclude {
+			continue
+		}
+		docs := make(map[string]*doc)
+		for _, term := range expanded[i][0] {
+			for k, d := range x.postings[term] {
+				docs[k] = d
+			}
+		}
+		return docs
+	}
+	return nil
+}
+
+// expand 返回条件中每个位置可以匹配的词，前缀条件的最后一个词展开为词典中所有以它开头的词，调用方需持有锁
+func (x *Index) expand(c clause) [][]string {
+	out := make([][]string, len(c.terms))
+	for i, term := range c.terms {
+		if c.prefix && i == len(c.terms)-1 {
+			for t := range x.postings {
+				if strings.HasPrefix(t, term) {
+					out[i] = append(out[i], t)
+				}
+			}
+			continue
+		}
+		out[i] = []string{term}
+	}
+	return out
+}
+
+// bm25 计算文档对需要出现的条件中各词的 BM25 得分，调用方需持有锁
+func (x *Index) bm25(d *doc, clauses []clause, expanded [][][]string) float64 {
+	n := float64(len(x.docs))
+	avgLen := float64(x.totalLen) / n
+	score := 0.0
+	for i, c := range clauses {
+		if c.exclude {
+			continue
+		}
+		for _, terms := range expanded[i] {
+			for _, term := range terms {
+				tf := float64(len(d.terms[term]))
+				if tf == 0 {
+					continue
+				}
+				df := float64(len(x.postings[term]))
+				idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+				score += idf * tf * (bm25K1 + 1) / (tf + bm25K1*(1-bm25B+bm25B*float64(d.length)/avgLen))
+			}
+		}
+	}
+	return score
+}
+
+// matchPhrase 返回短语在文档中出现的起始位置，每个位置的词按 expanded 中的任一词匹配
+func matchPhrase(d *doc, expanded [][]string) []int {
+	positions := func(i int) map[int]bool {
+		set := make(map[int]bool)
+		for _, term := range expanded[i] {
+			for _, p := range d.terms[term] {
+				set[p] = true
+			}
+		}
+		return set
+	}
+	var starts []int
+	for p := range positions(0) {
+		starts = append(starts, p)
+	}
+	for i := 1; i < len(expanded) && len(starts) > 0; i++ {
+		next := positions(i)
+		kept := starts[:0]
+		for _, p := range starts {
+			if next[p+i] {
+				kept = append(kept, p)
+			}
+		}
+		starts = kept
+	}
+	sort.Ints(starts)
+	return starts
+}
+
+// snippet 从匹配条件最多的部分截取第一个匹配前后的文本，返回部分的 Ref、摘要和匹配在摘要中的区间
+func snippet(d *doc, clauses []clause, expanded [][][]string) (string, string, [][2]int) {
+	if len(d.Parts) == 0 {
+		return "", "", nil
+	}
+	type span struct{ start, end int } // 词的位置区间 [start, end]
+	spans := make([][]span, len(d.Parts))
+	clauseHits := make([]map[int]bool, len(d.Parts))
+	for i, c := range clauses {
+		if c.exclude {
+			continue
+		}
+		for _, p := range matchPhrase(d, expanded[i]) {
+			part := sort.SearchInts(d.starts, p+1) - 1
+			spans[part] = append(spans[part], span{p, p + len(c.terms) - 1})
+			if clauseHits[part] == nil {
+				clauseHits[part] = make(map[int]bool)
+			}
+			clauseHits[part][i] = true
+		}
+	}
+	best := 0
+	for i := range d.Parts {
+		if len(clauseHits[i]) > len(clauseHits[best]) {
+			best = i
+		}
+	}
+	part := d.Parts[best]
+	text := part.Text
+
+	// 按位置找到每个词在文本中的字节区间
+	type bounds struct{ start, end int }
+	offsets := make(map[int]bounds)
+	for _, tok := range tokenize(text) {
+		b, ok := offsets[tok.pos]
+		if !ok {
+			b = bounds{tok.start, tok.end}
+		}
+		b.start, b.end = min(b.start, tok.start), max(b.end, tok.end)
+		offsets[tok.pos] = b
+	}
+	var ranges [][2]int
+	for _, s := range spans[best] {
+		first, last := offsets[s.start-d.starts[best]], offsets[s.end-d.starts[best]]
+		ranges = append(ranges, [2]int{first.start, last.end})
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i][0] < ranges[j][0] })
+
+	from, to := 0, min(len(text), 2*snippetRadius)
+	if len(ranges) > 0 {
+		from = max(0, ranges[0][0]-snippetRadius)
+		to = min(len(text), ranges[0][1]+snippetRadius)
+	}
+	for from > 0 && !utf8.RuneStart(text[from]) {
+		from--
+	}
+	for to < len(text) && !utf8.RuneStart(text[to]) {
+		to++
+	}
+
+	var b strings.Builder
+	shift := -from
+	if from > 0 {
+		b.WriteString("…")
+		shift += len("…")
+	}
+	b.WriteString(strings.NewReplacer("\r", " ", "\n", " ", "\t", " ").Replace(text[from:to]))
+	if to < len(text) {
+		b.WriteString("…")
+	}
+	var highlights [][2]int
+	for _, r := range ranges {
+		if r[0] >= from && r[1] <= to {
+			highlights = append(highlights, [2]int{r[0] + shift, r[1] + shift})
+		}
+	}
+	return part.Ref, b.String(), highlights
+}
+
+// clause 是查询中的一个条件，terms 按顺序连续出现时匹配
+type clause struct {
+	terms   []string
+	prefix  bool // 最后一个词按前缀匹配
+	exclude bool // 文档中不能出现
+}
+
+// parseQuery 解析查询文本，至少需要一个不是排除的条件
+func parseQuery(text string) ([]clause, error) {
+	var clauses []clause
+	add := func(raw string, exclude, quoted bool) {
+		prefix := !quoted && strings.HasSuffix(raw, "*")
+		var terms []string
+		for _, tok := range tokenize(strings.TrimRight(raw, "*")) {
+			// 标识符拆分出的子词与原词位置相同，查询只使用原词
+			if len(terms) == tok.pos {
+				terms = append(terms, tok.term)
+			}
+		}
+		if len(terms) > 0 {
+			clauses = append(clauses, clause{terms: terms, prefix: prefix, exclude: exclude})
+		}
+	}
+
+	for rest := strings.TrimSpace(text); rest != ""; rest = strings.TrimSpace(rest) {
+		exclude := false
+		if len(rest) > 1 && rest[0] == '-' {
+			exclude = true
+			rest = rest[1:]
+		}
+		if rest[0] == '"' {
+			end := strings.IndexByte(rest[1:], '"')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated phrase in query %q", text)
+			}
+			add(rest[1:end+1], exclude, true)
+			rest = rest[end+2:]
+			continue
+		}
+		end := strings.IndexFunc(rest, unicode.IsSpace)
+		if end < 0 {
+			end = len(rest)
+		}
+		add(rest[:end], exclude, false)
+		rest = rest[end:]
+	}
+
+	for _, c := range clauses {
+		if !c.exclude {
+			return clauses, nil
+		}
+	}
+	return nil, ErrEmptyQuery
+}
+
+// token 是文本中的一个词，start 和 end 为字节区间，pos 为词的位置
+type token struct {
+	term       string
+	start, end int
+	pos        int
+}
+
+// tokenize 把文本切分为小写的词：连续的字母和数字为一个词，汉字等没有空格分隔的文字每个字为一个词。
+// 驼峰或字母数字混合的标识符同时拆出子词，子词与原词位置相同，检索 foo 可以找到 TestFoo
+func tokenize(text string) []token {
+	var tokens []token
+	pos := 0
+	start := -1
+	flush := func(end int) {
+		if start < 0 {
+			return
+		}
+		word := text[start:end]
+		tokens = append(tokens, token{term: strings.ToLower(word), start: start, end: end, pos: pos})
+		if subs := splitWord(word); len(subs) > 1 {
+			offset := start
+			for _, sub := range subs {
+				tokens = append(tokens, token{term: strings.ToLower(sub), start: offset, end: offset + len(sub), pos: pos})
+				offset += len(sub)
+			}
+		}
+		pos++
+		start = -1
+	}
+	for i, r := range text {
+		switch {
+		case isIdeograph(r):
+			flush(i)
+			tokens = append(tokens, token{term: string(r), start: i, end: i + utf8.RuneLen(r), pos: pos})
+			pos++
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if start < 0 {
+				start = i
+			}
+		default:
+			flush(i)
+		}
+	}
+	flush(len(text))
+	return tokens
+}
+
+// splitWord 按大小写和字母数字的变化拆分单词，例如 TestFoo2 拆为 Test、Foo 和 2
+func splitWord(word string) []string {
+	var parts []string
+	runes := []rune(word)
+	begin := 0
+	for i := 1; i < len(runes); i++ {
+		prev, cur := runes[i-1], runes[i]
+		boundary := unicode.IsLower(prev) && unicode.IsUpper(cur) ||
+			unicode.IsDigit(prev) != unicode.IsDigit(cur) ||
+			// HTTPServer 在 S 之前拆分
+			i+1 < len(runes) && unicode.IsUpper(prev) && unicode.IsUpper(cur) && unicode.IsLower(runes[i+1])
+		if boundary {
+			parts = append(parts, string(runes[begin:i]))
+			begin = i
+		}
+	}
+	return append(parts, string(runes[begin:]))
+}
+
+// isIdeograph 判断字符是否属于不用空格分词的文字
+func isIdeograph(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
@@ -0,0 +1,179 @@
+package fulltext
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testIndex() *Index {
+	now := time.Now()
+	x := New("")
+	x.Put(&Document{Kind: KindConversation, ID: "c1", Title: "flaky test", UpdatedAt: now.Add(-21 * 24 * time.Hour), Parts: []Part{
+		{Ref: "m1", Text: "TestFoo fails about one run in ten on CI"},
+		{Ref: "m2", Text: "The agent fixed the flaky TestFoo by waiting for the server to start."},
+	}})
+	x.Put(&Document{Kind: KindTask, ID: "t1", Title: "refactor", UpdatedAt: now, Parts: []Part{
+		{Ref: "description", Text: "Refactor the HTTPServer setup"},
+	}})
+	x.Put(&Document{Kind: KindCommand, ID: "h1", Title: "go test ./...", UpdatedAt: now, Parts: []Part{
+		{Ref: "command", Text: "go test ./..."},
+		{Ref: "stdout", Text: "--- FAIL: TestFoo (0.01s)\n    foo_test.go:12: connection refused"},
+	}})
+	x.Put(&Document{Kind: KindConversation, ID: "c2", Title: "中文", UpdatedAt: now, Parts: []Part{
+		{Ref: "m1", Text: "修复了测试失败的问题"},
+	}})
+	return x
+}
+
+func ids(results []*Result) []string {
+	var out []string
+	for _, r := range results {
+		out = append(out, r.ID)
+	}
+	return out
+}
+
+func TestSearch(t *testing.T) {
+	x := testIndex()
+	tests := []struct {
+		query string
+		kinds []Kind
+		want  []string
+	}{
+		{query: "flaky TestFoo", want: []string{"c1"}},
+		{query: "testfoo", kinds: []Kind{KindCommand}, want: []string{"h1"}},
+		{query: `"fixed the flaky"`, want: []string{"c1"}},
+		{query: `"flaky fixed"`, want: nil},
+		{query: "foo", want: []string{"c1", "h1"}},
+		{query: "server", want: []string{"t1", "c1"}},
+		{query: "refus*", want: []string{"h1"}},
+		{query: "TestFoo -agent", want: []string{"h1"}},
+		{query: "测试失败", want: []string{"c2"}},
+		{query: "失败测试", want: nil},
+	}
+	for _, tt := range tests {
+		results, err := x.Search(&Query{Text: tt.query, Kinds: tt.kinds})
+		if err != nil {
+			t.Fatalf("%q: %v", tt.query, err)
+		}
+		got := ids(results)
+		if len(got) != len(tt.want) {
+			t.Errorf("%q: got %v, want %v", tt.query, got, tt.want)
+			continue
+		}
+		// 得分相近的结果顺序不固定，只比较集合
+		want := make(map[string]bool)
+		for _, id := range tt.want {
+			want[id] = true
+		}
+		for _, id := range got {
+			if !want[id] {
+				t.Errorf("%q: got %v, want %v", tt.query, got, tt.want)
+				break
+			}
+		}
+	}
+
+	if _, err := x.Search(&Query{Text: "-agent"}); !errors.Is(err, ErrEmptyQuery) {
+		t.Errorf("expected ErrEmptyQuery for exclusion only query, got %v", err)
+	}
+	if _, err := x.Search(&Query{Text: `"unterminated`}); err == nil {
+		t.Error("expected error for unterminated phrase")
+	}
+	results, err := x.Search(&Query{Text: "TestFoo", Since: time.Now().Add(-time.Hour)})
+	if err != nil || len(results) != 1 || results[0].ID != "h1" {
+		t.Errorf("expected only recent command with since filter, got %v %v", ids(results), err)
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	x := testIndex()
+	results, err := x.Search(&Query{Text: "flaky testfoo"})
+	if err != nil || len(results) != 1 {
+		t.Fatalf("unexpected results %v %v", results, err)
+	}
+	r := results[0]
+	if r.Ref != "m2" {
+		t.Errorf("expected snippet from message matching both terms, got %q", r.Ref)
+	}
+	var words []string
+	for _, h := range r.Highlights {
+		words = append(words, r.Snippet[h[0]:h[1]])
+	}
+	if len(words) != 2 || words[0] != "flaky" || words[1] != "TestFoo" {
+		t.Errorf("unexpected highlights %v in %q", words, r.Snippet)
+	}
+
+	results, _ = x.Search(&Query{Text: "connection"})
+	if len(results) != 1 || results[0].Ref != "stdout" {
+		t.Fatalf("unexpected results %+v", results)
+	}
+	if s := results[0].Snippet; s != "--- FAIL: TestFoo (0.01s)     foo_test.go:12: connection refused" {
+		t.Errorf("expected newlines replaced in snippet, got %q", s)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	x := testIndex()
+	updated := time.Now()
+	if x.Current(KindTask, "t1", updated, false) {
+		t.Error("expected task with different update time to be stale")
+	}
+	x.Put(&Document{Kind: KindTask, ID: "t1", UpdatedAt: updated, Archived: true, Parts: []Part{{Ref: "description", Text: "Rename packages"}}})
+	if !x.Current(KindTask, "t1", updated, true) || x.Current(KindTask, "t1", updated, false) {
+		t.Error("expected replaced task to be current only when archived")
+	}
+	if results, _ := x.Search(&Query{Text: "HTTPServer"}); len(results) != 0 {
+		t.Errorf("expected replaced text to be removed, got %v", ids(results))
+	}
+	if results, _ := x.Search(&Query{Text: "rename"}); len(results) != 1 || !results[0].Archived {
+		t.Errorf("expected archived task, got %+v", results)
+	}
+
+	removed := x.Prune(func(kind Kind, id string) bool { return kind != KindConversation })
+	if removed != 2 || x.Len() != 2 {
+		t.Errorf("expected two conversations pruned, removed %d, left %d", removed, x.Len())
+	}
+	if results, _ := x.Search(&Query{Text: "flaky"}); len(results) != 0 {
+		t.Errorf("expected pruned conversation not found, got %v", ids(results))
+	}
+}
+
+func TestSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "transcripts.json")
+	x := New(path)
+	if err := x.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expected an unchanged index not to be written, got %v", err)
+	}
+
+	updated := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	x.Put(&Document{Kind: KindTask, ID: "t1", Title: "flaky", UpdatedAt: updated, Archived: true, Parts: []Part{
+		{Ref: "description", Text: "fix the flaky TestFoo"},
+	}})
+	x.Put(&Document{Kind: KindCommand, ID: "h1", UpdatedAt: updated, Parts: []Part{{Ref: "stdout", Text: "ok"}}})
+	x.Remove(KindCommand, "h1")
+	if err := x.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 重新加载后文档和倒排表都恢复，不需要重新同步
+	loaded := New(path)
+	if loaded.Len() != 1 || !loaded.Current(KindTask, "t1", updated, true) {
+		t.Fatalf("expected the saved document to be loaded, got %d documents", loaded.Len())
+	}
+	results, err := loaded.Search(&Query{Text: `"flaky testfoo"`})
+	if err != nil || len(results) != 1 || results[0].Ref != "description" {
+		t.Errorf("unexpected results %+v %v", results, err)
+	}
+
+	os.WriteFile(path, []byte("{"), 0600)
+	if New(path).Len() != 0 {
+		t.Error("expected a corrupt file to start an empty index")
+	}
+}
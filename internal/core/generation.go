@@ -0,0 +1,153 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/liangsj/vimcoplit/internal/models"
+)
+
+// GenerationStatus 表示生成请求的状态
+type GenerationStatus string
+
+const (
+	GenerationStatusRunning   GenerationStatus = "running"
+	GenerationStatusCompleted GenerationStatus = "completed"
+	GenerationStatusCanceled  GenerationStatus = "canceled"
+	GenerationStatusFailed    GenerationStatus = "failed"
+)
+
+// GenerationRecord 描述一次生成请求，取消时保留已经生成的部分输出
+type GenerationRecord struct {
+	ID        string           `json:"id"`
+	Kind      string           `json:"kind"` // generate 或 conversation
+	Status    GenerationStatus `json:"status"`
+	Partial   string           `json:"partial,omitempty"`
+	StartedAt time.Time        `json:"started_at"`
+	EndedAt   time.Time        `json:"ended_at,omitempty"`
+}
+
+// inflightGeneration 是进行中的生成请求
+type inflightGeneration struct {
+	mu       sync.Mutex
+	record   GenerationRecord
+	partial  strings.Builder
+	cancel   context.CancelFunc
+	canceled bool
+}
+
+// generationTracker 跟踪进行中的生成请求，被取消的请求以 JSONL 格式追加保存
+type generationTracker struct {
+	mu       sync.Mutex
+	path     string
+	inflight map[string]*inflightGeneration
+}
+
+// newGenerationTracker 创建生成请求跟踪器
+func newGenerationTracker(path string) *generationTracker {
+	return &generationTracker{
+		path:     path,
+		inflight: make(map[string]*inflightGeneration),
+	}
+}
+
+// BeginGeneration 开始跟踪一次生成请求，返回的 ctx 可以通过 CancelGeneration 取消，
+// 模型的流式输出会被记录下来，ctx 中已有的流式输出回调仍然被调用。生成结束后必须调用 done，done 返回请求的最终状态
+func (s *serviceImpl) BeginGeneration(ctx context.Context, id, kind string) (context.Context, func(err error) *GenerationRecord) {
+	t := s.generations
+	ctx, cancel := context.WithCancel(ctx)
+	g := &inflightGeneration{
+		record: GenerationRecord{
+			ID:        id,
+			Kind:      kind,
+			Status:    GenerationStatusRunning,
+			StartedAt: time.Now(),
+		},
+		cancel: cancel,
+	}
+	ctx = models.AddTokenHandler(ctx, func(token string) {
+		g.mu.Lock()
+		g.partial.WriteString(token)
+		g.mu.Unlock()
+	})
+
+	t.mu.Lock()
+	t.inflight[id] = g
+	t.mu.Unlock()
+
+	return ctx, func(err error) *GenerationRecord {
+		defer cancel()
+		t.mu.Lock()
+		if t.inflight[id] == g {
+			delete(t.inflight, id)
+		}
+		t.mu.Unlock()
+
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		record := g.record
+		record.EndedAt = time.Now()
+		switch {
+		case g.canceled:
+			record.Status = GenerationStatusCanceled
+			record.Partial = g.partial.String()
+		case err != nil:
+			record.Status = GenerationStatusFailed
+		default:
+			record.Status = GenerationStatusCompleted
+		}
+		return &record
+	}
+}
+
+// CancelGeneration 取消进行中的生成请求，中断模型请求并记录已经生成的部分输出
+func (s *serviceImpl) CancelGeneration(ctx context.Context, id string) (*GenerationRecord, error) {
+	t := s.generations
+	t.mu.Lock()
+	g, exists := t.inflight[id]
+	t.mu.Unlock()
+	if !exists {
+		return nil, errors.New("generation not found or already finished")
+	}
+
+	g.mu.Lock()
+	g.canceled = true
+	g.cancel()
+	record := g.record
+	record.Status = GenerationStatusCanceled
+	record.Partial = g.partial.String()
+	record.EndedAt = time.Now()
+	g.mu.Unlock()
+
+	if err := t.append(&record); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// append 追加保存被取消的生成请求
+func (t *generationTracker) append(record *GenerationRecord) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(t.path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(t.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
@@ -0,0 +1,102 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/liangsj/vimcoplit/internal/models"
+)
+
+// firstToken 返回在收到第一段流式输出时关闭的通道
+func firstToken(ctx context.Context) (context.Context, <-chan struct{}) {
+	started := make(chan struct{})
+	closed := false
+	return models.AddTokenHandler(ctx, func(string) {
+		if !closed {
+			closed = true
+			close(started)
+		}
+	}), started
+}
+
+func TestCancelGeneration(t *testing.T) {
+	s, model := newTestService(t, models.MockResponse{Output: "abcd", ChunkSize: 2, DelayMS: 200})
+	ctx, started := firstToken(context.Background())
+
+	ctx, done := s.BeginGeneration(ctx, "gen-1", "generate")
+	finished := make(chan *GenerationRecord, 1)
+	go func() {
+		_, err := model.Generate(ctx, "x")
+		finished <- done(err)
+	}()
+	<-started
+
+	record, err := s.CancelGeneration(ctx, "gen-1")
+	if err != nil {
+		t.Fatalf("CancelGeneration: %v", err)
+	}
+	if record.Status != GenerationStatusCanceled || record.Partial != "ab" {
+		t.Errorf("unexpected cancel record %+v", record)
+	}
+	if final := <-finished; final.Status != GenerationStatusCanceled || final.Partial != "ab" {
+		t.Errorf("unexpected final record %+v", final)
+	}
+	if _, err := s.CancelGeneration(context.Background(), "gen-1"); err == nil {
+		t.Error("expected a finished generation to fail")
+	}
+
+	// 被取消的请求追加保存
+	data, err := os.ReadFile(s.generations.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved GenerationRecord
+	if err := json.Unmarshal(data, &saved); err != nil || saved.ID != "gen-1" || saved.Partial != "ab" {
+		t.Errorf("unexpected saved record %s %v", data, err)
+	}
+
+	// 未取消的请求按结果结束
+	_, done = s.BeginGeneration(context.Background(), "gen-2", "generate")
+	if record := done(nil); record.Status != GenerationStatusCompleted {
+		t.Errorf("expected completed, got %s", record.Status)
+	}
+	_, done = s.BeginGeneration(context.Background(), "gen-3", "generate")
+	if record := done(errors.New("boom")); record.Status != GenerationStatusFailed || record.Partial != "" {
+		t.Errorf("expected failed without partial output, got %+v", record)
+	}
+}
+
+func TestCancelMessage(t *testing.T) {
+	s, _ := newTestService(t, models.MockResponse{Output: "abcd", ChunkSize: 2, DelayMS: 200})
+	ctx := context.Background()
+	conv, err := s.CreateConversation(ctx, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CancelMessage(ctx, conv.ID); err == nil {
+		t.Error("expected a conversation without a reply in progress to fail")
+	}
+
+	sendCtx, started := firstToken(ctx)
+	sent := make(chan error, 1)
+	go func() {
+		_, err := s.SendMessage(sendCtx, conv.ID, &MessageRequest{Content: "hi"})
+		sent <- err
+	}()
+	<-started
+
+	record, err := s.CancelMessage(ctx, conv.ID)
+	if err != nil {
+		t.Fatalf("CancelMessage: %v", err)
+	}
+	if record.Kind != "conversation" || !strings.HasPrefix("abcd", record.Partial) || record.Partial == "" {
+		t.Errorf("unexpected cancel record %+v", record)
+	}
+	if err := <-sent; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the reply to be canceled, got %v", err)
+	}
+}
@@ -0,0 +1,246 @@
+// Package gomod 解析 go.mod 和 go.sum，并通过 go list 查询依赖和可用的升级
+package gomod
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Require 是 go.mod 中的一条 require
+type Require struct {
+	Path     string `json:"path"`
+	Version  string `json:"version"`
+	Indirect bool   `json:"indirect"`
+}
+
+// Replace 是 go.mod 中的一条 replace，OldVersion 为空表示替换所有版本
+type Replace struct {
+	Old        string `json:"old"`
+	OldVersion string `json:"old_version,omitempty"`
+	New        string `json:"new"`
+	NewVersion string `json:"new_version,omitempty"`
+}
+
+// File 是解析后的 go.mod
+type File struct {
+	Module  string    `json:"module"`
+	Go      string    `json:"go"`
+	Require []Require `json:"require"`
+	Replace []Replace `json:"replace,omitempty"`
+	Exclude []Require `json:"exclude,omitempty"`
+}
+
+// ReadFile 读取并解析 dir 下的 go.mod
+func ReadFile(dir string) (*File, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Parse 解析 go.mod 的内容，支持单行和括号块两种写法
+func Parse(data []byte) (*File, error) {
+	f := &File{Require: []Require{}}
+	block := ""
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		comment := ""
+		if i := strings.Index(line, "//"); i >= 0 {
+			line, comment = strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+2:])
+		}
+		if line == "" {
+			continue
+		}
+
+		if block != "" {
+			if line == ")" {
+				block = ""
+				continue
+			}
+			if err := f.add(block, fields(line), comment); err != nil {
+				return nil, fmt.Errorf("go.mod:%d: %v", n, err)
+			}
+			continue
+		}
+
+		words := fields(line)
+		if len(words) == 2 && words[1] == "(" {
+			block = words[0]
+			continue
+		}
+		if err := f.add(words[0], words[1:], comment); err != nil {
+			return nil, fmt.Errorf("go.mod:%d: %v", n, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if f.Module == "" {
+		return nil, errors.New("go.mod has no module directive")
+	}
+	return f, nil
+}
+
+// add 处理一条指令，未知的指令（如 toolchain、retract）忽略
+func (f *File) add(verb string, args []string, comment string) error {
+	switch verb {
+	case "module":
+		if len(args) != 1 {
+			return errors.New("usage: module path")
+		}
+		f.Module = args[0]
+	case "go":
+		if len(args) != 1 {
+			return errors.New("usage: go version")
+		}
+		f.Go = args[0]
+	case "require", "exclude":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: %s module/path v1.2.3", verb)
+		}
+		r := Require{Path: args[0], Version: args[1], Indirect: comment == "indirect" || strings.HasPrefix(comment, "indirect;")}
+		if verb == "require" {
+			f.Require = append(f.Require, r)
+		} else {
+			f.Exclude = append(f.Exclude, r)
+		}
+	case "replace":
+		arrow := -1
+		for i, a := range args {
+			if a == "=>" {
+				arrow = i
+			}
+		}
+		if arrow < 1 || arrow > 2 || len(args)-arrow-1 < 1 || len(args)-arrow-1 > 2 {
+			return errors.New("usage: replace module/path [v1.2.3] => other/module [v1.4.5]")
+		}
+		r := Replace{Old: args[0], New: args[arrow+1]}
+		if arrow == 2 {
+			r.OldVersion = args[1]
+		}
+		if len(args) == arrow+3 {
+			r.NewVersion = args[arrow+2]
+		}
+		f.Replace = append(f.Replace, r)
+	}
+	return nil
+}
+
+// fields 按空白切分，并去掉带引号的模块路径的引号
+func fields(line string) []string {
+	words := strings.Fields(line)
+	for i, w := range words {
+		if s, err := strconv.Unquote(w); err == nil {
+			words[i] = s
+		}
+	}
+	return words
+}
+
+// Direct 返回直接依赖
+func (f *File) Direct() []Require {
+	var direct []Require
+	for _, r := range f.Require {
+		if !r.Indirect {
+			direct = append(direct, r)
+		}
+	}
+	return direct
+}
+
+// SumEntry 是 go.sum 中记录了模块内容摘要的模块版本
+type SumEntry struct {
+	Path    string `json:"path"`
+	Version string `json:"version"`
+	Hash    string `json:"hash"`
+}
+
+// ParseSum 解析 go.sum，只返回记录了模块内容（而不仅是 go.mod）摘要的条目，按路径排序
+func ParseSum(data []byte) []SumEntry {
+	var entries []SumEntry
+	for _, line := range strings.Split(string(data), "\n") {
+		words := strings.Fields(line)
+		if len(words) != 3 || strings.HasSuffix(words[1], "/go.mod") {
+			continue
+		}
+		entries = append(entries, SumEntry{Path: words[0], Version: words[1], Hash: words[2]})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Path != entries[j].Path {
+			return entries[i].Path < entries[j].Path
+		}
+		return entries[i].Version < entries[j].Version
+	})
+	return entries
+}
+
+// Module 是 go list -m 输出的模块，Update 为可升级到的版本
+type Module struct {
+	Path     string `json:"path"`
+	Version  string `json:"version"`
+	Indirect bool   `json:"indirect"`
+	Update   string `json:"update,omitempty"`
+}
+
+// List 用 go list -m all 列出构建依赖的所有模块（不含主模块），
+// upgrades 为 true 时同时查询可用的升级，需要访问模块代理
+func List(ctx context.Context, dir string, upgrades bool) ([]Module, error) {
+	args := []string{"list", "-m", "-json"}
+	if upgrades {
+		args = append(args, "-u")
+	}
+	cmd := exec.CommandContext(ctx, "go", append(args, "all")...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("go list failed: %s", msg)
+		}
+		return nil, fmt.Errorf("go list failed: %v", err)
+	}
+	return decodeList(bytes.NewReader(out))
+}
+
+// decodeList 解析 go list -m -json 输出的 JSON 对象流
+func decodeList(r io.Reader) ([]Module, error) {
+	modules := []Module{}
+	dec := json.NewDecoder(r)
+	for {
+		var m struct {
+			Path     string
+			Version  string
+			Main     bool
+			Indirect bool
+			Update   *struct{ Version string }
+		}
+		if err := dec.Decode(&m); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("invalid go list output: %v", err)
+		}
+		if m.Main {
+			continue
+		}
+		module := Module{Path: m.Path, Version: m.Version, Indirect: m.Indirect}
+		if m.Update != nil {
+			module.Update = m.Update.Version
+		}
+		modules = append(modules, module)
+	}
+	return modules, nil
+}
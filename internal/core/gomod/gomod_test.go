@@ -0,0 +1,98 @@
+package gomod
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	data := `module github.com/example/app // main module
+
+go 1.22
+
+toolchain go1.22.3
+
+require github.com/google/uuid v1.6.0
+
+require (
+	github.com/fsnotify/fsnotify v1.9.0
+	golang.org/x/sys v0.13.0 // indirect
+	"example.com/quoted" v0.1.0
+)
+
+replace example.com/quoted => ../quoted
+
+replace (
+	golang.org/x/sys v0.13.0 => golang.org/x/sys v0.14.0
+)
+
+exclude golang.org/x/net v0.1.0
+`
+	f, err := Parse([]byte(data))
+	if err != nil {
+		t.Fatalf("failed to parse go.mod: %v", err)
+	}
+	if f.Module != "github.com/example/app" || f.Go != "1.22" {
+		t.Errorf("unexpected module header: %q %q", f.Module, f.Go)
+	}
+	wantRequire := []Require{
+		{Path: "github.com/google/uuid", Version: "v1.6.0"},
+		{Path: "github.com/fsnotify/fsnotify", Version: "v1.9.0"},
+		{Path: "golang.org/x/sys", Version: "v0.13.0", Indirect: true},
+		{Path: "example.com/quoted", Version: "v0.1.0"},
+	}
+	if !reflect.DeepEqual(f.Require, wantRequire) {
+		t.Errorf("expected require %+v, got %+v", wantRequire, f.Require)
+	}
+	wantReplace := []Replace{
+		{Old: "example.com/quoted", New: "../quoted"},
+		{Old: "golang.org/x/sys", OldVersion: "v0.13.0", New: "golang.org/x/sys", NewVersion: "v0.14.0"},
+	}
+	if !reflect.DeepEqual(f.Replace, wantReplace) {
+		t.Errorf("expected replace %+v, got %+v", wantReplace, f.Replace)
+	}
+	if len(f.Exclude) != 1 || f.Exclude[0].Path != "golang.org/x/net" {
+		t.Errorf("unexpected exclude %+v", f.Exclude)
+	}
+	if direct := f.Direct(); len(direct) != 3 {
+		t.Errorf("expected 3 direct dependencies, got %+v", direct)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, data := range []string{"", "go 1.22\n", "module a\nrequire b\n"} {
+		if _, err := Parse([]byte(data)); err == nil {
+			t.Errorf("expected error for %q", data)
+		}
+	}
+}
+
+func TestParseSum(t *testing.T) {
+	data := "golang.org/x/sys v0.13.0 h1:abc=\ngolang.org/x/sys v0.13.0/go.mod h1:def=\ngithub.com/google/uuid v1.6.0 h1:ghi=\n"
+	want := []SumEntry{
+		{Path: "github.com/google/uuid", Version: "v1.6.0", Hash: "h1:ghi="},
+		{Path: "golang.org/x/sys", Version: "v0.13.0", Hash: "h1:abc="},
+	}
+	if got := ParseSum([]byte(data)); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+}
+
+func TestDecodeList(t *testing.T) {
+	output := `{"Path": "github.com/example/app", "Main": true}
+{"Path": "github.com/google/uuid", "Version": "v1.5.0", "Update": {"Path": "github.com/google/uuid", "Version": "v1.6.0"}}
+{"Path": "golang.org/x/sys", "Version": "v0.13.0", "Indirect": true}
+`
+	got, err := decodeList(strings.NewReader(output))
+	if err != nil {
+		t.Fatalf("failed to decode go list output: %v", err)
+	}
+	want := []Module{
+		{Path: "github.com/google/uuid", Version: "v1.5.0", Update: "v1.6.0"},
+		{Path: "golang.org/x/sys", Version: "v0.13.0", Indirect: true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+}
@@ -0,0 +1,105 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/liangsj/vimcoplit/internal/core/gomod"
+	"github.com/liangsj/vimcoplit/internal/core/mcp"
+)
+
+// dirParameter 是 Go 模块工具共用的模块目录参数
+var dirParameter = mcp.ToolParameter{
+	Name:        "dir",
+	Type:        "string",
+	Description: "module directory relative to the workspace root, defaults to the root",
+}
+
+// registerGoModTools 注册分析 Go 模块依赖的内置工具
+func registerGoModTools(manager *mcp.Manager, root string) {
+	manager.RegisterBuiltinTool(&mcp.Tool{
+		ID:          "go_mod_info",
+		Name:        "go_mod_info",
+		Description: "Parse go.mod and go.sum: module path, Go version, requirements, replacements and checksummed modules",
+		Parameters:  []mcp.ToolParameter{dirParameter},
+	}, func(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+		dir, err := moduleDir(root, params)
+		if err != nil {
+			return nil, err
+		}
+		f, err := gomod.ReadFile(dir)
+		if err != nil {
+			return nil, err
+		}
+		sum, err := os.ReadFile(filepath.Join(dir, "go.sum"))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		return map[string]interface{}{"go_mod": f, "go_sum": gomod.ParseSum(sum)}, nil
+	})
+
+	manager.RegisterBuiltinTool(&mcp.Tool{
+		ID:          "go_mod_deps",
+		Name:        "go_mod_deps",
+		Description: "List direct dependencies from go.mod, or every module in the build list when transitive is true",
+		Parameters: []mcp.ToolParameter{dirParameter, {
+			Name:        "transitive",
+			Type:        "boolean",
+			Description: "include transitive dependencies (runs go list -m all)",
+		}},
+	}, func(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+		dir, err := moduleDir(root, params)
+		if err != nil {
+			return nil, err
+		}
+		if transitive, _ := params["transitive"].(bool); transitive {
+			return gomod.List(ctx, dir, false)
+		}
+		f, err := gomod.ReadFile(dir)
+		if err != nil {
+			return nil, err
+		}
+		return f.Direct(), nil
+	})
+
+	manager.RegisterBuiltinTool(&mcp.Tool{
+		ID:          "go_mod_upgrades",
+		Name:        "go_mod_upgrades",
+		Description: "List modules with newer versions available (runs go list -m -u all, needs module proxy access)",
+		Parameters: []mcp.ToolParameter{dirParameter, {
+			Name:        "direct_only",
+			Type:        "boolean",
+			Description: "only report direct dependencies",
+		}},
+	}, func(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+		dir, err := moduleDir(root, params)
+		if err != nil {
+			return nil, err
+		}
+		modules, err := gomod.List(ctx, dir, true)
+		if err != nil {
+			return nil, err
+		}
+		directOnly, _ := params["direct_only"].(bool)
+		upgrades := []gomod.Module{}
+		for _, m := range modules {
+			if m.Update != "" && !(directOnly && m.Indirect) {
+				upgrades = append(upgrades, m)
+			}
+		}
+		return upgrades, nil
+	})
+}
+
+// moduleDir 解析工具参数中的模块目录，不允许超出工作区
+func moduleDir(root string, params map[string]interface{}) (string, error) {
+	rel, _ := params["dir"].(string)
+	dir := filepath.Join(root, rel)
+	if r, err := filepath.Rel(root, dir); err != nil || r == ".." || strings.HasPrefix(r, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("directory %s is outside the workspace", rel)
+	}
+	return dir, nil
+}
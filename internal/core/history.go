@@ -0,0 +1,164 @@
+package core
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxHistoryOutput 是历史记录中每个输出流保留的最大字节数
+const maxHistoryOutput = 64 * 1024
+
+// HistoryEntry 表示一条命令执行历史
+type HistoryEntry struct {
+	ID        string    `json:"id"`
+	Command   string    `json:"command"`
+	Args      []string  `json:"args,omitempty"`
+	WorkDir   string    `json:"work_dir,omitempty"`
+	Source    string    `json:"source"` // api、agent 或 rerun
+	RunID     string    `json:"run_id,omitempty"`
+	ExitCode  int       `json:"exit_code"`
+	Stdout    string    `json:"stdout,omitempty"`
+	Stderr    string    `json:"stderr,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+}
+
+// HistoryQuery 是命令历史的查询条件，零值字段不参与过滤
+type HistoryQuery struct {
+	Text   string    // 在命令行和输出中搜索，不区分大小写
+	Source string    // 命令来源
+	RunID  string    // agent 运行 ID
+	Since  time.Time // 开始时间不早于
+	Until  time.Time // 开始时间早于
+	Limit  int       // 最多返回的条数
+}
+
+// matches 判断历史记录是否满足查询条件
+func (q *HistoryQuery) matches(e *HistoryEntry) bool {
+	if q.Source != "" && e.Source != q.Source {
+		return false
+	}
+	if q.RunID != "" && e.RunID != q.RunID {
+		return false
+	}
+	if !q.Since.IsZero() && e.StartTime.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && !e.StartTime.Before(q.Until) {
+		return false
+	}
+	if q.Text != "" {
+		text := strings.ToLower(q.Text)
+		haystack := strings.ToLower(strings.Join(append([]string{e.Command}, e.Args...), " ") + "\n" + e.Stdout + "\n" + e.Stderr)
+		if !strings.Contains(haystack, text) {
+			return false
+		}
+	}
+	return true
+}
+
+// commandHistory 是命令执行历史，以 JSONL 格式追加保存
+type commandHistory struct {
+	mu   sync.Mutex
+	path string
+}
+
+// newCommandHistory 创建命令历史
+func newCommandHistory(path string) *commandHistory {
+	return &commandHistory{path: path}
+}
+
+// append 追加一条历史记录，输出过长时截断
+func (h *commandHistory) append(entry *HistoryEntry) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	e := *entry
+	e.Stdout = truncateOutput(e.Stdout)
+	e.Stderr = truncateOutput(e.Stderr)
+	data, err := json.Marshal(&e)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(h.path), 0755); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(h.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// search 按时间倒序返回满足条件的历史记录
+func (h *commandHistory) search(q *HistoryQuery) ([]*HistoryEntry, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	f, err := os.Open(h.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []*HistoryEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var entry HistoryEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		if q.matches(&entry) {
+			entries = append(entries, &entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read command history: %v", err)
+	}
+
+	// 文件按执行顺序追加，倒序即最近的在前
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	if q.Limit > 0 && len(entries) > q.Limit {
+		entries = entries[:q.Limit]
+	}
+	return entries, nil
+}
+
+// get 根据命令 ID 获取历史记录
+func (h *commandHistory) get(id string) (*HistoryEntry, error) {
+	entries, err := h.search(&HistoryQuery{})
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		if e.ID == id {
+			return e, nil
+		}
+	}
+	return nil, fmt.Errorf("command %s not found in history", id)
+}
+
+// truncateOutput 截断过长的输出
+func truncateOutput(s string) string {
+	if len(s) <= maxHistoryOutput {
+		return s
+	}
+	return s[:maxHistoryOutput] + "\n... (truncated)"
+}
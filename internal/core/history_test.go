@@ -0,0 +1,71 @@
+package core
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCommandHistorySearch(t *testing.T) {
+	h := newCommandHistory(filepath.Join(t.TempDir(), "history.jsonl"))
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for i, e := range []*HistoryEntry{
+		{ID: "1", Command: "go", Args: []string{"test", "./..."}, Source: "api", Stdout: "ok", StartTime: start},
+		{ID: "2", Command: "make", Args: []string{"lint"}, Source: "agent", RunID: "run-1", Stderr: "Lint FAILED", StartTime: start.Add(time.Hour)},
+		{ID: "3", Command: "go", Args: []string{"build"}, Source: "agent", RunID: "run-2", StartTime: start.Add(2 * time.Hour)},
+	} {
+		if err := h.append(e); err != nil {
+			t.Fatalf("append %d: %v", i, err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		query HistoryQuery
+		want  string
+	}{
+		{"all newest first", HistoryQuery{}, "3 2 1"},
+		{"command text", HistoryQuery{Text: "GO "}, "3 1"},
+		{"output text", HistoryQuery{Text: "failed"}, "2"},
+		{"source", HistoryQuery{Source: "agent"}, "3 2"},
+		{"run", HistoryQuery{RunID: "run-1"}, "2"},
+		{"since", HistoryQuery{Since: start.Add(time.Hour)}, "3 2"},
+		{"until", HistoryQuery{Until: start.Add(time.Hour)}, "1"},
+		{"limit", HistoryQuery{Limit: 1}, "3"},
+	}
+	for _, tt := range tests {
+		entries, err := h.search(&tt.query)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		ids := make([]string, len(entries))
+		for i, e := range entries {
+			ids[i] = e.ID
+		}
+		if got := strings.Join(ids, " "); got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.want, got)
+		}
+	}
+
+	if e, err := h.get("2"); err != nil || e.Command != "make" {
+		t.Errorf("unexpected entry %+v %v", e, err)
+	}
+	if _, err := h.get("missing"); err == nil {
+		t.Error("expected an unknown command to fail")
+	}
+}
+
+func TestCommandHistoryTruncation(t *testing.T) {
+	h := newCommandHistory(filepath.Join(t.TempDir(), "history.jsonl"))
+	if err := h.append(&HistoryEntry{ID: "big", Command: "cat", Stdout: strings.Repeat("x", maxHistoryOutput+10)}); err != nil {
+		t.Fatal(err)
+	}
+	e, err := h.get("big")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(e.Stdout, "(truncated)") || len(e.Stdout) > maxHistoryOutput+20 {
+		t.Errorf("expected the output to be truncated, got %d bytes", len(e.Stdout))
+	}
+}
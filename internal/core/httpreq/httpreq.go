@@ -0,0 +1,187 @@
+// Package httpreq 为 agent 发送 HTTP 请求：只允许访问白名单中的域名（包括重定向的目标），
+// 并限制请求体和响应体的大小，用于调用内部 API 和获取 JSON
+package httpreq
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrNotAllowed 表示请求的域名不在白名单中
+var ErrNotAllowed = errors.New("domain is not in the http_request allowlist")
+
+// maxRedirects 是最多跟随的重定向次数
+const maxRedirects = 5
+
+// Options 是请求的限制，大小为 0 时使用默认值
+type Options struct {
+	AllowedDomains   []string      // 允许的域名，*.example.com 匹配所有子域名，* 允许任意域名，为空时拒绝所有请求
+	MaxRequestBytes  int64         // 请求体的大小上限
+	MaxResponseBytes int64         // 响应体的大小上限，超出部分被截断
+	Timeout          time.Duration // 单次请求（包括重定向）的超时
+}
+
+// 默认限制
+const (
+	DefaultMaxRequestBytes  = 1 << 20
+	DefaultMaxResponseBytes = 1 << 20
+	DefaultTimeout          = 30 * time.Second
+)
+
+// Request 是一个 HTTP 请求，Method 为空时为 GET
+type Request struct {
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    string            `json:"body,omitempty"`
+}
+
+// Response 是请求的响应，响应体是合法的 JSON 时同时放在 JSON 中
+type Response struct {
+	StatusCode int               `json:"status_code"`
+	Status     string            `json:"status"`
+	URL        string            `json:"url"` // 跟随重定向后的最终地址
+	Headers    map[string]string `json:"headers"`
+	Body       string            `json:"body"`
+	JSON       json.RawMessage   `json:"json,omitempty"`
+	Truncated  bool              `json:"truncated,omitempty"` // 响应体超出上限被截断
+	Duration   string            `json:"duration"`
+}
+
+// Client 按白名单和大小限制发送请求
+type Client struct {
+	opts   Options
+	client *http.Client
+}
+
+// New 创建客户端，transport 为 nil 时使用 http.DefaultTransport
+func New(opts Options, transport http.RoundTripper) *Client {
+	if opts.MaxRequestBytes <= 0 {
+		opts.MaxRequestBytes = DefaultMaxRequestBytes
+	}
+	if opts.MaxResponseBytes <= 0 {
+		opts.MaxResponseBytes = DefaultMaxResponseBytes
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	c := &Client{opts: opts}
+	c.client = &http.Client{
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			return c.check(req.URL)
+		},
+	}
+	return c
+}
+
+// Allowed 判断主机名是否在白名单中，不区分大小写，忽略端口
+func (c *Client) Allowed(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, pattern := range c.opts.AllowedDomains {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		switch {
+		case pattern == "*":
+			return true
+		case strings.HasPrefix(pattern, "*."):
+			if strings.HasSuffix(host, pattern[1:]) {
+				return true
+			}
+		case host == pattern:
+			return true
+		}
+	}
+	return false
+}
+
+// check 检查地址的协议和域名
+func (c *Client) check(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("unsupported url scheme %q", u.Scheme)
+	}
+	if !c.Allowed(u.Hostname()) {
+		return fmt.Errorf("%w: %s", ErrNotAllowed, u.Hostname())
+	}
+	return nil
+}
+
+// Do 发送请求。非 2xx 的响应不是错误，由调用方根据状态码判断
+func (c *Client) Do(ctx context.Context, r *Request) (*Response, error) {
+	method := strings.ToUpper(r.Method)
+	if method == "" {
+		method = http.MethodGet
+	}
+	u, err := url.Parse(r.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url: %v", err)
+	}
+	if err := c.check(u); err != nil {
+		return nil, err
+	}
+	if int64(len(r.Body)) > c.opts.MaxRequestBytes {
+		return nil, fmt.Errorf("request body of %d bytes exceeds the limit of %d bytes", len(r.Body), c.opts.MaxRequestBytes)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.opts.Timeout)
+	defer cancel()
+	var body io.Reader
+	if r.Body != "" {
+		body = strings.NewReader(r.Body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range r.Headers {
+		req.Header.Set(k, v)
+	}
+	if r.Body != "" && req.Header.Get("Content-Type") == "" && json.Valid([]byte(r.Body)) {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	start := time.Now()
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, c.opts.MaxResponseBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	out := &Response{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		URL:        resp.Request.URL.String(),
+		Headers:    flattenHeaders(resp.Header),
+		Duration:   time.Since(start).Round(time.Millisecond).String(),
+	}
+	if int64(len(data)) > c.opts.MaxResponseBytes {
+		data, out.Truncated = data[:c.opts.MaxResponseBytes], true
+	}
+	out.Body = string(data)
+	if !out.Truncated && strings.Contains(resp.Header.Get("Content-Type"), "json") && json.Valid(data) {
+		out.JSON = json.RawMessage(bytes.TrimSpace(data))
+	}
+	return out, nil
+}
+
+// flattenHeaders 把响应头的多个值用逗号合并
+func flattenHeaders(h http.Header) map[string]string {
+	out := make(map[string]string, len(h))
+	for k, v := range h {
+		out[k] = strings.Join(v, ", ")
+	}
+	return out
+}
@@ -0,0 +1,81 @@
+package httpreq
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAllowed(t *testing.T) {
+	c := New(Options{AllowedDomains: []string{"api.example.com", "*.corp.internal"}}, nil)
+	tests := map[string]bool{
+		"api.example.com":       true,
+		"API.Example.com.":      true,
+		"example.com":           false,
+		"billing.corp.internal": true,
+		"corp.internal":         false,
+		"evilcorp.internal":     false,
+	}
+	for host, want := range tests {
+		if got := c.Allowed(host); got != want {
+			t.Errorf("Allowed(%q) = %v, want %v", host, got, want)
+		}
+	}
+	if New(Options{}, nil).Allowed("api.example.com") {
+		t.Error("expected an empty allowlist to reject every domain")
+	}
+}
+
+func TestDo(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/echo":
+			body, _ := io.ReadAll(r.Body)
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"method": "` + r.Method + `", "type": "` + r.Header.Get("Content-Type") + `", "token": "` +
+				r.Header.Get("X-Token") + `", "body": ` + string(body) + `}`))
+		case "/large":
+			w.Write([]byte(strings.Repeat("x", 100)))
+		case "/away":
+			http.Redirect(w, r, "http://elsewhere.test/", http.StatusFound)
+		}
+	}))
+	defer srv.Close()
+
+	c := New(Options{AllowedDomains: []string{"127.0.0.1"}, MaxRequestBytes: 64, MaxResponseBytes: 90}, nil)
+	resp, err := c.Do(context.Background(), &Request{
+		Method:  "post",
+		URL:     srv.URL + "/echo",
+		Headers: map[string]string{"X-Token": "secret"},
+		Body:    `{"a": 1}`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"method": "POST", "type": "application/json", "token": "secret", "body": {"a": 1}}`
+	if resp.StatusCode != 200 || string(resp.JSON) != want || resp.Truncated {
+		t.Errorf("unexpected response %+v", resp)
+	}
+
+	resp, err = c.Do(context.Background(), &Request{URL: srv.URL + "/large"})
+	if err != nil || !resp.Truncated || len(resp.Body) != 90 || resp.JSON != nil {
+		t.Errorf("expected truncated body, got %+v %v", resp, err)
+	}
+
+	if _, err := c.Do(context.Background(), &Request{Method: "POST", URL: srv.URL + "/echo", Body: strings.Repeat("x", 65)}); err == nil {
+		t.Error("expected oversized request body to be rejected")
+	}
+	if _, err := c.Do(context.Background(), &Request{URL: srv.URL + "/away"}); !errors.Is(err, ErrNotAllowed) {
+		t.Errorf("expected redirect to a disallowed domain to fail, got %v", err)
+	}
+	if _, err := c.Do(context.Background(), &Request{URL: "http://localhost/"}); !errors.Is(err, ErrNotAllowed) {
+		t.Errorf("expected ErrNotAllowed, got %v", err)
+	}
+	if _, err := c.Do(context.Background(), &Request{URL: "file:///etc/passwd"}); err == nil {
+		t.Error("expected non-http scheme to be rejected")
+	}
+}
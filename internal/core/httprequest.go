@@ -0,0 +1,72 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/liangsj/vimcoplit/internal/core/httpreq"
+	"github.com/liangsj/vimcoplit/internal/core/mcp"
+)
+
+// httpRequest 按配置的域名白名单和大小限制发送请求，离线时不可用
+func (s *serviceImpl) httpRequest(ctx context.Context, req *httpreq.Request) (*httpreq.Response, error) {
+	if s.offline.Offline() {
+		return nil, errors.New("http_request is unavailable while offline")
+	}
+	cfg := s.cfg.HTTPRequest
+	client := httpreq.New(httpreq.Options{
+		AllowedDomains:   cfg.AllowedDomains,
+		MaxRequestBytes:  int64(cfg.MaxRequestBytes),
+		MaxResponseBytes: int64(cfg.MaxResponseBytes),
+		Timeout:          cfg.Timeout.Std(),
+	}, nil)
+	return client.Do(ctx, req)
+}
+
+// registerHTTPRequestTool 注册发送 HTTP 请求的内置工具，agent 可以调用白名单中的内部 API 而不需要单独的 MCP 服务器
+func registerHTTPRequestTool(manager *mcp.Manager, do func(ctx context.Context, req *httpreq.Request) (*httpreq.Response, error)) {
+	manager.RegisterBuiltinTool(&mcp.Tool{
+		ID:   "http_request",
+		Name: "http_request",
+		Description: "Send an HTTP request to an allowlisted domain and return the status, headers and body " +
+			"(parsed JSON is included when the response is JSON). Non-2xx responses are returned, not treated as errors",
+		Parameters: []mcp.ToolParameter{{
+			Name:        "method",
+			Type:        "string",
+			Description: "HTTP method, defaults to GET",
+		}, {
+			Name:        "url",
+			Type:        "string",
+			Description: "http or https URL, the host must be in http_request.allowed_domains",
+			Required:    true,
+		}, {
+			Name:        "headers",
+			Type:        "object",
+			Description: "request headers as a map of name to value",
+		}, {
+			Name:        "body",
+			Type:        "string",
+			Description: "request body, sent as application/json when it is valid JSON and no Content-Type is given",
+		}},
+	}, func(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+		req := &httpreq.Request{}
+		req.Method, _ = params["method"].(string)
+		req.URL, _ = params["url"].(string)
+		req.Body, _ = params["body"].(string)
+		if req.URL == "" {
+			return nil, errors.New("url is required")
+		}
+		if headers, ok := params["headers"].(map[string]interface{}); ok {
+			req.Headers = make(map[string]string, len(headers))
+			for k, v := range headers {
+				value, ok := v.(string)
+				if !ok {
+					return nil, fmt.Errorf("header %s must be a string", k)
+				}
+				req.Headers[k] = value
+			}
+		}
+		return do(ctx, req)
+	})
+}
@@ -0,0 +1,221 @@
+package index
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// reembedTimeout 是后台重新计算一批旧向量的超时时间
+const reembedTimeout = time.Minute
+
+// Embedder 把文本转换为向量，未设置时只使用关键词检索。
+// Model 标识提供方和模型，模型不同的向量不能比较
+type Embedder interface {
+	Model() string
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// space 是向量空间，同一模型且同一维度的向量才能比较
+type space struct {
+	model string
+	dim   int
+}
+
+// String 返回 模型@维度 形式的名称
+func (sp space) String() string {
+	return fmt.Sprintf("%s@%d", sp.model, sp.dim)
+}
+
+// SetEmbedder 设置向量化接口，fallbacks 在前一个接口失败时依次使用。
+// 切换模型或维度变化后不会立即重建：新片段使用新模型，旧片段的向量保留，
+// 查询时用对应的接口分别比较，并在被检索命中时于后台用新模型重新计算
+func (x *Index) SetEmbedder(embedder Embedder, fallbacks ...Embedder) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.embedders = nil
+	if embedder != nil {
+		x.embedders = append([]Embedder{embedder}, fallbacks...)
+	}
+}
+
+// spaceID 返回向量空间的 ID，不存在时加入，调用方需持有写锁
+func (x *Index) spaceID(sp space) uint32 {
+	if id, ok := x.spaceIDs[sp]; ok {
+		return id
+	}
+	id := uint32(len(x.spaces))
+	x.spaceIDs[sp] = id
+	x.spaces = append(x.spaces, sp)
+	x.spaceVectors = append(x.spaceVectors, 0)
+	return id
+}
+
+// embeddedIn 返回新计算的向量所在空间的 ID。索引中已有其他模型或维度的向量时记录迁移，
+// 旧向量保留到被检索命中时重新计算，调用方需持有写锁
+func (x *Index) embeddedIn(sp space) uint32 {
+	if id, ok := x.spaceIDs[sp]; ok {
+		return id
+	}
+	for id, old := range x.spaces {
+		if x.spaceVectors[id] == 0 {
+			continue
+		}
+		if old.model == sp.model {
+			log.Printf("%s 的向量维度从 %d 变为 %d，旧向量将在检索命中时重新计算\n", sp.model, old.dim, sp.dim)
+		} else {
+			log.Printf("向量模型从 %s 切换为 %s，旧向量将在检索命中时重新计算\n", old, sp)
+		}
+	}
+	return x.spaceID(sp)
+}
+
+// setVector 按当前的存储精度设置片段的向量，并更新各空间的向量数和向量占用的字节数，调用方需持有写锁
+func (x *Index) setVector(e *entry, vector []float32, id uint32) {
+	if e.vector != nil {
+		x.spaceVectors[e.space]--
+		x.vectorBytes -= int64(e.vector.size())
+	}
+	e.vector, e.space = quantize(vector, x.quantization), id
+	x.spaceVectors[id]++
+	x.vectorBytes += int64(e.vector.size())
+}
+
+// SetQuantization 设置向量的存储精度。已有向量的精度高于 q 时重新编码，在下次刷新时写入段文件；
+// 精度低于 q 的向量保持不变，重新计算向量（如 Rebuild）后才能恢复精度
+func (x *Index) SetQuantization(q Quantization) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.quantization = q
+	for rel, f := range x.files {
+		for _, e := range f.chunks {
+			if e.vector != nil && e.vector.q.bytes() > q.bytes() {
+				x.vectorBytes -= int64(e.vector.size())
+				e.vector = quantize(e.vector.floats(), q)
+				x.vectorBytes += int64(e.vector.size())
+				x.dirty[rel] = true
+			}
+		}
+	}
+}
+
+// embedTexts 依次尝试向量化接口，返回第一个成功的结果和所在的向量空间
+func embedTexts(ctx context.Context, embedders []Embedder, texts []string) ([][]float32, space, error) {
+	var lastErr error
+	for _, embedder := range embedders {
+		vectors, err := embedder.Embed(ctx, texts)
+		if err == nil {
+			err = checkVectors(vectors, len(texts))
+		}
+		if err == nil {
+			return vectors, space{model: embedder.Model(), dim: len(vectors[0])}, nil
+		}
+		if ctx.Err() != nil {
+			return nil, space{}, ctx.Err()
+		}
+		lastErr = fmt.Errorf("%s: %v", embedder.Model(), err)
+		if len(embedders) > 1 {
+			log.Printf("向量化接口 %s 失败，尝试下一个: %v\n", embedder.Model(), err)
+		}
+	}
+	return nil, space{}, lastErr
+}
+
+// checkVectors 检查向量数量与文本数量一致且维度相同
+func checkVectors(vectors [][]float32, n int) error {
+	if len(vectors) != n || n == 0 {
+		return fmt.Errorf("expected %d vectors, got %d", n, len(vectors))
+	}
+	for _, v := range vectors {
+		if len(v) == 0 || len(v) != len(vectors[0]) {
+			return fmt.Errorf("inconsistent vector dimensions")
+		}
+	}
+	return nil
+}
+
+// queryVectors 计算查询在各向量空间中的向量。主接口成功时 current 为主接口的向量空间；
+// 备用接口只在索引中还有该模型的向量时调用
+func (x *Index) queryVectors(ctx context.Context, text string, embedders []Embedder) (vectors map[uint32][]float32, current space, ok bool) {
+	vectors = make(map[uint32][]float32)
+	for i, embedder := range embedders {
+		if i > 0 && !x.hasVectors(embedder.Model()) {
+			continue
+		}
+		result, err := embedder.Embed(ctx, []string{text})
+		if err == nil {
+			err = checkVectors(result, 1)
+		}
+		if err != nil {
+			log.Printf("向量化接口 %s 计算查询向量失败: %v\n", embedder.Model(), err)
+			continue
+		}
+		sp := space{model: embedder.Model(), dim: len(result[0])}
+		if i == 0 {
+			current, ok = sp, true
+		}
+		x.mu.RLock()
+		if id, found := x.spaceIDs[sp]; found {
+			vectors[id] = result[0]
+		}
+		x.mu.RUnlock()
+	}
+	return vectors, current, ok
+}
+
+// hasVectors 判断索引中是否还有该模型计算的向量
+func (x *Index) hasVectors(model string) bool {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	for id, sp := range x.spaces {
+		if sp.model == model && x.spaceVectors[id] > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// reembed 在后台用主接口重新计算检索命中的旧空间片段的向量，同一时间只有一批在进行，
+// 结果在下次刷新时写入段文件
+func (x *Index) reembed(embedder Embedder, stale []*entry) {
+	if len(stale) == 0 || !x.reembedding.CompareAndSwap(false, true) {
+		return
+	}
+	go func() {
+		defer x.reembedding.Store(false)
+		ctx, cancel := context.WithTimeout(context.Background(), reembedTimeout)
+		defer cancel()
+
+		texts := make([]string, len(stale))
+		for i, e := range stale {
+			texts[i] = e.Content
+		}
+		vectors, sp, err := embedTexts(ctx, []Embedder{embedder}, texts)
+		if err != nil {
+			log.Printf("重新计算代码片段向量失败: %v\n", err)
+			return
+		}
+
+		x.mu.Lock()
+		defer x.mu.Unlock()
+		id := x.embeddedIn(sp)
+		for i, e := range stale {
+			// 片段所在的文件可能已在刷新时被替换
+			if f, ok := x.files[e.Path]; ok && containsEntry(f.chunks, e) {
+				x.setVector(e, vectors[i], id)
+				x.dirty[e.Path] = true
+			}
+		}
+	}()
+}
+
+// containsEntry 判断片段是否仍在文件中
+func containsEntry(chunks []*entry, e *entry) bool {
+	for _, c := range chunks {
+		if c == e {
+			return true
+		}
+	}
+	return false
+}
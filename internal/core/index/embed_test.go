@@ -0,0 +1,113 @@
+package index
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// wideEmbedder 与 fakeEmbedder 方向相同但维度不同，模拟更换后的模型
+type wideEmbedder struct{ model string }
+
+func (w wideEmbedder) Model() string { return w.model }
+
+func (w wideEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		if strings.Contains(text, "persist") || strings.Contains(text, "Save") {
+			vectors[i] = []float32{1, 0, 0}
+		} else {
+			vectors[i] = []float32{0, 1, 0}
+		}
+	}
+	return vectors, nil
+}
+
+// failingEmbedder 总是返回错误，模拟不可用的提供方
+type failingEmbedder struct{}
+
+func (failingEmbedder) Model() string { return "down" }
+
+func (failingEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	return nil, errors.New("service unavailable")
+}
+
+func TestEmbedderMigration(t *testing.T) {
+	root, dir := t.TempDir(), t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"store.go": "package store\n\nfunc Save() {}\n",
+		"route.go": "package server\n\nfunc route() {}\n",
+	})
+	ctx := context.Background()
+
+	x := New(root, dir)
+	x.SetEmbedder(fakeEmbedder{})
+	x.Refresh(ctx)
+	if vectors := x.Stats().Vectors; vectors["fake@2"] != 2 {
+		t.Fatalf("expected 2 vectors from the first model, got %v", vectors)
+	}
+
+	// 切换模型后只为新片段计算向量，旧向量保留
+	x.SetEmbedder(wideEmbedder{model: "wide"}, fakeEmbedder{})
+	writeFiles(t, root, map[string]string{"cache.go": "package cache\n\nfunc get() {}\n"})
+	x.Refresh(ctx)
+	if vectors := x.Stats().Vectors; vectors["fake@2"] != 2 || vectors["wide@3"] != 1 {
+		t.Fatalf("expected old vectors to be kept during migration, got %v", vectors)
+	}
+
+	// 旧向量仍可通过备用接口检索，命中后在后台用新模型重新计算
+	results, err := x.Search(ctx, &Query{Text: "persist data", Limit: 1})
+	if err != nil || len(results) != 1 || results[0].Path != "store.go" || results[0].Vector != 1 {
+		t.Fatalf("expected vector match in the old space, got %+v (%v)", results, err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for x.Stats().Vectors["wide@3"] != 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected hit chunk to be re-embedded, got %v", x.Stats().Vectors)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// 两个空间的向量都写入段文件
+	x.Refresh(ctx)
+	loaded := New(root, dir)
+	if vectors := loaded.Stats().Vectors; vectors["fake@2"] != 1 || vectors["wide@3"] != 2 {
+		t.Errorf("expected mixed vectors to persist, got %v", vectors)
+	}
+}
+
+func TestEmbedderFallback(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{"store.go": "package store\n\nfunc Save() {}\n"})
+
+	x := New(root, "")
+	x.SetEmbedder(failingEmbedder{}, fakeEmbedder{})
+	if err := x.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if vectors := x.Stats().Vectors; vectors["fake@2"] != 1 {
+		t.Errorf("expected fallback embedder to be used, got %v", vectors)
+	}
+	results, _ := x.Search(context.Background(), &Query{Text: "persist"})
+	if len(results) != 1 || results[0].Vector != 1 {
+		t.Errorf("expected fallback query vector, got %+v", results)
+	}
+}
+
+func TestEmbedderDimensionChange(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{"store.go": "package store\n\nfunc Save() {}\n"})
+
+	x := New(root, "")
+	x.SetEmbedder(fakeEmbedder{})
+	x.Refresh(context.Background())
+
+	// 同名模型的维度变化时作为新的向量空间，不与旧向量比较
+	x.SetEmbedder(wideEmbedder{model: "fake"})
+	results, _ := x.Search(context.Background(), &Query{Text: "Save"})
+	if len(results) != 1 || results[0].Vector != 0 {
+		t.Errorf("expected vectors of another dimension to be skipped, got %+v", results)
+	}
+}
@@ -0,0 +1,242 @@
+package index
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultGrepLimit 是文件检索未指定数量时返回的匹配数
+	defaultGrepLimit = 100
+
+	// maxGrepLimit 是文件检索一次最多返回的匹配数
+	maxGrepLimit = 5000
+
+	// recencyHalfLife 是文件修改时间得分减半的时间
+	recencyHalfLife = 7 * 24 * time.Hour
+
+	// maxMatchText 是匹配行返回的最大字节数，压缩后的代码一行可能很长
+	maxMatchText = 500
+)
+
+// GrepQuery 是按行检索文件内容的请求，Text 和 Regex 至少设置一个
+type GrepQuery struct {
+	Text       string    `json:"query,omitempty"`       // 按字面量匹配
+	Regex      string    `json:"regex,omitempty"`       // 按正则表达式匹配，优先于 Text
+	IgnoreCase bool      `json:"ignore_case,omitempty"` // 忽略大小写
+	Path       string    `json:"path,omitempty"`        // 文件路径必须以此为前缀
+	Near       string    `json:"near,omitempty"`        // 当前编辑的文件，同目录的文件排在前面
+	Limit      int       `json:"limit,omitempty"`       // 最多返回的匹配数
+	Since      time.Time `json:"since,omitempty"`       // 只检索在此之后（含）修改的文件
+}
+
+// Match 是文件检索的一行匹配，Score 为所在文件按路径相关性和修改时间计算的得分
+type Match struct {
+	Path    string    `json:"path"`
+	Line    int       `json:"line"`
+	Column  int       `json:"column"` // 从 1 开始的字节偏移
+	Text    string    `json:"text"`
+	Score   float64   `json:"score"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// GrepSummary 是一次文件检索的统计，Truncated 表示达到数量上限后提前结束
+type GrepSummary struct {
+	Files     int  `json:"files"`   // 参与检索的文件数
+	Scanned   int  `json:"scanned"` // 已读取的文件数
+	Matches   int  `json:"matches"`
+	Truncated bool `json:"truncated"`
+	Canceled  bool `json:"canceled"`
+}
+
+// candidate 是待检索的文件
+type candidate struct {
+	rel, abs string
+	modTime  time.Time
+	score    float64
+}
+
+// Grep 按行检索仓库中参与索引的文件，不依赖已建立的索引。文件只按路径和修改时间排序，
+// 排序后依次读取，每找到一行匹配就调用 fn，达到数量上限、fn 返回 false 或 ctx 取消时提前结束
+func (x *Index) Grep(ctx context.Context, q *GrepQuery, fn func(Match) bool) (*GrepSummary, error) {
+	pattern, err := q.compile()
+	if err != nil {
+		return nil, err
+	}
+	limit := q.Limit
+	if limit <= 0 {
+		limit = defaultGrepLimit
+	}
+	limit = min(limit, maxGrepLimit)
+
+	x.mu.RLock()
+	ignore, scope := x.ignore, x.scope
+	x.mu.RUnlock()
+
+	summary := &GrepSummary{}
+	now := time.Now()
+	words := pathWords(q)
+	var files []candidate
+	err = walk(ctx, x.root, ignore, scope, func(rel, abs string, info fs.FileInfo, reason string) {
+		if reason != "" || info.IsDir() || !strings.HasPrefix(rel, q.Path) || info.ModTime().Before(q.Since) {
+			return
+		}
+		c := candidate{rel: rel, abs: abs, modTime: info.ModTime()}
+		c.score = pathScore(rel, q.Near, words) + recencyScore(now.Sub(c.modTime))
+		files = append(files, c)
+	})
+	if err != nil {
+		summary.Canceled = ctx.Err() != nil
+		return summary, ignoreCanceled(ctx, err)
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		if files[i].score != files[j].score {
+			return files[i].score > files[j].score
+		}
+		return files[i].rel < files[j].rel
+	})
+	summary.Files = len(files)
+
+	for _, c := range files {
+		if ctx.Err() != nil {
+			summary.Canceled = true
+			return summary, nil
+		}
+		data, err := os.ReadFile(c.abs)
+		if err != nil || isBinary(data) {
+			continue
+		}
+		summary.Scanned++
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		scanner.Buffer(nil, len(data)+1)
+		for line := 1; scanner.Scan(); line++ {
+			loc := pattern.FindIndex(scanner.Bytes())
+			if loc == nil {
+				continue
+			}
+			m := Match{
+				Path:    c.rel,
+				Line:    line,
+				Column:  loc[0] + 1,
+				Text:    truncateLine(scanner.Text()),
+				Score:   c.score,
+				ModTime: c.modTime,
+			}
+			summary.Matches++
+			if !fn(m) {
+				summary.Canceled = true
+				return summary, nil
+			}
+			if summary.Matches >= limit {
+				summary.Truncated = true
+				return summary, nil
+			}
+		}
+	}
+	return summary, nil
+}
+
+// Validate 检查查询是否有效
+func (q *GrepQuery) Validate() error {
+	_, err := q.compile()
+	return err
+}
+
+// compile 把查询编译为正则表达式
+func (q *GrepQuery) compile() (*regexp.Regexp, error) {
+	expr := q.Regex
+	if expr == "" {
+		if q.Text == "" {
+			return nil, fmt.Errorf("query or regex is required")
+		}
+		expr = regexp.QuoteMeta(q.Text)
+	}
+	if q.IgnoreCase {
+		expr = "(?i)" + expr
+	}
+	pattern, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regex: %v", err)
+	}
+	return pattern, nil
+}
+
+// pathWords 返回查询中用于计算路径相关性的小写单词
+func pathWords(q *GrepQuery) []string {
+	text := q.Text
+	if text == "" {
+		text = q.Regex
+	}
+	var words []string
+	for _, ident := range identPattern.FindAllString(text, -1) {
+		for _, word := range splitIdent(ident) {
+			if len(word) > 2 {
+				words = append(words, strings.ToLower(word))
+			}
+		}
+	}
+	return words
+}
+
+// pathScore 计算路径相关性：路径中包含查询单词的比例，加上与 near 共同的目录层数带来的加分
+func pathScore(rel, near string, words []string) float64 {
+	score := 0.0
+	if len(words) > 0 {
+		lower := strings.ToLower(rel)
+		hits := 0
+		for _, word := range words {
+			if strings.Contains(lower, word) {
+				hits++
+			}
+		}
+		score += float64(hits) / float64(len(words))
+	}
+	if near != "" {
+		dir, nearDir := strings.Split(rel, "/"), strings.Split(near, "/")
+		dir, nearDir = dir[:len(dir)-1], nearDir[:len(nearDir)-1]
+		shared := 0
+		for shared < len(dir) && shared < len(nearDir) && dir[shared] == nearDir[shared] {
+			shared++
+		}
+		if depth := max(len(dir), len(nearDir)); depth == 0 {
+			score++
+		} else {
+			score += float64(shared) / float64(depth)
+		}
+	}
+	return score
+}
+
+// recencyScore 计算修改时间得分，刚修改的文件为 1，每过 recencyHalfLife 减半
+func recencyScore(age time.Duration) float64 {
+	if age < 0 {
+		age = 0
+	}
+	return math.Exp2(-float64(age) / float64(recencyHalfLife))
+}
+
+// truncateLine 截断过长的匹配行并去掉行尾的回车
+func truncateLine(text string) string {
+	text = strings.TrimRight(text, "\r")
+	if len(text) > maxMatchText {
+		text = strings.ToValidUTF8(text[:maxMatchText], "") + "…"
+	}
+	return text
+}
+
+// ignoreCanceled 把 ctx 取消导致的错误视为正常结束
+func ignoreCanceled(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,125 @@
+package index
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestGrepRanking(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"old/a.go":           "package old\n\n// TODO: handle request\n",
+		"server/request.go":  "package server\n\n// TODO: parse request\n",
+		"server/route.go":    "package server\n\n// TODO: route\n",
+		"store/store.go":     "package store\n\n// TODO: save\n",
+		"node_modules/x.js":  "// TODO: vendored\n",
+		"server/image.go":    "package server\n",
+		"server/blob/bin.go": "TODO\x00\x01",
+	})
+	old := time.Now().Add(-90 * 24 * time.Hour)
+	for _, name := range []string{"old/a.go", "store/store.go", "server/route.go"} {
+		os.Chtimes(filepath.Join(root, name), old, old)
+	}
+
+	x := New(root, "")
+	var matches []Match
+	summary, err := x.Grep(context.Background(), &GrepQuery{Text: "TODO: request", Regex: `TODO: \w+`}, func(m Match) bool {
+		matches = append(matches, m)
+		return true
+	})
+	if err != nil {
+		t.Fatalf("failed to grep: %v", err)
+	}
+	if len(matches) != 4 || summary.Matches != 4 || summary.Truncated || summary.Canceled {
+		t.Fatalf("expected 4 matches, got %+v %+v", matches, summary)
+	}
+	// 路径包含查询单词且最近修改的文件排在最前，旧文件按路径相关性排序
+	want := []string{"server/request.go", "old/a.go", "server/route.go", "store/store.go"}
+	for i, path := range want {
+		if matches[i].Path != path {
+			t.Fatalf("expected order %v, got %+v", want, matches)
+		}
+	}
+	if m := matches[0]; m.Line != 3 || m.Column != 4 || m.Text != "// TODO: parse request" {
+		t.Errorf("unexpected match position %+v", m)
+	}
+	if summary.Files != 6 || summary.Scanned != 5 {
+		t.Errorf("expected binary file to be skipped, got %+v", summary)
+	}
+
+	// near 使同目录的文件排在前面
+	matches = nil
+	x.Grep(context.Background(), &GrepQuery{Text: "TODO", Near: "store/main.go", Path: "s"}, func(m Match) bool {
+		matches = append(matches, m)
+		return true
+	})
+	if len(matches) != 3 || matches[0].Path != "store/store.go" {
+		t.Errorf("expected near file first, got %+v", matches)
+	}
+}
+
+func TestGrepEarlyTermination(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{}
+	for _, name := range []string{"a", "b", "c", "d"} {
+		files[name+".go"] = "x\nneedle\nNEEDLE\n"
+	}
+	writeFiles(t, root, files)
+	x := New(root, "")
+
+	count := 0
+	summary, err := x.Grep(context.Background(), &GrepQuery{Text: "needle", IgnoreCase: true, Limit: 3}, func(Match) bool {
+		count++
+		return true
+	})
+	if err != nil || count != 3 || !summary.Truncated {
+		t.Errorf("expected limit to stop after 3 matches, got %d %+v %v", count, summary, err)
+	}
+
+	count = 0
+	summary, _ = x.Grep(context.Background(), &GrepQuery{Text: "needle"}, func(Match) bool {
+		count++
+		return false
+	})
+	if count != 1 || !summary.Canceled {
+		t.Errorf("expected callback to stop the search, got %d %+v", count, summary)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	count = 0
+	summary, err = x.Grep(ctx, &GrepQuery{Text: "needle"}, func(Match) bool {
+		count++
+		cancel()
+		return true
+	})
+	if err != nil || count != 1 || !summary.Canceled || summary.Scanned != 1 {
+		t.Errorf("expected cancellation to stop before the next file, got %d %+v %v", count, summary, err)
+	}
+
+	if _, err := x.Grep(context.Background(), &GrepQuery{Regex: "("}, func(Match) bool { return true }); err == nil {
+		t.Error("expected invalid regex error")
+	}
+	if _, err := x.Grep(context.Background(), &GrepQuery{}, func(Match) bool { return true }); err == nil {
+		t.Error("expected error for empty query")
+	}
+}
+
+func TestGrepSince(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{"a.go": "// TODO a\n", "b.go": "// TODO b\n"})
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(filepath.Join(root, "a.go"), old, old)
+
+	x := New(root, "")
+	var paths []string
+	summary, err := x.Grep(context.Background(), &GrepQuery{Text: "TODO", Since: time.Now().Add(-time.Minute)}, func(m Match) bool {
+		paths = append(paths, m.Path)
+		return true
+	})
+	if err != nil || summary.Files != 1 || len(paths) != 1 || paths[0] != "b.go" {
+		t.Errorf("expected only recently modified file, got %v %+v %v", paths, summary, err)
+	}
+}
@@ -0,0 +1,523 @@
+package index
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode"
+)
+
+const (
+	// chunkLines 是每个代码片段的行数
+	chunkLines = 40
+
+	// chunkOverlap 是相邻片段重叠的行数，避免函数被切断后两边都检索不到
+	chunkOverlap = 10
+
+	// maxFileSize 是默认参与索引的文件的最大字节数，更大的文件通常是生成的代码或数据
+	maxFileSize = 1 << 20
+
+	// embedBatch 是每次调用 Embedder 的片段数量
+	embedBatch = 64
+
+	// defaultLimit 是查询未指定数量时返回的结果数
+	defaultLimit = 10
+)
+
+// BM25 参数
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// rrfK 是倒数排名融合的平滑常数，关键词和向量两路结果按 1/(rrfK+rank) 合并
+const rrfK = 60.0
+
+// sourceExts 是参与索引的源码扩展名
+var sourceExts = map[string]bool{
+	".go": true, ".py": true, ".js": true, ".jsx": true, ".ts": true, ".tsx": true,
+	".rs": true, ".java": true, ".rb": true, ".c": true, ".h": true, ".cpp": true, ".lua": true, ".vim": true,
+	".md": true, ".sh": true, ".yaml": true, ".yml": true, ".json": true, ".toml": true, ".proto": true, ".sql": true,
+}
+
+// skipDirs 是建立索引时跳过的目录
+var skipDirs = map[string]bool{
+	"node_modules": true, "vendor": true, "dist": true, "build": true, "target": true, "__pycache__": true,
+}
+
+// identPattern 匹配代码中的标识符
+var identPattern = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_]*`)
+
+// Chunk 是索引中的一个代码片段，Path 相对仓库根目录
+type Chunk struct {
+	Path      string `json:"path"`
+	StartLine int    `json:"start_line"`
+	EndLine   int    `json:"end_line"`
+	Content   string `json:"content"`
+}
+
+// Query 是检索请求，Regex 和 Path 先过滤候选片段，再按 Text 做关键词和向量检索
+type Query struct {
+	Text  string `json:"query"`
+	Regex string `json:"regex,omitempty"` // 片段内容必须匹配的正则表达式
+	Path  string `json:"path,omitempty"`  // 片段路径必须以此为前缀
+	Limit int    `json:"limit,omitempty"`
+}
+
+// Result 是一条检索结果，Score 为融合后的得分
+type Result struct {
+	Chunk
+	Score   float64  `json:"score"`
+	Keyword float64  `json:"keyword"`           // BM25 得分
+	Vector  float64  `json:"vector,omitempty"`  // 与查询向量的余弦相似度
+	Matches []string `json:"matches,omitempty"` // 在片段中精确出现的查询标识符
+	Source  string   `json:"source,omitempty"`  // 结果所在的只读上下文源，工作区中的结果为空
+
+	entry *entry
+}
+
+// posting 是片段中一个词的词频，term 为词典中的 ID
+type posting struct {
+	term uint32
+	freq uint32
+}
+
+// entry 是已索引的片段及其词频和向量，大仓库中片段数量很多，词频用按词 ID 排序的切片保存
+type entry struct {
+	Chunk
+	terms  []posting
+	length int
+	vector *storedVector
+	space  uint32 // 向量所在的向量空间，vector 为 nil 时无意义
+}
+
+// file 是单个文件的索引
+type file struct {
+	modTime time.Time
+	size    int64
+	chunks  []*entry
+}
+
+// Index 是仓库代码的检索索引，刷新时只重新切分修改过的文件，
+// 修改写入增量段文件，启动时从段文件加载而不必重新扫描整个仓库
+type Index struct {
+	refreshMu sync.Mutex // 同一时间只有一次刷新，刷新期间仍可检索
+	mu        sync.RWMutex
+	root      string
+	dir       string // 段文件目录，为空时只保存在内存中
+	files     map[string]*file
+	termIDs   map[string]uint32
+	termNames []string
+	docFreq   []int // 词 ID -> 包含该词的片段数
+	chunks    int
+	totalLen  int
+	dirty     map[string]bool // 上次写入段文件后修改或删除的文件
+	manifest  manifest
+	ignore    func(rel string) bool
+	scope     Scope
+	progress  progressHub
+
+	embedders    []Embedder   // 第一个为主接口，其余在失败时依次使用
+	quantization Quantization // 新计算的向量的存储精度
+	spaces       []space      // 向量空间 ID -> 向量空间
+	spaceIDs     map[space]uint32
+	spaceVectors []int // 向量空间 ID -> 该空间中的片段数
+	vectorBytes  int64 // 内存中向量编码后的总字节数
+	reembedding  atomic.Bool
+}
+
+// New 创建代码索引，root 为仓库根目录，dir 为保存段文件的目录，
+// 已有的段文件格式版本不一致或损坏时丢弃，下次刷新时重新建立索引
+func New(root, dir string) *Index {
+	x := &Index{root: root, dir: dir, quantization: Float32}
+	x.reset()
+	if dir != "" {
+		if err := x.load(); err != nil {
+			log.Printf("加载代码索引失败，将重新建立: %v\n", err)
+			x.reset()
+		}
+	}
+	return x
+}
+
+// reset 清空内存中的索引，调用方需持有锁
+func (x *Index) reset() {
+	x.files = make(map[string]*file)
+	x.termIDs = make(map[string]uint32)
+	x.termNames = nil
+	x.docFreq = nil
+	x.chunks = 0
+	x.totalLen = 0
+	x.dirty = make(map[string]bool)
+	x.spaces = nil
+	x.spaceIDs = make(map[space]uint32)
+	x.spaceVectors = nil
+	x.vectorBytes = 0
+	x.manifest = manifest{Version: FormatVersion}
+}
+
+// SetIgnore 设置忽略规则，匹配的文件和目录在下次刷新时移出索引
+func (x *Index) SetIgnore(ignore func(rel string) bool) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.ignore = ignore
+}
+
+// ignored 判断文件是否匹配忽略规则，调用方需持有锁
+func (x *Index) ignored(rel string) bool {
+	return x.ignore != nil && x.ignore(rel)
+}
+
+// add 加入文件的片段并更新词的文档频率，调用方需持有锁
+func (x *Index) add(rel string, f *file) {
+	x.files[rel] = f
+	for _, e := range f.chunks {
+		for _, p := range e.terms {
+			x.docFreq[p.term]++
+		}
+		if e.vector != nil {
+			x.spaceVectors[e.space]++
+			x.vectorBytes += int64(e.vector.size())
+		}
+		x.chunks++
+		x.totalLen += e.length
+	}
+}
+
+// remove 移除文件的片段，调用方需持有锁
+func (x *Index) remove(rel string) {
+	f, ok := x.files[rel]
+	if !ok {
+		return
+	}
+	for _, e := range f.chunks {
+		for _, p := range e.terms {
+			x.docFreq[p.term]--
+		}
+		if e.vector != nil {
+			x.spaceVectors[e.space]--
+			x.vectorBytes -= int64(e.vector.size())
+		}
+		x.chunks--
+		x.totalLen -= e.length
+	}
+	delete(x.files, rel)
+}
+
+// Search 检索与查询相关的代码片段：先按正则和路径过滤候选，
+// 再把 BM25 和向量相似度两路排名融合，查询中的标识符在片段中精确出现时额外加分
+func (x *Index) Search(ctx context.Context, q *Query) ([]Result, error) {
+	var pattern *regexp.Regexp
+	if q.Regex != "" {
+		var err error
+		if pattern, err = regexp.Compile(q.Regex); err != nil {
+			return nil, fmt.Errorf("invalid regex: %v", err)
+		}
+	}
+	if strings.TrimSpace(q.Text) == "" && pattern == nil {
+		return nil, fmt.Errorf("query or regex is required")
+	}
+	limit := q.Limit
+	if limit <= 0 {
+		limit = defaultLimit
+	}
+
+	x.mu.RLock()
+	embedders := x.embedders
+	x.mu.RUnlock()
+	var queryVectors map[uint32][]float32
+	var current space
+	var currentOK bool
+	if len(embedders) > 0 && strings.TrimSpace(q.Text) != "" {
+		queryVectors, current, currentOK = x.queryVectors(ctx, q.Text, embedders)
+	}
+
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	var candidates []*entry
+	for rel, f := range x.files {
+		if q.Path != "" && !strings.HasPrefix(rel, q.Path) {
+			continue
+		}
+		for _, e := range f.chunks {
+			if pattern == nil || pattern.MatchString(e.Content) {
+				candidates = append(candidates, e)
+			}
+		}
+	}
+
+	terms := queryTerms(q.Text)
+	ids := x.lookup(terms)
+	idents := identPattern.FindAllString(q.Text, -1)
+	results := make([]Result, len(candidates))
+	for i, e := range candidates {
+		results[i] = Result{Chunk: e.Chunk, Keyword: x.bm25(e, ids), entry: e}
+		if v, ok := queryVectors[e.space]; ok && e.vector != nil {
+			results[i].Vector = cosine(v, e.vector)
+		}
+		for _, ident := range idents {
+			if len(ident) > 2 && x.hasIdent(e, ident) {
+				results[i].Matches = append(results[i].Matches, ident)
+			}
+		}
+	}
+
+	fuse(results, terms, len(queryVectors) > 0, len(idents))
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		if results[i].Path != results[j].Path {
+			return results[i].Path < results[j].Path
+		}
+		return results[i].StartLine < results[j].StartLine
+	})
+
+	// 没有任何一路命中的片段不返回，指定了正则时匹配的片段都算命中
+	n := 0
+	for _, r := range results {
+		if r.Score > 0 || pattern != nil {
+			results[n] = r
+			n++
+		}
+	}
+	results = results[:min(n, limit)]
+
+	// 命中的片段的向量来自旧模型时在后台用当前模型重新计算
+	if currentOK {
+		id, found := x.spaceIDs[current]
+		var stale []*entry
+		for _, r := range results {
+			if r.entry.vector != nil && (!found || r.entry.space != id) {
+				stale = append(stale, r.entry)
+			}
+		}
+		x.reembed(embedders[0], stale)
+	}
+	return results, nil
+}
+
+// Merge 合并多个索引的检索结果并按得分排序，返回前 limit 条，limit 不大于 0 时使用默认数量。
+// 得分按各索引内的排名融合得到，不同索引的结果可以直接比较
+func Merge(limit int, lists ...[]Result) []Result {
+	if limit <= 0 {
+		limit = defaultLimit
+	}
+	var results []Result
+	for _, list := range lists {
+		results = append(results, list...)
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	return results[:min(len(results), limit)]
+}
+
+// fuse 按倒数排名融合关键词和向量两路排名，idents 为查询中标识符的数量
+func fuse(results []Result, terms []string, vectors bool, idents int) {
+	rank := func(score func(r *Result) float64) {
+		order := make([]int, 0, len(results))
+		for i := range results {
+			if score(&results[i]) > 0 {
+				order = append(order, i)
+			}
+		}
+		sort.SliceStable(order, func(a, b int) bool {
+			return score(&results[order[a]]) > score(&results[order[b]])
+		})
+		for r, i := range order {
+			results[i].Score += 1 / (rrfK + float64(r+1))
+		}
+	}
+
+	if len(terms) > 0 {
+		rank(func(r *Result) float64 { return r.Keyword })
+	}
+	if vectors {
+		rank(func(r *Result) float64 { return r.Vector })
+	}
+	for i := range results {
+		if r := &results[i]; len(r.Matches) > 0 {
+			// 所有标识符都精确出现相当于在另一路排名中名列第一
+			r.Score += float64(len(r.Matches)) / float64(idents) / (rrfK + 1)
+		}
+	}
+}
+
+// lookup 返回查询词在词典中的 ID，不在词典中的词不可能命中，直接忽略，调用方需持有锁
+func (x *Index) lookup(terms []string) []uint32 {
+	ids := make([]uint32, 0, len(terms))
+	for _, term := range terms {
+		if id, ok := x.termIDs[term]; ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// freq 返回词在片段中的词频
+func (e *entry) freq(term uint32) uint32 {
+	i := sort.Search(len(e.terms), func(i int) bool { return e.terms[i].term >= term })
+	if i < len(e.terms) && e.terms[i].term == term {
+		return e.terms[i].freq
+	}
+	return 0
+}
+
+// hasIdent 判断标识符是否在片段中完整出现（区分大小写），调用方需持有锁
+func (x *Index) hasIdent(e *entry, ident string) bool {
+	id, ok := x.termIDs[strings.ToLower(ident)]
+	if !ok || e.freq(id) == 0 {
+		return false
+	}
+	for content := e.Content; ; {
+		i := strings.Index(content, ident)
+		if i < 0 {
+			return false
+		}
+		end := i + len(ident)
+		if (i == 0 || !isIdentByte(content[i-1])) && (end == len(content) || !isIdentByte(content[end])) {
+			return true
+		}
+		content = content[i+1:]
+	}
+}
+
+// isIdentByte 判断字节是否可以出现在标识符中
+func isIdentByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// bm25 计算片段对查询词的 BM25 得分，调用方需持有锁
+func (x *Index) bm25(e *entry, terms []uint32) float64 {
+	if x.chunks == 0 || len(terms) == 0 {
+		return 0
+	}
+	avgLen := float64(x.totalLen) / float64(x.chunks)
+	score := 0.0
+	for _, term := range terms {
+		tf := float64(e.freq(term))
+		if tf == 0 {
+			continue
+		}
+		df := float64(x.docFreq[term])
+		idf := math.Log(1 + (float64(x.chunks)-df+0.5)/(df+0.5))
+		score += idf * tf * (bm25K1 + 1) / (tf + bm25K1*(1-bm25B+bm25B*float64(e.length)/avgLen))
+	}
+	return score
+}
+
+// parsedChunk 是切分后尚未加入词典的片段，可以在不持有锁的情况下并行生成
+type parsedChunk struct {
+	Chunk
+	counts map[string]uint32
+	length int
+}
+
+// split 把文件切分为相互重叠的片段并统计词频
+func split(rel, src string) []parsedChunk {
+	lines := strings.SplitAfter(src, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	var chunks []parsedChunk
+	for start := 0; start < len(lines); start += chunkLines - chunkOverlap {
+		end := min(start+chunkLines, len(lines))
+		content := strings.Join(lines[start:end], "")
+		if strings.TrimSpace(content) != "" {
+			chunks = append(chunks, parseChunk(Chunk{Path: rel, StartLine: start + 1, EndLine: end, Content: content}))
+		}
+		if end == len(lines) {
+			break
+		}
+	}
+	return chunks
+}
+
+// parseChunk 统计片段的词频，路径中的词也计入，方便按文件名检索
+func parseChunk(c Chunk) parsedChunk {
+	pc := parsedChunk{Chunk: c, counts: make(map[string]uint32)}
+	for _, term := range append(queryTerms(c.Content), queryTerms(c.Path)...) {
+		pc.counts[term]++
+		pc.length++
+	}
+	return pc
+}
+
+// newEntry 把切分后的片段加入词典，词频按词 ID 排序，调用方需持有锁
+func (x *Index) newEntry(pc parsedChunk) *entry {
+	e := &entry{Chunk: pc.Chunk, terms: make([]posting, 0, len(pc.counts)), length: pc.length}
+	for term, freq := range pc.counts {
+		e.terms = append(e.terms, posting{term: x.termID(term), freq: freq})
+	}
+	sort.Slice(e.terms, func(i, j int) bool { return e.terms[i].term < e.terms[j].term })
+	return e
+}
+
+// termID 返回词在词典中的 ID，不存在时加入词典，调用方需持有锁
+func (x *Index) termID(term string) uint32 {
+	if id, ok := x.termIDs[term]; ok {
+		return id
+	}
+	id := uint32(len(x.termNames))
+	x.termIDs[term] = id
+	x.termNames = append(x.termNames, term)
+	x.docFreq = append(x.docFreq, 0)
+	return id
+}
+
+// queryTerms 把文本拆成检索词
+func queryTerms(text string) []string {
+	var terms []string
+	for _, ident := range identPattern.FindAllString(text, -1) {
+		terms = append(terms, identTerms(ident)...)
+	}
+	return terms
+}
+
+// identTerms 把标识符拆成小写的检索词：完整的标识符以及驼峰和下划线分隔的各部分，
+// 如 parseHTTPRequest 得到 parsehttprequest、parse、http、request
+func identTerms(ident string) []string {
+	var terms []string
+	whole := strings.ToLower(ident)
+	if len(whole) > 1 {
+		terms = append(terms, whole)
+	}
+	parts := splitIdent(ident)
+	if len(parts) > 1 {
+		for _, part := range parts {
+			if len(part) > 1 {
+				terms = append(terms, strings.ToLower(part))
+			}
+		}
+	}
+	return terms
+}
+
+// splitIdent 按下划线、数字和大小写变化拆分标识符
+func splitIdent(ident string) []string {
+	var parts []string
+	runes := []rune(ident)
+	start := 0
+	for i := 1; i <= len(runes); i++ {
+		boundary := i == len(runes) || runes[i] == '_' ||
+			unicode.IsUpper(runes[i]) && (unicode.IsLower(runes[i-1]) ||
+				i+1 < len(runes) && unicode.IsUpper(runes[i-1]) && unicode.IsLower(runes[i+1]))
+		if !boundary {
+			continue
+		}
+		if part := strings.Trim(string(runes[start:i]), "_"); part != "" {
+			parts = append(parts, part)
+		}
+		start = i
+	}
+	return parts
+}
@@ -0,0 +1,134 @@
+package index
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSearchKeyword(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"server/http.go":    "package server\n\nfunc parseHTTPRequest(r *Request) error {\n\treturn nil\n}\n",
+		"server/route.go":   "package server\n\n// route requests to handlers\nfunc route() {}\n",
+		"store/store.go":    "package store\n\nfunc Save() {}\n",
+		"node_modules/x.js": "function parseHTTPRequest() {}\n",
+	})
+
+	x := New(root, "")
+	if err := x.Refresh(context.Background()); err != nil {
+		t.Fatalf("failed to refresh index: %v", err)
+	}
+	if stats := x.Stats(); stats.Files != 3 {
+		t.Errorf("expected 3 indexed files, got %d", stats.Files)
+	}
+
+	results, err := x.Search(context.Background(), &Query{Text: "parseHTTPRequest"})
+	if err != nil {
+		t.Fatalf("failed to search: %v", err)
+	}
+	if len(results) != 1 || results[0].Path != "server/http.go" {
+		t.Fatalf("expected exact identifier match, got %+v", results)
+	}
+	if !reflect.DeepEqual(results[0].Matches, []string{"parseHTTPRequest"}) {
+		t.Errorf("expected identifier to be reported as exact match, got %v", results[0].Matches)
+	}
+
+	// 拆分后的词同样能检索到
+	results, _ = x.Search(context.Background(), &Query{Text: "http request"})
+	if len(results) == 0 || results[0].Path != "server/http.go" {
+		t.Errorf("expected split identifier match first, got %+v", results)
+	}
+
+	results, _ = x.Search(context.Background(), &Query{Regex: `func \w+\(\)`, Path: "server/"})
+	if len(results) != 1 || results[0].Path != "server/route.go" {
+		t.Errorf("expected regex and path filter, got %+v", results)
+	}
+	if _, err := x.Search(context.Background(), &Query{Regex: "("}); err == nil {
+		t.Error("expected error for invalid regex")
+	}
+
+	os.Remove(filepath.Join(root, "server/http.go"))
+	x.Refresh(context.Background())
+	if results, _ = x.Search(context.Background(), &Query{Text: "parseHTTPRequest"}); len(results) != 0 {
+		t.Errorf("expected deleted file to leave the index, got %+v", results)
+	}
+}
+
+// fakeEmbedder 按是否包含关键字把文本映射到两个方向
+type fakeEmbedder struct{}
+
+func (fakeEmbedder) Model() string { return "fake" }
+
+func (fakeEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		if strings.Contains(text, "persist") || strings.Contains(text, "Save") {
+			vectors[i] = []float32{1, 0}
+		} else {
+			vectors[i] = []float32{0, 1}
+		}
+	}
+	return vectors, nil
+}
+
+func TestSearchHybrid(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"store.go": "package store\n\nfunc Save() {}\n",
+		"route.go": "package server\n\nfunc route() {}\n",
+	})
+
+	x := New(root, "")
+	x.SetEmbedder(fakeEmbedder{})
+	x.Refresh(context.Background())
+
+	// 关键词没有命中时由向量相似度找到语义相关的片段
+	results, err := x.Search(context.Background(), &Query{Text: "persist data", Limit: 1})
+	if err != nil {
+		t.Fatalf("failed to search: %v", err)
+	}
+	if len(results) != 1 || results[0].Path != "store.go" || results[0].Vector != 1 {
+		t.Errorf("expected vector match, got %+v", results)
+	}
+}
+
+func TestSplitIdent(t *testing.T) {
+	tests := map[string][]string{
+		"parseHTTPRequest": {"parse", "HTTP", "Request"},
+		"max_file_size":    {"max", "file", "size"},
+		"Save":             {"Save"},
+	}
+	for ident, want := range tests {
+		if got := splitIdent(ident); !reflect.DeepEqual(got, want) {
+			t.Errorf("splitIdent(%q) = %v, want %v", ident, got, want)
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	workspace := []Result{{Chunk: Chunk{Path: "a.go"}, Score: 0.03}, {Chunk: Chunk{Path: "b.go"}, Score: 0.01}}
+	library := []Result{{Chunk: Chunk{Path: "lib.go"}, Score: 0.02, Source: "lib"}}
+
+	merged := Merge(2, workspace, library)
+	if len(merged) != 2 || merged[0].Path != "a.go" || merged[1].Source != "lib" {
+		t.Errorf("unexpected merged results: %+v", merged)
+	}
+	if merged := Merge(0, workspace, library); len(merged) != 3 {
+		t.Errorf("expected default limit to keep all results, got %d", len(merged))
+	}
+}
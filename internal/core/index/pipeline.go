@@ -0,0 +1,347 @@
+package index
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log"
+	"math"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+)
+
+const (
+	// embedWorkers 是同时进行的向量化请求数
+	embedWorkers = 4
+
+	// progressInterval 是两次进度通知的最短间隔，避免大仓库刷新时通知过于频繁
+	progressInterval = 200 * time.Millisecond
+)
+
+// indexWorkers 是并行读取和切分文件的协程数
+var indexWorkers = min(runtime.NumCPU(), 8)
+
+// Phase 是刷新所处的阶段
+type Phase string
+
+const (
+	PhaseScan  Phase = "scan"  // 扫描仓库，找出修改过的文件
+	PhaseIndex Phase = "index" // 读取并切分修改过的文件
+	PhaseEmbed Phase = "embed" // 为新的片段计算向量
+	PhaseSave  Phase = "save"  // 写入段文件
+	PhaseDone  Phase = "done"
+)
+
+// Progress 是刷新的进度，ETA 为当前阶段预计剩余的秒数
+type Progress struct {
+	Running     bool      `json:"running"`
+	Phase       Phase     `json:"phase,omitempty"`
+	FilesDone   int       `json:"files_done"`
+	FilesTotal  int       `json:"files_total"`
+	ChunksDone  int       `json:"chunks_done"`  // 已计算向量的片段数
+	ChunksTotal int       `json:"chunks_total"` // 需要计算向量的片段数
+	StartedAt   time.Time `json:"started_at"`
+	ETA         float64   `json:"eta_seconds"`
+	Error       string    `json:"error,omitempty"`
+
+	phaseAt time.Time
+}
+
+// progressHub 保存最近的进度并通知订阅者，每个订阅者只保留最新的一条进度
+type progressHub struct {
+	mu      sync.Mutex
+	current Progress
+	sentAt  time.Time
+	nextID  int
+	subs    map[int]chan Progress
+}
+
+// update 修改进度并通知订阅者，force 为 false 时按 progressInterval 限流
+func (h *progressHub) update(force bool, fn func(p *Progress)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	p := &h.current
+	phase := p.Phase
+	fn(p)
+	now := time.Now()
+	if p.Phase != phase {
+		p.phaseAt = now
+	}
+	done, total := p.FilesDone, p.FilesTotal
+	if p.Phase == PhaseEmbed {
+		done, total = p.ChunksDone, p.ChunksTotal
+	}
+	p.ETA = 0
+	if p.Running && done > 0 && total > done {
+		eta := now.Sub(p.phaseAt).Seconds() / float64(done) * float64(total-done)
+		p.ETA = math.Round(eta*10) / 10
+	}
+
+	if !force && now.Sub(h.sentAt) < progressInterval {
+		return
+	}
+	h.sentAt = now
+	for _, ch := range h.subs {
+		// 只有持有锁时才会发送，清空后发送不会阻塞
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- *p
+	}
+}
+
+// Progress 返回当前或最近一次刷新的进度
+func (x *Index) Progress() Progress {
+	x.progress.mu.Lock()
+	defer x.progress.mu.Unlock()
+	return x.progress.current
+}
+
+// Watch 订阅刷新进度，返回当前进度、后续进度的通道和取消订阅的函数，通道只保留最新的一条进度
+func (x *Index) Watch() (Progress, <-chan Progress, func()) {
+	h := &x.progress
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.subs == nil {
+		h.subs = make(map[int]chan Progress)
+	}
+	id := h.nextID
+	h.nextID++
+	ch := make(chan Progress, 1)
+	h.subs[id] = ch
+	return h.current, ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		delete(h.subs, id)
+	}
+}
+
+// Refresh 扫描仓库，重新索引修改过的文件并移除已删除的文件，
+// 同一时间只有一次刷新，刷新期间已索引的内容仍可检索
+func (x *Index) Refresh(ctx context.Context) error {
+	x.refreshMu.Lock()
+	defer x.refreshMu.Unlock()
+	return x.refresh(ctx)
+}
+
+// refresh 依次扫描、并行切分、批量计算向量并写入段文件。
+// 取消时已处理的文件仍会写入段文件，下次刷新不必重新处理，调用方需持有 refreshMu
+func (x *Index) refresh(ctx context.Context) error {
+	x.progress.update(true, func(p *Progress) {
+		*p = Progress{Running: true, Phase: PhaseScan, StartedAt: time.Now()}
+	})
+	err := x.run(ctx)
+
+	x.progress.update(true, func(p *Progress) { p.Phase = PhaseSave })
+	x.mu.Lock()
+	if saveErr := x.flush(); saveErr != nil {
+		log.Printf("保存代码索引失败: %v\n", saveErr)
+	}
+	x.mu.Unlock()
+
+	x.progress.update(true, func(p *Progress) {
+		p.Running = false
+		p.Phase = PhaseDone
+		if err != nil {
+			p.Error = err.Error()
+		}
+	})
+	return err
+}
+
+// run 执行刷新的各个阶段
+func (x *Index) run(ctx context.Context) error {
+	changed, seen, err := x.scan(ctx)
+	if err != nil {
+		return err
+	}
+	x.progress.update(true, func(p *Progress) {
+		p.Phase = PhaseIndex
+		p.FilesTotal = len(changed)
+	})
+	if err := x.index(ctx, changed); err != nil {
+		return err
+	}
+
+	// 扫描完整时才移除没有出现的文件
+	x.mu.Lock()
+	for rel := range x.files {
+		if !seen[rel] {
+			x.remove(rel)
+			x.dirty[rel] = true
+		}
+	}
+	embedders := x.embedders
+	x.mu.Unlock()
+
+	if len(embedders) > 0 {
+		return x.embed(ctx, embedders)
+	}
+	return nil
+}
+
+// change 是需要重新索引的文件
+type change struct {
+	rel  string
+	path string
+	info fs.FileInfo
+}
+
+// scan 按索引范围遍历仓库，返回修改过的文件和所有参与索引的文件
+func (x *Index) scan(ctx context.Context) ([]change, map[string]bool, error) {
+	x.mu.RLock()
+	ignore, scope := x.ignore, x.scope
+	x.mu.RUnlock()
+
+	var candidates []change
+	err := walk(ctx, x.root, ignore, scope, func(rel, abs string, info fs.FileInfo, reason string) {
+		if reason == "" {
+			candidates = append(candidates, change{rel: rel, path: abs, info: info})
+		}
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to scan repository: %v", err)
+	}
+
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	var changed []change
+	seen := make(map[string]bool, len(candidates))
+	for _, c := range candidates {
+		seen[c.rel] = true
+		if f, ok := x.files[c.rel]; !ok || !f.modTime.Equal(c.info.ModTime()) || f.size != c.info.Size() {
+			changed = append(changed, c)
+		}
+	}
+	return changed, seen, nil
+}
+
+// parsedFile 是读取并切分后的文件，ok 为 false 表示文件无法读取或是二进制文件
+type parsedFile struct {
+	change
+	chunks []parsedChunk
+	ok     bool
+}
+
+// index 用 indexWorkers 个协程并行读取和切分文件，切分结果逐个加入索引
+func (x *Index) index(ctx context.Context, changed []change) error {
+	jobs := make(chan change)
+	results := make(chan parsedFile, indexWorkers)
+	var wg sync.WaitGroup
+	for i := 0; i < indexWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c := range jobs {
+				results <- parseFile(c)
+			}
+		}()
+	}
+	go func() {
+		defer close(jobs)
+		for _, c := range changed {
+			select {
+			case jobs <- c:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	for pf := range results {
+		if pf.ok {
+			x.mu.Lock()
+			chunks := make([]*entry, len(pf.chunks))
+			for i, pc := range pf.chunks {
+				chunks[i] = x.newEntry(pc)
+			}
+			x.remove(pf.rel)
+			x.add(pf.rel, &file{modTime: pf.info.ModTime(), size: pf.info.Size(), chunks: chunks})
+			x.dirty[pf.rel] = true
+			x.mu.Unlock()
+		}
+		x.progress.update(false, func(p *Progress) { p.FilesDone++ })
+	}
+	return ctx.Err()
+}
+
+// parseFile 读取并切分文件
+func parseFile(c change) parsedFile {
+	src, err := os.ReadFile(c.path)
+	if err != nil || isBinary(src) {
+		return parsedFile{change: c}
+	}
+	return parsedFile{change: c, chunks: split(c.rel, string(src)), ok: true}
+}
+
+// embed 为还没有向量的片段计算向量，每次请求 embedBatch 个片段，最多 embedWorkers 个请求同时进行。
+// 主接口失败时依次使用备用接口，都失败时停止计算，已有的向量和关键词检索不受影响。
+// 已有旧模型向量的片段不在这里重新计算，而是在被检索命中时计算
+func (x *Index) embed(ctx context.Context, embedders []Embedder) error {
+	x.mu.RLock()
+	var pending []*entry
+	for _, f := range x.files {
+		for _, e := range f.chunks {
+			if e.vector == nil {
+				pending = append(pending, e)
+			}
+		}
+	}
+	x.mu.RUnlock()
+	x.progress.update(true, func(p *Progress) {
+		p.Phase = PhaseEmbed
+		p.ChunksTotal = len(pending)
+	})
+
+	embedCtx, stop := context.WithCancel(ctx)
+	defer stop()
+	batches := make(chan []*entry)
+	var once sync.Once
+	var wg sync.WaitGroup
+	for i := 0; i < embedWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range batches {
+				texts := make([]string, len(batch))
+				for i, e := range batch {
+					texts[i] = e.Content
+				}
+				vectors, sp, err := embedTexts(embedCtx, embedders, texts)
+				if err != nil {
+					once.Do(func() {
+						log.Printf("计算代码片段向量失败: %v\n", err)
+						stop()
+					})
+					continue
+				}
+				x.mu.Lock()
+				id := x.embeddedIn(sp)
+				for i, e := range batch {
+					x.setVector(e, vectors[i], id)
+					x.dirty[e.Path] = true
+				}
+				x.mu.Unlock()
+				x.progress.update(false, func(p *Progress) { p.ChunksDone += len(batch) })
+			}
+		}()
+	}
+	for start := 0; start < len(pending) && embedCtx.Err() == nil; start += embedBatch {
+		select {
+		case batches <- pending[start:min(start+embedBatch, len(pending))]:
+		case <-embedCtx.Done():
+		}
+	}
+	close(batches)
+	wg.Wait()
+	return ctx.Err()
+}
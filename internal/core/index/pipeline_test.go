@@ -0,0 +1,83 @@
+package index
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+)
+
+// countingEmbedder 记录请求次数和每次请求的片段数
+type countingEmbedder struct {
+	calls atomic.Int32
+	max   atomic.Int32
+}
+
+func (c *countingEmbedder) Model() string { return "counting" }
+
+func (c *countingEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	c.calls.Add(1)
+	if n := int32(len(texts)); n > c.max.Load() {
+		c.max.Store(n)
+	}
+	vectors := make([][]float32, len(texts))
+	for i := range vectors {
+		vectors[i] = []float32{1, 0}
+	}
+	return vectors, nil
+}
+
+func TestRefreshProgress(t *testing.T) {
+	root := t.TempDir()
+	files := make(map[string]string)
+	for i := 0; i < 100; i++ {
+		files[fmt.Sprintf("pkg%d/f%d.go", i%7, i)] = fmt.Sprintf("package pkg\n\nfunc F%d() {}\n", i)
+	}
+	writeFiles(t, root, files)
+
+	x := New(root, "")
+	embedder := &countingEmbedder{}
+	x.SetEmbedder(embedder)
+	current, updates, cancel := x.Watch()
+	defer cancel()
+	if current.Running {
+		t.Fatalf("expected no refresh before start, got %+v", current)
+	}
+
+	if err := x.Refresh(context.Background()); err != nil {
+		t.Fatalf("failed to refresh: %v", err)
+	}
+	last := <-updates
+	if last.Running || last.Phase != PhaseDone || last.FilesDone != 100 || last.FilesTotal != 100 {
+		t.Errorf("expected final progress for 100 files, got %+v", last)
+	}
+	if last.ChunksDone != 100 || last.ChunksTotal != 100 {
+		t.Errorf("expected all chunks to be embedded, got %+v", last)
+	}
+	if calls := embedder.calls.Load(); calls != 2 || embedder.max.Load() != embedBatch {
+		t.Errorf("expected 2 batched embed calls of %d, got %d calls of up to %d", embedBatch, calls, embedder.max.Load())
+	}
+	if stats := x.Stats(); stats.Files != 100 {
+		t.Errorf("expected 100 indexed files, got %d", stats.Files)
+	}
+}
+
+func TestRefreshCanceled(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{"a.go": "package a\n"})
+	x := New(root, "")
+	x.Refresh(context.Background())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := x.Refresh(ctx); err == nil {
+		t.Fatal("expected canceled refresh to fail")
+	}
+	if p := x.Progress(); p.Running || p.Error == "" {
+		t.Errorf("expected error in final progress, got %+v", p)
+	}
+	// 取消的刷新不应移除已索引的文件
+	if stats := x.Stats(); stats.Files != 1 {
+		t.Errorf("expected indexed file to survive canceled refresh, got %d", stats.Files)
+	}
+}
@@ -0,0 +1,241 @@
+package index
+
+import (
+	"fmt"
+	"math"
+)
+
+// Quantization 是向量在内存和段文件中的存储精度，精度越低占用越小，相似度的误差越大
+type Quantization string
+
+const (
+	Float32 Quantization = "float32" // 不量化，每个分量 4 字节
+	Float16 Quantization = "float16" // 半精度浮点，每个分量 2 字节
+	Int8    Quantization = "int8"    // 按向量的最大绝对值线性缩放到 int8，每个分量 1 字节，另存 4 字节的缩放系数
+)
+
+// ParseQuantization 解析存储精度，空字符串表示 Float32
+func ParseQuantization(s string) (Quantization, error) {
+	switch q := Quantization(s); q {
+	case "":
+		return Float32, nil
+	case Float32, Float16, Int8:
+		return q, nil
+	default:
+		return "", fmt.Errorf("unsupported vector quantization: %s", s)
+	}
+}
+
+// code 返回段文件中的精度编号
+func (q Quantization) code() uint32 {
+	switch q {
+	case Float16:
+		return 1
+	case Int8:
+		return 2
+	default:
+		return 0
+	}
+}
+
+// quantizationCode 把段文件中的精度编号转换为存储精度
+func quantizationCode(code uint32) (Quantization, error) {
+	switch code {
+	case 0:
+		return Float32, nil
+	case 1:
+		return Float16, nil
+	case 2:
+		return Int8, nil
+	default:
+		return "", fmt.Errorf("unknown vector quantization %d", code)
+	}
+}
+
+// bytes 返回每个分量占用的字节数
+func (q Quantization) bytes() int {
+	switch q {
+	case Float16:
+		return 2
+	case Int8:
+		return 1
+	default:
+		return 4
+	}
+}
+
+// storedVector 是按存储精度编码的向量，data 为小端序的分量，Int8 时分量乘以 scale 为原值
+type storedVector struct {
+	q     Quantization
+	data  []byte
+	scale float32
+}
+
+// quantize 按存储精度编码向量
+func quantize(v []float32, q Quantization) *storedVector {
+	sv := &storedVector{q: q, data: make([]byte, len(v)*q.bytes())}
+	switch q {
+	case Float16:
+		for i, x := range v {
+			le.PutUint16(sv.data[2*i:], float16bits(x))
+		}
+	case Int8:
+		var maxAbs float32
+		for _, x := range v {
+			maxAbs = max(maxAbs, float32(math.Abs(float64(x))))
+		}
+		if maxAbs == 0 {
+			break
+		}
+		sv.scale = maxAbs / 127
+		for i, x := range v {
+			sv.data[i] = byte(int8(math.Round(float64(x / sv.scale))))
+		}
+	default:
+		for i, x := range v {
+			le.PutUint32(sv.data[4*i:], math.Float32bits(x))
+		}
+	}
+	return sv
+}
+
+// dim 返回向量的维度
+func (v *storedVector) dim() int {
+	return len(v.data) / v.q.bytes()
+}
+
+// at 返回第 i 个分量
+func (v *storedVector) at(i int) float32 {
+	switch v.q {
+	case Float16:
+		return float16to32(le.Uint16(v.data[2*i:]))
+	case Int8:
+		return float32(int8(v.data[i])) * v.scale
+	default:
+		return math.Float32frombits(le.Uint32(v.data[4*i:]))
+	}
+}
+
+// floats 解码为 float32 分量
+func (v *storedVector) floats() []float32 {
+	out := make([]float32, v.dim())
+	for i := range out {
+		out[i] = v.at(i)
+	}
+	return out
+}
+
+// size 返回编码后占用的字节数，Int8 包括缩放系数
+func (v *storedVector) size() int {
+	if v.q == Int8 {
+		return 4 + len(v.data)
+	}
+	return len(v.data)
+}
+
+// words 返回在段文件中占用的 4 字节字数，不足一个字的部分补零
+func (v *storedVector) words() int {
+	return (v.size() + 3) / 4
+}
+
+// encode 返回写入段文件的字节，长度为 4 的倍数
+func (v *storedVector) encode() []byte {
+	out := make([]byte, 0, v.words()*4)
+	if v.q == Int8 {
+		out = le.AppendUint32(out, math.Float32bits(v.scale))
+	}
+	out = append(out, v.data...)
+	return append(out, make([]byte, v.words()*4-len(out))...)
+}
+
+// decodeVector 从段文件的字节解码 dim 维的向量，b 的长度至少为编码后的字节数，返回的向量不引用 b
+func decodeVector(b []byte, q Quantization, dim int) *storedVector {
+	v := &storedVector{q: q}
+	if q == Int8 {
+		v.scale = math.Float32frombits(le.Uint32(b))
+		b = b[4:]
+	}
+	v.data = append([]byte(nil), b[:dim*q.bytes()]...)
+	return v
+}
+
+// encodedSize 返回 dim 维的向量按存储精度编码后的字节数
+func encodedSize(q Quantization, dim int) uint64 {
+	n := uint64(dim * q.bytes())
+	if q == Int8 {
+		n += 4
+	}
+	return n
+}
+
+// cosine 计算查询向量与存储的向量的余弦相似度，维度不同时返回 0
+func cosine(a []float32, b *storedVector) float64 {
+	if len(a) != b.dim() || len(a) == 0 {
+		return 0
+	}
+	var dot, na, nb float64
+	for i, x := range a {
+		y := float64(b.at(i))
+		dot += float64(x) * y
+		na += float64(x) * float64(x)
+		nb += y * y
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(na*nb)
+}
+
+// float16bits 把 float32 转换为 IEEE 754 半精度的位表示，就近舍入到偶数，超出范围时为无穷大
+func float16bits(f float32) uint16 {
+	b := math.Float32bits(f)
+	sign := uint16(b>>16) & 0x8000
+	exp := int(b>>23&0xff) - 127 + 15
+	mant := b & 0x7fffff
+	switch {
+	case b>>23&0xff == 0xff:
+		if mant != 0 {
+			return sign | 0x7e00
+		}
+		return sign | 0x7c00
+	case exp >= 0x1f:
+		return sign | 0x7c00
+	case exp <= 0:
+		// 非规格化数，值为 m * 2^-24
+		if exp < -10 {
+			return sign
+		}
+		mant |= 0x800000
+		shift := uint32(14 - exp)
+		half := uint16(mant >> shift)
+		rem, halfway := mant&(1<<shift-1), uint32(1)<<(shift-1)
+		if rem > halfway || (rem == halfway && half&1 == 1) {
+			half++
+		}
+		return sign | half
+	}
+	half := sign | uint16(exp)<<10 | uint16(mant>>13)
+	// 进位溢出到指数时结果仍然正确
+	if rem := mant & 0x1fff; rem > 0x1000 || (rem == 0x1000 && half&1 == 1) {
+		half++
+	}
+	return half
+}
+
+// float16to32 把 IEEE 754 半精度的位表示转换为 float32
+func float16to32(h uint16) float32 {
+	sign := uint32(h&0x8000) << 16
+	exp := uint32(h>>10) & 0x1f
+	mant := uint32(h & 0x3ff)
+	switch exp {
+	case 0x1f:
+		return math.Float32frombits(sign | 0x7f800000 | mant<<13)
+	case 0:
+		f := float32(mant) / (1 << 24)
+		if sign != 0 {
+			f = -f
+		}
+		return f
+	}
+	return math.Float32frombits(sign | (exp+112)<<23 | mant<<13)
+}
@@ -0,0 +1,75 @@
+package index
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+func TestQuantize(t *testing.T) {
+	v := []float32{0.5, -1.25, 0.001, 3, 0}
+	for _, q := range []Quantization{Float32, Float16, Int8} {
+		sv := quantize(v, q)
+		if sv.dim() != len(v) || len(sv.encode())%4 != 0 {
+			t.Fatalf("%s: unexpected dim %d or encoded length %d", q, sv.dim(), len(sv.encode()))
+		}
+		decoded := decodeVector(sv.encode(), q, len(v))
+		// 误差不超过各精度的量化步长
+		tolerance := map[Quantization]float64{Float32: 0, Float16: 0.002, Int8: 3.0 / 127}[q]
+		for i, x := range decoded.floats() {
+			if math.Abs(float64(x-v[i])) > tolerance {
+				t.Errorf("%s: component %d decoded as %v, want %v", q, i, x, v[i])
+			}
+		}
+		if sim := cosine(v, decoded); sim < 0.999 {
+			t.Errorf("%s: expected self similarity close to 1, got %v", q, sim)
+		}
+	}
+	if quantize(v, Int8).size() != 4+len(v) || quantize(v, Float16).size() != 2*len(v) {
+		t.Error("unexpected encoded sizes")
+	}
+
+	for _, f := range []float32{1, -2.5, 65504, 1.0 / (1 << 24), float32(math.Inf(1))} {
+		if got := float16to32(float16bits(f)); got != f {
+			t.Errorf("expected %v to round trip through float16, got %v", f, got)
+		}
+	}
+	if got := float16to32(float16bits(1e6)); !math.IsInf(float64(got), 1) {
+		t.Errorf("expected overflow to infinity, got %v", got)
+	}
+	if _, err := ParseQuantization("int4"); err == nil {
+		t.Error("expected unsupported quantization to be rejected")
+	}
+}
+
+func TestQuantizedIndex(t *testing.T) {
+	root, dir := t.TempDir(), t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"store.go": "package store\n\nfunc Save() {}\n",
+		"route.go": "package server\n\nfunc route() {}\n",
+	})
+	ctx := context.Background()
+
+	x := New(root, dir)
+	x.SetEmbedder(fakeEmbedder{})
+	x.Refresh(ctx)
+	full := x.Stats()
+
+	// 降低精度时已有的向量重新编码并写入段文件
+	x.SetQuantization(Int8)
+	x.Refresh(ctx)
+	stats := x.Stats()
+	if stats.Quantization != Int8 || stats.VectorBytes >= full.VectorBytes || stats.Vectors["fake@2"] != 2 {
+		t.Fatalf("expected smaller int8 vectors, got %+v (float32 %d bytes)", stats, full.VectorBytes)
+	}
+
+	loaded := New(root, dir)
+	loaded.SetEmbedder(fakeEmbedder{})
+	if got := loaded.Stats(); got.VectorBytes != stats.VectorBytes || got.Vectors["fake@2"] != 2 {
+		t.Fatalf("expected quantized vectors to persist, got %+v", got)
+	}
+	results, err := loaded.Search(ctx, &Query{Text: "persist data"})
+	if err != nil || len(results) == 0 || results[0].Path != "store.go" || results[0].Vector < 0.99 {
+		t.Errorf("expected vector match from quantized vectors, got %+v (%v)", results, err)
+	}
+}
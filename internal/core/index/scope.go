@@ -0,0 +1,269 @@
+package index
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/liangsj/vimcoplit/internal/core/langdetect"
+)
+
+// binarySniffSize 是判断二进制文件时检查的字节数
+const binarySniffSize = 8000
+
+// 文件不参与索引的原因
+const (
+	ReasonHidden      = "hidden"       // 隐藏目录或默认跳过的目录，如 node_modules
+	ReasonIgnored     = "ignored"      // 匹配工作区的忽略规则
+	ReasonExcluded    = "excluded"     // 匹配索引范围的排除规则
+	ReasonNotIncluded = "not_included" // 设置了包含规则但不匹配
+	ReasonExtension   = "extension"    // 没有设置包含规则时，不是源码文件
+	ReasonTooLarge    = "too_large"
+	ReasonBinary      = "binary"
+)
+
+// Scope 是工作区的索引范围。规则是相对仓库根目录的 glob：** 匹配任意层目录，
+// 不含 / 的规则匹配任意层级的文件名，以 / 结尾的规则匹配目录及其中的所有文件
+type Scope struct {
+	Include     []string `json:"include,omitempty"`       // 为空时索引所有源码文件，否则只索引匹配的文件
+	Exclude     []string `json:"exclude,omitempty"`       // 优先于包含规则
+	MaxFileSize int64    `json:"max_file_size,omitempty"` // 参与索引的文件的最大字节数，0 表示使用默认值
+}
+
+// Validate 检查规则是否有效
+func (s *Scope) Validate() error {
+	for _, pattern := range append(append([]string(nil), s.Include...), s.Exclude...) {
+		for _, segment := range strings.Split(strings.TrimSuffix(pattern, "/"), "/") {
+			if _, err := path.Match(segment, ""); err != nil {
+				return fmt.Errorf("invalid index pattern %q: %v", pattern, err)
+			}
+		}
+	}
+	if s.MaxFileSize < 0 {
+		return fmt.Errorf("max_file_size must not be negative")
+	}
+	return nil
+}
+
+// Copy 复制索引范围，避免调用方修改共享的切片
+func (s Scope) Copy() Scope {
+	s.Include = append([]string(nil), s.Include...)
+	s.Exclude = append([]string(nil), s.Exclude...)
+	return s
+}
+
+// maxSize 返回参与索引的文件的最大字节数
+func (s *Scope) maxSize() int64 {
+	if s.MaxFileSize > 0 {
+		return s.MaxFileSize
+	}
+	return maxFileSize
+}
+
+// FileStatus 是文件的索引状态，Reason 为不参与索引的原因
+type FileStatus struct {
+	Path     string `json:"path"`
+	Dir      bool   `json:"dir,omitempty"` // 整个目录被跳过
+	Size     int64  `json:"size,omitempty"`
+	Included bool   `json:"included"`
+	Reason   string `json:"reason,omitempty"`
+	Chunks   int    `json:"chunks,omitempty"` // 当前索引中的片段数，尚未刷新时为 0
+}
+
+// SetScope 设置索引范围，下次刷新时加入新纳入范围的文件并移除范围以外的文件
+func (x *Index) SetScope(scope Scope) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.scope = scope.Copy()
+}
+
+// Files 返回参与索引的文件，all 为 true 时同时返回不参与索引的文件和被跳过的目录及其原因
+func (x *Index) Files(ctx context.Context, all bool) ([]FileStatus, error) {
+	x.mu.RLock()
+	ignore, scope := x.ignore, x.scope
+	x.mu.RUnlock()
+
+	files := []FileStatus{}
+	err := walk(ctx, x.root, ignore, scope, func(rel, abs string, info fs.FileInfo, reason string) {
+		dir := info.IsDir()
+		if reason == "" && sniffBinary(abs) {
+			reason = ReasonBinary
+		}
+		if reason != "" && !all {
+			return
+		}
+		status := FileStatus{Path: rel, Dir: dir, Included: reason == "", Reason: reason}
+		if !dir {
+			status.Size = info.Size()
+		}
+		files = append(files, status)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	for i, status := range files {
+		if f, ok := x.files[status.Path]; ok {
+			files[i].Chunks = len(f.chunks)
+		}
+	}
+	return files, nil
+}
+
+// walk 按索引范围遍历仓库，对每个文件和被跳过的目录调用 fn，reason 为空表示文件参与索引。
+// 二进制文件需要读取内容才能判断，由调用方检查
+func walk(ctx context.Context, root string, ignore func(rel string) bool, scope Scope, fn func(rel, abs string, info fs.FileInfo, reason string)) error {
+	limit := scope.maxSize()
+	return filepath.WalkDir(root, func(abs string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if abs == root {
+			return nil
+		}
+		rel, _ := filepath.Rel(root, abs)
+		rel = filepath.ToSlash(rel)
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+
+		if d.IsDir() {
+			reason := ""
+			switch {
+			case ignore != nil && ignore(rel):
+				reason = ReasonIgnored
+			case matchAny(scope.Exclude, rel):
+				reason = ReasonExcluded
+			case (strings.HasPrefix(d.Name(), ".") || skipDirs[d.Name()]) && !includesUnder(scope.Include, rel):
+				reason = ReasonHidden
+			}
+			if reason != "" {
+				fn(rel, abs, info, reason)
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		reason := ""
+		switch {
+		case !d.Type().IsRegular():
+			return nil
+		case ignore != nil && ignore(rel):
+			reason = ReasonIgnored
+		case matchAny(scope.Exclude, rel):
+			reason = ReasonExcluded
+		case len(scope.Include) > 0 && !matchAny(scope.Include, rel):
+			reason = ReasonNotIncluded
+		case len(scope.Include) == 0 && !isSource(rel, abs):
+			reason = ReasonExtension
+		case info.Size() > limit:
+			reason = ReasonTooLarge
+		}
+		fn(rel, abs, info, reason)
+		return nil
+	})
+}
+
+// isSource 判断文件是否为源码：扩展名是源码扩展名，或没有扩展名但能按文件名或内容识别语言，
+// 如 Makefile、Dockerfile 和带 shebang 的脚本
+func isSource(rel, abs string) bool {
+	if ext := filepath.Ext(rel); ext != "" {
+		return sourceExts[ext]
+	}
+	lang, err := langdetect.DetectFile(abs)
+	return err == nil && lang != ""
+}
+
+// matchAny 判断路径是否匹配任意一条规则
+func matchAny(patterns []string, rel string) bool {
+	for _, pattern := range patterns {
+		if matchGlob(pattern, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// includesUnder 判断包含规则是否明确指向目录之下，这样的目录即使默认跳过也要进入
+func includesUnder(patterns []string, dir string) bool {
+	for _, pattern := range patterns {
+		if strings.HasPrefix(pattern, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// matchGlob 判断相对路径是否匹配规则
+func matchGlob(pattern, rel string) bool {
+	dir, isDir := strings.CutSuffix(pattern, "/")
+	if !strings.Contains(dir, "/") {
+		dir = "**/" + dir
+	}
+	if isDir {
+		dir += "/**"
+	}
+	return matchSegments(strings.Split(dir, "/"), strings.Split(rel, "/"))
+}
+
+// matchSegments 逐段匹配，** 匹配零个或多个目录
+func matchSegments(pattern, parts []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(parts); i++ {
+				if matchSegments(pattern[1:], parts[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(parts) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], parts[0]); !ok {
+			return false
+		}
+		pattern, parts = pattern[1:], parts[1:]
+	}
+	return len(parts) == 0
+}
+
+// isBinary 判断内容是否是二进制：开头部分包含 NUL 或不是有效的 UTF-8
+func isBinary(data []byte) bool {
+	sample := data[:min(len(data), binarySniffSize)]
+	if strings.IndexByte(string(sample), 0) >= 0 {
+		return true
+	}
+	// 截断处可能正好落在多字节字符中间
+	for i := 0; i < utf8.UTFMax-1 && len(sample) > 0 && !utf8.Valid(sample); i++ {
+		sample = sample[:len(sample)-1]
+	}
+	return !utf8.Valid(sample)
+}
+
+// sniffBinary 读取文件开头判断是否是二进制文件，无法读取的文件按二进制处理
+func sniffBinary(abs string) bool {
+	f, err := os.Open(abs)
+	if err != nil {
+		return true
+	}
+	defer f.Close()
+	buf := make([]byte, binarySniffSize)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return true
+	}
+	return isBinary(buf[:n])
+}
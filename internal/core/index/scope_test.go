@@ -0,0 +1,155 @@
+package index
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern, path string
+		want          bool
+	}{
+		{"*.pb.go", "api/v1/service.pb.go", true},
+		{"*.pb.go", "api/v1/service.go", false},
+		{"internal/**", "internal/core/index/index.go", true},
+		{"internal/**/*.go", "internal/index.go", true},
+		{"internal/**/*.go", "cmd/main.go", false},
+		{"docs/*.md", "docs/guide/intro.md", false},
+		{"testdata/", "pkg/testdata/input.txt", true},
+		{"testdata/", "pkg/testdata", true},
+		{"legacy/old/", "legacy/old/a.go", true},
+		{"legacy/old/", "src/legacy/old/a.go", false},
+	}
+	for _, tt := range tests {
+		if got := matchGlob(tt.pattern, tt.path); got != tt.want {
+			t.Errorf("matchGlob(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestIsBinary(t *testing.T) {
+	if isBinary([]byte("package main\n\n// 中文注释\n")) {
+		t.Error("expected UTF-8 source to be text")
+	}
+	if !isBinary([]byte("PK\x03\x04\x00\x00")) {
+		t.Error("expected NUL bytes to be binary")
+	}
+	if !isBinary([]byte{0xff, 0xfe, 'a', 0xff, 'b'}) {
+		t.Error("expected invalid UTF-8 to be binary")
+	}
+	// 截断处落在多字节字符中间不算二进制
+	text := []byte("中文")
+	if isBinary(text[:len(text)-1]) {
+		t.Error("expected truncated multi-byte character to be text")
+	}
+}
+
+func TestScope(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"src/app.go":          "package src\n\nfunc App() {}\n",
+		"src/gen/api.pb.go":   "package gen\n\nfunc Generated() {}\n",
+		"src/big.go":          "package src\n\n// long comment that makes the file too large\n",
+		"src/blob.go":         "\x00\x01binary",
+		"docs/notes.txt":      "design notes\n",
+		"vendor/lib/lib.go":   "package lib\n\nfunc Lib() {}\n",
+		"node_modules/x/x.js": "module.exports = 1\n",
+		"scripts/run.go":      "package main\n",
+	})
+
+	x := New(root, "")
+	x.SetIgnore(func(rel string) bool { return rel == "scripts" })
+	x.SetScope(Scope{
+		Include:     []string{"src/**", "docs/*.txt", "vendor/lib/**"},
+		Exclude:     []string{"*.pb.go"},
+		MaxFileSize: 40,
+	})
+	if err := x.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := x.Files(context.Background(), true)
+	if err != nil {
+		t.Fatalf("failed to list files: %v", err)
+	}
+	got := make(map[string]string)
+	for _, f := range files {
+		got[f.Path] = f.Reason
+		if f.Included && f.Chunks == 0 {
+			t.Errorf("expected included file %s to be indexed", f.Path)
+		}
+	}
+	want := map[string]string{
+		"src/app.go":        "",
+		"docs/notes.txt":    "",
+		"vendor/lib/lib.go": "",
+		"src/gen/api.pb.go": ReasonExcluded,
+		"src/big.go":        ReasonTooLarge,
+		"src/blob.go":       ReasonBinary,
+		"node_modules":      ReasonHidden,
+		"scripts":           ReasonIgnored,
+	}
+	for path, reason := range want {
+		if r, ok := got[path]; !ok || r != reason {
+			t.Errorf("expected %s to have reason %q, got %q (listed: %v)", path, reason, r, ok)
+		}
+	}
+
+	included, _ := x.Files(context.Background(), false)
+	if len(included) != 3 {
+		t.Errorf("expected 3 included files, got %+v", included)
+	}
+
+	// 缩小范围后刷新，范围以外的文件移出索引
+	x.SetScope(Scope{Include: []string{"docs/"}})
+	x.Refresh(context.Background())
+	if stats := x.Stats(); stats.Files != 1 {
+		t.Errorf("expected only docs to stay indexed, got %d files", stats.Files)
+	}
+}
+
+func TestScopeDetectsLanguage(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"bin/deploy": "#!/usr/bin/env bash\nset -e\necho deploy\n",
+		"Makefile":   "build:\n\tgo build ./...\n",
+		"LICENSE":    "Permission is hereby granted\n",
+		"notes.txt":  "#!/bin/sh\n",
+	})
+	x := New(root, "")
+	if err := x.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	files, err := x.Files(context.Background(), true)
+	if err != nil {
+		t.Fatalf("failed to list files: %v", err)
+	}
+	got := make(map[string]string)
+	for _, f := range files {
+		got[f.Path] = f.Reason
+	}
+	want := map[string]string{
+		"bin/deploy": "",
+		"Makefile":   "",
+		"LICENSE":    ReasonExtension,
+		"notes.txt":  ReasonExtension,
+	}
+	for path, reason := range want {
+		if r, ok := got[path]; !ok || r != reason {
+			t.Errorf("expected %s to have reason %q, got %q (listed: %v)", path, reason, r, ok)
+		}
+	}
+}
+
+func TestScopeValidate(t *testing.T) {
+	if err := (&Scope{Include: []string{"src/[a-"}}).Validate(); err == nil {
+		t.Error("expected invalid pattern to be rejected")
+	}
+	if err := (&Scope{MaxFileSize: -1}).Validate(); err == nil {
+		t.Error("expected negative max file size to be rejected")
+	}
+	if err := (&Scope{Include: []string{"**/*.go", "docs/"}}).Validate(); err != nil {
+		t.Errorf("expected valid scope, got %v", err)
+	}
+}
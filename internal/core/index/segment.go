@@ -0,0 +1,409 @@
+package index
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// FormatVersion 是段文件格式的版本，格式不兼容时递增，旧版本的索引会被丢弃并重新建立
+const FormatVersion = 3
+
+// 段文件布局（小端序）：
+//
+//	header   128 字节：magic、版本、各区的记录数、偏移和 blob 长度
+//	files    每个文件 40 字节：路径在 blob 中的偏移和长度、标志、修改时间、大小、第一个片段和片段数
+//	chunks   每个片段 40 字节：内容在 blob 中的偏移和长度、起止行号、词数、第一个词频和词频数、
+//	         向量空间序号加 1（0 表示没有向量）和向量的起始位置
+//	terms    每个词 16 字节：词在 blob 中的偏移和长度
+//	postings 每个词频 8 字节：段内的词序号和词频
+//	spaces   每个向量空间 24 字节：模型名在 blob 中的偏移和长度、维度和存储精度，
+//	         同一模型和维度的向量按不同精度存储时各占一条记录
+//	vectors  有向量的片段依次写入按所在空间的精度编码的向量，每个向量补齐到 4 字节，
+//	         片段记录中的向量起始位置以 4 字节为单位
+//	blob     路径、片段内容、词和模型名的字节
+//
+// 记录都是定长且按 8 字节对齐，文件映射到内存后可以直接按序号访问
+const (
+	segmentMagic    = "VCIDXSEG"
+	headerSize      = 128
+	fileRecordSize  = 40
+	chunkRecordSize = 40
+	termRecordSize  = 16
+	postingSize     = 8
+	spaceRecordSize = 24
+
+	// flagDeleted 表示文件已删除，加载时从索引中移除之前段中的记录
+	flagDeleted = 1
+)
+
+var le = binary.LittleEndian
+
+// segmentFile 是段文件中的一个文件，file 为 nil 表示文件已删除
+type segmentFile struct {
+	path string
+	file *file
+}
+
+// writeSegment 把文件写入段文件，termNames 是词 ID 到词的映射，spaces 是向量空间 ID 到向量空间的映射，
+// 返回写入的字节数
+func writeSegment(path string, files []segmentFile, termNames []string, spaces []space) (int64, error) {
+	// 第一遍统计各区大小，建立段内的词表和向量空间表
+	local := make(map[uint32]uint32)
+	localSpaces := make(map[spaceKey]uint32)
+	var terms []string
+	var segSpaces []spaceKey
+	var chunks, postings, words int
+	var pathsLen, contentsLen uint64
+	for _, sf := range files {
+		pathsLen += uint64(len(sf.path))
+		if sf.file == nil {
+			continue
+		}
+		for _, e := range sf.file.chunks {
+			chunks++
+			postings += len(e.terms)
+			contentsLen += uint64(len(e.Content))
+			if e.vector != nil {
+				words += e.vector.words()
+				key := spaceKey{e.space, e.vector.q}
+				if _, ok := localSpaces[key]; !ok {
+					localSpaces[key] = uint32(len(segSpaces))
+					segSpaces = append(segSpaces, key)
+				}
+			}
+			for _, p := range e.terms {
+				if _, ok := local[p.term]; !ok {
+					local[p.term] = uint32(len(terms))
+					terms = append(terms, termNames[p.term])
+				}
+			}
+		}
+	}
+	var termsLen, modelsLen uint64
+	for _, t := range terms {
+		termsLen += uint64(len(t))
+	}
+	for _, key := range segSpaces {
+		modelsLen += uint64(len(spaces[key.space].model))
+	}
+
+	filesOff := uint64(headerSize)
+	chunksOff := filesOff + uint64(len(files))*fileRecordSize
+	termsOff := chunksOff + uint64(chunks)*chunkRecordSize
+	postingsOff := termsOff + uint64(len(terms))*termRecordSize
+	spacesOff := postingsOff + uint64(postings)*postingSize
+	vectorsOff := spacesOff + uint64(len(segSpaces))*spaceRecordSize
+	blobOff := align8(vectorsOff + uint64(words)*4)
+	blobLen := pathsLen + contentsLen + termsLen + modelsLen
+
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp)
+	defer f.Close()
+	w := bufio.NewWriterSize(f, 1<<16)
+
+	header := make([]byte, headerSize)
+	copy(header, segmentMagic)
+	le.PutUint32(header[8:], FormatVersion)
+	le.PutUint32(header[12:], uint32(len(segSpaces)))
+	le.PutUint32(header[16:], uint32(len(files)))
+	le.PutUint32(header[20:], uint32(chunks))
+	le.PutUint32(header[24:], uint32(len(terms)))
+	le.PutUint32(header[28:], uint32(postings))
+	for i, off := range []uint64{filesOff, chunksOff, termsOff, postingsOff, spacesOff, vectorsOff, blobOff, blobLen} {
+		le.PutUint64(header[32+8*i:], off)
+	}
+	w.Write(header)
+
+	record := make([]byte, fileRecordSize)
+	var pathOff uint64
+	chunkIndex := uint32(0)
+	for _, sf := range files {
+		clear(record)
+		le.PutUint64(record[0:], pathOff)
+		le.PutUint32(record[8:], uint32(len(sf.path)))
+		if sf.file == nil {
+			le.PutUint32(record[12:], flagDeleted)
+		} else {
+			le.PutUint64(record[16:], uint64(sf.file.modTime.UnixNano()))
+			le.PutUint64(record[24:], uint64(sf.file.size))
+			le.PutUint32(record[32:], chunkIndex)
+			le.PutUint32(record[36:], uint32(len(sf.file.chunks)))
+			chunkIndex += uint32(len(sf.file.chunks))
+		}
+		w.Write(record)
+		pathOff += uint64(len(sf.path))
+	}
+
+	record = record[:chunkRecordSize]
+	contentOff := pathsLen
+	postingIndex := uint32(0)
+	vectorIndex := uint32(0)
+	eachChunk(files, func(e *entry) {
+		clear(record)
+		le.PutUint64(record[0:], contentOff)
+		le.PutUint32(record[8:], uint32(len(e.Content)))
+		le.PutUint32(record[12:], uint32(e.StartLine))
+		le.PutUint32(record[16:], uint32(e.EndLine))
+		le.PutUint32(record[20:], uint32(e.length))
+		le.PutUint32(record[24:], postingIndex)
+		le.PutUint32(record[28:], uint32(len(e.terms)))
+		if e.vector != nil {
+			le.PutUint32(record[32:], localSpaces[spaceKey{e.space, e.vector.q}]+1)
+			le.PutUint32(record[36:], vectorIndex)
+			vectorIndex += uint32(e.vector.words())
+		}
+		w.Write(record)
+		contentOff += uint64(len(e.Content))
+		postingIndex += uint32(len(e.terms))
+	})
+
+	record = record[:termRecordSize]
+	termOff := pathsLen + contentsLen
+	for _, t := range terms {
+		clear(record)
+		le.PutUint64(record[0:], termOff)
+		le.PutUint32(record[8:], uint32(len(t)))
+		w.Write(record)
+		termOff += uint64(len(t))
+	}
+
+	record = record[:postingSize]
+	eachChunk(files, func(e *entry) {
+		for _, p := range e.terms {
+			le.PutUint32(record[0:], local[p.term])
+			le.PutUint32(record[4:], p.freq)
+			w.Write(record)
+		}
+	})
+
+	record = record[:spaceRecordSize]
+	modelOff := pathsLen + contentsLen + termsLen
+	for _, key := range segSpaces {
+		sp := spaces[key.space]
+		clear(record)
+		le.PutUint64(record[0:], modelOff)
+		le.PutUint32(record[8:], uint32(len(sp.model)))
+		le.PutUint32(record[12:], uint32(sp.dim))
+		le.PutUint32(record[16:], key.q.code())
+		w.Write(record)
+		modelOff += uint64(len(sp.model))
+	}
+
+	eachChunk(files, func(e *entry) {
+		if e.vector != nil {
+			w.Write(e.vector.encode())
+		}
+	})
+	w.Write(make([]byte, blobOff-(vectorsOff+uint64(words)*4)))
+
+	for _, sf := range files {
+		w.WriteString(sf.path)
+	}
+	eachChunk(files, func(e *entry) { w.WriteString(e.Content) })
+	for _, t := range terms {
+		w.WriteString(t)
+	}
+	for _, key := range segSpaces {
+		w.WriteString(spaces[key.space].model)
+	}
+
+	if err := w.Flush(); err != nil {
+		return 0, err
+	}
+	if err := f.Close(); err != nil {
+		return 0, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return 0, err
+	}
+	return int64(blobOff + blobLen), nil
+}
+
+// spaceKey 是段内的向量空间记录：同一向量空间中按不同精度存储的向量分别记录
+type spaceKey struct {
+	space uint32
+	q     Quantization
+}
+
+// eachChunk 按写入顺序遍历段中的片段
+func eachChunk(files []segmentFile, fn func(e *entry)) {
+	for _, sf := range files {
+		if sf.file == nil {
+			continue
+		}
+		for _, e := range sf.file.chunks {
+			fn(e)
+		}
+	}
+}
+
+// align8 把偏移向上对齐到 8 字节
+func align8(n uint64) uint64 {
+	return (n + 7) &^ 7
+}
+
+// readSegment 解析段文件，intern 把词转换为索引的词 ID，internSpace 把向量空间转换为索引的向量空间 ID。
+// 返回的文件和片段不引用 data，调用方可以在返回后解除映射
+func readSegment(data []byte, intern func(term string) uint32, internSpace func(sp space) uint32) ([]segmentFile, error) {
+	if len(data) < headerSize || string(data[:len(segmentMagic)]) != segmentMagic {
+		return nil, errors.New("not an index segment")
+	}
+	if version := le.Uint32(data[8:]); version != FormatVersion {
+		return nil, fmt.Errorf("segment format version %d is not supported", version)
+	}
+	spaceCount := uint64(le.Uint32(data[12:]))
+	fileCount := uint64(le.Uint32(data[16:]))
+	chunkCount := uint64(le.Uint32(data[20:]))
+	termCount := uint64(le.Uint32(data[24:]))
+	postingCount := uint64(le.Uint32(data[28:]))
+	var offs [8]uint64
+	for i := range offs {
+		offs[i] = le.Uint64(data[32+8*i:])
+	}
+	filesOff, chunksOff, termsOff, postingsOff, spacesOff, vectorsOff, blobOff, blobLen := offs[0], offs[1], offs[2], offs[3], offs[4], offs[5], offs[6], offs[7]
+
+	size := uint64(len(data))
+	if vectorsOff > blobOff {
+		return nil, errors.New("segment is truncated")
+	}
+	for _, section := range [][2]uint64{
+		{filesOff, fileCount * fileRecordSize},
+		{chunksOff, chunkCount * chunkRecordSize},
+		{termsOff, termCount * termRecordSize},
+		{postingsOff, postingCount * postingSize},
+		{spacesOff, spaceCount * spaceRecordSize},
+		{vectorsOff, blobOff - vectorsOff},
+		{blobOff, blobLen},
+	} {
+		if section[0] > size || section[1] > size-section[0] {
+			return nil, errors.New("segment is truncated")
+		}
+	}
+	blob := data[blobOff : blobOff+blobLen]
+	str := func(off uint64, n uint32) (string, error) {
+		if off > uint64(len(blob)) || uint64(n) > uint64(len(blob))-off {
+			return "", errors.New("string is out of range")
+		}
+		return string(blob[off : off+uint64(n)]), nil
+	}
+
+	ids := make([]uint32, termCount)
+	for i := range ids {
+		record := data[termsOff+uint64(i)*termRecordSize:]
+		term, err := str(le.Uint64(record), le.Uint32(record[8:]))
+		if err != nil {
+			return nil, err
+		}
+		ids[i] = intern(term)
+	}
+
+	spaces := make([]segmentSpace, spaceCount)
+	for i := range spaces {
+		record := data[spacesOff+uint64(i)*spaceRecordSize:]
+		model, err := str(le.Uint64(record), le.Uint32(record[8:]))
+		if err != nil {
+			return nil, err
+		}
+		q, err := quantizationCode(le.Uint32(record[16:]))
+		if err != nil {
+			return nil, err
+		}
+		sp := space{model: model, dim: int(le.Uint32(record[12:]))}
+		spaces[i] = segmentSpace{id: internSpace(sp), dim: sp.dim, q: q}
+	}
+	vectors := data[vectorsOff:blobOff]
+
+	files := make([]segmentFile, fileCount)
+	for i := range files {
+		record := data[filesOff+uint64(i)*fileRecordSize:]
+		path, err := str(le.Uint64(record), le.Uint32(record[8:]))
+		if err != nil {
+			return nil, err
+		}
+		files[i].path = path
+		if le.Uint32(record[12:])&flagDeleted != 0 {
+			continue
+		}
+		f := &file{
+			modTime: time.Unix(0, int64(le.Uint64(record[16:]))),
+			size:    int64(le.Uint64(record[24:])),
+		}
+		first, count := uint64(le.Uint32(record[32:])), uint64(le.Uint32(record[36:]))
+		if first+count > chunkCount {
+			return nil, errors.New("chunk is out of range")
+		}
+		for c := first; c < first+count; c++ {
+			e, err := readChunk(data, blob, vectors, c, chunksOff, postingsOff, postingCount, ids, spaces)
+			if err != nil {
+				return nil, err
+			}
+			e.Path = path
+			f.chunks = append(f.chunks, e)
+		}
+		files[i].file = f
+	}
+	return files, nil
+}
+
+// segmentSpace 是段内的向量空间，id 为索引的向量空间 ID，q 为段内向量的存储精度
+type segmentSpace struct {
+	id  uint32
+	dim int
+	q   Quantization
+}
+
+// readChunk 解析第 c 个片段的记录、词频和向量
+func readChunk(data, blob, vectors []byte, c, chunksOff, postingsOff, postingCount uint64, ids []uint32, spaces []segmentSpace) (*entry, error) {
+	record := data[chunksOff+c*chunkRecordSize:]
+	contentOff, contentLen := le.Uint64(record), uint64(le.Uint32(record[8:]))
+	if contentOff > uint64(len(blob)) || contentLen > uint64(len(blob))-contentOff {
+		return nil, errors.New("content is out of range")
+	}
+	e := &entry{
+		Chunk: Chunk{
+			StartLine: int(le.Uint32(record[12:])),
+			EndLine:   int(le.Uint32(record[16:])),
+			Content:   string(blob[contentOff : contentOff+contentLen]),
+		},
+		length: int(le.Uint32(record[20:])),
+	}
+
+	first, count := uint64(le.Uint32(record[24:])), uint64(le.Uint32(record[28:]))
+	if first+count > postingCount {
+		return nil, errors.New("posting is out of range")
+	}
+	e.terms = make([]posting, count)
+	for i := range e.terms {
+		p := data[postingsOff+(first+uint64(i))*postingSize:]
+		local := le.Uint32(p)
+		if uint64(local) >= uint64(len(ids)) {
+			return nil, errors.New("term is out of range")
+		}
+		e.terms[i] = posting{term: ids[local], freq: le.Uint32(p[4:])}
+	}
+	// 段内词序号与索引的词 ID 顺序不同，需要重新排序
+	sort.Slice(e.terms, func(i, j int) bool { return e.terms[i].term < e.terms[j].term })
+
+	if local := uint64(le.Uint32(record[32:])); local > 0 {
+		if local > uint64(len(spaces)) {
+			return nil, errors.New("vector space is out of range")
+		}
+		sp := spaces[local-1]
+		off := uint64(le.Uint32(record[36:])) * 4
+		if off > uint64(len(vectors)) || encodedSize(sp.q, sp.dim) > uint64(len(vectors))-off {
+			return nil, errors.New("vector is out of range")
+		}
+		e.vector = decodeVector(vectors[off:], sp.q, sp.dim)
+		e.space = sp.id
+	}
+	return e, nil
+}
@@ -0,0 +1,293 @@
+package index
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// manifestName 是记录段文件列表的清单文件名
+	manifestName = "manifest.json"
+
+	// segmentExt 是段文件的扩展名
+	segmentExt = ".seg"
+
+	// maxSegments 是触发合并的段文件数量，每次刷新有修改时写入一个增量段
+	maxSegments = 8
+)
+
+// segmentInfo 是清单中的一个段文件
+type segmentInfo struct {
+	Name      string    `json:"name"`
+	Files     int       `json:"files"`
+	Chunks    int       `json:"chunks"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// manifest 记录索引的格式版本和按写入顺序排列的段文件，后写入的段覆盖之前段中同一文件的记录
+type manifest struct {
+	Version   int           `json:"version"`
+	Next      int           `json:"next"` // 下一个段文件的序号
+	Segments  []segmentInfo `json:"segments"`
+	UpdatedAt time.Time     `json:"updated_at"`
+}
+
+// Stats 是索引的统计信息
+type Stats struct {
+	Version   int       `json:"version"`
+	Files     int       `json:"files"`
+	Chunks    int       `json:"chunks"`
+	Terms     int       `json:"terms"`
+	Segments  int       `json:"segments"`
+	DiskBytes int64     `json:"disk_bytes"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// Vectors 是各向量空间（模型@维度）中的片段数，切换模型后旧空间的片段在被检索命中时重新计算
+	Vectors map[string]int `json:"vectors,omitempty"`
+	// Quantization 是新向量的存储精度，VectorBytes 是内存中向量编码后的总字节数
+	Quantization Quantization `json:"quantization"`
+	VectorBytes  int64        `json:"vector_bytes"`
+}
+
+// Stats 返回索引的统计信息
+func (x *Index) Stats() Stats {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	stats := Stats{
+		Version:      FormatVersion,
+		Files:        len(x.files),
+		Chunks:       x.chunks,
+		Segments:     len(x.manifest.Segments),
+		UpdatedAt:    x.manifest.UpdatedAt,
+		Quantization: x.quantization,
+		VectorBytes:  x.vectorBytes,
+	}
+	for _, df := range x.docFreq {
+		if df > 0 {
+			stats.Terms++
+		}
+	}
+	for _, seg := range x.manifest.Segments {
+		stats.DiskBytes += seg.Size
+	}
+	for id, n := range x.spaceVectors {
+		if n > 0 {
+			if stats.Vectors == nil {
+				stats.Vectors = make(map[string]int)
+			}
+			stats.Vectors[x.spaces[id].String()] = n
+		}
+	}
+	return stats
+}
+
+// Rebuild 丢弃已有的索引，重新扫描整个仓库并写入一个段文件
+func (x *Index) Rebuild(ctx context.Context) error {
+	x.refreshMu.Lock()
+	defer x.refreshMu.Unlock()
+
+	x.mu.Lock()
+	x.reset()
+	x.mu.Unlock()
+	if err := x.refresh(ctx); err != nil {
+		return err
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return x.flush()
+}
+
+// load 按清单顺序加载段文件，调用方需持有锁或在创建时调用
+func (x *Index) load() error {
+	data, err := os.ReadFile(filepath.Join(x.dir, manifestName))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return fmt.Errorf("invalid manifest: %v", err)
+	}
+	if m.Version != FormatVersion {
+		return fmt.Errorf("index format version %d is not supported", m.Version)
+	}
+	for _, seg := range m.Segments {
+		if err := x.loadSegment(filepath.Join(x.dir, seg.Name)); err != nil {
+			return fmt.Errorf("failed to load segment %s: %v", seg.Name, err)
+		}
+	}
+	x.manifest = m
+	return nil
+}
+
+// loadSegment 映射并加载一个段文件，调用方需持有锁
+func (x *Index) loadSegment(path string) error {
+	data, unmap, err := mapFile(path)
+	if err != nil {
+		return err
+	}
+	defer unmap()
+
+	files, err := readSegment(data, x.termID, x.spaceID)
+	if err != nil {
+		return err
+	}
+	for _, sf := range files {
+		x.remove(sf.path)
+		if sf.file != nil {
+			x.add(sf.path, sf.file)
+		}
+	}
+	return nil
+}
+
+// flush 把修改过的文件写入增量段，段文件过多或过期记录超过有效记录时合并为一个段，调用方需持有锁
+func (x *Index) flush() error {
+	if x.dir == "" {
+		clear(x.dirty)
+		return nil
+	}
+	if len(x.dirty) == 0 {
+		return nil
+	}
+	recorded := len(x.dirty)
+	for _, seg := range x.manifest.Segments {
+		recorded += seg.Files
+	}
+	if len(x.manifest.Segments) >= maxSegments || recorded > 2*len(x.files) {
+		return x.compact()
+	}
+
+	paths := make([]string, 0, len(x.dirty))
+	for path := range x.dirty {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	files := make([]segmentFile, len(paths))
+	for i, path := range paths {
+		files[i] = segmentFile{path: path, file: x.files[path]}
+	}
+	return x.writeSegments(files, false)
+}
+
+// compact 把全部文件写入一个新的段并删除旧的段文件，同时清理词典中不再出现的词，调用方需持有锁
+func (x *Index) compact() error {
+	paths := make([]string, 0, len(x.files))
+	for path := range x.files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	files := make([]segmentFile, len(paths))
+	for i, path := range paths {
+		files[i] = segmentFile{path: path, file: x.files[path]}
+	}
+	if err := x.writeSegments(files, true); err != nil {
+		return err
+	}
+	x.compactTerms()
+	return nil
+}
+
+// writeSegments 写入一个段文件并更新清单，replace 为 true 时新段替换所有旧段，调用方需持有锁
+func (x *Index) writeSegments(files []segmentFile, replace bool) error {
+	if err := os.MkdirAll(x.dir, 0755); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%08d%s", x.manifest.Next, segmentExt)
+	size, err := writeSegment(filepath.Join(x.dir, name), files, x.termNames, x.spaces)
+	if err != nil {
+		return fmt.Errorf("failed to write segment: %v", err)
+	}
+
+	seg := segmentInfo{Name: name, Files: len(files), Size: size, CreatedAt: time.Now()}
+	for _, sf := range files {
+		if sf.file != nil {
+			seg.Chunks += len(sf.file.chunks)
+		}
+	}
+	m := x.manifest
+	m.Version = FormatVersion
+	m.Next++
+	if replace {
+		m.Segments = nil
+	}
+	m.Segments = append(slices.Clip(m.Segments), seg)
+	m.UpdatedAt = seg.CreatedAt
+	if err := writeManifest(x.dir, &m); err != nil {
+		os.Remove(filepath.Join(x.dir, name))
+		return err
+	}
+	x.manifest = m
+	clear(x.dirty)
+	x.removeStale()
+	return nil
+}
+
+// writeManifest 先写临时文件再重命名，避免中断时留下不完整的清单
+func writeManifest(dir string, m *manifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(dir, manifestName+".tmp")
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(dir, manifestName))
+}
+
+// removeStale 删除不在清单中的段文件，包括合并前的旧段和写入中断留下的临时文件，调用方需持有锁
+func (x *Index) removeStale() {
+	entries, err := os.ReadDir(x.dir)
+	if err != nil {
+		return
+	}
+	live := make(map[string]bool)
+	for _, seg := range x.manifest.Segments {
+		live[seg.Name] = true
+	}
+	for _, e := range entries {
+		name := e.Name()
+		if (strings.HasSuffix(name, segmentExt) || strings.HasSuffix(name, segmentExt+".tmp")) && !live[name] {
+			os.Remove(filepath.Join(x.dir, name))
+		}
+	}
+}
+
+// compactTerms 词典中一半以上的词已不再出现时重新编号，调用方需持有锁
+func (x *Index) compactTerms() {
+	live := 0
+	for _, df := range x.docFreq {
+		if df > 0 {
+			live++
+		}
+	}
+	if len(x.termNames) <= 2*live {
+		return
+	}
+
+	oldNames := x.termNames
+	x.termIDs = make(map[string]uint32, live)
+	x.termNames = make([]string, 0, live)
+	x.docFreq = make([]int, 0, live)
+	for _, f := range x.files {
+		for _, e := range f.chunks {
+			for i, p := range e.terms {
+				e.terms[i].term = x.termID(oldNames[p.term])
+				x.docFreq[e.terms[i].term]++
+			}
+			sort.Slice(e.terms, func(i, j int) bool { return e.terms[i].term < e.terms[j].term })
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package index
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPersistence(t *testing.T) {
+	root, dir := t.TempDir(), t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"a.go": "package a\n\nfunc LoadConfig() {}\n",
+		"b.go": "package a\n\nfunc SaveConfig() {}\n",
+		"c.go": "package a\n",
+		"d.go": "package a\n",
+	})
+	ctx := context.Background()
+
+	x := New(root, dir)
+	x.SetEmbedder(fakeEmbedder{})
+	if err := x.Refresh(ctx); err != nil {
+		t.Fatalf("failed to refresh index: %v", err)
+	}
+	if stats := x.Stats(); stats.Segments != 1 || stats.Files != 4 || stats.DiskBytes == 0 {
+		t.Fatalf("expected one segment with 4 files, got %+v", stats)
+	}
+
+	// 重新打开时从段文件加载，未修改的文件不再写入新段
+	reopened := New(root, dir)
+	reopened.SetEmbedder(fakeEmbedder{})
+	if stats := reopened.Stats(); stats.Files != 4 || stats.Chunks != x.Stats().Chunks {
+		t.Fatalf("expected loaded index to match, got %+v", stats)
+	}
+	results, err := reopened.Search(ctx, &Query{Text: "SaveConfig"})
+	if err != nil || len(results) == 0 || results[0].Path != "b.go" {
+		t.Fatalf("expected search on loaded index, got %+v (%v)", results, err)
+	}
+	if err := reopened.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if stats := reopened.Stats(); stats.Segments != 1 {
+		t.Errorf("expected no new segment for unchanged files, got %d", stats.Segments)
+	}
+
+	// 修改和删除写入增量段，加载后以最新的段为准
+	os.Remove(filepath.Join(root, "a.go"))
+	writeFiles(t, root, map[string]string{"b.go": "package a\n\nfunc StoreConfig() {}\n"})
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(filepath.Join(root, "b.go"), future, future)
+	if err := reopened.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if stats := reopened.Stats(); stats.Segments != 2 {
+		t.Errorf("expected incremental segment, got %d segments", stats.Segments)
+	}
+	loaded := New(root, dir)
+	loaded.SetEmbedder(fakeEmbedder{})
+	if stats := loaded.Stats(); stats.Files != 3 {
+		t.Errorf("expected deleted file to stay deleted, got %d files", stats.Files)
+	}
+	if results, _ := loaded.Search(ctx, &Query{Text: "StoreConfig"}); len(results) == 0 || results[0].Path != "b.go" || results[0].Vector == 0 {
+		t.Errorf("expected updated content with vector, got %+v", results)
+	}
+	if results, _ := loaded.Search(ctx, &Query{Text: "SaveConfig"}); len(results) != 1 || len(results[0].Matches) != 0 {
+		t.Errorf("expected old content to be replaced, got %+v", results)
+	}
+}
+
+func TestCompaction(t *testing.T) {
+	root, dir := t.TempDir(), t.TempDir()
+	writeFiles(t, root, map[string]string{"a.go": "package a\n", "b.go": "package b\n"})
+	ctx := context.Background()
+
+	x := New(root, dir)
+	x.Refresh(ctx)
+	for i := 0; i < maxSegments+2; i++ {
+		writeFiles(t, root, map[string]string{"a.go": "package a\n\nvar v" + string(rune('a'+i)) + " = 1\n"})
+		modTime := time.Now().Add(time.Duration(i+1) * time.Minute)
+		os.Chtimes(filepath.Join(root, "a.go"), modTime, modTime)
+		if err := x.Refresh(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats := x.Stats()
+	if stats.Segments > maxSegments {
+		t.Errorf("expected segments to be compacted, got %d", stats.Segments)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != stats.Segments+1 {
+		t.Errorf("expected old segments to be removed, got %d files for %d segments", len(entries), stats.Segments)
+	}
+	if stats.Terms != len(x.termNames) && len(x.termNames) > 2*stats.Terms {
+		t.Errorf("expected unused terms to be dropped, got %d of %d", stats.Terms, len(x.termNames))
+	}
+	if results, _ := New(root, dir).Search(ctx, &Query{Text: "vj"}); len(results) != 1 {
+		t.Errorf("expected latest content after compaction, got %+v", results)
+	}
+}
+
+func TestRebuildDiscardsIncompatibleIndex(t *testing.T) {
+	root, dir := t.TempDir(), t.TempDir()
+	writeFiles(t, root, map[string]string{"a.go": "package a\n\nfunc Run() {}\n"})
+	os.WriteFile(filepath.Join(dir, manifestName), []byte(`{"version": 999, "segments": [{"name": "00000000.seg"}]}`), 0644)
+	os.WriteFile(filepath.Join(dir, "00000000.seg"), []byte("garbage"), 0644)
+
+	x := New(root, dir)
+	if stats := x.Stats(); stats.Files != 0 || stats.Segments != 0 {
+		t.Fatalf("expected incompatible index to be discarded, got %+v", stats)
+	}
+	if err := x.Rebuild(context.Background()); err != nil {
+		t.Fatalf("failed to rebuild: %v", err)
+	}
+	if stats := New(root, dir).Stats(); stats.Files != 1 || stats.Segments != 1 || stats.Version != FormatVersion {
+		t.Errorf("expected rebuilt index, got %+v", stats)
+	}
+}
+
+func TestReadSegmentTruncated(t *testing.T) {
+	root, dir := t.TempDir(), t.TempDir()
+	writeFiles(t, root, map[string]string{"a.go": "package a\n\nfunc Run() {}\n"})
+	x := New(root, dir)
+	x.Refresh(context.Background())
+
+	path := filepath.Join(dir, x.manifest.Segments[0].Name)
+	data, _ := os.ReadFile(path)
+	for _, n := range []int{0, headerSize - 1, headerSize, len(data) - 1} {
+		if _, err := readSegment(data[:n], x.termID, x.spaceID); err == nil {
+			t.Errorf("expected error for segment truncated to %d bytes", n)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package core
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/liangsj/vimcoplit/internal/core/index"
+)
+
+// IndexStatus 是代码检索索引的统计信息和刷新进度，Sources 为只读上下文源的索引统计
+type IndexStatus struct {
+	Stats    index.Stats    `json:"stats"`
+	Progress index.Progress `json:"progress"`
+	Sources  []SourceStatus `json:"sources,omitempty"`
+}
+
+// StartIndexing 在后台刷新代码检索索引，工作区刷新完成后再刷新只读上下文源，已有后台刷新在进行时返回 false
+func (s *serviceImpl) StartIndexing() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.indexCancel != nil {
+		return false
+	}
+	ctx, cancel := context.WithCancel(s.background)
+	s.indexCancel = cancel
+	s.codeIndexAt = time.Now()
+	go func() {
+		defer cancel()
+		if err := s.codeIndex.Refresh(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("刷新代码索引失败: %v\n", err)
+		}
+		s.sources.refresh(ctx)
+		s.mu.Lock()
+		s.indexCancel = nil
+		s.mu.Unlock()
+	}()
+	return true
+}
+
+// CancelIndexing 取消正在进行的后台刷新，已处理的文件仍会保存，没有刷新在进行时返回 false
+func (s *serviceImpl) CancelIndexing() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.indexCancel == nil {
+		return false
+	}
+	s.indexCancel()
+	return true
+}
+
+// IndexStatus 返回代码检索索引的统计信息和最近一次刷新的进度
+func (s *serviceImpl) IndexStatus() *IndexStatus {
+	return &IndexStatus{Stats: s.codeIndex.Stats(), Progress: s.codeIndex.Progress(), Sources: s.sources.status()}
+}
+
+// WatchIndexing 订阅索引刷新的进度，返回当前进度、后续进度的通道和取消订阅的函数
+func (s *serviceImpl) WatchIndexing() (index.Progress, <-chan index.Progress, func()) {
+	return s.codeIndex.Watch()
+}
+
+// IndexFiles 返回参与代码检索索引的文件，all 为 true 时同时返回被排除的文件和目录及其原因，
+// 用于确认助手能检索到哪些文件
+func (s *serviceImpl) IndexFiles(ctx context.Context, all bool) ([]index.FileStatus, error) {
+	return s.codeIndex.Files(ctx, all)
+}
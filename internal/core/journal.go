@@ -0,0 +1,238 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// JournalOp 表示日志记录的操作类型
+type JournalOp string
+
+const (
+	JournalOpWriteFile JournalOp = "write_file"
+)
+
+// JournalEntry 表示一条预写日志
+type JournalEntry struct {
+	ID        string    `json:"id"`
+	Op        JournalOp `json:"op,omitempty"`
+	Path      string    `json:"path,omitempty"`
+	Content   []byte    `json:"content,omitempty"`
+	Before    string    `json:"before,omitempty"` // 写入前文件内容的 SHA-256，文件不存在时为空
+	CreatedAt time.Time `json:"created_at"`
+	Applied   bool      `json:"applied,omitempty"`
+}
+
+// fileHash 返回文件内容的 SHA-256，文件不存在时返回空字符串
+func fileHash(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// journal 是文件修改的预写日志
+// 每次修改先追加一条日志再落盘，完成后追加一条 applied 记录，
+// 进程崩溃后可以重放未完成的修改。进行中的修改都完成后日志被压缩，只保留上次崩溃遗留的未完成日志
+type journal struct {
+	mu       sync.Mutex
+	path     string
+	inflight int // 本进程追加后尚未完成的日志数
+}
+
+// newJournal 创建一个新的预写日志
+func newJournal(path string) *journal {
+	return &journal{path: path}
+}
+
+// append 追加一条日志
+func (j *journal) append(entry *JournalEntry) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if err := j.write(entry); err != nil {
+		return err
+	}
+	j.inflight++
+	return nil
+}
+
+// write 向日志文件追加一条记录，调用方需持有锁
+func (j *journal) write(entry *JournalEntry) error {
+	if entry.ID == "" {
+		entry.ID = uuid.New().String()
+	}
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(j.path), 0755); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(j.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// markApplied 标记一条日志已经完成，本进程的修改都完成后压缩日志
+func (j *journal) markApplied(id string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if err := j.write(&JournalEntry{ID: id, Applied: true}); err != nil {
+		return err
+	}
+	if j.inflight > 0 {
+		j.inflight--
+	}
+	if j.inflight > 0 {
+		return nil
+	}
+	return j.compact()
+}
+
+// compact 重写日志文件，只保留未完成的日志，没有未完成的日志时删除文件，调用方需持有锁
+func (j *journal) compact() error {
+	entries, err := j.read()
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return j.remove()
+	}
+	var buf bytes.Buffer
+	for _, entry := range entries {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		buf.Write(append(data, '\n'))
+	}
+	return writeFileAtomic(j.path, buf.Bytes())
+}
+
+// pending 返回尚未完成的日志，按写入顺序排列
+func (j *journal) pending() ([]*JournalEntry, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return j.read()
+}
+
+// read 读取尚未完成的日志，调用方需持有锁
+func (j *journal) read() ([]*JournalEntry, error) {
+	f, err := os.Open(j.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var order []string
+	entries := make(map[string]*JournalEntry)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var entry JournalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			// 崩溃时可能留下不完整的最后一行
+			continue
+		}
+		if entry.Applied {
+			delete(entries, entry.ID)
+			continue
+		}
+		order = append(order, entry.ID)
+		entries[entry.ID] = &entry
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read journal: %v", err)
+	}
+
+	result := make([]*JournalEntry, 0, len(entries))
+	for _, id := range order {
+		if entry, ok := entries[id]; ok {
+			result = append(result, entry)
+		}
+	}
+	return result, nil
+}
+
+// replay 重放未完成的文件写入后清空日志，返回重放和跳过的写入数。
+// 只有文件仍是写入前的内容时才重放，已经写入或之后被修改过的文件保持不变
+func (j *journal) replay() (replayed, skipped int, err error) {
+	entries, err := j.pending()
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, entry := range entries {
+		if entry.Op != JournalOpWriteFile {
+			continue
+		}
+		current, err := fileHash(entry.Path)
+		if err != nil {
+			return replayed, skipped, fmt.Errorf("failed to replay write to %s: %v", entry.Path, err)
+		}
+		if sum := sha256.Sum256(entry.Content); current == hex.EncodeToString(sum[:]) {
+			continue
+		}
+		if current != entry.Before {
+			log.Printf("文件 %s 在中断的写入之后被修改，跳过重放\n", entry.Path)
+			skipped++
+			continue
+		}
+		if err := writeFileAtomic(entry.Path, entry.Content); err != nil {
+			return replayed, skipped, fmt.Errorf("failed to replay write to %s: %v", entry.Path, err)
+		}
+		replayed++
+	}
+	return replayed, skipped, j.truncate()
+}
+
+// truncate 清空日志，在所有日志都已完成后调用
+func (j *journal) truncate() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return j.remove()
+}
+
+// remove 删除日志文件，调用方需持有锁
+func (j *journal) remove() error {
+	if err := os.Remove(j.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
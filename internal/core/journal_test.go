@@ -0,0 +1,110 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestJournalCompaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.log")
+
+	// 上次崩溃遗留的日志在压缩时保留
+	crashed := &JournalEntry{Op: JournalOpWriteFile, Path: "left.txt", Content: []byte("x")}
+	if err := newJournal(path).append(crashed); err != nil {
+		t.Fatal(err)
+	}
+
+	j := newJournal(path)
+	first := &JournalEntry{Op: JournalOpWriteFile, Path: "a.txt", Content: []byte("a")}
+	second := &JournalEntry{Op: JournalOpWriteFile, Path: "b.txt", Content: []byte("b")}
+	for _, e := range []*JournalEntry{first, second} {
+		if err := j.append(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := j.markApplied(first.ID); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); strings.Count(string(data), "\n") != 4 {
+		t.Errorf("expected no compaction while a write is in flight, got %q", data)
+	}
+	if err := j.markApplied(second.ID); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if strings.Count(string(data), "\n") != 1 || !strings.Contains(string(data), crashed.ID) {
+		t.Errorf("expected only the crashed entry after compaction, got %q", data)
+	}
+	if pending, _ := j.pending(); len(pending) != 1 || pending[0].ID != crashed.ID {
+		t.Errorf("expected the crashed entry to stay pending, got %+v", pending)
+	}
+
+	if err := j.truncate(); err != nil {
+		t.Fatal(err)
+	}
+	third := &JournalEntry{Op: JournalOpWriteFile, Path: "c.txt", Content: []byte("c")}
+	if err := j.append(third); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.markApplied(third.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expected the journal to be removed once every write is applied, got %v", err)
+	}
+}
+
+func TestJournalReplay(t *testing.T) {
+	dir := t.TempDir()
+	file := func(name string) string { return filepath.Join(dir, name) }
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(file(name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("interrupted.txt", "old")
+	write("done.txt", "new")
+	write("edited.txt", "edited by the user")
+	hash := func(content string) string {
+		sum := sha256.Sum256([]byte(content))
+		return hex.EncodeToString(sum[:])
+	}
+
+	j := newJournal(filepath.Join(dir, "journal.log"))
+	for _, e := range []*JournalEntry{
+		{Op: JournalOpWriteFile, Path: file("interrupted.txt"), Content: []byte("new"), Before: hash("old")},
+		{Op: JournalOpWriteFile, Path: file("created.txt"), Content: []byte("new")},
+		{Op: JournalOpWriteFile, Path: file("done.txt"), Content: []byte("new"), Before: hash("old")},
+		{Op: JournalOpWriteFile, Path: file("edited.txt"), Content: []byte("new"), Before: hash("old")},
+	} {
+		if err := j.append(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	replayed, skipped, err := j.replay()
+	if err != nil {
+		t.Fatalf("replay failed: %v", err)
+	}
+	if replayed != 2 || skipped != 1 {
+		t.Errorf("expected 2 replayed and 1 skipped writes, got %d and %d", replayed, skipped)
+	}
+	for name, want := range map[string]string{
+		"interrupted.txt": "new",
+		"created.txt":     "new",
+		"done.txt":        "new",
+		"edited.txt":      "edited by the user",
+	} {
+		if data, _ := os.ReadFile(file(name)); string(data) != want {
+			t.Errorf("%s: expected %q, got %q", name, want, data)
+		}
+	}
+	if pending, _ := j.pending(); len(pending) != 0 {
+		t.Errorf("expected the journal to be empty after replay, got %d entries", len(pending))
+	}
+}
@@ -0,0 +1,267 @@
+package langdetect
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// headSize 是内容识别读取的文件开头的字节数
+const headSize = 4096
+
+// minScore 是特征语法识别的最低得分，低于该值时不猜测
+const minScore = 2
+
+// extensions 按扩展名识别文件的语言
+var extensions = map[string]string{
+	".go": "go", ".py": "python", ".js": "javascript", ".jsx": "javascript", ".mjs": "javascript",
+	".cjs": "javascript", ".ts": "typescript", ".tsx": "typescript", ".rs": "rust", ".java": "java",
+	".c": "c", ".cc": "cpp", ".cpp": "cpp", ".cxx": "cpp", ".hpp": "cpp", ".hh": "cpp", ".mm": "objc",
+	".rb": "ruby", ".sh": "sh", ".bash": "sh", ".zsh": "sh", ".lua": "lua", ".vim": "vim", ".php": "php",
+	".md": "markdown", ".json": "json", ".yaml": "yaml", ".yml": "yaml", ".toml": "toml",
+	".html": "html", ".css": "css", ".sql": "sql", ".proto": "proto", ".mk": "make", ".pm": "perl",
+}
+
+// ambiguous 是对应多种语言的扩展名，按内容在候选语言中识别，识别不出时使用第一个
+var ambiguous = map[string][]string{
+	".h":  {"c", "cpp", "objc"},
+	".m":  {"objc", "matlab"},
+	".pl": {"perl", "prolog"},
+}
+
+// filenames 按文件名识别没有扩展名或扩展名不表示语言的文件，文件名为小写
+var filenames = map[string]string{
+	"makefile": "make", "gnumakefile": "make", "dockerfile": "dockerfile", "containerfile": "dockerfile",
+	"gemfile": "ruby", "rakefile": "ruby", "vagrantfile": "ruby", "podfile": "ruby", "jenkinsfile": "groovy",
+	"cmakelists.txt": "cmake", ".bashrc": "sh", ".bash_profile": "sh", ".zshrc": "sh", ".profile": "sh",
+	".vimrc": "vim", "_vimrc": "vim", ".gvimrc": "vim",
+}
+
+// interpreters 按 shebang 中的解释器识别语言，解释器名已去掉版本号
+var interpreters = map[string]string{
+	"sh": "sh", "bash": "sh", "zsh": "sh", "dash": "sh", "ksh": "sh", "ash": "sh",
+	"python": "python", "pypy": "python", "ruby": "ruby", "node": "javascript", "nodejs": "javascript",
+	"deno": "typescript", "ts-node": "typescript", "tsx": "typescript", "bun": "javascript",
+	"perl": "perl", "php": "php", "lua": "lua", "luajit": "lua", "tclsh": "tcl", "wish": "tcl",
+	"rscript": "r", "awk": "awk", "gawk": "awk", "make": "make", "swipl": "prolog", "fish": "fish",
+	"runghc": "haskell", "runhaskell": "haskell", "elixir": "elixir", "escript": "erlang",
+	"groovy": "groovy", "scala": "scala", "julia": "julia", "pwsh": "powershell", "nvim": "vim", "vim": "vim",
+}
+
+// aliases 把编辑器模式行和代码块围栏中的名称统一为本包使用的语言名
+var aliases = map[string]string{
+	"bash": "sh", "zsh": "sh", "shell": "sh", "shell-script": "sh", "js": "javascript", "ts": "typescript",
+	"py": "python", "python3": "python", "c++": "cpp", "cperl": "perl", "makefile": "make",
+	"objective-c": "objc", "objectivec": "objc", "yml": "yaml", "md": "markdown", "golang": "go",
+	"node": "javascript", "rb": "ruby", "python2": "python", "ipython": "python",
+}
+
+var (
+	vimModeline   = regexp.MustCompile(`(?:^|\s)(?:vi|vim|ex):.*?\b(?:ft|filetype|syntax|syn)=([\w.+-]+)`)
+	emacsMode     = regexp.MustCompile(`-\*-.*?\bmode:\s*([\w.+-]+)`)
+	emacsModeWord = regexp.MustCompile(`-\*-\s*([\w.+-]+)\s*-\*-`)
+	versionSuffix = regexp.MustCompile(`[\d.]+$`)
+)
+
+// heuristic 是一种语言的特征语法，每条匹配的规则按权重计分一次
+type heuristic struct {
+	language string
+	rules    []rule
+}
+
+type rule struct {
+	pattern *regexp.Regexp
+	weight  int
+}
+
+// r 编译多行模式的规则
+func r(pattern string, weight int) rule {
+	return rule{regexp.MustCompile(`(?m)` + pattern), weight}
+}
+
+// heuristics 是各语言的特征语法，得分相同时使用靠前的语言
+var heuristics = []heuristic{
+	{"go", []rule{r(`^package \w+\s*$`, 2), r(`^func (?:\(\w+ \*?\w+\) )?\w+\(`, 2), r(`^import \($`, 1), r(`:= `, 1)}},
+	{"python", []rule{r(`^\s*def \w+\(.*\)(?:\s*->\s*[\w\[\], .]+)?:\s*$`, 2), r(`^from [\w.]+ import `, 2),
+		r(`^\s*if __name__ == ['"]__main__['"]:`, 3), r(`^\s*class \w+(?:\(.*\))?:\s*$`, 2), r(`^\s*elif .*:\s*$`, 2)}},
+	{"php", []rule{r(`<\?php`, 4)}},
+	{"objc", []rule{r(`^\s*@(?:interface|implementation|protocol|property)\b`, 3), r(`^\s*@end\s*$`, 2), r(`^#import\s*[<"]`, 2)}},
+	{"cpp", []rule{r(`\bstd::`, 2), r(`^#include\s*<\w+>`, 2), r(`^\s*(?:namespace \w+|template\s*<)`, 2),
+		r(`^\s*(?:public|private|protected):`, 2), r(`^\s*class \w+(?:\s*:\s*(?:public|private)\s+\w+)?\s*\{`, 1)}},
+	{"c", []rule{r(`^#include\s*[<"][\w/.]+\.h[>"]`, 2), r(`^\s*(?:typedef\s+)?struct \w+\s*\{`, 1), r(`\b(?:printf|malloc|free)\(`, 1),
+		r(`^\s*#(?:define|ifndef|ifdef|endif)\b`, 1)}},
+	{"typescript", []rule{r(`^\s*(?:export\s+)?(?:interface|type) \w+(?:<.*>)?\s*(?:\{|=)`, 2),
+		r(`\b\w+\??:\s*(?:string|number|boolean|void|any)\b`, 2), r(`^\s*import .* from ['"]`, 1)}},
+	{"javascript", []rule{r(`\b(?:const|let|var) \w+ = require\(`, 2), r(`^\s*module\.exports\b`, 2), r(`\bconsole\.log\(`, 1),
+		r(`^\s*import .* from ['"]`, 1), r(`\bfunction\s*\w*\s*\(.*\)\s*\{`, 1), r(`=> \{`, 1)}},
+	{"ruby", []rule{r(`^\s*def \w+[?!]?(?:\(.*\))?\s*$`, 2), r(`^\s*require(?:_relative)? ['"]`, 2), r(`^\s*end\s*$`, 1),
+		r(`^\s*(?:module|class) [A-Z]\w*(?:\s*<\s*[\w:]+)?\s*$`, 1), r(`\bputs\b`, 1), r(`\bdo \|\w+(?:, *\w+)*\|`, 2)}},
+	{"perl", []rule{r(`^\s*use (?:strict|warnings);`, 3), r(`^\s*my [$@%]\w+`, 2), r(`^\s*sub \w+\s*\{`, 2), r(`\$_\b`, 1)}},
+	{"prolog", []rule{r(`^\w+(?:\(.*\))?\s*:-`, 3), r(`^:-\s*\w+`, 2), r(`^\w+\(.*\)\.\s*$`, 1)}},
+	{"matlab", []rule{r(`^\s*function\s+(?:\[?[\w, ]*\]?\s*=\s*)?\w+\s*\(`, 2), r(`^\s*%`, 1), r(`\b(?:disp|fprintf|zeros|ones)\(`, 1),
+		r(`^\s*end\s*$`, 1), r(`;\s*$`, 1)}},
+	{"lua", []rule{r(`^\s*local (?:function )?\w+`, 2), r(`\bthen\s*$`, 1), r(`^\s*require\s*\(?['"]`, 1), r(`^\s*--`, 1)}},
+	{"sh", []rule{r(`^\s*(?:if|while) \[\[? `, 2), r(`^\s*(?:fi|done|esac)\s*$`, 2), r(`^\s*export \w+=`, 1),
+		r(`^\s*\w+\(\)\s*\{`, 1), r(`^\s*set -\w+`, 1), r(`\$\{?\w+\}?`, 1)}},
+	{"vim", []rule{r(`^\s*(?:set|setlocal) \w+`, 1), r(`^\s*(?:n|i|v|x)?noremap\s`, 2), r(`^\s*(?:autocmd|augroup)\s`, 2),
+		r(`^\s*let [gsbl]:\w+`, 2), r(`^\s*function! `, 2)}},
+	{"make", []rule{r(`^\.PHONY:`, 3), r(`^[\w.%/-]+\s*:(?:[^=]|$)`, 1), r(`^\t\S`, 1), r(`\$\([A-Z_]+\)`, 1)}},
+	{"dockerfile", []rule{r(`^FROM \S+`, 2), r(`^(?:RUN|COPY|ADD|ENTRYPOINT|CMD|WORKDIR|ENV|EXPOSE) `, 2)}},
+	{"toml", []rule{r(`^\[[\w.-]+\]\s*$`, 1), r(`^\[\[[\w.-]+\]\]\s*$`, 2), r(`^[\w-]+\s*=\s*(?:"|\d|\[|true|false)`, 1)}},
+	{"yaml", []rule{r(`^---\s*$`, 1), r(`^[\w-]+:\s*$`, 1), r(`^\s+- [\w-]+:`, 2), r(`^[\w-]+: \S`, 1)}},
+	{"markdown", []rule{r("^```", 2), r(`^#{1,6} \S`, 1), r(`\[[^\]]+\]\([^)]+\)`, 1), r(`^\s*[-*] \S`, 1)}},
+}
+
+// Detect 返回文件的语言：能由扩展名确定时直接使用，文件名可识别时使用文件名，
+// 否则依次根据内容开头的 shebang、编辑器模式行和特征语法识别。content 可以为 nil，无法识别时返回空字符串
+func Detect(path string, content []byte) string {
+	base := filepath.Base(path)
+	ext := strings.ToLower(filepath.Ext(base))
+	if lang, ok := extensions[ext]; ok {
+		return lang
+	}
+	if lang, ok := filenames[strings.ToLower(base)]; ok {
+		return lang
+	}
+	candidates := ambiguous[ext]
+	if len(content) > headSize {
+		content = content[:headSize]
+	}
+	if lang := fromContent(content, candidates); lang != "" {
+		return lang
+	}
+	if len(candidates) > 0 {
+		return candidates[0]
+	}
+	return ""
+}
+
+// FromPath 只根据文件名和扩展名识别语言，有歧义的扩展名返回最常见的语言
+func FromPath(path string) string {
+	return Detect(path, nil)
+}
+
+// DetectFile 读取文件开头识别语言
+func DetectFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	head := make([]byte, headSize)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	return Detect(path, head[:n]), nil
+}
+
+// fromContent 根据内容识别语言，candidates 不为空时只在候选语言中选择
+func fromContent(content []byte, candidates []string) string {
+	if len(content) == 0 || bytes.IndexByte(content, 0) >= 0 {
+		return ""
+	}
+	allowed := func(lang string) bool {
+		if lang == "" {
+			return false
+		}
+		return len(candidates) == 0 || slices.Contains(candidates, lang)
+	}
+	if lang := Shebang(content); allowed(lang) {
+		return lang
+	}
+	if lang := modeline(content); allowed(lang) {
+		return lang
+	}
+	if len(candidates) == 0 && len(content) < headSize && json.Valid(content) {
+		if trimmed := bytes.TrimSpace(content); len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+			return "json"
+		}
+	}
+
+	best, bestScore := "", 0
+	for _, h := range heuristics {
+		if !allowed(h.language) {
+			continue
+		}
+		score := 0
+		for _, rule := range h.rules {
+			if rule.pattern.Match(content) {
+				score += rule.weight
+			}
+		}
+		if score > bestScore {
+			best, bestScore = h.language, score
+		}
+	}
+	if bestScore < minScore {
+		return ""
+	}
+	return best
+}
+
+// Shebang 根据第一行的 #! 识别脚本的语言，支持 /usr/bin/env（包括 -S 和环境变量赋值）和带版本号的解释器，
+// 没有 shebang 或解释器无法识别时返回空字符串
+func Shebang(content []byte) string {
+	if !bytes.HasPrefix(content, []byte("#!")) {
+		return ""
+	}
+	line, _, _ := bytes.Cut(content[2:], []byte("\n"))
+	fields := strings.Fields(string(line))
+	if len(fields) == 0 {
+		return ""
+	}
+	interpreter := filepath.Base(fields[0])
+	if interpreter == "env" {
+		interpreter = ""
+		for _, field := range fields[1:] {
+			if strings.HasPrefix(field, "-") || strings.Contains(field, "=") {
+				continue
+			}
+			interpreter = filepath.Base(field)
+			break
+		}
+	}
+	name := strings.ToLower(interpreter)
+	if lang, ok := interpreters[name]; ok {
+		return lang
+	}
+	return interpreters[versionSuffix.ReplaceAllString(name, "")]
+}
+
+// modeline 识别内容开头或结尾五行中的 Vim 模式行和第一行的 Emacs 模式声明
+func modeline(content []byte) string {
+	lines := strings.Split(string(content), "\n")
+	for i, line := range lines {
+		if i >= 5 && i < len(lines)-5 {
+			continue
+		}
+		if m := vimModeline.FindStringSubmatch(line); m != nil {
+			return Normalize(m[1])
+		}
+		if i > 1 {
+			continue
+		}
+		if m := emacsMode.FindStringSubmatch(line); m != nil {
+			return Normalize(m[1])
+		}
+		if m := emacsModeWord.FindStringSubmatch(line); m != nil {
+			return Normalize(m[1])
+		}
+	}
+	return ""
+}
+
+// Normalize 统一模式行、代码块围栏等处的语言名称，如 py、bash 和 c++ 分别统一为 python、sh 和 cpp
+func Normalize(name string) string {
+	name = strings.TrimSuffix(strings.ToLower(name), "-mode")
+	if alias, ok := aliases[name]; ok {
+		return alias
+	}
+	return name
+}
@@ -0,0 +1,75 @@
+package langdetect
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		path    string
+		content string
+		want    string
+	}{
+		{"main.go", "", "go"},
+		{"src/App.TSX", "", "typescript"},
+		{"Makefile", "", "make"},
+		{"docker/Dockerfile", "", "dockerfile"},
+		{"bin/deploy", "#!/bin/bash\nset -e\n", "sh"},
+		{"bin/serve", "#!/usr/bin/env python3.11\nprint('hi')\n", "python"},
+		{"bin/run", "#!/usr/bin/env -S node --no-warnings\nconsole.log(1)\n", "javascript"},
+		{"bin/task", "#!/usr/bin/env FOO=1 ruby\nputs 1\n", "ruby"},
+		{"scripts/setup", "# vim: set ft=lua :\nlocal x = 1\n", "lua"},
+		{"tool", "# -*- mode: python; coding: utf-8 -*-\nx = 1\n", "python"},
+		{"tool", "# -*- coding: utf-8 -*-\nx = 1\n", ""},
+		{"main", "package main\n\nimport (\n\t\"fmt\"\n)\n\nfunc main() {\n\tfmt.Println(1)\n}\n", "go"},
+		{"data", "{\"a\": [1, 2]}\n", "json"},
+		{"notes", "just some words\n", ""},
+		{"lib/vec.h", "#include <vector>\nnamespace geo {\nclass Vec {\npublic:\n};\n}\n", "cpp"},
+		{"lib/list.h", "#include <stdlib.h>\ntypedef struct node {\n\tint v;\n} node;\n", "c"},
+		{"lib/View.h", "#import <UIKit/UIKit.h>\n@interface View : UIView\n@end\n", "objc"},
+		{"lib/empty.h", "", "c"},
+		{"fit.m", "function y = fit(x)\n% 拟合\ny = zeros(1, 3);\nend\n", "matlab"},
+		{"fit.m", "@implementation Fit\n@end\n", "objc"},
+		{"family.pl", "parent(tom, bob).\nancestor(X, Y) :- parent(X, Y).\n", "prolog"},
+		{"report.pl", "#!/usr/bin/perl\nuse strict;\nmy $x = 1;\n", "perl"},
+		// 有歧义的扩展名不接受候选之外的语言
+		{"odd.h", "#!/usr/bin/env python\n", "c"},
+		{"binary", "\x00\x01#!/bin/sh", ""},
+	}
+	for _, tt := range tests {
+		if got := Detect(tt.path, []byte(tt.content)); got != tt.want {
+			t.Errorf("Detect(%q, %q) = %q, want %q", tt.path, tt.content, got, tt.want)
+		}
+	}
+}
+
+func TestShebang(t *testing.T) {
+	tests := map[string]string{
+		"#!/bin/sh\n":                  "sh",
+		"#! /usr/local/bin/python3\n":  "python",
+		"#!/usr/bin/env -S deno run\n": "typescript",
+		"#!/usr/bin/env\n":             "",
+		"#!/opt/custom-interpreter\n":  "",
+		"echo hi\n":                    "",
+	}
+	for content, want := range tests {
+		if got := Shebang([]byte(content)); got != want {
+			t.Errorf("Shebang(%q) = %q, want %q", content, got, want)
+		}
+	}
+}
+
+func TestDetectFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hook")
+	if err := os.WriteFile(path, []byte("#!/usr/bin/env bash\necho ok\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if lang, err := DetectFile(path); err != nil || lang != "sh" {
+		t.Errorf("unexpected language %q %v", lang, err)
+	}
+	if _, err := DetectFile(path + ".missing"); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
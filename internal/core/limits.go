@@ -0,0 +1,55 @@
+package core
+
+import "errors"
+
+// ErrTooManySessions 表示同时进行的生成会话数已达到上限
+var ErrTooManySessions = errors.New("too many concurrent sessions, retry later")
+
+// ResourceUsage 是受资源上限约束的各项当前用量，上限为 0 表示不限制
+type ResourceUsage struct {
+	Sessions             int   `json:"sessions"`
+	MaxSessions          int   `json:"max_sessions"`
+	ContextBytes         int64 `json:"context_bytes"`         // 内存中上下文条目内容的总字节数
+	SpilledContextItems  int   `json:"spilled_context_items"` // 内容只在磁盘上的上下文条目数
+	MaxContextBytes      int64 `json:"max_context_bytes"`
+	Transcripts          int   `json:"transcripts"`
+	CachedTranscripts    int   `json:"cached_transcripts"` // 内存中缓存的完整对话数
+	MaxCachedTranscripts int   `json:"max_cached_transcripts"`
+}
+
+// newSessionSlots 创建限制同时进行的生成会话数的信号量，n 不大于 0 时不限制
+func newSessionSlots(n int) chan struct{} {
+	if n <= 0 {
+		return nil
+	}
+	return make(chan struct{}, n)
+}
+
+// beginSession 占用一个生成会话，达到上限时立即返回 ErrTooManySessions 而不是排队，
+// 避免长期运行的守护进程积压请求。会话结束后必须调用返回的函数
+func (s *serviceImpl) beginSession() (func(), error) {
+	if s.sessions == nil {
+		return func() {}, nil
+	}
+	select {
+	case s.sessions <- struct{}{}:
+		return func() { <-s.sessions }, nil
+	default:
+		return nil, ErrTooManySessions
+	}
+}
+
+// GetResourceUsage 返回各项资源的当前用量和上限
+func (s *serviceImpl) GetResourceUsage() *ResourceUsage {
+	usage := &ResourceUsage{
+		Sessions:    len(s.sessions),
+		MaxSessions: cap(s.sessions),
+	}
+	if m, ok := s.contextManager.(*Manager); ok {
+		usage.ContextBytes, usage.SpilledContextItems = m.LoadedBytes()
+		usage.MaxContextBytes = m.maxBytes
+	}
+	usage.Transcripts, usage.CachedTranscripts = s.conversations.usage()
+	usage.MaxCachedTranscripts = s.conversations.maxCached
+	return usage
+}
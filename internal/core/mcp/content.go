@@ -0,0 +1,158 @@
+package mcp
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ContentType 是工具结果中内容片段的类型
+type ContentType string
+
+const (
+	ContentText   ContentType = "text"   // 纯文本，Text 为内容
+	ContentJSON   ContentType = "json"   // 结构化数据，JSON 为内容
+	ContentImage  ContentType = "image"  // 图片，Data 为内容或 URI 为引用
+	ContentBinary ContentType = "binary" // 其他二进制数据，Data 为内容或 URI 为引用
+	ContentFile   ContentType = "file"   // 文件引用，Path 为文件路径
+)
+
+// Content 是工具结果中的一个内容片段，编辑器按类型渲染，
+// 加入模型对话时通过 RenderText 转换为文本，二进制数据不会直接进入提示词
+type Content struct {
+	Type     ContentType     `json:"type"`
+	Text     string          `json:"text,omitempty"`
+	JSON     json.RawMessage `json:"json,omitempty"`
+	MIMEType string          `json:"mime_type,omitempty"`
+	Data     []byte          `json:"data,omitempty"` // JSON 中为 base64
+	URI      string          `json:"uri,omitempty"`
+	Path     string          `json:"path,omitempty"`
+}
+
+// TextContent 返回文本片段
+func TextContent(text string) Content {
+	return Content{Type: ContentText, Text: text}
+}
+
+// JSONContent 返回把 v 编码为 JSON 的片段
+func JSONContent(v interface{}) (Content, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return Content{}, err
+	}
+	return Content{Type: ContentJSON, JSON: data}, nil
+}
+
+// BinaryContent 返回二进制数据片段，mimeType 为空时按内容检测，图片使用 ContentImage
+func BinaryContent(data []byte, mimeType string) Content {
+	if mimeType == "" {
+		mimeType = http.DetectContentType(data)
+	}
+	typ := ContentBinary
+	if strings.HasPrefix(mimeType, "image/") {
+		typ = ContentImage
+	}
+	return Content{Type: typ, MIMEType: mimeType, Data: data}
+}
+
+// FileContent 返回文件引用片段
+func FileContent(path string) Content {
+	return Content{Type: ContentFile, Path: path}
+}
+
+// Normalize 把本地工具的返回值或远程服务器的响应转换为内容片段：
+// string 为文本，[]byte 为二进制数据，Content 原样保留，
+// MCP 协议格式的 {"content": [...]} 按片段类型转换，其他值编码为 JSON 片段
+func Normalize(v interface{}) []Content {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case string:
+		return []Content{TextContent(v)}
+	case []byte:
+		return []Content{BinaryContent(v, "")}
+	case Content:
+		return []Content{v}
+	case *Content:
+		return []Content{*v}
+	case []Content:
+		return v
+	case map[string]interface{}:
+		if parts, ok := mcpContent(v); ok {
+			return parts
+		}
+	}
+	c, err := JSONContent(v)
+	if err != nil {
+		return []Content{TextContent(fmt.Sprintf("%v", v))}
+	}
+	return []Content{c}
+}
+
+// mcpContent 转换 MCP 协议 tools/call 结果中的 content 数组，不是该格式时 ok 为 false
+func mcpContent(v map[string]interface{}) ([]Content, bool) {
+	items, ok := v["content"].([]interface{})
+	if !ok {
+		return nil, false
+	}
+	parts := make([]Content, 0, len(items))
+	for _, item := range items {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		str := func(key string) string { s, _ := m[key].(string); return s }
+		switch str("type") {
+		case "text":
+			parts = append(parts, TextContent(str("text")))
+		case "image", "audio":
+			data, err := base64.StdEncoding.DecodeString(str("data"))
+			if err != nil {
+				return nil, false
+			}
+			parts = append(parts, BinaryContent(data, str("mimeType")))
+		case "resource":
+			res, _ := m["resource"].(map[string]interface{})
+			uri, _ := res["uri"].(string)
+			mimeType, _ := res["mimeType"].(string)
+			if text, ok := res["text"].(string); ok {
+				parts = append(parts, TextContent(text))
+			} else if strings.HasPrefix(uri, "file://") {
+				parts = append(parts, FileContent(strings.TrimPrefix(uri, "file://")))
+			} else {
+				parts = append(parts, Content{Type: ContentBinary, MIMEType: mimeType, URI: uri})
+			}
+		default:
+			return nil, false
+		}
+	}
+	return parts, true
+}
+
+// RenderText 把内容片段转换为可以加入模型对话的文本，
+// 二进制数据只保留类型和大小，文件只保留路径
+func RenderText(parts []Content) string {
+	var b strings.Builder
+	for i, p := range parts {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		switch p.Type {
+		case ContentText:
+			b.WriteString(p.Text)
+		case ContentJSON:
+			b.Write(p.JSON)
+		case ContentImage, ContentBinary:
+			if p.URI != "" {
+				fmt.Fprintf(&b, "[%s %s: %s]", p.Type, p.MIMEType, p.URI)
+			} else {
+				fmt.Fprintf(&b, "[%s %s, %d bytes]", p.Type, p.MIMEType, len(p.Data))
+			}
+		case ContentFile:
+			fmt.Fprintf(&b, "[file %s]", p.Path)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,70 @@
+package mcp
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n0000")
+	tests := []struct {
+		name string
+		in   interface{}
+		want []Content
+	}{
+		{"nil", nil, nil},
+		{"text", "hello", []Content{{Type: ContentText, Text: "hello"}}},
+		{"json", map[string]interface{}{"n": 1}, []Content{{Type: ContentJSON, JSON: json.RawMessage(`{"n":1}`)}}},
+		{"image bytes", png, []Content{{Type: ContentImage, MIMEType: "image/png", Data: png}}},
+		{"binary bytes", []byte{0, 1, 2}, []Content{{Type: ContentBinary, MIMEType: "application/octet-stream", Data: []byte{0, 1, 2}}}},
+		{"content", FileContent("/tmp/out.txt"), []Content{{Type: ContentFile, Path: "/tmp/out.txt"}}},
+		{"mcp", map[string]interface{}{"content": []interface{}{
+			map[string]interface{}{"type": "text", "text": "done"},
+			map[string]interface{}{"type": "image", "data": base64.StdEncoding.EncodeToString(png), "mimeType": "image/png"},
+			map[string]interface{}{"type": "resource", "resource": map[string]interface{}{"uri": "file:///src/a.go"}},
+			map[string]interface{}{"type": "resource", "resource": map[string]interface{}{"uri": "db://rows/1", "mimeType": "application/x-row"}},
+		}}, []Content{
+			{Type: ContentText, Text: "done"},
+			{Type: ContentImage, MIMEType: "image/png", Data: png},
+			{Type: ContentFile, Path: "/src/a.go"},
+			{Type: ContentBinary, MIMEType: "application/x-row", URI: "db://rows/1"},
+		}},
+		{"not mcp", map[string]interface{}{"content": "plain"}, []Content{{Type: ContentJSON, JSON: json.RawMessage(`{"content":"plain"}`)}}},
+	}
+	for _, tt := range tests {
+		if got := Normalize(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: expected %+v, got %+v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestRenderText(t *testing.T) {
+	parts := []Content{
+		TextContent("ok"),
+		{Type: ContentJSON, JSON: json.RawMessage(`{"n":1}`)},
+		BinaryContent([]byte("\x89PNG\r\n\x1a\n0000"), ""),
+		{Type: ContentBinary, MIMEType: "application/pdf", URI: "https://example.com/a.pdf"},
+		FileContent("/src/a.go"),
+	}
+	want := "ok\n{\"n\":1}\n[image image/png, 12 bytes]\n[binary application/pdf: https://example.com/a.pdf]\n[file /src/a.go]"
+	if got := RenderText(parts); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestContentJSON(t *testing.T) {
+	result := ToolResult{ToolID: "shot", Status: "success", Content: []Content{BinaryContent([]byte{1, 2}, "image/png")}}
+	data, err := json.Marshal(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded ToolResult
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded.Content, result.Content) {
+		t.Errorf("expected %+v after round trip, got %+v", result.Content, decoded.Content)
+	}
+}
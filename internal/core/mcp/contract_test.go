@@ -0,0 +1,20 @@
+package mcp_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/liangsj/vimcoplit/internal/core/mcp"
+	"github.com/liangsj/vimcoplit/internal/testutil/mcptest"
+)
+
+func TestManagerContract(t *testing.T) {
+	mcptest.TestToolManager(t, func(t *testing.T, dir string) mcp.ToolManager {
+		manager := mcp.NewManager(filepath.Join(dir, "mcp.json"))
+		if _, err := manager.Recover(context.Background()); err != nil {
+			t.Fatalf("failed to load manager: %v", err)
+		}
+		return manager
+	})
+}
@@ -91,7 +91,7 @@ func (e *HTTPExecutor) Execute(ctx context.Context, tool *Tool, params map[strin
 
 	// 成功
 	result.Status = ToolExecutionStatusSuccess
-	result.Result = responseBody
+	result.Content = Normalize(responseBody)
 	return result, nil
 }
 
@@ -100,7 +100,7 @@ type LocalExecutor struct {
 	handlers map[string]ToolHandler
 }
 
-// ToolHandler 定义了本地工具处理函数
+// ToolHandler 定义了本地工具处理函数，返回值由 Normalize 转换为内容片段
 type ToolHandler func(ctx context.Context, params map[string]interface{}) (interface{}, error)
 
 // NewLocalExecutor 创建一个新的本地执行器
@@ -150,6 +150,6 @@ func (e *LocalExecutor) Execute(ctx context.Context, tool *Tool, params map[stri
 	}
 
 	execResult.Status = ToolExecutionStatusSuccess
-	execResult.Result = result
+	execResult.Content = Normalize(result)
 	return execResult, nil
 }
@@ -5,12 +5,20 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
 	"os"
 	"path/filepath"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/liangsj/vimcoplit/internal/chaos"
+	"github.com/liangsj/vimcoplit/internal/core/procmon"
+	"github.com/liangsj/vimcoplit/internal/core/shell"
+	"github.com/liangsj/vimcoplit/internal/core/watchdog"
+	"github.com/liangsj/vimcoplit/internal/events"
+	"github.com/liangsj/vimcoplit/internal/units"
 )
 
 // Manager 是 ToolManager 接口的具体实现
@@ -22,8 +30,25 @@ type Manager struct {
 	mu          sync.RWMutex
 	configPath  string
 	executors   map[string]ToolExecutor
+	runners     map[string]ServerRunner
+	monitor     *procmon.Monitor
+	builtins    map[string]*Tool // 内置工具，不属于任何服务器，也不保存到配置文件
+	builtinExec *LocalExecutor
+	chaos       *chaos.Injector    // 测试构建中的故障注入，为 nil 时不注入
+	events      *events.Bus        // 工具调用完成后发布事件，为 nil 时不发布
+	shell       shell.Shell        // 本地服务器在宿主机上执行启动命令的 shell
+	attachments Attacher           // 保存结果中的二进制数据，为 nil 时数据保留在结果中
+	watchdog    *watchdog.Watchdog // 工具调用超过期限仍未返回时终止服务器，为 nil 时不监视
 }
 
+// Attacher 保存二进制数据并返回引用它的 URI
+type Attacher interface {
+	Attach(data []byte, mimeType string) (string, error)
+}
+
+// BuiltinServerID 是内置工具的 ServerID
+const BuiltinServerID = "builtin"
+
 // NewManager 创建一个新的工具管理器
 func NewManager(configPath string) *Manager {
 	return &Manager{
@@ -33,9 +58,60 @@ func NewManager(configPath string) *Manager {
 		timeout:     30 * time.Second,
 		configPath:  configPath,
 		executors:   make(map[string]ToolExecutor),
+		runners:     make(map[string]ServerRunner),
+		builtins:    make(map[string]*Tool),
+		builtinExec: NewLocalExecutor(),
+		chaos:       chaos.Default(),
 	}
 }
 
+// RegisterBuiltinTool 注册内置工具，内置工具无需启动服务器即可执行
+func (m *Manager) RegisterBuiltinTool(tool *Tool, handler ToolHandler) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tool.ServerID = BuiltinServerID
+	tool.CreatedAt = time.Now()
+	tool.UpdatedAt = tool.CreatedAt
+	m.builtins[tool.ID] = tool
+	m.builtinExec.RegisterHandler(tool.ID, handler)
+}
+
+// SetMonitor 设置进程资源监控器，本地服务器启动后会被纳入监控
+func (m *Manager) SetMonitor(monitor *procmon.Monitor) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.monitor = monitor
+}
+
+// SetEvents 设置事件总线，每次工具调用完成后发布 events.TypeTool 事件
+func (m *Manager) SetEvents(bus *events.Bus) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.events = bus
+}
+
+// SetShell 设置本地服务器在宿主机上执行启动命令的 shell，服务器元数据中的设置优先
+func (m *Manager) SetShell(sh shell.Shell) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.shell = sh
+}
+
+// SetAttachments 设置附件存储，工具结果中的二进制数据保存为附件，结果中只保留引用的 URI
+func (m *Manager) SetAttachments(attachments Attacher) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.attachments = attachments
+}
+
+// SetWatchdog 设置监视工具调用的监视器，服务器上的工具超过超时时间仍未返回时终止本地服务器进程
+func (m *Manager) SetWatchdog(w *watchdog.Watchdog) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.watchdog = w
+}
+
 // AddServer 添加一个新的 MCP 服务器
 func (m *Manager) AddServer(ctx context.Context, server *Server) error {
 	m.mu.Lock()
@@ -60,6 +136,15 @@ func (m *Manager) RemoveServer(ctx context.Context, serverID string) error {
 		return errors.New("server not found")
 	}
 
+	// 停止正在运行的服务器
+	if runner, exists := m.runners[serverID]; exists {
+		runner.Stop(ctx)
+		delete(m.runners, serverID)
+	}
+	if m.monitor != nil {
+		m.monitor.Untrack(monitorKey(serverID))
+	}
+
 	// 移除服务器相关的所有工具
 	for toolID, tool := range m.tools {
 		if tool.ServerID == serverID {
@@ -105,12 +190,63 @@ func (m *Manager) StartServer(ctx context.Context, serverID string) error {
 		return errors.New("server not found")
 	}
 
-	// TODO: 实现实际的服务器启动逻辑
+	runner, exists := m.runners[serverID]
+	if !exists {
+		switch server.Type {
+		case ServerTypeLocal:
+			local := NewLocalServerRunner(server)
+			local.shell = m.shell
+			runner = local
+		case ServerTypeRemote:
+			runner = NewRemoteServerRunner(server)
+		default:
+			return fmt.Errorf("unsupported server type: %s", server.Type)
+		}
+		m.runners[serverID] = runner
+	}
+
+	if err := runner.Start(ctx); err != nil {
+		server.Status = ServerStatusError
+		server.UpdatedAt = time.Now()
+		m.saveConfig()
+		return err
+	}
+
+	// 本地服务器纳入资源监控，超限时被终止并标记为错误状态
+	local, isLocal := runner.(*LocalServerRunner)
+	if isLocal {
+		server.PID = local.PID()
+	}
+	if isLocal && m.monitor != nil {
+		m.monitor.Track(monitorKey(serverID), local.PID(), func(stats procmon.Stats, reason string) {
+			log.Printf("MCP 服务器 %s 超出资源上限被终止: %s\n", serverID, reason)
+			local.Stop(context.Background())
+			m.markServerError(serverID)
+		})
+	}
+
 	server.Status = ServerStatusRunning
 	server.UpdatedAt = time.Now()
 	return m.saveConfig()
 }
 
+// monitorKey 返回服务器在资源监控器中的键
+func monitorKey(serverID string) string {
+	return "server/" + serverID
+}
+
+// markServerError 将服务器标记为错误状态
+func (m *Manager) markServerError(serverID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if server, exists := m.servers[serverID]; exists {
+		server.Status = ServerStatusError
+		server.UpdatedAt = time.Now()
+		m.saveConfig()
+	}
+}
+
 // StopServer 停止服务器
 func (m *Manager) StopServer(ctx context.Context, serverID string) error {
 	m.mu.Lock()
@@ -121,17 +257,91 @@ func (m *Manager) StopServer(ctx context.Context, serverID string) error {
 		return errors.New("server not found")
 	}
 
-	// TODO: 实现实际的服务器停止逻辑
+	if runner, exists := m.runners[serverID]; exists {
+		if err := runner.Stop(ctx); err != nil {
+			return err
+		}
+	}
+	if m.monitor != nil {
+		m.monitor.Untrack(monitorKey(serverID))
+	}
+
 	server.Status = ServerStatusStopped
+	server.PID = 0
 	server.UpdatedAt = time.Now()
 	return m.saveConfig()
 }
 
+// StopAll 停止所有正在运行的服务器，返回遇到的第一个错误
+func (m *Manager) StopAll(ctx context.Context) error {
+	m.mu.RLock()
+	ids := make([]string, 0, len(m.runners))
+	for id, runner := range m.runners {
+		if runner.Status() == ServerStatusRunning {
+			ids = append(ids, id)
+		}
+	}
+	m.mu.RUnlock()
+
+	var firstErr error
+	for _, id := range ids {
+		if err := m.StopServer(ctx, id); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Recover 加载持久化的服务器配置，并与实际进程状态对账
+// 上次运行时启动的进程不再受当前进程管理：已退出的标记为停止，
+// 仍然存活的孤儿进程标记为错误状态，返回状态被重置的服务器数量
+func (m *Manager) Recover(ctx context.Context) (int, error) {
+	if err := m.loadConfig(); err != nil {
+		return 0, fmt.Errorf("failed to load mcp config: %v", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	reset := 0
+	for _, server := range m.servers {
+		if server.Status != ServerStatusRunning {
+			continue
+		}
+		if server.Type == ServerTypeLocal && server.PID > 0 && processAlive(server.PID) {
+			log.Printf("MCP 服务器 %s 的孤儿进程 %d 仍在运行，请手动处理\n", server.ID, server.PID)
+			server.Status = ServerStatusError
+		} else {
+			server.Status = ServerStatusStopped
+			server.PID = 0
+		}
+		server.UpdatedAt = time.Now()
+		reset++
+	}
+
+	if reset == 0 {
+		return 0, nil
+	}
+	return reset, m.saveConfig()
+}
+
+// processAlive 判断进程是否存在
+func processAlive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	return p.Signal(syscall.Signal(0)) == nil
+}
+
 // GetTool 获取工具信息
 func (m *Manager) GetTool(ctx context.Context, toolID string) (*Tool, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
+	if tool, exists := m.builtins[toolID]; exists {
+		return tool, nil
+	}
 	tool, exists := m.tools[toolID]
 	if !exists {
 		return nil, errors.New("tool not found")
@@ -144,19 +354,132 @@ func (m *Manager) ListTools(ctx context.Context) ([]*Tool, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	tools := make([]*Tool, 0, len(m.tools))
+	tools := make([]*Tool, 0, len(m.builtins)+len(m.tools))
+	for _, tool := range m.builtins {
+		tools = append(tools, tool)
+	}
 	for _, tool := range m.tools {
 		tools = append(tools, tool)
 	}
 	return tools, nil
 }
 
-// ExecuteTool 执行工具
+// ExecuteTool 执行工具并发布调用事件，启用故障注入时可能返回注入的错误或在执行后丢弃输出
 func (m *Manager) ExecuteTool(ctx context.Context, toolID string, params map[string]interface{}) (*ToolResult, error) {
+	call := &ToolCall{ToolID: toolID, Params: params, StartTime: time.Now()}
+	result, err := m.watchTool(ctx, toolID, params)
+	if err == nil {
+		m.attach(result)
+	}
+	call.EndTime = time.Now()
+	switch {
+	case err != nil:
+		call.Status = string(ToolExecutionStatusError)
+		call.Error = err.Error()
+	default:
+		call.Status = result.Status
+		call.Error = result.Error
+	}
 	m.mu.RLock()
+	bus := m.events
+	m.mu.RUnlock()
+	bus.Publish(events.TypeTool, call)
+	return result, err
+}
+
+// watchTool 在监视器下执行工具。服务器上的工具以超时时间为期限，卡住时终止服务器：
+// 本地服务器的进程被停止并标记为错误状态，远程服务器丢弃执行器以便下次调用使用新的连接。
+// 内置工具只受调用方上下文的期限限制，卡住时放弃等待
+func (m *Manager) watchTool(ctx context.Context, toolID string, params map[string]interface{}) (*ToolResult, error) {
+	m.mu.RLock()
+	w := m.watchdog
 	tool, exists := m.tools[toolID]
+	timeout := m.timeout
 	m.mu.RUnlock()
 
+	var kill func() error
+	if exists {
+		serverID := tool.ServerID
+		kill = func() error { return m.killServer(serverID) }
+	} else {
+		timeout = 0
+	}
+	return watchdog.Run(w, ctx, "tool", toolID, timeout, kill, func(ctx context.Context) (*ToolResult, error) {
+		return m.injectAndExecute(ctx, toolID, params)
+	})
+}
+
+// killServer 强制终止卡住的工具所在的服务器
+func (m *Manager) killServer(serverID string) error {
+	m.mu.Lock()
+	runner := m.runners[serverID]
+	delete(m.executors, serverID)
+	if m.monitor != nil {
+		m.monitor.Untrack(monitorKey(serverID))
+	}
+	m.mu.Unlock()
+
+	local, ok := runner.(*LocalServerRunner)
+	if !ok {
+		return nil
+	}
+	err := local.Stop(context.Background())
+	m.markServerError(serverID)
+	return err
+}
+
+// attach 把结果中的二进制数据保存为附件，保存失败时数据保留在结果中
+func (m *Manager) attach(result *ToolResult) {
+	m.mu.RLock()
+	attachments := m.attachments
+	m.mu.RUnlock()
+	if attachments == nil {
+		return
+	}
+	for i, part := range result.Content {
+		if len(part.Data) == 0 {
+			continue
+		}
+		uri, err := attachments.Attach(part.Data, part.MIMEType)
+		if err != nil {
+			log.Printf("保存工具 %s 结果中的数据失败: %v\n", result.ToolID, err)
+			continue
+		}
+		result.Content[i].URI = uri
+		result.Content[i].Data = nil
+	}
+}
+
+// injectAndExecute 注入故障后执行工具
+func (m *Manager) injectAndExecute(ctx context.Context, toolID string, params map[string]interface{}) (*ToolResult, error) {
+	fault, err := m.chaos.Inject(ctx, chaos.TargetTool)
+	if err != nil {
+		return nil, err
+	}
+	if fault == chaos.FaultError {
+		return nil, chaos.ErrInjected
+	}
+	result, err := m.executeTool(ctx, toolID, params)
+	if err == nil && fault == chaos.FaultTruncate {
+		return nil, chaos.ErrTruncated
+	}
+	return result, err
+}
+
+// executeTool 执行内置工具或服务器提供的工具
+func (m *Manager) executeTool(ctx context.Context, toolID string, params map[string]interface{}) (*ToolResult, error) {
+	m.mu.RLock()
+	builtin, isBuiltin := m.builtins[toolID]
+	tool, exists := m.tools[toolID]
+	m.mu.RUnlock()
+
+	if isBuiltin {
+		result, err := m.builtinExec.Execute(ctx, builtin, params)
+		if err != nil {
+			return nil, err
+		}
+		return toolResult(toolID, result), nil
+	}
 	if !exists {
 		return nil, errors.New("tool not found")
 	}
@@ -192,15 +515,19 @@ func (m *Manager) ExecuteTool(ctx context.Context, toolID string, params map[str
 		return nil, err
 	}
 
-	// 转换结果
+	return toolResult(toolID, result), nil
+}
+
+// toolResult 将执行器的结果转换为 ToolResult
+func toolResult(toolID string, result *ToolExecutionResult) *ToolResult {
 	return &ToolResult{
 		ToolID:    toolID,
 		Status:    string(result.Status),
-		Result:    result.Result,
+		Content:   result.Content,
 		Error:     result.Error,
 		StartTime: result.StartTime,
 		EndTime:   result.EndTime,
-	}, nil
+	}
 }
 
 // SearchTools 搜索工具
@@ -296,12 +623,12 @@ func (m *Manager) saveConfig() error {
 		Servers     map[string]*Server `json:"servers"`
 		Tools       map[string]*Tool   `json:"tools"`
 		AutoApprove bool               `json:"auto_approve"`
-		Timeout     time.Duration      `json:"timeout"`
+		Timeout     units.Duration     `json:"timeout"`
 	}{
 		Servers:     m.servers,
 		Tools:       m.tools,
 		AutoApprove: m.autoApprove,
-		Timeout:     m.timeout,
+		Timeout:     units.Duration(m.timeout),
 	}
 
 	data, err := json.MarshalIndent(config, "", "  ")
@@ -331,20 +658,46 @@ func (m *Manager) loadConfig() error {
 		Servers     map[string]*Server `json:"servers"`
 		Tools       map[string]*Tool   `json:"tools"`
 		AutoApprove bool               `json:"auto_approve"`
-		Timeout     time.Duration      `json:"timeout"`
+		Timeout     json.RawMessage    `json:"timeout"`
 	}
 
 	if err := json.Unmarshal(data, &config); err != nil {
 		return err
 	}
+	timeout, err := parseTimeout(config.Timeout)
+	if err != nil {
+		return err
+	}
 
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	m.servers = config.Servers
-	m.tools = config.Tools
+	if config.Servers != nil {
+		m.servers = config.Servers
+	}
+	if config.Tools != nil {
+		m.tools = config.Tools
+	}
 	m.autoApprove = config.AutoApprove
-	m.timeout = config.Timeout
+	if timeout > 0 {
+		m.timeout = timeout
+	}
 
 	return nil
 }
+
+// parseTimeout 解析配置文件中的超时时间，旧版本以纳秒数保存，新版本保存为 "30s" 格式的字符串
+func parseTimeout(data json.RawMessage) (time.Duration, error) {
+	if len(data) == 0 || string(data) == "null" {
+		return 0, nil
+	}
+	var ns int64
+	if err := json.Unmarshal(data, &ns); err == nil {
+		return time.Duration(ns), nil
+	}
+	var d units.Duration
+	if err := json.Unmarshal(data, &d); err != nil {
+		return 0, fmt.Errorf("invalid timeout: %v", err)
+	}
+	return d.Std(), nil
+}
@@ -0,0 +1,212 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/liangsj/vimcoplit/internal/chaos"
+	"github.com/liangsj/vimcoplit/internal/core/watchdog"
+	"github.com/liangsj/vimcoplit/internal/events"
+)
+
+func TestBuiltinTool(t *testing.T) {
+	manager := NewManager(filepath.Join(t.TempDir(), "mcp.json"))
+	manager.RegisterBuiltinTool(&Tool{ID: "echo", Name: "echo", Parameters: []ToolParameter{{Name: "text", Type: "string"}}}, func(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+		return params["text"], nil
+	})
+
+	ctx := context.Background()
+	tool, err := manager.GetTool(ctx, "echo")
+	if err != nil {
+		t.Fatalf("Failed to get builtin tool: %v", err)
+	}
+	if tool.ServerID != BuiltinServerID {
+		t.Errorf("Expected server %s, got %s", BuiltinServerID, tool.ServerID)
+	}
+
+	tools, err := manager.ListTools(ctx)
+	if err != nil || len(tools) != 1 {
+		t.Fatalf("Expected 1 tool, got %d (%v)", len(tools), err)
+	}
+
+	result, err := manager.ExecuteTool(ctx, "echo", map[string]interface{}{"text": "hi"})
+	if err != nil {
+		t.Fatalf("Failed to execute builtin tool: %v", err)
+	}
+	if result.Text() != "hi" || result.Content[0].Type != ContentText {
+		t.Errorf("Expected text result hi, got %+v", result.Content)
+	}
+
+	// 内置工具不写入配置，重新加载后仍然可用
+	if err := manager.saveConfig(); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+	if err := manager.loadConfig(); err != nil {
+		t.Fatalf("Failed to reload config: %v", err)
+	}
+	if _, err := manager.GetTool(ctx, "echo"); err != nil {
+		t.Errorf("Expected builtin tool to survive reload: %v", err)
+	}
+}
+
+func TestExecuteToolChaos(t *testing.T) {
+	manager := NewManager(filepath.Join(t.TempDir(), "mcp.json"))
+	manager.RegisterBuiltinTool(&Tool{ID: "echo", Name: "echo"}, func(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+		return "ok", nil
+	})
+
+	ctx := context.Background()
+	manager.chaos = chaos.New(chaos.Config{ErrorRate: 1})
+	if _, err := manager.ExecuteTool(ctx, "echo", nil); !errors.Is(err, chaos.ErrInjected) {
+		t.Errorf("Expected injected error, got %v", err)
+	}
+	manager.chaos = chaos.New(chaos.Config{TruncateRate: 1})
+	if _, err := manager.ExecuteTool(ctx, "echo", nil); !errors.Is(err, chaos.ErrTruncated) {
+		t.Errorf("Expected truncated output, got %v", err)
+	}
+	manager.chaos = nil
+	if _, err := manager.ExecuteTool(ctx, "echo", nil); err != nil {
+		t.Errorf("Expected success without injector, got %v", err)
+	}
+}
+
+func TestExecuteToolEvents(t *testing.T) {
+	manager := NewManager(filepath.Join(t.TempDir(), "mcp.json"))
+	manager.RegisterBuiltinTool(&Tool{ID: "echo", Name: "echo"}, func(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+		return "ok", nil
+	})
+	bus := events.New(0)
+	manager.SetEvents(bus)
+
+	ctx := context.Background()
+	manager.ExecuteTool(ctx, "echo", nil)
+	manager.ExecuteTool(ctx, "missing", nil)
+
+	backlog, _, cancel := bus.Subscribe(0, events.TypeTool)
+	defer cancel()
+	if len(backlog) != 2 {
+		t.Fatalf("expected 2 tool events, got %d", len(backlog))
+	}
+	if call := backlog[0].Data.(*ToolCall); call.ToolID != "echo" || call.Status != string(ToolExecutionStatusSuccess) {
+		t.Errorf("unexpected event for successful call %+v", call)
+	}
+	if call := backlog[1].Data.(*ToolCall); call.Status != string(ToolExecutionStatusError) || call.Error == "" {
+		t.Errorf("unexpected event for failed call %+v", call)
+	}
+}
+
+func TestExecuteToolWatchdog(t *testing.T) {
+	manager := NewManager(filepath.Join(t.TempDir(), "mcp.json"))
+	release := make(chan struct{})
+	defer close(release)
+	manager.RegisterBuiltinTool(&Tool{ID: "stuck", Name: "stuck"}, func(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+		<-release // 不响应上下文取消
+		return "late", nil
+	})
+	bus := events.New(0)
+	manager.SetEvents(bus)
+	manager.SetWatchdog(watchdog.New(10*time.Millisecond, bus))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := manager.ExecuteTool(ctx, "stuck", nil); !errors.Is(err, watchdog.ErrHung) {
+		t.Fatalf("expected the hung call to be abandoned, got %v", err)
+	}
+	backlog, _, unsubscribe := bus.Subscribe(0, events.TypeWatchdog, events.TypeTool)
+	defer unsubscribe()
+	if len(backlog) != 2 || backlog[0].Data.(watchdog.Report).Action != "abandoned" ||
+		backlog[1].Data.(*ToolCall).Status != string(ToolExecutionStatusError) {
+		t.Errorf("expected watchdog report followed by failed tool call, got %+v", backlog)
+	}
+}
+
+// fakeAttacher 把数据保存在内存中，返回按顺序编号的 URI
+type fakeAttacher struct {
+	saved [][]byte
+	err   error
+}
+
+func (a *fakeAttacher) Attach(data []byte, mimeType string) (string, error) {
+	if a.err != nil {
+		return "", a.err
+	}
+	a.saved = append(a.saved, data)
+	return fmt.Sprintf("attachment:%d", len(a.saved)), nil
+}
+
+func TestExecuteToolAttachments(t *testing.T) {
+	manager := NewManager(filepath.Join(t.TempDir(), "mcp.json"))
+	manager.RegisterBuiltinTool(&Tool{ID: "render", Name: "render"}, func(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+		return []Content{TextContent("rendered"), BinaryContent([]byte("\x89PNG\r\n\x1a\n"), "image/png")}, nil
+	})
+	attacher := &fakeAttacher{}
+	manager.SetAttachments(attacher)
+
+	ctx := context.Background()
+	result, err := manager.ExecuteTool(ctx, "render", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	image := result.Content[1]
+	if len(attacher.saved) != 1 || image.Data != nil || image.URI != "attachment:1" || image.MIMEType != "image/png" {
+		t.Errorf("expected image moved to attachment, got %+v", image)
+	}
+	if text := result.Text(); text != "rendered\n[image image/png: attachment:1]" {
+		t.Errorf("expected attachment reference in text, got %q", text)
+	}
+
+	// 保存失败时数据保留在结果中
+	attacher.err = errors.New("quota exceeded")
+	result, err = manager.ExecuteTool(ctx, "render", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if image := result.Content[1]; image.URI != "" || len(image.Data) == 0 {
+		t.Errorf("expected inline data kept when attaching fails, got %+v", image)
+	}
+}
+
+func TestTimeoutPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mcp.json")
+	manager := NewManager(path)
+	if err := manager.SetTimeout(context.Background(), 90*time.Second); err != nil {
+		t.Fatalf("SetTimeout: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"timeout": "1m30s"`) {
+		t.Errorf("expected human-readable timeout, got %s", data)
+	}
+
+	// 旧版本以纳秒数保存超时时间
+	for content, want := range map[string]time.Duration{
+		`{"timeout": 7000000000}`: 7 * time.Second,
+		`{"timeout": "2m"}`:       2 * time.Minute,
+	} {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		reopened := NewManager(path)
+		if err := reopened.loadConfig(); err != nil {
+			t.Fatalf("loadConfig(%s): %v", content, err)
+		}
+		if got := reopened.GetTimeout(context.Background()); got != want {
+			t.Errorf("loadConfig(%s) timeout = %s, want %s", content, got, want)
+		}
+	}
+
+	if err := os.WriteFile(path, []byte(`{"timeout": "soon"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewManager(path).loadConfig(); err == nil {
+		t.Error("expected error for invalid timeout")
+	}
+}
@@ -7,6 +7,9 @@ import (
 	"os/exec"
 	"sync"
 	"time"
+
+	"github.com/liangsj/vimcoplit/internal/core/sandbox"
+	"github.com/liangsj/vimcoplit/internal/core/shell"
 )
 
 // ServerRunner 定义了服务器运行器接口
@@ -21,11 +24,13 @@ type ServerRunner interface {
 type LocalServerRunner struct {
 	server     *Server
 	cmd        *exec.Cmd
+	cancel     context.CancelFunc
 	mu         sync.RWMutex
 	status     ServerStatus
 	stopChan   chan struct{}
 	healthURL  string
 	httpClient *http.Client
+	shell      shell.Shell // 宿主机上执行启动命令的 shell，服务器元数据优先
 }
 
 // NewLocalServerRunner 创建一个新的本地服务器运行器
@@ -54,21 +59,41 @@ func (r *LocalServerRunner) Start(ctx context.Context) error {
 		return fmt.Errorf("no start command specified for server %s", r.server.ID)
 	}
 
-	// 创建命令
-	r.cmd = exec.CommandContext(ctx, "sh", "-c", cmd)
+	// 选择执行后端，默认在宿主机上运行
+	runner, err := sandbox.New(sandboxConfig(r.server))
+	if err != nil {
+		r.status = ServerStatusError
+		return err
+	}
 
-	// 设置工作目录
-	if workDir := r.server.Metadata["work_dir"]; workDir != "" {
-		r.cmd.Dir = workDir
+	// 容器中使用镜像自带的 sh，宿主机上使用用户配置的 shell
+	name, args := "sh", []string{"-c", cmd}
+	if runner.Backend() == sandbox.BackendHost {
+		name, args = serverShell(r.server, r.shell).Command(cmd)
 	}
 
-	// 设置环境变量
+	spec := &sandbox.Spec{
+		Name: name,
+		Args: args,
+		Dir:  r.server.Metadata["work_dir"],
+	}
 	if env := r.server.Metadata["env"]; env != "" {
-		r.cmd.Env = append(r.cmd.Env, env)
+		spec.Env = []string{env}
+	}
+
+	// 创建命令，进程生命周期不受发起启动的请求上下文影响
+	runCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	r.cmd, err = runner.Command(runCtx, spec)
+	if err != nil {
+		cancel()
+		r.status = ServerStatusError
+		return err
 	}
+	r.cancel = cancel
 
 	// 启动进程
 	if err := r.cmd.Start(); err != nil {
+		cancel()
 		r.status = ServerStatusError
 		return fmt.Errorf("failed to start server: %v", err)
 	}
@@ -77,7 +102,8 @@ func (r *LocalServerRunner) Start(ctx context.Context) error {
 	r.status = ServerStatusRunning
 
 	// 启动健康检查
-	go r.healthCheck()
+	r.stopChan = make(chan struct{})
+	go r.healthCheck(r.stopChan)
 
 	return nil
 }
@@ -94,11 +120,10 @@ func (r *LocalServerRunner) Stop(ctx context.Context) error {
 	// 发送停止信号
 	close(r.stopChan)
 
-	// 停止进程
-	if r.cmd != nil && r.cmd.Process != nil {
-		if err := r.cmd.Process.Kill(); err != nil {
-			return fmt.Errorf("failed to stop server: %v", err)
-		}
+	// 停止进程，容器后端会在取消时一并停止容器
+	if r.cancel != nil {
+		r.cancel()
+		r.cancel = nil
 	}
 
 	// 更新状态
@@ -132,8 +157,19 @@ func (r *LocalServerRunner) HealthCheck(ctx context.Context) error {
 	return nil
 }
 
+// PID 返回服务器进程 ID，未运行时返回 0
+func (r *LocalServerRunner) PID() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.cmd == nil || r.cmd.Process == nil {
+		return 0
+	}
+	return r.cmd.Process.Pid
+}
+
 // healthCheck 定期执行健康检查
-func (r *LocalServerRunner) healthCheck() {
+func (r *LocalServerRunner) healthCheck(stopChan <-chan struct{}) {
 	ticker := time.NewTicker(30 * time.Second)
 	defer ticker.Stop()
 
@@ -145,12 +181,37 @@ func (r *LocalServerRunner) healthCheck() {
 				r.status = ServerStatusError
 				r.mu.Unlock()
 			}
-		case <-r.stopChan:
+		case <-stopChan:
 			return
 		}
 	}
 }
 
+// sandboxConfig 根据服务器元数据构造沙箱配置
+func sandboxConfig(server *Server) sandbox.Config {
+	return sandbox.Config{
+		Backend:   sandbox.Backend(server.Metadata["sandbox"]),
+		Image:     server.Metadata["sandbox_image"],
+		Workspace: server.Metadata["workspace"],
+		Network:   server.Metadata["sandbox_network"],
+	}
+}
+
+// serverShell 返回执行启动命令的 shell，服务器元数据优先于 base
+func serverShell(server *Server, base shell.Shell) *shell.Shell {
+	sh := &base
+	if path := server.Metadata["shell"]; path != "" {
+		sh.Path = path
+	}
+	if login := server.Metadata["shell_login"]; login != "" {
+		sh.Login = login == "true"
+	}
+	if rcFile := server.Metadata["shell_rc"]; rcFile != "" {
+		sh.RCFile = rcFile
+	}
+	return sh
+}
+
 // RemoteServerRunner 是远程服务器的运行器
 type RemoteServerRunner struct {
 	server     *Server
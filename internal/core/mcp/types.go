@@ -38,6 +38,7 @@ type Server struct {
 	URL         string            `json:"url"`
 	Type        ServerType        `json:"type"`
 	Status      ServerStatus      `json:"status"`
+	PID         int               `json:"pid,omitempty"`
 	Tools       []Tool            `json:"tools"`
 	CreatedAt   time.Time         `json:"created_at"`
 	UpdatedAt   time.Time         `json:"updated_at"`
@@ -61,14 +62,29 @@ const (
 	ServerStatusError   ServerStatus = "error"
 )
 
-// ToolResult 表示工具执行结果
+// ToolResult 表示工具执行结果，Content 为按类型区分的内容片段
 type ToolResult struct {
-	ToolID    string      `json:"tool_id"`
-	Status    string      `json:"status"`
-	Result    interface{} `json:"result,omitempty"`
-	Error     string      `json:"error,omitempty"`
-	StartTime time.Time   `json:"start_time"`
-	EndTime   time.Time   `json:"end_time"`
+	ToolID    string    `json:"tool_id"`
+	Status    string    `json:"status"`
+	Content   []Content `json:"content,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+}
+
+// Text 返回可以加入模型对话的结果文本
+func (r *ToolResult) Text() string {
+	return RenderText(r.Content)
+}
+
+// ToolCall 是一次工具调用的记录，调用完成后作为事件发布
+type ToolCall struct {
+	ToolID    string                 `json:"tool_id"`
+	Params    map[string]interface{} `json:"params,omitempty"`
+	Status    string                 `json:"status"`
+	Error     string                 `json:"error,omitempty"`
+	StartTime time.Time              `json:"start_time"`
+	EndTime   time.Time              `json:"end_time"`
 }
 
 // ToolExecutionStatus 表示工具执行状态
@@ -83,7 +99,7 @@ const (
 // ToolExecutionResult 表示工具执行结果
 type ToolExecutionResult struct {
 	Status    ToolExecutionStatus `json:"status"`
-	Result    interface{}         `json:"result,omitempty"`
+	Content   []Content           `json:"content,omitempty"`
 	Error     string              `json:"error,omitempty"`
 	StartTime time.Time           `json:"start_time"`
 	EndTime   time.Time           `json:"end_time"`
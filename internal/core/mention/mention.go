@@ -0,0 +1,321 @@
+// Package mention 解析对话消息中的 @ 引用（@file、@folder、@url、@symbol），
+// 并将其解析为加入提示词的上下文，插件无需自行展开引用
+package mention
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/liangsj/vimcoplit/internal/models"
+)
+
+// Kind 是引用的类型
+type Kind string
+
+const (
+	KindFile   Kind = "file"
+	KindFolder Kind = "folder"
+	KindURL    Kind = "url"
+	KindSymbol Kind = "symbol"
+)
+
+// maxFolderEntries 是文件夹引用列出的最多文件数
+const maxFolderEntries = 200
+
+// mentionPattern 匹配 @kind:value、@"带空格的值" 和 @value，@ 前必须是行首、空白或左括号，避免误匹配邮箱地址
+var mentionPattern = regexp.MustCompile(`(?:^|[\s(\[{])@(?:(file|folder|url|symbol):)?("[^"\n]+"|[^\s)\]}"]+)`)
+
+// identPattern 匹配可以作为符号引用的标识符，如 Name 或 Type.Method
+var identPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// Mention 是消息中的一个 @ 引用，Kind 为空表示未指定类型，解析时按值推断
+type Mention struct {
+	Kind  Kind   `json:"kind,omitempty"`
+	Value string `json:"value"`
+	Raw   string `json:"raw"` // 消息中的原文，如 @file:main.go
+}
+
+// Parse 按出现顺序返回文本中的 @ 引用，相同的引用只返回一次。
+// 值末尾的句号、逗号等标点不属于引用，带空格的路径可以用双引号括起来
+func Parse(text string) []Mention {
+	var mentions []Mention
+	seen := map[Mention]bool{}
+	for _, m := range mentionPattern.FindAllStringSubmatchIndex(text, -1) {
+		kind := Kind("")
+		if m[2] >= 0 {
+			kind = Kind(text[m[2]:m[3]])
+		}
+		value := text[m[4]:m[5]]
+		end := m[5]
+		if strings.HasPrefix(value, `"`) {
+			value = strings.Trim(value, `"`)
+		} else {
+			trimmed := strings.TrimRight(value, ".,;:!?'")
+			end -= len(value) - len(trimmed)
+			value = trimmed
+		}
+		if value == "" {
+			continue
+		}
+		if kind == "" && (strings.HasPrefix(value, "http://") || strings.HasPrefix(value, "https://")) {
+			kind = KindURL
+		}
+		mention := Mention{Kind: kind, Value: value, Raw: strings.TrimLeft(text[m[0]:end], " \t\r\n([{")}
+		if !seen[mention] {
+			seen[mention] = true
+			mentions = append(mentions, mention)
+		}
+	}
+	return mentions
+}
+
+// Symbol 是符号引用解析到的定义
+type Symbol struct {
+	Path      string
+	StartLine int
+	EndLine   int
+	Content   string
+}
+
+// Attachment 记录一个引用实际加入上下文的内容，Error 不为空时该引用没有加入上下文
+type Attachment struct {
+	Mention
+	Path      string `json:"path,omitempty"`       // 文件、文件夹或符号定义所在文件相对工作区的路径
+	StartLine int    `json:"start_line,omitempty"` // 符号定义的行范围
+	EndLine   int    `json:"end_line,omitempty"`
+	Tokens    int    `json:"tokens,omitempty"`
+	Truncated bool   `json:"truncated,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Resolver 把引用解析为上下文，FetchURL 或 FindSymbol 为空时对应类型的引用不可用
+type Resolver struct {
+	Root         string                                                  // 工作区根目录，文件和文件夹引用不能超出该目录
+	Ignore       func(rel string) bool                                   // 匹配工作区忽略规则的路径不会加入上下文
+	MaxFileBytes int                                                     // 每个文件或网页最多保留的字节数，不大于 0 时不限制
+	FetchURL     func(ctx context.Context, url string) (string, error)   // 返回网页的文本内容
+	FindSymbol   func(ctx context.Context, name string) (*Symbol, error) // 返回符号的定义
+}
+
+// Resolve 依次解析引用，返回加入提示词的上下文和每个引用的解析结果。
+// budget 大于 0 时上下文总长度不超过 budget 个 token，超出预算的引用记录错误后跳过
+func (r *Resolver) Resolve(ctx context.Context, mentions []Mention, budget int) (string, []*Attachment) {
+	var b strings.Builder
+	attachments := make([]*Attachment, 0, len(mentions))
+	for _, m := range mentions {
+		a := &Attachment{Mention: m}
+		attachments = append(attachments, a)
+		label, content, err := r.resolve(ctx, a)
+		if err != nil {
+			a.Error = err.Error()
+			continue
+		}
+		if max := r.MaxFileBytes; max > 0 && len(content) > max {
+			content = content[:max] + "\n..."
+			a.Truncated = true
+		}
+		entry := fmt.Sprintf("--- %s\n%s\n", label, content)
+		tokens := models.EstimateTokens(entry)
+		if budget > 0 && models.EstimateTokens(b.String())+tokens > budget {
+			a.Error = "exceeds the context budget"
+			continue
+		}
+		a.Tokens = tokens
+		b.WriteString(entry)
+	}
+	return b.String(), attachments
+}
+
+// resolve 解析一个引用，返回上下文条目的标题和内容，未指定类型时先按路径再按符号解析
+func (r *Resolver) resolve(ctx context.Context, a *Attachment) (string, string, error) {
+	switch a.Kind {
+	case KindFile, KindFolder:
+		return r.resolvePath(a)
+	case KindURL:
+		return r.resolveURL(ctx, a)
+	case KindSymbol:
+		return r.resolveSymbol(ctx, a)
+	}
+	label, content, err := r.resolvePath(a)
+	if err == nil || !identPattern.MatchString(a.Value) {
+		return label, content, err
+	}
+	if _, statErr := os.Stat(r.abs(a.Value)); statErr == nil {
+		return label, content, err
+	}
+	return r.resolveSymbol(ctx, a)
+}
+
+// abs 返回相对工作区根目录的路径对应的绝对路径
+func (r *Resolver) abs(path string) string {
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+	return filepath.Join(r.Root, path)
+}
+
+// resolvePath 读取文件内容或列出文件夹中的文件，并按实际类型设置 Kind
+func (r *Resolver) resolvePath(a *Attachment) (string, string, error) {
+	path := r.abs(a.Value)
+	rel, err := filepath.Rel(r.Root, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", "", errors.New("path is outside the workspace")
+	}
+	rel = filepath.ToSlash(rel)
+	if r.Ignore != nil && rel != "." && r.Ignore(rel) {
+		return "", "", errors.New("path is ignored")
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", "", errors.New("no such file or folder")
+	}
+	a.Path = rel
+	switch {
+	case info.IsDir() && a.Kind == KindFile:
+		return "", "", errors.New("not a file")
+	case !info.IsDir() && a.Kind == KindFolder:
+		return "", "", errors.New("not a folder")
+	case info.IsDir():
+		a.Kind = KindFolder
+		return r.listFolder(path, rel)
+	}
+	a.Kind = KindFile
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", "", err
+	}
+	return rel, string(data), nil
+}
+
+// listFolder 列出文件夹中未被忽略的文件，跳过隐藏目录
+func (r *Resolver) listFolder(dir, rel string) (string, string, error) {
+	var files []string
+	truncated := false
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		name, _ := filepath.Rel(r.Root, path)
+		name = filepath.ToSlash(name)
+		if path != dir && (strings.HasPrefix(d.Name(), ".") || (r.Ignore != nil && r.Ignore(name))) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if len(files) == maxFolderEntries {
+			truncated = true
+			return filepath.SkipAll
+		}
+		files = append(files, name)
+		return nil
+	})
+	if err != nil {
+		return "", "", err
+	}
+	sort.Strings(files)
+	content := strings.Join(files, "\n")
+	if truncated {
+		content += "\n..."
+	}
+	return rel + "/ (file list)", content, nil
+}
+
+// resolveURL 获取网页的文本内容
+func (r *Resolver) resolveURL(ctx context.Context, a *Attachment) (string, string, error) {
+	if !strings.HasPrefix(a.Value, "http://") && !strings.HasPrefix(a.Value, "https://") {
+		return "", "", errors.New("only http and https urls are supported")
+	}
+	if r.FetchURL == nil {
+		return "", "", errors.New("url mentions are unavailable")
+	}
+	a.Kind = KindURL
+	text, err := r.FetchURL(ctx, a.Value)
+	if err != nil {
+		return "", "", err
+	}
+	return a.Value, text, nil
+}
+
+// resolveSymbol 查找符号的定义
+func (r *Resolver) resolveSymbol(ctx context.Context, a *Attachment) (string, string, error) {
+	if !identPattern.MatchString(a.Value) {
+		return "", "", errors.New("invalid symbol name")
+	}
+	if r.FindSymbol == nil {
+		return "", "", errors.New("symbol mentions are unavailable")
+	}
+	a.Kind = KindSymbol
+	sym, err := r.FindSymbol(ctx, a.Value)
+	if err != nil {
+		return "", "", err
+	}
+	a.Path, a.StartLine, a.EndLine = sym.Path, sym.StartLine, sym.EndLine
+	return fmt.Sprintf("%s (%s:%d-%d)", a.Value, sym.Path, sym.StartLine, sym.EndLine), sym.Content, nil
+}
+
+// DefinitionPattern 返回匹配符号定义的正则表达式，Type.Method 形式的名称匹配 Go 方法定义
+func DefinitionPattern(name string) string {
+	if recv, method, ok := strings.Cut(name, "."); ok {
+		return fmt.Sprintf(`func\s*\([^)]*\b%s\)\s*%s\b`, regexp.QuoteMeta(recv), regexp.QuoteMeta(method))
+	}
+	return fmt.Sprintf(`\b(func|type|var|const|def|class|function|fn|interface|struct|enum|trait)\s+%s\b`, regexp.QuoteMeta(name))
+}
+
+// htmlPatterns 去掉网页中的脚本、样式和标签
+var (
+	scriptPattern = regexp.MustCompile(`(?is)<(script|style)[^>]*>.*?</(script|style)>`)
+	tagPattern    = regexp.MustCompile(`(?s)<[^>]*>`)
+	spacePattern  = regexp.MustCompile(`[ \t]*\n[ \t\n]*`)
+)
+
+// Fetch 获取网页并返回其文本内容，HTML 页面去掉标签，最多读取 limit 字节
+func Fetch(ctx context.Context, url string, limit int64) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("fetch failed: %s", resp.Status)
+	}
+	var body io.Reader = resp.Body
+	if limit > 0 {
+		body = io.LimitReader(resp.Body, limit)
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return "", err
+	}
+	return PageText(data, resp.Header.Get("Content-Type")), nil
+}
+
+// PageText 返回网页的文本内容，contentType 为 HTML 时去掉脚本、样式和标签
+func PageText(data []byte, contentType string) string {
+	text := string(data)
+	if strings.Contains(contentType, "html") {
+		text = scriptPattern.ReplaceAllString(text, "")
+		text = tagPattern.ReplaceAllString(text, " ")
+		text = spacePattern.ReplaceAllString(html.UnescapeString(text), "\n")
+	}
+	return strings.TrimSpace(text)
+}
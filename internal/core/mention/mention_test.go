@@ -0,0 +1,153 @@
+package mention
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	text := "Why does @file:server/main.go call @Handler.ServeHTTP? See @https://example.com/doc, " +
+		"@folder:\"my docs\" and (@utils). Mail me at dev@example.com, @file:server/main.go again."
+	got := Parse(text)
+	want := []Mention{
+		{Kind: KindFile, Value: "server/main.go", Raw: "@file:server/main.go"},
+		{Value: "Handler.ServeHTTP", Raw: "@Handler.ServeHTTP"},
+		{Kind: KindURL, Value: "https://example.com/doc", Raw: "@https://example.com/doc"},
+		{Kind: KindFolder, Value: "my docs", Raw: `@folder:"my docs"`},
+		{Value: "utils", Raw: "@utils"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d mentions, got %+v", len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("mention %d: expected %+v, got %+v", i, want[i], got[i])
+		}
+	}
+	if got := Parse("no mentions here, user@host"); len(got) != 0 {
+		t.Errorf("expected no mentions, got %+v", got)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	root := t.TempDir()
+	for name, content := range map[string]string{
+		"main.go":         "package main\n",
+		"pkg/a.go":        "package pkg\n",
+		"pkg/b.go":        "package pkg\n",
+		"pkg/.hidden/x":   "x",
+		"secret.env":      "TOKEN=1",
+		"big.txt":         strings.Repeat("x", 100),
+		"my docs/read.md": "# docs",
+	} {
+		path := filepath.Join(root, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, []byte(content), 0644)
+	}
+	r := &Resolver{
+		Root:         root,
+		Ignore:       func(rel string) bool { return strings.HasSuffix(rel, ".env") },
+		MaxFileBytes: 50,
+		FindSymbol: func(ctx context.Context, name string) (*Symbol, error) {
+			if name != "Serve" {
+				return nil, errors.New("symbol not found")
+			}
+			return &Symbol{Path: "server.go", StartLine: 3, EndLine: 5, Content: "func Serve() {}"}, nil
+		},
+	}
+
+	text, attachments := r.Resolve(context.Background(), Parse(
+		"@main.go @pkg @Serve @folder:main.go @secret.env @../outside @big.txt @https://example.com @Missing @\"my docs/read.md\""), 0)
+	byRaw := map[string]*Attachment{}
+	for _, a := range attachments {
+		byRaw[a.Raw] = a
+	}
+	if a := byRaw["@main.go"]; a.Kind != KindFile || a.Path != "main.go" || a.Error != "" || a.Tokens == 0 {
+		t.Errorf("unexpected file attachment %+v", a)
+	}
+	if a := byRaw["@pkg"]; a.Kind != KindFolder || a.Error != "" {
+		t.Errorf("unexpected folder attachment %+v", a)
+	}
+	if a := byRaw["@Serve"]; a.Kind != KindSymbol || a.Path != "server.go" || a.StartLine != 3 {
+		t.Errorf("unexpected symbol attachment %+v", a)
+	}
+	if a := byRaw["@big.txt"]; !a.Truncated {
+		t.Errorf("expected large file to be truncated, got %+v", a)
+	}
+	for raw, want := range map[string]string{
+		"@folder:main.go":      "not a folder",
+		"@secret.env":          "path is ignored",
+		"@../outside":          "path is outside the workspace",
+		"@https://example.com": "url mentions are unavailable",
+		"@Missing":             "symbol not found",
+	} {
+		if a := byRaw[raw]; a.Error != want {
+			t.Errorf("%s: expected error %q, got %+v", raw, want, a)
+		}
+	}
+	for _, want := range []string{"--- main.go\npackage main\n", "--- pkg/ (file list)\npkg/a.go\npkg/b.go\n", "--- Serve (server.go:3-5)\nfunc Serve() {}", "--- my docs/read.md\n# docs"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("expected context to contain %q, got:\n%s", want, text)
+		}
+	}
+	if strings.Contains(text, ".hidden") || strings.Contains(text, "TOKEN") {
+		t.Errorf("expected hidden and ignored files to be skipped, got:\n%s", text)
+	}
+
+	// 超出预算的引用跳过，后面放得下的引用仍然加入
+	text, attachments = r.Resolve(context.Background(), Parse("@big.txt @main.go"), 12)
+	if attachments[0].Error != "exceeds the context budget" || attachments[1].Error != "" || !strings.Contains(text, "main.go") {
+		t.Errorf("expected budget to skip the large file, got %+v %+v", attachments[0], attachments[1])
+	}
+}
+
+func TestDefinitionPattern(t *testing.T) {
+	for name, cases := range map[string]map[string]bool{
+		"Serve": {
+			"func Serve(w io.Writer) {": true,
+			"type Serve struct{}":       true,
+			"def Serve(self):":          true,
+			"return Serve()":            false,
+			"func Server() {}":          false,
+		},
+		"Handler.ServeHTTP": {
+			"func (h *Handler) ServeHTTP(w http.ResponseWriter) {": true,
+			"func (h Handler) ServeHTTP() {}":                      true,
+			"func (m *Mux) ServeHTTP() {}":                         false,
+		},
+	} {
+		re := regexp.MustCompile(DefinitionPattern(name))
+		for line, want := range cases {
+			if re.MatchString(line) != want {
+				t.Errorf("%s: expected match=%v for %q", name, want, line)
+			}
+		}
+	}
+}
+
+func TestFetch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte("<html><head><style>p{}</style><script>x()</script></head><body><h1>Title</h1>\n\n<p>a &amp; b</p></body></html>"))
+	}))
+	defer server.Close()
+
+	text, err := Fetch(context.Background(), server.URL, 0)
+	if err != nil || text != "Title\na & b" {
+		t.Errorf("expected page text, got %q %v", text, err)
+	}
+	if _, err := Fetch(context.Background(), server.URL+"/missing", 0); err == nil {
+		t.Error("expected error for missing page")
+	}
+}
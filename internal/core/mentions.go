@@ -0,0 +1,45 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/liangsj/vimcoplit/internal/core/index"
+	"github.com/liangsj/vimcoplit/internal/core/mention"
+)
+
+// maxMentionPageBytes 是获取 @url 引用的网页时最多读取的字节数
+const maxMentionPageBytes = 1 << 20
+
+// mentionResolver 返回解析消息中 @ 引用的解析器，离线时 @url 引用只能使用网页缓存中的内容
+func (s *serviceImpl) mentionResolver() *mention.Resolver {
+	root := s.cfg.WorkspaceRoot()
+	if abs, err := filepath.Abs(root); err == nil {
+		root = abs
+	}
+	return &mention.Resolver{
+		Root:         root,
+		Ignore:       s.settings.get().Ignored,
+		MaxFileBytes: int(s.cfg.AutoContext.MaxFileBytes),
+		FetchURL:     s.fetchPage,
+		FindSymbol:   s.findSymbol,
+	}
+}
+
+// findSymbol 在代码检索索引中查找符号的定义，只读上下文源中的定义路径带上源名称前缀
+func (s *serviceImpl) findSymbol(ctx context.Context, name string) (*mention.Symbol, error) {
+	results, err := s.SearchCode(ctx, &index.Query{Text: name, Regex: mention.DefinitionPattern(name), Limit: 1})
+	if err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, fmt.Errorf("symbol %s not found", name)
+	}
+	r := results[0]
+	path := r.Path
+	if r.Source != "" {
+		path = r.Source + ":" + path
+	}
+	return &mention.Symbol{Path: path, StartLine: r.StartLine, EndLine: r.EndLine, Content: r.Content}, nil
+}
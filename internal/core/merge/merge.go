@@ -0,0 +1,303 @@
+package merge
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// maxDiffCells 是逐行比较时动态规划表的最大单元数，超过时整段视为修改
+const maxDiffCells = 4_000_000
+
+// 冲突标记
+const (
+	markerCurrent = "<<<<<<< current\n"
+	markerBase    = "||||||| base\n"
+	markerSep     = "=======\n"
+	markerEdit    = ">>>>>>> edit\n"
+)
+
+// Hash 返回内容的 SHA-256 摘要
+func Hash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// Conflict 是无法自动合并的一段修改，Line 为该段在合并结果中的起始行，从 1 开始
+type Conflict struct {
+	Line    int    `json:"line"`
+	Base    string `json:"base"`
+	Current string `json:"current"`
+	Edit    string `json:"edit"`
+}
+
+// Result 是三方合并的结果，存在冲突时 Content 中包含冲突标记
+type Result struct {
+	Content   string     `json:"content"`
+	Conflicts []Conflict `json:"conflicts,omitempty"`
+}
+
+// Merge 以 base 为共同祖先逐行合并 current（磁盘上的内容）和 edit（计划写入的内容）
+func Merge(base, current, edit string) *Result {
+	bl, cl, el := splitLines(base), splitLines(current), splitLines(edit)
+	mc, me := match(bl, cl), match(bl, el)
+
+	var out strings.Builder
+	var conflicts []Conflict
+	line := 1
+	emit := func(lines []string) {
+		for _, l := range lines {
+			out.WriteString(l)
+		}
+		line += len(lines)
+	}
+
+	i, c, e := 0, 0, 0
+	for {
+		// 找到下一行三方都没有修改的稳定行
+		k := i
+		for k < len(bl) && (mc[k] < 0 || me[k] < 0) {
+			k++
+		}
+		cEnd, eEnd := len(cl), len(el)
+		if k < len(bl) {
+			cEnd, eEnd = mc[k], me[k]
+		}
+
+		baseChunk, curChunk, editChunk := bl[i:k], cl[c:cEnd], el[e:eEnd]
+		switch {
+		case equal(curChunk, baseChunk):
+			emit(editChunk)
+		case equal(editChunk, baseChunk), equal(curChunk, editChunk):
+			emit(curChunk)
+		default:
+			conflicts = append(conflicts, Conflict{
+				Line:    line,
+				Base:    strings.Join(baseChunk, ""),
+				Current: strings.Join(curChunk, ""),
+				Edit:    strings.Join(editChunk, ""),
+			})
+			out.WriteString(markerCurrent)
+			writeChunk(&out, curChunk)
+			out.WriteString(markerBase)
+			writeChunk(&out, baseChunk)
+			out.WriteString(markerSep)
+			writeChunk(&out, editChunk)
+			out.WriteString(markerEdit)
+			line += 4 + len(curChunk) + len(baseChunk) + len(editChunk)
+		}
+
+		if k == len(bl) {
+			break
+		}
+		emit(bl[k : k+1])
+		i, c, e = k+1, cEnd+1, eEnd+1
+	}
+	return &Result{Content: out.String(), Conflicts: conflicts}
+}
+
+// writeChunk 写入冲突中的一段，保证以换行结尾，避免与冲突标记连在一起
+func writeChunk(out *strings.Builder, lines []string) {
+	for _, l := range lines {
+		out.WriteString(l)
+	}
+	if n := len(lines); n > 0 && !strings.HasSuffix(lines[n-1], "\n") {
+		out.WriteString("\n")
+	}
+}
+
+// splitLines 按行切分并保留换行符
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// equal 判断两段行是否相同
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// match 计算 a 与 b 的最长公共子序列，返回 a 中每一行在 b 中对应的行号，未匹配为 -1
+func match(a, b []string) []int {
+	m := make([]int, len(a))
+	for i := range m {
+		m[i] = -1
+	}
+
+	// 先匹配公共前缀和后缀，减少动态规划的规模
+	pre := 0
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		m[pre] = pre
+		pre++
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+		m[len(a)-1-suf] = len(b) - 1 - suf
+		suf++
+	}
+	a, b = a[pre:len(a)-suf], b[pre:len(b)-suf]
+	if len(a) == 0 || len(b) == 0 || len(a)*len(b) > maxDiffCells {
+		return m
+	}
+
+	// lcs[i][j] 为 a[i:] 与 b[j:] 的最长公共子序列长度
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] == b[j]:
+			m[pre+i] = pre + j
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+	return m
+}
+
+// diffContext 是统一 diff 中每段修改前后保留的上下文行数
+const diffContext = 3
+
+// diffLine 是统一 diff 中的一行，kind 为 ' '、'-' 或 '+'
+type diffLine struct {
+	kind byte
+	text string
+	old  int // 该行之前已经出现的旧内容行数
+	new  int // 该行之前已经出现的新内容行数
+}
+
+// Hunk 是统一 diff 中的一段修改，OldStart 和 NewStart 是该段在旧、新内容中的起始行号（从 1 开始），
+// Lines 的每行以 ' '、'-' 或 '+' 开头，保留原来的换行符，文件末尾没有换行的行不以换行结尾
+type Hunk struct {
+	OldStart int      `json:"old_start"`
+	OldLines int      `json:"old_lines"`
+	NewStart int      `json:"new_start"`
+	NewLines int      `json:"new_lines"`
+	Lines    []string `json:"lines"`
+}
+
+// Header 返回该段的段头，如 @@ -1,3 +1,4 @@
+func (h *Hunk) Header() string {
+	return fmt.Sprintf("@@ -%s +%s @@", hunkRange(h.OldStart-1, h.OldLines), hunkRange(h.NewStart-1, h.NewLines))
+}
+
+// Diff 返回从 old 到 new 的统一 diff（git apply 可用的格式），内容相同时返回空字符串
+// old 为空表示新建文件，new 为空表示删除文件
+func Diff(path, old, new string) string {
+	if old == new {
+		return ""
+	}
+	var out strings.Builder
+	oldName, newName := "a/"+path, "b/"+path
+	if old == "" {
+		oldName = "/dev/null"
+	}
+	if new == "" {
+		newName = "/dev/null"
+	}
+	out.WriteString("--- " + oldName + "\n+++ " + newName + "\n")
+	for _, h := range Hunks(old, new) {
+		out.WriteString(h.Header() + "\n")
+		WriteHunkLines(&out, h.Lines)
+	}
+	return out.String()
+}
+
+// WriteHunkLines 按统一 diff 的格式写出段中的行，没有换行结尾的行加上 \ No newline at end of file
+func WriteHunkLines(out *strings.Builder, lines []string) {
+	for _, l := range lines {
+		out.WriteString(l)
+		if !strings.HasSuffix(l, "\n") {
+			out.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+// Hunks 返回从 old 到 new 的修改，每段前后保留 diffContext 行上下文，
+// 相邻修改之间的未修改行不超过两倍上下文时合并为一段
+func Hunks(old, new string) []Hunk {
+	a, b := splitLines(old), splitLines(new)
+	m := match(a, b)
+
+	var lines []diffLine
+	var changed []int
+	for i, j := 0, 0; i < len(a) || j < len(b); {
+		switch {
+		case i < len(a) && m[i] < 0:
+			lines = append(lines, diffLine{kind: '-', text: a[i], old: i, new: j})
+			i++
+		case j < len(b) && (i == len(a) || j < m[i]):
+			lines = append(lines, diffLine{kind: '+', text: b[j], old: i, new: j})
+			j++
+		default:
+			lines = append(lines, diffLine{kind: ' ', text: a[i], old: i, new: j})
+			i++
+			j++
+			continue
+		}
+		changed = append(changed, len(lines)-1)
+	}
+
+	var hunks []Hunk
+	for k := 0; k < len(changed); {
+		start, end := changed[k], changed[k]
+		for k++; k < len(changed) && changed[k]-end <= 2*diffContext; k++ {
+			end = changed[k]
+		}
+		start = max(start-diffContext, 0)
+		end = min(end+diffContext, len(lines)-1)
+
+		h := Hunk{OldStart: lines[start].old + 1, NewStart: lines[start].new + 1}
+		for _, l := range lines[start : end+1] {
+			if l.kind != '+' {
+				h.OldLines++
+			}
+			if l.kind != '-' {
+				h.NewLines++
+			}
+			h.Lines = append(h.Lines, string(l.kind)+l.text)
+		}
+		hunks = append(hunks, h)
+	}
+	return hunks
+}
+
+// hunkRange 返回统一 diff 段头中的行号范围，start 为该段之前的行数
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
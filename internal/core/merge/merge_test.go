@@ -0,0 +1,70 @@
+package merge
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMergeClean(t *testing.T) {
+	base := "a\nb\nc\nd\ne\n"
+	current := "a\nB\nc\nd\ne\n" // 用户修改了第 2 行
+	edit := "a\nb\nc\nd\nE\nf\n" // 计划修改第 5 行并追加一行
+	result := Merge(base, current, edit)
+	if len(result.Conflicts) != 0 {
+		t.Fatalf("expected clean merge, got %+v", result.Conflicts)
+	}
+	if want := "a\nB\nc\nd\nE\nf\n"; result.Content != want {
+		t.Errorf("expected %q, got %q", want, result.Content)
+	}
+
+	if result := Merge(base, base, edit); result.Content != edit {
+		t.Errorf("expected edit when current is unchanged, got %q", result.Content)
+	}
+	if result := Merge(base, edit, edit); result.Content != edit || len(result.Conflicts) != 0 {
+		t.Errorf("expected identical changes to merge, got %+v", result)
+	}
+}
+
+func TestMergeConflict(t *testing.T) {
+	base := "a\nb\nc\n"
+	result := Merge(base, "a\nuser\nc\n", "a\nai\nc\n")
+	if len(result.Conflicts) != 1 {
+		t.Fatalf("expected 1 conflict, got %+v", result.Conflicts)
+	}
+	conflict := result.Conflicts[0]
+	if conflict.Line != 2 || conflict.Current != "user\n" || conflict.Edit != "ai\n" || conflict.Base != "b\n" {
+		t.Errorf("unexpected conflict %+v", conflict)
+	}
+	want := "a\n<<<<<<< current\nuser\n||||||| base\nb\n=======\nai\n>>>>>>> edit\nc\n"
+	if result.Content != want {
+		t.Errorf("expected %q, got %q", want, result.Content)
+	}
+}
+
+func TestMergeMissingNewline(t *testing.T) {
+	result := Merge("x", "y", "z")
+	if len(result.Conflicts) != 1 || !strings.Contains(result.Content, "y\n||||||| base\nx\n=======\nz\n>>>>>>>") {
+		t.Errorf("unexpected result %q", result.Content)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	old := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\n"
+	new := "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\n"
+	want := "--- a/x.txt\n+++ b/x.txt\n" +
+		"@@ -1,5 +1,5 @@\n a\n-b\n+B\n c\n d\n e\n" +
+		"@@ -8,3 +8,4 @@\n h\n i\n j\n+k\n"
+	if got := Diff("x.txt", old, new); got != want {
+		t.Errorf("unexpected diff:\n%s\nwant:\n%s", got, want)
+	}
+	if got := Diff("x.txt", old, old); got != "" {
+		t.Errorf("expected empty diff for identical content, got %q", got)
+	}
+}
+
+func TestDiffNewFile(t *testing.T) {
+	want := "--- /dev/null\n+++ b/x.txt\n@@ -0,0 +1,2 @@\n+a\n+b\n\\ No newline at end of file\n"
+	if got := Diff("x.txt", "", "a\nb"); got != want {
+		t.Errorf("unexpected diff:\n%s\nwant:\n%s", got, want)
+	}
+}
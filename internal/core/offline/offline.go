@@ -0,0 +1,147 @@
+package offline
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// ErrOffline 表示处于离线模式，无法访问云端模型
+var ErrOffline = errors.New("offline: cloud models are unavailable until connectivity returns")
+
+// Status 描述当前的联网状态
+type Status struct {
+	Offline     bool      `json:"offline"`
+	Forced      bool      `json:"forced"`       // 用户显式开启了离线模式
+	Reachable   bool      `json:"reachable"`    // 最近一次探测是否成功
+	AutoDetect  bool      `json:"auto_detect"`  // 是否自动探测联网状态
+	LastChecked time.Time `json:"last_checked"` // 最近一次探测的时间
+}
+
+// Detector 维护离线状态：显式开启的离线模式，或自动探测到网络不可达
+type Detector struct {
+	mu          sync.RWMutex
+	forced      bool
+	reachable   bool
+	lastChecked time.Time
+	address     string
+	interval    time.Duration
+	listeners   []func(offline bool)
+	probe       func(ctx context.Context, address string) error
+}
+
+// NewDetector 创建离线状态检测器，interval 为 0 时不自动探测，认为网络始终可达
+func NewDetector(forced bool, address string, interval time.Duration) *Detector {
+	return &Detector{
+		forced:    forced,
+		reachable: true,
+		address:   address,
+		interval:  interval,
+		probe:     dialProbe,
+	}
+}
+
+// dialProbe 通过建立 TCP 连接探测网络是否可达
+func dialProbe(ctx context.Context, address string) error {
+	var d net.Dialer
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	conn, err := d.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// Start 在后台定期探测网络，直到 ctx 结束
+func (d *Detector) Start(ctx context.Context) {
+	if d.interval <= 0 || d.address == "" {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(d.interval)
+		defer ticker.Stop()
+		for {
+			d.Check(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Check 立即探测一次网络
+func (d *Detector) Check(ctx context.Context) {
+	reachable := d.probe(ctx, d.address) == nil
+
+	d.mu.Lock()
+	before := d.offlineLocked()
+	d.reachable = reachable
+	d.lastChecked = time.Now()
+	after := d.offlineLocked()
+	d.mu.Unlock()
+
+	if before != after {
+		d.notify(after)
+	}
+}
+
+// SetForced 开启或关闭显式离线模式
+func (d *Detector) SetForced(forced bool) {
+	d.mu.Lock()
+	before := d.offlineLocked()
+	d.forced = forced
+	after := d.offlineLocked()
+	d.mu.Unlock()
+
+	if before != after {
+		d.notify(after)
+	}
+}
+
+// Offline 判断当前是否离线
+func (d *Detector) Offline() bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.offlineLocked()
+}
+
+// offlineLocked 判断当前是否离线，调用方需持有锁
+func (d *Detector) offlineLocked() bool {
+	return d.forced || !d.reachable
+}
+
+// Status 返回当前联网状态
+func (d *Detector) Status() Status {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return Status{
+		Offline:     d.offlineLocked(),
+		Forced:      d.forced,
+		Reachable:   d.reachable,
+		AutoDetect:  d.interval > 0 && d.address != "",
+		LastChecked: d.lastChecked,
+	}
+}
+
+// OnChange 注册离线状态变化的回调
+func (d *Detector) OnChange(fn func(offline bool)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.listeners = append(d.listeners, fn)
+}
+
+// notify 通知所有回调
+func (d *Detector) notify(offline bool) {
+	d.mu.RLock()
+	listeners := append([]func(bool){}, d.listeners...)
+	d.mu.RUnlock()
+
+	for _, fn := range listeners {
+		fn(offline)
+	}
+}
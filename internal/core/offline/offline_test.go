@@ -0,0 +1,50 @@
+package offline
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestDetector(t *testing.T) {
+	d := NewDetector(false, "example.com:443", 0)
+	reachable := true
+	d.probe = func(ctx context.Context, address string) error {
+		if !reachable {
+			return errors.New("unreachable")
+		}
+		return nil
+	}
+
+	var changes []bool
+	d.OnChange(func(offline bool) { changes = append(changes, offline) })
+
+	if d.Offline() {
+		t.Fatal("expected detector to start online")
+	}
+
+	reachable = false
+	d.Check(context.Background())
+	if !d.Offline() {
+		t.Error("expected failed probe to switch to offline")
+	}
+
+	reachable = true
+	d.Check(context.Background())
+	d.SetForced(true)
+	if !d.Offline() || !d.Status().Forced {
+		t.Error("expected forced offline mode")
+	}
+	d.SetForced(false)
+
+	want := []bool{true, false, true, false}
+	if len(changes) != len(want) {
+		t.Fatalf("expected changes %v, got %v", want, changes)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Errorf("expected changes %v, got %v", want, changes)
+			break
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package core
+
+import (
+	"context"
+
+	"github.com/liangsj/vimcoplit/internal/config"
+	"github.com/liangsj/vimcoplit/internal/core/mcp"
+	"github.com/liangsj/vimcoplit/internal/core/opstools"
+)
+
+// registerOpsTools 注册配置中启用的 kubectl 和 docker 只读检查工具，两者默认都不注册
+func registerOpsTools(manager *mcp.Manager, cfg *config.Config) {
+	opts := opstools.Options{
+		KubeContexts:   cfg.OpsTools.KubeContexts,
+		AllowSecrets:   cfg.OpsTools.AllowSecrets,
+		Timeout:        cfg.OpsTools.Timeout.Std(),
+		MaxOutputBytes: int(cfg.OpsTools.MaxOutputBytes),
+	}
+	tailParam := mcp.ToolParameter{
+		Name:        "tail",
+		Type:        "number",
+		Description: "logs only: number of most recent lines, defaults to 200",
+	}
+	sinceParam := mcp.ToolParameter{
+		Name:        "since",
+		Type:        "string",
+		Description: "logs only: only return logs newer than a duration such as 10m or 1h",
+	}
+
+	if cfg.OpsTools.Kubectl {
+		manager.RegisterBuiltinTool(&mcp.Tool{
+			ID:   "kubectl",
+			Name: "kubectl",
+			Description: "Inspect a Kubernetes cluster with kubectl get, describe or logs. " +
+				"Only these read-only commands are available; secrets are hidden unless allowed in the configuration",
+			Parameters: []mcp.ToolParameter{{
+				Name:        "action",
+				Type:        "string",
+				Description: "get, describe or logs",
+				Required:    true,
+			}, {
+				Name:        "resource",
+				Type:        "string",
+				Description: "resource type such as pods, deployments or services; for logs an optional type of the named object such as deployment",
+			}, {
+				Name:        "name",
+				Type:        "string",
+				Description: "object name, omit to list every object of the resource type",
+			}, {
+				Name:        "namespace",
+				Type:        "string",
+				Description: "namespace, defaults to the namespace of the current context",
+			}, {
+				Name:        "all_namespaces",
+				Type:        "boolean",
+				Description: "query every namespace",
+			}, {
+				Name:        "selector",
+				Type:        "string",
+				Description: "label selector such as app=web",
+			}, {
+				Name:        "output",
+				Type:        "string",
+				Description: "get only: wide (default), yaml, json or name",
+			}, {
+				Name:        "container",
+				Type:        "string",
+				Description: "logs only: container in the pod",
+			}, {
+				Name:        "previous",
+				Type:        "boolean",
+				Description: "logs only: logs of the previous terminated container",
+			}, tailParam, sinceParam, {
+				Name:        "context",
+				Type:        "string",
+				Description: "kubeconfig context, must be one of the configured kube_contexts",
+			}},
+		}, func(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+			var req opstools.KubectlRequest
+			req.Action, _ = params["action"].(string)
+			req.Resource, _ = params["resource"].(string)
+			req.Name, _ = params["name"].(string)
+			req.Namespace, _ = params["namespace"].(string)
+			req.AllNamespaces, _ = params["all_namespaces"].(bool)
+			req.Selector, _ = params["selector"].(string)
+			req.Output, _ = params["output"].(string)
+			req.Container, _ = params["container"].(string)
+			req.Previous, _ = params["previous"].(bool)
+			req.Since, _ = params["since"].(string)
+			req.Context, _ = params["context"].(string)
+			tail, _ := params["tail"].(float64)
+			req.Tail = int(tail)
+			return opstools.Kubectl(ctx, &req, opts)
+		})
+	}
+
+	if cfg.OpsTools.Docker {
+		manager.RegisterBuiltinTool(&mcp.Tool{
+			ID:   "docker",
+			Name: "docker",
+			Description: "Inspect local Docker containers with docker ps, logs or inspect. " +
+				"Only these read-only commands are available; environment variable values are hidden unless allowed in the configuration",
+			Parameters: []mcp.ToolParameter{{
+				Name:        "action",
+				Type:        "string",
+				Description: "ps, logs or inspect",
+				Required:    true,
+			}, {
+				Name:        "container",
+				Type:        "string",
+				Description: "container name or ID, required for logs and inspect",
+			}, {
+				Name:        "all",
+				Type:        "boolean",
+				Description: "ps only: include stopped containers",
+			}, tailParam, sinceParam},
+		}, func(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+			var req opstools.DockerRequest
+			req.Action, _ = params["action"].(string)
+			req.Container, _ = params["container"].(string)
+			req.All, _ = params["all"].(bool)
+			req.Since, _ = params["since"].(string)
+			tail, _ := params["tail"].(float64)
+			req.Tail = int(tail)
+			return opstools.Docker(ctx, &req, opts)
+		})
+	}
+}
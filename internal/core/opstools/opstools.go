@@ -0,0 +1,326 @@
+// Package opstools 为 agent 提供查看 Kubernetes 和 Docker 中运行的服务的只读命令：
+// kubectl get/describe/logs 和 docker ps/logs/inspect。参数由结构化的请求生成并逐一校验，
+// 不经过 shell，也不能附加任意的命令行参数；默认隐藏 Secret 和容器环境变量的值
+package opstools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrSecretsHidden 表示请求会输出 Secret 的内容，需要配置 allow_secrets
+var ErrSecretsHidden = errors.New("reading secrets is disabled")
+
+// 默认限制
+const (
+	DefaultTimeout        = 30 * time.Second
+	DefaultMaxOutputBytes = 256 << 10
+	DefaultTail           = 200
+	maxTail               = 5000
+)
+
+var (
+	// namePattern 匹配资源名、容器名、命名空间和 context，不能以 - 开头，避免被当作命令行选项
+	namePattern = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.:@/-]*$`)
+	// resourcePattern 匹配 kubectl 的资源类型，如 pods、deploy/web、pods,services
+	resourcePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9.,/-]*$`)
+	// selectorPattern 匹配标签选择器，如 app=web,tier!=cache
+	selectorPattern = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.,=!/ ()-]*$`)
+	// sincePattern 匹配 --since 的时长，如 10m、1h30m
+	sincePattern = regexp.MustCompile(`^([0-9]+(s|m|h))+$`)
+)
+
+// Options 是工具的限制
+type Options struct {
+	KubeContexts   []string      // 允许使用的 kubeconfig context，为空时只能使用当前 context
+	AllowSecrets   bool          // 允许读取 Secret 和容器环境变量的值
+	Timeout        time.Duration // 单条命令的超时
+	MaxOutputBytes int           // 输出最多保留的字节数，超出部分被截断
+}
+
+// KubectlRequest 是一次 kubectl 查询
+type KubectlRequest struct {
+	Action        string `json:"action"`   // get、describe 或 logs
+	Resource      string `json:"resource"` // 资源类型，logs 时可以为空（默认为 pod）
+	Name          string `json:"name,omitempty"`
+	Namespace     string `json:"namespace,omitempty"`
+	AllNamespaces bool   `json:"all_namespaces,omitempty"`
+	Selector      string `json:"selector,omitempty"`
+	Output        string `json:"output,omitempty"` // get 的输出格式：wide（默认）、yaml、json 或 name
+	Container     string `json:"container,omitempty"`
+	Tail          int    `json:"tail,omitempty"`
+	Since         string `json:"since,omitempty"`
+	Previous      bool   `json:"previous,omitempty"`
+	Context       string `json:"context,omitempty"`
+}
+
+// DockerRequest 是一次 docker 查询
+type DockerRequest struct {
+	Action    string `json:"action"` // ps、logs 或 inspect
+	Container string `json:"container,omitempty"`
+	All       bool   `json:"all,omitempty"` // ps 时包括已停止的容器
+	Tail      int    `json:"tail,omitempty"`
+	Since     string `json:"since,omitempty"`
+}
+
+// Output 是命令的输出，命令以非零状态退出不是错误，由调用方根据 ExitCode 和 Stderr 判断
+type Output struct {
+	Command   string `json:"command"`
+	ExitCode  int    `json:"exit_code"`
+	Stdout    string `json:"stdout"`
+	Stderr    string `json:"stderr,omitempty"`
+	Truncated bool   `json:"truncated,omitempty"`
+}
+
+// checkName 校验名称类参数，为空时不检查
+func checkName(field, value string) error {
+	if value != "" && !namePattern.MatchString(value) {
+		return fmt.Errorf("invalid %s %q", field, value)
+	}
+	return nil
+}
+
+// logArgs 生成 logs 共用的 --tail 和 --since 参数
+func logArgs(tail int, since string) ([]string, error) {
+	if tail <= 0 {
+		tail = DefaultTail
+	}
+	args := []string{"--tail", strconv.Itoa(min(tail, maxTail))}
+	if since != "" {
+		if !sincePattern.MatchString(since) {
+			return nil, fmt.Errorf("invalid since %q, expected a duration such as 10m or 1h", since)
+		}
+		args = append(args, "--since", since)
+	}
+	return args, nil
+}
+
+// KubectlArgs 校验请求并生成 kubectl 的参数
+func KubectlArgs(req *KubectlRequest, opts Options) ([]string, error) {
+	for field, value := range map[string]string{
+		"name": req.Name, "namespace": req.Namespace, "container": req.Container, "context": req.Context,
+	} {
+		if err := checkName(field, value); err != nil {
+			return nil, err
+		}
+	}
+	if req.Resource != "" && !resourcePattern.MatchString(req.Resource) {
+		return nil, fmt.Errorf("invalid resource %q", req.Resource)
+	}
+	if req.Selector != "" && !selectorPattern.MatchString(req.Selector) {
+		return nil, fmt.Errorf("invalid selector %q", req.Selector)
+	}
+	if req.Context != "" && !slices.Contains(opts.KubeContexts, req.Context) {
+		return nil, fmt.Errorf("kube context %q is not allowed, allowed contexts: %s", req.Context, strings.Join(opts.KubeContexts, ", "))
+	}
+
+	var args []string
+	if req.Context != "" {
+		args = append(args, "--context", req.Context)
+	}
+	switch {
+	case req.AllNamespaces:
+		args = append(args, "--all-namespaces")
+	case req.Namespace != "":
+		args = append(args, "--namespace", req.Namespace)
+	}
+
+	switch req.Action {
+	case "get", "describe":
+		if req.Resource == "" {
+			return nil, fmt.Errorf("resource is required for kubectl %s", req.Action)
+		}
+		if req.Action == "get" && !opts.AllowSecrets && mentionsSecrets(req.Resource) {
+			return nil, fmt.Errorf("%w: set ops_tools.allow_secrets to get secrets", ErrSecretsHidden)
+		}
+		args = append(args, req.Action, req.Resource)
+		if req.Name != "" {
+			args = append(args, req.Name)
+		}
+		if req.Selector != "" {
+			args = append(args, "--selector", req.Selector)
+		}
+		if req.Action == "get" {
+			output := req.Output
+			if output == "" {
+				output = "wide"
+			}
+			if !slices.Contains([]string{"wide", "yaml", "json", "name"}, output) {
+				return nil, fmt.Errorf("invalid output %q, expected wide, yaml, json or name", output)
+			}
+			args = append(args, "--output", output)
+		}
+	case "logs":
+		target := req.Name
+		if req.Resource != "" && req.Name != "" {
+			target = req.Resource + "/" + req.Name
+		}
+		if target == "" && req.Selector == "" {
+			return nil, errors.New("name or selector is required for kubectl logs")
+		}
+		args = append(args, "logs")
+		if target != "" {
+			args = append(args, target)
+		} else {
+			args = append(args, "--selector", req.Selector)
+		}
+		if req.Container != "" {
+			args = append(args, "--container", req.Container)
+		}
+		if req.Previous {
+			args = append(args, "--previous")
+		}
+		logs, err := logArgs(req.Tail, req.Since)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, logs...)
+	default:
+		return nil, fmt.Errorf("unsupported kubectl action %q, expected get, describe or logs", req.Action)
+	}
+	return args, nil
+}
+
+// mentionsSecrets 判断资源类型中是否包含 Secret
+func mentionsSecrets(resource string) bool {
+	for _, r := range strings.Split(resource, ",") {
+		kind, _, _ := strings.Cut(r, "/")
+		kind, _, _ = strings.Cut(kind, ".")
+		if kind == "secret" || kind == "secrets" {
+			return true
+		}
+	}
+	return false
+}
+
+// DockerArgs 校验请求并生成 docker 的参数
+func DockerArgs(req *DockerRequest) ([]string, error) {
+	if err := checkName("container", req.Container); err != nil {
+		return nil, err
+	}
+	switch req.Action {
+	case "ps":
+		args := []string{"ps", "--no-trunc"}
+		if req.All {
+			args = append(args, "--all")
+		}
+		return args, nil
+	case "logs":
+		if req.Container == "" {
+			return nil, errors.New("container is required for docker logs")
+		}
+		logs, err := logArgs(req.Tail, req.Since)
+		if err != nil {
+			return nil, err
+		}
+		return append(append([]string{"logs"}, logs...), req.Container), nil
+	case "inspect":
+		if req.Container == "" {
+			return nil, errors.New("container is required for docker inspect")
+		}
+		return []string{"inspect", req.Container}, nil
+	}
+	return nil, fmt.Errorf("unsupported docker action %q, expected ps, logs or inspect", req.Action)
+}
+
+// Kubectl 校验请求并执行 kubectl
+func Kubectl(ctx context.Context, req *KubectlRequest, opts Options) (*Output, error) {
+	args, err := KubectlArgs(req, opts)
+	if err != nil {
+		return nil, err
+	}
+	return run(ctx, "kubectl", args, opts, nil)
+}
+
+// Docker 校验请求并执行 docker，没有 AllowSecrets 时在截断输出前隐藏 inspect 中环境变量的值
+func Docker(ctx context.Context, req *DockerRequest, opts Options) (*Output, error) {
+	args, err := DockerArgs(req)
+	if err != nil {
+		return nil, err
+	}
+	var filter func(string) string
+	if req.Action == "inspect" && !opts.AllowSecrets {
+		filter = MaskInspectEnv
+	}
+	return run(ctx, "docker", args, opts, filter)
+}
+
+// run 执行命令并返回输出，filter 不为 nil 时在截断前处理标准输出。命令不存在或无法启动时返回错误
+func run(ctx context.Context, name string, args []string, opts Options, filter func(string) string) (*Output, error) {
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	if opts.MaxOutputBytes <= 0 {
+		opts.MaxOutputBytes = DefaultMaxOutputBytes
+	}
+	if _, err := exec.LookPath(name); err != nil {
+		return nil, fmt.Errorf("%s is not installed or not in PATH", name)
+	}
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, name, args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	err := cmd.Run()
+	out := &Output{Command: strings.Join(append([]string{name}, args...), " ")}
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(err, &exitErr):
+		out.ExitCode = exitErr.ExitCode()
+	case err != nil:
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("%s timed out after %s", name, opts.Timeout)
+		}
+		return nil, err
+	}
+	text := stdout.String()
+	if filter != nil {
+		text = filter(text)
+	}
+	out.Stdout, out.Truncated = truncate(text, opts.MaxOutputBytes)
+	var truncated bool
+	out.Stderr, truncated = truncate(stderr.String(), opts.MaxOutputBytes)
+	out.Truncated = out.Truncated || truncated
+	return out, nil
+}
+
+// truncate 保留输出的末尾，日志最新的部分在最后
+func truncate(s string, n int) (string, bool) {
+	if len(s) <= n {
+		return s, false
+	}
+	return strings.ToValidUTF8(s[len(s)-n:], ""), true
+}
+
+// MaskInspectEnv 把 docker inspect 输出中容器环境变量的值替换为 ***，输出不是预期的 JSON 时原样返回
+func MaskInspectEnv(output string) string {
+	var objects []map[string]interface{}
+	if err := json.Unmarshal([]byte(output), &objects); err != nil {
+		return output
+	}
+	for _, obj := range objects {
+		config, _ := obj["Config"].(map[string]interface{})
+		env, _ := config["Env"].([]interface{})
+		for i, v := range env {
+			if s, ok := v.(string); ok {
+				name, _, _ := strings.Cut(s, "=")
+				env[i] = name + "=***"
+			}
+		}
+	}
+	data, err := json.MarshalIndent(objects, "", "    ")
+	if err != nil {
+		return output
+	}
+	return string(data) + "\n"
+}
@@ -0,0 +1,175 @@
+package opstools
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestKubectlArgs(t *testing.T) {
+	opts := Options{KubeContexts: []string{"staging"}}
+	tests := []struct {
+		req  KubectlRequest
+		want string
+	}{
+		{KubectlRequest{Action: "get", Resource: "pods"}, "get pods --output wide"},
+		{KubectlRequest{Action: "get", Resource: "deploy", Name: "web", Namespace: "prod", Output: "yaml"},
+			"--namespace prod get deploy web --output yaml"},
+		{KubectlRequest{Action: "describe", Resource: "pods", Selector: "app=web", AllNamespaces: true, Namespace: "ignored"},
+			"--all-namespaces describe pods --selector app=web"},
+		{KubectlRequest{Action: "logs", Name: "web-1", Container: "app", Tail: 50, Since: "10m", Previous: true, Context: "staging"},
+			"--context staging logs web-1 --container app --previous --tail 50 --since 10m"},
+		{KubectlRequest{Action: "logs", Resource: "deployment", Name: "web"}, "logs deployment/web --tail 200"},
+		{KubectlRequest{Action: "logs", Selector: "app=web", Tail: 100000}, "logs --selector app=web --tail 5000"},
+	}
+	for _, tt := range tests {
+		args, err := KubectlArgs(&tt.req, opts)
+		if err != nil {
+			t.Errorf("%+v: %v", tt.req, err)
+			continue
+		}
+		if got := strings.Join(args, " "); got != tt.want {
+			t.Errorf("%+v: expected %q, got %q", tt.req, tt.want, got)
+		}
+	}
+}
+
+func TestKubectlArgsRejects(t *testing.T) {
+	tests := []KubectlRequest{
+		{Action: "delete", Resource: "pods"},
+		{Action: "exec", Name: "web-1"},
+		{Action: "get"},
+		{Action: "get", Resource: "pods", Name: "--raw=/api"},
+		{Action: "get", Resource: "pods", Namespace: "-A"},
+		{Action: "get", Resource: "pods; rm -rf /"},
+		{Action: "get", Resource: "pods", Output: "go-template={{.}}"},
+		{Action: "get", Resource: "pods", Selector: "app=$(id)"},
+		{Action: "get", Resource: "pods", Context: "production"},
+		{Action: "logs"},
+		{Action: "logs", Name: "web-1", Since: "yesterday"},
+	}
+	for _, req := range tests {
+		if args, err := KubectlArgs(&req, Options{}); err == nil {
+			t.Errorf("%+v: expected an error, got %q", req, args)
+		}
+	}
+}
+
+func TestKubectlSecrets(t *testing.T) {
+	for _, resource := range []string{"secrets", "secret/db", "pods,secrets", "secrets.v1"} {
+		req := KubectlRequest{Action: "get", Resource: resource}
+		if _, err := KubectlArgs(&req, Options{}); !errors.Is(err, ErrSecretsHidden) {
+			t.Errorf("%s: expected ErrSecretsHidden, got %v", resource, err)
+		}
+		if _, err := KubectlArgs(&req, Options{AllowSecrets: true}); err != nil {
+			t.Errorf("%s: expected secrets to be allowed, got %v", resource, err)
+		}
+	}
+	// describe 只输出 Secret 各个键的长度
+	if _, err := KubectlArgs(&KubectlRequest{Action: "describe", Resource: "secrets"}, Options{}); err != nil {
+		t.Errorf("expected describe secrets to be allowed, got %v", err)
+	}
+}
+
+func TestDockerArgs(t *testing.T) {
+	tests := []struct {
+		req  DockerRequest
+		want string
+	}{
+		{DockerRequest{Action: "ps"}, "ps --no-trunc"},
+		{DockerRequest{Action: "ps", All: true}, "ps --no-trunc --all"},
+		{DockerRequest{Action: "logs", Container: "web", Tail: 20, Since: "1h30m"}, "logs --tail 20 --since 1h30m web"},
+		{DockerRequest{Action: "inspect", Container: "3f2a9c"}, "inspect 3f2a9c"},
+	}
+	for _, tt := range tests {
+		args, err := DockerArgs(&tt.req)
+		if err != nil {
+			t.Errorf("%+v: %v", tt.req, err)
+			continue
+		}
+		if got := strings.Join(args, " "); got != tt.want {
+			t.Errorf("%+v: expected %q, got %q", tt.req, tt.want, got)
+		}
+	}
+
+	for _, req := range []DockerRequest{
+		{Action: "rm", Container: "web"},
+		{Action: "logs"},
+		{Action: "inspect", Container: "--format={{.}}"},
+		{Action: "logs", Container: "web`id`"},
+	} {
+		if args, err := DockerArgs(&req); err == nil {
+			t.Errorf("%+v: expected an error, got %q", req, args)
+		}
+	}
+}
+
+// fakeCommand 在 PATH 最前面放一个输出固定内容的脚本
+func fakeCommand(t *testing.T, name, script string) {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestDockerInspectMasksEnv(t *testing.T) {
+	fakeCommand(t, "docker", `echo '[{"Name":"/web","Config":{"Env":["PATH=/usr/bin","DB_PASSWORD=hunter2"]}}]'`)
+	out, err := Docker(context.Background(), &DockerRequest{Action: "inspect", Container: "web"}, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out.Stdout, "hunter2") || !strings.Contains(out.Stdout, `"DB_PASSWORD=***"`) {
+		t.Errorf("expected environment values to be masked, got %s", out.Stdout)
+	}
+	if out.Command != "docker inspect web" {
+		t.Errorf("unexpected command %q", out.Command)
+	}
+
+	out, err = Docker(context.Background(), &DockerRequest{Action: "inspect", Container: "web"}, Options{AllowSecrets: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.Stdout, "hunter2") {
+		t.Errorf("expected environment values with allow_secrets, got %s", out.Stdout)
+	}
+}
+
+func TestRunExitCodeAndTruncation(t *testing.T) {
+	fakeCommand(t, "kubectl", `printf 'line1\nline2\nline3\n'; echo 'Error from server (NotFound)' >&2; exit 1`)
+	out, err := Kubectl(context.Background(), &KubectlRequest{Action: "logs", Name: "web-1"}, Options{MaxOutputBytes: 6})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.ExitCode != 1 || !out.Truncated {
+		t.Errorf("expected exit code 1 and truncated output, got %+v", out)
+	}
+	// 保留最新的输出
+	if out.Stdout != "line3\n" {
+		t.Errorf("expected the tail of the output, got %q", out.Stdout)
+	}
+}
+
+func TestRunMissingCommand(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	_, err := Docker(context.Background(), &DockerRequest{Action: "ps"}, Options{})
+	if err == nil || !strings.Contains(err.Error(), "not installed") {
+		t.Errorf("expected a missing command error, got %v", err)
+	}
+}
+
+func TestMaskInspectEnvKeepsInvalidOutput(t *testing.T) {
+	for _, s := range []string{"", "Error: No such object: web\n", `{"Config":{}}`} {
+		if got := MaskInspectEnv(s); got != s {
+			t.Errorf("expected %q unchanged, got %q", s, got)
+		}
+	}
+	if got := MaskInspectEnv(`[{"Config":{"Env":null}}]`); !slices.Contains(strings.Fields(got), `"Env":`) {
+		t.Errorf("expected valid output to be reformatted, got %q", got)
+	}
+}
@@ -0,0 +1,475 @@
+// Package patch 把计划的文件修改渲染为供审阅的 diff：每段修改有稳定的 ID，
+// 标出所在的语法区域（函数、类型等），可以输出统一 diff 或左右对照的文本，
+// 并按审阅结果只应用被接受的段
+package patch
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"regexp"
+	"strings"
+
+	"github.com/liangsj/vimcoplit/internal/core/langdetect"
+	"github.com/liangsj/vimcoplit/internal/core/merge"
+)
+
+// Region 是一段修改所在的语法区域，Kind 为 func、method、type、var、const 或 import，
+// 只能按行首识别时为空；行号按修改后的内容计算
+type Region struct {
+	Kind      string `json:"kind,omitempty"`
+	Name      string `json:"name"`
+	StartLine int    `json:"start_line"`
+	EndLine   int    `json:"end_line,omitempty"`
+}
+
+// Hunk 是一段可以单独接受或拒绝的修改，ID 由文件路径、位置和内容计算，内容不变时保持不变
+type Hunk struct {
+	ID string `json:"id"`
+	merge.Hunk
+	Region   *Region `json:"region,omitempty"`
+	Rejected bool    `json:"rejected,omitempty"`
+
+	introduced map[string]bool // 新增行中旧内容没有的标识符
+	defined    map[string]bool // 新增行中定义的、旧内容没有的标识符
+	removed    map[string]bool // 删除行中新内容没有的标识符
+	deleted    map[string]bool // 删除行中的所有标识符，拒绝该段时这些引用保留
+}
+
+// File 是一个文件的修改，Created 和 Deleted 表示新建和删除文件
+type File struct {
+	Path     string  `json:"path"`
+	Language string  `json:"language,omitempty"`
+	Created  bool    `json:"created,omitempty"`
+	Deleted  bool    `json:"deleted,omitempty"`
+	Hunks    []*Hunk `json:"hunks"`
+}
+
+// New 计算从 old 到 new 的修改，内容相同时 Hunks 为空
+func New(path, old, new string) *File {
+	content := new
+	if content == "" {
+		content = old
+	}
+	f := &File{
+		Path:     path,
+		Language: langdetect.Detect(path, []byte(content)),
+		Created:  old == "" && new != "",
+		Deleted:  old != "" && new == "",
+		Hunks:    []*Hunk{},
+	}
+	regions := newRegionFinder(f.Language, new)
+	oldIdents, newIdents := identifiers(old), identifiers(new)
+	for _, h := range merge.Hunks(old, new) {
+		sum := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%d\x00%s", path, h.OldStart, strings.Join(h.Lines, ""))))
+		hunk := &Hunk{
+			ID:         fmt.Sprintf("%x", sum[:6]),
+			Hunk:       h,
+			Region:     regions.find(firstChange(&h)),
+			introduced: make(map[string]bool),
+			defined:    make(map[string]bool),
+			removed:    make(map[string]bool),
+			deleted:    make(map[string]bool),
+		}
+		for _, l := range h.Lines {
+			if l[0] == '+' {
+				for _, m := range definedPattern.FindAllStringSubmatch(l[1:], -1) {
+					if ident := m[1] + m[2]; !oldIdents[ident] {
+						hunk.defined[ident] = true
+					}
+				}
+			}
+			for ident := range identifiers(l[1:]) {
+				switch {
+				case l[0] == '+' && !oldIdents[ident]:
+					hunk.introduced[ident] = true
+				case l[0] == '-':
+					hunk.deleted[ident] = true
+					if !newIdents[ident] {
+						hunk.removed[ident] = true
+					}
+				}
+			}
+		}
+		f.Hunks = append(f.Hunks, hunk)
+	}
+	return f
+}
+
+// Reject 按 ID 标记被拒绝的段，其余的段视为接受；返回不属于该文件的 ID
+func (f *File) Reject(ids []string) []string {
+	rejected := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		rejected[id] = true
+	}
+	for _, h := range f.Hunks {
+		h.Rejected = rejected[h.ID]
+		delete(rejected, h.ID)
+	}
+	var unknown []string
+	for _, id := range ids {
+		if rejected[id] {
+			unknown = append(unknown, id)
+		}
+	}
+	return unknown
+}
+
+// Accepted 返回被接受的段数
+func (f *File) Accepted() int {
+	n := 0
+	for _, h := range f.Hunks {
+		if !h.Rejected {
+			n++
+		}
+	}
+	return n
+}
+
+// Apply 把被接受的段应用到 old 上，old 必须是计算修改时的旧内容；没有拒绝任何段时结果等于新内容
+func (f *File) Apply(old string) string {
+	lines := strings.SplitAfter(old, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	var b strings.Builder
+	pos := 0
+	for _, h := range f.Hunks {
+		for ; pos < h.OldStart-1 && pos < len(lines); pos++ {
+			b.WriteString(lines[pos])
+		}
+		for _, l := range h.Lines {
+			switch {
+			case l[0] == ' ', l[0] == '+' && !h.Rejected, l[0] == '-' && h.Rejected:
+				b.WriteString(l[1:])
+			}
+		}
+		pos += h.OldLines
+	}
+	for ; pos < len(lines); pos++ {
+		b.WriteString(lines[pos])
+	}
+	return b.String()
+}
+
+// Dependency 表示被接受的段依赖被拒绝的段，只应用前者可能得到无法编译的结果
+type Dependency struct {
+	Path         string `json:"path"`
+	Hunk         string `json:"hunk"`          // 被接受的段
+	RequiresPath string `json:"requires_path"` // 被拒绝的段所在的文件
+	Requires     string `json:"requires"`      // 被拒绝的段
+	Identifier   string `json:"identifier"`
+	Reason       string `json:"reason"` // uses：使用了被拒绝的段定义的标识符；removes：删除了被拒绝的段保留的代码仍在使用的标识符
+}
+
+func (d *Dependency) String() string {
+	if d.Reason == "removes" {
+		return fmt.Sprintf("accepted hunk %s in %s removes %s, which is still used by rejected hunk %s in %s",
+			d.Hunk, d.Path, d.Identifier, d.Requires, d.RequiresPath)
+	}
+	return fmt.Sprintf("accepted hunk %s in %s uses %s, which is defined by rejected hunk %s in %s",
+		d.Hunk, d.Path, d.Identifier, d.Requires, d.RequiresPath)
+}
+
+// Dependencies 按标识符查找被接受的段对被拒绝的段的依赖，可以跨文件：
+// 被接受的段新增的代码使用了只有被拒绝的段才定义的名称，或者删除了被拒绝的段保留下来的代码仍引用的名称。
+// 只按名称匹配，不分析作用域，结果用于提醒而不是阻止应用
+func Dependencies(files []*File) []Dependency {
+	// 被接受的段自己定义的名称不算依赖
+	accepted := make(map[string]bool)
+	for _, f := range files {
+		for _, h := range f.Hunks {
+			if !h.Rejected {
+				for ident := range h.defined {
+					accepted[ident] = true
+				}
+			}
+		}
+	}
+	var deps []Dependency
+	for _, f := range files {
+		for _, h := range f.Hunks {
+			if h.Rejected {
+				continue
+			}
+			for _, rf := range files {
+				for _, r := range rf.Hunks {
+					if !r.Rejected {
+						continue
+					}
+					if ident := common(h.introduced, r.defined, accepted); ident != "" {
+						deps = append(deps, Dependency{Path: f.Path, Hunk: h.ID, RequiresPath: rf.Path, Requires: r.ID, Identifier: ident, Reason: "uses"})
+					} else if ident := common(h.removed, r.deleted, nil); ident != "" {
+						deps = append(deps, Dependency{Path: f.Path, Hunk: h.ID, RequiresPath: rf.Path, Requires: r.ID, Identifier: ident, Reason: "removes"})
+					}
+				}
+			}
+		}
+	}
+	return deps
+}
+
+// common 返回 a 和 b 中都有、except 中没有的按字典序最小的元素，没有时返回空字符串
+func common(a, b, except map[string]bool) string {
+	var found string
+	for ident := range a {
+		if b[ident] && !except[ident] && (found == "" || ident < found) {
+			found = ident
+		}
+	}
+	return found
+}
+
+// identPattern 匹配标识符，过短的名称容易误判，不参与依赖分析
+var identPattern = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_]{2,}`)
+
+// definedPattern 匹配常见语言中定义名称的写法：声明关键字之后的名称，或 Go 的短变量声明
+var definedPattern = regexp.MustCompile(`\b(?:func|type|var|const|def|class|function|fn|struct|enum|trait|interface|let)\s+(?:\([^)]*\)\s*)?([A-Za-z_]\w*)|([A-Za-z_]\w*)\s*:=`)
+
+// identifiers 返回文本中的标识符集合
+func identifiers(text string) map[string]bool {
+	set := make(map[string]bool)
+	for _, ident := range identPattern.FindAllString(text, -1) {
+		set[ident] = true
+	}
+	return set
+}
+
+// label 返回段头后附加的标记：段 ID、拒绝状态和语法区域
+func (h *Hunk) label() string {
+	label := "[" + h.ID
+	if h.Rejected {
+		label += " rejected"
+	}
+	label += "]"
+	if h.Region != nil {
+		label += " " + h.Region.Name
+	}
+	return label
+}
+
+// Unified 返回统一 diff，段头之后标出段 ID 和语法区域，git apply 会忽略这部分
+func (f *File) Unified() string {
+	if len(f.Hunks) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	oldName, newName := "a/"+f.Path, "b/"+f.Path
+	if f.Created {
+		oldName = "/dev/null"
+	}
+	if f.Deleted {
+		newName = "/dev/null"
+	}
+	b.WriteString("--- " + oldName + "\n+++ " + newName + "\n")
+	for _, h := range f.Hunks {
+		b.WriteString(h.Header() + " " + h.label() + "\n")
+		merge.WriteHunkLines(&b, h.Lines)
+	}
+	return b.String()
+}
+
+// minSideWidth 是左右对照时每一侧的最小宽度
+const minSideWidth = 20
+
+// SideBySide 返回左右对照的文本，width 为总宽度。左侧为旧内容、右侧为新内容，
+// 中间的标记为 | 修改、< 删除、> 新增，行号按各自的内容计算
+func (f *File) SideBySide(width int) string {
+	if len(f.Hunks) == 0 {
+		return ""
+	}
+	side := max((width-3)/2, minSideWidth)
+	var b strings.Builder
+	b.WriteString(f.Path + "\n")
+	for _, h := range f.Hunks {
+		b.WriteString(h.Header() + " " + h.label() + "\n")
+		oldLine, newLine := h.OldStart, h.NewStart
+		var removed, added []string
+		flush := func() {
+			for i := 0; i < len(removed) || i < len(added); i++ {
+				left, right, mark := "", "", byte('|')
+				leftNum, rightNum := 0, 0
+				if i < len(removed) {
+					left, leftNum = removed[i], oldLine
+					oldLine++
+				} else {
+					mark = '>'
+				}
+				if i < len(added) {
+					right, rightNum = added[i], newLine
+					newLine++
+				} else {
+					mark = '<'
+				}
+				writeRow(&b, side, leftNum, left, mark, rightNum, right)
+			}
+			removed, added = nil, nil
+		}
+		for _, l := range h.Lines {
+			switch l[0] {
+			case '-':
+				if len(added) > 0 {
+					flush()
+				}
+				removed = append(removed, l[1:])
+			case '+':
+				added = append(added, l[1:])
+			default:
+				flush()
+				writeRow(&b, side, oldLine, l[1:], ' ', newLine, l[1:])
+				oldLine++
+				newLine++
+			}
+		}
+		flush()
+	}
+	return b.String()
+}
+
+// writeRow 写出左右对照的一行，行号为 0 表示该侧没有内容
+func writeRow(b *strings.Builder, side, leftNum int, left string, mark byte, rightNum int, right string) {
+	row := cell(side, leftNum, left) + " " + string(mark) + " " + cell(side, rightNum, right)
+	b.WriteString(strings.TrimRight(row, " ") + "\n")
+}
+
+// cell 返回宽度为 side 的一侧内容：4 位行号和截断后的文本，制表符展开为 4 个空格
+func cell(side, num int, text string) string {
+	if num == 0 {
+		return strings.Repeat(" ", side)
+	}
+	text = strings.ReplaceAll(strings.TrimRight(text, "\r\n"), "\t", "    ")
+	runes := []rune(text)
+	if limit := side - 5; len(runes) > limit {
+		runes = append(runes[:limit-1], '…')
+	}
+	s := fmt.Sprintf("%4d %s", num, string(runes))
+	return s + strings.Repeat(" ", max(side-5-len(runes), 0))
+}
+
+// firstChange 返回段中第一处修改在新内容中的行号，纯删除时为删除位置之后的行
+func firstChange(h *merge.Hunk) int {
+	line := h.NewStart
+	for _, l := range h.Lines {
+		if l[0] != ' ' {
+			return line
+		}
+		line++
+	}
+	return line
+}
+
+// definitionPattern 匹配常见语言中定义的开始行
+var definitionPattern = regexp.MustCompile(`^\s*(?:(?:export|pub(?:\([a-z]+\))?|async|static|public|private|protected|default)\s+)*(?:def|class|function|fn|func|impl|struct|enum|trait|interface|module)\b`)
+
+// regionFinder 查找新内容中某一行所在的语法区域
+type regionFinder struct {
+	lines   []string
+	regions []Region // Go 文件解析出的顶层声明，为空时按行首识别
+}
+
+// newRegionFinder 解析新内容，Go 文件按顶层声明识别，解析失败或其他语言按行首识别
+func newRegionFinder(language, content string) *regionFinder {
+	r := &regionFinder{lines: strings.Split(content, "\n")}
+	if language == "go" {
+		r.regions = goRegions(content)
+	}
+	return r
+}
+
+// find 返回行所在的语法区域，不在任何区域中时返回 nil
+func (r *regionFinder) find(line int) *Region {
+	for i := range r.regions {
+		if region := r.regions[i]; region.StartLine <= line && line <= region.EndLine {
+			return &region
+		}
+	}
+	if len(r.regions) > 0 {
+		return nil
+	}
+	// 与 git diff 的默认规则相同：向上查找第一个定义行，没有时取第一个不缩进的行
+	var fallback *Region
+	for i := min(line, len(r.lines)) - 1; i >= 0; i-- {
+		text := strings.TrimRight(r.lines[i], "\r")
+		if definitionPattern.MatchString(text) {
+			return &Region{Name: truncate(strings.TrimSpace(text)), StartLine: i + 1}
+		}
+		if fallback == nil && text != "" && isIdentStart(text[0]) {
+			fallback = &Region{Name: truncate(text), StartLine: i + 1}
+		}
+	}
+	return fallback
+}
+
+// isIdentStart 判断字符能否作为不缩进的定义行的开头
+func isIdentStart(c byte) bool {
+	return c == '_' || c == '$' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+// truncate 截断过长的区域名称
+func truncate(s string) string {
+	if runes := []rune(s); len(runes) > 80 {
+		return string(runes[:79]) + "…"
+	}
+	return s
+}
+
+// goRegions 返回 Go 源码的顶层声明，包括前面的文档注释
+func goRegions(content string) []Region {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", content, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil {
+		return nil
+	}
+	var regions []Region
+	for _, decl := range file.Decls {
+		var region Region
+		start := decl.Pos()
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			region.Kind, region.Name = "func", "func "+d.Name.Name
+			if d.Recv != nil && len(d.Recv.List) > 0 {
+				region.Kind, region.Name = "method", "func ("+receiver(d.Recv.List[0].Type)+") "+d.Name.Name
+			}
+			if d.Doc != nil {
+				start = d.Doc.Pos()
+			}
+		case *ast.GenDecl:
+			region.Kind = d.Tok.String()
+			region.Name = region.Kind
+			if len(d.Specs) > 0 {
+				switch spec := d.Specs[0].(type) {
+				case *ast.TypeSpec:
+					region.Name += " " + spec.Name.Name
+				case *ast.ValueSpec:
+					region.Name += " " + spec.Names[0].Name
+				}
+			}
+			if d.Doc != nil {
+				start = d.Doc.Pos()
+			}
+		default:
+			continue
+		}
+		region.StartLine = fset.Position(start).Line
+		region.EndLine = fset.Position(decl.End()).Line
+		regions = append(regions, region)
+	}
+	return regions
+}
+
+// receiver 返回方法接收者的类型，如 *Server
+func receiver(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return "*" + receiver(t.X)
+	case *ast.IndexExpr:
+		return receiver(t.X)
+	case *ast.IndexListExpr:
+		return receiver(t.X)
+	case *ast.Ident:
+		return t.Name
+	}
+	return "?"
+}
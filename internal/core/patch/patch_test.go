@@ -0,0 +1,125 @@
+package patch
+
+import (
+	"strings"
+	"testing"
+)
+
+const oldSource = `package main
+
+import "fmt"
+
+// Load 读取配置
+func Load() error {
+	fmt.Println("load")
+	return nil
+}
+
+type Server struct{}
+
+func (s *Server) Start() {
+	fmt.Println("start")
+}
+`
+
+func newSource() string {
+	s := strings.Replace(oldSource, `fmt.Println("load")`, `fmt.Println("loading")`, 1)
+	return strings.Replace(s, `fmt.Println("start")`, "fmt.Println(\"start\")\n\tfmt.Println(\"started\")", 1)
+}
+
+func TestHunks(t *testing.T) {
+	f := New("main.go", oldSource, newSource())
+	if len(f.Hunks) != 2 || f.Language != "go" || f.Created {
+		t.Fatalf("expected two hunks in a go file, got %+v", f)
+	}
+	first, second := f.Hunks[0], f.Hunks[1]
+	if first.Region == nil || first.Region.Name != "func Load" || first.Region.StartLine != 5 {
+		t.Errorf("expected first hunk in Load including its doc comment, got %+v", first.Region)
+	}
+	if second.Region == nil || second.Region.Kind != "method" || second.Region.Name != "func (*Server) Start" {
+		t.Errorf("expected second hunk in Server.Start, got %+v", second.Region)
+	}
+	// 内容不变时 ID 不变
+	if again := New("main.go", oldSource, newSource()); again.Hunks[0].ID != first.ID || first.ID == second.ID {
+		t.Errorf("expected stable distinct ids, got %s %s %s", first.ID, again.Hunks[0].ID, second.ID)
+	}
+
+	unified := f.Unified()
+	if !strings.HasPrefix(unified, "--- a/main.go\n+++ b/main.go\n@@ -4,7 +4,7 @@ ["+first.ID+"] func Load\n") {
+		t.Errorf("unexpected unified diff:\n%s", unified)
+	}
+
+	// 每侧宽度为 (100-3)/2 = 48
+	side := f.SideBySide(100)
+	if !strings.Contains(side, "\n   7     fmt.Println(\"load\")"+strings.Repeat(" ", 20)+" |    7     fmt.Println(\"loading\")\n") ||
+		!strings.Contains(side, "\n"+strings.Repeat(" ", 48)+" >   15     fmt.Println(\"started\")\n") {
+		t.Errorf("unexpected side-by-side diff:\n%s", side)
+	}
+}
+
+func TestApply(t *testing.T) {
+	f := New("main.go", oldSource, newSource())
+	if got := f.Apply(oldSource); got != newSource() {
+		t.Errorf("expected all hunks applied, got:\n%s", got)
+	}
+
+	unknown := f.Reject([]string{f.Hunks[0].ID, "missing"})
+	if len(unknown) != 1 || unknown[0] != "missing" || f.Accepted() != 1 {
+		t.Fatalf("unexpected rejection result %v, %d accepted", unknown, f.Accepted())
+	}
+	want := strings.Replace(newSource(), `fmt.Println("loading")`, `fmt.Println("load")`, 1)
+	if got := f.Apply(oldSource); got != want {
+		t.Errorf("expected only the second hunk applied, got:\n%s", got)
+	}
+	if !strings.Contains(f.Unified(), "["+f.Hunks[0].ID+" rejected]") {
+		t.Errorf("expected rejected marker in unified diff:\n%s", f.Unified())
+	}
+
+	f.Reject([]string{f.Hunks[0].ID, f.Hunks[1].ID})
+	if got := f.Apply(oldSource); got != oldSource {
+		t.Errorf("expected no change with all hunks rejected, got:\n%s", got)
+	}
+}
+
+func TestHeuristicRegion(t *testing.T) {
+	old := "class Cache:\n    def get(self, key):\n        return self.items[key]\n"
+	f := New("cache.py", old, strings.Replace(old, "items[key]", "items.get(key)", 1))
+	if len(f.Hunks) != 1 || f.Hunks[0].Region == nil || f.Hunks[0].Region.Name != "def get(self, key):" {
+		t.Errorf("expected enclosing python method, got %+v", f.Hunks[0].Region)
+	}
+}
+
+func TestDependencies(t *testing.T) {
+	old := "package main\n\nfunc run() {\n\tstart()\n}\n\n\n\n\n\n\nfunc start() {}\n"
+	// 第一段定义 retry，第二段使用它
+	new := strings.Replace(old, "\tstart()\n", "\tretry(start)\n", 1)
+	new = strings.Replace(new, "func start() {}\n", "func start() {}\n\nfunc retry(fn func()) { fn() }\n", 1)
+	f := New("main.go", old, new)
+	if len(f.Hunks) != 2 {
+		t.Fatalf("expected two hunks, got %d", len(f.Hunks))
+	}
+	if deps := Dependencies([]*File{f}); len(deps) != 0 {
+		t.Errorf("expected no dependencies when everything is accepted, got %v", deps)
+	}
+
+	f.Reject([]string{f.Hunks[1].ID})
+	deps := Dependencies([]*File{f})
+	if len(deps) != 1 || deps[0].Hunk != f.Hunks[0].ID || deps[0].Requires != f.Hunks[1].ID ||
+		deps[0].Identifier != "retry" || deps[0].Reason != "uses" {
+		t.Fatalf("expected first hunk to depend on the rejected definition, got %+v", deps)
+	}
+	// 拒绝使用方不影响定义
+	f.Reject([]string{f.Hunks[0].ID})
+	if deps := Dependencies([]*File{f}); len(deps) != 0 {
+		t.Errorf("expected no dependencies when the caller is rejected, got %v", deps)
+	}
+
+	// 删除仍被拒绝的段保留的代码使用的函数
+	removed := New("util.go", "func helper() {}\n", "")
+	caller := New("main.go", "func main() {\n\thelper()\n}\n", "func main() {\n}\n")
+	caller.Reject([]string{caller.Hunks[0].ID})
+	deps = Dependencies([]*File{removed, caller})
+	if len(deps) != 1 || deps[0].Reason != "removes" || deps[0].Identifier != "helper" || deps[0].RequiresPath != "main.go" {
+		t.Errorf("expected removal dependency on the kept caller, got %+v", deps)
+	}
+}
@@ -0,0 +1,215 @@
+package procmon
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Stats 表示一个进程及其所有子进程的资源占用
+type Stats struct {
+	PID         int       `json:"pid"`
+	CPUPercent  float64   `json:"cpu_percent"`
+	MemoryBytes uint64    `json:"memory_bytes"`
+	Children    int       `json:"children"`
+	SampledAt   time.Time `json:"sampled_at"`
+}
+
+// Limits 定义了资源上限，零值表示不限制
+type Limits struct {
+	MaxCPUPercent  float64
+	MaxMemoryBytes uint64
+	MaxChildren    int
+}
+
+// exceeded 返回超出的上限说明，未超出时返回空字符串
+func (l Limits) exceeded(s Stats) string {
+	switch {
+	case l.MaxCPUPercent > 0 && s.CPUPercent > l.MaxCPUPercent:
+		return fmt.Sprintf("cpu usage %.1f%% exceeds limit %.1f%%", s.CPUPercent, l.MaxCPUPercent)
+	case l.MaxMemoryBytes > 0 && s.MemoryBytes > l.MaxMemoryBytes:
+		return fmt.Sprintf("memory usage %d bytes exceeds limit %d bytes", s.MemoryBytes, l.MaxMemoryBytes)
+	case l.MaxChildren > 0 && s.Children > l.MaxChildren:
+		return fmt.Sprintf("child process count %d exceeds limit %d", s.Children, l.MaxChildren)
+	default:
+		return ""
+	}
+}
+
+// KillFunc 在进程因超出资源上限被终止后调用
+type KillFunc func(stats Stats, reason string)
+
+// tracked 表示一个被监控的进程
+type tracked struct {
+	pid        int
+	onKill     KillFunc
+	stats      Stats
+	lastTicks  uint64
+	lastSample time.Time
+}
+
+// Monitor 周期性采样被监控进程的资源占用，并终止超出上限的进程
+type Monitor struct {
+	mu       sync.Mutex
+	procs    map[string]*tracked
+	limits   Limits
+	interval time.Duration
+	running  bool
+}
+
+// NewMonitor 创建一个新的资源监控器
+func NewMonitor(limits Limits, interval time.Duration) *Monitor {
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	return &Monitor{
+		procs:    make(map[string]*tracked),
+		limits:   limits,
+		interval: interval,
+	}
+}
+
+// Track 开始监控一个进程，id 通常是服务器 ID 或命令 ID
+func (m *Monitor) Track(id string, pid int, onKill KillFunc) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.procs[id] = &tracked{
+		pid:    pid,
+		onKill: onKill,
+		stats:  Stats{PID: pid},
+	}
+
+	// 仅在有进程需要监控时运行采样循环
+	if !m.running {
+		m.running = true
+		go m.loop()
+	}
+}
+
+// Untrack 停止监控一个进程
+func (m *Monitor) Untrack(id string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.procs, id)
+}
+
+// Stats 返回所有被监控进程最近一次的采样结果
+func (m *Monitor) Stats() map[string]Stats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := make(map[string]Stats, len(m.procs))
+	for id, t := range m.procs {
+		result[id] = t.stats
+	}
+	return result
+}
+
+// loop 定期采样，直到没有需要监控的进程
+func (m *Monitor) loop() {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if !m.sample() {
+			return
+		}
+	}
+}
+
+// kill 表示一次因超限触发的终止操作
+type kill struct {
+	pids   []int
+	stats  Stats
+	reason string
+	onKill KillFunc
+}
+
+// sample 采样所有被监控的进程，返回 false 表示采样循环应当退出
+func (m *Monitor) sample() bool {
+	table, err := readProcessTable()
+
+	m.mu.Lock()
+	if len(m.procs) == 0 {
+		m.running = false
+		m.mu.Unlock()
+		return false
+	}
+	if err != nil {
+		m.mu.Unlock()
+		return true
+	}
+
+	now := time.Now()
+	var kills []kill
+	for id, t := range m.procs {
+		if _, alive := table[t.pid]; !alive {
+			continue
+		}
+
+		tree := descendants(table, t.pid)
+		var ticks, rss uint64
+		for _, pid := range tree {
+			ticks += table[pid].ticks
+			rss += table[pid].rss
+		}
+
+		cpu := 0.0
+		if !t.lastSample.IsZero() && ticks >= t.lastTicks {
+			elapsed := now.Sub(t.lastSample).Seconds()
+			if elapsed > 0 {
+				cpu = float64(ticks-t.lastTicks) / clockTicks / elapsed * 100
+			}
+		}
+		t.lastTicks = ticks
+		t.lastSample = now
+		t.stats = Stats{
+			PID:         t.pid,
+			CPUPercent:  cpu,
+			MemoryBytes: rss,
+			Children:    len(tree) - 1,
+			SampledAt:   now,
+		}
+
+		if reason := m.limits.exceeded(t.stats); reason != "" {
+			kills = append(kills, kill{pids: tree, stats: t.stats, reason: reason, onKill: t.onKill})
+			delete(m.procs, id)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, k := range kills {
+		for _, pid := range k.pids {
+			if p, err := os.FindProcess(pid); err == nil {
+				p.Kill()
+			}
+		}
+		if k.onKill != nil {
+			k.onKill(k.stats, k.reason)
+		}
+	}
+	return true
+}
+
+// procInfo 是进程表中的一项
+type procInfo struct {
+	ppid  int
+	ticks uint64
+	rss   uint64
+}
+
+// descendants 返回 pid 及其所有子孙进程
+func descendants(table map[int]procInfo, pid int) []int {
+	children := make(map[int][]int)
+	for p, info := range table {
+		children[info.ppid] = append(children[info.ppid], p)
+	}
+
+	result := []int{pid}
+	for i := 0; i < len(result); i++ {
+		result = append(result, children[result[i]]...)
+	}
+	return result
+}
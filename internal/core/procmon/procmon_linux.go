@@ -0,0 +1,70 @@
+package procmon
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// clockTicks 是 /proc 中 CPU 时间的单位（USER_HZ），Linux 上固定为 100
+const clockTicks = 100
+
+// readProcessTable 从 /proc 读取所有进程的父进程、CPU 时间和常驻内存
+func readProcessTable() (map[int]procInfo, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+
+	pageSize := uint64(os.Getpagesize())
+	table := make(map[int]procInfo, len(entries))
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join("/proc", entry.Name(), "stat"))
+		if err != nil {
+			// 进程可能已经退出
+			continue
+		}
+		info, ok := parseStat(string(data), pageSize)
+		if !ok {
+			continue
+		}
+		table[pid] = info
+	}
+	return table, nil
+}
+
+// parseStat 解析 /proc/<pid>/stat
+// 进程名可能包含空格和括号，因此从最后一个 ')' 之后开始按字段切分
+func parseStat(stat string, pageSize uint64) (procInfo, bool) {
+	end := strings.LastIndexByte(stat, ')')
+	if end < 0 {
+		return procInfo{}, false
+	}
+	fields := strings.Fields(stat[end+1:])
+	// fields[0] 对应第 3 个字段 state
+	if len(fields) < 22 {
+		return procInfo{}, false
+	}
+
+	ppid, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return procInfo{}, false
+	}
+	utime, _ := strconv.ParseUint(fields[11], 10, 64)
+	stime, _ := strconv.ParseUint(fields[12], 10, 64)
+	rss, _ := strconv.ParseInt(fields[21], 10, 64)
+	if rss < 0 {
+		rss = 0
+	}
+
+	return procInfo{
+		ppid:  ppid,
+		ticks: utime + stime,
+		rss:   uint64(rss) * pageSize,
+	}, true
+}
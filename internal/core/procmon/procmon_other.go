@@ -0,0 +1,13 @@
+//go:build !linux
+
+package procmon
+
+import "errors"
+
+// clockTicks 在非 Linux 平台上仅用于编译
+const clockTicks = 100
+
+// readProcessTable 在非 Linux 平台上暂不支持
+func readProcessTable() (map[int]procInfo, error) {
+	return nil, errors.New("process monitoring is only supported on linux")
+}
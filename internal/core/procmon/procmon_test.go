@@ -0,0 +1,41 @@
+package procmon
+
+import "testing"
+
+func TestDescendants(t *testing.T) {
+	table := map[int]procInfo{
+		1:  {ppid: 0},
+		10: {ppid: 1},
+		11: {ppid: 10},
+		12: {ppid: 10},
+		13: {ppid: 12},
+		20: {ppid: 1},
+	}
+
+	tree := descendants(table, 10)
+	if len(tree) != 4 {
+		t.Fatalf("expected 4 processes in tree, got %d: %v", len(tree), tree)
+	}
+	if tree[0] != 10 {
+		t.Errorf("expected root pid first, got %d", tree[0])
+	}
+	for _, pid := range tree {
+		if pid == 20 || pid == 1 {
+			t.Errorf("unexpected pid %d in tree", pid)
+		}
+	}
+}
+
+func TestLimitsExceeded(t *testing.T) {
+	limits := Limits{MaxMemoryBytes: 1024, MaxChildren: 2}
+
+	if reason := limits.exceeded(Stats{MemoryBytes: 512, Children: 1, CPUPercent: 99}); reason != "" {
+		t.Errorf("expected no limit exceeded, got %q", reason)
+	}
+	if reason := limits.exceeded(Stats{MemoryBytes: 2048}); reason == "" {
+		t.Error("expected memory limit to be exceeded")
+	}
+	if reason := limits.exceeded(Stats{Children: 3}); reason == "" {
+		t.Error("expected child process limit to be exceeded")
+	}
+}
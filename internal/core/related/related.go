@@ -0,0 +1,293 @@
+package related
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 各类关联的权重
+const (
+	scoreTestPair  = 5.0
+	scoreImport    = 3.0
+	scoreCoEdit    = 1.0 // 每次共同修改
+	scoreSamePkg   = 0.5
+	maxCoEditScore = 4.0
+)
+
+// coEditTTL 是 git 共同修改统计的缓存时间
+const coEditTTL = 5 * time.Minute
+
+// coEditCommits 是统计共同修改时读取的最近提交数
+const coEditCommits = 300
+
+// Candidate 是一个相关文件及其得分
+type Candidate struct {
+	Path    string   `json:"path"`
+	Score   float64  `json:"score"`
+	Reasons []string `json:"reasons"` // test_pair、import、co_edit、same_package
+}
+
+// Finder 根据同包、导入、测试与实现配对和 git 历史中的共同修改推荐相关文件
+type Finder struct {
+	root string
+
+	mu       sync.Mutex
+	coEdits  map[string]map[string]int // 文件 -> 共同修改的文件 -> 次数
+	loadedAt time.Time
+}
+
+// NewFinder 创建相关文件推荐器，root 为仓库根目录
+func NewFinder(root string) *Finder {
+	return &Finder{root: root}
+}
+
+// Root 返回仓库根目录
+func (f *Finder) Root() string {
+	return f.root
+}
+
+// Find 返回 path 的相关文件，按得分从高到低排列，最多 limit 个
+func (f *Finder) Find(ctx context.Context, path string, limit int) ([]Candidate, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	rel, err := filepath.Rel(f.root, abs)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		rel = abs
+	}
+
+	scores := make(map[string]*Candidate)
+	add := func(file string, score float64, reason string) {
+		file = filepath.Clean(file)
+		if file == rel || file == abs {
+			return
+		}
+		if info, err := os.Stat(f.abs(file)); err != nil || info.IsDir() {
+			return
+		}
+		c, ok := scores[file]
+		if !ok {
+			c = &Candidate{Path: file}
+			scores[file] = c
+		}
+		c.Score += score
+		for _, r := range c.Reasons {
+			if r == reason {
+				return
+			}
+		}
+		c.Reasons = append(c.Reasons, reason)
+	}
+
+	for _, pair := range testPairs(rel) {
+		add(pair, scoreTestPair, "test_pair")
+	}
+	for _, file := range f.samePackage(rel) {
+		add(file, scoreSamePkg, "same_package")
+	}
+	if src, err := os.ReadFile(abs); err == nil {
+		for _, file := range f.imports(rel, src) {
+			add(file, scoreImport, "import")
+		}
+	}
+	for file, n := range f.coEdited(ctx, rel) {
+		add(file, min(float64(n)*scoreCoEdit, maxCoEditScore), "co_edit")
+	}
+
+	candidates := make([]Candidate, 0, len(scores))
+	for _, c := range scores {
+		candidates = append(candidates, *c)
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].Score != candidates[j].Score {
+			return candidates[i].Score > candidates[j].Score
+		}
+		return candidates[i].Path < candidates[j].Path
+	})
+	if limit > 0 && len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+	return candidates, nil
+}
+
+// abs 将相对仓库根目录的路径转换为绝对路径
+func (f *Finder) abs(file string) string {
+	if filepath.IsAbs(file) {
+		return file
+	}
+	return filepath.Join(f.root, file)
+}
+
+// testPairs 返回测试文件与实现文件的对应文件名
+func testPairs(file string) []string {
+	dir, base := filepath.Split(file)
+	ext := filepath.Ext(base)
+	name := strings.TrimSuffix(base, ext)
+
+	var pairs []string
+	switch {
+	case strings.HasSuffix(name, "_test"):
+		pairs = append(pairs, strings.TrimSuffix(name, "_test")+ext)
+	case strings.HasPrefix(name, "test_"):
+		pairs = append(pairs, strings.TrimPrefix(name, "test_")+ext)
+	case strings.HasSuffix(name, ".test"), strings.HasSuffix(name, ".spec"):
+		pairs = append(pairs, strings.TrimSuffix(strings.TrimSuffix(name, ".test"), ".spec")+ext)
+	default:
+		pairs = append(pairs, name+"_test"+ext, "test_"+name+ext, name+".test"+ext, name+".spec"+ext)
+	}
+	for i, p := range pairs {
+		pairs[i] = filepath.Join(dir, p)
+	}
+	return pairs
+}
+
+// samePackage 返回同一目录下扩展名相同的文件
+func (f *Finder) samePackage(file string) []string {
+	dir := filepath.Dir(file)
+	entries, err := os.ReadDir(f.abs(dir))
+	if err != nil {
+		return nil
+	}
+	var files []string
+	for _, e := range entries {
+		if !e.IsDir() && filepath.Ext(e.Name()) == filepath.Ext(file) {
+			files = append(files, filepath.Join(dir, e.Name()))
+		}
+	}
+	return files
+}
+
+var (
+	goImport       = regexp.MustCompile(`^\s*(?:import\s+)?(?:[\w.]+\s+)?"([^"]+)"`)
+	relativeImport = regexp.MustCompile(`(?:from\s+|import\s+|require\(\s*)['"](\.{1,2}/[^'"]+)['"]`)
+	pythonImport   = regexp.MustCompile(`^\s*from\s+(\.+)([\w.]*)\s+import\b`)
+)
+
+// imports 解析源码中引用的本仓库文件：Go 的模块内包、JS/TS 的相对路径和 Python 的相对导入
+func (f *Finder) imports(file string, src []byte) []string {
+	dir := filepath.Dir(file)
+	var files []string
+	module := f.goModule()
+
+	inImportBlock := false
+	scanner := bufio.NewScanner(bytes.NewReader(src))
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch filepath.Ext(file) {
+		case ".go":
+			trimmed := strings.TrimSpace(line)
+			if strings.HasPrefix(trimmed, "import (") {
+				inImportBlock = true
+				continue
+			}
+			if inImportBlock && trimmed == ")" {
+				inImportBlock = false
+				continue
+			}
+			if !inImportBlock && !strings.HasPrefix(trimmed, "import ") {
+				continue
+			}
+			if m := goImport.FindStringSubmatch(strings.TrimPrefix(trimmed, "import ")); m != nil && module != "" && strings.HasPrefix(m[1], module+"/") {
+				pkgDir := strings.TrimPrefix(m[1], module+"/")
+				for _, pkgFile := range f.samePackage(filepath.Join(pkgDir, "x.go")) {
+					if !strings.HasSuffix(pkgFile, "_test.go") {
+						files = append(files, pkgFile)
+					}
+				}
+			}
+		case ".py":
+			if m := pythonImport.FindStringSubmatch(line); m != nil {
+				base := dir
+				for i := 1; i < len(m[1]); i++ {
+					base = filepath.Dir(base)
+				}
+				files = append(files, filepath.Join(base, strings.ReplaceAll(m[2], ".", "/"))+".py")
+			}
+		default:
+			for _, m := range relativeImport.FindAllStringSubmatch(line, -1) {
+				target := filepath.Join(dir, m[1])
+				if filepath.Ext(target) != "" {
+					files = append(files, target)
+					continue
+				}
+				for _, ext := range []string{".ts", ".tsx", ".js", ".jsx", "/index.ts", "/index.js"} {
+					files = append(files, target+ext)
+				}
+			}
+		}
+	}
+	return files
+}
+
+// goModule 读取 go.mod 中的模块路径
+func (f *Finder) goModule() string {
+	data, err := os.ReadFile(filepath.Join(f.root, "go.mod"))
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, "module ") {
+			return strings.TrimSpace(strings.TrimPrefix(line, "module "))
+		}
+	}
+	return ""
+}
+
+// coEdited 返回 git 历史中与 file 在同一提交中修改过的文件及次数
+func (f *Finder) coEdited(ctx context.Context, file string) map[string]int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.coEdits == nil || time.Since(f.loadedAt) > coEditTTL {
+		f.coEdits = loadCoEdits(ctx, f.root)
+		f.loadedAt = time.Now()
+	}
+	return f.coEdits[file]
+}
+
+// loadCoEdits 读取最近的提交，统计文件两两共同修改的次数，不是 git 仓库时返回空结果
+func loadCoEdits(ctx context.Context, root string) map[string]map[string]int {
+	coEdits := make(map[string]map[string]int)
+	cmd := exec.CommandContext(ctx, "git", "log", "-n", strconv.Itoa(coEditCommits), "--name-only", "--format=@@")
+	cmd.Dir = root
+	output, err := cmd.Output()
+	if err != nil {
+		return coEdits
+	}
+
+	for _, commit := range strings.Split(string(output), "@@") {
+		var files []string
+		for _, line := range strings.Split(commit, "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				files = append(files, line)
+			}
+		}
+		// 大提交（如格式化、重命名）中的共同修改不代表关联
+		if len(files) < 2 || len(files) > 20 {
+			continue
+		}
+		for _, a := range files {
+			for _, b := range files {
+				if a == b {
+					continue
+				}
+				if coEdits[a] == nil {
+					coEdits[a] = make(map[string]int)
+				}
+				coEdits[a][b]++
+			}
+		}
+	}
+	return coEdits
+}
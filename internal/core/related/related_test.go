@@ -0,0 +1,56 @@
+package related
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFinderFind(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"go.mod":              "module example.com/demo\n\ngo 1.24\n",
+		"svc/service.go":      "package svc\n\nimport (\n\t\"fmt\"\n\n\t\"example.com/demo/store\"\n)\n",
+		"svc/service_test.go": "package svc\n",
+		"svc/helpers.go":      "package svc\n",
+		"store/store.go":      "package store\n",
+		"store/store_test.go": "package store\n",
+		"web/app.ts":          "import { api } from './api'\n",
+		"web/api.ts":          "export const api = 1\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f := NewFinder(root)
+	candidates, err := f.Find(context.Background(), filepath.Join(root, "svc/service.go"), 0)
+	if err != nil {
+		t.Fatalf("failed to find related files: %v", err)
+	}
+	got := make(map[string][]string)
+	for _, c := range candidates {
+		got[c.Path] = c.Reasons
+	}
+	if len(candidates) == 0 || candidates[0].Path != filepath.Join("svc", "service_test.go") {
+		t.Errorf("expected test file to rank first, got %+v", candidates)
+	}
+	if _, ok := got[filepath.Join("store", "store.go")]; !ok {
+		t.Errorf("expected imported package file, got %+v", candidates)
+	}
+	if _, ok := got[filepath.Join("store", "store_test.go")]; ok {
+		t.Errorf("expected tests of imported packages to be excluded, got %+v", candidates)
+	}
+	if _, ok := got[filepath.Join("svc", "helpers.go")]; !ok {
+		t.Errorf("expected same package file, got %+v", candidates)
+	}
+
+	candidates, _ = f.Find(context.Background(), filepath.Join(root, "web/app.ts"), 1)
+	if len(candidates) != 1 || candidates[0].Path != filepath.Join("web", "api.ts") {
+		t.Errorf("expected relative import, got %+v", candidates)
+	}
+}
@@ -0,0 +1,350 @@
+package repomap
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/liangsj/vimcoplit/internal/core/langdetect"
+	"github.com/liangsj/vimcoplit/internal/models"
+)
+
+// sourceExts 是参与生成仓库地图的源码扩展名
+var sourceExts = map[string]bool{
+	".go": true, ".py": true, ".js": true, ".jsx": true, ".ts": true, ".tsx": true,
+	".rs": true, ".java": true, ".rb": true, ".c": true, ".h": true, ".cpp": true, ".lua": true, ".vim": true,
+}
+
+// skipDirs 是生成仓库地图时跳过的目录
+var skipDirs = map[string]bool{
+	"node_modules": true, "vendor": true, "dist": true, "build": true, "target": true, "__pycache__": true,
+}
+
+// Symbol 是文件中的一个导出符号
+type Symbol struct {
+	Name string `json:"name"`
+	Doc  string `json:"doc,omitempty"` // 文档注释的第一句
+}
+
+// File 是单个文件的符号摘要
+type File struct {
+	Path    string    `json:"path"`
+	ModTime time.Time `json:"mod_time"`
+	Size    int64     `json:"size"`
+	Package string    `json:"package,omitempty"` // Go 包的文档注释第一句
+	Symbols []Symbol  `json:"symbols"`
+}
+
+// Map 是压缩后的仓库概览
+type Map struct {
+	Text      string    `json:"text"`
+	Tokens    int       `json:"tokens"`
+	Files     int       `json:"files"`
+	Detail    string    `json:"detail"` // full、names 或 dirs，超出预算时逐级压缩
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Generator 生成并缓存仓库地图，刷新时只重新解析修改过的文件
+type Generator struct {
+	mu        sync.Mutex
+	root      string
+	cachePath string
+	maxTokens int
+	files     map[string]*File
+	current   *Map
+	ignore    func(rel string) bool
+}
+
+// New 创建仓库地图生成器，cachePath 保存每个文件的符号摘要，maxTokens 为地图的 token 预算
+func New(root, cachePath string, maxTokens int) *Generator {
+	g := &Generator{
+		root:      root,
+		cachePath: cachePath,
+		maxTokens: maxTokens,
+		files:     make(map[string]*File),
+	}
+	if data, err := os.ReadFile(cachePath); err == nil {
+		json.Unmarshal(data, &g.files)
+	}
+	return g
+}
+
+// SetIgnore 设置忽略规则，匹配的文件和目录不会出现在地图中，下次获取地图时重新生成
+func (g *Generator) SetIgnore(ignore func(rel string) bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.ignore = ignore
+	g.current = nil
+}
+
+// Current 返回最近一次生成的地图，尚未生成时立即生成
+func (g *Generator) Current() (*Map, error) {
+	g.mu.Lock()
+	current := g.current
+	g.mu.Unlock()
+	if current != nil {
+		return current, nil
+	}
+	return g.Refresh()
+}
+
+// Refresh 扫描仓库，重新解析修改过的文件并生成地图
+func (g *Generator) Refresh() (*Map, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	seen := make(map[string]bool)
+	changed := false
+	err := filepath.WalkDir(g.root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		name := d.Name()
+		rel, _ := filepath.Rel(g.root, path)
+		if d.IsDir() {
+			if path != g.root && (strings.HasPrefix(name, ".") || skipDirs[name] || g.ignored(rel)) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if g.ignored(rel) {
+			return nil
+		}
+		if !sourceExts[filepath.Ext(name)] || strings.HasSuffix(name, "_test.go") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		seen[rel] = true
+		if f, ok := g.files[rel]; ok && f.ModTime.Equal(info.ModTime()) && f.Size == info.Size() {
+			return nil
+		}
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		f := summarize(rel, src)
+		f.ModTime, f.Size = info.ModTime(), info.Size()
+		g.files[rel] = f
+		changed = true
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan repository: %v", err)
+	}
+	for rel := range g.files {
+		if !seen[rel] {
+			delete(g.files, rel)
+			changed = true
+		}
+	}
+
+	if changed {
+		if err := g.save(); err != nil {
+			return nil, err
+		}
+	}
+	if changed || g.current == nil {
+		g.current = g.render()
+	}
+	return g.current, nil
+}
+
+// ignored 判断文件是否匹配忽略规则，调用方需持有锁
+func (g *Generator) ignored(rel string) bool {
+	return g.ignore != nil && g.ignore(rel)
+}
+
+// save 保存符号摘要缓存，调用方需持有锁
+func (g *Generator) save() error {
+	data, err := json.Marshal(g.files)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(g.cachePath), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(g.cachePath, data, 0644)
+}
+
+// render 按目录生成地图文本，超出预算时依次去掉说明、去掉符号，调用方需持有锁
+func (g *Generator) render() *Map {
+	dirs := make(map[string][]*File)
+	for _, f := range g.files {
+		dir := filepath.Dir(f.Path)
+		dirs[dir] = append(dirs[dir], f)
+	}
+	names := make([]string, 0, len(dirs))
+	for dir, files := range dirs {
+		names = append(names, dir)
+		sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	}
+	sort.Strings(names)
+
+	var text string
+	var detail string
+	for _, detail = range []string{"full", "names", "dirs"} {
+		text = renderLevel(names, dirs, detail)
+		if g.maxTokens <= 0 || models.EstimateTokens(text) <= g.maxTokens {
+			break
+		}
+	}
+	return &Map{
+		Text:      text,
+		Tokens:    models.EstimateTokens(text),
+		Files:     len(g.files),
+		Detail:    detail,
+		UpdatedAt: time.Now(),
+	}
+}
+
+// renderLevel 按指定的详细程度生成地图文本
+func renderLevel(dirs []string, files map[string][]*File, detail string) string {
+	var b strings.Builder
+	for _, dir := range dirs {
+		b.WriteString(dir + "/")
+		for _, f := range files[dir] {
+			if f.Package != "" {
+				b.WriteString(" - " + f.Package)
+				break
+			}
+		}
+		b.WriteString("\n")
+		if detail == "dirs" {
+			continue
+		}
+		for _, f := range files[dir] {
+			b.WriteString("  " + filepath.Base(f.Path))
+			if len(f.Symbols) == 0 {
+				b.WriteString("\n")
+				continue
+			}
+			if detail == "names" {
+				names := make([]string, len(f.Symbols))
+				for i, s := range f.Symbols {
+					names[i] = s.Name
+				}
+				b.WriteString(": " + strings.Join(names, ", ") + "\n")
+				continue
+			}
+			b.WriteString("\n")
+			for _, s := range f.Symbols {
+				b.WriteString("    " + s.Name)
+				if s.Doc != "" {
+					b.WriteString(" - " + s.Doc)
+				}
+				b.WriteString("\n")
+			}
+		}
+	}
+	return b.String()
+}
+
+// summarize 提取文件的导出符号
+func summarize(path string, src []byte) *File {
+	if langdetect.Detect(path, src) == "go" {
+		if f := summarizeGo(path, src); f != nil {
+			return f
+		}
+	}
+	return summarizeGeneric(path, src)
+}
+
+// summarizeGo 使用 go/parser 提取导出的类型、函数和方法
+func summarizeGo(path string, src []byte) *File {
+	file, err := parser.ParseFile(token.NewFileSet(), path, src, parser.ParseComments)
+	if err != nil {
+		return nil
+	}
+	f := &File{Path: path, Package: firstSentence("Package "+file.Name.Name, file.Doc.Text())}
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if !d.Name.IsExported() {
+				continue
+			}
+			name := d.Name.Name
+			if d.Recv != nil && len(d.Recv.List) > 0 {
+				recv := receiverName(d.Recv.List[0].Type)
+				if !ast.IsExported(recv) {
+					continue
+				}
+				name = recv + "." + name
+			}
+			f.Symbols = append(f.Symbols, Symbol{Name: name, Doc: firstSentence(d.Name.Name, d.Doc.Text())})
+		case *ast.GenDecl:
+			if d.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range d.Specs {
+				ts := spec.(*ast.TypeSpec)
+				if !ts.Name.IsExported() {
+					continue
+				}
+				doc := ts.Doc.Text()
+				if doc == "" {
+					doc = d.Doc.Text()
+				}
+				f.Symbols = append(f.Symbols, Symbol{Name: ts.Name.Name, Doc: firstSentence(ts.Name.Name, doc)})
+			}
+		}
+	}
+	return f
+}
+
+// receiverName 返回方法接收者的类型名
+func receiverName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return receiverName(t.X)
+	case *ast.IndexExpr:
+		return receiverName(t.X)
+	case *ast.Ident:
+		return t.Name
+	}
+	return ""
+}
+
+// genericSymbol 匹配其他语言中顶层的函数和类定义
+var genericSymbol = regexp.MustCompile(`^(?:export\s+)?(?:default\s+)?(?:pub\s+)?(?:async\s+)?(?:def|class|function|fn|struct|trait|interface|module)\s+([A-Za-z_][\w]*)`)
+
+// summarizeGeneric 提取其他语言中顶层的定义，以下划线开头的视为私有
+func summarizeGeneric(path string, src []byte) *File {
+	f := &File{Path: path}
+	scanner := bufio.NewScanner(bytes.NewReader(src))
+	for scanner.Scan() {
+		m := genericSymbol.FindStringSubmatch(scanner.Text())
+		if m == nil || strings.HasPrefix(m[1], "_") {
+			continue
+		}
+		f.Symbols = append(f.Symbols, Symbol{Name: m[1]})
+	}
+	return f
+}
+
+// firstSentence 返回文档注释的第一句，去掉开头重复的符号名
+func firstSentence(name, doc string) string {
+	doc = strings.TrimSpace(strings.SplitN(doc, "\n", 2)[0])
+	doc = strings.TrimSpace(strings.TrimPrefix(doc, name))
+	for _, sep := range []string{"。", ". "} {
+		if i := strings.Index(doc, sep); i > 0 {
+			doc = doc[:i]
+		}
+	}
+	return strings.TrimSuffix(doc, ".")
+}
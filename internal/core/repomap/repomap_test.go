@@ -0,0 +1,68 @@
+package repomap
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGeneratorRefresh(t *testing.T) {
+	root := t.TempDir()
+	write := func(name, content string) {
+		path := filepath.Join(root, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("store/store.go", "// Package store 保存数据\npackage store\n\n// Store 是数据存储\ntype Store struct{}\n\n// Get 读取数据。\nfunc (s *Store) Get() {}\n\nfunc helper() {}\n")
+	write("store/store_test.go", "package store\n\nfunc TestX() {}\n")
+	write("web/app.py", "class App:\n    pass\n\ndef _private():\n    pass\n\ndef serve():\n    pass\n")
+	write(".git/config.go", "package git\n")
+
+	cachePath := filepath.Join(t.TempDir(), "repomap.json")
+	g := New(root, cachePath, 0)
+	m, err := g.Refresh()
+	if err != nil {
+		t.Fatalf("failed to generate repo map: %v", err)
+	}
+	want := "store/ - 保存数据\n  store.go\n    Store - 是数据存储\n    Store.Get - 读取数据\nweb/\n  app.py\n    App\n    serve\n"
+	if m.Text != want || m.Files != 2 || m.Detail != "full" {
+		t.Errorf("unexpected map %+v, want text %q", m, want)
+	}
+
+	// 预算不足时压缩为只有符号名
+	g = New(root, cachePath, 25)
+	m, _ = g.Refresh()
+	if m.Detail != "names" || !strings.Contains(m.Text, "store.go: Store, Store.Get") {
+		t.Errorf("expected compressed map, got %+v", m)
+	}
+
+	// 只有修改过的文件会重新解析
+	write("web/app.py", "def serve():\n    pass\n\ndef stop():\n    pass\n")
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(filepath.Join(root, "web/app.py"), later, later)
+	m, _ = New(root, cachePath, 0).Refresh()
+	if !strings.Contains(m.Text, "stop") || strings.Contains(m.Text, "App") {
+		t.Errorf("expected modified file to be refreshed, got %q", m.Text)
+	}
+}
+
+func TestGeneratorIgnore(t *testing.T) {
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, "gen"), 0755)
+	os.WriteFile(filepath.Join(root, "main.go"), []byte("package main\n\nfunc Run() {}\n"), 0644)
+	os.WriteFile(filepath.Join(root, "gen", "gen.go"), []byte("package gen\n\nfunc Generated() {}\n"), 0644)
+
+	g := New(root, filepath.Join(t.TempDir(), "repomap.json"), 0)
+	g.SetIgnore(func(rel string) bool { return rel == "gen" })
+	m, err := g.Current()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Files != 1 || strings.Contains(m.Text, "Generated") {
+		t.Errorf("expected ignored directory to be skipped, got %q", m.Text)
+	}
+}
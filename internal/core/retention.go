@@ -0,0 +1,150 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/liangsj/vimcoplit/internal/core/archive"
+)
+
+// retentionInterval 是执行保留策略的间隔
+const retentionInterval = time.Hour
+
+// RetentionReport 描述一次执行保留策略的结果
+type RetentionReport struct {
+	ArchivedConversations int   `json:"archived_conversations"`
+	ArchivedTasks         int   `json:"archived_tasks"`
+	Purged                int   `json:"purged"`
+	CollectedAttachments  int   `json:"collected_attachments"`
+	FreedBytes            int64 `json:"freed_bytes"`
+}
+
+// ApplyRetention 按保留策略归档不活跃的对话和已结束的任务，删除过期的归档，
+// 最后回收不再被引用的附件
+func (s *serviceImpl) ApplyRetention(ctx context.Context) (*RetentionReport, error) {
+	policy := s.cfg.Retention
+	report := &RetentionReport{}
+	now := time.Now()
+	var err error
+
+	if policy.ArchiveAfterDays > 0 {
+		before := now.AddDate(0, 0, -policy.ArchiveAfterDays)
+		report.ArchivedConversations, err = s.conversations.archive(before, func(conv *Conversation) error {
+			return s.putArchive(archive.KindConversation, conv.ID, conv.Title, conv.UpdatedAt, conv)
+		})
+		if err != nil {
+			return report, fmt.Errorf("failed to archive conversations: %v", err)
+		}
+		report.ArchivedTasks, err = s.tasks.archive(before.Unix(), func(task *Task) error {
+			title := task.Name
+			if title == "" {
+				title = task.Description
+			}
+			return s.putArchive(archive.KindTask, task.ID, title, time.Unix(task.UpdatedAt, 0), task)
+		})
+		if err != nil {
+			return report, fmt.Errorf("failed to archive tasks: %v", err)
+		}
+	}
+	if policy.PurgeAfterDays > 0 {
+		report.Purged, err = s.archive.Purge(now.AddDate(0, 0, -policy.PurgeAfterDays))
+		if err != nil {
+			return report, fmt.Errorf("failed to purge archives: %v", err)
+		}
+	}
+	collection, err := s.CollectAttachments(ctx)
+	if err != nil {
+		return report, fmt.Errorf("failed to collect attachments: %v", err)
+	}
+	report.CollectedAttachments, report.FreedBytes = collection.Removed, collection.Freed
+	return report, nil
+}
+
+// putArchive 将记录序列化后保存到归档
+func (s *serviceImpl) putArchive(kind archive.Kind, id, title string, updatedAt time.Time, record interface{}) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return s.archive.Put(&archive.Entry{
+		Kind:      kind,
+		ID:        id,
+		Title:     title,
+		UpdatedAt: updatedAt,
+		Data:      data,
+	})
+}
+
+// ListArchive 按归档时间倒序列出归档，不包含数据
+func (s *serviceImpl) ListArchive(ctx context.Context) []*archive.Entry {
+	return s.archive.List()
+}
+
+// RestoreArchive 将归档的对话或任务放回活动存储并删除归档
+func (s *serviceImpl) RestoreArchive(ctx context.Context, kind archive.Kind, id string) (*archive.Entry, error) {
+	entry, err := s.archive.Get(kind, id)
+	if err != nil {
+		return nil, err
+	}
+	switch entry.Kind {
+	case archive.KindConversation:
+		var conv Conversation
+		if err := json.Unmarshal(entry.Data, &conv); err != nil {
+			return nil, fmt.Errorf("invalid archived conversation: %v", err)
+		}
+		conv.ID = entry.ID
+		if conv.Messages == nil {
+			conv.Messages = []*Message{}
+		}
+		err = s.conversations.restore(&conv)
+	case archive.KindTask:
+		var task Task
+		if err := json.Unmarshal(entry.Data, &task); err != nil {
+			return nil, fmt.Errorf("invalid archived task: %v", err)
+		}
+		task.ID = entry.ID
+		err = s.tasks.restore(&task)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := s.archive.Delete(kind, id); err != nil {
+		log.Printf("删除已恢复的归档失败: %v\n", err)
+	}
+	entry.Data = nil
+	return entry, nil
+}
+
+// ExportArchive 将所有归档以导出格式写入 w，返回导出的数量
+func (s *serviceImpl) ExportArchive(ctx context.Context, w io.Writer) (int, error) {
+	return s.archive.Export(w)
+}
+
+// ImportArchive 导入之前导出的归档，导入后可以逐条恢复，返回导入的数量
+func (s *serviceImpl) ImportArchive(ctx context.Context, r io.Reader) (int, error) {
+	return s.archive.Import(r)
+}
+
+// enforceRetention 启动时和之后每隔 retentionInterval 执行一次保留策略
+func (s *serviceImpl) enforceRetention(ctx context.Context) {
+	ticker := time.NewTicker(retentionInterval)
+	defer ticker.Stop()
+	for {
+		report, err := s.ApplyRetention(ctx)
+		if err != nil {
+			log.Printf("执行保留策略失败: %v\n", err)
+		} else if report.ArchivedConversations+report.ArchivedTasks+report.Purged+report.CollectedAttachments > 0 {
+			log.Printf("已归档 %d 个对话和 %d 个任务，删除 %d 条过期归档，回收 %d 个附件\n",
+				report.ArchivedConversations, report.ArchivedTasks, report.Purged, report.CollectedAttachments)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
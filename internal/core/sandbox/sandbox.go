@@ -0,0 +1,173 @@
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Backend 表示命令执行后端类型
+type Backend string
+
+const (
+	BackendHost   Backend = "host"
+	BackendDocker Backend = "docker"
+)
+
+// Config 定义了沙箱配置
+type Config struct {
+	Backend   Backend
+	Image     string
+	Workspace string
+	Network   string
+	DockerBin string
+}
+
+// Spec 描述一次要执行的命令
+type Spec struct {
+	Name string
+	Args []string
+	Dir  string
+	Env  []string
+}
+
+// Runner 定义了命令执行后端接口
+type Runner interface {
+	// Command 根据 Spec 构造待执行的命令
+	Command(ctx context.Context, spec *Spec) (*exec.Cmd, error)
+
+	// Backend 返回执行后端类型
+	Backend() Backend
+}
+
+// New 根据配置创建执行后端
+func New(cfg Config) (Runner, error) {
+	switch cfg.Backend {
+	case "", BackendHost:
+		return &HostRunner{}, nil
+	case BackendDocker:
+		return NewDockerRunner(cfg)
+	default:
+		return nil, fmt.Errorf("unsupported sandbox backend: %s", cfg.Backend)
+	}
+}
+
+// HostRunner 直接在宿主机上执行命令
+type HostRunner struct{}
+
+// Command 构造宿主机命令
+func (r *HostRunner) Command(ctx context.Context, spec *Spec) (*exec.Cmd, error) {
+	cmd := exec.CommandContext(ctx, spec.Name, spec.Args...)
+	cmd.Dir = spec.Dir
+	if len(spec.Env) > 0 {
+		cmd.Env = append(os.Environ(), spec.Env...)
+	}
+	return cmd, nil
+}
+
+// Backend 返回执行后端类型
+func (r *HostRunner) Backend() Backend {
+	return BackendHost
+}
+
+// DockerRunner 在容器中执行命令，只挂载工作区目录
+type DockerRunner struct {
+	image     string
+	workspace string
+	network   string
+	dockerBin string
+}
+
+// NewDockerRunner 创建一个新的容器执行后端
+func NewDockerRunner(cfg Config) (*DockerRunner, error) {
+	if cfg.Image == "" {
+		return nil, errors.New("docker sandbox requires an image")
+	}
+
+	workspace := cfg.Workspace
+	if workspace == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve workspace: %v", err)
+		}
+		workspace = wd
+	}
+	workspace, err := filepath.Abs(workspace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve workspace: %v", err)
+	}
+
+	network := cfg.Network
+	if network == "" {
+		network = "none"
+	}
+	dockerBin := cfg.DockerBin
+	if dockerBin == "" {
+		dockerBin = "docker"
+	}
+
+	return &DockerRunner{
+		image:     cfg.Image,
+		workspace: workspace,
+		network:   network,
+		dockerBin: dockerBin,
+	}, nil
+}
+
+// Command 构造 docker run 命令
+// 工作目录必须位于工作区内，容器内路径与宿主机路径保持一致
+func (r *DockerRunner) Command(ctx context.Context, spec *Spec) (*exec.Cmd, error) {
+	dir := r.workspace
+	if spec.Dir != "" {
+		abs, err := filepath.Abs(spec.Dir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve work dir: %v", err)
+		}
+		if !withinDir(r.workspace, abs) {
+			return nil, fmt.Errorf("work dir %s is outside of workspace %s", abs, r.workspace)
+		}
+		dir = abs
+	}
+
+	name := "vimcoplit-" + uuid.New().String()
+	args := []string{
+		"run", "--rm", "-i", "--init",
+		"--name", name,
+		"--network", r.network,
+		"-v", r.workspace + ":" + r.workspace,
+		"-w", dir,
+	}
+	for _, env := range spec.Env {
+		args = append(args, "-e", env)
+	}
+	args = append(args, r.image, spec.Name)
+	args = append(args, spec.Args...)
+
+	cmd := exec.CommandContext(ctx, r.dockerBin, args...)
+	// 取消时同时停止容器，仅杀死 docker 客户端进程不会停止容器
+	cmd.Cancel = func() error {
+		exec.Command(r.dockerBin, "kill", name).Run()
+		return cmd.Process.Kill()
+	}
+	return cmd, nil
+}
+
+// Backend 返回执行后端类型
+func (r *DockerRunner) Backend() Backend {
+	return BackendDocker
+}
+
+// withinDir 判断 path 是否位于 dir 内
+func withinDir(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
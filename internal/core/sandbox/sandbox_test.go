@@ -0,0 +1,66 @@
+package sandbox
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDockerRunnerCommand(t *testing.T) {
+	workspace := t.TempDir()
+	runner, err := New(Config{
+		Backend:   BackendDocker,
+		Image:     "golang:1.24",
+		Workspace: workspace,
+	})
+	if err != nil {
+		t.Fatalf("failed to create docker runner: %v", err)
+	}
+
+	cmd, err := runner.Command(context.Background(), &Spec{
+		Name: "go",
+		Args: []string{"test", "./..."},
+		Dir:  filepath.Join(workspace, "pkg"),
+		Env:  []string{"GOFLAGS=-mod=mod"},
+	})
+	if err != nil {
+		t.Fatalf("failed to build command: %v", err)
+	}
+
+	args := strings.Join(cmd.Args, " ")
+	for _, want := range []string{
+		"run --rm -i",
+		"--network none",
+		"-v " + workspace + ":" + workspace,
+		"-w " + filepath.Join(workspace, "pkg"),
+		"-e GOFLAGS=-mod=mod",
+		"golang:1.24 go test ./...",
+	} {
+		if !strings.Contains(args, want) {
+			t.Errorf("expected args to contain %q, got %q", want, args)
+		}
+	}
+}
+
+func TestDockerRunnerRejectsOutsideWorkspace(t *testing.T) {
+	runner, err := New(Config{
+		Backend:   BackendDocker,
+		Image:     "golang:1.24",
+		Workspace: t.TempDir(),
+	})
+	if err != nil {
+		t.Fatalf("failed to create docker runner: %v", err)
+	}
+
+	if _, err := runner.Command(context.Background(), &Spec{Name: "ls", Dir: os.TempDir()}); err == nil {
+		t.Error("expected error for work dir outside of workspace")
+	}
+}
+
+func TestNewUnsupportedBackend(t *testing.T) {
+	if _, err := New(Config{Backend: "vm"}); err == nil {
+		t.Error("expected error for unsupported backend")
+	}
+}
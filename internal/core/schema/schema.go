@@ -0,0 +1,294 @@
+// Package schema 实现结构化生成使用的 JSON Schema 子集：解析、校验模型输出，
+// 从模型输出中提取 JSON 以及构造要求模型修正输出的提示词
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// maxErrors 是一次校验最多返回的错误数，避免修正提示词过长
+const maxErrors = 20
+
+// Schema 是支持的 JSON Schema 子集：type、properties、required、additionalProperties、
+// items、enum、minItems、maxItems 和 minLength，不认识的关键字被忽略
+type Schema struct {
+	Type                 Types              `json:"type,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *bool              `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+}
+
+// Types 是 type 关键字的取值，可以是单个类型或类型列表
+type Types []string
+
+// UnmarshalJSON 同时接受字符串和字符串数组
+func (t *Types) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*t = Types{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return errors.New("type must be a string or an array of strings")
+	}
+	*t = list
+	return nil
+}
+
+// MarshalJSON 单个类型输出为字符串
+func (t Types) MarshalJSON() ([]byte, error) {
+	if len(t) == 1 {
+		return json.Marshal(t[0])
+	}
+	return json.Marshal([]string(t))
+}
+
+// validTypes 是支持的类型
+var validTypes = map[string]bool{
+	"object": true, "array": true, "string": true, "number": true, "integer": true, "boolean": true, "null": true,
+}
+
+// Parse 解析 schema 并检查类型是否受支持
+func Parse(data []byte) (*Schema, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, errors.New("schema is required")
+	}
+	var s Schema
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("invalid schema: %v", err)
+	}
+	if err := s.check("$"); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// check 递归检查 schema 中的类型
+func (s *Schema) check(path string) error {
+	for _, t := range s.Type {
+		if !validTypes[t] {
+			return fmt.Errorf("invalid schema: %s: unsupported type %q", path, t)
+		}
+	}
+	for name, prop := range s.Properties {
+		if prop == nil {
+			return fmt.Errorf("invalid schema: %s.%s: property schema is null", path, name)
+		}
+		if err := prop.check(path + "." + name); err != nil {
+			return err
+		}
+	}
+	if s.Items != nil {
+		return s.Items.check(path + "[]")
+	}
+	return nil
+}
+
+// Validate 校验 JSON 数据是否符合 schema，返回带路径的错误列表，符合时返回 nil
+func (s *Schema) Validate(data []byte) []string {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return []string{fmt.Sprintf("invalid JSON: %v", err)}
+	}
+	if dec.More() {
+		return []string{"invalid JSON: unexpected data after the top-level value"}
+	}
+	var errs []string
+	s.validate("$", v, &errs)
+	return errs
+}
+
+// validate 递归校验值，错误追加到 errs
+func (s *Schema) validate(path string, v any, errs *[]string) {
+	if len(*errs) >= maxErrors {
+		return
+	}
+	fail := func(format string, args ...any) {
+		*errs = append(*errs, path+": "+fmt.Sprintf(format, args...))
+	}
+
+	if len(s.Type) > 0 && !s.Type.match(v) {
+		fail("expected %s, got %s", strings.Join(s.Type, " or "), typeOf(v))
+		return
+	}
+	if len(s.Enum) > 0 && !inEnum(s.Enum, v) {
+		fail("must be one of %s", enumString(s.Enum))
+	}
+
+	switch v := v.(type) {
+	case map[string]any:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				fail("missing required property %q", name)
+			}
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if prop, ok := s.Properties[name]; ok {
+				prop.validate(path+"."+name, v[name], errs)
+			} else if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+				fail("unexpected property %q", name)
+			}
+		}
+	case []any:
+		if s.MinItems != nil && len(v) < *s.MinItems {
+			fail("expected at least %d items, got %d", *s.MinItems, len(v))
+		}
+		if s.MaxItems != nil && len(v) > *s.MaxItems {
+			fail("expected at most %d items, got %d", *s.MaxItems, len(v))
+		}
+		if s.Items != nil {
+			for i, item := range v {
+				s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item, errs)
+			}
+		}
+	case string:
+		if s.MinLength != nil && len([]rune(v)) < *s.MinLength {
+			fail("expected at least %d characters", *s.MinLength)
+		}
+	}
+}
+
+// match 判断值是否属于任一类型
+func (t Types) match(v any) bool {
+	actual := typeOf(v)
+	for _, want := range t {
+		if want == actual {
+			return true
+		}
+		if want == "number" && actual == "integer" {
+			return true
+		}
+	}
+	return false
+}
+
+// typeOf 返回值的 JSON 类型，整数返回 integer
+func typeOf(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			return "integer"
+		}
+		return "number"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return "unknown"
+}
+
+// inEnum 判断值是否在枚举中，按 JSON 编码比较
+func inEnum(enum []any, v any) bool {
+	encoded, _ := json.Marshal(v)
+	for _, e := range enum {
+		candidate, _ := json.Marshal(e)
+		if bytes.Equal(candidate, encoded) {
+			return true
+		}
+	}
+	return false
+}
+
+// enumString 返回枚举值的 JSON 表示
+func enumString(enum []any) string {
+	data, _ := json.Marshal(enum)
+	return string(data)
+}
+
+// Extract 从模型输出中提取第一个完整的 JSON 对象或数组，容忍代码块包裹和前后多余文本
+func Extract(output string) (json.RawMessage, error) {
+	for start := 0; start < len(output); start++ {
+		if output[start] != '{' && output[start] != '[' {
+			continue
+		}
+		if end := matchingEnd(output, start); end > 0 && json.Valid([]byte(output[start:end])) {
+			return json.RawMessage(output[start:end]), nil
+		}
+	}
+	return nil, errors.New("model output contains no JSON value")
+}
+
+// matchingEnd 返回从 start 开始的括号匹配结束后的位置，字符串中的括号不计入，没有匹配时返回 -1
+func matchingEnd(s string, start int) int {
+	depth := 0
+	inString, escaped := false, false
+	for i := start; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case inString:
+			if escaped {
+				escaped = false
+			} else if c == '\\' {
+				escaped = true
+			} else if c == '"' {
+				inString = false
+			}
+		case c == '"':
+			inString = true
+		case c == '{' || c == '[':
+			depth++
+		case c == '}' || c == ']':
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return -1
+}
+
+// Instructions 返回追加到提示词后、要求模型按 schema 输出的说明
+func Instructions(schema []byte) string {
+	return "\n\nRespond with a single JSON value that conforms to the following JSON schema, " +
+		"without Markdown code fences or any other text:\n" + compact(schema)
+}
+
+// RepairPrompt 构造让模型修正不符合 schema 的输出的提示词
+func RepairPrompt(prompt, output string, errs []string, schema []byte) string {
+	var b strings.Builder
+	b.WriteString(prompt)
+	b.WriteString(Instructions(schema))
+	b.WriteString("\n\nYour previous response did not conform to the schema:\n")
+	for _, e := range errs {
+		b.WriteString("- " + e + "\n")
+	}
+	b.WriteString("\nPrevious response:\n")
+	b.WriteString(output)
+	b.WriteString("\n\nRespond again with only the corrected JSON value.")
+	return b.String()
+}
+
+// compact 去掉 schema 中多余的空白，无法解析时原样返回
+func compact(schema []byte) string {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, schema); err != nil {
+		return string(schema)
+	}
+	return buf.String()
+}
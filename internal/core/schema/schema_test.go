@@ -0,0 +1,92 @@
+package schema
+
+import (
+	"strings"
+	"testing"
+)
+
+const planSchema = `{
+	"type": "object",
+	"required": ["steps"],
+	"additionalProperties": false,
+	"properties": {
+		"steps": {
+			"type": "array",
+			"minItems": 1,
+			"items": {
+				"type": "object",
+				"required": ["description", "action"],
+				"properties": {
+					"description": {"type": "string", "minLength": 1},
+					"action": {"enum": ["command", "note"]},
+					"timeout": {"type": "integer"},
+					"weight": {"type": ["number", "null"]}
+				}
+			}
+		}
+	}
+}`
+
+func TestValidate(t *testing.T) {
+	s, err := Parse([]byte(planSchema))
+	if err != nil {
+		t.Fatalf("failed to parse schema: %v", err)
+	}
+
+	valid := `{"steps": [{"description": "run tests", "action": "command", "timeout": 30, "weight": null}]}`
+	if errs := s.Validate([]byte(valid)); errs != nil {
+		t.Errorf("expected valid output, got %v", errs)
+	}
+
+	tests := map[string]string{
+		`{"steps": []}`: "$.steps: expected at least 1 items",
+		`{"steps": [{"description": "x", "action": "deploy"}]}`:               `$.steps[0].action: must be one of ["command","note"]`,
+		`{"steps": [{"action": "note"}]}`:                                     `$.steps[0]: missing required property "description"`,
+		`{"steps": [{"description": "x", "action": "note", "timeout": 1.5}]}`: "$.steps[0].timeout: expected integer, got number",
+		`{"steps": [], "extra": true}`:                                        `$: unexpected property "extra"`,
+		`{"steps": [{"description": "", "action": "note"}]}`:                  "$.steps[0].description: expected at least 1 characters",
+		`[1, 2]`:   "$: expected object, got array",
+		`{"steps"`: "invalid JSON",
+	}
+	for input, want := range tests {
+		errs := s.Validate([]byte(input))
+		if !strings.Contains(strings.Join(errs, "\n"), want) {
+			t.Errorf("Validate(%s) = %v, want error containing %q", input, errs, want)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, input := range []string{``, `{"type": "map"}`, `{"properties": {"a": {"type": 1}}}`, `not json`} {
+		if _, err := Parse([]byte(input)); err == nil {
+			t.Errorf("expected error for schema %q", input)
+		}
+	}
+}
+
+func TestExtract(t *testing.T) {
+	tests := map[string]string{
+		"```json\n{\"a\": 1}\n```":                    `{"a": 1}`,
+		"Here is the plan: {\"a\": \"}\"} Done.":      `{"a": "}"}`,
+		"[1, {\"b\": [2]}] trailing {":                `[1, {"b": [2]}]`,
+		"see [note] then {\"a\": \"\\\"quoted\\\"\"}": `{"a": "\"quoted\""}`,
+	}
+	for input, want := range tests {
+		got, err := Extract(input)
+		if err != nil || string(got) != want {
+			t.Errorf("Extract(%q) = %s, %v, want %s", input, got, err, want)
+		}
+	}
+	if _, err := Extract("no json here"); err == nil {
+		t.Error("expected error for output without JSON")
+	}
+}
+
+func TestRepairPrompt(t *testing.T) {
+	prompt := RepairPrompt("Plan the goal.", `{"steps": []}`, []string{"$.steps: expected at least 1 items"}, []byte(planSchema))
+	for _, want := range []string{"Plan the goal.", `"minItems":1`, "- $.steps: expected at least 1 items", `{"steps": []}`} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("expected repair prompt to contain %q:\n%s", want, prompt)
+		}
+	}
+}
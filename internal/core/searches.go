@@ -0,0 +1,300 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/liangsj/vimcoplit/internal/core/index"
+	"github.com/liangsj/vimcoplit/internal/events"
+)
+
+// searchWatchInterval 是检查监视的保存检索是否出现新匹配的间隔
+const searchWatchInterval = 10 * time.Second
+
+// watchLimit 是监视的保存检索记录的最多匹配数
+const watchLimit = 5000
+
+// SavedSearch 是保存在工作区 .vimcoplit/searches.json 中的命名检索，
+// 用于反复查看 TODO、已废弃 API 的调用等
+type SavedSearch struct {
+	Name        string          `json:"name"`
+	Query       index.GrepQuery `json:"query"`
+	Watch       bool            `json:"watch"` // 文件修改后出现新的匹配时发布 search 事件
+	CreatedAt   time.Time       `json:"created_at"`
+	LastRun     time.Time       `json:"last_run,omitempty"`
+	LastMatches int             `json:"last_matches"`
+}
+
+// SearchAlert 是监视的保存检索出现新匹配时发布的事件数据
+type SearchAlert struct {
+	Name    string        `json:"name"`
+	Matches []index.Match `json:"matches"`
+}
+
+// searchWatch 是监视中的保存检索已知的匹配，新匹配按文件和行内容判断，行号变化不算新匹配
+type searchWatch struct {
+	checkedAt time.Time
+	files     map[string]map[string]int // 文件 -> 行内容 -> 出现次数
+}
+
+// searchStore 是保存检索的持久化存储
+type searchStore struct {
+	mu       sync.Mutex
+	path     string
+	searches map[string]*SavedSearch
+	watches  map[string]*searchWatch // 已建立基线的监视，保存检索修改后重新建立
+}
+
+// newSearchStore 创建保存检索存储，并加载已有记录
+func newSearchStore(path string) *searchStore {
+	s := &searchStore{
+		path:     path,
+		searches: make(map[string]*SavedSearch),
+		watches:  make(map[string]*searchWatch),
+	}
+	if data, err := os.ReadFile(path); err == nil {
+		json.Unmarshal(data, &s.searches)
+	}
+	return s
+}
+
+// save 保存到文件，调用方需持有锁
+func (s *searchStore) save() error {
+	data, err := json.MarshalIndent(s.searches, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(s.path, data, 0644)
+}
+
+// put 创建或替换同名的保存检索
+func (s *searchStore) put(search *SavedSearch) (*SavedSearch, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item := *search
+	previous, exists := s.searches[item.Name]
+	if exists {
+		item.CreatedAt = previous.CreatedAt
+	} else {
+		item.CreatedAt = time.Now()
+	}
+	s.searches[item.Name] = &item
+	if err := s.save(); err != nil {
+		if exists {
+			s.searches[item.Name] = previous
+		} else {
+			delete(s.searches, item.Name)
+		}
+		return nil, err
+	}
+	delete(s.watches, item.Name)
+	c := item
+	return &c, nil
+}
+
+// get 获取保存检索
+func (s *searchStore) get(name string) (*SavedSearch, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, exists := s.searches[name]
+	if !exists {
+		return nil, errors.New("saved search not found")
+	}
+	c := *item
+	return &c, nil
+}
+
+// list 按名称列出所有保存检索
+func (s *searchStore) list() []*SavedSearch {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	items := make([]*SavedSearch, 0, len(s.searches))
+	for _, item := range s.searches {
+		c := *item
+		items = append(items, &c)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Name < items[j].Name
+	})
+	return items
+}
+
+// delete 删除保存检索
+func (s *searchStore) delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, exists := s.searches[name]
+	if !exists {
+		return errors.New("saved search not found")
+	}
+	delete(s.searches, name)
+	if err := s.save(); err != nil {
+		s.searches[name] = item
+		return err
+	}
+	delete(s.watches, name)
+	return nil
+}
+
+// recordRun 记录最近一次执行的时间和匹配数
+func (s *searchStore) recordRun(name string, at time.Time, matches int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, exists := s.searches[name]
+	if !exists {
+		return
+	}
+	item.LastRun, item.LastMatches = at, matches
+	if err := s.save(); err != nil {
+		log.Printf("保存检索记录失败: %v\n", err)
+	}
+}
+
+// SaveSearch 创建或替换同名的保存检索
+func (s *serviceImpl) SaveSearch(ctx context.Context, search *SavedSearch) (*SavedSearch, error) {
+	search.Name = strings.TrimSpace(search.Name)
+	if search.Name == "" {
+		return nil, errors.New("name is required")
+	}
+	if err := search.Query.Validate(); err != nil {
+		return nil, err
+	}
+	return s.searches.put(search)
+}
+
+// ListSavedSearches 列出工作区的保存检索
+func (s *serviceImpl) ListSavedSearches(ctx context.Context) []*SavedSearch {
+	return s.searches.list()
+}
+
+// DeleteSavedSearch 删除保存检索
+func (s *serviceImpl) DeleteSavedSearch(ctx context.Context, name string) error {
+	return s.searches.delete(name)
+}
+
+// RunSavedSearch 执行保存检索，每找到一行匹配就调用 fn，并记录执行时间和匹配数
+func (s *serviceImpl) RunSavedSearch(ctx context.Context, name string, fn func(index.Match) bool) (*index.GrepSummary, error) {
+	search, err := s.searches.get(name)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	summary, err := s.SearchFiles(ctx, &search.Query, fn)
+	if err != nil {
+		return nil, err
+	}
+	if !summary.Canceled {
+		s.searches.recordRun(name, start, summary.Matches)
+	}
+	return summary, nil
+}
+
+// watchSearches 定期检查监视的保存检索，只重新读取上次检查后修改过的文件
+func (s *serviceImpl) watchSearches(ctx context.Context) {
+	ticker := time.NewTicker(searchWatchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.checkSearches(ctx)
+		}
+	}
+}
+
+// checkSearches 检查所有监视的保存检索，第一次检查时只建立基线，之后出现新匹配时发布 search 事件
+func (s *serviceImpl) checkSearches(ctx context.Context) {
+	for _, search := range s.searches.list() {
+		if !search.Watch {
+			continue
+		}
+		s.searches.mu.Lock()
+		watch := s.searches.watches[search.Name]
+		s.searches.mu.Unlock()
+
+		fresh, err := s.scanWatch(ctx, search, watch)
+		if err != nil {
+			log.Printf("检查保存检索 %s 失败: %v\n", search.Name, err)
+			continue
+		}
+		if len(fresh) > 0 {
+			s.events.Publish(events.TypeSearch, &SearchAlert{Name: search.Name, Matches: fresh})
+		}
+	}
+}
+
+// scanWatch 检索上次检查后修改过的文件并更新已知的匹配，返回新出现的匹配，watch 为 nil 时建立基线
+func (s *serviceImpl) scanWatch(ctx context.Context, search *SavedSearch, watch *searchWatch) ([]index.Match, error) {
+	q := search.Query
+	q.Limit = watchLimit
+	baseline := watch == nil
+	if baseline {
+		watch = &searchWatch{files: make(map[string]map[string]int)}
+	} else {
+		q.Since = watch.checkedAt
+	}
+	checkedAt := time.Now()
+	found := make(map[string][]index.Match)
+	if _, err := s.SearchFiles(ctx, &q, func(m index.Match) bool {
+		found[m.Path] = append(found[m.Path], m)
+		return true
+	}); err != nil {
+		return nil, err
+	}
+
+	// 修改后不再有匹配的文件不会出现在结果中，清空其已知的匹配
+	root := s.cfg.WorkspaceRoot()
+	for path := range watch.files {
+		if _, ok := found[path]; ok {
+			continue
+		}
+		if info, err := os.Stat(filepath.Join(root, filepath.FromSlash(path))); err != nil || !info.ModTime().Before(watch.checkedAt) {
+			delete(watch.files, path)
+		}
+	}
+
+	var fresh []index.Match
+	for path, matches := range found {
+		known := watch.files[path]
+		lines := make(map[string]int, len(matches))
+		for _, m := range matches {
+			lines[m.Text]++
+			if !baseline && lines[m.Text] > known[m.Text] {
+				fresh = append(fresh, m)
+			}
+		}
+		watch.files[path] = lines
+	}
+	sort.Slice(fresh, func(i, j int) bool {
+		if fresh[i].Path != fresh[j].Path {
+			return fresh[i].Path < fresh[j].Path
+		}
+		return fresh[i].Line < fresh[j].Line
+	})
+	watch.checkedAt = checkedAt
+
+	// 检查期间保存检索被修改或删除时丢弃结果
+	s.searches.mu.Lock()
+	defer s.searches.mu.Unlock()
+	if current, ok := s.searches.searches[search.Name]; ok && current.Watch && current.Query == search.Query {
+		s.searches.watches[search.Name] = watch
+	}
+	return fresh, nil
+}
@@ -0,0 +1,118 @@
+package secrets
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Rule 是一条密钥匹配规则
+type Rule struct {
+	Name    string
+	Pattern *regexp.Regexp
+}
+
+// Finding 表示一处疑似密钥，不包含密钥原文
+type Finding struct {
+	Rule    string `json:"rule"`
+	Line    int    `json:"line"`
+	Preview string `json:"preview"`
+}
+
+// Config 定义了密钥扫描配置
+type Config struct {
+	Patterns  []string // 额外的正则规则
+	Allowlist []string // 误报白名单，匹配内容包含其中任一字符串时忽略
+}
+
+// builtinRules 是内置的密钥规则
+var builtinRules = []Rule{
+	{Name: "private_key", Pattern: regexp.MustCompile(`-----BEGIN ([A-Z]+ )?PRIVATE KEY-----[\s\S]*?(-----END ([A-Z]+ )?PRIVATE KEY-----|$)`)},
+	{Name: "aws_access_key", Pattern: regexp.MustCompile(`\b(AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{Name: "aws_secret_key", Pattern: regexp.MustCompile(`(?i)aws_?secret_?(access_?)?key["']?\s*[:=]\s*["']?[A-Za-z0-9/+=]{40}\b`)},
+	{Name: "github_token", Pattern: regexp.MustCompile(`\b(ghp|gho|ghu|ghs|ghr)_[A-Za-z0-9]{36,}\b|\bgithub_pat_[A-Za-z0-9_]{22,}\b`)},
+	{Name: "slack_token", Pattern: regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}\b`)},
+	{Name: "anthropic_key", Pattern: regexp.MustCompile(`\bsk-ant-[A-Za-z0-9_-]{20,}`)},
+	{Name: "openai_key", Pattern: regexp.MustCompile(`\bsk-(proj-)?[A-Za-z0-9]{20,}\b`)},
+	{Name: "google_api_key", Pattern: regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`)},
+	{Name: "stripe_key", Pattern: regexp.MustCompile(`\b(sk|rk)_live_[0-9A-Za-z]{20,}\b`)},
+	{Name: "generic_secret", Pattern: regexp.MustCompile(`(?i)\b(api_?key|secret|token|passwd|password)["']?\s*[:=]\s*["'][^"'\s]{12,}["']`)},
+}
+
+// Scanner 在内容发送给模型提供商之前扫描并脱敏疑似密钥
+type Scanner struct {
+	rules     []Rule
+	allowlist []string
+}
+
+// New 根据配置创建扫描器
+func New(cfg Config) (*Scanner, error) {
+	s := &Scanner{
+		rules:     append([]Rule(nil), builtinRules...),
+		allowlist: cfg.Allowlist,
+	}
+	for i, pattern := range cfg.Patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid secret pattern %q: %v", pattern, err)
+		}
+		s.rules = append(s.rules, Rule{Name: fmt.Sprintf("custom_%d", i+1), Pattern: re})
+	}
+	return s, nil
+}
+
+// Scan 返回文本中的所有疑似密钥
+func (s *Scanner) Scan(text string) []Finding {
+	_, findings := s.Redact(text)
+	return findings
+}
+
+// Redact 将疑似密钥替换为 [REDACTED:<rule>]，返回脱敏后的文本和命中位置
+func (s *Scanner) Redact(text string) (string, []Finding) {
+	var findings []Finding
+	for _, rule := range s.rules {
+		locs := rule.Pattern.FindAllStringIndex(text, -1)
+		if len(locs) == 0 {
+			continue
+		}
+
+		var b strings.Builder
+		last := 0
+		for _, loc := range locs {
+			match := text[loc[0]:loc[1]]
+			if s.allowed(match) {
+				continue
+			}
+			findings = append(findings, Finding{
+				Rule:    rule.Name,
+				Line:    strings.Count(text[:loc[0]], "\n") + 1,
+				Preview: mask(match),
+			})
+			b.WriteString(text[last:loc[0]])
+			b.WriteString("[REDACTED:" + rule.Name + "]")
+			last = loc[1]
+		}
+		b.WriteString(text[last:])
+		text = b.String()
+	}
+	return text, findings
+}
+
+// allowed 判断匹配内容是否在白名单中
+func (s *Scanner) allowed(match string) bool {
+	for _, a := range s.allowlist {
+		if a != "" && strings.Contains(match, a) {
+			return true
+		}
+	}
+	return false
+}
+
+// mask 只保留密钥开头几个字符用于提示
+func mask(secret string) string {
+	secret = strings.SplitN(secret, "\n", 2)[0]
+	if len(secret) <= 8 {
+		return "****"
+	}
+	return secret[:4] + "****"
+}
@@ -1,14 +1,53 @@
 package core
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
 	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
+	"github.com/google/uuid"
+	"github.com/liangsj/vimcoplit/internal/audit"
+	"github.com/liangsj/vimcoplit/internal/backup"
+	"github.com/liangsj/vimcoplit/internal/config"
+	"github.com/liangsj/vimcoplit/internal/core/archive"
+	"github.com/liangsj/vimcoplit/internal/core/attachment"
+	"github.com/liangsj/vimcoplit/internal/core/command"
+	"github.com/liangsj/vimcoplit/internal/core/completion"
+	"github.com/liangsj/vimcoplit/internal/core/dbquery"
+	"github.com/liangsj/vimcoplit/internal/core/diffsum"
+	"github.com/liangsj/vimcoplit/internal/core/experiment"
+	"github.com/liangsj/vimcoplit/internal/core/filter"
+	"github.com/liangsj/vimcoplit/internal/core/forge"
+	"github.com/liangsj/vimcoplit/internal/core/fulltext"
+	"github.com/liangsj/vimcoplit/internal/core/index"
 	"github.com/liangsj/vimcoplit/internal/core/mcp"
+	"github.com/liangsj/vimcoplit/internal/core/offline"
+	"github.com/liangsj/vimcoplit/internal/core/procmon"
+	"github.com/liangsj/vimcoplit/internal/core/related"
+	"github.com/liangsj/vimcoplit/internal/core/repomap"
+	"github.com/liangsj/vimcoplit/internal/core/sandbox"
+	"github.com/liangsj/vimcoplit/internal/core/secrets"
+	"github.com/liangsj/vimcoplit/internal/core/shell"
+	"github.com/liangsj/vimcoplit/internal/core/syntax"
+	"github.com/liangsj/vimcoplit/internal/core/watchdog"
+	"github.com/liangsj/vimcoplit/internal/core/webcache"
+	"github.com/liangsj/vimcoplit/internal/core/websearch"
+	"github.com/liangsj/vimcoplit/internal/events"
 	"github.com/liangsj/vimcoplit/internal/models"
+	"github.com/liangsj/vimcoplit/internal/permission"
+	"github.com/liangsj/vimcoplit/internal/setup"
 )
 
 // Service 定义了 VimCoplit 的核心服务接口
@@ -25,20 +64,166 @@ type Service interface {
 	WriteFile(ctx context.Context, path string, content []byte) error
 	WatchFile(ctx context.Context, path string) (<-chan FileEvent, error)
 
+	// 并发修改的冲突检测，计划修改时记录文件摘要，应用时文件已被改动则三方合并
+	TrackFile(ctx context.Context, path string) ([]byte, string, error)
+	ReadSnapshot(ctx context.Context, hash string) ([]byte, error)
+	EditFile(ctx context.Context, edit *FileEdit) (*EditResult, error)
+	ListConflicts(ctx context.Context) ([]*FileConflict, error)
+	GetConflict(ctx context.Context, id string) (*FileConflict, error)
+	ResolveConflict(ctx context.Context, id string, content *string) (*FileConflict, error)
+
 	// 命令执行
 	ExecuteCommand(ctx context.Context, cmd *Command) (*CommandResult, error)
 	CancelCommand(ctx context.Context, cmdID string) error
+	SearchCommandHistory(ctx context.Context, query *HistoryQuery) ([]*HistoryEntry, error)
+	GetCommandHistory(ctx context.Context, cmdID string) (*HistoryEntry, error)
 
 	// AI 交互
 	GenerateResponse(ctx context.Context, prompt string) (string, error)
+	GenerateStructured(ctx context.Context, req *StructuredRequest) (*StructuredResult, error)
+	GenerateWithTools(ctx context.Context, req *ToolLoopRequest) (*ToolLoopResult, error)
+	Complete(ctx context.Context, req *CompletionRequest) (*Completion, error)
+	GenerateAlternatives(ctx context.Context, prompt string, n int) ([]string, []*GenerationParams, error)
+	GenerateBatch(ctx context.Context, prompts []BatchPrompt) (map[string]*BatchResult, error)
+	SummarizeDiff(ctx context.Context, req *DiffSummaryRequest) (*diffsum.Summary, error)
+	SuggestTitle(ctx context.Context, kind, text string) string
+
+	// 生成请求的取消，取消时中断模型请求并记录已经生成的部分输出
+	BeginGeneration(ctx context.Context, id, kind string) (context.Context, func(err error) *GenerationRecord)
+	CancelGeneration(ctx context.Context, id string) (*GenerationRecord, error)
+
+	// 对话，消息以树的形式保存，可以从任意消息创建分支并将分支设为主线
+	CreateConversation(ctx context.Context, title string) (*Conversation, error)
+	GetConversation(ctx context.Context, id string) (*Conversation, error)
+	ListConversations(ctx context.Context) ([]*Conversation, error)
+	RenameConversation(ctx context.Context, id, name string) (*Conversation, error)
+	SendMessage(ctx context.Context, convID string, req *MessageRequest) ([]*Message, error)
+	ListBranches(ctx context.Context, convID string) ([]*Branch, error)
+	PromoteBranch(ctx context.Context, convID, messageID string) (*Conversation, error)
+	ListCommands(ctx context.Context) []command.Command
+
+	// 自动上下文，推荐与当前文件相关的文件
+	RelatedFiles(ctx context.Context, path string, limit int) ([]related.Candidate, error)
+	RepoMap(ctx context.Context, refresh bool) (*repomap.Map, error)
+	SearchCode(ctx context.Context, q *index.Query) ([]index.Result, error)
+	SearchFiles(ctx context.Context, q *index.GrepQuery, fn func(index.Match) bool) (*index.GrepSummary, error)
+
+	// 保存检索，保存在工作区的 .vimcoplit/searches.json 中，监视的检索出现新匹配时发布 search 事件
+	SaveSearch(ctx context.Context, search *SavedSearch) (*SavedSearch, error)
+	ListSavedSearches(ctx context.Context) []*SavedSearch
+	DeleteSavedSearch(ctx context.Context, name string) error
+	RunSavedSearch(ctx context.Context, name string, fn func(index.Match) bool) (*index.GrepSummary, error)
+
+	// 代码检索索引的后台刷新和进度
+	StartIndexing() bool
+	CancelIndexing() bool
+	IndexStatus() *IndexStatus
+	WatchIndexing() (index.Progress, <-chan index.Progress, func())
+	IndexFiles(ctx context.Context, all bool) ([]index.FileStatus, error)
+
+	// 解释错误，解析错误文本中的文件和行号并把对应代码加入上下文
+	ExplainError(ctx context.Context, req *ExplainRequest) (*ErrorExplanation, error)
+
+	// 工作区设置，保存在工作区的 .vimcoplit/settings.json 中
+	GetSettings(ctx context.Context) *WorkspaceSettings
+	UpdateSettings(ctx context.Context, patch *SettingsPatch) (*WorkspaceSettings, error)
+	AddPermissionRule(ctx context.Context, rule permission.Rule) error // 加入的规则优先于已有规则
+	CancelMessage(ctx context.Context, convID string) (*GenerationRecord, error)
+
+	// 建议反馈，插件上报补全或编辑建议是否被接受，用于统计各模型和模板的接受率
+	RecordFeedback(ctx context.Context, fb *Feedback) (*Feedback, error)
+	GetFeedbackStats(ctx context.Context) ([]*FeedbackStats, error)
+	GetExperimentReport() *experiment.Report
 	SwitchModel(ctx context.Context, modelType models.ModelType) error
+	// ApplySetup 把配置向导写入的模型类型、API Key 和允许执行的命令更新到运行中的配置并切换模型
+	ApplySetup(ctx context.Context, written *config.Config) error
+	// SetupStatus 返回运行中的配置的向导状态
+	SetupStatus() *setup.Status
+	// AllowedCommands 返回允许执行的命令，配置向导可以在运行时修改
+	AllowedCommands() []string
 	GetCurrentModel() models.ModelType
+	GetModelDefaults() models.Defaults // 当前模型生效的默认生成参数
+	ListModels() []*ModelInfo
+	TestModel(ctx context.Context) *models.TestResult
+	GetRateLimit() models.RateLimitInfo
+	GetKeyUsage() []models.KeyUsage
+	GetResourceUsage() *ResourceUsage
+	DebugState() *DebugState
+
+	// 输出过滤，拦截模式下命中规则且 ctx 未声明覆盖时返回 *filter.BlockedError
+	CheckOutput(ctx context.Context, text string) ([]filter.Finding, error)
+
+	// 离线模式，离线时云端模型不可用，非紧急的生成请求排队到恢复联网后执行
+	GetConnectivity() offline.Status
+	SetOffline(enabled bool)
+	DeferGeneration(ctx context.Context, prompt string) (*DeferredGeneration, error)
+	GetDeferredGeneration(id string) (*DeferredGeneration, error)
+	ListDeferredGenerations() []*DeferredGeneration
+
+	// 密钥扫描，返回脱敏后的文本和疑似密钥的位置
+	RedactSecrets(text string) (string, []secrets.Finding)
 
 	// Context Manager
 	GetContextManager() ContextManager
 
 	// MCP Manager
 	GetMCPManager() mcp.ToolManager
+
+	// Config 返回服务使用的配置
+	Config() *config.Config
+
+	// 事件总线，任务、文件写入、命令和工具调用等事件发布到总线上
+	Events() *events.Bus
+	// Audit 返回审计日志，未启用时返回 nil
+	Audit() *audit.Log
+
+	// 进程资源监控
+	GetProcessStats(ctx context.Context) map[string]procmon.Stats
+
+	// 崩溃恢复
+	Recover(ctx context.Context) (*RecoveryReport, error)
+
+	// Close 取消后台任务并停止正在运行的 MCP 服务器
+	Close() error
+
+	// 备份与恢复
+	Backup(ctx context.Context, w io.Writer) (*backup.Manifest, error)
+	Restore(ctx context.Context, r io.Reader) (*backup.Manifest, error)
+
+	// 保留策略与归档
+	ApplyRetention(ctx context.Context) (*RetentionReport, error)
+	ListArchive(ctx context.Context) []*archive.Entry
+	RestoreArchive(ctx context.Context, kind archive.Kind, id string) (*archive.Entry, error)
+	ExportArchive(ctx context.Context, w io.Writer) (int, error)
+	ImportArchive(ctx context.Context, r io.Reader) (int, error)
+
+	// 全文检索对话、任务和命令输出，包括已归档的记录
+	SearchTranscripts(ctx context.Context, q *fulltext.Query) ([]*fulltext.Result, error)
+
+	// 附件，按内容寻址保存运行中产生的文件，记录中以 attachment:<id> 引用
+	PutAttachment(ctx context.Context, r io.Reader, mediaType, name string) (*attachment.Attachment, error)
+	OpenAttachment(ctx context.Context, id string) (io.ReadCloser, *attachment.Attachment, error)
+	ListAttachments(ctx context.Context) ([]*attachment.Attachment, attachment.Usage)
+	DeleteAttachment(ctx context.Context, id string) error
+	CollectAttachments(ctx context.Context) (*attachment.Collection, error)
+
+	// 网页缓存，@url 引用和 fetch_url 工具共用
+	WebCacheStats() *webcache.Stats
+	ClearWebCache() int
+
+	// 网页搜索，web_search 工具和 question 类型的上下文共用，未启用时返回 ErrWebSearchDisabled
+	SearchWeb(ctx context.Context, query string, count int) (*websearch.Response, error)
+	ResolveQuestion(ctx context.Context, question string) (string, error)
+	WebSearchUsage() *websearch.Usage
+
+	// 代码托管平台（GitHub、GitLab）的 issue 和 PR，未配置时返回 ErrForgeDisabled
+	ForgeStatus() *ForgeStatus
+	ListIssues(ctx context.Context, opts *forge.ListOptions) ([]*forge.Issue, error)
+	GetIssue(ctx context.Context, number int) (*forge.Issue, error)
+	ListPullRequests(ctx context.Context, opts *forge.ListOptions) ([]*forge.PullRequest, error)
+	GetPullRequest(ctx context.Context, number int) (*forge.PullRequest, error)
+	PostComment(ctx context.Context, kind forge.Kind, number int, body string) (*forge.Comment, error)
+	CreatePullRequest(ctx context.Context, req *PullRequestRequest) (*forge.PullRequest, error)
 }
 
 // Task 表示一个任务
@@ -49,6 +234,7 @@ type Task struct {
 	Status      TaskStatus        `json:"status"`
 	CreatedAt   int64             `json:"created_at"`
 	UpdatedAt   int64             `json:"updated_at"`
+	Error       string            `json:"error,omitempty"`
 	Metadata    map[string]string `json:"metadata"`
 }
 
@@ -101,58 +287,392 @@ const (
 	FileEventDeleted  FileEventType = "deleted"
 )
 
-// NewService 创建新的核心服务实例
-func NewService() Service {
-	return &serviceImpl{
-		model:          nil,
+// NewService 使用 cfg 创建新的核心服务实例，cfg 由调用方加载并在服务的生命周期内共享
+func NewService(cfg *config.Config) Service {
+	monitor := procmon.NewMonitor(procmon.Limits{
+		MaxCPUPercent:  cfg.Monitor.MaxCPUPercent,
+		MaxMemoryBytes: uint64(cfg.Monitor.MaxMemory),
+		MaxChildren:    cfg.Monitor.MaxChildren,
+	}, cfg.Monitor.Interval.Std())
+
+	bus := events.New(200)
+	migrateMCPConfig(cfg.MCPPath())
+	mcpManager := mcp.NewManager(cfg.MCPPath())
+	mcpManager.SetMonitor(monitor)
+	mcpManager.SetEvents(bus)
+	calls := watchdog.New(cfg.Watchdog.Grace.Std(), bus)
+	mcpManager.SetWatchdog(calls)
+	mcpManager.SetShell(shell.Shell{Path: cfg.Shell.Path, Login: cfg.Shell.Login, RCFile: cfg.Shell.RCFile})
+	registerGoModTools(mcpManager, cfg.WorkspaceRoot())
+	registerOpsTools(mcpManager, cfg)
+	attachments := attachment.New(filepath.Join(cfg.DataDir(), "attachments"), int64(cfg.Attachments.MaxBytes), int64(cfg.Attachments.MaxFileBytes))
+	mcpManager.SetAttachments(attachments)
+
+	var auditLog *audit.Log
+	if cfg.Audit.Enabled {
+		l, err := audit.Open(cfg.AuditPath())
+		if err != nil {
+			log.Printf("打开审计日志失败，审计日志未启用: %v\n", err)
+		} else {
+			auditLog = l
+			bus.Hook(func(event events.Event) {
+				if err := auditLog.Record(event); err != nil {
+					log.Printf("写入审计日志失败: %v\n", err)
+				}
+			}, audit.Types...)
+		}
+	}
+
+	outputFilter, err := filter.New(filter.Config{
+		Mode:     filter.Mode(cfg.Filter.Mode),
+		Patterns: cfg.Filter.Patterns,
+		Disabled: cfg.Filter.Disabled,
+	})
+	if err != nil {
+		log.Printf("输出过滤配置无效，使用内置规则: %v\n", err)
+		outputFilter, _ = filter.New(filter.Config{})
+	}
+
+	secretScanner, err := secrets.New(secrets.Config{
+		Patterns:  cfg.Secrets.Patterns,
+		Allowlist: cfg.Secrets.Allowlist,
+	})
+	if err != nil {
+		log.Printf("密钥扫描配置无效，使用内置规则: %v\n", err)
+		secretScanner, _ = secrets.New(secrets.Config{Allowlist: cfg.Secrets.Allowlist})
+	}
+
+	limiter, keys := newRateLimiter(cfg), newKeyPool(cfg)
+	model, err := models.NewModel(modelConfig(cfg, cfg.Model.Type, limiter, keys))
+	if err != nil {
+		log.Printf("初始化模型失败: %v\n", err)
+	}
+
+	probeInterval := cfg.Offline.ProbeInterval.Std()
+	if !cfg.Offline.AutoDetect {
+		probeInterval = 0
+	}
+	detector := offline.NewDetector(cfg.Offline.Enabled, cfg.Offline.ProbeAddress, probeInterval)
+
+	dataDir := cfg.DataDir()
+	root := cfg.WorkspaceRoot()
+	experimentCfg := experiment.Config{Name: cfg.Experiment.Name}
+	for _, v := range cfg.Experiment.Variants {
+		experimentCfg.Variants = append(experimentCfg.Variants, experiment.Variant(v))
+	}
+	experiments, err := experiment.New(experimentCfg, filepath.Join(dataDir, "experiments.json"))
+	if err != nil {
+		log.Printf("实验配置无效，不进行实验: %v\n", err)
+	}
+
+	background, stopBackground := context.WithCancel(context.Background())
+	s := &serviceImpl{
+		cfg:            cfg,
+		background:     background,
+		stopBackground: stopBackground,
+		model:          model,
+		limiter:        limiter,
+		keys:           keys,
 		mu:             &sync.RWMutex{},
-		contextManager: NewManager(),
-		mcpManager:     mcp.NewManager("config/mcp.json"),
+		contextManager: NewLimitedManager(filepath.Join(dataDir, "context_spill"), int64(cfg.Limits.MaxContextBytes)),
+		sessions:       newSessionSlots(cfg.Limits.MaxSessions),
+		mcpManager:     mcpManager,
+		events:         bus,
+		audit:          auditLog,
+		commands:       make(map[string]context.CancelFunc),
+		monitor:        monitor,
+		filter:         outputFilter,
+		secrets:        secretScanner,
+		tasks:          newTaskStore(filepath.Join(dataDir, "tasks.json")),
+		journal:        newJournal(filepath.Join(dataDir, "journal.log")),
+		snapshots:      newSnapshotStore(filepath.Join(dataDir, "snapshots")),
+		conflicts:      newConflictStore(filepath.Join(dataDir, "conflicts.json")),
+		offline:        detector,
+		deferred:       newDeferredStore(filepath.Join(dataDir, "deferred.json")),
+		history:        newCommandHistory(filepath.Join(dataDir, "history.jsonl")),
+		experiments:    experiments,
+		conversations:  newConversationStore(filepath.Join(dataDir, "conversations"), filepath.Join(dataDir, "conversations.json"), cfg.Limits.MaxCachedTranscripts),
+		archive:        archive.New(filepath.Join(dataDir, "archive")),
+		transcripts:    fulltext.New(filepath.Join(dataDir, "transcripts.json")),
+		attachments:    attachments,
+		webCache:       newWebCache(cfg),
+		webSearch:      newWebSearch(cfg),
+		databases:      dbquery.NewPool(root),
+		forge:          newForge(cfg),
+		related:        related.NewFinder(root),
+		repoMap:        repomap.New(root, filepath.Join(dataDir, "repomap.json"), cfg.RepoMap.MaxTokens),
+		codeIndex:      index.New(root, codeIndexDir(cfg)),
+		sources:        newSourceSet(filepath.Join(codeIndexDir(cfg), "sources"), codeIndexQuantization(cfg)),
+		settings:       newSettingsStore(filepath.Join(root, ".vimcoplit", "settings.json"), filepath.Join(dataDir, "confirmed_settings.json")),
+		searches:       newSearchStore(filepath.Join(root, ".vimcoplit", "searches.json")),
+		generations:    newGenerationTracker(filepath.Join(dataDir, "canceled_generations.jsonl")),
+		variantModels:  make(map[string]models.Model),
+		feedback:       newFeedbackStore(filepath.Join(dataDir, "feedback.jsonl")),
+		completions:    completion.NewCache(cfg.Completion.CacheSize, cfg.Completion.CacheTTL.Std()),
+		watchdog:       calls,
+	}
+
+	registerFetchTool(mcpManager, s.fetchPage)
+	registerCodeBlockTool(mcpManager, s)
+	registerHTTPRequestTool(mcpManager, s.httpRequest)
+	registerWebSearchTool(mcpManager, s.SearchWeb)
+	registerDatabaseTools(mcpManager, s.databases)
+	registerForgeTools(mcpManager, s)
+
+	// 应用工作区设置中的模型、忽略规则、索引范围和上下文源
+	settings := s.settings.get()
+	s.repoMap.SetIgnore(settings.Ignored)
+	s.codeIndex.SetIgnore(settings.Ignored)
+	s.codeIndex.SetScope(settings.IndexScope)
+	s.codeIndex.SetQuantization(codeIndexQuantization(cfg))
+	s.sources.set(settings.ContextSources)
+	s.databases.Set(settings.Databases)
+	if settings.Model != "" && settings.Model != cfg.Model.Type {
+		if err := s.SwitchModel(background, settings.Model); err != nil {
+			log.Printf("切换到工作区设置的模型失败: %v\n", err)
+		}
 	}
+
+	// 恢复联网后执行排队的生成请求
+	detector.OnChange(func(isOffline bool) {
+		if isOffline {
+			log.Println("网络不可用，已进入离线模式")
+			return
+		}
+		log.Println("网络已恢复，开始执行排队的生成请求")
+		go s.drainDeferred(s.background)
+	})
+	detector.Start(s.background)
+	go s.watchSearches(s.background)
+	go s.enforceRetention(s.background)
+	return s
+}
+
+// legacyMCPPath 是早期版本相对当前目录保存 MCP 配置的路径
+const legacyMCPPath = "config/mcp.json"
+
+// migrateMCPConfig 在数据目录中还没有 MCP 配置时复制旧路径下的配置，旧文件保留不动
+func migrateMCPConfig(path string) {
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		return
+	}
+	data, err := os.ReadFile(legacyMCPPath)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err == nil {
+		err = os.WriteFile(path, data, 0644)
+	}
+	if err != nil {
+		log.Printf("迁移 MCP 配置 %s 失败: %v\n", legacyMCPPath, err)
+		return
+	}
+	log.Printf("已将 MCP 配置从 %s 复制到 %s\n", legacyMCPPath, path)
+}
+
+// closeTimeout 是关闭服务时等待 MCP 服务器退出的最长时间
+const closeTimeout = 10 * time.Second
+
+// Close 取消后台任务并停止正在运行的 MCP 服务器，之后服务不能再使用
+func (s *serviceImpl) Close() error {
+	s.stopBackground()
+	s.CancelIndexing()
+	ctx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+	defer cancel()
+	return s.mcpManager.StopAll(ctx)
+}
+
+// RecoveryReport 描述启动时崩溃恢复的结果
+type RecoveryReport struct {
+	ReplayedWrites int `json:"replayed_writes"`
+	SkippedWrites  int `json:"skipped_writes"` // 文件已被修改、没有重放的写入
+	ResumedTasks   int `json:"resumed_tasks"`
+	FailedTasks    int `json:"failed_tasks"`
+	ResetServers   int `json:"reset_servers"`
 }
 
 // serviceImpl 是Service接口的具体实现
 type serviceImpl struct {
+	cfg            *config.Config
 	model          models.Model
+	limiter        *models.RateLimiter
+	keys           *models.KeyPool
 	mu             *sync.RWMutex
 	contextManager ContextManager
-	mcpManager     mcp.ToolManager
+	mcpManager     *mcp.Manager
+	events         *events.Bus
+	audit          *audit.Log
+	commands       map[string]context.CancelFunc
+	monitor        *procmon.Monitor
+	filter         *filter.Filter
+	secrets        *secrets.Scanner
+	tasks          *taskStore
+	journal        *journal
+	snapshots      *snapshotStore
+	conflicts      *conflictStore
+	offline        *offline.Detector
+	deferred       *deferredStore
+	history        *commandHistory
+	completions    *completion.Cache
+	watchdog       *watchdog.Watchdog // 监视模型和工具调用，超过期限仍未返回时不再等待
+	feedback       *feedbackStore
+	experiments    *experiment.Runner
+	conversations  *conversationStore
+	archive        *archive.Store
+	transcripts    *fulltext.Index
+	transcriptsMu  sync.Mutex // 同一时间只有一次索引同步
+	attachments    *attachment.Store
+	webCache       *webcache.Cache     // 未启用时为 nil
+	webSearch      *websearch.Searcher // 未启用时为 nil
+	databases      *dbquery.Pool
+	forge          forge.Provider // 未配置时为 nil
+	generations    *generationTracker
+	sessions       chan struct{} // 同时进行的生成会话，为 nil 时不限制
+	related        *related.Finder
+	repoMap        *repomap.Generator
+	codeIndex      *index.Index
+	sources        *sourceSet
+	settings       *settingsStore
+	searches       *searchStore
+	repoMapAt      time.Time
+	codeIndexAt    time.Time
+	indexCancel    context.CancelFunc // 正在进行的后台刷新，没有时为 nil
+	variantModels  map[string]models.Model
+	drainMu        sync.Mutex
+	background     context.Context // 后台任务的 ctx，Close 时取消
+	stopBackground context.CancelFunc
 }
 
 // 实现Service接口的所有方法
 func (s *serviceImpl) CreateTask(ctx context.Context, task *Task) error {
-	// TODO: 实现创建任务的逻辑
+	if err := s.tasks.create(task); err != nil {
+		return err
+	}
+	s.publishTask(task)
 	return nil
 }
 
 func (s *serviceImpl) GetTask(ctx context.Context, taskID string) (*Task, error) {
-	// TODO: 实现获取任务的逻辑
-	return nil, nil
+	return s.tasks.get(taskID)
 }
 
 func (s *serviceImpl) UpdateTask(ctx context.Context, task *Task) error {
-	// TODO: 实现更新任务的逻辑
+	if err := s.tasks.update(task); err != nil {
+		return err
+	}
+	s.publishTask(task)
 	return nil
 }
 
+// publishTask 发布任务的拷贝，订阅者读取时任务可能已被修改
+func (s *serviceImpl) publishTask(task *Task) {
+	c := *task
+	s.events.Publish(events.TypeTask, &c)
+}
+
 func (s *serviceImpl) DeleteTask(ctx context.Context, taskID string) error {
-	// TODO: 实现删除任务的逻辑
-	return nil
+	return s.tasks.delete(taskID)
 }
 
 func (s *serviceImpl) ListTasks(ctx context.Context) ([]*Task, error) {
-	// TODO: 实现获取任务列表的逻辑
-	return nil, nil
+	return s.tasks.list(), nil
 }
 
+// ReadFile 读取文件内容，超过配置的大小上限时返回错误
 func (s *serviceImpl) ReadFile(ctx context.Context, path string) ([]byte, error) {
-	// TODO: 实现读取文件的逻辑
-	return nil, nil
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if maxSize := int64(s.cfg.File.MaxFileSize); maxSize > 0 && info.Size() > maxSize {
+		return nil, fmt.Errorf("file too large: %d bytes exceeds limit %d", info.Size(), maxSize)
+	}
+	return os.ReadFile(path)
 }
 
+// WriteFile 写入文件内容，只读上下文源中的文件不能写入
+// 写入前先检查语法并记录预写日志，崩溃后可在启动时重放未完成的写入
 func (s *serviceImpl) WriteFile(ctx context.Context, path string, content []byte) error {
-	// TODO: 实现写入文件的逻辑
-	return nil
+	if err := s.checkWritable(path); err != nil {
+		return err
+	}
+	if err := checkSyntax(ctx, syntax.Mode(s.cfg.Syntax.Mode), path, content); err != nil {
+		return err
+	}
+	before, err := fileHash(path)
+	if err != nil {
+		return err
+	}
+	entry := &JournalEntry{
+		Op:      JournalOpWriteFile,
+		Path:    path,
+		Content: content,
+		Before:  before,
+	}
+	if err := s.journal.append(entry); err != nil {
+		return fmt.Errorf("failed to write journal: %v", err)
+	}
+	if err := writeFileAtomic(path, content); err != nil {
+		// 调用方已经收到错误，失败的写入不应在重启时重放
+		s.journal.markApplied(entry.ID)
+		return err
+	}
+	s.events.Publish(events.TypeFile, &FileWrite{Path: path, Size: len(content), SHA256: fmt.Sprintf("%x", sha256.Sum256(content))})
+	return s.journal.markApplied(entry.ID)
+}
+
+// FileWrite 是文件写入事件
+type FileWrite struct {
+	Path   string `json:"path"`
+	Size   int    `json:"size"`
+	SHA256 string `json:"sha256"` // 写入内容的哈希，审计时用于核对文件内容
+}
+
+// checkSyntax 按 mode 检查写入内容的语法，block 模式下返回 *syntax.InvalidError
+func checkSyntax(ctx context.Context, mode syntax.Mode, path string, content []byte) error {
+	if mode == syntax.ModeOff || syntax.IsOverridden(ctx) {
+		return nil
+	}
+	err := syntax.Validate(path, content)
+	if err != nil && mode == syntax.ModeFlag {
+		log.Printf("写入的内容存在语法错误: %v\n", err)
+		return nil
+	}
+	return err
+}
+
+// writeFileAtomic 先写入临时文件再重命名，避免崩溃时留下写了一半的文件
+func writeFileAtomic(path string, content []byte) error {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
 }
 
 func (s *serviceImpl) WatchFile(ctx context.Context, path string) (<-chan FileEvent, error) {
@@ -208,13 +728,170 @@ func (s *serviceImpl) WatchFile(ctx context.Context, path string) (<-chan FileEv
 	return events, nil
 }
 
+// ExecuteCommand 在配置的沙箱后端中执行命令
 func (s *serviceImpl) ExecuteCommand(ctx context.Context, cmd *Command) (*CommandResult, error) {
-	// TODO: 实现执行命令的逻辑
-	return nil, nil
+	cfg := s.cfg
+	if !permission.CommandAllowed(cmd.Command, s.AllowedCommands()) {
+		return nil, fmt.Errorf("command not allowed: %s", cmd.Command)
+	}
+	if _, err := s.CheckOutput(ctx, strings.Join(append([]string{cmd.Command}, cmd.Args...), " ")); err != nil {
+		return nil, err
+	}
+
+	runner, err := sandbox.New(sandbox.Config{
+		Backend:   sandbox.Backend(cfg.Sandbox.Backend),
+		Image:     cfg.Sandbox.Image,
+		Workspace: cfg.Sandbox.Workspace,
+		Network:   cfg.Sandbox.Network,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if cmd.ID == "" {
+		cmd.ID = uuid.New().String()
+	}
+
+	// 命令自带的超时优先于全局配置，单位为秒
+	timeout := cfg.Command.Timeout.Std()
+	if cmd.Timeout > 0 {
+		timeout = time.Duration(cmd.Timeout) * time.Second
+	}
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+
+	s.mu.Lock()
+	s.commands[cmd.ID] = cancel
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.commands, cmd.ID)
+		s.mu.Unlock()
+	}()
+
+	env := make([]string, 0, len(cmd.Env))
+	for k, v := range cmd.Env {
+		env = append(env, k+"="+v)
+	}
+	c, err := runner.Command(ctx, &sandbox.Spec{
+		Name: cmd.Command,
+		Args: cmd.Args,
+		Dir:  cmd.WorkDir,
+		Env:  env,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var stdout, stderr bytes.Buffer
+	c.Stdout = &stdout
+	c.Stderr = &stderr
+
+	result := &CommandResult{
+		ID:        cmd.ID,
+		StartTime: time.Now().Unix(),
+	}
+	err = c.Start()
+	if err == nil {
+		key := "command/" + cmd.ID
+		s.monitor.Track(key, c.Process.Pid, func(stats procmon.Stats, reason string) {
+			log.Printf("命令 %s 超出资源上限被终止: %s\n", cmd.ID, reason)
+		})
+		err = c.Wait()
+		s.monitor.Untrack(key)
+	}
+	result.EndTime = time.Now().Unix()
+	result.Stdout = stdout.String()
+	result.Stderr = stderr.String()
+
+	if err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			err = fmt.Errorf("failed to run command: %v", err)
+			s.recordHistory(cmd, result, err)
+			return nil, err
+		}
+		result.ExitCode = exitErr.ExitCode()
+	}
+	s.recordHistory(cmd, result, nil)
+	return result, nil
+}
+
+// RecordFeedback 保存插件上报的建议处理结果
+func (s *serviceImpl) RecordFeedback(ctx context.Context, fb *Feedback) (*Feedback, error) {
+	entry, err := s.feedback.record(fb)
+	if err != nil {
+		return nil, err
+	}
+	if entry.Experiment != "" && entry.Experiment == s.experiments.Name() {
+		s.experiments.RecordOutcome(entry.Variant, string(entry.Outcome))
+	}
+	return entry, nil
+}
+
+// GetExperimentReport 返回当前实验各分组的请求、延迟和接受率，没有实验时返回 nil
+func (s *serviceImpl) GetExperimentReport() *experiment.Report {
+	return s.experiments.Report()
+}
+
+// GetFeedbackStats 按模型和模板返回建议的接受率
+func (s *serviceImpl) GetFeedbackStats(ctx context.Context) ([]*FeedbackStats, error) {
+	return s.feedback.stats()
+}
+
+// recordHistory 将执行过的命令写入历史，来源和 agent 运行 ID 取自命令的元数据
+func (s *serviceImpl) recordHistory(cmd *Command, result *CommandResult, runErr error) {
+	source := cmd.Metadata["source"]
+	if source == "" {
+		source = "api"
+	}
+	entry := &HistoryEntry{
+		ID:        cmd.ID,
+		Command:   cmd.Command,
+		Args:      cmd.Args,
+		WorkDir:   cmd.WorkDir,
+		Source:    source,
+		RunID:     cmd.Metadata["run_id"],
+		ExitCode:  result.ExitCode,
+		Stdout:    result.Stdout,
+		Stderr:    result.Stderr,
+		StartTime: time.Unix(result.StartTime, 0),
+		EndTime:   time.Unix(result.EndTime, 0),
+	}
+	if runErr != nil {
+		entry.Error = runErr.Error()
+	}
+	if err := s.history.append(entry); err != nil {
+		log.Printf("记录命令历史失败: %v\n", err)
+	}
+	s.events.Publish(events.TypeCommand, entry)
+}
+
+// SearchCommandHistory 按条件搜索命令历史，最近的在前
+func (s *serviceImpl) SearchCommandHistory(ctx context.Context, query *HistoryQuery) ([]*HistoryEntry, error) {
+	return s.history.search(query)
+}
+
+// GetCommandHistory 获取一条命令历史
+func (s *serviceImpl) GetCommandHistory(ctx context.Context, cmdID string) (*HistoryEntry, error) {
+	return s.history.get(cmdID)
 }
 
+// CancelCommand 取消正在执行的命令
 func (s *serviceImpl) CancelCommand(ctx context.Context, cmdID string) error {
-	// TODO: 实现取消命令的逻辑
+	s.mu.RLock()
+	cancel, exists := s.commands[cmdID]
+	s.mu.RUnlock()
+
+	if !exists {
+		return errors.New("command not found")
+	}
+	cancel()
 	return nil
 }
 
@@ -223,32 +900,185 @@ func (s *serviceImpl) GenerateResponse(ctx context.Context, prompt string) (stri
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	if s.model == nil {
+	return s.generate(ctx, s.model, prompt)
+}
+
+// generate 使用指定模型生成响应，调用方需持有读锁
+func (s *serviceImpl) generate(ctx context.Context, model models.Model, prompt string) (string, error) {
+	prompt, err := s.prepare(model, prompt)
+	if err != nil {
+		return "", err
+	}
+	return s.watchModel(ctx, model, func(ctx context.Context) (string, error) {
+		return model.Generate(ctx, prompt)
+	})
+}
+
+// watchModel 在监视器下执行模型调用，期限过后仍未返回时放弃等待并释放调用方持有的服务锁
+func (s *serviceImpl) watchModel(ctx context.Context, model models.Model, fn func(context.Context) (string, error)) (string, error) {
+	return watchdog.Run(s.watchdog, ctx, "model", string(model.GetModelType()), s.cfg.Watchdog.ModelTimeout.Std(), nil, fn)
+}
+
+// prepare 检查模型是否可用并返回脱敏后的提示词
+func (s *serviceImpl) prepare(model models.Model, prompt string) (string, error) {
+	if model == nil {
 		return "", errors.New("no AI model configured")
 	}
+	if s.offline.Offline() && !model.GetModelType().IsLocal() {
+		return "", offline.ErrOffline
+	}
 
-	return s.model.Generate(ctx, prompt)
+	// 提示词中的疑似密钥在发送给模型提供商之前脱敏
+	prompt, _ = s.RedactSecrets(prompt)
+	return prompt, nil
+}
+
+// generateWithModel 使用实验分组指定的模型生成响应，name 为空时使用当前模型
+func (s *serviceImpl) generateWithModel(ctx context.Context, name, prompt string) (string, error) {
+	if name == "" {
+		return s.GenerateResponse(ctx, prompt)
+	}
+
+	s.mu.Lock()
+	model, ok := s.variantModels[name]
+	if !ok {
+		var err error
+		model, err = models.NewModel(modelConfig(s.cfg, models.ModelType(name), s.limiter, s.keys))
+		if err != nil {
+			s.mu.Unlock()
+			return "", err
+		}
+		s.variantModels[name] = model
+	}
+	s.mu.Unlock()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.generate(ctx, model, prompt)
+}
+
+// CheckOutput 扫描模型输出中的危险内容，命中的规则会记录日志
+func (s *serviceImpl) CheckOutput(ctx context.Context, text string) ([]filter.Finding, error) {
+	findings, err := s.filter.Check(ctx, text)
+	for _, f := range findings {
+		log.Printf("输出过滤命中规则 %s: %s\n", f.Rule, f.Match)
+	}
+	return findings, err
+}
+
+// RedactSecrets 脱敏文本中的疑似密钥，命中时记录警告日志
+func (s *serviceImpl) RedactSecrets(text string) (string, []secrets.Finding) {
+	redacted, findings := s.secrets.Redact(text)
+	for _, f := range findings {
+		log.Printf("警告: 第 %d 行疑似包含密钥 (%s: %s)，已脱敏\n", f.Line, f.Rule, f.Preview)
+	}
+	return redacted, findings
 }
 
 func (s *serviceImpl) SwitchModel(ctx context.Context, modelType models.ModelType) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	config := models.ModelConfig{
-		ModelType:   modelType,
-		MaxTokens:   4096,
-		Temperature: 0.7,
-	}
+	return s.switchModel(modelType)
+}
 
-	model, err := models.NewModel(config)
+// ApplySetup 在持有服务锁时更新配置向导修改的配置项，模型配置和允许的命令只在这个锁内读写
+func (s *serviceImpl) ApplySetup(ctx context.Context, written *config.Config) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cfg.Model.Type = written.Model.Type
+	s.cfg.Model.APIKey = written.Model.APIKey
+	s.cfg.Model.APIKeyFile = written.Model.APIKeyFile
+	s.cfg.Command.AllowedCmds = written.Command.AllowedCmds
+	return s.switchModel(written.Model.Type)
+}
+
+// SetupStatus 返回运行中的配置的向导状态
+func (s *serviceImpl) SetupStatus() *setup.Status {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return setup.CurrentStatus(s.cfg)
+}
+
+// AllowedCommands 返回允许执行的命令的副本
+func (s *serviceImpl) AllowedCommands() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return slices.Clone(s.cfg.Command.AllowedCmds)
+}
+
+// switchModel 创建并切换到 modelType 的模型，调用方需持有 s.mu 的写锁
+func (s *serviceImpl) switchModel(modelType models.ModelType) error {
+	// 不同提供商的限流相互独立，切换模型时重新开始记录
+	cfg := s.cfg
+	limiter, keys := newRateLimiter(cfg), newKeyPool(cfg)
+	model, err := models.NewModel(modelConfig(cfg, modelType, limiter, keys))
 	if err != nil {
 		return err
 	}
 
 	s.model = model
+	s.limiter = limiter
+	s.keys = keys
+	s.variantModels = make(map[string]models.Model)
 	return nil
 }
 
+// modelConfig 根据配置构造指定类型的模型配置，生成参数使用该类型生效的默认值
+func modelConfig(cfg *config.Config, modelType models.ModelType, limiter *models.RateLimiter, keys *models.KeyPool) models.ModelConfig {
+	defaults := cfg.ModelDefaults(modelType)
+	return models.ModelConfig{
+		APIKey:      cfg.Model.APIKey,
+		ModelType:   modelType,
+		MaxTokens:   defaults.MaxTokens,
+		Temperature: defaults.Temperature,
+		TopP:        defaults.TopP,
+		Stop:        defaults.Stop,
+		RateLimiter: limiter,
+		KeyPool:     keys,
+		BaseURL:     cfg.Model.BaseURL,
+		Model:       defaults.Model,
+	}
+}
+
+// newKeyPool 根据配置创建 API Key 池，APIKey 排在 APIKeys 之前
+func newKeyPool(cfg *config.Config) *models.KeyPool {
+	keys := append([]string{cfg.Model.APIKey}, cfg.Model.APIKeys...)
+	return models.NewKeyPool(keys, models.KeyRotation(cfg.Model.KeyRotation))
+}
+
+// GetKeyUsage 返回每个 API Key 的使用统计
+func (s *serviceImpl) GetKeyUsage() []models.KeyUsage {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.keys.Usage()
+}
+
+// newRateLimiter 根据配置创建模型请求的限流器
+func newRateLimiter(cfg *config.Config) *models.RateLimiter {
+	return models.NewRateLimiter(cfg.Model.MaxRateLimitWait.Std())
+}
+
+// GetRateLimit 返回当前模型提供商最近一次的限流信息
+func (s *serviceImpl) GetRateLimit() models.RateLimitInfo {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.limiter.Info()
+}
+
+// TestModel 对当前模型执行一次最小生成，检查连通性和 API Key
+func (s *serviceImpl) TestModel(ctx context.Context) *models.TestResult {
+	s.mu.RLock()
+	model, keys := s.model, s.keys
+	s.mu.RUnlock()
+
+	if model == nil {
+		return &models.TestResult{Error: "no AI model configured", TestedAt: time.Now()}
+	}
+	return models.SelfTest(ctx, model, keys.Len() > 0)
+}
+
 func (s *serviceImpl) GetCurrentModel() models.ModelType {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -260,6 +1090,36 @@ func (s *serviceImpl) GetCurrentModel() models.ModelType {
 	return s.model.GetModelType()
 }
 
+// GetModelDefaults 返回当前模型生效的默认生成参数，包含 model.profiles 中该模型的设置
+func (s *serviceImpl) GetModelDefaults() models.Defaults {
+	return s.cfg.ModelDefaults(s.GetCurrentModel())
+}
+
+// ModelInfo 是可选模型的能力、生效的默认生成参数和当前状态
+type ModelInfo struct {
+	models.Info
+	Params    models.Defaults `json:"params"`
+	Current   bool            `json:"current"`
+	Available bool            `json:"available"` // 离线模式下只有本地模型可用
+}
+
+// ListModels 返回当前构建支持的所有模型，按 models.SupportedModelTypes 的顺序
+func (s *serviceImpl) ListModels() []*ModelInfo {
+	current := s.GetCurrentModel()
+	offline := s.offline.Status().Offline
+	var list []*ModelInfo
+	for _, t := range models.SupportedModelTypes() {
+		info := t.Info()
+		list = append(list, &ModelInfo{
+			Info:      info,
+			Params:    s.cfg.ModelDefaults(t),
+			Current:   t == current,
+			Available: !offline || info.Local,
+		})
+	}
+	return list
+}
+
 // GetContextManager 返回上下文管理器
 func (s *serviceImpl) GetContextManager() ContextManager {
 	return s.contextManager
@@ -269,3 +1129,105 @@ func (s *serviceImpl) GetContextManager() ContextManager {
 func (s *serviceImpl) GetMCPManager() mcp.ToolManager {
 	return s.mcpManager
 }
+
+// Config 返回服务使用的配置
+func (s *serviceImpl) Config() *config.Config {
+	return s.cfg
+}
+
+// Events 返回服务的事件总线
+func (s *serviceImpl) Events() *events.Bus {
+	return s.events
+}
+
+// Audit 返回审计日志，未启用时返回 nil
+func (s *serviceImpl) Audit() *audit.Log {
+	return s.audit
+}
+
+// GetProcessStats 返回本地 MCP 服务器和正在执行的命令的资源占用
+// 键的格式为 server/<id> 或 command/<id>
+func (s *serviceImpl) GetProcessStats(ctx context.Context) map[string]procmon.Stats {
+	return s.monitor.Stats()
+}
+
+// Recover 在启动时加载持久化状态并与实际情况对账：
+// 重放未完成的文件写入，处理中断的任务，重置进程已不存在的 MCP 服务器
+func (s *serviceImpl) Recover(ctx context.Context) (*RecoveryReport, error) {
+	report := &RecoveryReport{}
+
+	var err error
+	if report.ReplayedWrites, report.SkippedWrites, err = s.journal.replay(); err != nil {
+		return nil, err
+	}
+
+	if err := s.tasks.load(); err != nil {
+		return nil, fmt.Errorf("failed to load tasks: %v", err)
+	}
+	report.ResumedTasks, report.FailedTasks, err = s.tasks.recover()
+	if err != nil {
+		return nil, err
+	}
+
+	report.ResetServers, err = s.mcpManager.Recover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// 上次退出前排队的生成请求
+	go s.drainDeferred(s.background)
+	return report, nil
+}
+
+// GetConnectivity 返回当前联网状态
+func (s *serviceImpl) GetConnectivity() offline.Status {
+	return s.offline.Status()
+}
+
+// SetOffline 开启或关闭显式离线模式
+func (s *serviceImpl) SetOffline(enabled bool) {
+	s.offline.SetForced(enabled)
+}
+
+// DeferGeneration 将非紧急的生成请求排队，联网时立即开始执行
+// 提示词在入队前脱敏，避免密钥落盘
+func (s *serviceImpl) DeferGeneration(ctx context.Context, prompt string) (*DeferredGeneration, error) {
+	prompt, _ = s.RedactSecrets(prompt)
+	item, err := s.deferred.add(prompt)
+	if err != nil {
+		return nil, err
+	}
+	if !s.offline.Offline() {
+		go s.drainDeferred(s.background)
+	}
+	return item, nil
+}
+
+// GetDeferredGeneration 获取排队的生成请求
+func (s *serviceImpl) GetDeferredGeneration(id string) (*DeferredGeneration, error) {
+	return s.deferred.get(id)
+}
+
+// ListDeferredGenerations 列出所有排队的生成请求
+func (s *serviceImpl) ListDeferredGenerations() []*DeferredGeneration {
+	return s.deferred.list()
+}
+
+// drainDeferred 依次执行排队的生成请求，再次离线时停止
+func (s *serviceImpl) drainDeferred(ctx context.Context) {
+	s.drainMu.Lock()
+	defer s.drainMu.Unlock()
+
+	for _, item := range s.deferred.queued() {
+		if s.offline.Offline() {
+			return
+		}
+		response, err := s.GenerateResponse(ctx, item.Prompt)
+		if errors.Is(err, offline.ErrOffline) {
+			return
+		}
+		if err := s.deferred.complete(item.ID, response, err); err != nil {
+			log.Printf("保存排队的生成结果失败: %v\n", err)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/liangsj/vimcoplit/internal/config"
+	"github.com/liangsj/vimcoplit/internal/models"
+)
+
+// newTestService 创建使用临时工作区和数据目录的服务，模型按 responses 依次响应
+func newTestService(t *testing.T, responses ...models.MockResponse) (*serviceImpl, *models.MockModel) {
+	t.Helper()
+	cfg := config.DefaultConfig()
+	cfg.Workspace.Root = t.TempDir()
+	cfg.Storage.DataDir = t.TempDir()
+	cfg.Titles.Enabled = false
+	cfg.RepoMap.Enabled = false
+	cfg.Offline.AutoDetect = false
+
+	s := NewService(cfg).(*serviceImpl)
+	t.Cleanup(func() { s.Close() })
+	model := models.NewMockModel("", responses...)
+	s.model = model
+	return s, model
+}
@@ -0,0 +1,398 @@
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/liangsj/vimcoplit/internal/core/command"
+	"github.com/liangsj/vimcoplit/internal/core/dbquery"
+	"github.com/liangsj/vimcoplit/internal/core/index"
+	"github.com/liangsj/vimcoplit/internal/models"
+	"github.com/liangsj/vimcoplit/internal/permission"
+)
+
+// AutoApproveLevel 表示 agent 计划的自动审批级别
+type AutoApproveLevel string
+
+const (
+	AutoApproveNone  AutoApproveLevel = "none"  // 所有计划都需要用户审批
+	AutoApproveEdits AutoApproveLevel = "edits" // 只包含写文件和说明步骤的计划自动审批
+	AutoApproveAll   AutoApproveLevel = "all"   // 所有计划自动审批，输出过滤和上限仍然生效
+)
+
+// DirtyPolicy 表示 agent 运行要修改的文件有用户未提交的修改时的处理方式
+type DirtyPolicy string
+
+const (
+	DirtyAllow    DirtyPolicy = "allow"    // 直接在用户的修改上合并 agent 的修改
+	DirtyRefuse   DirtyPolicy = "refuse"   // 暂停运行，等用户提交或暂存修改
+	DirtyStash    DirtyPolicy = "stash"    // 运行前暂存修改，运行结束后恢复
+	DirtyWorktree DirtyPolicy = "worktree" // 在工作区副本的任务分支上运行，不动用户的工作区
+)
+
+// WorkspaceSettings 是当前工作区的运行时设置，保存在工作区的 .vimcoplit/settings.json 中，
+// 插件可以直接修改而不需要编辑全局配置
+type WorkspaceSettings struct {
+	Model          models.ModelType   `json:"model,omitempty"` // 当前使用的模型，为空时使用全局配置
+	AutoApprove    AutoApproveLevel   `json:"auto_approve"`
+	ContextBudget  int                `json:"context_budget"`  // 自动加入提示词的上下文的 token 上限，0 表示不限制
+	IgnorePatterns []string           `json:"ignore_patterns"` // 不加入自动上下文和仓库地图的文件，如 *.pb.go、testdata/
+	IndexScope     index.Scope        `json:"index"`           // 代码检索索引的范围
+	ContextSources []ContextSource    `json:"context_sources"` // 只读的其他仓库，参与代码检索
+	Permissions    []permission.Rule  `json:"permissions"`     // agent 动作的策略规则，按顺序匹配第一条
+	Commands       []command.Command  `json:"commands"`        // 用户定义的聊天斜杠命令，与内置命令同名时覆盖内置命令
+	Databases      []dbquery.Database `json:"databases"`       // sql_query 工具可以查询的数据库，连接串从环境变量或文件读取
+	TaskBranches   bool               `json:"task_branches"`   // agent 任务在专用分支上执行并逐步提交，结束后切回原分支
+	DirtyFiles     DirtyPolicy        `json:"dirty_files"`     // 要修改的文件有未提交的修改时的处理方式，为空时同 allow
+	UpdatedAt      time.Time          `json:"updated_at,omitempty"`
+}
+
+// SettingsPatch 是对工作区设置的部分修改，为 nil 的字段保持不变
+type SettingsPatch struct {
+	Model          *models.ModelType   `json:"model"`
+	AutoApprove    *AutoApproveLevel   `json:"auto_approve"`
+	ContextBudget  *int                `json:"context_budget"`
+	IgnorePatterns *[]string           `json:"ignore_patterns"`
+	IndexScope     *index.Scope        `json:"index"`
+	ContextSources *[]ContextSource    `json:"context_sources"`
+	Permissions    *[]permission.Rule  `json:"permissions"`
+	Commands       *[]command.Command  `json:"commands"`
+	Databases      *[]dbquery.Database `json:"databases"`
+	TaskBranches   *bool               `json:"task_branches"`
+	DirtyFiles     *DirtyPolicy        `json:"dirty_files"`
+}
+
+// Ignored 判断相对工作区根目录的路径是否匹配忽略规则，以 / 结尾的规则匹配目录
+func (ws *WorkspaceSettings) Ignored(rel string) bool {
+	rel = filepath.ToSlash(rel)
+	for _, pattern := range ws.IgnorePatterns {
+		if dir, ok := strings.CutSuffix(pattern, "/"); ok {
+			if rel == dir || strings.HasPrefix(rel, dir+"/") || strings.Contains(rel, "/"+dir+"/") {
+				return true
+			}
+			continue
+		}
+		if matched, _ := filepath.Match(pattern, rel); matched {
+			return true
+		}
+		if matched, _ := filepath.Match(pattern, filepath.Base(rel)); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// validate 检查设置是否有效，root 为工作区根目录
+func (ws *WorkspaceSettings) validate(root string) error {
+	switch ws.AutoApprove {
+	case AutoApproveNone, AutoApproveEdits, AutoApproveAll:
+	default:
+		return fmt.Errorf("invalid auto_approve level %q", ws.AutoApprove)
+	}
+	switch ws.DirtyFiles {
+	case "", DirtyAllow, DirtyRefuse, DirtyStash, DirtyWorktree:
+	default:
+		return fmt.Errorf("invalid dirty_files policy %q", ws.DirtyFiles)
+	}
+	if ws.ContextBudget < 0 {
+		return fmt.Errorf("context_budget must not be negative")
+	}
+	for _, pattern := range ws.IgnorePatterns {
+		if _, err := filepath.Match(strings.TrimSuffix(pattern, "/"), ""); err != nil {
+			return fmt.Errorf("invalid ignore pattern %q: %v", pattern, err)
+		}
+	}
+	for i := range ws.Permissions {
+		if err := ws.Permissions[i].Validate(); err != nil {
+			return err
+		}
+	}
+	names := make(map[string]bool)
+	for i := range ws.ContextSources {
+		cs := &ws.ContextSources[i]
+		if err := cs.validate(root); err != nil {
+			return err
+		}
+		if names[cs.Name] {
+			return fmt.Errorf("duplicate context source %q", cs.Name)
+		}
+		names[cs.Name] = true
+	}
+	commands := make(map[string]bool)
+	for i := range ws.Commands {
+		c := &ws.Commands[i]
+		if err := c.Validate(); err != nil {
+			return err
+		}
+		if commands[c.Name] {
+			return fmt.Errorf("duplicate command %q", c.Name)
+		}
+		commands[c.Name] = true
+	}
+	databases := make(map[string]bool)
+	for i := range ws.Databases {
+		d := &ws.Databases[i]
+		if err := d.Validate(); err != nil {
+			return err
+		}
+		if databases[d.Name] {
+			return fmt.Errorf("duplicate database %q", d.Name)
+		}
+		databases[d.Name] = true
+	}
+	return ws.IndexScope.Validate()
+}
+
+// sensitiveSettings 是工作区设置中放宽审批或让助手访问工作区以外数据的部分：自动审批级别、
+// allow 规则、上下文源和数据库。设置文件随仓库分发，这些设置只有经过 API 保存后才生效
+type sensitiveSettings struct {
+	AutoApprove    AutoApproveLevel   `json:"auto_approve"`
+	Allow          []permission.Rule  `json:"allow"`
+	ContextSources []ContextSource    `json:"context_sources"`
+	Databases      []dbquery.Database `json:"databases"`
+}
+
+// sensitiveHash 返回设置中敏感部分的摘要，没有敏感设置时返回空字符串
+func (ws *WorkspaceSettings) sensitiveHash() string {
+	sensitive := sensitiveSettings{
+		AutoApprove:    ws.AutoApprove,
+		ContextSources: ws.ContextSources,
+		Databases:      ws.Databases,
+	}
+	for _, rule := range ws.Permissions {
+		if rule.Decision == permission.DecisionAllow {
+			sensitive.Allow = append(sensitive.Allow, rule)
+		}
+	}
+	if sensitive.AutoApprove == AutoApproveNone && sensitive.Allow == nil && sensitive.ContextSources == nil && sensitive.Databases == nil {
+		return ""
+	}
+	data, _ := json.Marshal(sensitive)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// dropSensitive 去掉设置中的敏感部分，只保留 deny 和 ask 规则
+func (ws *WorkspaceSettings) dropSensitive() {
+	ws.AutoApprove = AutoApproveNone
+	var rules []permission.Rule
+	for _, rule := range ws.Permissions {
+		if rule.Decision != permission.DecisionAllow {
+			rules = append(rules, rule)
+		}
+	}
+	ws.Permissions = rules
+	ws.ContextSources = nil
+	ws.Databases = nil
+}
+
+// settingsStore 是工作区设置的持久化存储。confirmedPath 保存在数据目录中，
+// 记录每个设置文件最近一次经过 API 保存时敏感部分的摘要
+type settingsStore struct {
+	mu            sync.RWMutex
+	path          string
+	confirmedPath string
+	settings      WorkspaceSettings
+}
+
+// newSettingsStore 创建工作区设置存储，并加载已有设置。设置文件无效时记录日志后使用默认设置，
+// 敏感部分与 confirmedPath 中记录的摘要不一致时（如随仓库检出或被手工修改）被忽略，需要通过 API 重新保存。
+// confirmedPath 为空时所有敏感设置都被忽略
+func newSettingsStore(path, confirmedPath string) *settingsStore {
+	s := &settingsStore{
+		path:          path,
+		confirmedPath: confirmedPath,
+		settings:      WorkspaceSettings{AutoApprove: AutoApproveNone},
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return s
+	}
+	loaded := WorkspaceSettings{AutoApprove: AutoApproveNone}
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		log.Printf("工作区设置 %s 无效，使用默认设置: %v\n", path, err)
+		return s
+	}
+	if hash := loaded.sensitiveHash(); hash != "" && hash != s.confirmed()[path] {
+		log.Printf("工作区设置 %s 中的 auto_approve、allow 规则、context_sources 和 databases 未经确认，已忽略，请通过 PATCH /api/v1/settings 保存\n", path)
+		loaded.dropSensitive()
+	}
+	if err := loaded.validate(filepath.Dir(filepath.Dir(path))); err != nil {
+		log.Printf("工作区设置 %s 无效，使用默认设置: %v\n", path, err)
+		return s
+	}
+	s.settings = loaded
+	return s
+}
+
+// confirmed 读取已确认的设置摘要，文件不存在或无效时返回空表
+func (s *settingsStore) confirmed() map[string]string {
+	hashes := make(map[string]string)
+	if s.confirmedPath == "" {
+		return hashes
+	}
+	if data, err := os.ReadFile(s.confirmedPath); err == nil {
+		json.Unmarshal(data, &hashes)
+	}
+	return hashes
+}
+
+// get 返回设置的副本
+func (s *settingsStore) get() *WorkspaceSettings {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return copySettings(&s.settings)
+}
+
+// copySettings 复制设置，避免调用方修改共享的切片
+func copySettings(ws *WorkspaceSettings) *WorkspaceSettings {
+	c := *ws
+	c.IgnorePatterns = append([]string(nil), ws.IgnorePatterns...)
+	c.Permissions = append([]permission.Rule(nil), ws.Permissions...)
+	c.Commands = append([]command.Command(nil), ws.Commands...)
+	c.Databases = append([]dbquery.Database(nil), ws.Databases...)
+	c.IndexScope = ws.IndexScope.Copy()
+	c.ContextSources = make([]ContextSource, len(ws.ContextSources))
+	for i, cs := range ws.ContextSources {
+		cs.IndexScope = cs.IndexScope.Copy()
+		c.ContextSources[i] = cs
+	}
+	return &c
+}
+
+// save 保存到文件，并记录敏感部分已经确认，调用方需持有锁
+func (s *settingsStore) save() error {
+	data, err := json.MarshalIndent(s.settings, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(s.path, data, 0644); err != nil {
+		return err
+	}
+	if s.confirmedPath == "" {
+		return nil
+	}
+	hashes := s.confirmed()
+	if hash := s.settings.sensitiveHash(); hash != "" {
+		hashes[s.path] = hash
+	} else {
+		delete(hashes, s.path)
+	}
+	if data, err = json.MarshalIndent(hashes, "", "  "); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.confirmedPath), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(s.confirmedPath, data, 0600)
+}
+
+// GetSettings 返回当前工作区的设置
+func (s *serviceImpl) GetSettings(ctx context.Context) *WorkspaceSettings {
+	return s.settings.get()
+}
+
+// UpdateSettings 修改工作区设置并立即生效，修改模型时切换到该模型
+func (s *serviceImpl) UpdateSettings(ctx context.Context, patch *SettingsPatch) (*WorkspaceSettings, error) {
+	store := s.settings
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	updated := store.settings
+	if patch.Model != nil {
+		updated.Model = *patch.Model
+	}
+	if patch.AutoApprove != nil {
+		updated.AutoApprove = *patch.AutoApprove
+	}
+	if patch.ContextBudget != nil {
+		updated.ContextBudget = *patch.ContextBudget
+	}
+	if patch.IgnorePatterns != nil {
+		updated.IgnorePatterns = append([]string(nil), (*patch.IgnorePatterns)...)
+	}
+	if patch.IndexScope != nil {
+		updated.IndexScope = patch.IndexScope.Copy()
+	}
+	if patch.ContextSources != nil {
+		updated.ContextSources = append([]ContextSource(nil), (*patch.ContextSources)...)
+	}
+	if patch.Permissions != nil {
+		updated.Permissions = append([]permission.Rule(nil), (*patch.Permissions)...)
+	}
+	if patch.Commands != nil {
+		updated.Commands = append([]command.Command(nil), (*patch.Commands)...)
+	}
+	if patch.Databases != nil {
+		updated.Databases = append([]dbquery.Database(nil), (*patch.Databases)...)
+	}
+	if patch.TaskBranches != nil {
+		updated.TaskBranches = *patch.TaskBranches
+	}
+	if patch.DirtyFiles != nil {
+		updated.DirtyFiles = *patch.DirtyFiles
+	}
+	if err := updated.validate(s.cfg.WorkspaceRoot()); err != nil {
+		return nil, err
+	}
+
+	if patch.Model != nil && updated.Model != "" && updated.Model != s.GetCurrentModel() {
+		if err := s.SwitchModel(ctx, updated.Model); err != nil {
+			return nil, err
+		}
+	}
+	if patch.IgnorePatterns != nil {
+		s.repoMap.SetIgnore(updated.Ignored)
+		s.codeIndex.SetIgnore(updated.Ignored)
+	}
+	if patch.IndexScope != nil {
+		s.codeIndex.SetScope(updated.IndexScope)
+	}
+	if patch.ContextSources != nil {
+		s.sources.set(updated.ContextSources)
+	}
+	if patch.Databases != nil {
+		s.databases.Set(updated.Databases)
+	}
+
+	updated.UpdatedAt = time.Now()
+	previous := store.settings
+	store.settings = updated
+	if err := store.save(); err != nil {
+		store.settings = previous
+		return nil, err
+	}
+	return copySettings(&updated), nil
+}
+
+// AddPermissionRule 在工作区的策略规则最前面加入一条规则并保存
+func (s *serviceImpl) AddPermissionRule(ctx context.Context, rule permission.Rule) error {
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+	store := s.settings
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	previous := store.settings
+	store.settings.Permissions = append([]permission.Rule{rule}, previous.Permissions...)
+	store.settings.UpdatedAt = time.Now()
+	if err := store.save(); err != nil {
+		store.settings = previous
+		return err
+	}
+	return nil
+}
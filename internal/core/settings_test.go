@@ -0,0 +1,69 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/liangsj/vimcoplit/internal/permission"
+)
+
+func TestSettingsStoreLoad(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, ".vimcoplit", "settings.json")
+	confirmed := filepath.Join(t.TempDir(), "confirmed_settings.json")
+	write := func(content string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 随仓库检出的设置文件不能放宽审批或附加外部数据
+	write(`{"auto_approve": "all", "context_budget": 100, "permissions": [
+		{"operation": "command", "target": "*", "decision": "allow"},
+		{"operation": "command", "target": "rm *", "decision": "deny"}],
+		"databases": [{"name": "app", "driver": "sqlite", "dsn_env": "APP_DB"}]}`)
+	ws := newSettingsStore(path, confirmed).get()
+	if ws.AutoApprove != AutoApproveNone || len(ws.Databases) != 0 || ws.ContextBudget != 100 {
+		t.Errorf("expected unconfirmed sensitive settings to be ignored, got %+v", ws)
+	}
+	if len(ws.Permissions) != 1 || ws.Permissions[0].Decision != permission.DecisionDeny {
+		t.Errorf("expected only the deny rule to be kept, got %+v", ws.Permissions)
+	}
+
+	// 经过 API 保存后生效，之后再被修改又会被忽略
+	store := newSettingsStore(path, confirmed)
+	store.settings.AutoApprove = AutoApproveEdits
+	store.settings.Permissions = []permission.Rule{{Operation: permission.OpTool, Target: "sql_query", Decision: permission.DecisionAllow}}
+	if err := store.save(); err != nil {
+		t.Fatal(err)
+	}
+	ws = newSettingsStore(path, confirmed).get()
+	if ws.AutoApprove != AutoApproveEdits || len(ws.Permissions) != 1 {
+		t.Errorf("expected confirmed settings to be loaded, got %+v", ws)
+	}
+	if ws := newSettingsStore(path, "").get(); ws.AutoApprove != AutoApproveNone {
+		t.Errorf("expected sensitive settings to be ignored without confirmations, got %+v", ws)
+	}
+	write(`{"auto_approve": "all"}`)
+	if ws := newSettingsStore(path, confirmed).get(); ws.AutoApprove != AutoApproveNone {
+		t.Errorf("expected a modified file to need confirmation again, got %s", ws.AutoApprove)
+	}
+
+	// 无效的设置文件使用默认设置
+	for _, content := range []string{
+		`{"context_budget": `,
+		`{"context_budget": -1, "ignore_patterns": ["vendor/"]}`,
+		`{"ignore_patterns": ["vendor/"], "permissions": [{"operation": "teleport", "decision": "deny"}]}`,
+	} {
+		write(content)
+		ws := newSettingsStore(path, confirmed).get()
+		if ws.AutoApprove != AutoApproveNone || ws.ContextBudget != 0 || len(ws.IgnorePatterns) != 0 || len(ws.Permissions) != 0 {
+			t.Errorf("expected defaults for %s, got %+v", content, ws)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package shell
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Kind 表示 shell 的种类
+type Kind string
+
+const (
+	KindSh   Kind = "sh"
+	KindBash Kind = "bash"
+	KindZsh  Kind = "zsh"
+	KindFish Kind = "fish"
+	KindPwsh Kind = "pwsh"
+)
+
+// Shell 描述用于执行脚本的 shell
+type Shell struct {
+	// Path 是 shell 可执行文件路径，为空时自动检测
+	Path string
+	// Login 表示以登录 shell 方式启动，以便加载 nvm、pyenv 等版本管理器的 PATH
+	Login bool
+	// RCFile 是执行脚本前需要加载的 rc 文件
+	RCFile string
+}
+
+// Detect 检测当前用户的 shell，优先使用 $SHELL
+func Detect() string {
+	if sh := os.Getenv("SHELL"); sh != "" {
+		return sh
+	}
+	for _, candidate := range []string{"bash", "zsh", "sh"} {
+		if path, err := exec.LookPath(candidate); err == nil {
+			return path
+		}
+	}
+	return "sh"
+}
+
+// Resolve 返回实际使用的 shell 路径
+func (s *Shell) Resolve() string {
+	if s.Path != "" {
+		return s.Path
+	}
+	return Detect()
+}
+
+// Kind 根据可执行文件名判断 shell 种类，无法识别时按 POSIX sh 处理
+func (s *Shell) Kind() Kind {
+	name := strings.TrimSuffix(filepath.Base(s.Resolve()), ".exe")
+	switch name {
+	case "bash":
+		return KindBash
+	case "zsh":
+		return KindZsh
+	case "fish":
+		return KindFish
+	case "pwsh", "powershell":
+		return KindPwsh
+	default:
+		return KindSh
+	}
+}
+
+// Command 返回执行脚本所需的可执行文件和参数
+func (s *Shell) Command(script string) (string, []string) {
+	path := s.Resolve()
+	kind := s.Kind()
+
+	if s.RCFile != "" {
+		script = sourceLine(kind, s.RCFile) + script
+	}
+
+	var args []string
+	switch kind {
+	case KindPwsh:
+		if s.Login {
+			// pwsh 要求 -Login 为第一个参数
+			args = append(args, "-Login")
+		}
+		args = append(args, "-NoLogo", "-Command", script)
+	default:
+		if s.Login {
+			args = append(args, "-l")
+		}
+		args = append(args, "-c", script)
+	}
+	return path, args
+}
+
+// Interactive 返回启动交互式 shell 所需的可执行文件和参数，登录方式和 rc 文件与 Command 一致。
+// POSIX shell 没有统一的“加载文件后进入交互”参数，加载 rc 文件后 exec 一个交互式 shell，
+// rc 文件导出的环境变量会保留，定义的函数和别名不会
+func (s *Shell) Interactive() (string, []string) {
+	path := s.Resolve()
+	kind := s.Kind()
+
+	var args []string
+	switch kind {
+	case KindPwsh:
+		if s.Login {
+			args = append(args, "-Login")
+		}
+		args = append(args, "-NoLogo")
+		if s.RCFile != "" {
+			args = append(args, "-NoExit", "-Command", strings.TrimSuffix(sourceLine(kind, s.RCFile), "; "))
+		}
+	case KindFish:
+		if s.Login {
+			args = append(args, "-l")
+		}
+		if s.RCFile != "" {
+			args = append(args, "-C", strings.TrimSuffix(sourceLine(kind, s.RCFile), "; "))
+		}
+		args = append(args, "-i")
+	default:
+		if s.Login {
+			args = append(args, "-l")
+		}
+		if s.RCFile != "" {
+			args = append(args, "-c", sourceLine(kind, s.RCFile)+"exec "+quote(path)+" -i")
+		} else {
+			args = append(args, "-i")
+		}
+	}
+	return path, args
+}
+
+// sourceLine 返回加载 rc 文件的语句
+func sourceLine(kind Kind, rcFile string) string {
+	switch kind {
+	case KindFish:
+		return "source " + fishQuote(rcFile) + "; "
+	case KindPwsh:
+		return ". '" + strings.ReplaceAll(rcFile, "'", "''") + "'; "
+	default:
+		return ". " + quote(rcFile) + "; "
+	}
+}
+
+// quote 使用 POSIX 单引号转义路径
+func quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// fishQuote 使用 fish 的单引号转义路径，fish 在单引号中仍然处理 \\ 和 \'
+func fishQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return "'" + strings.ReplaceAll(s, "'", `\'`) + "'"
+}
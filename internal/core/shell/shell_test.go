@@ -0,0 +1,123 @@
+package shell
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestKind(t *testing.T) {
+	for path, want := range map[string]Kind{
+		"/bin/bash":              KindBash,
+		"/usr/bin/zsh":           KindZsh,
+		"/opt/homebrew/bin/fish": KindFish,
+		"pwsh":                   KindPwsh,
+		"pwsh.exe":               KindPwsh,
+		"powershell.exe":         KindPwsh,
+		"/bin/dash":              KindSh,
+		"/bin/sh":                KindSh,
+	} {
+		s := &Shell{Path: path}
+		if got := s.Kind(); got != want {
+			t.Errorf("%s: expected %s, got %s", path, want, got)
+		}
+	}
+}
+
+func TestCommand(t *testing.T) {
+	tests := []struct {
+		name  string
+		shell Shell
+		want  []string
+	}{
+		{"sh", Shell{Path: "/bin/sh"}, []string{"-c", "echo hi"}},
+		{"bash login", Shell{Path: "/bin/bash", Login: true}, []string{"-l", "-c", "echo hi"}},
+		{"zsh rc file", Shell{Path: "/bin/zsh", RCFile: "/home/me/.zshrc"}, []string{"-c", ". '/home/me/.zshrc'; echo hi"}},
+		{"fish login rc file", Shell{Path: "/usr/bin/fish", Login: true, RCFile: "/home/me/env.fish"},
+			[]string{"-l", "-c", "source '/home/me/env.fish'; echo hi"}},
+		{"pwsh", Shell{Path: "pwsh"}, []string{"-NoLogo", "-Command", "echo hi"}},
+		// pwsh 要求 -Login 为第一个参数
+		{"pwsh login rc file", Shell{Path: "pwsh", Login: true, RCFile: "C:/Users/me/profile.ps1"},
+			[]string{"-Login", "-NoLogo", "-Command", ". 'C:/Users/me/profile.ps1'; echo hi"}},
+	}
+	for _, tt := range tests {
+		path, args := tt.shell.Command("echo hi")
+		if path != tt.shell.Path || !slices.Equal(args, tt.want) {
+			t.Errorf("%s: expected %s %q, got %s %q", tt.name, tt.shell.Path, tt.want, path, args)
+		}
+	}
+}
+
+func TestInteractive(t *testing.T) {
+	tests := []struct {
+		name  string
+		shell Shell
+		want  []string
+	}{
+		{"bash", Shell{Path: "/bin/bash"}, []string{"-i"}},
+		{"zsh login", Shell{Path: "/bin/zsh", Login: true}, []string{"-l", "-i"}},
+		{"sh rc file", Shell{Path: "/bin/sh", RCFile: "/home/me/.env"}, []string{"-c", ". '/home/me/.env'; exec '/bin/sh' -i"}},
+		{"fish rc file", Shell{Path: "/usr/bin/fish", Login: true, RCFile: "/home/me/env.fish"},
+			[]string{"-l", "-C", "source '/home/me/env.fish'", "-i"}},
+		{"pwsh", Shell{Path: "pwsh"}, []string{"-NoLogo"}},
+		{"pwsh login rc file", Shell{Path: "pwsh", Login: true, RCFile: "profile.ps1"},
+			[]string{"-Login", "-NoLogo", "-NoExit", "-Command", ". 'profile.ps1'"}},
+	}
+	for _, tt := range tests {
+		path, args := tt.shell.Interactive()
+		if path != tt.shell.Path || !slices.Equal(args, tt.want) {
+			t.Errorf("%s: expected %s %q, got %s %q", tt.name, tt.shell.Path, tt.want, path, args)
+		}
+	}
+}
+
+func TestSourceLine(t *testing.T) {
+	tests := []struct {
+		kind   Kind
+		rcFile string
+		want   string
+	}{
+		{KindBash, "/home/me/.bashrc", ". '/home/me/.bashrc'; "},
+		{KindSh, "/tmp/it's here/rc", `. '/tmp/it'\''s here/rc'; `},
+		{KindZsh, "/tmp/$HOME `x`/rc", ". '/tmp/$HOME `x`/rc'; "},
+		{KindFish, "/tmp/it's/rc", `source '/tmp/it\'s/rc'; `},
+		{KindFish, `/tmp/a\b/rc`, `source '/tmp/a\\b/rc'; `},
+		{KindPwsh, "C:/it's/profile.ps1", ". 'C:/it''s/profile.ps1'; "},
+	}
+	for _, tt := range tests {
+		if got := sourceLine(tt.kind, tt.rcFile); got != tt.want {
+			t.Errorf("%s %s: expected %q, got %q", tt.kind, tt.rcFile, tt.want, got)
+		}
+	}
+}
+
+// TestRCFile 用真实的 sh 执行脚本和交互式 shell，确认 rc 文件导出的变量可见，
+// 路径中的引号和空格被正确转义
+func TestRCFile(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh not available")
+	}
+	rcFile := filepath.Join(t.TempDir(), "it's an rc")
+	if err := os.WriteFile(rcFile, []byte("export VIMCOPLIT_RC=loaded\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s := &Shell{Path: sh, RCFile: rcFile}
+
+	path, args := s.Command(`echo "$VIMCOPLIT_RC"`)
+	out, err := exec.Command(path, args...).Output()
+	if err != nil || strings.TrimSpace(string(out)) != "loaded" {
+		t.Errorf("expected the rc file to be loaded, got %q %v", out, err)
+	}
+
+	path, args = s.Interactive()
+	cmd := exec.Command(path, args...)
+	cmd.Stdin = strings.NewReader("echo \"$VIMCOPLIT_RC\"\nexit\n")
+	out, err = cmd.Output()
+	if err != nil || strings.TrimSpace(string(out)) != "loaded" {
+		t.Errorf("expected the interactive shell to see the rc file, got %q %v", out, err)
+	}
+}
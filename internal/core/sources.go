@@ -0,0 +1,174 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/liangsj/vimcoplit/internal/core/index"
+)
+
+// sourceNamePattern 限制上下文源的名称，名称同时用作索引目录名
+var sourceNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// ContextSource 是附加到工作区的只读仓库，如在其他位置检出的共享库，
+// 参与代码检索但不允许通过助手修改
+type ContextSource struct {
+	Name       string      `json:"name"` // 检索结果中标识来源的名称
+	Path       string      `json:"path"` // 仓库根目录的绝对路径
+	IndexScope index.Scope `json:"index"`
+}
+
+// validate 检查上下文源是否有效，root 为工作区根目录
+func (cs *ContextSource) validate(root string) error {
+	if !sourceNamePattern.MatchString(cs.Name) {
+		return fmt.Errorf("invalid context source name %q", cs.Name)
+	}
+	if !filepath.IsAbs(cs.Path) {
+		return fmt.Errorf("context source %q: path must be absolute", cs.Name)
+	}
+	if info, err := os.Stat(cs.Path); err != nil || !info.IsDir() {
+		return fmt.Errorf("context source %q: %s is not a directory", cs.Name, cs.Path)
+	}
+	// 与工作区重叠时工作区中的文件也会变成只读
+	if root, err := filepath.Abs(root); err == nil && (within(root, cs.Path) || within(cs.Path, root)) {
+		return fmt.Errorf("context source %q overlaps the workspace", cs.Name)
+	}
+	return cs.IndexScope.Validate()
+}
+
+// SourceStatus 是一个上下文源的索引统计信息
+type SourceStatus struct {
+	Name  string      `json:"name"`
+	Path  string      `json:"path"`
+	Stats index.Stats `json:"stats"`
+}
+
+// source 是一个上下文源及其索引
+type source struct {
+	ContextSource
+	index *index.Index
+}
+
+// sourceSet 管理工作区的只读上下文源，每个源有独立的代码检索索引
+type sourceSet struct {
+	mu      sync.RWMutex
+	dir     string // 索引目录，每个源保存在以名称命名的子目录中
+	sources []*source
+
+	quantization index.Quantization // 源索引中向量的存储精度
+}
+
+// newSourceSet 创建上下文源集合
+func newSourceSet(dir string, quantization index.Quantization) *sourceSet {
+	return &sourceSet{dir: dir, quantization: quantization}
+}
+
+// set 替换上下文源，名称和路径都没有变化的源保留已加载的索引
+func (ss *sourceSet) set(list []ContextSource) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	previous := make(map[string]*source, len(ss.sources))
+	for _, src := range ss.sources {
+		previous[src.Name] = src
+	}
+	sources := make([]*source, 0, len(list))
+	for _, cs := range list {
+		cs.IndexScope = cs.IndexScope.Copy()
+		src := &source{ContextSource: cs}
+		if old := previous[cs.Name]; old != nil && old.Path == cs.Path {
+			src.index = old.index
+		} else {
+			src.index = index.New(cs.Path, filepath.Join(ss.dir, cs.Name))
+			src.index.SetQuantization(ss.quantization)
+		}
+		src.index.SetScope(cs.IndexScope)
+		sources = append(sources, src)
+	}
+	ss.sources = sources
+}
+
+// list 返回当前的上下文源
+func (ss *sourceSet) list() []*source {
+	ss.mu.RLock()
+	defer ss.mu.RUnlock()
+	return append([]*source(nil), ss.sources...)
+}
+
+// owner 返回包含 path 的上下文源名称，path 不在任何上下文源中时返回 false
+func (ss *sourceSet) owner(path string) (string, bool) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", false
+	}
+	for _, src := range ss.list() {
+		if within(src.Path, abs) {
+			return src.Name, true
+		}
+	}
+	return "", false
+}
+
+// within 判断 path 是否是 dir 或在 dir 之下
+func within(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// search 在所有上下文源中检索，索引为空的源先等待刷新完成。
+// 结果的 Path 为绝对路径，Source 为上下文源的名称
+func (ss *sourceSet) search(ctx context.Context, q *index.Query) ([][]index.Result, error) {
+	var lists [][]index.Result
+	for _, src := range ss.list() {
+		if src.index.Stats().Files == 0 {
+			if err := src.index.Refresh(ctx); err != nil {
+				return nil, fmt.Errorf("failed to index context source %q: %v", src.Name, err)
+			}
+		}
+		results, err := src.index.Search(ctx, q)
+		if err != nil {
+			return nil, err
+		}
+		for i := range results {
+			results[i].Path = filepath.Join(src.Path, filepath.FromSlash(results[i].Path))
+			results[i].Source = src.Name
+		}
+		lists = append(lists, results)
+	}
+	return lists, nil
+}
+
+// refresh 依次刷新所有上下文源的索引，单个源失败时记录日志并继续
+func (ss *sourceSet) refresh(ctx context.Context) {
+	for _, src := range ss.list() {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := src.index.Refresh(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("刷新上下文源 %s 的索引失败: %v\n", src.Name, err)
+		}
+	}
+}
+
+// status 返回所有上下文源的索引统计信息
+func (ss *sourceSet) status() []SourceStatus {
+	var statuses []SourceStatus
+	for _, src := range ss.list() {
+		statuses = append(statuses, SourceStatus{Name: src.Name, Path: src.Path, Stats: src.index.Stats()})
+	}
+	return statuses
+}
+
+// checkWritable 拒绝写入只读上下文源中的文件
+func (s *serviceImpl) checkWritable(path string) error {
+	if name, ok := s.sources.owner(path); ok {
+		return fmt.Errorf("%s belongs to read-only context source %q", path, name)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+// Package stacktrace 从编译器错误和运行时堆栈中解析出文件和行号
+package stacktrace
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Location 是错误文本中引用的源码位置，Line 和 Column 从 1 开始，Column 为 0 表示未知
+type Location struct {
+	Path     string `json:"path"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column,omitempty"`
+	Function string `json:"function,omitempty"`
+	Message  string `json:"message,omitempty"`
+}
+
+// patterns 是支持的错误格式，path、line、col、func、msg 为命名分组，
+// 按顺序尝试，每行只取第一个匹配的格式
+var patterns = []*regexp.Regexp{
+	// Python: File "app/main.py", line 12, in handler
+	regexp.MustCompile(`^\s*File "(?P<path>[^"]+)", line (?P<line>\d+)(?:, in (?P<func>\S+))?`),
+	// JavaScript/TypeScript: at handler (src/index.js:10:5) 或 at src/index.js:10:5
+	regexp.MustCompile(`^\s*at (?:(?P<func>[^\s(]+) \()?(?:file://)?(?P<path>[^\s():]+):(?P<line>\d+):(?P<col>\d+)\)?\s*$`),
+	// Java: at com.example.Main.run(Main.java:42)
+	regexp.MustCompile(`^\s*at (?P<func>[\w$.<>]+)\((?P<path>[\w$-]+\.\w+):(?P<line>\d+)\)`),
+	// Rust: --> src/main.rs:2:5
+	regexp.MustCompile(`^\s*--> (?P<path>[^\s:]+):(?P<line>\d+):(?P<col>\d+)`),
+	// Go 堆栈: \t/home/u/app/main.go:123 +0x1d
+	regexp.MustCompile(`^\s+(?P<path>[^\s:]+\.\w+):(?P<line>\d+)(?: \+0x[0-9a-f]+)?\s*$`),
+	// 编译器和 lint 工具: main.go:12:5: message 或 main.c(12): error
+	regexp.MustCompile(`^(?P<path>[^\s:()"]+\.\w+):(?P<line>\d+)(?::(?P<col>\d+))?:?\s*(?P<msg>.*)$`),
+	regexp.MustCompile(`^(?P<path>[^\s:()"]+\.\w+)\((?P<line>\d+)(?:,(?P<col>\d+))?\)\s*:?\s*(?P<msg>.*)$`),
+}
+
+// goFunc 匹配 Go 堆栈中位于文件行之前的函数行，如 main.handler(0x1, 0x2)
+var goFunc = regexp.MustCompile(`^([\w./*()-]+)\(.*\)$`)
+
+// Parse 解析错误文本中的源码位置，按出现顺序返回并去除重复的位置
+func Parse(text string) []Location {
+	var locations []Location
+	seen := make(map[string]bool)
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		loc, ok := parseLine(line)
+		if !ok {
+			continue
+		}
+		// Go 堆栈的函数名在上一行
+		if loc.Function == "" && i > 0 && strings.HasPrefix(line, "\t") {
+			if m := goFunc.FindStringSubmatch(strings.TrimSpace(lines[i-1])); m != nil {
+				loc.Function = m[1]
+			}
+		}
+		key := loc.Path + ":" + strconv.Itoa(loc.Line)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		locations = append(locations, loc)
+	}
+	return locations
+}
+
+// parseLine 用第一个匹配的格式解析一行
+func parseLine(line string) (Location, bool) {
+	for _, re := range patterns {
+		m := re.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		var loc Location
+		for i, name := range re.SubexpNames() {
+			switch name {
+			case "path":
+				loc.Path = m[i]
+			case "line":
+				loc.Line, _ = strconv.Atoi(m[i])
+			case "col":
+				loc.Column, _ = strconv.Atoi(m[i])
+			case "func":
+				loc.Function = m[i]
+			case "msg":
+				loc.Message = strings.TrimSpace(m[i])
+			}
+		}
+		if loc.Path == "" || loc.Line == 0 {
+			continue
+		}
+		return loc, true
+	}
+	return Location{}, false
+}
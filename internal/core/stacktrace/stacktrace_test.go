@@ -0,0 +1,60 @@
+package stacktrace
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []Location
+	}{
+		{
+			name: "go compiler",
+			text: "# example.com/app\n./main.go:12:5: undefined: foo\n./main.go:12:5: undefined: foo\n",
+			want: []Location{{Path: "./main.go", Line: 12, Column: 5, Message: "undefined: foo"}},
+		},
+		{
+			name: "go panic",
+			text: "panic: runtime error: index out of range\n\ngoroutine 1 [running]:\nmain.handler(0x1)\n\t/home/u/app/main.go:23 +0x1d\nmain.main()\n\t/home/u/app/main.go:8 +0x25\n",
+			want: []Location{
+				{Path: "/home/u/app/main.go", Line: 23, Function: "main.handler"},
+				{Path: "/home/u/app/main.go", Line: 8, Function: "main.main"},
+			},
+		},
+		{
+			name: "python",
+			text: "Traceback (most recent call last):\n  File \"app/main.py\", line 12, in handler\n    foo()\nNameError: name 'foo' is not defined\n",
+			want: []Location{{Path: "app/main.py", Line: 12, Function: "handler"}},
+		},
+		{
+			name: "node",
+			text: "TypeError: x is undefined\n    at render (src/view.js:10:5)\n    at src/index.js:3:1\n",
+			want: []Location{
+				{Path: "src/view.js", Line: 10, Column: 5, Function: "render"},
+				{Path: "src/index.js", Line: 3, Column: 1},
+			},
+		},
+		{
+			name: "java",
+			text: "Exception in thread \"main\" java.lang.NullPointerException\n\tat com.example.Main.run(Main.java:42)\n",
+			want: []Location{{Path: "Main.java", Line: 42, Function: "com.example.Main.run"}},
+		},
+		{
+			name: "rust",
+			text: "error[E0425]: cannot find value `x` in this scope\n --> src/main.rs:2:5\n",
+			want: []Location{{Path: "src/main.rs", Line: 2, Column: 5}},
+		},
+		{
+			name: "no locations",
+			text: "segmentation fault\n",
+		},
+	}
+	for _, tt := range tests {
+		if got := Parse(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: expected %+v, got %+v", tt.name, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/liangsj/vimcoplit/internal/core/schema"
+	"github.com/liangsj/vimcoplit/internal/models"
+)
+
+// defaultStructuredRetries 是输出不符合 schema 时默认让模型修正的次数
+const defaultStructuredRetries = 2
+
+// maxStructuredRetries 是请求可以指定的最多修正次数
+const maxStructuredRetries = 5
+
+// StructuredRequest 是结构化生成请求，模型输出必须是符合 Schema 的 JSON
+type StructuredRequest struct {
+	Prompt  string          `json:"prompt"`
+	Schema  json.RawMessage `json:"schema"`
+	Retries int             `json:"retries,omitempty"` // 输出不符合 schema 时让模型修正的次数，0 表示使用默认值，-1 表示不修正
+}
+
+// StructuredResult 是结构化生成的结果，Value 已通过 schema 校验
+type StructuredResult struct {
+	Value    json.RawMessage `json:"value"`
+	Attempts int             `json:"attempts"`
+}
+
+// StructuredError 表示修正后模型输出仍不符合 schema
+type StructuredError struct {
+	Output   string   `json:"output"` // 最后一次的模型输出
+	Errors   []string `json:"errors"`
+	Attempts int      `json:"attempts"`
+}
+
+func (e *StructuredError) Error() string {
+	return fmt.Sprintf("model output does not match schema after %d attempts: %s", e.Attempts, strings.Join(e.Errors, "; "))
+}
+
+// GenerateStructured 生成符合 JSON Schema 的输出：优先使用模型原生的 JSON 模式，
+// 从输出中提取 JSON 并校验，不符合时把错误交给模型修正后重试
+func (s *serviceImpl) GenerateStructured(ctx context.Context, req *StructuredRequest) (*StructuredResult, error) {
+	sch, err := schema.Parse(req.Schema)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(req.Prompt) == "" {
+		return nil, errors.New("prompt is required")
+	}
+	retries := req.Retries
+	switch {
+	case retries == 0:
+		retries = defaultStructuredRetries
+	case retries < 0:
+		retries = 0
+	case retries > maxStructuredRetries:
+		retries = maxStructuredRetries
+	}
+
+	prompt := req.Prompt + schema.Instructions(req.Schema)
+	for attempt := 1; ; attempt++ {
+		output, err := s.generateJSON(ctx, prompt, req.Schema)
+		if err != nil {
+			return nil, err
+		}
+		var errs []string
+		value, err := schema.Extract(output)
+		if err != nil {
+			errs = []string{err.Error()}
+		} else {
+			errs = sch.Validate(value)
+		}
+		if len(errs) == 0 {
+			return &StructuredResult{Value: value, Attempts: attempt}, nil
+		}
+		if attempt > retries {
+			return nil, &StructuredError{Output: output, Errors: errs, Attempts: attempt}
+		}
+		prompt = schema.RepairPrompt(req.Prompt, output, errs, req.Schema)
+	}
+}
+
+// generateJSON 使用当前模型的原生 JSON 模式生成响应
+func (s *serviceImpl) generateJSON(ctx context.Context, prompt string, jsonSchema []byte) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	prompt, err := s.prepare(s.model, prompt)
+	if err != nil {
+		return "", err
+	}
+	model := s.model
+	return s.watchModel(ctx, model, func(ctx context.Context) (string, error) {
+		return models.GenerateJSON(ctx, model, prompt, jsonSchema)
+	})
+}
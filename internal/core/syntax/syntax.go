@@ -0,0 +1,123 @@
+package syntax
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+
+	"github.com/liangsj/vimcoplit/internal/core/langdetect"
+)
+
+// Mode 表示发现语法错误后的处理方式
+type Mode string
+
+const (
+	ModeBlock Mode = "block" // 拒绝写入，需要显式覆盖才能放行
+	ModeFlag  Mode = "flag"  // 写入但记录语法错误
+	ModeOff   Mode = "off"   // 不检查
+)
+
+// maxErrors 是每个文件最多报告的语法错误数
+const maxErrors = 10
+
+// Error 是一处语法错误，行列从 1 开始
+type Error struct {
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Message string `json:"message"`
+}
+
+// InvalidError 表示写入的内容存在语法错误
+type InvalidError struct {
+	Path   string
+	Errors []Error
+}
+
+func (e *InvalidError) Error() string {
+	first := e.Errors[0]
+	msg := fmt.Sprintf("syntax error in %s:%d:%d: %s", e.Path, first.Line, first.Column, first.Message)
+	if len(e.Errors) > 1 {
+		msg += fmt.Sprintf(" (and %d more)", len(e.Errors)-1)
+	}
+	return msg
+}
+
+// Check 检查文件内容的语法，目前支持 Go 和 JSON，其他类型的文件不检查。
+// 没有扩展名的文件按内容识别语言
+func Check(path string, content []byte) []Error {
+	switch langdetect.Detect(path, content) {
+	case "go":
+		return checkGo(path, content)
+	case "json":
+		return checkJSON(content)
+	}
+	return nil
+}
+
+// Validate 检查文件内容的语法，存在错误时返回 *InvalidError
+func Validate(path string, content []byte) error {
+	if errs := Check(path, content); len(errs) > 0 {
+		return &InvalidError{Path: path, Errors: errs}
+	}
+	return nil
+}
+
+// checkGo 使用 go/parser 解析，与 gofmt -e 报告的错误一致
+func checkGo(path string, content []byte) []Error {
+	_, err := parser.ParseFile(token.NewFileSet(), path, content, parser.AllErrors)
+	if err == nil {
+		return nil
+	}
+	var list scanner.ErrorList
+	if !errors.As(err, &list) {
+		return []Error{{Line: 1, Column: 1, Message: err.Error()}}
+	}
+	var errs []Error
+	for _, e := range list {
+		if len(errs) == maxErrors {
+			break
+		}
+		errs = append(errs, Error{Line: e.Pos.Line, Column: e.Pos.Column, Message: e.Msg})
+	}
+	return errs
+}
+
+// checkJSON 解析 JSON，并将出错的字节偏移转换为行列
+func checkJSON(content []byte) []Error {
+	var v interface{}
+	err := json.Unmarshal(content, &v)
+	if err == nil {
+		return nil
+	}
+	offset := int64(len(content))
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) && syntaxErr.Offset > 0 {
+		// Offset 指向出错字符之后
+		offset = syntaxErr.Offset - 1
+	}
+	if offset > int64(len(content)) {
+		offset = int64(len(content))
+	}
+	before := content[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := len(before) - bytes.LastIndexByte(before, '\n')
+	return []Error{{Line: line, Column: column, Message: err.Error()}}
+}
+
+type overrideKey struct{}
+
+// WithOverride 返回跳过语法检查的 context，用户明确确认后才应使用
+func WithOverride(ctx context.Context) context.Context {
+	return context.WithValue(ctx, overrideKey{}, true)
+}
+
+// IsOverridden 判断 ctx 是否声明了跳过语法检查
+func IsOverridden(ctx context.Context) bool {
+	overridden, _ := ctx.Value(overrideKey{}).(bool)
+	return overridden
+}
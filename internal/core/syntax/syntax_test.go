@@ -0,0 +1,41 @@
+package syntax
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	if errs := Check("main.go", []byte("package main\n\nfunc main() {}\n")); errs != nil {
+		t.Errorf("expected valid Go, got %+v", errs)
+	}
+	errs := Check("main.go", []byte("package main\n\nfunc main() {\n\tx := \n}\n"))
+	if len(errs) == 0 || errs[0].Line != 5 {
+		t.Errorf("expected error on line 5, got %+v", errs)
+	}
+
+	errs = Check("config.json", []byte("{\n  \"a\": 1,\n}"))
+	if len(errs) != 1 || errs[0].Line != 3 || errs[0].Column != 1 {
+		t.Errorf("expected error at 3:1, got %+v", errs)
+	}
+	// 没有扩展名的文件按内容识别语言
+	if errs := Check("cmd/tool", []byte("package main\n\nfunc main() {\n\tx := \n}\n")); len(errs) == 0 {
+		t.Error("expected extensionless Go source to be checked")
+	}
+	if errs := Check("notes.txt", []byte("{{{")); errs != nil {
+		t.Errorf("expected unsupported files to pass, got %+v", errs)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	err := Validate("a.go", []byte("package a\nfunc (\n"))
+	var invalid *InvalidError
+	if !errors.As(err, &invalid) || !strings.HasPrefix(err.Error(), "syntax error in a.go:") {
+		t.Fatalf("expected invalid error, got %v", err)
+	}
+	if !IsOverridden(WithOverride(context.Background())) || IsOverridden(context.Background()) {
+		t.Error("unexpected override state")
+	}
+}
@@ -0,0 +1,196 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// taskStore 是任务的持久化存储，所有任务保存在一个 JSON 文件中
+type taskStore struct {
+	mu    sync.RWMutex
+	path  string
+	tasks map[string]*Task
+}
+
+// newTaskStore 创建一个新的任务存储
+func newTaskStore(path string) *taskStore {
+	return &taskStore{
+		path:  path,
+		tasks: make(map[string]*Task),
+	}
+}
+
+// load 从文件加载任务
+func (s *taskStore) load() error {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	tasks := make(map[string]*Task)
+	if err := json.Unmarshal(data, &tasks); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tasks = tasks
+	return nil
+}
+
+// save 保存任务到文件，调用方需持有锁
+func (s *taskStore) save() error {
+	data, err := json.MarshalIndent(s.tasks, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(s.path, data, 0644)
+}
+
+// create 创建任务
+func (s *taskStore) create(task *Task) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if task.ID == "" {
+		task.ID = uuid.New().String()
+	}
+	if task.Status == "" {
+		task.Status = TaskStatusPending
+	}
+	now := time.Now().Unix()
+	task.CreatedAt = now
+	task.UpdatedAt = now
+
+	s.tasks[task.ID] = task
+	return s.save()
+}
+
+// get 获取任务
+func (s *taskStore) get(taskID string) (*Task, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	task, exists := s.tasks[taskID]
+	if !exists {
+		return nil, errors.New("task not found")
+	}
+	return task, nil
+}
+
+// update 更新任务
+func (s *taskStore) update(task *Task) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, exists := s.tasks[task.ID]
+	if !exists {
+		return errors.New("task not found")
+	}
+	task.CreatedAt = existing.CreatedAt
+	task.UpdatedAt = time.Now().Unix()
+	s.tasks[task.ID] = task
+	return s.save()
+}
+
+// delete 删除任务
+func (s *taskStore) delete(taskID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.tasks[taskID]; !exists {
+		return errors.New("task not found")
+	}
+	delete(s.tasks, taskID)
+	return s.save()
+}
+
+// list 列出所有任务
+func (s *taskStore) list() []*Task {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tasks := make([]*Task, 0, len(s.tasks))
+	for _, task := range s.tasks {
+		tasks = append(tasks, task)
+	}
+	return tasks
+}
+
+// recover 处理上次退出时仍处于运行状态的任务
+// 标记为可恢复的任务回到 pending 状态，其余任务标记为失败并记录原因
+func (s *taskStore) recover() (resumed, failed int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().Unix()
+	for _, task := range s.tasks {
+		if task.Status != TaskStatusRunning {
+			continue
+		}
+		if task.Metadata["resumable"] == "true" {
+			task.Status = TaskStatusPending
+			resumed++
+		} else {
+			task.Status = TaskStatusFailed
+			task.Error = "interrupted by server restart"
+			failed++
+		}
+		task.UpdatedAt = now
+	}
+
+	if resumed+failed > 0 {
+		err = s.save()
+	}
+	return resumed, failed, err
+}
+
+// archive 将最后更新早于 before 的已结束任务交给 put 归档，归档成功的任务从存储中删除
+func (s *taskStore) archive(before int64, put func(task *Task) error) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	archived := 0
+	var err error
+	for id, task := range s.tasks {
+		if task.Status == TaskStatusPending || task.Status == TaskStatusRunning || task.UpdatedAt >= before {
+			continue
+		}
+		if err = put(task); err != nil {
+			break
+		}
+		delete(s.tasks, id)
+		archived++
+	}
+	if archived > 0 {
+		if saveErr := s.save(); err == nil {
+			err = saveErr
+		}
+	}
+	return archived, err
+}
+
+// restore 放回归档的任务，保留创建时间，更新时间设为现在，避免再次被归档
+func (s *taskStore) restore(task *Task) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.tasks[task.ID]; exists {
+		return errors.New("task already exists")
+	}
+	task.UpdatedAt = time.Now().Unix()
+	s.tasks[task.ID] = task
+	return s.save()
+}
@@ -0,0 +1,199 @@
+package taskbranch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/liangsj/vimcoplit/internal/core/merge"
+)
+
+// Stash 是任务开始前暂存的用户修改，任务结束后恢复
+type Stash struct {
+	SHA       string   `json:"sha"`
+	Paths     []string `json:"paths"`               // 暂存的已跟踪文件，相对工作区根目录
+	Untracked []string `json:"untracked,omitempty"` // 暂存的未跟踪文件
+	Restored  bool     `json:"restored,omitempty"`
+	Error     string   `json:"error,omitempty"` // 恢复失败的原因，此时 stash 保留在 git stash 列表中
+}
+
+// Clone 返回暂存记录的深拷贝
+func (s *Stash) Clone() *Stash {
+	c := *s
+	c.Paths = append([]string(nil), s.Paths...)
+	c.Untracked = append([]string(nil), s.Untracked...)
+	return &c
+}
+
+// DirtyFiles 返回 paths 中有未提交修改的文件，包括未跟踪的文件；paths 为空时检查所有已跟踪的文件。
+// 路径相对工作区根目录
+func DirtyFiles(ctx context.Context, root string, paths []string) ([]string, error) {
+	args := []string{"status", "--porcelain", "-z"}
+	if len(paths) == 0 {
+		args = append(args, "--untracked-files=no")
+	} else {
+		args = append(append(args, "--untracked-files=all", "--"), paths...)
+	}
+	output, err := gitOutput(ctx, root, args...)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	entries := strings.Split(string(output), "\x00")
+	for i := 0; i < len(entries); i++ {
+		entry := entries[i]
+		if len(entry) < 4 {
+			continue
+		}
+		files = append(files, entry[3:])
+		// 重命名和复制的条目之后是原路径
+		if entry[0] == 'R' || entry[0] == 'C' {
+			i++
+		}
+	}
+	return files, nil
+}
+
+// StashFiles 把 paths 中未提交的修改（包括未跟踪的文件）保存到 git stash 并从工作区移除，
+// paths 相对工作区根目录
+func StashFiles(ctx context.Context, root, message string, paths []string) (*Stash, error) {
+	untracked, err := untrackedFiles(ctx, root)
+	if err != nil {
+		return nil, err
+	}
+	s := &Stash{}
+	for _, path := range paths {
+		if slices.Contains(untracked, path) {
+			s.Untracked = append(s.Untracked, path)
+		} else {
+			s.Paths = append(s.Paths, path)
+		}
+	}
+	args := []string{"stash", "push", "--include-untracked", "--message", message, "--"}
+	if _, err := git(ctx, root, append(args, paths...)...); err != nil {
+		return nil, err
+	}
+	if s.SHA, err = git(ctx, root, "rev-parse", "refs/stash"); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Restore 把暂存的修改写回工作区并删除 stash。文件在暂存后被任务修改过时与任务的修改三方合并，
+// 任一文件有冲突时不写入任何文件，stash 保留，可以用 git stash apply 手动恢复
+func (s *Stash) Restore(ctx context.Context, root string) error {
+	type restore struct {
+		path    string
+		content []byte // 为 nil 时删除文件
+	}
+	var restores []restore
+	var conflicts []string
+	for _, path := range append(append([]string(nil), s.Paths...), s.Untracked...) {
+		var base, stashed []byte
+		var baseOK, stashedOK bool
+		if slices.Contains(s.Untracked, path) {
+			stashed, stashedOK = s.show(ctx, root, s.SHA+"^3", path)
+		} else {
+			base, baseOK = s.show(ctx, root, s.SHA+"^1", path)
+			stashed, stashedOK = s.show(ctx, root, s.SHA, path)
+		}
+		current, err := os.ReadFile(filepath.Join(root, path))
+		currentOK := err == nil
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+
+		switch {
+		case currentOK == baseOK && string(current) == string(base):
+			// 任务没有修改这个文件，直接恢复
+			restores = append(restores, restore{path, stashed})
+			if !stashedOK {
+				restores[len(restores)-1].content = nil
+			}
+		case currentOK && stashedOK:
+			merged := merge.Merge(string(base), string(current), string(stashed))
+			if len(merged.Conflicts) > 0 {
+				conflicts = append(conflicts, path)
+				continue
+			}
+			restores = append(restores, restore{path, []byte(merged.Content)})
+		default:
+			// 一方删除了文件而另一方修改了文件
+			conflicts = append(conflicts, path)
+		}
+	}
+	if len(conflicts) > 0 {
+		return fmt.Errorf("cannot restore stashed changes to %s without conflicts, apply stash %s manually",
+			strings.Join(conflicts, ", "), s.SHA[:min(len(s.SHA), 12)])
+	}
+
+	for _, r := range restores {
+		path := filepath.Join(root, r.path)
+		if r.content == nil {
+			if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+			continue
+		}
+		mode := os.FileMode(0644)
+		if info, err := os.Stat(path); err == nil {
+			mode = info.Mode().Perm()
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, r.content, mode); err != nil {
+			return err
+		}
+	}
+	s.Restored = true
+	return s.drop(ctx, root)
+}
+
+// show 读取 stash 中某个提交里的文件内容，文件不存在时返回 false
+func (s *Stash) show(ctx context.Context, root, rev, path string) ([]byte, bool) {
+	content, err := gitOutput(ctx, root, "show", rev+":"+filepath.ToSlash(path))
+	return content, err == nil
+}
+
+// drop 从 git stash 列表中删除这次暂存，列表已被用户修改时按 SHA 查找
+func (s *Stash) drop(ctx context.Context, root string) error {
+	list, err := git(ctx, root, "stash", "list", "--format=%H")
+	if err != nil {
+		return err
+	}
+	for i, sha := range strings.Split(list, "\n") {
+		if sha == s.SHA {
+			_, err := git(ctx, root, "stash", "drop", "--quiet", fmt.Sprintf("stash@{%d}", i))
+			return err
+		}
+	}
+	return nil
+}
+
+// StartWorktree 在 dir 创建工作区副本并在其中从工作区当前的提交创建名为 name 的分支，
+// 用户工作区的文件和分支都不变，未提交的修改不会进入副本
+func StartWorktree(ctx context.Context, root, dir, name string) (*Branch, error) {
+	if _, err := git(ctx, root, "check-ref-format", "--branch", name); err != nil {
+		return nil, fmt.Errorf("invalid branch name %q", name)
+	}
+	original, err := currentBranch(ctx, root)
+	if err != nil {
+		return nil, err
+	}
+	base, err := git(ctx, root, "rev-parse", "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+		return nil, err
+	}
+	if _, err := git(ctx, root, "worktree", "add", "--quiet", "-b", name, dir, base); err != nil {
+		return nil, err
+	}
+	return &Branch{Name: name, Original: original, Base: base, Worktree: dir}, nil
+}
@@ -0,0 +1,127 @@
+package taskbranch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDirtyFiles(t *testing.T) {
+	ctx := context.Background()
+	root := newRepo(t)
+	write(t, root, "other.go", "package main\n")
+	git(ctx, root, "add", "other.go")
+	git(ctx, root, "commit", "--quiet", "-m", "other")
+	write(t, root, "main.go", "package main // edited\n")
+	write(t, root, "new.go", "package main\n")
+
+	if files, err := DirtyFiles(ctx, root, []string{"main.go", "new.go", "other.go"}); err != nil || !slices.Equal(files, []string{"main.go", "new.go"}) {
+		t.Errorf("expected main.go and new.go to be dirty, got %v %v", files, err)
+	}
+	if files, _ := DirtyFiles(ctx, root, []string{"other.go"}); len(files) != 0 {
+		t.Errorf("expected other.go to be clean, got %v", files)
+	}
+	// 不指定文件时只检查已跟踪的文件
+	if files, _ := DirtyFiles(ctx, root, nil); !slices.Equal(files, []string{"main.go"}) {
+		t.Errorf("expected only tracked changes, got %v", files)
+	}
+}
+
+func TestStashRestoreMerges(t *testing.T) {
+	ctx := context.Background()
+	root := newRepo(t)
+	write(t, root, "main.go", "package main\n\nfunc a() {}\n\nfunc b() {}\n")
+	git(ctx, root, "commit", "--quiet", "-am", "functions")
+	write(t, root, "main.go", "package main\n\nfunc a() { println(\"user\") }\n\nfunc b() {}\n")
+	write(t, root, "draft.txt", "user notes\n")
+
+	s, err := StashFiles(ctx, root, "vimcoplit: run 1", []string{"main.go", "draft.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(s.Paths, []string{"main.go"}) || !slices.Equal(s.Untracked, []string{"draft.txt"}) {
+		t.Fatalf("unexpected stash %+v", s)
+	}
+	if files, _ := DirtyFiles(ctx, root, []string{"main.go", "draft.txt"}); len(files) != 0 {
+		t.Fatalf("expected the stashed files to be clean, got %v", files)
+	}
+
+	// 任务修改了同一文件的另一处
+	write(t, root, "main.go", "package main\n\nfunc a() {}\n\nfunc b() { println(\"agent\") }\n")
+	if err := s.Restore(ctx, root); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(filepath.Join(root, "main.go"))
+	if want := "package main\n\nfunc a() { println(\"user\") }\n\nfunc b() { println(\"agent\") }\n"; string(data) != want {
+		t.Errorf("expected both changes, got:\n%s", data)
+	}
+	if data, _ := os.ReadFile(filepath.Join(root, "draft.txt")); string(data) != "user notes\n" {
+		t.Errorf("expected the untracked file to be restored, got %q", data)
+	}
+	if list, _ := git(ctx, root, "stash", "list"); list != "" || !s.Restored {
+		t.Errorf("expected the stash to be dropped, got %q", list)
+	}
+}
+
+func TestStashRestoreConflict(t *testing.T) {
+	ctx := context.Background()
+	root := newRepo(t)
+	write(t, root, "main.go", "package main // user\n")
+	s, err := StashFiles(ctx, root, "vimcoplit: run 1", []string{"main.go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	write(t, root, "main.go", "package main // agent\n")
+	if err := s.Restore(ctx, root); err == nil || !strings.Contains(err.Error(), "main.go") {
+		t.Fatalf("expected a conflict, got %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(root, "main.go")); string(data) != "package main // agent\n" {
+		t.Errorf("expected the file to be left alone, got %q", data)
+	}
+	if list, _ := git(ctx, root, "stash", "list"); list == "" {
+		t.Error("expected the stash to be kept")
+	}
+}
+
+func TestWorktree(t *testing.T) {
+	ctx := context.Background()
+	root := newRepo(t)
+	write(t, root, "main.go", "package main // user\n")
+
+	dir := filepath.Join(root, ".vimcoplit", "worktrees", "1")
+	b, err := StartWorktree(ctx, root, dir, "vimcoplit/task")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Dir(root) != dir || b.Original != "main" {
+		t.Fatalf("unexpected branch %+v", b)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "main.go")); string(data) != "package main\n" {
+		t.Errorf("expected the worktree to start from the last commit, got %q", data)
+	}
+	write(t, dir, "main.go", "package main // agent\n")
+	if _, err := b.Stage(ctx, dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Commit(ctx, dir, "Edit main", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Restore(ctx, root); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Error("expected the worktree to be removed")
+	}
+	if data, _ := os.ReadFile(filepath.Join(root, "main.go")); string(data) != "package main // user\n" {
+		t.Errorf("expected the user's changes to be untouched, got %q", data)
+	}
+	if current, _ := currentBranch(ctx, root); current != "main" {
+		t.Errorf("expected the workspace to stay on main, got %s", current)
+	}
+	if diff, _ := b.Diff(ctx, root); !strings.Contains(diff, "+package main // agent") {
+		t.Errorf("expected the commit on the task branch, got:\n%s", diff)
+	}
+}
@@ -0,0 +1,283 @@
+// Package taskbranch 管理 agent 任务使用的专用 git 分支：任务开始时从工作区当前分支创建分支，
+// 执行过程中逐步提交修改，结束后切回原分支，之后可以把分支上的提交压缩为一个。
+// 用户的工作分支始终不包含 agent 的修改。另外提供运行前保护用户未提交修改的 stash 和工作区副本
+package taskbranch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ErrDirty 表示工作区有未提交的修改，不能创建或切换任务分支
+var ErrDirty = errors.New("the working tree has uncommitted changes, commit or stash them first")
+
+// Prefix 是任务分支名的前缀
+const Prefix = "vimcoplit/"
+
+// stateDir 是工作区中服务自己的数据目录，不提交到任务分支
+const stateDir = ".vimcoplit"
+
+// slugPattern 匹配分支名中不能使用的字符
+var slugPattern = regexp.MustCompile(`[^a-z0-9]+`)
+
+// Commit 是任务分支上的一次提交
+type Commit struct {
+	SHA       string    `json:"sha"`
+	Message   string    `json:"message"`
+	Step      string    `json:"step,omitempty"` // 产生修改的计划步骤
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Branch 是一次任务使用的分支
+type Branch struct {
+	Name     string   `json:"name"`
+	Original string   `json:"original"` // 创建任务分支时工作区所在的分支，任务结束后切回
+	Base     string   `json:"base"`     // 创建任务分支时的提交
+	Commits  []Commit `json:"commits,omitempty"`
+	// Untracked 是创建分支前已经存在的未跟踪文件，不属于任务的修改，不会被提交
+	Untracked []string `json:"untracked,omitempty"`
+	// Worktree 不为空时任务在该目录的工作区副本中执行，用户的工作区不切换分支
+	Worktree    string `json:"worktree,omitempty"`
+	Squashed    bool   `json:"squashed,omitempty"`
+	PullRequest string `json:"pull_request,omitempty"` // 为分支创建的 PR 地址
+}
+
+// Clone 返回分支记录的深拷贝
+func (b *Branch) Clone() *Branch {
+	c := *b
+	c.Commits = append([]Commit(nil), b.Commits...)
+	c.Untracked = append([]string(nil), b.Untracked...)
+	return &c
+}
+
+// Name 根据任务标题和运行 ID 生成分支名，如 vimcoplit/fix-login-timeout-1a2b3c4d
+func Name(title, id string) string {
+	slug := strings.Trim(slugPattern.ReplaceAllString(strings.ToLower(title), "-"), "-")
+	if len(slug) > 40 {
+		slug = strings.TrimRight(slug[:40], "-")
+	}
+	id = strings.ReplaceAll(id, "-", "")
+	if len(id) > 8 {
+		id = id[:8]
+	}
+	if slug == "" {
+		return Prefix + id
+	}
+	return Prefix + slug + "-" + id
+}
+
+// Start 在工作区 root 从当前分支创建名为 name 的分支并切换过去。
+// 已跟踪的文件有未提交的修改或处于分离的 HEAD 时返回错误
+func Start(ctx context.Context, root, name string) (*Branch, error) {
+	if _, err := git(ctx, root, "check-ref-format", "--branch", name); err != nil {
+		return nil, fmt.Errorf("invalid branch name %q", name)
+	}
+	original, err := currentBranch(ctx, root)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkClean(ctx, root); err != nil {
+		return nil, err
+	}
+	base, err := git(ctx, root, "rev-parse", "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	untracked, err := untrackedFiles(ctx, root)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := git(ctx, root, "switch", "-c", name); err != nil {
+		return nil, err
+	}
+	return &Branch{Name: name, Original: original, Base: base, Untracked: untracked}, nil
+}
+
+// Dir 返回任务执行的目录：工作区副本或工作区本身
+func (b *Branch) Dir(root string) string {
+	if b.Worktree != "" {
+		return b.Worktree
+	}
+	return root
+}
+
+// Checkout 确保工作区在任务分支上，用于暂停后继续执行
+func (b *Branch) Checkout(ctx context.Context, root string) error {
+	if b.Worktree != "" {
+		if _, err := os.Stat(b.Worktree); err != nil {
+			return fmt.Errorf("the worktree of branch %s is missing: %w", b.Name, err)
+		}
+		return nil
+	}
+	return b.switchTo(ctx, root, b.Name)
+}
+
+// Restore 切回创建任务分支时的原分支，任务分支上还有未提交的修改时返回 ErrDirty。
+// 在工作区副本中执行时删除副本，分支保留
+func (b *Branch) Restore(ctx context.Context, root string) error {
+	if b.Worktree != "" {
+		if err := checkClean(ctx, b.Worktree); err != nil {
+			return err
+		}
+		_, err := git(ctx, root, "worktree", "remove", b.Worktree)
+		return err
+	}
+	return b.switchTo(ctx, root, b.Original)
+}
+
+// switchTo 在工作区干净时切换到 branch，已经在该分支上时不做任何事
+func (b *Branch) switchTo(ctx context.Context, root, branch string) error {
+	current, err := currentBranch(ctx, root)
+	if err == nil && current == branch {
+		return nil
+	}
+	if err := checkClean(ctx, root); err != nil {
+		return err
+	}
+	_, err = git(ctx, root, "switch", branch)
+	return err
+}
+
+// Stage 暂存任务分支上的所有修改并返回暂存的 diff，没有修改时返回空字符串。
+// 创建分支前已存在的未跟踪文件和服务的数据目录不会被暂存
+func (b *Branch) Stage(ctx context.Context, root string) (string, error) {
+	current, err := currentBranch(ctx, root)
+	if err != nil {
+		return "", err
+	}
+	if current != b.Name {
+		return "", fmt.Errorf("the workspace is on %s instead of the task branch %s", current, b.Name)
+	}
+	if _, err := git(ctx, root, "add", "--update"); err != nil {
+		return "", err
+	}
+	untracked, err := untrackedFiles(ctx, root)
+	if err != nil {
+		return "", err
+	}
+	var added []string
+	for _, path := range untracked {
+		if !slices.Contains(b.Untracked, path) && path != stateDir && !strings.HasPrefix(path, stateDir+"/") {
+			added = append(added, path)
+		}
+	}
+	if len(added) > 0 {
+		if _, err := git(ctx, root, append([]string{"add", "--"}, added...)...); err != nil {
+			return "", err
+		}
+	}
+	return git(ctx, root, "diff", "--cached")
+}
+
+// Commit 提交暂存的修改并记录提交，step 为产生修改的计划步骤
+func (b *Branch) Commit(ctx context.Context, root, message, step string) (Commit, error) {
+	if _, err := git(ctx, root, "commit", "--quiet", "--message", message); err != nil {
+		return Commit{}, err
+	}
+	sha, err := git(ctx, root, "rev-parse", "HEAD")
+	if err != nil {
+		return Commit{}, err
+	}
+	c := Commit{SHA: sha, Message: message, Step: step, CreatedAt: time.Now()}
+	b.Commits = append(b.Commits, c)
+	return c, nil
+}
+
+// Diff 返回任务分支相对创建时的提交的全部修改
+func (b *Branch) Diff(ctx context.Context, root string) (string, error) {
+	return git(ctx, root, "diff", b.Base, b.Name, "--")
+}
+
+// Squash 把任务分支上的提交压缩为一个提交，不切换分支也不修改工作区的文件。
+// 分支在记录的提交之后被改动过时返回错误
+func (b *Branch) Squash(ctx context.Context, root, message string) (Commit, error) {
+	if len(b.Commits) == 0 {
+		return Commit{}, errors.New("the task branch has no commits")
+	}
+	head, err := git(ctx, root, "rev-parse", "refs/heads/"+b.Name)
+	if err != nil {
+		return Commit{}, err
+	}
+	if last := b.Commits[len(b.Commits)-1].SHA; head != last {
+		return Commit{}, fmt.Errorf("branch %s has moved since the task committed %s", b.Name, last[:min(len(last), 12)])
+	}
+	sha, err := git(ctx, root, "commit-tree", head+"^{tree}", "-p", b.Base, "-m", message)
+	if err != nil {
+		return Commit{}, err
+	}
+	if _, err := git(ctx, root, "update-ref", "-m", "vimcoplit: squash task branch", "refs/heads/"+b.Name, sha, head); err != nil {
+		return Commit{}, err
+	}
+	c := Commit{SHA: sha, Message: message, CreatedAt: time.Now()}
+	b.Commits = []Commit{c}
+	b.Squashed = true
+	return c, nil
+}
+
+// currentBranch 返回工作区当前的分支名，分离的 HEAD 返回错误
+func currentBranch(ctx context.Context, root string) (string, error) {
+	branch, err := git(ctx, root, "rev-parse", "--abbrev-ref", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	if branch == "HEAD" {
+		return "", errors.New("the workspace is on a detached HEAD, check out a branch first")
+	}
+	return branch, nil
+}
+
+// checkClean 检查已跟踪的文件没有未提交的修改，未跟踪的文件不影响切换分支
+func checkClean(ctx context.Context, root string) error {
+	status, err := git(ctx, root, "status", "--porcelain", "--untracked-files=no")
+	if err != nil {
+		return err
+	}
+	if status != "" {
+		return ErrDirty
+	}
+	return nil
+}
+
+// untrackedFiles 返回未被忽略的未跟踪文件，路径相对工作区根目录
+func untrackedFiles(ctx context.Context, root string) ([]string, error) {
+	output, err := git(ctx, root, "ls-files", "--others", "--exclude-standard", "-z")
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, path := range strings.Split(output, "\x00") {
+		if path != "" {
+			files = append(files, path)
+		}
+	}
+	return files, nil
+}
+
+// git 在工作区执行 git 命令并返回去掉首尾空白的输出，失败时错误中带有 git 的报错
+func git(ctx context.Context, root string, args ...string) (string, error) {
+	output, err := gitOutput(ctx, root, args...)
+	return strings.TrimSpace(string(output)), err
+}
+
+// gitOutput 在工作区执行 git 命令并返回原始输出，用于读取文件内容
+func gitOutput(ctx context.Context, root string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = root
+	output, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			message, _, _ := strings.Cut(strings.TrimSpace(string(exitErr.Stderr)), "\n")
+			return nil, fmt.Errorf("git %s failed: %s", args[0], message)
+		}
+		return nil, err
+	}
+	return output, nil
+}
@@ -0,0 +1,150 @@
+package taskbranch
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newRepo 创建一个有一次提交的临时仓库，当前分支为 main
+func newRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	t.Setenv("GIT_AUTHOR_NAME", "test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+	root := t.TempDir()
+	write(t, root, "main.go", "package main\n")
+	for _, args := range [][]string{{"init", "--quiet", "--initial-branch=main"}, {"add", "."}, {"commit", "--quiet", "-m", "initial"}} {
+		if _, err := git(context.Background(), root, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func write(t *testing.T, root, path, content string) {
+	t.Helper()
+	path = filepath.Join(root, path)
+	os.MkdirAll(filepath.Dir(path), 0755)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestName(t *testing.T) {
+	tests := map[string]string{
+		"Fix login timeout":  "vimcoplit/fix-login-timeout-1a2b3c4d",
+		"  修复 #12: Crash!! ": "vimcoplit/12-crash-1a2b3c4d",
+		"":                   "vimcoplit/1a2b3c4d",
+	}
+	for title, want := range tests {
+		if got := Name(title, "1a2b3c4d-0000-4000-8000-000000000000"); got != want {
+			t.Errorf("%q: expected %s, got %s", title, want, got)
+		}
+	}
+}
+
+func TestBranchLifecycle(t *testing.T) {
+	ctx := context.Background()
+	root := newRepo(t)
+	write(t, root, "notes.txt", "not part of the task\n")
+
+	b, err := Start(ctx, root, "vimcoplit/task-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Original != "main" || len(b.Untracked) != 1 {
+		t.Fatalf("unexpected branch %+v", b)
+	}
+
+	write(t, root, "main.go", "package main\n\nfunc main() {}\n")
+	write(t, root, "util/util.go", "package util\n")
+	write(t, root, ".vimcoplit/settings.json", "{}")
+	diff, err := b.Stage(ctx, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(diff, "util/util.go") || strings.Contains(diff, "notes.txt") || strings.Contains(diff, "settings.json") {
+		t.Fatalf("expected only the task's changes to be staged, got:\n%s", diff)
+	}
+	if _, err := b.Commit(ctx, root, "Add main function", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if diff, _ := b.Stage(ctx, root); diff != "" {
+		t.Errorf("expected nothing left to stage, got:\n%s", diff)
+	}
+	write(t, root, "util/util.go", "package util\n\nconst X = 1\n")
+	b.Stage(ctx, root)
+	if _, err := b.Commit(ctx, root, "Add X", "2"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.Restore(ctx, root); err != nil {
+		t.Fatal(err)
+	}
+	// 原分支不包含任务的修改，之前的未跟踪文件保留
+	if data, _ := os.ReadFile(filepath.Join(root, "main.go")); string(data) != "package main\n" {
+		t.Errorf("expected the original branch to be untouched, got %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(root, "notes.txt")); err != nil {
+		t.Error("expected untracked files to be kept")
+	}
+
+	c, err := b.Squash(ctx, root, "Add main function and util")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b.Commits) != 1 || !b.Squashed {
+		t.Errorf("expected a single squashed commit, got %+v", b.Commits)
+	}
+	count, _ := git(ctx, root, "rev-list", "--count", "main.."+b.Name)
+	parent, _ := git(ctx, root, "rev-parse", c.SHA+"^")
+	if count != "1" || parent != b.Base {
+		t.Errorf("expected one commit on top of the base, got %s commit(s) with parent %s", count, parent)
+	}
+	if diff, _ := b.Diff(ctx, root); !strings.Contains(diff, "const X = 1") {
+		t.Errorf("expected the squashed commit to keep the changes, got:\n%s", diff)
+	}
+}
+
+func TestStartRequiresCleanTree(t *testing.T) {
+	ctx := context.Background()
+	root := newRepo(t)
+	write(t, root, "main.go", "package main // edited\n")
+	if _, err := Start(ctx, root, "vimcoplit/task"); !errors.Is(err, ErrDirty) {
+		t.Errorf("expected ErrDirty, got %v", err)
+	}
+	if _, err := Start(ctx, root, "-bad"); err == nil {
+		t.Error("expected an invalid branch name to be rejected")
+	}
+}
+
+func TestSquashRejectsMovedBranch(t *testing.T) {
+	ctx := context.Background()
+	root := newRepo(t)
+	b, err := Start(ctx, root, "vimcoplit/task")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Squash(ctx, root, "empty"); err == nil {
+		t.Error("expected squashing a branch without commits to fail")
+	}
+	write(t, root, "a.txt", "a\n")
+	b.Stage(ctx, root)
+	b.Commit(ctx, root, "Add a", "1")
+	write(t, root, "b.txt", "b\n")
+	git(ctx, root, "add", ".")
+	git(ctx, root, "commit", "--quiet", "-m", "manual")
+	if _, err := b.Squash(ctx, root, "squash"); err == nil {
+		t.Error("expected squashing to fail after the branch moved")
+	}
+}
@@ -0,0 +1,132 @@
+// Package textedit 在一次操作中把同一文件中多处互不重叠的替换应用到内容上。
+// 所有范围都按修改前的内容计算，应用时自动调整后续范围的偏移，
+// 用于文件内重命名、给所有调用点加错误处理等需要同时修改多处的操作
+package textedit
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrInvalidRange 表示修改的范围超出内容或与其他修改重叠
+var ErrInvalidRange = errors.New("invalid edit range")
+
+// Edit 是一处替换，行列从 1 开始，列按字节计算。
+//
+// StartColumn 和 EndColumn 都为 0 时按行替换：用 Replacement 替换 StartLine 到 EndLine 的整行，
+// EndLine 为 StartLine-1 时表示在 StartLine 之前插入，Replacement 没有以换行结尾时补上换行。
+// 否则替换从 StartLine:StartColumn 到 EndLine:EndColumn（不含）的文本，Replacement 原样插入，
+// 起止位置相同时表示插入
+type Edit struct {
+	StartLine   int    `json:"start_line"`
+	EndLine     int    `json:"end_line"`
+	StartColumn int    `json:"start_column,omitempty"`
+	EndColumn   int    `json:"end_column,omitempty"`
+	Replacement string `json:"replacement"`
+}
+
+// lineMode 判断是否按整行替换
+func (e *Edit) lineMode() bool {
+	return e.StartColumn == 0 && e.EndColumn == 0
+}
+
+// span 是修改在原内容中的字节范围
+type span struct {
+	index      int
+	start, end int
+	text       string
+}
+
+// Apply 把所有修改应用到内容上，返回修改后的内容。范围都基于原内容，
+// 互相重叠或超出内容时返回 ErrInvalidRange，内容不变
+func Apply(content string, edits []Edit) (string, error) {
+	starts := lineStarts(content)
+	spans := make([]span, 0, len(edits))
+	for i := range edits {
+		s, err := resolve(content, starts, &edits[i])
+		if err != nil {
+			if len(edits) > 1 {
+				return "", fmt.Errorf("edit %d: %w", i+1, err)
+			}
+			return "", err
+		}
+		s.index = i
+		spans = append(spans, s)
+	}
+	// 起点相同的插入保持输入的顺序
+	sort.SliceStable(spans, func(i, j int) bool {
+		if spans[i].start != spans[j].start {
+			return spans[i].start < spans[j].start
+		}
+		return spans[i].end < spans[j].end
+	})
+
+	var b strings.Builder
+	b.Grow(len(content))
+	pos := 0
+	for i, s := range spans {
+		if s.start < pos {
+			return "", fmt.Errorf("%w: edits %d and %d overlap", ErrInvalidRange, spans[i-1].index+1, s.index+1)
+		}
+		b.WriteString(content[pos:s.start])
+		b.WriteString(s.text)
+		pos = s.end
+	}
+	b.WriteString(content[pos:])
+	return b.String(), nil
+}
+
+// lineStarts 返回每行起始的偏移，最后一个元素为内容的长度
+func lineStarts(content string) []int {
+	starts := []int{0}
+	for i := 0; i < len(content); i++ {
+		if content[i] == '\n' && i+1 < len(content) {
+			starts = append(starts, i+1)
+		}
+	}
+	if content == "" {
+		return starts
+	}
+	return append(starts, len(content))
+}
+
+// resolve 把修改的行列范围转换为原内容中的字节范围
+func resolve(content string, starts []int, e *Edit) (span, error) {
+	lines := len(starts) - 1
+	if e.lineMode() {
+		if e.StartLine < 1 || e.EndLine < e.StartLine-1 || e.EndLine > lines {
+			return span{}, fmt.Errorf("%w: line range %d-%d", ErrInvalidRange, e.StartLine, e.EndLine)
+		}
+		text := e.Replacement
+		if text != "" && !strings.HasSuffix(text, "\n") {
+			text += "\n"
+		}
+		return span{start: starts[e.StartLine-1], end: starts[e.EndLine], text: text}, nil
+	}
+
+	start, ok := offset(content, starts, e.StartLine, e.StartColumn)
+	end, ok2 := offset(content, starts, e.EndLine, e.EndColumn)
+	if !ok || !ok2 || end < start {
+		return span{}, fmt.Errorf("%w: %d:%d-%d:%d", ErrInvalidRange, e.StartLine, e.StartColumn, e.EndLine, e.EndColumn)
+	}
+	return span{start: start, end: end, text: e.Replacement}, nil
+}
+
+// offset 返回行列位置的字节偏移，列最多可以指向行尾的换行符；
+// 最后一行之后的第 1 列表示内容的末尾
+func offset(content string, starts []int, line, column int) (int, bool) {
+	lines := len(starts) - 1
+	if line == lines+1 && column == 1 {
+		return len(content), true
+	}
+	if line < 1 || line > lines || column < 1 {
+		return 0, false
+	}
+	length := len(strings.TrimSuffix(content[starts[line-1]:starts[line]], "\n"))
+	if column > length+1 {
+		return 0, false
+	}
+	return starts[line-1] + column - 1, true
+}
@@ -0,0 +1,75 @@
+package textedit
+
+import (
+	"errors"
+	"testing"
+)
+
+const source = `func load() {
+	data := read(path)
+	parse(data)
+	data2 := read(other)
+}
+`
+
+func TestApplyRanges(t *testing.T) {
+	// 同一行和不同行的多处重命名，范围都基于原内容
+	got, err := Apply(source, []Edit{
+		{StartLine: 4, StartColumn: 2, EndLine: 4, EndColumn: 7, Replacement: "extra"},
+		{StartLine: 2, StartColumn: 2, EndLine: 2, EndColumn: 6, Replacement: "content"},
+		{StartLine: 3, StartColumn: 8, EndLine: 3, EndColumn: 12, Replacement: "content"},
+	})
+	want := `func load() {
+	content := read(path)
+	parse(content)
+	extra := read(other)
+}
+`
+	if err != nil || got != want {
+		t.Fatalf("unexpected result %q %v", got, err)
+	}
+
+	// 整行替换与插入混用，插入在同一位置时保持输入的顺序
+	got, err = Apply(source, []Edit{
+		{StartLine: 2, EndLine: 2, Replacement: "\tdata, err := read(path)\n\tif err != nil {\n\t\treturn err\n\t}"},
+		{StartLine: 3, EndLine: 2, Replacement: "\t// parsed"},
+		{StartLine: 3, EndLine: 2, Replacement: "\t// below"},
+		{StartLine: 6, StartColumn: 1, EndLine: 6, EndColumn: 1, Replacement: "// end\n"},
+	})
+	want = `func load() {
+	data, err := read(path)
+	if err != nil {
+		return err
+	}
+	// parsed
+	// below
+	parse(data)
+	data2 := read(other)
+}
+// end
+`
+	if err != nil || got != want {
+		t.Fatalf("unexpected result %q %v", got, err)
+	}
+
+	if got, err := Apply("", []Edit{{StartLine: 1, EndLine: 0, Replacement: "package main"}}); err != nil || got != "package main\n" {
+		t.Errorf("expected insertion into empty content, got %q %v", got, err)
+	}
+}
+
+func TestApplyInvalid(t *testing.T) {
+	cases := map[string][]Edit{
+		"overlap": {
+			{StartLine: 2, EndLine: 3, Replacement: "x"},
+			{StartLine: 3, StartColumn: 2, EndLine: 3, EndColumn: 3, Replacement: "y"},
+		},
+		"past end of line": {{StartLine: 1, StartColumn: 20, EndLine: 1, EndColumn: 20}},
+		"past last line":   {{StartLine: 6, EndLine: 6}},
+		"reversed":         {{StartLine: 2, StartColumn: 5, EndLine: 2, EndColumn: 2}},
+	}
+	for name, edits := range cases {
+		if _, err := Apply(source, edits); !errors.Is(err, ErrInvalidRange) {
+			t.Errorf("%s: expected ErrInvalidRange, got %v", name, err)
+		}
+	}
+}
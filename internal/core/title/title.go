@@ -0,0 +1,66 @@
+// Package title 为对话和 agent 运行生成简短的标题，便于插件在会话列表中区分
+package title
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// MaxRunes 是标题的最大字符数
+	MaxRunes = 60
+	// maxInput 是生成标题时使用的内容的最大字节数，标题只需要开头的内容
+	maxInput = 2000
+)
+
+// Prompt 返回为内容生成标题的提示词，kind 描述内容的类型，如 chat session
+func Prompt(kind, text string) string {
+	if len(text) > maxInput {
+		text = strings.ToValidUTF8(text[:maxInput], "")
+	}
+	return "Write a short title of at most six words for the " + kind + " below, in the same language as the content. " +
+		"Reply with only the title, without quotes or trailing punctuation.\n\n" + text
+}
+
+// Clean 从模型输出中取出标题：第一行非空文本，去掉 Title: 前缀、Markdown 标记、引号和末尾的标点
+func Clean(output string) string {
+	var line string
+	for _, l := range strings.Split(output, "\n") {
+		if l = strings.TrimSpace(l); l != "" {
+			line = l
+			break
+		}
+	}
+	if prefix, rest, ok := strings.Cut(line, ":"); ok && strings.EqualFold(strings.TrimSpace(prefix), "title") {
+		line = rest
+	}
+	line = strings.Trim(line, " \t#*_\"'“”‘’「」")
+	line = strings.TrimRightFunc(line, func(r rune) bool {
+		return unicode.IsPunct(r) && r != ')' && r != '）'
+	})
+	return truncate(strings.Join(strings.Fields(line), " "))
+}
+
+// Fallback 在模型不可用时使用内容的第一行非空文本作为标题
+func Fallback(text string) string {
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			return truncate(line)
+		}
+	}
+	return ""
+}
+
+// truncate 把标题截断到 MaxRunes 个字符，尽量在单词边界截断并加上省略号
+func truncate(s string) string {
+	if utf8.RuneCountInString(s) <= MaxRunes {
+		return s
+	}
+	runes := []rune(s)[:MaxRunes-1]
+	cut := string(runes)
+	if i := strings.LastIndex(cut, " "); i > len(cut)/2 {
+		cut = cut[:i]
+	}
+	return strings.TrimRightFunc(cut, func(r rune) bool { return unicode.IsSpace(r) || unicode.IsPunct(r) }) + "…"
+}
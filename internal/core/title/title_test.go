@@ -0,0 +1,45 @@
+package title
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestClean(t *testing.T) {
+	for output, want := range map[string]string{
+		"Fix nil pointer in handler":              "Fix nil pointer in handler",
+		"\n  Title: \"Refactor config loading.\"": "Refactor config loading",
+		"## **Add retry to client**\nbecause":     "Add retry to client",
+		"“修复登录超时问题”。":                             "修复登录超时问题",
+		"Explain `Grep` ranking (index)":          "Explain `Grep` ranking (index)",
+		"":                                        "",
+	} {
+		if got := Clean(output); got != want {
+			t.Errorf("Clean(%q) = %q, want %q", output, got, want)
+		}
+	}
+	long := Clean(strings.Repeat("word ", 30))
+	if utf8.RuneCountInString(long) > MaxRunes || !strings.HasSuffix(long, "word…") {
+		t.Errorf("expected long title to be truncated at a word boundary, got %q", long)
+	}
+}
+
+func TestFallback(t *testing.T) {
+	if got := Fallback("\n\n  why does   @file:main.go panic?\nstack trace..."); got != "why does @file:main.go panic?" {
+		t.Errorf("unexpected fallback %q", got)
+	}
+	if got := Fallback(strings.Repeat("很长的标题", 20)); utf8.RuneCountInString(got) != MaxRunes || !strings.HasSuffix(got, "…") {
+		t.Errorf("expected truncated fallback, got %q", got)
+	}
+	if got := Fallback(" \n "); got != "" {
+		t.Errorf("expected empty fallback, got %q", got)
+	}
+}
+
+func TestPrompt(t *testing.T) {
+	p := Prompt("chat session", strings.Repeat("界", maxInput))
+	if !strings.Contains(p, "chat session") || len(p) > maxInput+300 || !utf8.ValidString(p) {
+		t.Errorf("expected truncated valid prompt, got %d bytes", len(p))
+	}
+}
@@ -0,0 +1,83 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/liangsj/vimcoplit/internal/core/offline"
+	"github.com/liangsj/vimcoplit/internal/core/title"
+	"github.com/liangsj/vimcoplit/internal/events"
+)
+
+// titleTimeout 是在后台生成标题的超时时间
+const titleTimeout = 30 * time.Second
+
+// SuggestTitle 为内容生成简短标题，kind 描述内容的类型，如 chat session。
+// 模型不可用时使用内容的第一行，未启用自动标题时返回空字符串
+func (s *serviceImpl) SuggestTitle(ctx context.Context, kind, text string) string {
+	if !s.cfg.Titles.Enabled {
+		return ""
+	}
+	output, err := s.generateWithModel(ctx, s.cfg.Titles.Model, title.Prompt(kind, text))
+	if err == nil {
+		if t := title.Clean(output); t != "" {
+			return t
+		}
+	} else if !errors.Is(err, offline.ErrOffline) {
+		log.Printf("生成标题失败: %v\n", err)
+	}
+	return title.Fallback(text)
+}
+
+// RenameConversation 修改对话标题，之后不会再自动生成标题
+func (s *serviceImpl) RenameConversation(ctx context.Context, id, name string) (*Conversation, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, errors.New("title is required")
+	}
+	return s.setConversationTitle(id, name, false)
+}
+
+// titleConversation 在后台根据第一条消息为还没有标题的对话生成标题
+func (s *serviceImpl) titleConversation(id, text string) {
+	ctx, cancel := context.WithTimeout(s.background, titleTimeout)
+	defer cancel()
+
+	name := s.SuggestTitle(ctx, "chat session", text)
+	if name == "" {
+		return
+	}
+	if _, err := s.setConversationTitle(id, name, true); err != nil {
+		log.Printf("保存对话标题失败: %v\n", err)
+	}
+}
+
+// setConversationTitle 修改对话标题并发布 title 事件，onlyEmpty 为 true 时只修改还没有标题的对话，
+// 避免覆盖生成期间用户设置的标题
+func (s *serviceImpl) setConversationTitle(id, name string, onlyEmpty bool) (*Conversation, error) {
+	store := s.conversations
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	loaded, err := store.load(id)
+	if err != nil {
+		return nil, err
+	}
+	if onlyEmpty && loaded.Title != "" {
+		return copyConversation(loaded), nil
+	}
+	current := copyConversation(loaded)
+	current.Title = name
+	if err := store.put(current); err != nil {
+		return nil, err
+	}
+	s.events.Publish(events.TypeTitle, map[string]interface{}{
+		"conversation_id": id,
+		"title":           name,
+		"generated":       onlyEmpty,
+	})
+	return copyConversation(current), nil
+}
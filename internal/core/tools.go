@@ -0,0 +1,268 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/liangsj/vimcoplit/internal/core/mcp"
+	"github.com/liangsj/vimcoplit/internal/models"
+)
+
+// defaultToolRounds 是工具调用循环默认的最多生成轮数
+const defaultToolRounds = 8
+
+// maxToolRounds 是请求可以指定的最多生成轮数
+const maxToolRounds = 32
+
+// maxToolResultBytes 是加入对话的单个工具结果的最大长度
+const maxToolResultBytes = 32 << 10
+
+// ToolLoopRequest 是让模型调用 MCP 工具完成的生成请求
+type ToolLoopRequest struct {
+	Prompt    string   `json:"prompt"`
+	System    string   `json:"system,omitempty"`
+	Tools     []string `json:"tools,omitempty"`      // 允许调用的工具 ID，为空时可以调用所有已注册的工具
+	MaxRounds int      `json:"max_rounds,omitempty"` // 最多生成轮数，0 表示使用默认值
+
+	// Authorize 在执行模型请求的每次调用前检查权限，返回错误时不执行并把错误交给模型
+	Authorize func(ctx context.Context, tool string, params map[string]interface{}) error `json:"-"`
+}
+
+// ToolInvocation 是工具调用循环中执行的一次工具调用
+type ToolInvocation struct {
+	Tool      string                 `json:"tool"`
+	Arguments map[string]interface{} `json:"arguments"`
+	Output    string                 `json:"output,omitempty"`
+	Error     string                 `json:"error,omitempty"`
+}
+
+// ToolLoopResult 是工具调用循环的结果，Text 为模型最终的回复
+type ToolLoopResult struct {
+	Text   string            `json:"text"`
+	Calls  []*ToolInvocation `json:"calls,omitempty"`
+	Rounds int               `json:"rounds"`
+}
+
+// ErrToolRounds 表示达到最多生成轮数时模型仍在请求工具调用
+var ErrToolRounds = errors.New("model kept calling tools after the maximum number of rounds")
+
+// invalidToolName 匹配提供商不接受的工具名字符
+var invalidToolName = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// GenerateWithTools 把 MCP 工具提供给模型，执行模型请求的工具调用并把结果交回模型，
+// 直到模型给出不再调用工具的回复。工具执行失败时错误作为结果交给模型处理
+func (s *serviceImpl) GenerateWithTools(ctx context.Context, req *ToolLoopRequest) (*ToolLoopResult, error) {
+	if strings.TrimSpace(req.Prompt) == "" {
+		return nil, errors.New("prompt is required")
+	}
+	rounds := req.MaxRounds
+	switch {
+	case rounds <= 0:
+		rounds = defaultToolRounds
+	case rounds > maxToolRounds:
+		rounds = maxToolRounds
+	}
+	specs, ids, err := s.toolSpecs(ctx, req.Tools)
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []models.Message
+	if req.System != "" {
+		messages = append(messages, models.Message{Role: models.RoleSystem, Content: req.System})
+	}
+	messages = append(messages, models.Message{Role: models.RoleUser, Content: req.Prompt})
+	result := &ToolLoopResult{}
+	for result.Rounds < rounds {
+		result.Rounds++
+		resp, err := s.generateWithTools(ctx, messages, specs)
+		if err != nil {
+			return nil, err
+		}
+		if len(resp.ToolCalls) == 0 {
+			result.Text = resp.Text
+			return result, nil
+		}
+		messages = append(messages, models.Message{Role: models.RoleAssistant, Content: resp.Text, ToolCalls: resp.ToolCalls})
+		for _, call := range resp.ToolCalls {
+			invocation := s.invokeTool(ctx, req, ids, call)
+			result.Calls = append(result.Calls, invocation)
+			content := invocation.Output
+			if invocation.Error != "" {
+				content = "error: " + invocation.Error + "\n" + content
+			}
+			messages = append(messages, models.Message{Role: models.RoleTool, Content: content, ToolCallID: call.ID})
+		}
+	}
+	return result, fmt.Errorf("%w (%d)", ErrToolRounds, rounds)
+}
+
+// generateWithTools 使用当前模型生成一轮回复，对话中的疑似密钥在发送前脱敏
+func (s *serviceImpl) generateWithTools(ctx context.Context, messages []models.Message, specs []models.ToolSpec) (*models.ToolResponse, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	prepared := make([]models.Message, len(messages))
+	for i, msg := range messages {
+		content, err := s.prepare(s.model, msg.Content)
+		if err != nil {
+			return nil, err
+		}
+		msg.Content = content
+		prepared[i] = msg
+	}
+	model := s.model
+	var resp *models.ToolResponse
+	_, err := s.watchModel(ctx, model, func(ctx context.Context) (string, error) {
+		var err error
+		resp, err = models.GenerateWithTools(ctx, model, prepared, specs)
+		if resp == nil {
+			return "", err
+		}
+		return resp.Text, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// invokeTool 执行模型请求的一次工具调用。调用在执行前经过输出过滤和请求的权限检查，
+// 模型给出的工具名被映射回工具 ID
+func (s *serviceImpl) invokeTool(ctx context.Context, req *ToolLoopRequest, ids map[string]string, call models.ToolCall) *ToolInvocation {
+	invocation := &ToolInvocation{Tool: call.Name, Arguments: call.Arguments}
+	id, ok := ids[call.Name]
+	if !ok {
+		invocation.Error = fmt.Sprintf("unknown tool %q", call.Name)
+		return invocation
+	}
+	invocation.Tool = id
+	params, _ := json.Marshal(call.Arguments)
+	if _, err := s.CheckOutput(ctx, id+" "+string(params)); err != nil {
+		invocation.Error = err.Error()
+		return invocation
+	}
+	if req.Authorize != nil {
+		if err := req.Authorize(ctx, id, call.Arguments); err != nil {
+			invocation.Error = err.Error()
+			return invocation
+		}
+	}
+	result, err := s.GetMCPManager().ExecuteTool(ctx, id, call.Arguments)
+	if err != nil {
+		invocation.Error = err.Error()
+		return invocation
+	}
+	output := result.Text()
+	if len(output) > maxToolResultBytes {
+		output = output[:maxToolResultBytes] + "\n... (output truncated)"
+	}
+	invocation.Output = output
+	if result.Status != string(mcp.ToolExecutionStatusSuccess) {
+		invocation.Error = result.Error
+		if invocation.Error == "" {
+			invocation.Error = "tool failed with status " + result.Status
+		}
+	}
+	return invocation
+}
+
+// toolSpecs 返回提供给模型的工具描述以及工具名到工具 ID 的映射。
+// 工具 ID 中提供商不接受的字符替换为下划线，重名时加序号区分
+func (s *serviceImpl) toolSpecs(ctx context.Context, allowed []string) ([]models.ToolSpec, map[string]string, error) {
+	tools, err := s.GetMCPManager().ListTools(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	sort.Slice(tools, func(i, j int) bool { return tools[i].ID < tools[j].ID })
+	specs := make([]models.ToolSpec, 0, len(tools))
+	ids := make(map[string]string, len(tools))
+	for _, tool := range tools {
+		if len(allowed) > 0 && !slices.Contains(allowed, tool.ID) {
+			continue
+		}
+		name := toolName(tool.ID, ids)
+		ids[name] = tool.ID
+		description := tool.Description
+		if description == "" {
+			description = tool.Name
+		}
+		specs = append(specs, models.ToolSpec{Name: name, Description: description, Parameters: toolSchema(tool.Parameters)})
+	}
+	if len(specs) < len(allowed) {
+		for _, id := range allowed {
+			if !slices.ContainsFunc(tools, func(tool *mcp.Tool) bool { return tool.ID == id }) {
+				return nil, nil, fmt.Errorf("tool %s not found", id)
+			}
+		}
+	}
+	return specs, ids, nil
+}
+
+// toolName 把工具 ID 转换为提供商接受的工具名，最长 64 个字符
+func toolName(id string, used map[string]string) string {
+	base := invalidToolName.ReplaceAllString(id, "_")
+	if base == "" {
+		base = "tool"
+	}
+	if len(base) > 64 {
+		base = base[:64]
+	}
+	name := base
+	for n := 2; ; n++ {
+		if _, ok := used[name]; !ok {
+			return name
+		}
+		suffix := fmt.Sprintf("_%d", n)
+		name = base[:min(len(base), 64-len(suffix))] + suffix
+	}
+}
+
+// toolSchema 把 MCP 工具的参数列表转换为 JSON Schema
+func toolSchema(params []mcp.ToolParameter) json.RawMessage {
+	properties := make(map[string]interface{}, len(params))
+	required := []string{}
+	for _, p := range params {
+		property := map[string]interface{}{"type": schemaType(p.Type)}
+		if p.Description != "" {
+			property["description"] = p.Description
+		}
+		if p.Default != nil {
+			property["default"] = p.Default
+		}
+		properties[p.Name] = property
+		if p.Required {
+			required = append(required, p.Name)
+		}
+	}
+	schema, _ := json.Marshal(map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+		"required":   required,
+	})
+	return schema
+}
+
+// schemaType 返回参数类型对应的 JSON Schema 类型，未知的类型按字符串处理
+func schemaType(t string) string {
+	switch t = strings.ToLower(t); t {
+	case "string", "number", "integer", "boolean", "object", "array":
+		return t
+	case "int", "int64":
+		return "integer"
+	case "float", "float64":
+		return "number"
+	case "bool":
+		return "boolean"
+	case "map":
+		return "object"
+	default:
+		return "string"
+	}
+}
@@ -0,0 +1,187 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/liangsj/vimcoplit/internal/core/archive"
+	"github.com/liangsj/vimcoplit/internal/core/fulltext"
+)
+
+// SearchTranscripts 在对话、任务和命令输出（包括已归档的对话和任务）中全文检索，
+// 检索前把新增或修改的记录同步到索引
+func (s *serviceImpl) SearchTranscripts(ctx context.Context, q *fulltext.Query) ([]*fulltext.Result, error) {
+	if err := s.syncTranscripts(); err != nil {
+		return nil, err
+	}
+	return s.transcripts.Search(q)
+}
+
+// syncTranscripts 把更新时间与索引不一致的记录重新加入全文索引，删除已不存在的记录，
+// 有变化时保存索引。只有第一次启动时读取所有记录，之后只读取修改过的对话和归档
+func (s *serviceImpl) syncTranscripts() error {
+	s.transcriptsMu.Lock()
+	defer s.transcriptsMu.Unlock()
+
+	seen := make(map[string]bool)
+	mark := func(kind fulltext.Kind, id string) {
+		seen[string(kind)+"/"+id] = true
+	}
+
+	store := s.conversations
+	store.mu.Lock()
+	var stale []string
+	for id, sum := range store.summaries {
+		mark(fulltext.KindConversation, id)
+		if !s.transcripts.Current(fulltext.KindConversation, id, sum.UpdatedAt, false) {
+			stale = append(stale, id)
+		}
+	}
+	store.mu.Unlock()
+	// 对话文件原子写入，不持有锁读取，避免第一次同步时阻塞对话
+	for _, id := range stale {
+		conv, err := store.read(id)
+		if err != nil {
+			log.Printf("索引对话 %s 失败: %v\n", id, err)
+			continue
+		}
+		s.transcripts.Put(conversationDocument(conv, false))
+	}
+
+	for _, task := range s.tasks.list() {
+		mark(fulltext.KindTask, task.ID)
+		if !s.transcripts.Current(fulltext.KindTask, task.ID, time.Unix(task.UpdatedAt, 0), false) {
+			s.transcripts.Put(taskDocument(task, false))
+		}
+	}
+
+	entries, err := s.history.search(&HistoryQuery{})
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		mark(fulltext.KindCommand, e.ID)
+		if !s.transcripts.Current(fulltext.KindCommand, e.ID, e.EndTime, false) {
+			s.transcripts.Put(commandDocument(e))
+		}
+	}
+
+	for _, header := range s.archive.List() {
+		kind := fulltext.KindConversation
+		if header.Kind == archive.KindTask {
+			kind = fulltext.KindTask
+		}
+		mark(kind, header.ID)
+		if s.transcripts.Current(kind, header.ID, header.UpdatedAt, true) {
+			continue
+		}
+		doc, err := s.archivedDocument(header.Kind, header.ID)
+		if err != nil {
+			log.Printf("索引归档 %s %s 失败: %v\n", header.Kind, header.ID, err)
+			continue
+		}
+		doc.UpdatedAt = header.UpdatedAt
+		s.transcripts.Put(doc)
+	}
+
+	s.transcripts.Prune(func(kind fulltext.Kind, id string) bool {
+		return seen[string(kind)+"/"+id]
+	})
+	// 保存失败不影响本次检索，下次同步时再保存
+	if err := s.transcripts.Save(); err != nil {
+		log.Printf("保存全文索引失败: %v\n", err)
+	}
+	return nil
+}
+
+// archivedDocument 读取归档的对话或任务并转换为索引文档
+func (s *serviceImpl) archivedDocument(kind archive.Kind, id string) (*fulltext.Document, error) {
+	entry, err := s.archive.Get(kind, id)
+	if err != nil {
+		return nil, err
+	}
+	switch entry.Kind {
+	case archive.KindConversation:
+		var conv Conversation
+		if err := json.Unmarshal(entry.Data, &conv); err != nil {
+			return nil, err
+		}
+		conv.ID = entry.ID
+		return conversationDocument(&conv, true), nil
+	case archive.KindTask:
+		var task Task
+		if err := json.Unmarshal(entry.Data, &task); err != nil {
+			return nil, err
+		}
+		task.ID = entry.ID
+		return taskDocument(&task, true), nil
+	}
+	return nil, fmt.Errorf("unknown archive kind %q", entry.Kind)
+}
+
+// conversationDocument 把对话转换为索引文档，每条消息为一个部分，包括所有分支
+func conversationDocument(conv *Conversation, archived bool) *fulltext.Document {
+	doc := &fulltext.Document{
+		Kind:      fulltext.KindConversation,
+		ID:        conv.ID,
+		Title:     conv.Title,
+		Archived:  archived,
+		UpdatedAt: conv.UpdatedAt,
+		Parts:     []fulltext.Part{{Ref: "title", Text: conv.Title}},
+	}
+	for _, m := range conv.Messages {
+		doc.Parts = append(doc.Parts, fulltext.Part{Ref: m.ID, Text: m.Content})
+	}
+	return doc
+}
+
+// taskDocument 把任务转换为索引文档，元数据按键排序，每项为一个部分
+func taskDocument(task *Task, archived bool) *fulltext.Document {
+	title := task.Name
+	if title == "" {
+		title = task.Description
+	}
+	doc := &fulltext.Document{
+		Kind:      fulltext.KindTask,
+		ID:        task.ID,
+		Title:     title,
+		Archived:  archived,
+		UpdatedAt: time.Unix(task.UpdatedAt, 0),
+		Parts: []fulltext.Part{
+			{Ref: "name", Text: task.Name},
+			{Ref: "description", Text: task.Description},
+			{Ref: "error", Text: task.Error},
+		},
+	}
+	keys := make([]string, 0, len(task.Metadata))
+	for k := range task.Metadata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		doc.Parts = append(doc.Parts, fulltext.Part{Ref: "metadata." + k, Text: task.Metadata[k]})
+	}
+	return doc
+}
+
+// commandDocument 把命令历史转换为索引文档，命令行和各输出流分别为一个部分
+func commandDocument(e *HistoryEntry) *fulltext.Document {
+	line := strings.Join(append([]string{e.Command}, e.Args...), " ")
+	return &fulltext.Document{
+		Kind:      fulltext.KindCommand,
+		ID:        e.ID,
+		Title:     line,
+		UpdatedAt: e.EndTime,
+		Parts: []fulltext.Part{
+			{Ref: "command", Text: line},
+			{Ref: "stdout", Text: e.Stdout},
+			{Ref: "stderr", Text: e.Stderr},
+			{Ref: "error", Text: e.Error},
+		},
+	}
+}
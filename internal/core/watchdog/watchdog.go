@@ -0,0 +1,173 @@
+// Package watchdog 监视模型和工具调用：调用的期限已过、上下文已取消，但在宽限期内仍未返回时
+// （TCP 连接卡住、本地进程不响应等），强制终止底层资源，发布诊断事件，并让调用方不再等待
+package watchdog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/liangsj/vimcoplit/internal/events"
+)
+
+// ErrHung 表示调用在期限过后的宽限期内仍未返回，已被放弃
+var ErrHung = errors.New("call did not return after its deadline")
+
+// DefaultGrace 是期限过后等待调用返回的默认宽限期
+const DefaultGrace = 10 * time.Second
+
+// Call 是一个被监视的调用，Hung 表示已被判定为卡住，但底层的调用还没有返回
+type Call struct {
+	Kind     string    `json:"kind"` // model 或 tool
+	Name     string    `json:"name"`
+	Started  time.Time `json:"started"`
+	Deadline time.Time `json:"deadline,omitempty"` // 零值表示没有期限
+	Hung     bool      `json:"hung"`
+}
+
+// Report 是调用被判定为卡住时发布的诊断事件
+type Report struct {
+	Call
+	Elapsed string `json:"elapsed"`
+	Action  string `json:"action"`          // killed 表示已强制终止底层资源，abandoned 表示只是不再等待
+	Error   string `json:"error,omitempty"` // 强制终止失败的原因
+}
+
+// Watchdog 记录进行中的调用。nil 的 Watchdog 不监视，Run 直接执行调用
+type Watchdog struct {
+	grace  time.Duration
+	events *events.Bus
+
+	mu     sync.Mutex
+	nextID int64
+	calls  map[int64]*Call
+}
+
+// New 创建监视器，grace 为期限过后等待调用返回的时间，bus 为 nil 时不发布事件
+func New(grace time.Duration, bus *events.Bus) *Watchdog {
+	if grace <= 0 {
+		grace = DefaultGrace
+	}
+	return &Watchdog{
+		grace:  grace,
+		events: bus,
+		calls:  make(map[int64]*Call),
+	}
+}
+
+// Active 返回进行中的调用，包括已被放弃但仍未返回的调用，按开始时间排序
+func (w *Watchdog) Active() []Call {
+	if w == nil {
+		return nil
+	}
+	w.mu.Lock()
+	calls := make([]Call, 0, len(w.calls))
+	for _, c := range w.calls {
+		calls = append(calls, *c)
+	}
+	w.mu.Unlock()
+	sort.Slice(calls, func(i, j int) bool {
+		return calls[i].Started.Before(calls[j].Started)
+	})
+	return calls
+}
+
+// begin 登记一个调用并返回它的 ID
+func (w *Watchdog) begin(call *Call) int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.nextID++
+	w.calls[w.nextID] = call
+	return w.nextID
+}
+
+// end 在调用返回后注销
+func (w *Watchdog) end(id int64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.calls, id)
+}
+
+// hung 把调用标记为卡住，执行 kill 并发布诊断事件
+func (w *Watchdog) hung(id int64, kill func() error) Report {
+	w.mu.Lock()
+	call, ok := w.calls[id]
+	if ok {
+		call.Hung = true
+	}
+	report := Report{Action: "abandoned"}
+	if ok {
+		report.Call = *call
+	}
+	w.mu.Unlock()
+
+	report.Elapsed = time.Since(report.Started).Round(time.Millisecond).String()
+	if kill != nil {
+		report.Action = "killed"
+		if err := kill(); err != nil {
+			report.Error = err.Error()
+		}
+	}
+	log.Printf("%s 调用 %s 超过期限 %s 仍未返回，已%s\n", report.Kind, report.Name, report.Elapsed, actionText(report.Action))
+	w.events.Publish(events.TypeWatchdog, report)
+	return report
+}
+
+// actionText 返回日志中的处理方式
+func actionText(action string) string {
+	if action == "killed" {
+		return "强制终止"
+	}
+	return "放弃等待"
+}
+
+// Run 在 timeout 期限内执行 fn，timeout 为 0 时只使用 ctx 的期限。ctx 结束后 fn 在宽限期内仍未返回时，
+// 调用 kill 强制终止底层资源（kill 为 nil 时只放弃等待），发布 events.TypeWatchdog 事件并返回 ErrHung，
+// fn 之后返回的结果被丢弃。fn 必须使用传入的上下文
+func Run[T any](w *Watchdog, ctx context.Context, kind, name string, timeout time.Duration, kill func() error, fn func(context.Context) (T, error)) (T, error) {
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+	if w == nil {
+		return fn(ctx)
+	}
+
+	call := &Call{Kind: kind, Name: name, Started: time.Now()}
+	call.Deadline, _ = ctx.Deadline()
+	id := w.begin(call)
+
+	type result struct {
+		value T
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		value, err := fn(ctx)
+		w.end(id)
+		done <- result{value, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.value, r.err
+	case <-ctx.Done():
+	}
+	timer := time.NewTimer(w.grace)
+	defer timer.Stop()
+	select {
+	case r := <-done:
+		return r.value, r.err
+	case <-timer.C:
+	}
+	w.hung(id, kill)
+	var zero T
+	return zero, fmt.Errorf("%s %s: %w", kind, name, ErrHung)
+}
@@ -0,0 +1,94 @@
+package watchdog
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/liangsj/vimcoplit/internal/events"
+)
+
+func TestRunReturns(t *testing.T) {
+	bus := events.New(0)
+	w := New(time.Second, bus)
+	got, err := Run(w, context.Background(), "model", "mock", time.Second, nil, func(ctx context.Context) (string, error) {
+		return "ok", nil
+	})
+	if err != nil || got != "ok" {
+		t.Fatalf("unexpected result %q %v", got, err)
+	}
+
+	// 遵守上下文的调用在宽限期内返回自己的错误，不算卡住
+	_, err = Run(w, context.Background(), "tool", "slow", 10*time.Millisecond, nil, func(ctx context.Context) (int, error) {
+		<-ctx.Done()
+		return 0, ctx.Err()
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+	backlog, _, cancel := bus.Subscribe(0, events.TypeWatchdog)
+	cancel()
+	if len(backlog) != 0 {
+		t.Errorf("expected no watchdog events, got %+v", backlog)
+	}
+	if active := w.Active(); len(active) != 0 {
+		t.Errorf("expected no active calls, got %+v", active)
+	}
+}
+
+func TestRunHung(t *testing.T) {
+	bus := events.New(0)
+	w := New(20*time.Millisecond, bus)
+	release := make(chan struct{})
+	returned := make(chan struct{})
+	_, err := Run(w, context.Background(), "tool", "stuck", 10*time.Millisecond, func() error {
+		return errors.New("process already exited")
+	}, func(ctx context.Context) (string, error) {
+		defer close(returned)
+		<-release // 忽略上下文取消
+		return "late", nil
+	})
+	if !errors.Is(err, ErrHung) {
+		t.Fatalf("expected ErrHung, got %v", err)
+	}
+
+	backlog, _, cancel := bus.Subscribe(0, events.TypeWatchdog)
+	cancel()
+	if len(backlog) != 1 {
+		t.Fatalf("expected one watchdog event, got %+v", backlog)
+	}
+	report := backlog[0].Data.(Report)
+	if report.Kind != "tool" || report.Name != "stuck" || report.Action != "killed" ||
+		report.Error != "process already exited" || report.Deadline.IsZero() {
+		t.Errorf("unexpected report %+v", report)
+	}
+
+	// 被放弃的调用返回前仍然可以在 Active 中看到
+	if active := w.Active(); len(active) != 1 || !active[0].Hung {
+		t.Errorf("expected the hung call to stay active, got %+v", active)
+	}
+	close(release)
+	<-returned
+	deadline := time.Now().Add(time.Second)
+	for len(w.Active()) != 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if active := w.Active(); len(active) != 0 {
+		t.Errorf("expected the call to be removed after returning, got %+v", active)
+	}
+}
+
+func TestRunNil(t *testing.T) {
+	var w *Watchdog
+	_, err := Run(w, context.Background(), "model", "mock", 10*time.Millisecond, nil, func(ctx context.Context) (string, error) {
+		<-ctx.Done()
+		return "", ctx.Err()
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the timeout to apply without a watchdog, got %v", err)
+	}
+	if w.Active() != nil {
+		t.Error("expected no active calls")
+	}
+}
@@ -0,0 +1,442 @@
+// Package webcache 是网页和文档获取共用的磁盘 HTTP 缓存，多次 agent 运行读取同一份文档时不必重新下载。
+// 缓存遵循响应的 Cache-Control：新鲜的条目直接返回；过期但在 stale-while-revalidate 窗口内的条目
+// 立即返回并在后台重新验证；超出窗口时带 ETag 或 Last-Modified 同步重新验证，验证失败时返回过期的条目
+package webcache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// revalidateTimeout 是后台重新验证的超时，后台验证不受请求的 ctx 控制
+const revalidateTimeout = 30 * time.Second
+
+// Options 是缓存的默认策略，响应的 Cache-Control 中的值优先
+type Options struct {
+	MaxAge               time.Duration // 响应没有给出有效期时视为新鲜的时长
+	StaleWhileRevalidate time.Duration // 过期后仍可立即返回并在后台重新验证的时长
+	MaxBytes             int64         // 缓存在磁盘上的总大小，超出时删除最久未使用的条目，不大于 0 时不限制
+	MaxBodyBytes         int64         // 每个响应最多保存的字节数，不大于 0 时不限制
+}
+
+// Response 是获取到的响应，Stale 表示返回的是过期的缓存，Cached 表示没有访问网络
+type Response struct {
+	URL         string    `json:"url"`
+	StatusCode  int       `json:"status_code"`
+	ContentType string    `json:"content_type,omitempty"`
+	Body        []byte    `json:"body"`
+	FetchedAt   time.Time `json:"fetched_at"` // 最近一次从服务器获取或验证的时间
+	Stale       bool      `json:"-"`
+	Cached      bool      `json:"-"`
+}
+
+// entry 是缓存在磁盘上的一条响应
+type entry struct {
+	Response
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	FreshUntil   time.Time `json:"fresh_until"`
+	StaleUntil   time.Time `json:"stale_until"`   // 在此之前过期的条目可以立即返回并在后台重新验证
+	MustValidate bool      `json:"must_validate"` // no-cache 或 must-revalidate，过期后不能在验证前返回
+}
+
+// Stats 是缓存的使用情况
+type Stats struct {
+	Entries     int   `json:"entries"`
+	Bytes       int64 `json:"bytes"`
+	Hits        int64 `json:"hits"`        // 没有访问网络直接返回的次数，包括过期的条目
+	StaleHits   int64 `json:"stale_hits"`  // 返回过期条目的次数
+	Revalidated int64 `json:"revalidated"` // 服务器返回 304 的次数
+	Misses      int64 `json:"misses"`
+}
+
+// meta 是内存中记录的条目大小和最近使用时间，用于按大小淘汰
+type meta struct {
+	size   int64
+	usedAt time.Time
+}
+
+// call 是正在进行的一次获取，同一 URL 的并发请求共享结果
+type call struct {
+	done chan struct{}
+	resp *Response
+	err  error
+}
+
+// Cache 是磁盘 HTTP 缓存
+type Cache struct {
+	dir    string
+	client *http.Client
+	opts   Options
+
+	mu       sync.Mutex
+	entries  map[string]*meta
+	inflight map[string]*call
+	stats    Stats
+}
+
+// New 创建缓存并加载已有条目的大小，client 为 nil 时使用 http.DefaultClient
+func New(dir string, client *http.Client, opts Options) *Cache {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	c := &Cache{
+		dir:      dir,
+		client:   client,
+		opts:     opts,
+		entries:  make(map[string]*meta),
+		inflight: make(map[string]*call),
+	}
+	files, _ := os.ReadDir(dir)
+	for _, f := range files {
+		if f.IsDir() || filepath.Ext(f.Name()) != ".json" {
+			continue
+		}
+		if info, err := f.Info(); err == nil {
+			c.entries[strings.TrimSuffix(f.Name(), ".json")] = &meta{size: info.Size(), usedAt: info.ModTime()}
+		}
+	}
+	return c
+}
+
+// key 返回 URL 对应的缓存文件名
+func key(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return hex.EncodeToString(sum[:])
+}
+
+// path 返回缓存文件的路径
+func (c *Cache) path(k string) string {
+	return filepath.Join(c.dir, k+".json")
+}
+
+// Get 按缓存策略获取 URL，状态码不是 200 时返回错误
+func (c *Cache) Get(ctx context.Context, url string) (*Response, error) {
+	now := time.Now()
+	cached := c.load(url)
+	if cached != nil {
+		switch {
+		case now.Before(cached.FreshUntil):
+			return c.hit(cached, false), nil
+		case !cached.MustValidate && now.Before(cached.StaleUntil):
+			c.revalidateAsync(url)
+			return c.hit(cached, true), nil
+		}
+	}
+
+	resp, err := c.fetch(ctx, url)
+	if err != nil {
+		// 无法验证时返回过期的条目，must-revalidate 的条目除外
+		if cached != nil && !cached.MustValidate {
+			log.Printf("重新验证 %s 失败，使用过期的缓存: %v\n", url, err)
+			return c.hit(cached, true), nil
+		}
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Cached 返回缓存中的响应而不访问网络，不管是否过期，没有缓存时返回 nil，用于离线时
+func (c *Cache) Cached(url string) *Response {
+	e := c.load(url)
+	if e == nil {
+		return nil
+	}
+	return c.hit(e, !time.Now().Before(e.FreshUntil))
+}
+
+// hit 记录一次命中并返回条目中的响应
+func (c *Cache) hit(e *entry, stale bool) *Response {
+	c.mu.Lock()
+	c.stats.Hits++
+	if stale {
+		c.stats.StaleHits++
+	}
+	if m, ok := c.entries[key(e.URL)]; ok {
+		m.usedAt = time.Now()
+	}
+	c.mu.Unlock()
+	resp := e.Response
+	resp.Stale, resp.Cached = stale, true
+	return &resp
+}
+
+// revalidateAsync 在后台重新验证，同一 URL 已在获取时不重复发起
+func (c *Cache) revalidateAsync(url string) {
+	c.mu.Lock()
+	_, busy := c.inflight[url]
+	c.mu.Unlock()
+	if busy {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), revalidateTimeout)
+		defer cancel()
+		if _, err := c.fetch(ctx, url); err != nil {
+			log.Printf("后台重新验证 %s 失败: %v\n", url, err)
+		}
+	}()
+}
+
+// fetch 从服务器获取或重新验证 URL 并更新缓存，同一 URL 的并发获取只发出一次请求
+func (c *Cache) fetch(ctx context.Context, url string) (*Response, error) {
+	c.mu.Lock()
+	if cl, ok := c.inflight[url]; ok {
+		c.mu.Unlock()
+		select {
+		case <-cl.done:
+			return cl.resp, cl.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	cl := &call{done: make(chan struct{})}
+	c.inflight[url] = cl
+	c.mu.Unlock()
+
+	cl.resp, cl.err = c.roundTrip(ctx, url)
+
+	c.mu.Lock()
+	delete(c.inflight, url)
+	c.mu.Unlock()
+	close(cl.done)
+	return cl.resp, cl.err
+}
+
+// roundTrip 发出请求，有缓存时带上条件请求头，304 时沿用缓存的内容
+func (c *Cache) roundTrip(ctx context.Context, url string) (*Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	cached := c.load(url)
+	if cached != nil {
+		if cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	now := time.Now()
+
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		c.mu.Lock()
+		c.stats.Revalidated++
+		c.mu.Unlock()
+		e := c.newEntry(cached.Response, resp.Header, now)
+		if e.ETag == "" {
+			e.ETag = cached.ETag
+		}
+		if e.LastModified == "" {
+			e.LastModified = cached.LastModified
+		}
+		c.store(url, e)
+		out := e.Response
+		return &out, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch failed: %s", resp.Status)
+	}
+
+	var body io.Reader = resp.Body
+	if c.opts.MaxBodyBytes > 0 {
+		body = io.LimitReader(resp.Body, c.opts.MaxBodyBytes)
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.stats.Misses++
+	c.mu.Unlock()
+
+	e := c.newEntry(Response{
+		URL:         url,
+		StatusCode:  resp.StatusCode,
+		ContentType: resp.Header.Get("Content-Type"),
+		Body:        data,
+	}, resp.Header, now)
+	if directives(resp.Header).has("no-store") {
+		c.remove(key(url))
+	} else {
+		c.store(url, e)
+	}
+	out := e.Response
+	return &out, nil
+}
+
+// newEntry 按响应头计算条目的新鲜期和后台重新验证窗口
+func (c *Cache) newEntry(resp Response, header http.Header, now time.Time) *entry {
+	resp.FetchedAt = now
+	e := &entry{
+		Response:     resp,
+		ETag:         header.Get("ETag"),
+		LastModified: header.Get("Last-Modified"),
+	}
+	d := directives(header)
+	maxAge := c.opts.MaxAge
+	if v, ok := d.seconds("max-age"); ok {
+		maxAge = v
+	} else if expires, err := http.ParseTime(header.Get("Expires")); err == nil {
+		if date, err := http.ParseTime(header.Get("Date")); err == nil {
+			maxAge = expires.Sub(date)
+		} else {
+			maxAge = expires.Sub(now)
+		}
+	}
+	if age, err := strconv.Atoi(header.Get("Age")); err == nil {
+		maxAge -= time.Duration(age) * time.Second
+	}
+	if d.has("no-cache") {
+		maxAge = 0
+	}
+	swr := c.opts.StaleWhileRevalidate
+	if v, ok := d.seconds("stale-while-revalidate"); ok {
+		swr = v
+	}
+	e.FreshUntil = now.Add(max(maxAge, 0))
+	e.StaleUntil = e.FreshUntil.Add(max(swr, 0))
+	e.MustValidate = d.has("no-cache") || d.has("must-revalidate")
+	return e
+}
+
+// load 读取缓存的条目，不存在或无法读取时返回 nil
+func (c *Cache) load(url string) *entry {
+	data, err := os.ReadFile(c.path(key(url)))
+	if err != nil {
+		return nil
+	}
+	var e entry
+	if err := json.Unmarshal(data, &e); err != nil || e.URL != url {
+		return nil
+	}
+	return &e
+}
+
+// store 写入条目，超出总大小时删除最久未使用的条目
+func (c *Cache) store(url string, e *entry) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		log.Printf("写入网页缓存失败: %v\n", err)
+		return
+	}
+	k := key(url)
+	if err := os.WriteFile(c.path(k), data, 0644); err != nil {
+		log.Printf("写入网页缓存失败: %v\n", err)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[k] = &meta{size: int64(len(data)), usedAt: time.Now()}
+	if c.opts.MaxBytes <= 0 {
+		return
+	}
+	total := int64(0)
+	keys := make([]string, 0, len(c.entries))
+	for other, m := range c.entries {
+		total += m.size
+		keys = append(keys, other)
+	}
+	sort.Slice(keys, func(i, j int) bool { return c.entries[keys[i]].usedAt.Before(c.entries[keys[j]].usedAt) })
+	for _, other := range keys {
+		if total <= c.opts.MaxBytes || other == k {
+			continue
+		}
+		total -= c.entries[other].size
+		os.Remove(c.path(other))
+		delete(c.entries, other)
+	}
+}
+
+// remove 删除 URL 的缓存条目
+func (c *Cache) remove(k string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	os.Remove(c.path(k))
+	delete(c.entries, k)
+}
+
+// Clear 删除所有缓存条目，返回删除的数量
+func (c *Cache) Clear() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	for k := range c.entries {
+		if err := os.Remove(c.path(k)); err == nil || os.IsNotExist(err) {
+			delete(c.entries, k)
+			n++
+		}
+	}
+	return n
+}
+
+// Stats 返回缓存的使用情况
+func (c *Cache) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := c.stats
+	s.Entries = len(c.entries)
+	for _, m := range c.entries {
+		s.Bytes += m.size
+	}
+	return s
+}
+
+// cacheControl 是解析后的 Cache-Control 指令，没有值的指令值为空字符串
+type cacheControl map[string]string
+
+// directives 解析响应头中的 Cache-Control
+func directives(header http.Header) cacheControl {
+	d := make(cacheControl)
+	for _, line := range header.Values("Cache-Control") {
+		for _, part := range strings.Split(line, ",") {
+			name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+			if name != "" {
+				d[strings.ToLower(name)] = strings.Trim(value, `"`)
+			}
+		}
+	}
+	return d
+}
+
+// has 判断是否有指定的指令
+func (d cacheControl) has(name string) bool {
+	_, ok := d[name]
+	return ok
+}
+
+// seconds 返回以秒为单位的指令值
+func (d cacheControl) seconds(name string) (time.Duration, bool) {
+	value, ok := d[name]
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, false
+	}
+	return time.Duration(n) * time.Second, true
+}
@@ -0,0 +1,143 @@
+package webcache
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// docServer 返回带 ETag 的文档，记录收到的请求和其中的条件请求数
+type docServer struct {
+	*httptest.Server
+	requests    atomic.Int32
+	conditional atomic.Int32
+	control     atomic.Value // Cache-Control 响应头
+	down        atomic.Bool
+}
+
+func newDocServer(t *testing.T, control string) *docServer {
+	s := &docServer{}
+	s.control.Store(control)
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.requests.Add(1)
+		if s.down.Load() {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Cache-Control", s.control.Load().(string))
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			s.conditional.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("docs"))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestFreshAndRevalidate(t *testing.T) {
+	server := newDocServer(t, "max-age=60")
+	dir := t.TempDir()
+	c := New(dir, nil, Options{})
+	ctx := context.Background()
+
+	resp, err := c.Get(ctx, server.URL)
+	if err != nil || string(resp.Body) != "docs" || resp.Cached {
+		t.Fatalf("expected fetched response, got %+v %v", resp, err)
+	}
+	// 新鲜的条目不访问网络，重新打开的缓存也能读取
+	c = New(dir, nil, Options{})
+	resp, err = c.Get(ctx, server.URL)
+	if err != nil || !resp.Cached || resp.Stale || server.requests.Load() != 1 {
+		t.Fatalf("expected fresh cached response, got %+v %v after %d requests", resp, err, server.requests.Load())
+	}
+
+	// 没有 stale-while-revalidate 窗口时同步重新验证，304 沿用缓存的内容
+	server.control.Store("no-cache")
+	expire(t, c, server.URL)
+	resp, err = c.Get(ctx, server.URL)
+	if err != nil || string(resp.Body) != "docs" || resp.Cached || server.conditional.Load() != 1 {
+		t.Fatalf("expected revalidated response, got %+v %v", resp, err)
+	}
+	if s := c.Stats(); s.Entries != 1 || s.Revalidated != 1 || s.Misses != 0 || s.Hits != 1 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+
+	// no-cache 的条目在服务器不可用时不能返回
+	server.down.Store(true)
+	if _, err := c.Get(ctx, server.URL); err == nil {
+		t.Error("expected error for must-validate entry when server is down")
+	}
+	if resp := c.Cached(server.URL); resp == nil || !resp.Stale {
+		t.Errorf("expected stale entry available for offline use, got %+v", resp)
+	}
+}
+
+func TestStaleWhileRevalidate(t *testing.T) {
+	server := newDocServer(t, "max-age=0, stale-while-revalidate=3600")
+	c := New(t.TempDir(), nil, Options{})
+	ctx := context.Background()
+	if _, err := c.Get(ctx, server.URL); err != nil {
+		t.Fatal(err)
+	}
+
+	// 窗口内立即返回过期的条目，并在后台重新验证
+	resp, err := c.Get(ctx, server.URL)
+	if err != nil || !resp.Stale || !resp.Cached || string(resp.Body) != "docs" {
+		t.Fatalf("expected stale response, got %+v %v", resp, err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for server.conditional.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if server.conditional.Load() != 1 {
+		t.Fatal("expected background revalidation")
+	}
+
+	// 超出窗口后服务器不可用时返回过期的条目
+	server.down.Store(true)
+	expire(t, c, server.URL)
+	resp, err = c.Get(ctx, server.URL)
+	if err != nil || !resp.Stale {
+		t.Errorf("expected stale response when server is down, got %+v %v", resp, err)
+	}
+}
+
+func TestEviction(t *testing.T) {
+	server := newDocServer(t, "max-age=60")
+	c := New(t.TempDir(), nil, Options{MaxBytes: 1})
+	ctx := context.Background()
+	for _, path := range []string{"/a", "/b"} {
+		if _, err := c.Get(ctx, server.URL+path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 超出总大小时只保留最近写入的条目
+	if s := c.Stats(); s.Entries != 1 {
+		t.Errorf("expected one entry after eviction, got %+v", s)
+	}
+	if c.Cached(server.URL+"/a") != nil || c.Cached(server.URL+"/b") == nil {
+		t.Error("expected least recently used entry evicted")
+	}
+	if n := c.Clear(); n != 1 || c.Cached(server.URL+"/b") != nil {
+		t.Errorf("expected cache cleared, removed %d", n)
+	}
+}
+
+// expire 把条目的新鲜期和后台重新验证窗口都设为已过去
+func expire(t *testing.T, c *Cache, url string) {
+	t.Helper()
+	e := c.load(url)
+	if e == nil {
+		t.Fatalf("no cache entry for %s", url)
+	}
+	e.FreshUntil = time.Now().Add(-2 * time.Hour)
+	e.StaleUntil = time.Now().Add(-time.Hour)
+	c.store(url, e)
+}
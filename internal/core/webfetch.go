@@ -0,0 +1,94 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/liangsj/vimcoplit/internal/config"
+	"github.com/liangsj/vimcoplit/internal/core/mcp"
+	"github.com/liangsj/vimcoplit/internal/core/mention"
+	"github.com/liangsj/vimcoplit/internal/core/webcache"
+)
+
+// fetchTimeout 是获取网页的超时，后台重新验证不受此限制
+const fetchTimeout = 10 * time.Second
+
+// newWebCache 按配置创建网页缓存，未启用时返回 nil
+func newWebCache(cfg *config.Config) *webcache.Cache {
+	if !cfg.WebCache.Enabled {
+		return nil
+	}
+	return webcache.New(filepath.Join(cfg.DataDir(), "webcache"), nil, webcache.Options{
+		MaxAge:               cfg.WebCache.MaxAge.Std(),
+		StaleWhileRevalidate: cfg.WebCache.StaleWhileRevalidate.Std(),
+		MaxBytes:             int64(cfg.WebCache.MaxBytes),
+		MaxBodyBytes:         maxMentionPageBytes,
+	})
+}
+
+// fetchPage 获取网页的文本内容，启用网页缓存时经过缓存。
+// 离线时只使用缓存中的内容，不管是否过期，没有缓存时返回错误
+func (s *serviceImpl) fetchPage(ctx context.Context, url string) (string, error) {
+	if s.webCache == nil {
+		if s.offline.Offline() {
+			return "", errors.New("url fetch is unavailable while offline")
+		}
+		return mention.Fetch(ctx, url, maxMentionPageBytes)
+	}
+
+	var resp *webcache.Response
+	if s.offline.Offline() {
+		if resp = s.webCache.Cached(url); resp == nil {
+			return "", fmt.Errorf("%s is not cached and url fetch is unavailable while offline", url)
+		}
+	} else {
+		ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+		defer cancel()
+		var err error
+		if resp, err = s.webCache.Get(ctx, url); err != nil {
+			return "", err
+		}
+	}
+	return mention.PageText(resp.Body, resp.ContentType), nil
+}
+
+// WebCacheStats 返回网页缓存的使用情况，未启用时返回 nil
+func (s *serviceImpl) WebCacheStats() *webcache.Stats {
+	if s.webCache == nil {
+		return nil
+	}
+	stats := s.webCache.Stats()
+	return &stats
+}
+
+// ClearWebCache 删除所有缓存的网页，返回删除的数量
+func (s *serviceImpl) ClearWebCache() int {
+	if s.webCache == nil {
+		return 0
+	}
+	return s.webCache.Clear()
+}
+
+// registerFetchTool 注册获取网页或文档文本的内置工具，多次运行读取同一份文档时使用网页缓存
+func registerFetchTool(manager *mcp.Manager, fetch func(ctx context.Context, url string) (string, error)) {
+	manager.RegisterBuiltinTool(&mcp.Tool{
+		ID:          "fetch_url",
+		Name:        "fetch_url",
+		Description: "Fetch a web page or documentation URL and return its text, served from the shared web cache when fresh",
+		Parameters: []mcp.ToolParameter{{
+			Name:        "url",
+			Type:        "string",
+			Description: "http or https URL to fetch",
+			Required:    true,
+		}},
+	}, func(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+		url, _ := params["url"].(string)
+		if url == "" {
+			return nil, errors.New("url is required")
+		}
+		return fetch(ctx, url)
+	})
+}
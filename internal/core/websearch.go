@@ -0,0 +1,93 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"log"
+	"path/filepath"
+
+	"github.com/liangsj/vimcoplit/internal/config"
+	"github.com/liangsj/vimcoplit/internal/core/mcp"
+	"github.com/liangsj/vimcoplit/internal/core/websearch"
+)
+
+// ErrWebSearchDisabled 表示没有启用网页搜索或搜索配置无效
+var ErrWebSearchDisabled = errors.New("web search is not enabled")
+
+// newWebSearch 按配置创建网页搜索，未启用或配置无效时返回 nil
+func newWebSearch(cfg *config.Config) *websearch.Searcher {
+	if !cfg.WebSearch.Enabled {
+		return nil
+	}
+	searcher, err := websearch.New(websearch.Options{
+		Backend:       cfg.WebSearch.Backend,
+		URL:           cfg.WebSearch.URL,
+		APIKey:        cfg.WebSearch.APIKey,
+		MaxResults:    cfg.WebSearch.MaxResults,
+		DailyBudget:   cfg.WebSearch.DailyBudget,
+		SnippetLength: cfg.WebSearch.SnippetLength,
+		Timeout:       cfg.WebSearch.Timeout.Std(),
+		UsageFile:     filepath.Join(cfg.DataDir(), "websearch_usage.json"),
+	}, nil)
+	if err != nil {
+		log.Printf("web search disabled: %v", err)
+		return nil
+	}
+	return searcher
+}
+
+// SearchWeb 使用配置的后端搜索网页，count 不大于 0 时返回配置的最多结果数，离线时不可用
+func (s *serviceImpl) SearchWeb(ctx context.Context, query string, count int) (*websearch.Response, error) {
+	if s.webSearch == nil {
+		return nil, ErrWebSearchDisabled
+	}
+	if s.offline.Offline() {
+		return nil, errors.New("web search is unavailable while offline")
+	}
+	return s.webSearch.Search(ctx, query, count)
+}
+
+// ResolveQuestion 把 question 类型的上下文解析为问题和网页搜索结果
+func (s *serviceImpl) ResolveQuestion(ctx context.Context, question string) (string, error) {
+	resp, err := s.SearchWeb(ctx, question, 0)
+	if err != nil {
+		return "", err
+	}
+	return "Question: " + resp.Query + "\n\n" + websearch.Format(resp), nil
+}
+
+// WebSearchUsage 返回网页搜索当天的查询次数，未启用时返回 nil
+func (s *serviceImpl) WebSearchUsage() *websearch.Usage {
+	if s.webSearch == nil {
+		return nil
+	}
+	usage := s.webSearch.Usage()
+	return &usage
+}
+
+// registerWebSearchTool 注册搜索网页的内置工具，结果中的链接可以再用 fetch_url 读取
+func registerWebSearchTool(manager *mcp.Manager, search func(ctx context.Context, query string, count int) (*websearch.Response, error)) {
+	manager.RegisterBuiltinTool(&mcp.Tool{
+		ID:   "web_search",
+		Name: "web_search",
+		Description: "Search the web and return titles, URLs and short snippets of the top results. " +
+			"Queries count against a daily budget; use fetch_url to read a result in full",
+		Parameters: []mcp.ToolParameter{{
+			Name:        "query",
+			Type:        "string",
+			Description: "search query",
+			Required:    true,
+		}, {
+			Name:        "count",
+			Type:        "number",
+			Description: "number of results, capped by web_search.max_results",
+		}},
+	}, func(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+		query, _ := params["query"].(string)
+		if query == "" {
+			return nil, errors.New("query is required")
+		}
+		count, _ := params["count"].(float64)
+		return search(ctx, query, int(count))
+	})
+}
@@ -0,0 +1,362 @@
+// Package websearch 通过可配置的搜索后端（SearxNG、Bing、Brave）搜索网页，
+// 返回数量受限、摘要经过清理的结果，并按天限制查询次数，避免 agent 耗尽付费 API 的额度
+package websearch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// ErrBudgetExceeded 表示当天的查询次数已经用完
+var ErrBudgetExceeded = errors.New("daily web search budget exceeded")
+
+// 默认限制
+const (
+	DefaultMaxResults    = 5
+	DefaultSnippetLength = 300
+	DefaultTimeout       = 10 * time.Second
+)
+
+// maxErrorBody 是搜索失败时错误信息中保留的响应体长度
+const maxErrorBody = 200
+
+// Options 是搜索的配置，数量和长度为 0 时使用默认值
+type Options struct {
+	Backend       string        // searxng、bing 或 brave
+	URL           string        // SearxNG 实例的地址，Bing 和 Brave 为空时使用官方 API 地址
+	APIKey        string        // Bing 和 Brave 的订阅 Key
+	MaxResults    int           // 每次查询最多返回的结果数
+	DailyBudget   int           // 每天最多的查询次数，不大于 0 时不限制
+	SnippetLength int           // 每条摘要最多保留的字符数
+	Timeout       time.Duration // 单次查询的超时
+	UsageFile     string        // 保存当天已用查询次数的文件，为空时只在内存中计数
+}
+
+// Result 是一条搜索结果，Snippet 为去掉 HTML 标记并截断后的摘要
+type Result struct {
+	Title   string `json:"title"`
+	URL     string `json:"url"`
+	Snippet string `json:"snippet,omitempty"`
+}
+
+// Response 是一次查询的结果，Remaining 为当天剩余的查询次数，-1 表示不限制
+type Response struct {
+	Query     string   `json:"query"`
+	Backend   string   `json:"backend"`
+	Results   []Result `json:"results"`
+	Remaining int      `json:"remaining"`
+}
+
+// Usage 是当天的查询次数，Budget 为 0 表示不限制
+type Usage struct {
+	Date    string `json:"date"`
+	Queries int    `json:"queries"`
+	Budget  int    `json:"budget"`
+}
+
+// backend 是一个搜索后端：request 构造查询请求，parse 从响应体中取出结果
+type backend struct {
+	endpoint string // 默认的 API 地址，为空时必须配置 URL
+	request  func(endpoint, apiKey, query string, count int) (*http.Request, error)
+	parse    func(data []byte) ([]Result, error)
+}
+
+var backends = map[string]backend{
+	"searxng": {
+		request: func(endpoint, _, query string, _ int) (*http.Request, error) {
+			u := strings.TrimSuffix(endpoint, "/") + "/search?" + url.Values{"q": {query}, "format": {"json"}}.Encode()
+			return http.NewRequest(http.MethodGet, u, nil)
+		},
+		parse: func(data []byte) ([]Result, error) {
+			var body struct {
+				Results []struct {
+					Title   string `json:"title"`
+					URL     string `json:"url"`
+					Content string `json:"content"`
+				} `json:"results"`
+			}
+			if err := json.Unmarshal(data, &body); err != nil {
+				return nil, err
+			}
+			results := make([]Result, 0, len(body.Results))
+			for _, r := range body.Results {
+				results = append(results, Result{Title: r.Title, URL: r.URL, Snippet: r.Content})
+			}
+			return results, nil
+		},
+	},
+	"bing": {
+		endpoint: "https://api.bing.microsoft.com/v7.0/search",
+		request: func(endpoint, apiKey, query string, count int) (*http.Request, error) {
+			q := url.Values{"q": {query}, "count": {strconv.Itoa(count)}, "textDecorations": {"false"}}
+			req, err := http.NewRequest(http.MethodGet, endpoint+"?"+q.Encode(), nil)
+			if err == nil {
+				req.Header.Set("Ocp-Apim-Subscription-Key", apiKey)
+			}
+			return req, err
+		},
+		parse: func(data []byte) ([]Result, error) {
+			var body struct {
+				WebPages struct {
+					Value []struct {
+						Name    string `json:"name"`
+						URL     string `json:"url"`
+						Snippet string `json:"snippet"`
+					} `json:"value"`
+				} `json:"webPages"`
+			}
+			if err := json.Unmarshal(data, &body); err != nil {
+				return nil, err
+			}
+			results := make([]Result, 0, len(body.WebPages.Value))
+			for _, r := range body.WebPages.Value {
+				results = append(results, Result{Title: r.Name, URL: r.URL, Snippet: r.Snippet})
+			}
+			return results, nil
+		},
+	},
+	"brave": {
+		endpoint: "https://api.search.brave.com/res/v1/web/search",
+		request: func(endpoint, apiKey, query string, count int) (*http.Request, error) {
+			q := url.Values{"q": {query}, "count": {strconv.Itoa(count)}}
+			req, err := http.NewRequest(http.MethodGet, endpoint+"?"+q.Encode(), nil)
+			if err == nil {
+				req.Header.Set("X-Subscription-Token", apiKey)
+			}
+			return req, err
+		},
+		parse: func(data []byte) ([]Result, error) {
+			var body struct {
+				Web struct {
+					Results []struct {
+						Title       string `json:"title"`
+						URL         string `json:"url"`
+						Description string `json:"description"`
+					} `json:"results"`
+				} `json:"web"`
+			}
+			if err := json.Unmarshal(data, &body); err != nil {
+				return nil, err
+			}
+			results := make([]Result, 0, len(body.Web.Results))
+			for _, r := range body.Web.Results {
+				results = append(results, Result{Title: r.Title, URL: r.URL, Snippet: r.Description})
+			}
+			return results, nil
+		},
+	},
+}
+
+// Backends 返回支持的搜索后端
+func Backends() []string {
+	return []string{"searxng", "bing", "brave"}
+}
+
+// Searcher 按配置的后端搜索网页，并发安全
+type Searcher struct {
+	opts     Options
+	backend  backend
+	endpoint string
+	client   *http.Client
+	now      func() time.Time
+
+	mu    sync.Mutex
+	usage Usage
+}
+
+// New 创建搜索器，client 为 nil 时使用 http.DefaultClient。后端未知或缺少地址、API Key 时返回错误
+func New(opts Options, client *http.Client) (*Searcher, error) {
+	b, ok := backends[opts.Backend]
+	if !ok {
+		return nil, fmt.Errorf("unknown web search backend %q, expected one of %s", opts.Backend, strings.Join(Backends(), ", "))
+	}
+	endpoint := opts.URL
+	if endpoint == "" {
+		endpoint = b.endpoint
+	}
+	if endpoint == "" {
+		return nil, fmt.Errorf("web search backend %s requires a url", opts.Backend)
+	}
+	if b.endpoint != "" && opts.APIKey == "" {
+		return nil, fmt.Errorf("web search backend %s requires an api_key", opts.Backend)
+	}
+	if opts.MaxResults <= 0 {
+		opts.MaxResults = DefaultMaxResults
+	}
+	if opts.SnippetLength <= 0 {
+		opts.SnippetLength = DefaultSnippetLength
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	s := &Searcher{opts: opts, backend: b, endpoint: endpoint, client: client, now: time.Now}
+	s.load()
+	return s, nil
+}
+
+// Search 查询网页，count 不大于 0 或超过 MaxResults 时返回 MaxResults 条结果。
+// 发出的每次查询都计入当天的次数，包括失败的查询；次数用完时返回 ErrBudgetExceeded
+func (s *Searcher) Search(ctx context.Context, query string, count int) (*Response, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, errors.New("search query is empty")
+	}
+	if count <= 0 || count > s.opts.MaxResults {
+		count = s.opts.MaxResults
+	}
+	remaining, err := s.reserve()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.opts.Timeout)
+	defer cancel()
+	req, err := s.backend.request(s.endpoint, s.opts.APIKey, query, count)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s search failed: %w", s.opts.Backend, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s search failed: %s: %s", s.opts.Backend, resp.Status, Snippet(string(data), maxErrorBody))
+	}
+	results, err := s.backend.parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s search returned an invalid response: %w", s.opts.Backend, err)
+	}
+
+	out := &Response{Query: query, Backend: s.opts.Backend, Results: make([]Result, 0, count), Remaining: remaining}
+	for _, r := range results {
+		if len(out.Results) == count {
+			break
+		}
+		if r.URL == "" {
+			continue
+		}
+		out.Results = append(out.Results, Result{
+			Title:   Snippet(r.Title, s.opts.SnippetLength),
+			URL:     r.URL,
+			Snippet: Snippet(r.Snippet, s.opts.SnippetLength),
+		})
+	}
+	return out, nil
+}
+
+// Usage 返回当天的查询次数
+func (s *Searcher) Usage() Usage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rollover()
+	return s.usage
+}
+
+// reserve 记录一次查询，返回当天剩余的次数
+func (s *Searcher) reserve() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rollover()
+	if s.opts.DailyBudget > 0 && s.usage.Queries >= s.opts.DailyBudget {
+		return 0, fmt.Errorf("%w: %d queries used on %s", ErrBudgetExceeded, s.usage.Queries, s.usage.Date)
+	}
+	s.usage.Queries++
+	s.save()
+	if s.opts.DailyBudget <= 0 {
+		return -1, nil
+	}
+	return s.opts.DailyBudget - s.usage.Queries, nil
+}
+
+// rollover 在日期变化后重新计数，调用方需持有锁
+func (s *Searcher) rollover() {
+	s.usage.Budget = max(s.opts.DailyBudget, 0)
+	if today := s.now().Format(time.DateOnly); s.usage.Date != today {
+		s.usage.Date, s.usage.Queries = today, 0
+	}
+}
+
+// load 读取保存的查询次数，文件不存在或损坏时从 0 开始
+func (s *Searcher) load() {
+	if s.opts.UsageFile == "" {
+		return
+	}
+	if data, err := os.ReadFile(s.opts.UsageFile); err == nil {
+		json.Unmarshal(data, &s.usage)
+	}
+}
+
+// save 保存查询次数，调用方需持有锁。保存失败不影响查询，重启后次数可能偏少
+func (s *Searcher) save() {
+	if s.opts.UsageFile == "" {
+		return
+	}
+	data, err := json.Marshal(s.usage)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(s.opts.UsageFile), 0o755); err == nil {
+		os.WriteFile(s.opts.UsageFile, data, 0o644)
+	}
+}
+
+var (
+	tagPattern   = regexp.MustCompile(`<[^>]*>`)
+	spacePattern = regexp.MustCompile(`\s+`)
+)
+
+// Snippet 去掉文本中的 HTML 标记和实体，合并空白，超过 n 个字符时在词边界处截断并加上省略号
+func Snippet(text string, n int) string {
+	text = html.UnescapeString(tagPattern.ReplaceAllString(text, ""))
+	text = strings.TrimSpace(spacePattern.ReplaceAllString(text, " "))
+	if n <= 0 || utf8.RuneCountInString(text) <= n {
+		return text
+	}
+	runes := []rune(text)[:n]
+	cut := string(runes)
+	// 截断点附近有空格时在空格处截断，避免截断单词；中文等没有空格的文本直接截断
+	if i := strings.LastIndexByte(cut, ' '); i > len(cut)*4/5 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " ,.;:") + "…"
+}
+
+// Format 把搜索结果格式化为加入提示词的上下文
+func Format(resp *Response) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Web search results for %q:\n", resp.Query)
+	if len(resp.Results) == 0 {
+		b.WriteString("(no results)\n")
+	}
+	for i, r := range resp.Results {
+		fmt.Fprintf(&b, "%d. %s\n   %s\n", i+1, r.Title, r.URL)
+		if r.Snippet != "" {
+			fmt.Fprintf(&b, "   %s\n", r.Snippet)
+		}
+	}
+	return b.String()
+}
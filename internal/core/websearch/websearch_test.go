@@ -0,0 +1,120 @@
+package websearch
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSnippet(t *testing.T) {
+	tests := []struct {
+		text string
+		n    int
+		want string
+	}{
+		{"Go <strong>generics</strong> &amp; \n  interfaces", 0, "Go generics & interfaces"},
+		{"The quick brown fox jumps over the lazy dog", 20, "The quick brown fox…"},
+		{"Thequickbrownfoxjumps", 10, "Thequickbr…"},
+		{"短文本", 10, "短文本"},
+	}
+	for _, tt := range tests {
+		if got := Snippet(tt.text, tt.n); got != tt.want {
+			t.Errorf("Snippet(%q, %d) = %q, want %q", tt.text, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New(Options{Backend: "google"}, nil); err == nil {
+		t.Error("expected unknown backend to be rejected")
+	}
+	if _, err := New(Options{Backend: "searxng"}, nil); err == nil {
+		t.Error("expected searxng without url to be rejected")
+	}
+	if _, err := New(Options{Backend: "brave"}, nil); err == nil {
+		t.Error("expected brave without api key to be rejected")
+	}
+	if _, err := New(Options{Backend: "bing", APIKey: "key"}, nil); err != nil {
+		t.Errorf("expected bing to use the official endpoint, got %v", err)
+	}
+}
+
+func TestSearchBackends(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query().Get("q")
+		switch {
+		case r.URL.Path == "/search" && r.URL.Query().Get("format") == "json":
+			w.Write([]byte(`{"results": [{"title": "A", "url": "https://a.test", "content": "about ` + q + `"},
+				{"title": "no url"}, {"title": "B", "url": "https://b.test"}, {"title": "C", "url": "https://c.test"}]}`))
+		case r.URL.Path == "/bing" && r.Header.Get("Ocp-Apim-Subscription-Key") == "bing-key":
+			w.Write([]byte(`{"webPages": {"value": [{"name": "Bing", "url": "https://bing.test", "snippet": "count ` +
+				r.URL.Query().Get("count") + `"}]}}`))
+		case r.URL.Path == "/brave" && r.Header.Get("X-Subscription-Token") == "brave-key":
+			w.Write([]byte(`{"web": {"results": [{"title": "Brave", "url": "https://brave.test", "description": "<strong>` + q + `</strong>"}]}}`))
+		default:
+			http.Error(w, "forbidden", http.StatusForbidden)
+		}
+	}))
+	defer srv.Close()
+
+	s, _ := New(Options{Backend: "searxng", URL: srv.URL + "/", MaxResults: 2}, nil)
+	resp, err := s.Search(context.Background(), " golang ", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Query != "golang" || len(resp.Results) != 2 || resp.Results[0].Snippet != "about golang" ||
+		resp.Results[1].URL != "https://b.test" || resp.Remaining != -1 {
+		t.Errorf("unexpected searxng response %+v", resp)
+	}
+
+	s, _ = New(Options{Backend: "bing", URL: srv.URL + "/bing", APIKey: "bing-key"}, nil)
+	if resp, err := s.Search(context.Background(), "golang", 3); err != nil || resp.Results[0].Snippet != "count 3" {
+		t.Errorf("unexpected bing response %+v %v", resp, err)
+	}
+
+	s, _ = New(Options{Backend: "brave", URL: srv.URL + "/brave", APIKey: "brave-key"}, nil)
+	if resp, err := s.Search(context.Background(), "golang", 0); err != nil || resp.Results[0].Snippet != "golang" {
+		t.Errorf("unexpected brave response %+v %v", resp, err)
+	}
+
+	s, _ = New(Options{Backend: "brave", URL: srv.URL + "/brave", APIKey: "wrong"}, nil)
+	if _, err := s.Search(context.Background(), "golang", 0); err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("expected status in error, got %v", err)
+	}
+}
+
+func TestDailyBudget(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"results": []}`))
+	}))
+	defer srv.Close()
+
+	usageFile := filepath.Join(t.TempDir(), "usage.json")
+	opts := Options{Backend: "searxng", URL: srv.URL, DailyBudget: 2, UsageFile: usageFile}
+	s, _ := New(opts, nil)
+	if resp, err := s.Search(context.Background(), "a", 0); err != nil || resp.Remaining != 1 {
+		t.Fatalf("unexpected response %+v %v", resp, err)
+	}
+
+	// 重启后保留当天的次数
+	s, _ = New(opts, nil)
+	if _, err := s.Search(context.Background(), "b", 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Search(context.Background(), "c", 0); !errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("expected ErrBudgetExceeded, got %v", err)
+	}
+	if u := s.Usage(); u.Queries != 2 || u.Budget != 2 {
+		t.Errorf("unexpected usage %+v", u)
+	}
+
+	s.now = func() time.Time { return time.Now().Add(24 * time.Hour) }
+	if _, err := s.Search(context.Background(), "d", 0); err != nil {
+		t.Errorf("expected the budget to reset on the next day, got %v", err)
+	}
+}
@@ -0,0 +1,235 @@
+package window
+
+import (
+	"errors"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"regexp"
+	"strings"
+
+	"github.com/liangsj/vimcoplit/internal/core/langdetect"
+)
+
+// 提取方式
+const (
+	KindGoAST  = "go_ast" // 使用 go/parser 定位光标所在的声明
+	KindIndent = "indent" // 根据缩进和定义关键字推断光标所在的函数或类
+	KindLines  = "lines"  // 无法识别时取光标前后固定行数
+)
+
+// defaultRadius 是按行截取时光标前后各保留的行数
+const defaultRadius = 40
+
+// Window 是光标附近的局部上下文：导入语句加上光标所在的函数或类，而不是整个文件
+type Window struct {
+	Path      string `json:"path"`
+	Kind      string `json:"kind"`
+	Imports   string `json:"imports,omitempty"`
+	StartLine int    `json:"start_line"` // 局部代码的起止行，从 1 开始
+	EndLine   int    `json:"end_line"`
+	Before    string `json:"before"` // 局部代码中光标之前的内容
+	After     string `json:"after"`  // 局部代码中光标之后的内容
+}
+
+// String 将局部上下文拼接为提示词使用的文本
+func (w *Window) String() string {
+	var b strings.Builder
+	if w.Imports != "" {
+		b.WriteString(w.Imports)
+		b.WriteString("\n\n")
+	}
+	b.WriteString(w.Before)
+	b.WriteString(w.After)
+	return b.String()
+}
+
+// Extract 提取 line、col（从 1 开始，col 为字节偏移）处的局部上下文
+func Extract(path string, src []byte, line, col int) (*Window, error) {
+	lines := strings.SplitAfter(string(src), "\n")
+	if line < 1 || line > len(lines) {
+		return nil, errors.New("cursor line out of range")
+	}
+	if col < 1 {
+		col = 1
+	}
+	if max := len(strings.TrimRight(lines[line-1], "\n")) + 1; col > max {
+		col = max
+	}
+	offset := len(strings.Join(lines[:line-1], "")) + col - 1
+
+	if langdetect.Detect(path, src) == "go" {
+		if w := extractGo(path, src, offset); w != nil {
+			return w, nil
+		}
+	}
+	if w := extractIndent(path, lines, line, offset); w != nil {
+		return w, nil
+	}
+	return extractLines(path, lines, line, offset), nil
+}
+
+// extractGo 使用 go/parser 定位光标所在的声明，语法错误时尽量使用已解析的部分
+func extractGo(path string, src []byte, offset int) *Window {
+	fset := token.NewFileSet()
+	file, _ := parser.ParseFile(fset, path, src, parser.ParseComments)
+	if file == nil {
+		return nil
+	}
+
+	var imports strings.Builder
+	imports.WriteString("package " + file.Name.Name)
+	for _, decl := range file.Decls {
+		if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.IMPORT {
+			imports.WriteString("\n\n")
+			imports.Write(src[fset.Position(gen.Pos()).Offset:fset.Position(gen.End()).Offset])
+		}
+	}
+
+	for _, decl := range file.Decls {
+		start := declStart(decl)
+		if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.IMPORT {
+			continue
+		}
+		from, to := fset.Position(start).Offset, fset.Position(decl.End()).Offset
+		if offset < from || offset > to || to > len(src) {
+			continue
+		}
+		return &Window{
+			Path:      path,
+			Kind:      KindGoAST,
+			Imports:   imports.String(),
+			StartLine: fset.Position(start).Line,
+			EndLine:   fset.Position(decl.End()).Line,
+			Before:    string(src[from:offset]),
+			After:     string(src[offset:to]),
+		}
+	}
+	return nil
+}
+
+// declStart 返回声明的起始位置，包含文档注释
+func declStart(decl ast.Decl) token.Pos {
+	switch d := decl.(type) {
+	case *ast.FuncDecl:
+		if d.Doc != nil {
+			return d.Doc.Pos()
+		}
+	case *ast.GenDecl:
+		if d.Doc != nil {
+			return d.Doc.Pos()
+		}
+	}
+	return decl.Pos()
+}
+
+// definitionLine 匹配常见语言中函数、方法和类定义的开头
+var definitionLine = regexp.MustCompile(`^\s*(export\s+)?(default\s+)?(pub(\(\w+\))?\s+)?((public|private|protected|static|final|abstract|async|override|virtual)\s+)*(def|class|func|function|fn|impl|trait|struct|enum|interface|module|sub|proc)\b`)
+
+// importLine 匹配常见语言中的导入语句
+var importLine = regexp.MustCompile(`^\s*(import\b|from\s+\S+\s+import\b|#include\b|use\s+\S|require\b|package\b|using\s+\S|(const|let|var)\s+.*=\s*require\()`)
+
+// extractIndent 向上查找缩进更小的定义行，再向下取到缩进回到同一层为止
+func extractIndent(path string, lines []string, line, offset int) *Window {
+	start := -1
+	cursorIndent := indentOf(lines[line-1])
+	for i := line - 1; i >= 0; i-- {
+		if isBlank(lines[i]) {
+			continue
+		}
+		if definitionLine.MatchString(lines[i]) && (i == line-1 || indentOf(lines[i]) <= cursorIndent) {
+			start = i
+			break
+		}
+	}
+	if start < 0 {
+		return nil
+	}
+
+	indent := indentOf(lines[start])
+	if cur := lines[line-1]; line-1 != start && !isBlank(cur) && indentOf(cur) <= indent && !strings.HasPrefix(strings.TrimSpace(cur), "}") {
+		// 光标所在行已经回到定义的缩进，说明不在该定义内
+		return nil
+	}
+	end := len(lines)
+	for i := line; i < len(lines); i++ {
+		if isBlank(lines[i]) || indentOf(lines[i]) > indent {
+			continue
+		}
+		end = i
+		// 与定义同一缩进的右括号属于该定义
+		if trimmed := strings.TrimSpace(lines[i]); strings.HasPrefix(trimmed, "}") || strings.HasPrefix(trimmed, "end") {
+			end = i + 1
+		}
+		break
+	}
+
+	from := len(strings.Join(lines[:start], ""))
+	to := len(strings.Join(lines[:end], ""))
+	text := strings.Join(lines, "")
+	return &Window{
+		Path:      path,
+		Kind:      KindIndent,
+		Imports:   collectImports(lines[:start]),
+		StartLine: start + 1,
+		EndLine:   end,
+		Before:    text[from:offset],
+		After:     text[offset:to],
+	}
+}
+
+// extractLines 取光标前后各 defaultRadius 行
+func extractLines(path string, lines []string, line, offset int) *Window {
+	start := line - 1 - defaultRadius
+	if start < 0 {
+		start = 0
+	}
+	end := line + defaultRadius
+	if end > len(lines) {
+		end = len(lines)
+	}
+	from := len(strings.Join(lines[:start], ""))
+	to := len(strings.Join(lines[:end], ""))
+	text := strings.Join(lines, "")
+	return &Window{
+		Path:      path,
+		Kind:      KindLines,
+		Imports:   collectImports(lines[:start]),
+		StartLine: start + 1,
+		EndLine:   end,
+		Before:    text[from:offset],
+		After:     text[offset:to],
+	}
+}
+
+// collectImports 收集导入语句
+func collectImports(lines []string) string {
+	var imports []string
+	for _, l := range lines {
+		if importLine.MatchString(l) {
+			imports = append(imports, strings.TrimRight(l, "\r\n"))
+		}
+	}
+	return strings.Join(imports, "\n")
+}
+
+// indentOf 返回行首空白的宽度，制表符按 4 个空格计算
+func indentOf(line string) int {
+	n := 0
+	for _, r := range line {
+		switch r {
+		case ' ':
+			n++
+		case '\t':
+			n += 4
+		default:
+			return n
+		}
+	}
+	return n
+}
+
+// isBlank 判断是否为空行
+func isBlank(line string) bool {
+	return strings.TrimSpace(line) == ""
+}
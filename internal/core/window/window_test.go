@@ -0,0 +1,81 @@
+package window
+
+import (
+	"strings"
+	"testing"
+)
+
+const goSource = `package demo
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Hello 打招呼
+func Hello(name string) string {
+	name = strings.TrimSpace(name)
+	return fmt.Sprintf("hello %s", name)
+}
+
+func Other() {}
+`
+
+func TestExtractGo(t *testing.T) {
+	w, err := Extract("demo.go", []byte(goSource), 11, 2)
+	if err != nil {
+		t.Fatalf("failed to extract window: %v", err)
+	}
+	if w.Kind != KindGoAST || w.StartLine != 8 || w.EndLine != 12 {
+		t.Errorf("unexpected window %+v", w)
+	}
+	if !strings.Contains(w.Imports, `"strings"`) || !strings.HasPrefix(w.Imports, "package demo") {
+		t.Errorf("expected imports with package clause, got %q", w.Imports)
+	}
+	if !strings.HasPrefix(w.Before, "// Hello") || !strings.HasSuffix(w.Before, "\t") || !strings.HasPrefix(w.After, "return fmt") {
+		t.Errorf("unexpected split: before %q after %q", w.Before, w.After)
+	}
+	if strings.Contains(w.String(), "Other") {
+		t.Error("expected other declarations to be excluded")
+	}
+}
+
+const pySource = `import os
+from typing import List
+
+
+class Greeter:
+    def greet(self, name):
+        name = name.strip()
+        return "hello " + name
+
+    def other(self):
+        pass
+
+
+print("done")
+`
+
+func TestExtractIndent(t *testing.T) {
+	w, err := Extract("greeter.py", []byte(pySource), 8, 9)
+	if err != nil {
+		t.Fatalf("failed to extract window: %v", err)
+	}
+	if w.Kind != KindIndent || w.StartLine != 6 || w.EndLine != 9 {
+		t.Errorf("unexpected window %+v", w)
+	}
+	if w.Imports != "import os\nfrom typing import List" {
+		t.Errorf("unexpected imports %q", w.Imports)
+	}
+	if !strings.HasPrefix(w.After, "return") || strings.Contains(w.After, "other") {
+		t.Errorf("unexpected after %q", w.After)
+	}
+
+	w, err = Extract("greeter.py", []byte(pySource), 14, 1)
+	if err != nil {
+		t.Fatalf("failed to extract window: %v", err)
+	}
+	if w.Kind != KindLines {
+		t.Errorf("expected top-level code to fall back to lines, got %s", w.Kind)
+	}
+}
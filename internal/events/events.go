@@ -0,0 +1,236 @@
+// Package events 是各子系统共用的进程内事件总线：任务、文件、命令、工具调用和审批等事件
+// 发布到同一条总线，SSE 等对外推送的接口从总线订阅，不需要为每个功能单独传递
+package events
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Type 表示事件类型
+type Type string
+
+const (
+	TypeTask       Type = "task"       // 任务创建或状态变化
+	TypeFile       Type = "file"       // 文件写入
+	TypeDiff       Type = "diff"       // 带 diff 的文件修改
+	TypeCommand    Type = "command"    // 命令执行完成
+	TypeTool       Type = "tool"       // MCP 工具调用完成
+	TypeApproval   Type = "approval"   // agent 计划等待审批或审批结果
+	TypeRun        Type = "run"        // agent 运行或步骤状态变化
+	TypeChat       Type = "chat"       // 对话消息
+	TypePermission Type = "permission" // 权限请求或插件的回复
+	TypeSearch     Type = "search"     // 监视的保存检索出现新的匹配
+	TypeTitle      Type = "title"      // 对话标题自动生成或重命名
+	TypeWatchdog   Type = "watchdog"   // 模型或工具调用超过期限仍未返回，被强制终止
+)
+
+// Event 是总线上的事件，ID 单调递增，断线重连时据此补发错过的事件
+type Event struct {
+	ID   int64       `json:"id"`
+	Type Type        `json:"type"`
+	Time time.Time   `json:"time"`
+	Data interface{} `json:"data"`
+}
+
+// subscriberBuffer 是每个订阅者的事件缓冲，处理不过来的订阅者会丢失事件
+const subscriberBuffer = 64
+
+// subscriber 是一个订阅者，types 为空时接收所有类型，dropped 为缓冲已满而丢失的事件数
+type subscriber struct {
+	ch      chan Event
+	types   map[Type]bool
+	dropped int
+}
+
+// wants 判断订阅者是否接收该类型的事件
+func (s *subscriber) wants(t Type) bool {
+	return len(s.types) == 0 || s.types[t]
+}
+
+// Bus 保存最近的事件并分发给订阅者。nil 的 Bus 丢弃所有事件，可以直接调用 Publish
+type Bus struct {
+	mu      sync.Mutex
+	epoch   string // 总线创建时生成，服务重启后变化，续传令牌据此判断事件 ID 是否来自同一个进程
+	nextID  int64
+	history []Event
+	size    int
+	subs    map[*subscriber]struct{}
+	hooks   []hook
+}
+
+// hook 是同步处理事件的函数，types 为空时处理所有类型
+type hook struct {
+	fn    func(Event)
+	types map[Type]bool
+}
+
+// New 创建一个保留最近 history 条事件的总线
+func New(history int) *Bus {
+	if history <= 0 {
+		history = 200
+	}
+	return &Bus{
+		epoch: strconv.FormatInt(time.Now().UnixNano(), 36),
+		size:  history,
+		subs:  make(map[*subscriber]struct{}),
+	}
+}
+
+// Publish 发布一个事件，不会阻塞在处理缓慢的订阅者上
+func (b *Bus) Publish(typ Type, data interface{}) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.nextID++
+	event := Event{ID: b.nextID, Type: typ, Time: time.Now(), Data: data}
+	b.history = append(b.history, event)
+	if len(b.history) > b.size {
+		b.history = b.history[len(b.history)-b.size:]
+	}
+	for _, h := range b.hooks {
+		if len(h.types) == 0 || h.types[typ] {
+			h.fn(event)
+		}
+	}
+	for sub := range b.subs {
+		if !sub.wants(typ) {
+			continue
+		}
+		select {
+		case sub.ch <- event:
+		default:
+			sub.dropped++
+		}
+	}
+}
+
+// Hook 注册一个同步处理 types 中事件（为空时处理所有事件）的函数。fn 在 Publish 中按发布顺序
+// 调用，不会像 Subscribe 那样在处理缓慢时丢失事件，适合审计等不能遗漏的场景；fn 不能再调用 Publish
+func (b *Bus) Hook(fn func(Event), types ...Type) {
+	h := hook{fn: fn}
+	if len(types) > 0 {
+		h.types = make(map[Type]bool, len(types))
+		for _, t := range types {
+			h.types[t] = true
+		}
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.hooks = append(b.hooks, h)
+}
+
+// Subscribe 订阅 types 中的事件（为空时订阅所有事件），返回 ID 大于 after 的历史事件、
+// 后续事件的通道和取消订阅的函数
+func (b *Bus) Subscribe(after int64, types ...Type) ([]Event, <-chan Event, func()) {
+	s := b.subscribe(after, types)
+	return s.Backlog, s.Events, s.Cancel
+}
+
+// 续传时无法从令牌的位置无缝接上的原因
+const (
+	ResetRestarted = "restarted" // 令牌来自服务重启前，事件 ID 已经重新计数
+	ResetExpired   = "expired"   // 令牌之后的部分事件已不在保留的历史中
+)
+
+// ErrInvalidToken 表示续传令牌格式错误
+var ErrInvalidToken = errors.New("invalid stream token")
+
+// Token 返回事件 ID 对应的续传令牌，格式为 <epoch>-<id>。令牌带有总线的 epoch，
+// 服务重启后旧令牌不会被误认为是新进程中的位置
+func (b *Bus) Token(id int64) string {
+	return b.epoch + "-" + strconv.FormatInt(id, 10)
+}
+
+// Position 返回最新事件的续传令牌，从这里续传只会收到之后发布的事件
+func (b *Bus) Position() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.Token(b.nextID)
+}
+
+// Subscription 是一个可以续传的订阅。Reset 不为空时表示无法从请求的位置无缝接上，
+// 客户端在处理 Backlog 之前应重新获取完整状态
+type Subscription struct {
+	Backlog []Event
+	Events  <-chan Event
+	Reset   string
+
+	bus    *Bus
+	sub    *subscriber
+	cancel func()
+}
+
+// Dropped 返回并清零自上次调用以来因缓冲已满而丢失的事件数，
+// 不为 0 时应取消订阅并从最后处理的事件的令牌续传
+func (s *Subscription) Dropped() int {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	n := s.sub.dropped
+	s.sub.dropped = 0
+	return n
+}
+
+// Cancel 取消订阅，可以多次调用
+func (s *Subscription) Cancel() {
+	s.cancel()
+}
+
+// Resume 从续传令牌的位置订阅 types 中的事件，也接受不带 epoch 的事件 ID，按当前进程中的 ID 处理
+func (b *Bus) Resume(token string, types ...Type) (*Subscription, error) {
+	epoch, id := b.epoch, token
+	if i := strings.LastIndex(token, "-"); i >= 0 {
+		epoch, id = token[:i], token[i+1:]
+	}
+	after, err := strconv.ParseInt(id, 10, 64)
+	if err != nil || after < 0 || epoch == "" {
+		return nil, ErrInvalidToken
+	}
+	if epoch != b.epoch {
+		s := b.subscribe(0, types)
+		s.Reset = ResetRestarted
+		return s, nil
+	}
+	return b.subscribe(after, types), nil
+}
+
+// subscribe 订阅 ID 大于 after 的事件，after 之后的事件已被移出历史时标记为 ResetExpired
+func (b *Bus) subscribe(after int64, types []Type) *Subscription {
+	sub := &subscriber{ch: make(chan Event, subscriberBuffer)}
+	if len(types) > 0 {
+		sub.types = make(map[Type]bool, len(types))
+		for _, t := range types {
+			sub.types[t] = true
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s := &Subscription{Events: sub.ch, bus: b, sub: sub}
+	if len(b.history) > 0 && b.history[0].ID > after+1 {
+		s.Reset = ResetExpired
+	}
+	for _, event := range b.history {
+		if event.ID > after && sub.wants(event.Type) {
+			s.Backlog = append(s.Backlog, event)
+		}
+	}
+	b.subs[sub] = struct{}{}
+
+	var once sync.Once
+	s.cancel = func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subs, sub)
+			b.mu.Unlock()
+		})
+	}
+	return s
+}
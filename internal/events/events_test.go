@@ -0,0 +1,132 @@
+package events
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSubscribeBacklogAndLive(t *testing.T) {
+	bus := New(2)
+	bus.Publish(TypeChat, "first")
+	bus.Publish(TypeChat, "second")
+	bus.Publish(TypeChat, "third")
+
+	backlog, events, cancel := bus.Subscribe(2)
+	defer cancel()
+	if len(backlog) != 1 || backlog[0].Data != "third" {
+		t.Fatalf("expected backlog with the third event, got %+v", backlog)
+	}
+
+	bus.Publish(TypeRun, "fourth")
+	select {
+	case event := <-events:
+		if event.ID != 4 || event.Type != TypeRun {
+			t.Errorf("unexpected event %+v", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected live event")
+	}
+
+	cancel()
+	bus.Publish(TypeRun, "fifth")
+	select {
+	case event := <-events:
+		t.Errorf("unexpected event after cancel: %+v", event)
+	default:
+	}
+}
+
+func TestSubscribeTypes(t *testing.T) {
+	bus := New(0)
+	bus.Publish(TypeTask, "task")
+	bus.Publish(TypeTool, "tool")
+
+	backlog, events, cancel := bus.Subscribe(0, TypeTool, TypeApproval)
+	defer cancel()
+	if len(backlog) != 1 || backlog[0].Type != TypeTool {
+		t.Fatalf("expected only the tool event in backlog, got %+v", backlog)
+	}
+
+	bus.Publish(TypeFile, "file")
+	bus.Publish(TypeApproval, "approval")
+	select {
+	case event := <-events:
+		if event.Type != TypeApproval {
+			t.Errorf("expected approval event, got %+v", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected live event")
+	}
+}
+
+func TestNilBus(t *testing.T) {
+	var bus *Bus
+	bus.Publish(TypeTask, "dropped")
+}
+
+func TestHook(t *testing.T) {
+	bus := New(1)
+	var got []int64
+	bus.Hook(func(e Event) { got = append(got, e.ID) }, TypeFile, TypeCommand)
+	for i := 0; i < 100; i++ {
+		bus.Publish(TypeFile, i)
+	}
+	bus.Publish(TypeChat, "ignored")
+	bus.Publish(TypeCommand, "ls")
+	if len(got) != 101 || got[0] != 1 || got[100] != 102 {
+		t.Errorf("expected every matching event in order, got %d events", len(got))
+	}
+}
+
+func TestResume(t *testing.T) {
+	bus := New(3)
+	for i := 0; i < 5; i++ {
+		bus.Publish(TypeRun, i)
+	}
+
+	// 令牌之后的事件都在历史中
+	sub, err := bus.Resume(bus.Token(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub.Cancel()
+	if sub.Reset != "" || len(sub.Backlog) != 2 || sub.Backlog[0].ID != 4 {
+		t.Errorf("expected seamless resume with two events, got %q %+v", sub.Reset, sub.Backlog)
+	}
+	// 不带 epoch 的事件 ID 按当前进程处理
+	if sub, err = bus.Resume("4"); err != nil || sub.Reset != "" || len(sub.Backlog) != 1 {
+		t.Errorf("expected plain event id to resume, got %v %+v", err, sub)
+	}
+	sub.Cancel()
+
+	// 第 2 个事件已被移出历史
+	sub, _ = bus.Resume(bus.Token(1))
+	sub.Cancel()
+	if sub.Reset != ResetExpired || len(sub.Backlog) != 3 {
+		t.Errorf("expected expired resume with the retained events, got %q %+v", sub.Reset, sub.Backlog)
+	}
+
+	// 服务重启后旧令牌从头开始
+	sub, _ = New(10).Resume(bus.Token(5))
+	sub.Cancel()
+	if sub.Reset != ResetRestarted {
+		t.Errorf("expected restarted reset, got %q", sub.Reset)
+	}
+
+	if _, err := bus.Resume("x-y"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expected invalid token, got %v", err)
+	}
+
+	sub, _ = bus.Resume(bus.Position())
+	defer sub.Cancel()
+	if len(sub.Backlog) != 0 {
+		t.Errorf("expected no backlog from the current position, got %+v", sub.Backlog)
+	}
+	for i := 0; i < subscriberBuffer+2; i++ {
+		bus.Publish(TypeRun, i)
+	}
+	if n := sub.Dropped(); n != 2 || sub.Dropped() != 0 {
+		t.Errorf("expected two dropped events reported once, got %d", n)
+	}
+}
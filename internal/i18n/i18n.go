@@ -0,0 +1,85 @@
+package i18n
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// Locale 表示语言区域
+type Locale string
+
+const (
+	ZhCN Locale = "zh-CN"
+	EnUS Locale = "en-US"
+
+	// DefaultLocale 是未配置时使用的语言
+	DefaultLocale = ZhCN
+)
+
+var (
+	mu     sync.RWMutex
+	locale = DefaultLocale
+)
+
+func init() {
+	// 在加载配置前就可能输出信息（如命令行帮助），先从环境变量读取
+	if l := os.Getenv("VIMCOPLIT_LOCALE"); l != "" {
+		SetLocale(Locale(l))
+	}
+}
+
+// SetLocale 设置当前语言，不支持的语言返回错误且保持原设置
+func SetLocale(l Locale) error {
+	if l == "" {
+		l = DefaultLocale
+	}
+	if !Supported(l) {
+		return fmt.Errorf("unsupported locale: %s", l)
+	}
+	mu.Lock()
+	locale = l
+	mu.Unlock()
+	return nil
+}
+
+// Current 返回当前语言
+func Current() Locale {
+	mu.RLock()
+	defer mu.RUnlock()
+	return locale
+}
+
+// Supported 判断是否支持该语言
+func Supported(l Locale) bool {
+	return l == ZhCN || l == EnUS
+}
+
+// T 返回 key 在当前语言下的消息，args 按 fmt 格式化
+// 当前语言缺少该消息时回退到英文，仍缺少时返回 key 本身
+func T(key string, args ...interface{}) string {
+	format := lookup(Current(), key)
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// Error 返回当前语言下的错误
+func Error(key string, args ...interface{}) error {
+	return errors.New(T(key, args...))
+}
+
+// lookup 查找消息模板
+func lookup(l Locale, key string) string {
+	if msgs, ok := catalog[key]; ok {
+		if msg, ok := msgs[l]; ok {
+			return msg
+		}
+		if msg, ok := msgs[EnUS]; ok {
+			return msg
+		}
+	}
+	return key
+}
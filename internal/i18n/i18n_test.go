@@ -0,0 +1,42 @@
+package i18n
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestT(t *testing.T) {
+	defer SetLocale(Current())
+
+	SetLocale(EnUS)
+	if got := T("cli.server_started", "localhost:8080"); got != "VimCoplit server listening on localhost:8080" {
+		t.Errorf("unexpected en-US message: %q", got)
+	}
+	SetLocale(ZhCN)
+	if got := T("api.method_not_allowed"); got != "不支持的请求方法" {
+		t.Errorf("unexpected zh-CN message: %q", got)
+	}
+	if got := T("no.such.key"); got != "no.such.key" {
+		t.Errorf("expected missing key to fall back to key, got %q", got)
+	}
+
+	if err := SetLocale("fr-FR"); err == nil {
+		t.Error("expected unsupported locale error")
+	}
+	if Current() != ZhCN {
+		t.Errorf("expected locale to be unchanged, got %s", Current())
+	}
+}
+
+func TestCatalogComplete(t *testing.T) {
+	for key, msgs := range catalog {
+		for _, l := range []Locale{ZhCN, EnUS} {
+			if msgs[l] == "" {
+				t.Errorf("message %s is missing locale %s", key, l)
+			}
+		}
+		if strings.Count(msgs[ZhCN], "%") != strings.Count(msgs[EnUS], "%") {
+			t.Errorf("message %s has mismatched format verbs", key)
+		}
+	}
+}
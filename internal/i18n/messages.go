@@ -0,0 +1,370 @@
+package i18n
+
+// catalog 是消息目录，key 按 "模块.含义" 命名
+var catalog = map[string]map[Locale]string{
+	// API 错误
+	"api.method_not_allowed": {
+		ZhCN: "不支持的请求方法",
+		EnUS: "method not allowed",
+	},
+	"api.task_id_required": {
+		ZhCN: "缺少任务 ID",
+		EnUS: "task ID is required",
+	},
+	"api.server_id_required": {
+		ZhCN: "缺少服务器 ID",
+		EnUS: "server ID is required",
+	},
+	"api.run_id_required": {
+		ZhCN: "缺少运行 ID",
+		EnUS: "run ID is required",
+	},
+	"api.path_required": {
+		ZhCN: "缺少路径",
+		EnUS: "path is required",
+	},
+	"api.error_text_required": {
+		ZhCN: "缺少错误信息",
+		EnUS: "error text is required",
+	},
+	"api.query_required": {
+		ZhCN: "缺少检索词或正则表达式",
+		EnUS: "query or regex is required",
+	},
+	"api.diff_required": {
+		ZhCN: "缺少 diff 或修订范围",
+		EnUS: "diff or range is required",
+	},
+	"api.title_required": {
+		ZhCN: "缺少标题",
+		EnUS: "title is required",
+	},
+	"api.prompt_required": {
+		ZhCN: "缺少提示词",
+		EnUS: "prompt is required",
+	},
+	"api.analytics_export_disabled": {
+		ZhCN: "未开启使用统计导出",
+		EnUS: "analytics export is disabled",
+	},
+	"api.audit_disabled": {
+		ZhCN: "未启用审计日志",
+		EnUS: "audit log is disabled",
+	},
+	"api.host_not_allowed": {
+		ZhCN: "不允许的 Host",
+		EnUS: "host not allowed",
+	},
+	"api.client_not_allowed": {
+		ZhCN: "不允许的客户端地址",
+		EnUS: "client address not allowed",
+	},
+	"api.observer_token_invalid": {
+		ZhCN: "观察者令牌无效或已过期",
+		EnUS: "observer token is invalid or expired",
+	},
+	"api.debug_token_invalid": {
+		ZhCN: "调试令牌无效",
+		EnUS: "debug token is invalid",
+	},
+	"api.observer_read_only": {
+		ZhCN: "观察者令牌只能访问只读接口",
+		EnUS: "observer token is read-only",
+	},
+	"api.owner_token_required": {
+		ZhCN: "来自其他机器的请求需要在 Authorization 头中带 server.token",
+		EnUS: "requests from other machines require server.token in the Authorization header",
+	},
+	"api.stream_multiple": {
+		ZhCN: "流式请求不支持 n > 1",
+		EnUS: "stream does not support n > 1",
+	},
+	"api.number_body_required": {
+		ZhCN: "缺少编号或内容",
+		EnUS: "number and body are required",
+	},
+	"api.search_query_required": {
+		ZhCN: "缺少搜索词",
+		EnUS: "query is required",
+	},
+	"api.conflict_resolution_required": {
+		ZhCN: "缺少 content 或 keep",
+		EnUS: "content or keep is required",
+	},
+	"api.conflict_keep_invalid": {
+		ZhCN: "keep 只能是 current 或 edit",
+		EnUS: "keep must be current or edit",
+	},
+	"api.limit_invalid": {
+		ZhCN: "limit 无效: %v",
+		EnUS: "invalid limit: %v",
+	},
+	"api.origin_not_allowed": {
+		ZhCN: "不接受来自其他网站的请求，请设置 server.token 并在 Authorization 头中携带",
+		EnUS: "requests from other origins are not allowed, set server.token and send it in the Authorization header",
+	},
+	"api.content_type_required": {
+		ZhCN: "修改请求需要 Content-Type: application/json 或在 Authorization 头中携带 server.token",
+		EnUS: "state-changing requests require Content-Type: application/json or server.token in the Authorization header",
+	},
+	"api.heartbeat_invalid": {
+		ZhCN: "heartbeat 无效: %s",
+		EnUS: "invalid heartbeat: %s",
+	},
+	"api.event_id_invalid": {
+		ZhCN: "事件 ID 无效: %s",
+		EnUS: "invalid event id: %s",
+	},
+	"api.permission_answer_invalid": {
+		ZhCN: "权限应答无效: %q",
+		EnUS: "invalid permission answer %q",
+	},
+
+	// 命令行参数
+	"cli.flag_config": {
+		ZhCN: "配置文件路径",
+		EnUS: "path to the config file",
+	},
+	"cli.flag_host": {
+		ZhCN: "服务器监听地址（默认使用配置文件）",
+		EnUS: "address to listen on (defaults to the config file)",
+	},
+	"cli.flag_port": {
+		ZhCN: "服务器监听端口（默认使用配置文件）",
+		EnUS: "port to listen on (defaults to the config file)",
+	},
+	"cli.flag_version": {
+		ZhCN: "显示版本信息并退出",
+		EnUS: "print version information and exit",
+	},
+	"cli.flag_output": {
+		ZhCN: "备份文件路径",
+		EnUS: "path of the backup file",
+	},
+	"cli.flag_local_port": {
+		ZhCN: "本地监听端口",
+		EnUS: "local port to listen on",
+	},
+	"cli.flag_remote_port": {
+		ZhCN: "远程服务器端口",
+		EnUS: "port of the remote server",
+	},
+	"cli.flag_remote_host": {
+		ZhCN: "远程机器上服务监听的地址",
+		EnUS: "address the server listens on at the remote machine",
+	},
+	"cli.flag_identity": {
+		ZhCN: "SSH 私钥文件",
+		EnUS: "SSH private key file",
+	},
+	"cli.flag_ssh": {
+		ZhCN: "SSH 可执行文件",
+		EnUS: "SSH executable",
+	},
+	"cli.flag_provider": {
+		ZhCN: "模型提供商",
+		EnUS: "model provider",
+	},
+	"cli.flag_api_key": {
+		ZhCN: "API Key",
+		EnUS: "API key",
+	},
+	"cli.flag_key_storage": {
+		ZhCN: "API Key 的保存方式: config、file 或 env",
+		EnUS: "where to store the API key: config, file or env",
+	},
+	"cli.flag_root": {
+		ZhCN: "工作区根目录",
+		EnUS: "workspace root directory",
+	},
+	"cli.flag_allowed_cmds": {
+		ZhCN: "允许执行的命令，逗号分隔",
+		EnUS: "comma-separated list of allowed commands",
+	},
+	"cli.flag_skip_validate": {
+		ZhCN: "跳过连通性检查",
+		EnUS: "skip the connectivity check",
+	},
+	"cli.flag_channel": {
+		ZhCN: "发布渠道: stable 或 beta（默认使用配置文件）",
+		EnUS: "release channel: stable or beta (defaults to the config file)",
+	},
+	"cli.flag_check": {
+		ZhCN: "只检查是否有新版本，不安装",
+		EnUS: "only check for a newer version, do not install",
+	},
+	"cli.flag_insecure": {
+		ZhCN: "没有发布公钥时也安装，只校验摘要",
+		EnUS: "install without a release public key, verifying only the checksum",
+	},
+	"cli.flag_yes": {
+		ZhCN: "不交互，使用参数和默认值",
+		EnUS: "do not prompt, use flags and defaults",
+	},
+
+	// 命令行输出
+	"cli.command_failed": {
+		ZhCN: "%s 失败: %v",
+		EnUS: "%s failed: %v",
+	},
+	"cli.load_config_failed": {
+		ZhCN: "加载配置失败: %v",
+		EnUS: "failed to load config: %v",
+	},
+	"cli.invalid_locale": {
+		ZhCN: "语言配置无效: %v",
+		EnUS: "invalid locale: %v",
+	},
+	"cli.recover_failed": {
+		ZhCN: "恢复持久化状态失败: %v",
+		EnUS: "failed to recover persisted state: %v",
+	},
+	"cli.recover_done": {
+		ZhCN: "状态恢复完成: 重放写入 %d 个, 跳过已修改文件的写入 %d 个, 恢复任务 %d 个, 失败任务 %d 个, 重置服务器 %d 个",
+		EnUS: "state recovered: %d writes replayed, %d writes skipped for modified files, %d tasks resumed, %d tasks failed, %d servers reset",
+	},
+	"cli.warmup_ok": {
+		ZhCN: "模型预热完成: %s, 耗时 %dms",
+		EnUS: "model warm-up succeeded: %s in %dms",
+	},
+	"cli.warmup_failed": {
+		ZhCN: "模型预热失败: %s (API Key 有效: %t)",
+		EnUS: "model warm-up failed: %s (API key valid: %t)",
+	},
+	"cli.access_config_invalid": {
+		ZhCN: "访问控制配置错误: %v",
+		EnUS: "invalid access control config: %v",
+	},
+	"cli.shutting_down": {
+		ZhCN: "正在关闭服务器...",
+		EnUS: "shutting down server...",
+	},
+	"cli.close_failed": {
+		ZhCN: "关闭服务时出错: %v",
+		EnUS: "failed to close the service: %v",
+	},
+	"cli.shutdown_failed": {
+		ZhCN: "关闭服务器时出错: %v",
+		EnUS: "failed to shut down server: %v",
+	},
+	"cli.server_started": {
+		ZhCN: "VimCoplit 服务器启动在 %s",
+		EnUS: "VimCoplit server listening on %s",
+	},
+	"cli.dashboard_url": {
+		ZhCN: "管理面板：http://%s/dashboard/",
+		EnUS: "Dashboard: http://%s/dashboard/",
+	},
+	"cli.debug_started": {
+		ZhCN: "调试端口启动在 %s",
+		EnUS: "debug server listening on %s",
+	},
+	"cli.server_token_missing": {
+		ZhCN: "服务监听在 %s 但未设置 server.token，只接受本机和观察者令牌的请求",
+		EnUS: "listening on %s without server.token; only local requests and observer tokens are accepted",
+	},
+	"cli.debug_token_required": {
+		ZhCN: "未设置 debug.token，不启动调试端口",
+		EnUS: "debug.token is not set, debug server not started",
+	},
+	"cli.debug_server_error": {
+		ZhCN: "调试端口错误: %v",
+		EnUS: "debug server error: %v",
+	},
+	"cli.server_error": {
+		ZhCN: "服务器错误: %v",
+		EnUS: "server error: %v",
+	},
+	"cli.backup_done": {
+		ZhCN: "备份完成: %s (%d 个文件)",
+		EnUS: "backup written: %s (%d files)",
+	},
+	"cli.restore_done": {
+		ZhCN: "恢复完成: 备份创建于 %s (%d 个文件)",
+		EnUS: "restore complete: backup created at %s (%d files)",
+	},
+	"cli.tunnel_opening": {
+		ZhCN: "正在建立隧道: localhost:%d -> %s:%s:%d",
+		EnUS: "opening tunnel: localhost:%d -> %s:%s:%d",
+	},
+	"cli.tunnel_closed": {
+		ZhCN: "隧道已关闭",
+		EnUS: "tunnel closed",
+	},
+	"cli.tunnel_usage": {
+		ZhCN: "用法: vimcoplit tunnel [参数] [user@]host",
+		EnUS: "usage: vimcoplit tunnel [flags] [user@]host",
+	},
+	"cli.restore_usage": {
+		ZhCN: "用法: vimcoplit restore [-config 路径] 文件",
+		EnUS: "usage: vimcoplit restore [-config path] file",
+	},
+	"cli.index_usage": {
+		ZhCN: "用法: vimcoplit index [-config 路径] stats|rebuild",
+		EnUS: "usage: vimcoplit index [-config path] stats|rebuild",
+	},
+	"cli.config_usage": {
+		ZhCN: "用法: vimcoplit config show [-config 路径] [-origin]",
+		EnUS: "usage: vimcoplit config show [-config path] [-origin]",
+	},
+	"cli.flag_origin": {
+		ZhCN: "同时显示每个配置项的来源",
+		EnUS: "also show where each value came from",
+	},
+	"cli.index_rebuilt": {
+		ZhCN: "索引重建完成，耗时 %v",
+		EnUS: "index rebuilt in %v",
+	},
+	"cli.index_stats": {
+		ZhCN: "索引格式 v%d: %d 个文件, %d 个片段, %d 个词, %d 个段文件, 占用 %d 字节, 更新于 %s",
+		EnUS: "index format v%d: %d files, %d chunks, %d terms, %d segments, %d bytes on disk, updated %s",
+	},
+	"cli.not_configured": {
+		ZhCN: "配置文件 %s 不存在，使用默认配置，运行 vimcoplit init 完成配置",
+		EnUS: "config file %s does not exist, using defaults; run vimcoplit init to configure",
+	},
+	"cli.init_provider": {
+		ZhCN: "模型提供商 (%s)",
+		EnUS: "Model provider (%s)",
+	},
+	"cli.init_api_key": {
+		ZhCN: "API Key",
+		EnUS: "API key",
+	},
+	"cli.init_key_storage": {
+		ZhCN: "API Key 保存方式 (config/file/env)",
+		EnUS: "Store API key in (config/file/env)",
+	},
+	"cli.init_root": {
+		ZhCN: "工作区根目录",
+		EnUS: "Workspace root",
+	},
+	"cli.init_allowed_cmds": {
+		ZhCN: "允许执行的命令（逗号分隔）",
+		EnUS: "Allowed commands (comma-separated)",
+	},
+	"cli.init_validating": {
+		ZhCN: "正在检查与 %s 的连通性...",
+		EnUS: "checking connectivity to %s...",
+	},
+	"cli.init_validated": {
+		ZhCN: "连通性检查通过，耗时 %dms",
+		EnUS: "connectivity check passed in %dms",
+	},
+	"cli.init_done": {
+		ZhCN: "配置已写入 %s",
+		EnUS: "config written to %s",
+	},
+	"cli.update_latest": {
+		ZhCN: "已是最新版本: %s (%s 渠道)",
+		EnUS: "already up to date: %s (%s channel)",
+	},
+	"cli.update_available": {
+		ZhCN: "发现新版本: %s -> %s (%s 渠道)",
+		EnUS: "update available: %s -> %s (%s channel)",
+	},
+	"cli.update_done": {
+		ZhCN: "已更新到 %s，重启服务后生效",
+		EnUS: "updated to %s, restart the server to use it",
+	},
+}
@@ -0,0 +1,68 @@
+// Package logbuf 在内存中保留最近的日志行，供管理面板查看
+package logbuf
+
+import (
+	"bytes"
+	"sync"
+)
+
+// Buffer 保存最近 size 行日志的环形缓冲区，实现 io.Writer
+type Buffer struct {
+	mu      sync.Mutex
+	lines   []string
+	size    int
+	next    int
+	full    bool
+	partial []byte
+}
+
+// New 创建一个最多保留 size 行的缓冲区
+func New(size int) *Buffer {
+	if size <= 0 {
+		size = 500
+	}
+	return &Buffer{lines: make([]string, size), size: size}
+}
+
+// Write 按行写入日志，不完整的行等到换行后再记录
+func (b *Buffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	data := append(b.partial, p...)
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		b.append(string(data[:i]))
+		data = data[i+1:]
+	}
+	b.partial = append([]byte(nil), data...)
+	return len(p), nil
+}
+
+// append 记录一行，调用方需持有锁
+func (b *Buffer) append(line string) {
+	b.lines[b.next] = line
+	b.next = (b.next + 1) % b.size
+	if b.next == 0 {
+		b.full = true
+	}
+}
+
+// Lines 返回最近的 limit 行，按时间先后排列，limit <= 0 时返回全部
+func (b *Buffer) Lines(limit int) []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var out []string
+	if b.full {
+		out = append(out, b.lines[b.next:]...)
+	}
+	out = append(out, b.lines[:b.next]...)
+	if limit > 0 && len(out) > limit {
+		out = out[len(out)-limit:]
+	}
+	return out
+}
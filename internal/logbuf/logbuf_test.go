@@ -0,0 +1,36 @@
+package logbuf
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestBufferKeepsRecentLines(t *testing.T) {
+	b := New(3)
+	for i := 1; i <= 5; i++ {
+		fmt.Fprintf(b, "line %d\n", i)
+	}
+
+	want := []string{"line 3", "line 4", "line 5"}
+	if got := b.Lines(0); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	if got := b.Lines(2); !reflect.DeepEqual(got, want[1:]) {
+		t.Errorf("expected %v, got %v", want[1:], got)
+	}
+}
+
+func TestBufferPartialLines(t *testing.T) {
+	b := New(10)
+	b.Write([]byte("hel"))
+	if got := b.Lines(0); len(got) != 0 {
+		t.Fatalf("expected no complete lines, got %v", got)
+	}
+	b.Write([]byte("lo\nworld\n"))
+
+	want := []string{"hello", "world"}
+	if got := b.Lines(0); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
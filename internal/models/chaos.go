@@ -0,0 +1,75 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/liangsj/vimcoplit/internal/chaos"
+)
+
+// chaosModel 按故障注入配置在提供商调用上注入延迟、错误、限流和截断的流式输出，
+// 位于 Key 池和限流包装层之下，用于在集成测试中检验它们的重试和切换逻辑
+type chaosModel struct {
+	Model
+	injector *chaos.Injector
+}
+
+// withChaos 为提供商模型的构造函数加上故障注入，injector 为 nil 时原样返回
+func withChaos(injector *chaos.Injector, factory func(ModelConfig) (Model, error)) func(ModelConfig) (Model, error) {
+	if injector == nil {
+		return factory
+	}
+	return func(config ModelConfig) (Model, error) {
+		model, err := factory(config)
+		if err != nil {
+			return nil, err
+		}
+		return &chaosModel{Model: model, injector: injector}, nil
+	}
+}
+
+func (m *chaosModel) Generate(ctx context.Context, prompt string) (string, error) {
+	return m.call(ctx, func(ctx context.Context) (string, error) { return m.Model.Generate(ctx, prompt) })
+}
+
+func (m *chaosModel) GenerateJSON(ctx context.Context, prompt string, schema []byte) (string, error) {
+	return m.call(ctx, func(ctx context.Context) (string, error) { return GenerateJSON(ctx, m.Model, prompt, schema) })
+}
+
+func (m *chaosModel) GenerateWithTools(ctx context.Context, messages []Message, tools []ToolSpec) (*ToolResponse, error) {
+	var resp *ToolResponse
+	_, err := m.call(ctx, func(ctx context.Context) (string, error) {
+		var err error
+		resp, err = GenerateWithTools(ctx, m.Model, messages, tools)
+		return toolText(resp), err
+	})
+	return resp, err
+}
+
+func (m *chaosModel) GenerateStream(ctx context.Context, prompt string) (<-chan string, error) {
+	return streamGenerate(ctx, m, prompt)
+}
+
+// call 注入故障后调用模型。截断时模型的流式输出被截留，只向调用方输出前一半后返回 chaos.ErrTruncated
+func (m *chaosModel) call(ctx context.Context, generate func(ctx context.Context) (string, error)) (string, error) {
+	fault, err := m.injector.Inject(ctx, chaos.TargetModel)
+	if err != nil {
+		return "", err
+	}
+	switch fault {
+	case chaos.FaultError:
+		return "", chaos.ErrInjected
+	case chaos.FaultRateLimit:
+		return "", &RateLimitError{RetryAfter: time.Second, Message: chaos.ErrInjected.Error()}
+	case chaos.FaultTruncate:
+		// 完整输出不交给调用方，模拟连接在中途断开
+		output, err := generate(WithTokenHandler(ctx, func(string) {}))
+		if err != nil {
+			return "", err
+		}
+		partial := chaos.Truncate(output)
+		EmitToken(ctx, partial)
+		return partial, chaos.ErrTruncated
+	}
+	return generate(ctx)
+}
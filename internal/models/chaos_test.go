@@ -0,0 +1,47 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/liangsj/vimcoplit/internal/chaos"
+)
+
+func TestChaosModel(t *testing.T) {
+	factory := func(config ModelConfig) (Model, error) {
+		return &fakeModel{key: "abcdef"}, nil
+	}
+	if _, ok := mustModel(t, withChaos(nil, factory)).(*fakeModel); !ok {
+		t.Error("expected no wrapper without injector")
+	}
+
+	ctx := context.Background()
+	model := mustModel(t, withChaos(chaos.New(chaos.Config{ErrorRate: 1}), factory))
+	if _, err := model.Generate(ctx, "x"); !errors.Is(err, chaos.ErrInjected) {
+		t.Errorf("expected injected error, got %v", err)
+	}
+
+	model = mustModel(t, withChaos(chaos.New(chaos.Config{RateLimitRate: 1}), factory))
+	var rateErr *RateLimitError
+	if _, err := model.Generate(ctx, "x"); !errors.As(err, &rateErr) {
+		t.Errorf("expected rate limit error, got %v", err)
+	}
+
+	model = mustModel(t, withChaos(chaos.New(chaos.Config{TruncateRate: 1}), factory))
+	var streamed string
+	output, err := model.Generate(WithTokenHandler(ctx, func(token string) { streamed += token }), "x")
+	if !errors.Is(err, chaos.ErrTruncated) || output != "abc" || streamed != "abc" {
+		t.Errorf("expected truncated stream, got %q %q %v", output, streamed, err)
+	}
+}
+
+// mustModel 使用构造函数创建模型
+func mustModel(t *testing.T, factory func(ModelConfig) (Model, error)) Model {
+	t.Helper()
+	model, err := factory(ModelConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return model
+}
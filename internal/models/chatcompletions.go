@@ -0,0 +1,259 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// chatRequest 是 OpenAI 兼容的 chat completions 请求体
+type chatRequest struct {
+	Model          string              `json:"model"`
+	Messages       []chatMessage       `json:"messages"`
+	MaxTokens      int                 `json:"max_tokens,omitempty"`
+	Temperature    *float64            `json:"temperature,omitempty"`
+	TopP           float64             `json:"top_p,omitempty"`
+	Stop           []string            `json:"stop,omitempty"`
+	Seed           *int64              `json:"seed,omitempty"`
+	Stream         bool                `json:"stream"`
+	StreamOptions  *chatStreamOptions  `json:"stream_options,omitempty"`
+	ResponseFormat *chatResponseFormat `json:"response_format,omitempty"`
+	Tools          []chatTool          `json:"tools,omitempty"`
+}
+
+type chatMessage struct {
+	Role       string         `json:"role"`
+	Content    string         `json:"content"`
+	ToolCalls  []chatToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string         `json:"tool_call_id,omitempty"`
+}
+
+// chatTool 是请求中可以调用的函数
+type chatTool struct {
+	Type     string       `json:"type"`
+	Function chatFunction `json:"function"`
+}
+
+type chatFunction struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters,omitempty"`
+}
+
+// chatToolCall 是模型请求的函数调用，流式响应中按 Index 分段给出，Arguments 为 JSON 字符串
+type chatToolCall struct {
+	Index    int    `json:"index,omitempty"`
+	ID       string `json:"id,omitempty"`
+	Type     string `json:"type,omitempty"`
+	Function struct {
+		Name      string `json:"name,omitempty"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+type chatStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type chatResponseFormat struct {
+	Type string `json:"type"`
+}
+
+// chatChunk 是流式响应中的一段，开启 include_usage 时最后一段的 choices 为空并带有 usage
+type chatChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content   string         `json:"content"`
+			ToolCalls []chatToolCall `json:"tool_calls"`
+		} `json:"delta"`
+	} `json:"choices"`
+	Usage *struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+	} `json:"usage"`
+	apiErrorBody
+}
+
+// chatClient 调用 OpenAI 兼容的 chat completions 接口
+type chatClient struct {
+	provider    string
+	baseURL     string // 默认的 API 根地址，ModelConfig.BaseURL 不为空时使用后者
+	model       string // ModelConfig.Model 为空时使用的模型
+	config      ModelConfig
+	client      *http.Client
+	keyOptional bool // 没有 API Key 时不带鉴权请求头，用于本地推理服务
+	azure       bool // Azure OpenAI：按部署名拼接地址并使用 api-key 请求头
+}
+
+// newChatRequest 按模型配置和 ctx 中的采样参数构造流式请求
+func (c *chatClient) newChatRequest(ctx context.Context, prompt string) (*chatRequest, error) {
+	if c.config.APIKey == "" && !c.keyOptional {
+		return nil, fmt.Errorf("%w: API key is not configured", ErrUnauthorized)
+	}
+	params, err := ParamsFrom(ctx).Resolve(c.config.ModelType, c.config.Temperature)
+	if err != nil {
+		return nil, err
+	}
+	model := c.config.Model
+	if model == "" {
+		model = c.model
+	}
+	maxTokens := c.config.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = defaultMaxTokens
+	}
+	return &chatRequest{
+		Model:         model,
+		Messages:      []chatMessage{{Role: "user", Content: prompt}},
+		MaxTokens:     maxTokens,
+		Temperature:   params.Temperature,
+		TopP:          c.config.TopP,
+		Stop:          c.config.Stop,
+		Seed:          params.Seed,
+		Stream:        true,
+		StreamOptions: &chatStreamOptions{IncludeUsage: true},
+	}, nil
+}
+
+// complete 以流式方式发送请求，对每段输出调用 EmitToken，结束后用 ReportUsage 报告 token 用量
+func (c *chatClient) complete(ctx context.Context, req *chatRequest) (string, error) {
+	output, _, err := c.send(ctx, req)
+	return output, err
+}
+
+// send 以流式方式发送请求，返回生成的文本和按序拼接好的函数调用
+func (c *chatClient) send(ctx context.Context, req *chatRequest) (string, []chatToolCall, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return "", nil, err
+	}
+	header := http.Header{}
+	switch {
+	case c.config.APIKey == "":
+	case c.azure:
+		header.Set("api-key", c.config.APIKey)
+	default:
+		header.Set("Authorization", "Bearer "+c.config.APIKey)
+	}
+	header.Set("Content-Type", "application/json")
+	header.Set("Accept", "text/event-stream")
+	resp, err := postWithRetry(ctx, c.client, c.provider, c.endpoint(req.Model), header, body, c.config.RateLimiter)
+	if err != nil {
+		return "", nil, err
+	}
+	defer resp.Body.Close()
+
+	// 输出开始后不再重试，失败时连同已生成的部分一起返回
+	var output strings.Builder
+	var calls []chatToolCall
+	err = readSSE(resp.Body, func(data []byte) error {
+		var chunk chatChunk
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			return fmt.Errorf("%s API returned an invalid stream chunk: %w", c.provider, err)
+		}
+		if chunk.Error != nil {
+			return &APIError{Provider: c.provider, Type: chunk.Error.Type, Message: chunk.Error.Message}
+		}
+		for _, choice := range chunk.Choices {
+			if text := choice.Delta.Content; text != "" {
+				output.WriteString(text)
+				EmitToken(ctx, text)
+			}
+			for _, delta := range choice.Delta.ToolCalls {
+				// 同一调用的后续分段只带参数的增量，部分服务在每段中重复调用 ID
+				if n := len(calls); n == 0 || calls[n-1].Index != delta.Index || (delta.ID != "" && delta.ID != calls[n-1].ID) {
+					calls = append(calls, delta)
+					continue
+				}
+				last := &calls[len(calls)-1]
+				if last.Function.Name == "" {
+					last.Function.Name = delta.Function.Name
+				}
+				last.Function.Arguments += delta.Function.Arguments
+			}
+		}
+		if chunk.Usage != nil {
+			ReportUsage(ctx, Usage{PromptTokens: chunk.Usage.PromptTokens, CompletionTokens: chunk.Usage.CompletionTokens})
+		}
+		return nil
+	})
+	if err != nil && ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	return output.String(), calls, err
+}
+
+// endpoint 返回 chat completions 接口的地址
+func (c *chatClient) endpoint(model string) string {
+	baseURL := c.baseURL
+	if c.config.BaseURL != "" {
+		baseURL = strings.TrimSuffix(c.config.BaseURL, "/")
+	}
+	if c.azure {
+		return azureEndpoint(baseURL, model)
+	}
+	return baseURL + "/chat/completions"
+}
+
+// generate 生成纯文本响应
+func (c *chatClient) generate(ctx context.Context, prompt string) (string, error) {
+	req, err := c.newChatRequest(ctx, prompt)
+	if err != nil {
+		return "", err
+	}
+	return c.complete(ctx, req)
+}
+
+// generateJSON 以 response_format json_object 生成响应，提示词需要说明输出的 schema
+func (c *chatClient) generateJSON(ctx context.Context, prompt string) (string, error) {
+	req, err := c.newChatRequest(ctx, prompt)
+	if err != nil {
+		return "", err
+	}
+	req.ResponseFormat = &chatResponseFormat{Type: "json_object"}
+	return c.complete(ctx, req)
+}
+
+// generateWithTools 以多轮对话和函数列表生成一轮回复
+func (c *chatClient) generateWithTools(ctx context.Context, messages []Message, tools []ToolSpec) (*ToolResponse, error) {
+	req, err := c.newChatRequest(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	req.Messages = make([]chatMessage, 0, len(messages))
+	for _, msg := range messages {
+		m := chatMessage{Role: msg.Role, Content: msg.Content, ToolCallID: msg.ToolCallID}
+		for _, call := range msg.ToolCalls {
+			args, err := json.Marshal(call.Arguments)
+			if err != nil {
+				return nil, err
+			}
+			tc := chatToolCall{ID: call.ID, Type: "function"}
+			tc.Function.Name, tc.Function.Arguments = call.Name, string(args)
+			m.ToolCalls = append(m.ToolCalls, tc)
+		}
+		req.Messages = append(req.Messages, m)
+	}
+	for _, tool := range tools {
+		req.Tools = append(req.Tools, chatTool{
+			Type:     "function",
+			Function: chatFunction{Name: tool.Name, Description: tool.Description, Parameters: tool.Parameters},
+		})
+	}
+
+	output, calls, err := c.send(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	result := &ToolResponse{Text: output}
+	for _, call := range calls {
+		args, err := toolArguments(c.provider, call.Function.Name, call.Function.Arguments)
+		if err != nil {
+			return nil, err
+		}
+		result.ToolCalls = append(result.ToolCalls, ToolCall{ID: call.ID, Name: call.Function.Name, Arguments: args})
+	}
+	return result, nil
+}
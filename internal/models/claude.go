@@ -0,0 +1,271 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Anthropic Messages API
+const (
+	claudeBaseURL        = "https://api.anthropic.com"
+	claudeMessagesPath   = "/v1/messages"
+	claudeAPIVersion     = "2023-06-01"
+	claudeMaxTemperature = 1.0
+)
+
+// defaultMaxTokens 是配置中没有指定 max_tokens 时每次生成的 token 上限
+const defaultMaxTokens = 4096
+
+// claudeModel Claude模型实现，通过 Anthropic Messages API 以流式方式生成并报告 token 用量
+type claudeModel struct {
+	config ModelConfig
+	client *http.Client
+}
+
+func newClaudeModel(config ModelConfig) (Model, error) {
+	return &claudeModel{
+		config: config,
+		client: http.DefaultClient,
+	}, nil
+}
+
+// claudeRequest 是 Messages API 的请求体
+type claudeRequest struct {
+	Model         string          `json:"model"`
+	MaxTokens     int             `json:"max_tokens"`
+	Messages      []claudeMessage `json:"messages"`
+	Temperature   *float64        `json:"temperature,omitempty"`
+	TopP          float64         `json:"top_p,omitempty"`
+	StopSequences []string        `json:"stop_sequences,omitempty"`
+	Stream        bool            `json:"stream"`
+	System        string          `json:"system,omitempty"`
+	Tools         []claudeTool    `json:"tools,omitempty"`
+}
+
+// claudeMessage 的 Content 为字符串或 []claudeBlock
+type claudeMessage struct {
+	Role    string      `json:"role"`
+	Content interface{} `json:"content"`
+}
+
+// claudeBlock 是消息中的一个内容块：text、tool_use 或 tool_result
+type claudeBlock struct {
+	Type      string      `json:"type"`
+	Text      string      `json:"text,omitempty"`
+	ID        string      `json:"id,omitempty"`
+	Name      string      `json:"name,omitempty"`
+	Input     interface{} `json:"input,omitempty"` // tool_use 的参数，没有参数时也需要是空对象
+	ToolUseID string      `json:"tool_use_id,omitempty"`
+	Content   string      `json:"content,omitempty"`
+}
+
+type claudeTool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	InputSchema json.RawMessage `json:"input_schema"`
+}
+
+// claudeEvent 是流式响应中的一个事件，只解析用到的字段。
+// message_start 带有输入的 token 数，message_delta 带有累计的输出 token 数，
+// 工具调用的参数以 input_json_delta 分段给出
+type claudeEvent struct {
+	Type         string `json:"type"`
+	ContentBlock struct {
+		Type string `json:"type"`
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"content_block"`
+	Delta struct {
+		Type        string `json:"type"`
+		Text        string `json:"text"`
+		PartialJSON string `json:"partial_json"`
+	} `json:"delta"`
+	Message struct {
+		Usage claudeUsage `json:"usage"`
+	} `json:"message"`
+	Usage claudeUsage `json:"usage"`
+	apiErrorBody
+}
+
+type claudeUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+func (m *claudeModel) Generate(ctx context.Context, prompt string) (string, error) {
+	req, err := m.newRequest(ctx)
+	if err != nil {
+		return "", err
+	}
+	req.Messages = []claudeMessage{{Role: "user", Content: prompt}}
+	output, _, err := m.send(ctx, req)
+	return output, err
+}
+
+// GenerateWithTools 以多轮对话和工具列表生成一轮回复，system 消息合并为请求的 system 字段，
+// 连续的工具结果合并到同一条 user 消息中
+func (m *claudeModel) GenerateWithTools(ctx context.Context, messages []Message, tools []ToolSpec) (*ToolResponse, error) {
+	req, err := m.newRequest(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var system []string
+	for _, msg := range messages {
+		switch msg.Role {
+		case RoleSystem:
+			system = append(system, msg.Content)
+		case RoleTool:
+			block := claudeBlock{Type: "tool_result", ToolUseID: msg.ToolCallID, Content: msg.Content}
+			if n := len(req.Messages); n > 0 && req.Messages[n-1].Role == "user" {
+				if blocks, ok := req.Messages[n-1].Content.([]claudeBlock); ok {
+					req.Messages[n-1].Content = append(blocks, block)
+					continue
+				}
+			}
+			req.Messages = append(req.Messages, claudeMessage{Role: "user", Content: []claudeBlock{block}})
+		case RoleAssistant:
+			var blocks []claudeBlock
+			if msg.Content != "" {
+				blocks = append(blocks, claudeBlock{Type: "text", Text: msg.Content})
+			}
+			for _, call := range msg.ToolCalls {
+				input := call.Arguments
+				if input == nil {
+					input = map[string]interface{}{}
+				}
+				blocks = append(blocks, claudeBlock{Type: "tool_use", ID: call.ID, Name: call.Name, Input: input})
+			}
+			req.Messages = append(req.Messages, claudeMessage{Role: "assistant", Content: blocks})
+		default:
+			req.Messages = append(req.Messages, claudeMessage{Role: "user", Content: msg.Content})
+		}
+	}
+	req.System = strings.Join(system, "\n\n")
+	for _, tool := range tools {
+		req.Tools = append(req.Tools, claudeTool{Name: tool.Name, Description: tool.Description, InputSchema: tool.Parameters})
+	}
+
+	output, calls, err := m.send(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return &ToolResponse{Text: output, ToolCalls: calls}, nil
+}
+
+// newRequest 按模型配置和 ctx 中的采样参数构造不含消息的流式请求
+func (m *claudeModel) newRequest(ctx context.Context) (*claudeRequest, error) {
+	if m.config.APIKey == "" {
+		return nil, fmt.Errorf("%w: API key is not configured", ErrUnauthorized)
+	}
+	params, err := ParamsFrom(ctx).Resolve(m.config.ModelType, m.config.Temperature)
+	if err != nil {
+		return nil, err
+	}
+	if *params.Temperature > claudeMaxTemperature {
+		return nil, fmt.Errorf("%w: claude accepts temperature between 0 and %g", ErrInvalidParams, claudeMaxTemperature)
+	}
+	maxTokens := m.config.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = defaultMaxTokens
+	}
+	model := string(m.config.ModelType)
+	if m.config.Model != "" {
+		model = m.config.Model
+	}
+	return &claudeRequest{
+		Model:         model,
+		MaxTokens:     maxTokens,
+		Temperature:   params.Temperature,
+		TopP:          m.config.TopP,
+		StopSequences: m.config.Stop,
+		Stream:        true,
+	}, nil
+}
+
+// send 以流式方式发送请求，对每段文本调用 EmitToken，结束时用 ReportUsage 报告 token 用量，
+// 返回生成的文本和工具调用
+func (m *claudeModel) send(ctx context.Context, req *claudeRequest) (string, []ToolCall, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return "", nil, err
+	}
+
+	header := http.Header{}
+	header.Set("x-api-key", m.config.APIKey)
+	header.Set("anthropic-version", claudeAPIVersion)
+	header.Set("content-type", "application/json")
+	header.Set("accept", "text/event-stream")
+	baseURL := claudeBaseURL
+	if m.config.BaseURL != "" {
+		baseURL = strings.TrimSuffix(m.config.BaseURL, "/")
+	}
+	resp, err := postWithRetry(ctx, m.client, "claude", baseURL+claudeMessagesPath, header, body, m.config.RateLimiter)
+	if err != nil {
+		return "", nil, err
+	}
+	defer resp.Body.Close()
+
+	// 输出开始后不再重试，失败时连同已生成的部分一起返回
+	var output strings.Builder
+	var usage Usage
+	var calls []ToolCall
+	var inputs []string // 与 calls 对应的参数 JSON
+	err = readSSE(resp.Body, func(data []byte) error {
+		var event claudeEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			return fmt.Errorf("claude API returned an invalid stream event: %w", err)
+		}
+		switch event.Type {
+		case "message_start":
+			usage.PromptTokens = event.Message.Usage.InputTokens
+			usage.CompletionTokens = event.Message.Usage.OutputTokens
+		case "message_delta":
+			usage.CompletionTokens = event.Usage.OutputTokens
+		case "message_stop":
+			ReportUsage(ctx, usage)
+		case "content_block_start":
+			if event.ContentBlock.Type == "tool_use" {
+				calls = append(calls, ToolCall{ID: event.ContentBlock.ID, Name: event.ContentBlock.Name})
+				inputs = append(inputs, "")
+			}
+		case "content_block_delta":
+			switch {
+			case event.Delta.Type == "text_delta" && event.Delta.Text != "":
+				output.WriteString(event.Delta.Text)
+				EmitToken(ctx, event.Delta.Text)
+			case event.Delta.Type == "input_json_delta" && len(inputs) > 0:
+				inputs[len(inputs)-1] += event.Delta.PartialJSON
+			}
+		case "error":
+			apiErr := &APIError{Provider: "claude"}
+			if event.Error != nil {
+				apiErr.Type, apiErr.Message = event.Error.Type, event.Error.Message
+			}
+			return apiErr
+		}
+		return nil
+	})
+	if err != nil && ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		return output.String(), nil, err
+	}
+	for i := range calls {
+		if calls[i].Arguments, err = toolArguments("claude", calls[i].Name, inputs[i]); err != nil {
+			return output.String(), nil, err
+		}
+	}
+	return output.String(), calls, nil
+}
+
+func (m *claudeModel) GenerateStream(ctx context.Context, prompt string) (<-chan string, error) {
+	return streamGenerate(ctx, m, prompt)
+}
+
+func (m *claudeModel) GetModelType() ModelType {
+	return m.config.ModelType
+}
@@ -0,0 +1,136 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// claudeStream 返回依次输出 chunks 的 Messages API 流式响应
+func claudeStream(chunks ...string) string {
+	var b strings.Builder
+	b.WriteString("event: message_start\ndata: {\"type\": \"message_start\", \"message\": {\"usage\": {\"input_tokens\": 5, \"output_tokens\": 1}}}\n\n")
+	for _, chunk := range chunks {
+		data, _ := json.Marshal(map[string]interface{}{
+			"type":  "content_block_delta",
+			"delta": map[string]string{"type": "text_delta", "text": chunk},
+		})
+		fmt.Fprintf(&b, "event: content_block_delta\ndata: %s\n\n", data)
+	}
+	fmt.Fprintf(&b, "event: message_delta\ndata: {\"type\": \"message_delta\", \"usage\": {\"output_tokens\": %d}}\n\n", len(chunks))
+	b.WriteString("event: message_stop\ndata: {\"type\": \"message_stop\"}\n\n")
+	return b.String()
+}
+
+func TestClaudeGenerate(t *testing.T) {
+	var got claudeRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/messages" || r.Header.Get("x-api-key") != "sk-test" || r.Header.Get("anthropic-version") != claudeAPIVersion {
+			t.Errorf("unexpected request %s %v", r.URL.Path, r.Header)
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		w.Header().Set("anthropic-ratelimit-requests-remaining", "9")
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte(claudeStream("Hello", ", world")))
+	}))
+	defer srv.Close()
+
+	limiter := NewRateLimiter(time.Second)
+	m, _ := newClaudeModel(ModelConfig{
+		APIKey: "sk-test", ModelType: ModelTypeClaude, Temperature: 0.5, Stop: []string{"END"},
+		RateLimiter: limiter, BaseURL: srv.URL + "/",
+	})
+	var tokens []string
+	ctx := WithTokenHandler(context.Background(), func(token string) { tokens = append(tokens, token) })
+	ctx, recorder := RecordUsage(ctx)
+	output, err := m.Generate(ctx, "hi")
+	if err != nil || output != "Hello, world" || len(tokens) != 2 {
+		t.Fatalf("unexpected output %q %v %v", output, tokens, err)
+	}
+	if usage, ok := recorder.Usage(); !ok || usage != (Usage{PromptTokens: 5, CompletionTokens: 2}) {
+		t.Errorf("unexpected usage %+v %v", usage, ok)
+	}
+	if got.Model != string(ModelTypeClaude) || got.MaxTokens != defaultMaxTokens || *got.Temperature != 0.5 ||
+		!got.Stream || got.StopSequences[0] != "END" || got.Messages[0].Content != "hi" {
+		t.Errorf("unexpected request body %+v", got)
+	}
+	if limiter.Info().RemainingRequests != 9 {
+		t.Error("expected response headers to be observed by the rate limiter")
+	}
+
+	zero := 0.0
+	if _, err := m.Generate(WithParams(context.Background(), Params{Temperature: &zero}), "hi"); err != nil || *got.Temperature != 0 {
+		t.Errorf("expected temperature override, got %v %v", *got.Temperature, err)
+	}
+	high := 1.5
+	if _, err := m.Generate(WithParams(context.Background(), Params{Temperature: &high}), "hi"); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("expected ErrInvalidParams, got %v", err)
+	}
+}
+
+func TestClaudeErrors(t *testing.T) {
+	defer func(d time.Duration) { retryBaseDelay = d }(retryBaseDelay)
+	retryBaseDelay = time.Millisecond
+
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		switch r.Header.Get("x-api-key") {
+		case "bad":
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"type": "error", "error": {"type": "authentication_error", "message": "invalid x-api-key"}}`))
+		case "flaky":
+			if n == 1 {
+				w.WriteHeader(529)
+				w.Write([]byte(`{"type": "error", "error": {"type": "overloaded_error", "message": "Overloaded"}}`))
+				return
+			}
+			w.Write([]byte(claudeStream("ok")))
+		case "limited":
+			w.Header().Set("retry-after", "60")
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"type": "error", "error": {"type": "rate_limit_error", "message": "slow down"}}`))
+		case "down":
+			w.WriteHeader(http.StatusInternalServerError)
+		case "midstream":
+			w.Write([]byte("event: content_block_delta\ndata: {\"type\": \"content_block_delta\", \"delta\": {\"type\": \"text_delta\", \"text\": \"par\"}}\n\n"))
+			w.Write([]byte("event: error\ndata: {\"type\": \"error\", \"error\": {\"type\": \"overloaded_error\", \"message\": \"Overloaded\"}}\n\n"))
+		}
+	}))
+	defer srv.Close()
+
+	generate := func(key string) (string, error) {
+		calls.Store(0)
+		m, _ := newClaudeModel(ModelConfig{APIKey: key, ModelType: ModelTypeClaude, BaseURL: srv.URL})
+		return m.Generate(context.Background(), "hi")
+	}
+
+	_, err := generate("bad")
+	var apiErr *APIError
+	if !errors.Is(err, ErrUnauthorized) || !errors.As(err, &apiErr) || apiErr.Type != "authentication_error" || calls.Load() != 1 {
+		t.Errorf("expected unauthorized error without retries, got %v after %d calls", err, calls.Load())
+	}
+	if output, err := generate("flaky"); err != nil || output != "ok" || calls.Load() != 2 {
+		t.Errorf("expected retry after overload, got %q %v after %d calls", output, err, calls.Load())
+	}
+	var rateErr *RateLimitError
+	if _, err := generate("limited"); !errors.As(err, &rateErr) || rateErr.RetryAfter != time.Minute || calls.Load() != 1 {
+		t.Errorf("expected rate limit error without waiting, got %v after %d calls", err, calls.Load())
+	}
+	if _, err := generate("down"); !errors.As(err, &apiErr) || apiErr.StatusCode != 500 || calls.Load() != maxRetries+1 {
+		t.Errorf("expected server error after retries, got %v after %d calls", err, calls.Load())
+	}
+	if output, err := generate("midstream"); !errors.As(err, &apiErr) || apiErr.Type != "overloaded_error" || output != "par" {
+		t.Errorf("expected stream error with partial output, got %q %v", output, err)
+	}
+	if _, err := generate(""); !errors.Is(err, ErrUnauthorized) || calls.Load() != 0 {
+		t.Errorf("expected missing key to fail without a request, got %v", err)
+	}
+}
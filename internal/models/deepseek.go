@@ -0,0 +1,61 @@
+package models
+
+import (
+	"context"
+	"net/http"
+)
+
+// DeepSeek 的 OpenAI 兼容接口
+const deepSeekBaseURL = "https://api.deepseek.com"
+
+// DeepSeek 的模型，通过 ModelConfig.Model 选择，也可以使用简写 chat 和 coder
+const (
+	DeepSeekChat  = "deepseek-chat"
+	DeepSeekCoder = "deepseek-coder"
+)
+
+// deepSeekVariants 是模型简写对应的模型名
+var deepSeekVariants = map[string]string{
+	"chat":  DeepSeekChat,
+	"coder": DeepSeekCoder,
+}
+
+// deepSeekModel DeepSeek模型实现，通过 chat completions 接口以流式方式生成并报告 token 用量
+type deepSeekModel struct {
+	chat chatClient
+}
+
+func newDeepSeekModel(config ModelConfig) (Model, error) {
+	if name, ok := deepSeekVariants[config.Model]; ok {
+		config.Model = name
+	}
+	return &deepSeekModel{
+		chat: chatClient{
+			provider: "deepseek",
+			baseURL:  deepSeekBaseURL,
+			model:    DeepSeekChat,
+			config:   config,
+			client:   http.DefaultClient,
+		},
+	}, nil
+}
+
+func (m *deepSeekModel) Generate(ctx context.Context, prompt string) (string, error) {
+	return m.chat.generate(ctx, prompt)
+}
+
+func (m *deepSeekModel) GenerateStream(ctx context.Context, prompt string) (<-chan string, error) {
+	return streamGenerate(ctx, m, prompt)
+}
+
+func (m *deepSeekModel) GenerateJSON(ctx context.Context, prompt string, schema []byte) (string, error) {
+	return m.chat.generateJSON(ctx, prompt)
+}
+
+func (m *deepSeekModel) GenerateWithTools(ctx context.Context, messages []Message, tools []ToolSpec) (*ToolResponse, error) {
+	return m.chat.generateWithTools(ctx, messages, tools)
+}
+
+func (m *deepSeekModel) GetModelType() ModelType {
+	return m.chat.config.ModelType
+}
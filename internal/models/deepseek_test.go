@@ -0,0 +1,54 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDeepSeekGenerate(t *testing.T) {
+	var got chatRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = chatRequest{}
+		json.NewDecoder(r.Body).Decode(&got)
+		switch got.Model {
+		case "deepseek-bogus":
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": {"message": "Model Not Exist", "type": "invalid_request_error", "param": null, "code": "invalid_request_error"}}`))
+		case "deepseek-gateway":
+			w.WriteHeader(http.StatusBadGateway)
+			w.Write([]byte("<html>upstream unavailable</html>"))
+		default:
+			w.Write([]byte(chatStream(Usage{PromptTokens: 4, CompletionTokens: 2}, "func", " main()")))
+		}
+	}))
+	defer srv.Close()
+
+	generate := func(model string) (string, error) {
+		m, _ := newDeepSeekModel(ModelConfig{APIKey: "sk-test", ModelType: ModelTypeDeepSeek, Model: model, BaseURL: srv.URL})
+		return m.Generate(context.Background(), "hi")
+	}
+
+	tests := map[string]string{"": DeepSeekChat, "chat": DeepSeekChat, "coder": DeepSeekCoder, "deepseek-reasoner": "deepseek-reasoner"}
+	for model, want := range tests {
+		if output, err := generate(model); err != nil || output != "func main()" || got.Model != want {
+			t.Errorf("model %q: got %q %q %v, want request for %s", model, got.Model, output, err, want)
+		}
+	}
+
+	var apiErr *APIError
+	if _, err := generate("deepseek-bogus"); !errors.As(err, &apiErr) || apiErr.StatusCode != 400 ||
+		apiErr.Type != "invalid_request_error" || apiErr.Message != "Model Not Exist" {
+		t.Errorf("expected API error body in error, got %v", err)
+	}
+
+	defer func(d time.Duration) { retryBaseDelay = d }(retryBaseDelay)
+	retryBaseDelay = 0
+	if _, err := generate("deepseek-gateway"); !errors.As(err, &apiErr) || apiErr.Message != "<html>upstream unavailable</html>" {
+		t.Errorf("expected raw body in error, got %v", err)
+	}
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"context"
+	"net/http"
+)
+
+// 火山方舟（豆包）的 OpenAI 兼容接口
+const (
+	doubaoBaseURL      = "https://ark.cn-beijing.volces.com/api/v3"
+	doubaoDefaultModel = "doubao-1-5-pro-32k-250115"
+)
+
+// doubaoModel 豆包模型实现，通过火山方舟的 chat completions 接口以流式方式生成并报告 token 用量
+type doubaoModel struct {
+	chat chatClient
+}
+
+func newDoubaoModel(config ModelConfig) (Model, error) {
+	return &doubaoModel{
+		chat: chatClient{
+			provider: "doubao",
+			baseURL:  doubaoBaseURL,
+			model:    doubaoDefaultModel,
+			config:   config,
+			client:   http.DefaultClient,
+		},
+	}, nil
+}
+
+func (m *doubaoModel) Generate(ctx context.Context, prompt string) (string, error) {
+	return m.chat.generate(ctx, prompt)
+}
+
+func (m *doubaoModel) GenerateStream(ctx context.Context, prompt string) (<-chan string, error) {
+	return streamGenerate(ctx, m, prompt)
+}
+
+func (m *doubaoModel) GenerateJSON(ctx context.Context, prompt string, schema []byte) (string, error) {
+	return m.chat.generateJSON(ctx, prompt)
+}
+
+func (m *doubaoModel) GenerateWithTools(ctx context.Context, messages []Message, tools []ToolSpec) (*ToolResponse, error) {
+	return m.chat.generateWithTools(ctx, messages, tools)
+}
+
+func (m *doubaoModel) GetModelType() ModelType {
+	return m.chat.config.ModelType
+}
@@ -0,0 +1,76 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// chatStream 返回依次输出 chunks 并在最后报告用量的 chat completions 流式响应
+func chatStream(usage Usage, chunks ...string) string {
+	var b strings.Builder
+	for _, chunk := range chunks {
+		data, _ := json.Marshal(map[string]interface{}{
+			"choices": []interface{}{map[string]interface{}{"delta": map[string]string{"content": chunk}}},
+		})
+		fmt.Fprintf(&b, "data: %s\n\n", data)
+	}
+	data, _ := json.Marshal(map[string]interface{}{"choices": []interface{}{}, "usage": usage})
+	fmt.Fprintf(&b, "data: %s\n\ndata: [DONE]\n\n", data)
+	return b.String()
+}
+
+func TestDoubaoGenerate(t *testing.T) {
+	var got chatRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/chat/completions" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if r.Header.Get("Authorization") != "Bearer ark-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error": {"code": "AuthenticationError", "message": "the API key is invalid", "type": "Unauthorized"}}`))
+			return
+		}
+		got = chatRequest{}
+		json.NewDecoder(r.Body).Decode(&got)
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte(chatStream(Usage{PromptTokens: 12, CompletionTokens: 3}, `{"a"`, `: 1}`)))
+	}))
+	defer srv.Close()
+
+	m, _ := newDoubaoModel(ModelConfig{APIKey: "ark-key", ModelType: ModelTypeDoubao, Temperature: 0.3, BaseURL: srv.URL})
+	var tokens []string
+	ctx := WithTokenHandler(context.Background(), func(token string) { tokens = append(tokens, token) })
+	ctx, recorder := RecordUsage(ctx)
+	output, err := m.Generate(WithParams(ctx, Params{Deterministic: true}), "hi")
+	if err != nil || output != `{"a": 1}` || len(tokens) != 2 {
+		t.Fatalf("unexpected output %q %v %v", output, tokens, err)
+	}
+	if got.Model != doubaoDefaultModel || !got.Stream || got.StreamOptions == nil || !got.StreamOptions.IncludeUsage ||
+		*got.Temperature != 0 || got.Seed == nil || *got.Seed != DefaultSeed || got.ResponseFormat != nil {
+		t.Errorf("unexpected request %+v", got)
+	}
+	if usage, ok := recorder.Usage(); !ok || usage.Total() != 15 {
+		t.Errorf("unexpected usage %+v %v", usage, ok)
+	}
+
+	m, _ = newDoubaoModel(ModelConfig{APIKey: "ark-key", ModelType: ModelTypeDoubao, Model: "ep-20250101-abc", BaseURL: srv.URL})
+	if _, err := GenerateJSON(context.Background(), m, "hi", []byte(`{}`)); err != nil {
+		t.Fatal(err)
+	}
+	if got.Model != "ep-20250101-abc" || got.ResponseFormat == nil || got.ResponseFormat.Type != "json_object" {
+		t.Errorf("expected json mode with the configured endpoint, got %+v", got)
+	}
+
+	m, _ = newDoubaoModel(ModelConfig{APIKey: "wrong", ModelType: ModelTypeDoubao, BaseURL: srv.URL})
+	var apiErr *APIError
+	if _, err := m.Generate(context.Background(), "hi"); !errors.Is(err, ErrUnauthorized) || !errors.As(err, &apiErr) ||
+		apiErr.Message != "the API key is invalid" {
+		t.Errorf("expected unauthorized error, got %v", err)
+	}
+}
@@ -0,0 +1,169 @@
+package models
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// 请求提供商 API 失败后的重试策略
+const (
+	maxRetries    = 2                // 429、5xx 和网络错误最多重试的次数
+	maxRetryDelay = 10 * time.Second // Retry-After 超过该时间时不再重试，直接返回错误
+)
+
+// retryBaseDelay 是没有 Retry-After 时第一次重试前的等待时间，之后每次翻倍
+var retryBaseDelay = 500 * time.Millisecond
+
+// APIError 是提供商返回的错误响应。鉴权失败时与 ErrUnauthorized 一起包装，被限流时转换为 *RateLimitError
+type APIError struct {
+	Provider   string `json:"provider"`
+	StatusCode int    `json:"status_code"` // 流式输出中途返回的错误为 0
+	Type       string `json:"type,omitempty"`
+	Message    string `json:"message"`
+}
+
+func (e *APIError) Error() string {
+	var b strings.Builder
+	b.WriteString(e.Provider + " API error")
+	if e.StatusCode != 0 {
+		fmt.Fprintf(&b, " (status %d)", e.StatusCode)
+	}
+	if e.Type != "" {
+		b.WriteString(": " + e.Type)
+	}
+	if e.Message != "" {
+		b.WriteString(": " + e.Message)
+	}
+	return b.String()
+}
+
+// Temporary 判断请求是否可以重试：限流、服务端错误以及 Anthropic 过载时返回的 529
+func (e *APIError) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// apiErrorBody 是 Anthropic 和 OpenAI 兼容接口共用的错误响应格式 {"error": {"type": ..., "message": ...}}
+type apiErrorBody struct {
+	Error *struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// maxErrorBody 是错误信息中保留的响应体长度
+const maxErrorBody = 2048
+
+// readAPIError 读取并关闭错误响应，响应体不是约定的格式或没有错误信息时把原文作为错误信息
+func readAPIError(provider string, resp *http.Response) *APIError {
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	apiErr := &APIError{Provider: provider, StatusCode: resp.StatusCode}
+	var body apiErrorBody
+	if json.Unmarshal(data, &body) == nil && body.Error != nil {
+		apiErr.Type, apiErr.Message = body.Error.Type, body.Error.Message
+	}
+	if apiErr.Message == "" {
+		apiErr.Message = strings.TrimSpace(string(data))
+		if len(apiErr.Message) > maxErrorBody {
+			apiErr.Message = strings.ToValidUTF8(apiErr.Message[:maxErrorBody], "") + "..."
+		}
+	}
+	return apiErr
+}
+
+// classifyAPIError 把错误响应转换为调用方可以区分的错误：401 和 403 包装 ErrUnauthorized，
+// 429 返回 *RateLimitError，其余原样返回 *APIError
+func classifyAPIError(apiErr *APIError, retryAfter time.Duration) error {
+	switch apiErr.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return fmt.Errorf("%w: %w", ErrUnauthorized, apiErr)
+	case http.StatusTooManyRequests:
+		return &RateLimitError{RetryAfter: retryAfter, Message: apiErr.Message}
+	}
+	return apiErr
+}
+
+// postWithRetry 发送 POST 请求并返回状态码为 200 的响应，429、5xx 和网络错误按 Retry-After 或指数退避重试。
+// 每次收到响应后把限流信息交给 limiter（可以为 nil），最终失败时返回 classifyAPIError 转换后的错误
+func postWithRetry(ctx context.Context, client *http.Client, provider, url string, header http.Header, body []byte,
+	limiter *RateLimiter) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header = header.Clone()
+
+		wait := retryBaseDelay << attempt
+		resp, err := client.Do(req)
+		if err != nil {
+			if ctx.Err() != nil || attempt == maxRetries {
+				return nil, fmt.Errorf("%s API request failed: %w", provider, err)
+			}
+		} else {
+			if limiter != nil {
+				limiter.Observe(resp.Header)
+			}
+			if resp.StatusCode == http.StatusOK {
+				return resp, nil
+			}
+			apiErr := readAPIError(provider, resp)
+			if d, ok := parseRetryAfter(resp.Header.Get("retry-after"), time.Now()); ok {
+				wait = max(d, 0)
+			}
+			if !apiErr.Temporary() || attempt == maxRetries || wait > maxRetryDelay {
+				return nil, classifyAPIError(apiErr, wait)
+			}
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// readSSE 逐个读取 server-sent events 的 data 字段交给 fn，忽略事件名、注释和 OpenAI 的 [DONE] 结束标记
+func readSSE(r io.Reader, fn func(data []byte) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64<<10), 4<<20)
+	var data []byte
+	flush := func() error {
+		if len(data) == 0 || string(data) == "[DONE]" {
+			data = data[:0]
+			return nil
+		}
+		err := fn(data)
+		data = data[:0]
+		return err
+	}
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			if err := flush(); err != nil {
+				return err
+			}
+			continue
+		}
+		if v, ok := strings.CutPrefix(line, "data:"); ok {
+			if len(data) > 0 {
+				data = append(data, '\n')
+			}
+			data = append(data, strings.TrimPrefix(v, " ")...)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return flush()
+}
@@ -0,0 +1,57 @@
+package models
+
+// Info 描述模型类型的提供商和支持的能力，插件据此填充模型选择器，
+// 并对不支持的操作（如图片输入、工具调用）隐藏入口
+type Info struct {
+	Type       ModelType `json:"type"`
+	Provider   string    `json:"provider"`
+	Local      bool      `json:"local"`       // 本地模型，离线模式下仍然可用
+	Streaming  bool      `json:"streaming"`   // 支持流式输出
+	Tools      bool      `json:"tools"`       // 支持原生工具调用
+	Vision     bool      `json:"vision"`      // 支持图片输入
+	JSONMode   bool      `json:"json_mode"`   // 支持原生 JSON 输出模式
+	Seed       bool      `json:"seed"`        // 支持指定采样种子
+	MaxContext int       `json:"max_context"` // 上下文窗口的 token 数
+}
+
+// modelInfo 是各模型类型的能力，JSONMode 与 Seed 需要和模型实现保持一致
+var modelInfo = map[ModelType]Info{
+	ModelTypeClaude: {
+		Provider:   "anthropic",
+		Streaming:  true,
+		Tools:      true,
+		Vision:     true,
+		MaxContext: 200000,
+	},
+	ModelTypeDoubao: {
+		Provider:   "volcengine",
+		Streaming:  true,
+		Tools:      true,
+		JSONMode:   true,
+		MaxContext: 32768,
+	},
+	ModelTypeDeepSeek: {
+		Provider:   "deepseek",
+		Streaming:  true,
+		Tools:      true,
+		JSONMode:   true,
+		MaxContext: 65536,
+	},
+	ModelTypeOpenAI: {
+		Provider:   "openai",
+		Streaming:  true,
+		Tools:      true,
+		Vision:     true,
+		JSONMode:   true,
+		MaxContext: 128000,
+	},
+}
+
+// Info 返回模型类型的能力，未知的类型只填写 Type
+func (t ModelType) Info() Info {
+	info := modelInfo[t]
+	info.Type = t
+	info.Local = t.IsLocal()
+	info.Seed = t.SupportsSeed()
+	return info
+}
@@ -0,0 +1,26 @@
+package models
+
+import "testing"
+
+func TestInfoMatchesImplementation(t *testing.T) {
+	for _, modelType := range SupportedModelTypes() {
+		info := modelType.Info()
+		if info.Type != modelType || info.Provider == "" || info.MaxContext <= 0 {
+			t.Errorf("incomplete info for %s: %+v", modelType, info)
+		}
+		model, err := newProviderModel(ModelConfig{ModelType: modelType})
+		if err != nil {
+			t.Fatalf("newProviderModel(%s): %v", modelType, err)
+		}
+		if _, ok := model.(JSONModel); ok != info.JSONMode {
+			t.Errorf("%s: json_mode is %v but implementation supports it: %v", modelType, info.JSONMode, ok)
+		}
+		if info.Seed != modelType.SupportsSeed() {
+			t.Errorf("%s: seed mismatch", modelType)
+		}
+	}
+
+	if info := ModelType("unknown").Info(); info.Type != "unknown" || info.Streaming {
+		t.Errorf("unexpected info for unknown model %+v", info)
+	}
+}
@@ -0,0 +1,238 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// KeyRotation 表示多个 API Key 的轮换策略
+type KeyRotation string
+
+const (
+	KeyRotationRoundRobin KeyRotation = "round_robin" // 依次使用每个可用的 Key
+	KeyRotationFailover   KeyRotation = "failover"    // 优先使用第一个可用的 Key，失效后切换到下一个
+)
+
+// KeyUsage 是单个 API Key 的使用统计，Key 只保留首尾几个字符
+type KeyUsage struct {
+	Key           string    `json:"key"`
+	Requests      int64     `json:"requests"`
+	Failures      int64     `json:"failures"`
+	Disabled      bool      `json:"disabled"`
+	CoolDownUntil time.Time `json:"cool_down_until,omitempty"`
+	LastUsed      time.Time `json:"last_used,omitempty"`
+	LastError     string    `json:"last_error,omitempty"`
+}
+
+// keyState 是 API Key 及其使用状态
+type keyState struct {
+	key   string
+	usage KeyUsage
+}
+
+// KeyPool 管理同一提供商的多个 API Key，被吊销的 Key 会停用，被限流的 Key 会暂时冷却
+type KeyPool struct {
+	mu       sync.Mutex
+	keys     []*keyState
+	rotation KeyRotation
+	next     int
+}
+
+// NewKeyPool 创建 API Key 池，忽略空 Key 和重复的 Key
+func NewKeyPool(keys []string, rotation KeyRotation) *KeyPool {
+	if rotation == "" {
+		rotation = KeyRotationRoundRobin
+	}
+	p := &KeyPool{rotation: rotation}
+	seen := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		p.keys = append(p.keys, &keyState{key: key, usage: KeyUsage{Key: maskKey(key)}})
+	}
+	return p
+}
+
+// Len 返回 Key 的数量
+func (p *KeyPool) Len() int {
+	return len(p.keys)
+}
+
+// Keys 返回所有 Key
+func (p *KeyPool) Keys() []string {
+	keys := make([]string, len(p.keys))
+	for i, k := range p.keys {
+		keys[i] = k.key
+	}
+	return keys
+}
+
+// Next 按轮换策略选择下一个可用的 Key
+// 所有 Key 都在冷却时返回 *RateLimitError，全部停用时返回错误
+func (p *KeyPool) Next() (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.keys) == 0 {
+		return "", errors.New("no API key configured")
+	}
+
+	now := time.Now()
+	start := 0
+	if p.rotation == KeyRotationRoundRobin {
+		start = p.next
+	}
+	var earliest time.Time
+	for i := 0; i < len(p.keys); i++ {
+		idx := (start + i) % len(p.keys)
+		k := p.keys[idx]
+		if k.usage.Disabled {
+			continue
+		}
+		if k.usage.CoolDownUntil.After(now) {
+			if earliest.IsZero() || k.usage.CoolDownUntil.Before(earliest) {
+				earliest = k.usage.CoolDownUntil
+			}
+			continue
+		}
+		p.next = (idx + 1) % len(p.keys)
+		return k.key, nil
+	}
+
+	if earliest.IsZero() {
+		return "", errors.New("all API keys are disabled")
+	}
+	return "", &RateLimitError{RetryAfter: time.Until(earliest).Round(time.Second), Message: "all API keys are rate limited"}
+}
+
+// Report 记录一次请求的结果：Key 被拒绝时停用，被限流时冷却到可重试为止
+func (p *KeyPool) Report(key string, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, k := range p.keys {
+		if k.key != key {
+			continue
+		}
+		k.usage.Requests++
+		k.usage.LastUsed = time.Now()
+		if err == nil {
+			return
+		}
+
+		k.usage.Failures++
+		k.usage.LastError = err.Error()
+		var rateErr *RateLimitError
+		switch {
+		case errors.Is(err, ErrUnauthorized):
+			k.usage.Disabled = true
+		case errors.As(err, &rateErr):
+			k.usage.CoolDownUntil = time.Now().Add(rateErr.RetryAfter)
+		}
+		return
+	}
+}
+
+// Usage 返回每个 Key 的使用统计
+func (p *KeyPool) Usage() []KeyUsage {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	usage := make([]KeyUsage, len(p.keys))
+	for i, k := range p.keys {
+		usage[i] = k.usage
+	}
+	return usage
+}
+
+// maskKey 隐藏 API Key 的中间部分
+func maskKey(key string) string {
+	if len(key) <= 12 {
+		return "****"
+	}
+	return key[:4] + "..." + key[len(key)-4:]
+}
+
+// pooledModel 为每个 Key 创建一个模型实例，按轮换策略分发请求
+// Key 被拒绝或被限流时自动切换到下一个 Key
+type pooledModel struct {
+	pool   *KeyPool
+	models map[string]Model
+	typ    ModelType
+}
+
+// newPooledModel 为 Key 池中的每个 Key 创建模型实例
+func newPooledModel(config ModelConfig, create func(ModelConfig) (Model, error)) (Model, error) {
+	m := &pooledModel{
+		pool:   config.KeyPool,
+		models: make(map[string]Model, config.KeyPool.Len()),
+		typ:    config.ModelType,
+	}
+	for _, key := range config.KeyPool.Keys() {
+		keyConfig := config
+		keyConfig.APIKey = key
+		keyConfig.KeyPool = nil
+		keyConfig.RateLimiter = nil
+		model, err := create(keyConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create model for key %s: %v", maskKey(key), err)
+		}
+		m.models[key] = model
+	}
+	return m, nil
+}
+
+func (m *pooledModel) Generate(ctx context.Context, prompt string) (string, error) {
+	return m.call(func(model Model) (string, error) { return model.Generate(ctx, prompt) })
+}
+
+func (m *pooledModel) GenerateJSON(ctx context.Context, prompt string, schema []byte) (string, error) {
+	return m.call(func(model Model) (string, error) { return GenerateJSON(ctx, model, prompt, schema) })
+}
+
+func (m *pooledModel) GenerateWithTools(ctx context.Context, messages []Message, tools []ToolSpec) (*ToolResponse, error) {
+	var resp *ToolResponse
+	_, err := m.call(func(model Model) (string, error) {
+		var err error
+		resp, err = GenerateWithTools(ctx, model, messages, tools)
+		return toolText(resp), err
+	})
+	return resp, err
+}
+
+// GenerateStream 的输出来自实际使用的 Key，换 Key 重试时之前的部分输出不会撤回
+func (m *pooledModel) GenerateStream(ctx context.Context, prompt string) (<-chan string, error) {
+	return streamGenerate(ctx, m, prompt)
+}
+
+// call 按轮换策略选择 Key 调用模型，Key 无效或被限流时换下一个 Key 重试
+func (m *pooledModel) call(generate func(model Model) (string, error)) (string, error) {
+	var lastErr error
+	for i := 0; i < m.pool.Len(); i++ {
+		key, err := m.pool.Next()
+		if err != nil {
+			if lastErr != nil {
+				return "", lastErr
+			}
+			return "", err
+		}
+
+		output, err := generate(m.models[key])
+		m.pool.Report(key, err)
+		var rateErr *RateLimitError
+		if err == nil || !(errors.Is(err, ErrUnauthorized) || errors.As(err, &rateErr)) {
+			return output, err
+		}
+		lastErr = err
+	}
+	return "", lastErr
+}
+
+func (m *pooledModel) GetModelType() ModelType {
+	return m.typ
+}
@@ -0,0 +1,110 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestKeyPoolRotation(t *testing.T) {
+	p := NewKeyPool([]string{"key-a", "key-b", "", "key-a"}, KeyRotationRoundRobin)
+	if p.Len() != 2 {
+		t.Fatalf("expected duplicate and empty keys to be ignored, got %d keys", p.Len())
+	}
+	first, _ := p.Next()
+	second, _ := p.Next()
+	if first == second {
+		t.Errorf("expected round robin to alternate keys, got %s twice", first)
+	}
+
+	p = NewKeyPool([]string{"key-a", "key-b"}, KeyRotationFailover)
+	for i := 0; i < 3; i++ {
+		if key, _ := p.Next(); key != "key-a" {
+			t.Fatalf("expected failover to keep using the first key, got %s", key)
+		}
+	}
+	p.Report("key-a", fmt.Errorf("provider said: %w", ErrUnauthorized))
+	if key, _ := p.Next(); key != "key-b" {
+		t.Errorf("expected revoked key to be skipped, got %s", key)
+	}
+
+	p.Report("key-b", &RateLimitError{RetryAfter: time.Minute})
+	var rateErr *RateLimitError
+	if _, err := p.Next(); !errors.As(err, &rateErr) {
+		t.Errorf("expected rate limit error when all keys are unavailable, got %v", err)
+	}
+
+	usage := p.Usage()
+	if !usage[0].Disabled || usage[1].CoolDownUntil.IsZero() || usage[0].Key != "****" {
+		t.Errorf("unexpected usage: %+v", usage)
+	}
+}
+
+// fakeModel 用于测试的模型，对指定的 Key 返回错误
+type fakeModel struct {
+	key string
+	err error
+}
+
+func (m *fakeModel) Generate(ctx context.Context, prompt string) (string, error) {
+	return m.key, m.err
+}
+
+func (m *fakeModel) GenerateStream(ctx context.Context, prompt string) (<-chan string, error) {
+	return streamGenerate(ctx, m, prompt)
+}
+
+func (m *fakeModel) GetModelType() ModelType {
+	return ModelTypeClaude
+}
+
+func TestPooledModelFailover(t *testing.T) {
+	pool := NewKeyPool([]string{"revoked", "good"}, KeyRotationFailover)
+	model, err := newPooledModel(ModelConfig{ModelType: ModelTypeClaude, KeyPool: pool}, func(c ModelConfig) (Model, error) {
+		if c.APIKey == "revoked" {
+			return &fakeModel{key: c.APIKey, err: ErrUnauthorized}, nil
+		}
+		return &fakeModel{key: c.APIKey}, nil
+	})
+	if err != nil {
+		t.Fatalf("failed to create pooled model: %v", err)
+	}
+
+	output, err := model.Generate(context.Background(), "hi")
+	if err != nil || output != "good" {
+		t.Fatalf("expected failover to the good key, got %q %v", output, err)
+	}
+	usage := pool.Usage()
+	if !usage[0].Disabled || usage[1].Requests != 1 {
+		t.Errorf("unexpected usage: %+v", usage)
+	}
+}
+
+// jsonModel 支持原生 JSON 模式的测试模型
+type jsonModel struct{ fakeModel }
+
+func (m *jsonModel) GenerateJSON(ctx context.Context, prompt string, schema []byte) (string, error) {
+	return `{"key": "` + m.key + `"}`, nil
+}
+
+func TestPooledModelGenerateJSON(t *testing.T) {
+	pool := NewKeyPool([]string{"a", "b"}, KeyRotationRoundRobin)
+	model, err := newPooledModel(ModelConfig{ModelType: ModelTypeClaude, KeyPool: pool}, func(c ModelConfig) (Model, error) {
+		if c.APIKey == "a" {
+			return &jsonModel{fakeModel{key: c.APIKey}}, nil
+		}
+		return &fakeModel{key: c.APIKey}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 支持原生 JSON 模式的模型使用原生模式，其余退回普通生成
+	first, _ := GenerateJSON(context.Background(), model, "hi", []byte(`{"type": "object"}`))
+	second, _ := GenerateJSON(context.Background(), model, "hi", []byte(`{"type": "object"}`))
+	if first != `{"key": "a"}` || second != "b" {
+		t.Errorf("unexpected outputs %q and %q", first, second)
+	}
+}
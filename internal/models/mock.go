@@ -0,0 +1,145 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// ErrNoMockResponse 表示 MockModel 没有可返回的脚本响应
+var ErrNoMockResponse = errors.New("mock model has no scripted responses")
+
+// MockResponse 是 MockModel 的一条脚本响应
+type MockResponse struct {
+	Output string `json:"output"`
+	// Error 不为空时先流式输出 Output 再返回该错误，用于模拟中途断开的生成
+	Error string `json:"error,omitempty"`
+	// Err 与 Error 相同，但可以指定错误类型，优先于 Error
+	Err error `json:"-"`
+	// RetryAfterMS 大于 0 时不输出任何内容，直接返回 *RateLimitError
+	RetryAfterMS int `json:"retry_after_ms,omitempty"`
+	// ChunkSize 是每段流式输出的字符数，为 0 时整段输出
+	ChunkSize int `json:"chunk_size,omitempty"`
+	// DelayMS 是每段流式输出前的等待时间，ctx 取消时提前返回
+	DelayMS int `json:"delay_ms,omitempty"`
+}
+
+// MockModel 按脚本依次返回响应的模型，用于测试和插件开发，不需要 API Key。
+// 脚本用完后重复最后一条响应
+type MockModel struct {
+	modelType ModelType
+
+	mu        sync.Mutex
+	responses []MockResponse
+	prompts   []string
+}
+
+// NewMockModel 创建按 responses 依次响应的模型，modelType 为空时使用 ModelTypeClaude
+func NewMockModel(modelType ModelType, responses ...MockResponse) *MockModel {
+	if modelType == "" {
+		modelType = ModelTypeClaude
+	}
+	return &MockModel{modelType: modelType, responses: responses}
+}
+
+// LoadMockScript 从 JSON 文件读取 MockResponse 数组
+func LoadMockScript(path string) ([]MockResponse, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var responses []MockResponse
+	if err := json.Unmarshal(data, &responses); err != nil {
+		return nil, fmt.Errorf("failed to parse mock script: %v", err)
+	}
+	return responses, nil
+}
+
+// Push 在脚本末尾追加响应
+func (m *MockModel) Push(responses ...MockResponse) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.responses = append(m.responses, responses...)
+}
+
+// Prompts 返回模型收到的所有提示词
+func (m *MockModel) Prompts() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.prompts...)
+}
+
+// next 记录提示词并取出下一条响应
+func (m *MockModel) next(prompt string) (MockResponse, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.prompts = append(m.prompts, prompt)
+	if len(m.responses) == 0 {
+		return MockResponse{}, false
+	}
+	resp := m.responses[0]
+	if len(m.responses) > 1 {
+		m.responses = m.responses[1:]
+	}
+	return resp, true
+}
+
+func (m *MockModel) Generate(ctx context.Context, prompt string) (string, error) {
+	resp, ok := m.next(prompt)
+	if !ok {
+		return "", ErrNoMockResponse
+	}
+	if resp.RetryAfterMS > 0 {
+		return "", &RateLimitError{RetryAfter: time.Duration(resp.RetryAfterMS) * time.Millisecond, Message: "mock"}
+	}
+
+	chunks := []string{resp.Output}
+	if resp.ChunkSize > 0 {
+		chunks = splitRunes(resp.Output, resp.ChunkSize)
+	}
+	var output string
+	for _, chunk := range chunks {
+		if resp.DelayMS > 0 {
+			select {
+			case <-ctx.Done():
+				return output, ctx.Err()
+			case <-time.After(time.Duration(resp.DelayMS) * time.Millisecond):
+			}
+		}
+		if chunk != "" {
+			EmitToken(ctx, chunk)
+		}
+		output += chunk
+	}
+
+	switch {
+	case resp.Err != nil:
+		return output, resp.Err
+	case resp.Error != "":
+		return output, errors.New(resp.Error)
+	}
+	return output, nil
+}
+
+func (m *MockModel) GenerateStream(ctx context.Context, prompt string) (<-chan string, error) {
+	return streamGenerate(ctx, m, prompt)
+}
+
+func (m *MockModel) GetModelType() ModelType {
+	return m.modelType
+}
+
+// splitRunes 将 s 按每段 n 个字符切分
+func splitRunes(s string, n int) []string {
+	runes := []rune(s)
+	var chunks []string
+	for len(runes) > n {
+		chunks = append(chunks, string(runes[:n]))
+		runes = runes[n:]
+	}
+	return append(chunks, string(runes))
+}
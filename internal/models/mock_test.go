@@ -0,0 +1,79 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMockModelScript(t *testing.T) {
+	ctx := context.Background()
+	model := NewMockModel("", MockResponse{Output: "你好世界", ChunkSize: 2}, MockResponse{Output: "done"})
+	if model.GetModelType() != ModelTypeClaude {
+		t.Errorf("expected default model type, got %s", model.GetModelType())
+	}
+
+	var tokens []string
+	output, err := model.Generate(WithTokenHandler(ctx, func(token string) { tokens = append(tokens, token) }), "first")
+	if err != nil || output != "你好世界" {
+		t.Fatalf("unexpected first response %q %v", output, err)
+	}
+	if strings.Join(tokens, "|") != "你好|世界" {
+		t.Errorf("unexpected stream chunks %v", tokens)
+	}
+
+	// 脚本用完后重复最后一条
+	for i := 0; i < 2; i++ {
+		if output, _ := model.Generate(ctx, "again"); output != "done" {
+			t.Errorf("expected last response to repeat, got %q", output)
+		}
+	}
+	if prompts := model.Prompts(); len(prompts) != 3 || prompts[0] != "first" {
+		t.Errorf("unexpected recorded prompts %v", prompts)
+	}
+}
+
+func TestMockModelFailures(t *testing.T) {
+	ctx := context.Background()
+	if _, err := NewMockModel("").Generate(ctx, "x"); !errors.Is(err, ErrNoMockResponse) {
+		t.Errorf("expected no response error, got %v", err)
+	}
+
+	sentinel := errors.New("boom")
+	model := NewMockModel(ModelTypeDeepSeek,
+		MockResponse{RetryAfterMS: 1500},
+		MockResponse{Output: "part", Err: sentinel},
+		MockResponse{Output: "slow", DelayMS: 1000},
+	)
+	var rateErr *RateLimitError
+	if _, err := model.Generate(ctx, "x"); !errors.As(err, &rateErr) || rateErr.RetryAfter != 1500*time.Millisecond {
+		t.Errorf("expected rate limit error, got %v", err)
+	}
+	if output, err := model.Generate(ctx, "x"); !errors.Is(err, sentinel) || output != "part" {
+		t.Errorf("expected partial output with error, got %q %v", output, err)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := model.Generate(cancelled, "x"); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected cancellation during delay, got %v", err)
+	}
+}
+
+func TestLoadMockScript(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "script.json")
+	if err := os.WriteFile(path, []byte(`[{"output":"a","chunk_size":1},{"output":"b","error":"cut"}]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	responses, err := LoadMockScript(path)
+	if err != nil {
+		t.Fatalf("failed to load script: %v", err)
+	}
+	if len(responses) != 2 || responses[0].ChunkSize != 1 || responses[1].Error != "cut" {
+		t.Errorf("unexpected script %+v", responses)
+	}
+}
@@ -3,6 +3,8 @@ package models
 import (
 	"context"
 	"fmt"
+
+	"github.com/liangsj/vimcoplit/internal/chaos"
 )
 
 // ModelType 定义支持的模型类型
@@ -12,27 +14,102 @@ const (
 	ModelTypeClaude   ModelType = "claude-3-sonnet-20240229"
 	ModelTypeDoubao   ModelType = "doubao"
 	ModelTypeDeepSeek ModelType = "deepseek"
+	ModelTypeOpenAI   ModelType = "openai" // OpenAI 及 chat completions 协议的兼容服务，通过 BaseURL 指定
 )
 
+// SupportedModelTypes 返回当前构建支持的所有模型类型
+func SupportedModelTypes() []ModelType {
+	return []ModelType{ModelTypeClaude, ModelTypeDoubao, ModelTypeDeepSeek, ModelTypeOpenAI}
+}
+
+// IsLocal 判断模型是否在本地运行，本地模型在离线模式下仍然可用
+// 当前支持的模型都是云端模型
+func (t ModelType) IsLocal() bool {
+	return false
+}
+
 // Model 定义了AI模型的接口
 type Model interface {
-	// Generate 生成响应
+	// Generate 生成响应，支持流式输出的模型每收到一段输出调用一次 EmitToken
 	Generate(ctx context.Context, prompt string) (string, error)
 
+	// GenerateStream 在后台生成响应，返回的通道逐段输出生成的内容，生成结束或失败时关闭
+	GenerateStream(ctx context.Context, prompt string) (<-chan string, error)
+
 	// GetModelType 返回模型类型
 	GetModelType() ModelType
 }
 
+// JSONModel 是支持提供商原生 JSON 输出模式的模型，schema 为输出需要符合的 JSON Schema。
+// 原生模式只保证输出是 JSON，调用方仍需校验是否符合 schema
+type JSONModel interface {
+	GenerateJSON(ctx context.Context, prompt string, schema []byte) (string, error)
+}
+
+// GenerateJSON 使用模型的原生 JSON 模式生成响应，模型不支持时退回普通生成，
+// 此时由提示词要求模型按 schema 输出
+func GenerateJSON(ctx context.Context, m Model, prompt string, schema []byte) (string, error) {
+	if jm, ok := m.(JSONModel); ok {
+		return jm.GenerateJSON(ctx, prompt, schema)
+	}
+	return m.Generate(ctx, prompt)
+}
+
+// Defaults 是模型的默认生成参数，单次请求可以通过 Params 覆盖温度
+type Defaults struct {
+	Model       string   `json:"model,omitempty"` // 提供商的模型名，为空时使用默认模型
+	MaxTokens   int      `json:"max_tokens"`
+	Temperature float64  `json:"temperature"`
+	TopP        float64  `json:"top_p,omitempty"` // 0 表示使用提供商的默认值
+	Stop        []string `json:"stop,omitempty"`  // 停止序列
+}
+
 // ModelConfig 定义了模型配置
 type ModelConfig struct {
 	APIKey      string
 	ModelType   ModelType
 	MaxTokens   int
 	Temperature float64
+	TopP        float64
+	Stop        []string
+
+	// RateLimiter 不为 nil 时模型会按限流信息调整请求节奏，
+	// 模型实现应在收到提供商响应后调用 RateLimiter.Observe，被限流时返回 *RateLimitError
+	RateLimiter *RateLimiter
+
+	// KeyPool 包含多个 Key 时按轮换策略分发请求，此时忽略 APIKey
+	KeyPool *KeyPool
+
+	// BaseURL 是提供商 API 的根地址，用于代理或兼容网关，为空时使用官方地址
+	BaseURL string
+
+	// Model 是请求中使用的提供商模型名或推理接入点 ID（如豆包的 ep-xxx），为空时使用该提供商的默认模型
+	Model string
 }
 
 // NewModel 创建新的模型实例
 func NewModel(config ModelConfig) (Model, error) {
+	var (
+		model Model
+		err   error
+	)
+	factory := withChaos(chaos.Default(), newProviderModel)
+	if config.KeyPool != nil && config.KeyPool.Len() > 1 {
+		model, err = newPooledModel(config, factory)
+	} else {
+		if config.KeyPool != nil && config.KeyPool.Len() == 1 {
+			config.APIKey = config.KeyPool.Keys()[0]
+		}
+		model, err = factory(config)
+	}
+	if err != nil || config.RateLimiter == nil {
+		return model, err
+	}
+	return &rateLimitedModel{Model: model, limiter: config.RateLimiter}, nil
+}
+
+// newProviderModel 根据模型类型创建对应提供商的模型实例
+func newProviderModel(config ModelConfig) (Model, error) {
 	switch config.ModelType {
 	case ModelTypeClaude:
 		return newClaudeModel(config)
@@ -40,67 +117,9 @@ func NewModel(config ModelConfig) (Model, error) {
 		return newDoubaoModel(config)
 	case ModelTypeDeepSeek:
 		return newDeepSeekModel(config)
+	case ModelTypeOpenAI:
+		return newOpenAIModel(config)
 	default:
 		return nil, fmt.Errorf("unsupported model type: %s", config.ModelType)
 	}
 }
-
-// claudeModel Claude模型实现
-type claudeModel struct {
-	config ModelConfig
-}
-
-func newClaudeModel(config ModelConfig) (Model, error) {
-	return &claudeModel{
-		config: config,
-	}, nil
-}
-
-func (m *claudeModel) Generate(ctx context.Context, prompt string) (string, error) {
-	// TODO: 实现Claude API调用
-	return "", nil
-}
-
-func (m *claudeModel) GetModelType() ModelType {
-	return m.config.ModelType
-}
-
-// doubaoModel 豆包模型实现
-type doubaoModel struct {
-	config ModelConfig
-}
-
-func newDoubaoModel(config ModelConfig) (Model, error) {
-	return &doubaoModel{
-		config: config,
-	}, nil
-}
-
-func (m *doubaoModel) Generate(ctx context.Context, prompt string) (string, error) {
-	// TODO: 实现豆包API调用
-	return "", nil
-}
-
-func (m *doubaoModel) GetModelType() ModelType {
-	return m.config.ModelType
-}
-
-// deepSeekModel DeepSeek模型实现
-type deepSeekModel struct {
-	config ModelConfig
-}
-
-func newDeepSeekModel(config ModelConfig) (Model, error) {
-	return &deepSeekModel{
-		config: config,
-	}, nil
-}
-
-func (m *deepSeekModel) Generate(ctx context.Context, prompt string) (string, error) {
-	// TODO: 实现DeepSeek API调用
-	return "", nil
-}
-
-func (m *deepSeekModel) GetModelType() ModelType {
-	return m.config.ModelType
-}
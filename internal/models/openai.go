@@ -0,0 +1,169 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// OpenAI 的 chat completions 接口，ModelConfig.BaseURL 指向其他地址时也用于 Azure OpenAI
+// 以及 vLLM、LM Studio、Ollama 等兼容服务
+const (
+	openAIBaseURL      = "https://api.openai.com/v1"
+	openAIHost         = "api.openai.com"
+	openAIDefaultModel = "gpt-4o-mini"
+	azureAPIVersion    = "2024-10-21"
+)
+
+// openAIModel OpenAI兼容模型实现，通过 chat completions 接口以流式方式生成并报告 token 用量
+type openAIModel struct {
+	chat chatClient
+
+	// discover 为 true 时没有配置模型名，使用兼容服务 /models 接口返回的第一个模型
+	discover   bool
+	mu         sync.Mutex
+	discovered string
+}
+
+func newOpenAIModel(config ModelConfig) (Model, error) {
+	m := &openAIModel{
+		chat: chatClient{
+			provider: "openai",
+			baseURL:  openAIBaseURL,
+			model:    openAIDefaultModel,
+			config:   config,
+			client:   http.DefaultClient,
+		},
+	}
+	if config.BaseURL == "" {
+		return m, nil
+	}
+	u, err := url.Parse(config.BaseURL)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid OpenAI base URL %q", config.BaseURL)
+	}
+	switch host := u.Hostname(); {
+	case isAzureHost(host):
+		if config.Model == "" && !strings.Contains(u.Path, "/deployments/") {
+			return nil, fmt.Errorf("azure OpenAI requires model to be set to the deployment name")
+		}
+		m.chat.azure = true
+	case host != openAIHost:
+		// 本地推理服务通常不需要 API Key，需要时服务端返回 401
+		m.chat.keyOptional = true
+		m.discover = config.Model == ""
+	}
+	return m, nil
+}
+
+// isAzureHost 判断是否为 Azure OpenAI 的资源地址
+func isAzureHost(host string) bool {
+	return strings.HasSuffix(host, ".openai.azure.com") || strings.HasSuffix(host, ".cognitiveservices.azure.com")
+}
+
+// azureEndpoint 返回 Azure OpenAI 部署的 chat completions 地址。baseURL 可以是资源地址
+// （如 https://res.openai.azure.com），也可以已经包含部署路径和 api-version 参数
+func azureEndpoint(baseURL, deployment string) string {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return baseURL
+	}
+	path := strings.TrimSuffix(u.Path, "/")
+	if !strings.Contains(path, "/deployments/") {
+		path = strings.TrimSuffix(path, "/openai") + "/openai/deployments/" + url.PathEscape(deployment)
+	}
+	u.Path, u.RawPath = path+"/chat/completions", ""
+	q := u.Query()
+	if q.Get("api-version") == "" {
+		q.Set("api-version", azureAPIVersion)
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// client 返回本次请求使用的客户端，需要时先从兼容服务取得模型名，取得后不再重复查询
+func (m *openAIModel) client(ctx context.Context) (*chatClient, error) {
+	chat := m.chat
+	if !m.discover {
+		return &chat, nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.discovered == "" {
+		name, err := m.firstModel(ctx)
+		if err != nil {
+			return nil, err
+		}
+		m.discovered = name
+	}
+	chat.model = m.discovered
+	return &chat, nil
+}
+
+// firstModel 返回兼容服务 /models 接口列出的第一个模型，vLLM 和 LM Studio 只列出已加载的模型
+func (m *openAIModel) firstModel(ctx context.Context) (string, error) {
+	baseURL := strings.TrimSuffix(m.chat.config.BaseURL, "/")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+"/models", nil)
+	if err != nil {
+		return "", err
+	}
+	if m.chat.config.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+m.chat.config.APIKey)
+	}
+	resp, err := m.chat.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("openai API request failed: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", classifyAPIError(readAPIError("openai", resp), 0)
+	}
+	defer resp.Body.Close()
+	var list struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return "", fmt.Errorf("openai API returned an invalid model list: %w", err)
+	}
+	if len(list.Data) == 0 || list.Data[0].ID == "" {
+		return "", fmt.Errorf("no model is available at %s, load a model or set model in the configuration", baseURL)
+	}
+	return list.Data[0].ID, nil
+}
+
+func (m *openAIModel) Generate(ctx context.Context, prompt string) (string, error) {
+	chat, err := m.client(ctx)
+	if err != nil {
+		return "", err
+	}
+	return chat.generate(ctx, prompt)
+}
+
+func (m *openAIModel) GenerateStream(ctx context.Context, prompt string) (<-chan string, error) {
+	return streamGenerate(ctx, m, prompt)
+}
+
+func (m *openAIModel) GenerateJSON(ctx context.Context, prompt string, schema []byte) (string, error) {
+	chat, err := m.client(ctx)
+	if err != nil {
+		return "", err
+	}
+	return chat.generateJSON(ctx, prompt)
+}
+
+func (m *openAIModel) GenerateWithTools(ctx context.Context, messages []Message, tools []ToolSpec) (*ToolResponse, error) {
+	chat, err := m.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return chat.generateWithTools(ctx, messages, tools)
+}
+
+func (m *openAIModel) GetModelType() ModelType {
+	return m.chat.config.ModelType
+}
@@ -0,0 +1,138 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestOpenAICompatibleServer(t *testing.T) {
+	var got chatRequest
+	var listed int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" {
+			t.Errorf("expected no authorization header without an API key, got %q", r.Header.Get("Authorization"))
+		}
+		switch r.URL.Path {
+		case "/v1/models":
+			listed++
+			w.Write([]byte(`{"object": "list", "data": [{"id": "qwen2.5-coder-7b-instruct"}, {"id": "other"}]}`))
+		case "/v1/chat/completions":
+			got = chatRequest{}
+			json.NewDecoder(r.Body).Decode(&got)
+			w.Write([]byte(chatStream(Usage{PromptTokens: 3, CompletionTokens: 1}, "ok")))
+		default:
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+	}))
+	defer srv.Close()
+
+	m, err := newOpenAIModel(ModelConfig{ModelType: ModelTypeOpenAI, BaseURL: srv.URL + "/v1/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, usage := RecordUsage(context.Background())
+	for i := 0; i < 2; i++ {
+		if output, err := m.Generate(ctx, "hi"); err != nil || output != "ok" {
+			t.Fatalf("unexpected output %q %v", output, err)
+		}
+	}
+	// 没有配置模型名时使用服务加载的第一个模型，只查询一次
+	if got.Model != "qwen2.5-coder-7b-instruct" || listed != 1 {
+		t.Errorf("expected the discovered model to be used and cached, got %q after %d lookups", got.Model, listed)
+	}
+	if u, ok := usage.Usage(); !ok || u.Total() != 8 {
+		t.Errorf("expected usage to be reported, got %+v", u)
+	}
+
+	// 配置了模型名时不查询
+	m, _ = newOpenAIModel(ModelConfig{ModelType: ModelTypeOpenAI, BaseURL: srv.URL + "/v1", Model: "llama"})
+	seed := int64(7)
+	if _, err := m.Generate(WithParams(context.Background(), Params{Seed: &seed}), "hi"); err != nil {
+		t.Fatal(err)
+	}
+	if got.Model != "llama" || listed != 1 || got.Seed == nil || *got.Seed != 7 {
+		t.Errorf("expected the configured model and seed, got %+v", got)
+	}
+}
+
+func TestOpenAINoModelLoaded(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data": []}`))
+	}))
+	defer srv.Close()
+	m, _ := newOpenAIModel(ModelConfig{ModelType: ModelTypeOpenAI, BaseURL: srv.URL})
+	if _, err := m.Generate(context.Background(), "hi"); err == nil {
+		t.Error("expected an error when the server has no model loaded")
+	}
+}
+
+func TestOpenAIRequiresKey(t *testing.T) {
+	m, err := newOpenAIModel(ModelConfig{ModelType: ModelTypeOpenAI})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Generate(context.Background(), "hi"); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("expected ErrUnauthorized for api.openai.com without a key, got %v", err)
+	}
+	if _, err := newOpenAIModel(ModelConfig{ModelType: ModelTypeOpenAI, BaseURL: "https://res.openai.azure.com"}); err == nil {
+		t.Error("expected Azure without a deployment name to be rejected")
+	}
+}
+
+// redirectTransport 把请求转发到测试服务器，用于测试固定主机名的 Azure 地址
+type redirectTransport struct {
+	target *url.URL
+	seen   []*http.Request
+}
+
+func (rt *redirectTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	rt.seen = append(rt.seen, r)
+	r = r.Clone(r.Context())
+	r.URL.Scheme, r.URL.Host = rt.target.Scheme, rt.target.Host
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func TestOpenAIAzure(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("api-key") != "azure-key" || r.Header.Get("Authorization") != "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error": {"code": "401", "message": "Access denied due to invalid subscription key."}}`))
+			return
+		}
+		w.Write([]byte(chatStream(Usage{}, "hello")))
+	}))
+	defer srv.Close()
+	target, _ := url.Parse(srv.URL)
+	transport := &redirectTransport{target: target}
+
+	m, err := newOpenAIModel(ModelConfig{APIKey: "azure-key", ModelType: ModelTypeOpenAI, BaseURL: "https://res.openai.azure.com/", Model: "gpt-4o"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.(*openAIModel).chat.client = &http.Client{Transport: transport}
+	if output, err := m.Generate(context.Background(), "hi"); err != nil || output != "hello" {
+		t.Fatalf("unexpected output %q %v", output, err)
+	}
+	want := "https://res.openai.azure.com/openai/deployments/gpt-4o/chat/completions?api-version=" + azureAPIVersion
+	if got := transport.seen[0].URL.String(); got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
+
+func TestAzureEndpoint(t *testing.T) {
+	tests := map[string]string{
+		"https://res.openai.azure.com":                                                "https://res.openai.azure.com/openai/deployments/dep/chat/completions?api-version=" + azureAPIVersion,
+		"https://res.openai.azure.com/openai":                                         "https://res.openai.azure.com/openai/deployments/dep/chat/completions?api-version=" + azureAPIVersion,
+		"https://res.openai.azure.com/openai/deployments/prod?api-version=2024-06-01": "https://res.openai.azure.com/openai/deployments/prod/chat/completions?api-version=2024-06-01",
+	}
+	for base, want := range tests {
+		if got := azureEndpoint(base, "dep"); got != want {
+			t.Errorf("%s: expected %s, got %s", base, want, got)
+		}
+	}
+}
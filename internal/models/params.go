@@ -0,0 +1,90 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// DefaultSeed 是确定性模式下未指定种子时使用的种子
+const DefaultSeed int64 = 1
+
+// maxTemperature 是允许的最大温度
+const maxTemperature = 2.0
+
+// ErrInvalidParams 表示请求的采样参数无效
+var ErrInvalidParams = errors.New("invalid generation parameters")
+
+// Params 是单次生成的采样参数，为 nil 的字段使用模型配置中的默认值
+type Params struct {
+	Temperature   *float64 `json:"temperature,omitempty"`
+	Seed          *int64   `json:"seed,omitempty"`          // 只有支持种子的提供商可以指定
+	Deterministic bool     `json:"deterministic,omitempty"` // 温度固定为 0，支持种子时使用固定种子
+}
+
+// SupportsSeed 判断提供商是否支持指定采样种子，不支持时确定性模式只能将温度设为 0
+func (t ModelType) SupportsSeed() bool {
+	switch t {
+	case ModelTypeDoubao, ModelTypeDeepSeek, ModelTypeOpenAI:
+		return true
+	}
+	return false
+}
+
+// Resolve 用模型类型和默认温度补全参数，返回的 Temperature 不为 nil，
+// Seed 只在提供商支持种子且指定了种子或处于确定性模式时不为 nil
+func (p Params) Resolve(t ModelType, temperature float64) (Params, error) {
+	if p.Temperature != nil {
+		if *p.Temperature < 0 || *p.Temperature > maxTemperature {
+			return Params{}, fmt.Errorf("%w: temperature must be between 0 and %g", ErrInvalidParams, maxTemperature)
+		}
+		if p.Deterministic && *p.Temperature != 0 {
+			return Params{}, fmt.Errorf("%w: deterministic generation requires temperature 0", ErrInvalidParams)
+		}
+		temperature = *p.Temperature
+	}
+	if p.Seed != nil && !t.SupportsSeed() {
+		return Params{}, fmt.Errorf("%w: model %s does not support seeds", ErrInvalidParams, t)
+	}
+
+	resolved := Params{Deterministic: p.Deterministic}
+	if p.Deterministic {
+		temperature = 0
+	}
+	resolved.Temperature = &temperature
+	if t.SupportsSeed() {
+		switch {
+		case p.Seed != nil:
+			seed := *p.Seed
+			resolved.Seed = &seed
+		case p.Deterministic:
+			seed := DefaultSeed
+			resolved.Seed = &seed
+		}
+	}
+	return resolved, nil
+}
+
+// WithOffset 返回种子加上 offset 的参数，用于同一请求的多个候选回复，没有种子时原样返回
+func (p Params) WithOffset(offset int64) Params {
+	if p.Seed != nil {
+		seed := *p.Seed + offset
+		p.Seed = &seed
+	}
+	return p
+}
+
+// paramsKey 是采样参数在 context 中的键
+type paramsKey struct{}
+
+// WithParams 在 ctx 中设置采样参数。参数与流式输出回调一样通过 context 传递，
+// 限流和 Key 池等包装层不需要感知
+func WithParams(ctx context.Context, p Params) context.Context {
+	return context.WithValue(ctx, paramsKey{}, p)
+}
+
+// ParamsFrom 返回 ctx 中的采样参数，没有设置时返回零值
+func ParamsFrom(ctx context.Context) Params {
+	p, _ := ctx.Value(paramsKey{}).(Params)
+	return p
+}
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestParamsResolve(t *testing.T) {
+	p, err := Params{}.Resolve(ModelTypeClaude, 0.7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *p.Temperature != 0.7 || p.Seed != nil {
+		t.Errorf("expected default temperature without seed, got %+v", p)
+	}
+
+	p, err = Params{Deterministic: true}.Resolve(ModelTypeDeepSeek, 0.7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *p.Temperature != 0 || p.Seed == nil || *p.Seed != DefaultSeed {
+		t.Errorf("expected temperature 0 and default seed, got %+v", p)
+	}
+
+	// 不支持种子的提供商在确定性模式下只固定温度
+	p, err = Params{Deterministic: true}.Resolve(ModelTypeClaude, 0.7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *p.Temperature != 0 || p.Seed != nil {
+		t.Errorf("expected temperature 0 without seed, got %+v", p)
+	}
+
+	seed, temperature := int64(42), 0.3
+	p, err = Params{Seed: &seed, Temperature: &temperature}.Resolve(ModelTypeDoubao, 0.7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *p.Temperature != 0.3 || *p.Seed != 42 {
+		t.Errorf("expected requested parameters, got %+v", p)
+	}
+	seed = 7
+	if *p.Seed != 42 {
+		t.Error("resolved params should not share the request seed")
+	}
+
+	invalid := []Params{
+		{Seed: &seed},
+		{Temperature: &[]float64{3}[0]},
+		{Temperature: &temperature, Deterministic: true},
+	}
+	for _, p := range invalid {
+		if _, err := p.Resolve(ModelTypeClaude, 0.7); !errors.Is(err, ErrInvalidParams) {
+			t.Errorf("expected invalid params error for %+v, got %v", p, err)
+		}
+	}
+}
+
+func TestParamsWithOffset(t *testing.T) {
+	seed := int64(10)
+	p := Params{Seed: &seed}
+	q := p.WithOffset(2)
+	if *q.Seed != 12 || *p.Seed != 10 {
+		t.Errorf("unexpected seeds %d and %d", *q.Seed, *p.Seed)
+	}
+	if (Params{}).WithOffset(1).Seed != nil {
+		t.Error("expected no seed")
+	}
+}
+
+func TestParamsContext(t *testing.T) {
+	if p := ParamsFrom(context.Background()); p.Temperature != nil || p.Seed != nil || p.Deterministic {
+		t.Errorf("expected zero params, got %+v", p)
+	}
+	ctx := WithParams(context.Background(), Params{Deterministic: true})
+	if !ParamsFrom(ctx).Deterministic {
+		t.Error("expected params from context")
+	}
+}
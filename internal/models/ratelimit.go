@@ -0,0 +1,229 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateLimitInfo 是从提供商响应头中解析出的限流信息，-1 表示未知
+type RateLimitInfo struct {
+	LimitRequests     int       `json:"limit_requests"`
+	RemainingRequests int       `json:"remaining_requests"`
+	LimitTokens       int       `json:"limit_tokens"`
+	RemainingTokens   int       `json:"remaining_tokens"`
+	ResetAt           time.Time `json:"reset_at,omitempty"`
+	RetryAfter        float64   `json:"retry_after,omitempty"` // 秒
+	UpdatedAt         time.Time `json:"updated_at,omitempty"`
+}
+
+// RateLimitError 表示请求被提供商限流，调用方应在 RetryAfter 之后重试
+type RateLimitError struct {
+	RetryAfter time.Duration
+	Message    string
+}
+
+func (e *RateLimitError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("rate limited by provider, retry after %s", e.RetryAfter)
+	}
+	return fmt.Sprintf("rate limited by provider, retry after %s: %s", e.RetryAfter, e.Message)
+}
+
+// ParseRateLimitHeaders 解析 Anthropic 和 OpenAI 风格的限流响应头
+func ParseRateLimitHeaders(h http.Header) RateLimitInfo {
+	now := time.Now()
+	info := RateLimitInfo{
+		LimitRequests:     -1,
+		RemainingRequests: -1,
+		LimitTokens:       -1,
+		RemainingTokens:   -1,
+		UpdatedAt:         now,
+	}
+
+	intHeader := func(dst *int, names ...string) {
+		for _, name := range names {
+			if v, err := strconv.Atoi(h.Get(name)); err == nil {
+				*dst = v
+				return
+			}
+		}
+	}
+	intHeader(&info.LimitRequests, "anthropic-ratelimit-requests-limit", "x-ratelimit-limit-requests")
+	intHeader(&info.RemainingRequests, "anthropic-ratelimit-requests-remaining", "x-ratelimit-remaining-requests")
+	intHeader(&info.LimitTokens, "anthropic-ratelimit-tokens-limit", "x-ratelimit-limit-tokens")
+	intHeader(&info.RemainingTokens, "anthropic-ratelimit-tokens-remaining", "x-ratelimit-remaining-tokens")
+
+	// 重置时间：Anthropic 使用 RFC 3339 时间，OpenAI 使用 "1s"、"6m0s" 这样的时长
+	for _, name := range []string{
+		"anthropic-ratelimit-requests-reset", "anthropic-ratelimit-tokens-reset",
+		"x-ratelimit-reset-requests", "x-ratelimit-reset-tokens",
+	} {
+		v := h.Get(name)
+		if v == "" {
+			continue
+		}
+		var reset time.Time
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			reset = t
+		} else if d, err := time.ParseDuration(v); err == nil {
+			reset = now.Add(d)
+		}
+		if reset.After(info.ResetAt) {
+			info.ResetAt = reset
+		}
+	}
+
+	if d, ok := parseRetryAfter(h.Get("retry-after"), now); ok {
+		info.RetryAfter = d.Seconds()
+	}
+	return info
+}
+
+// parseRetryAfter 解析 Retry-After 头，支持秒数和 HTTP 日期
+func parseRetryAfter(v string, now time.Time) (time.Duration, bool) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.ParseFloat(v, 64); err == nil {
+		return time.Duration(secs * float64(time.Second)), true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return t.Sub(now), true
+	}
+	return 0, false
+}
+
+// RateLimiter 根据提供商返回的限流信息自动调整请求节奏
+type RateLimiter struct {
+	mu      sync.Mutex
+	info    RateLimitInfo
+	blocked time.Time // 在此之前不应发送请求
+	maxWait time.Duration
+}
+
+// NewRateLimiter 创建限流器，需要等待超过 maxWait 时直接返回 *RateLimitError
+func NewRateLimiter(maxWait time.Duration) *RateLimiter {
+	return &RateLimiter{
+		info: RateLimitInfo{
+			LimitRequests:     -1,
+			RemainingRequests: -1,
+			LimitTokens:       -1,
+			RemainingTokens:   -1,
+		},
+		maxWait: maxWait,
+	}
+}
+
+// Observe 记录提供商响应头中的限流信息，模型实现在每次收到响应后调用
+func (l *RateLimiter) Observe(h http.Header) {
+	info := ParseRateLimitHeaders(h)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.info = info
+	if info.RetryAfter > 0 {
+		l.block(time.Duration(info.RetryAfter * float64(time.Second)))
+	} else if (info.RemainingRequests == 0 || info.RemainingTokens == 0) && !info.ResetAt.IsZero() {
+		l.block(time.Until(info.ResetAt))
+	}
+}
+
+// Backoff 在被限流后暂停发送请求
+func (l *RateLimiter) Backoff(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.block(d)
+}
+
+// block 在 d 之内阻止请求，调用方需持有锁
+func (l *RateLimiter) block(d time.Duration) {
+	if until := time.Now().Add(d); until.After(l.blocked) {
+		l.blocked = until
+	}
+}
+
+// Wait 在发送请求前等待限流解除，等待时间超过上限时返回 *RateLimitError
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	l.mu.Lock()
+	wait := time.Until(l.blocked)
+	l.mu.Unlock()
+
+	if wait <= 0 {
+		return nil
+	}
+	if wait > l.maxWait {
+		return &RateLimitError{RetryAfter: wait.Round(time.Second)}
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Info 返回最近一次的限流信息
+func (l *RateLimiter) Info() RateLimitInfo {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	info := l.info
+	if wait := time.Until(l.blocked); wait > 0 {
+		info.RetryAfter = wait.Seconds()
+	} else {
+		info.RetryAfter = 0
+	}
+	return info
+}
+
+// rateLimitedModel 在调用模型前根据限流信息等待，被限流时记录退避时间
+type rateLimitedModel struct {
+	Model
+	limiter *RateLimiter
+}
+
+func (m *rateLimitedModel) Generate(ctx context.Context, prompt string) (string, error) {
+	return m.call(ctx, func(model Model) (string, error) { return model.Generate(ctx, prompt) })
+}
+
+func (m *rateLimitedModel) GenerateJSON(ctx context.Context, prompt string, schema []byte) (string, error) {
+	return m.call(ctx, func(model Model) (string, error) { return GenerateJSON(ctx, model, prompt, schema) })
+}
+
+func (m *rateLimitedModel) GenerateWithTools(ctx context.Context, messages []Message, tools []ToolSpec) (*ToolResponse, error) {
+	var resp *ToolResponse
+	_, err := m.call(ctx, func(model Model) (string, error) {
+		var err error
+		resp, err = GenerateWithTools(ctx, model, messages, tools)
+		return toolText(resp), err
+	})
+	return resp, err
+}
+
+func (m *rateLimitedModel) GenerateStream(ctx context.Context, prompt string) (<-chan string, error) {
+	return streamGenerate(ctx, m, prompt)
+}
+
+// call 等待限流后调用模型，被限流时记录退避时间
+func (m *rateLimitedModel) call(ctx context.Context, generate func(model Model) (string, error)) (string, error) {
+	if err := m.limiter.Wait(ctx); err != nil {
+		return "", err
+	}
+	output, err := generate(m.Model)
+	var rateErr *RateLimitError
+	if errors.As(err, &rateErr) {
+		m.limiter.Backoff(rateErr.RetryAfter)
+	}
+	return output, err
+}
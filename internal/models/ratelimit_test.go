@@ -0,0 +1,61 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseRateLimitHeaders(t *testing.T) {
+	h := http.Header{}
+	h.Set("anthropic-ratelimit-requests-limit", "50")
+	h.Set("anthropic-ratelimit-requests-remaining", "0")
+	h.Set("anthropic-ratelimit-requests-reset", time.Now().Add(time.Minute).UTC().Format(time.RFC3339))
+	h.Set("retry-after", "12")
+
+	info := ParseRateLimitHeaders(h)
+	if info.LimitRequests != 50 || info.RemainingRequests != 0 {
+		t.Errorf("unexpected request limits: %+v", info)
+	}
+	if info.LimitTokens != -1 {
+		t.Errorf("expected unknown token limit, got %d", info.LimitTokens)
+	}
+	if info.RetryAfter != 12 || info.ResetAt.IsZero() {
+		t.Errorf("unexpected retry info: %+v", info)
+	}
+
+	h = http.Header{}
+	h.Set("x-ratelimit-remaining-tokens", "100")
+	h.Set("x-ratelimit-reset-tokens", "6m0s")
+	info = ParseRateLimitHeaders(h)
+	if info.RemainingTokens != 100 || time.Until(info.ResetAt) < 5*time.Minute {
+		t.Errorf("unexpected openai style limits: %+v", info)
+	}
+}
+
+func TestRateLimiterWait(t *testing.T) {
+	l := NewRateLimiter(time.Second)
+	if err := l.Wait(context.Background()); err != nil {
+		t.Fatalf("expected no wait, got %v", err)
+	}
+
+	l.Backoff(20 * time.Millisecond)
+	start := time.Now()
+	if err := l.Wait(context.Background()); err != nil {
+		t.Fatalf("expected short wait to succeed, got %v", err)
+	}
+	if time.Since(start) < 15*time.Millisecond {
+		t.Error("expected limiter to pace the request")
+	}
+
+	l.Backoff(time.Minute)
+	var rateErr *RateLimitError
+	if err := l.Wait(context.Background()); !errors.As(err, &rateErr) {
+		t.Fatalf("expected rate limit error, got %v", err)
+	}
+	if l.Info().RetryAfter <= 0 {
+		t.Error("expected info to report retry after")
+	}
+}
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrUnauthorized 表示提供商拒绝了 API Key，模型实现应使用 %w 包装该错误
+var ErrUnauthorized = errors.New("invalid API key")
+
+// selfTestPrompt 是自检时使用的最小提示词
+const selfTestPrompt = "Reply with the single word OK."
+
+// TestResult 是模型连通性自检的结果，模型没有报告 token 用量时为估算值
+type TestResult struct {
+	Model            ModelType `json:"model"`
+	OK               bool      `json:"ok"`
+	AuthValid        bool      `json:"auth_valid"`
+	Latency          int64     `json:"latency_ms"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	Response         string    `json:"response,omitempty"`
+	Error            string    `json:"error,omitempty"`
+	TestedAt         time.Time `json:"tested_at"`
+}
+
+// SelfTest 对模型执行一次最小生成，报告延迟、API Key 是否有效以及 token 用量
+// hasKey 表示是否配置了 API Key
+func SelfTest(ctx context.Context, model Model, hasKey bool) *TestResult {
+	result := &TestResult{
+		Model:        model.GetModelType(),
+		PromptTokens: EstimateTokens(selfTestPrompt),
+		TestedAt:     time.Now(),
+	}
+	if !hasKey {
+		result.Error = "API key is not configured"
+		return result
+	}
+
+	start := time.Now()
+	ctx, recorder := RecordUsage(ctx)
+	output, err := model.Generate(ctx, selfTestPrompt)
+	result.Latency = time.Since(start).Milliseconds()
+	if err != nil {
+		result.Error = err.Error()
+		result.AuthValid = !errors.Is(err, ErrUnauthorized)
+		return result
+	}
+
+	result.OK = true
+	result.AuthValid = true
+	result.Response = output
+	result.CompletionTokens = EstimateTokens(output)
+	if usage, ok := recorder.Usage(); ok {
+		result.PromptTokens, result.CompletionTokens = usage.PromptTokens, usage.CompletionTokens
+	}
+	return result
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestSelfTest(t *testing.T) {
+	ctx := context.Background()
+	model := NewMockModel("",
+		MockResponse{Output: "OK"},
+		MockResponse{Err: fmt.Errorf("status 401: %w", ErrUnauthorized)},
+		MockResponse{Err: errors.New("connection refused")},
+	)
+
+	// 没有 API Key 时不发出请求
+	if result := SelfTest(ctx, model, false); result.OK || result.Error == "" || len(model.Prompts()) != 0 {
+		t.Errorf("expected a missing key to fail without a request, got %+v", result)
+	}
+
+	result := SelfTest(ctx, model, true)
+	if !result.OK || !result.AuthValid || result.Response != "OK" || result.Model != model.GetModelType() {
+		t.Errorf("unexpected result %+v", result)
+	}
+	if result.PromptTokens == 0 || result.CompletionTokens == 0 {
+		t.Errorf("expected estimated token usage, got %+v", result)
+	}
+
+	if result := SelfTest(ctx, model, true); result.OK || result.AuthValid {
+		t.Errorf("expected a rejected key to be reported, got %+v", result)
+	}
+	// 其他错误不代表 API Key 无效
+	if result := SelfTest(ctx, model, true); result.OK || !result.AuthValid || result.Error != "connection refused" {
+		t.Errorf("expected a connection error with a valid key, got %+v", result)
+	}
+}
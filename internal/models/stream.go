@@ -0,0 +1,59 @@
+package models
+
+import "context"
+
+// tokenHandlerKey 是流式输出回调在 context 中的键
+type tokenHandlerKey struct{}
+
+// WithTokenHandler 在 ctx 中注册流式输出回调，支持流式输出的模型每收到一段输出调用一次。
+// 回调通过 context 传递，限流和 Key 池等包装层不需要感知
+func WithTokenHandler(ctx context.Context, fn func(token string)) context.Context {
+	return context.WithValue(ctx, tokenHandlerKey{}, fn)
+}
+
+// EmitToken 将一段流式输出交给 ctx 中注册的回调，没有回调时不做任何事
+func EmitToken(ctx context.Context, token string) {
+	if fn, ok := ctx.Value(tokenHandlerKey{}).(func(string)); ok {
+		fn(token)
+	}
+}
+
+// AddTokenHandler 在 ctx 中追加流式输出回调，ctx 中已有的回调仍然先被调用
+func AddTokenHandler(ctx context.Context, fn func(token string)) context.Context {
+	prev, ok := ctx.Value(tokenHandlerKey{}).(func(string))
+	if !ok {
+		return WithTokenHandler(ctx, fn)
+	}
+	return WithTokenHandler(ctx, func(token string) {
+		prev(token)
+		fn(token)
+	})
+}
+
+// streamGenerate 在后台调用 m.Generate，把流式输出逐段写入返回的通道，生成结束后关闭通道。
+// 模型没有流式输出时一次写入完整的输出。生成失败时通道提前关闭，需要区分失败和正常结束的调用方
+// 应使用 Generate 和 WithTokenHandler。调用方不再读取时必须取消 ctx，否则生成会阻塞在写入上
+func streamGenerate(ctx context.Context, m Model, prompt string) (<-chan string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	tokens := make(chan string, 16)
+	go func() {
+		defer close(tokens)
+		send := func(token string) {
+			select {
+			case tokens <- token:
+			case <-ctx.Done():
+			}
+		}
+		streamed := false
+		output, err := m.Generate(AddTokenHandler(ctx, func(token string) {
+			streamed = true
+			send(token)
+		}), prompt)
+		if err == nil && !streamed && output != "" {
+			send(output)
+		}
+	}()
+	return tokens, nil
+}
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// collect 读取流式输出直到通道关闭
+func collect(t *testing.T, tokens <-chan string, err error) []string {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("failed to start stream: %v", err)
+	}
+	var out []string
+	for token := range tokens {
+		out = append(out, token)
+	}
+	return out
+}
+
+func TestGenerateStream(t *testing.T) {
+	ctx := context.Background()
+	model := NewMockModel("",
+		MockResponse{Output: "你好世界", ChunkSize: 2},
+		MockResponse{Output: "whole"},
+		MockResponse{Output: "par", ChunkSize: 1, Err: errors.New("boom")},
+	)
+
+	// 外层已注册的回调仍然收到输出
+	var observed strings.Builder
+	ctx = WithTokenHandler(ctx, func(token string) { observed.WriteString(token) })
+	tokens, err := model.GenerateStream(ctx, "first")
+	if got := collect(t, tokens, err); strings.Join(got, "|") != "你好|世界" {
+		t.Errorf("unexpected stream chunks %v", got)
+	}
+	if observed.String() != "你好世界" {
+		t.Errorf("expected outer handler to observe the stream, got %q", observed.String())
+	}
+
+	// 模型没有流式输出时一次输出完整内容
+	tokens, err = model.GenerateStream(context.Background(), "second")
+	if got := collect(t, tokens, err); len(got) != 1 || got[0] != "whole" {
+		t.Errorf("expected the whole output as one token, got %v", got)
+	}
+
+	// 失败时通道在已输出的部分之后关闭
+	tokens, err = model.GenerateStream(context.Background(), "third")
+	if got := collect(t, tokens, err); strings.Join(got, "") != "par" {
+		t.Errorf("expected partial output before failure, got %v", got)
+	}
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := model.GenerateStream(canceled, "x"); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected canceled context to fail, got %v", err)
+	}
+}
+
+func TestAddTokenHandler(t *testing.T) {
+	model := NewMockModel("",
+		MockResponse{Output: "你好世界", ChunkSize: 2},
+		MockResponse{Output: "par", ChunkSize: 1, Err: errors.New("boom")},
+	)
+
+	// 外层已注册的回调先收到输出，追加的回调仍然收到每一段
+	var observed strings.Builder
+	var tokens []string
+	ctx := WithTokenHandler(context.Background(), func(token string) { observed.WriteString(token) })
+	ctx = AddTokenHandler(ctx, func(token string) {
+		if observed.Len() == 0 {
+			t.Error("expected the outer handler to run first")
+		}
+		tokens = append(tokens, token)
+	})
+	output, err := model.Generate(ctx, "first")
+	if err != nil || output != "你好世界" {
+		t.Fatalf("unexpected output %q %v", output, err)
+	}
+	if strings.Join(tokens, "|") != "你好|世界" || observed.String() != "你好世界" {
+		t.Errorf("unexpected stream chunks %v, outer handler saw %q", tokens, observed.String())
+	}
+
+	// 失败时已输出的部分仍然推送给回调，错误由 Generate 返回
+	tokens = nil
+	_, err = model.Generate(AddTokenHandler(context.Background(), func(token string) { tokens = append(tokens, token) }), "second")
+	if err == nil || strings.Join(tokens, "") != "par" {
+		t.Errorf("expected partial output and an error, got %v %v", tokens, err)
+	}
+}
@@ -0,0 +1,18 @@
+package models
+
+import "unicode/utf8"
+
+// EstimateTokens 粗略估算文本的 token 数
+// ASCII 字符按每 4 个一个 token 计算，其余字符（如中文）按每个字符一个 token 计算，
+// 用于提供商未返回用量时的预算控制
+func EstimateTokens(text string) int {
+	ascii, other := 0, 0
+	for _, r := range text {
+		if r < utf8.RuneSelf {
+			ascii++
+		} else {
+			other++
+		}
+	}
+	return (ascii+3)/4 + other
+}
@@ -0,0 +1,139 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// 对话消息的角色
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+	RoleTool      = "tool" // 工具调用的结果，ToolCallID 指明对应的调用
+)
+
+// Message 是多轮工具调用对话中的一条消息
+type Message struct {
+	Role       string     `json:"role"`
+	Content    string     `json:"content,omitempty"`
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`   // assistant 消息请求的工具调用
+	ToolCallID string     `json:"tool_call_id,omitempty"` // tool 消息对应的调用 ID
+}
+
+// ToolSpec 描述模型可以调用的工具，Parameters 为参数的 JSON Schema
+type ToolSpec struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Parameters  json.RawMessage `json:"parameters"`
+}
+
+// ToolCall 是模型请求的一次工具调用
+type ToolCall struct {
+	ID        string                 `json:"id"`
+	Name      string                 `json:"name"`
+	Arguments map[string]interface{} `json:"arguments"`
+}
+
+// ToolResponse 是带工具的一轮生成的结果：ToolCalls 为空时 Text 是最终回复，
+// 否则调用方执行这些工具，把结果作为 tool 消息加入对话后再次生成
+type ToolResponse struct {
+	Text      string     `json:"text,omitempty"`
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+}
+
+// ToolModel 是支持提供商原生工具调用的模型
+type ToolModel interface {
+	GenerateWithTools(ctx context.Context, messages []Message, tools []ToolSpec) (*ToolResponse, error)
+}
+
+// GenerateWithTools 使用模型的原生工具调用生成一轮回复，模型不支持时退回普通生成，
+// 此时由提示词列出工具并要求模型以 JSON 输出调用请求
+func GenerateWithTools(ctx context.Context, m Model, messages []Message, tools []ToolSpec) (*ToolResponse, error) {
+	if tm, ok := m.(ToolModel); ok {
+		return tm.GenerateWithTools(ctx, messages, tools)
+	}
+	output, err := m.Generate(ctx, toolsPrompt(messages, tools))
+	if err != nil {
+		return nil, err
+	}
+	return parseToolResponse(output, tools), nil
+}
+
+// toolText 返回回复的文本，用于复用按文本输出实现的包装层
+func toolText(resp *ToolResponse) string {
+	if resp == nil {
+		return ""
+	}
+	return resp.Text
+}
+
+// toolsPrompt 把对话和工具列表写成一个提示词，用于不支持原生工具调用的模型
+func toolsPrompt(messages []Message, tools []ToolSpec) string {
+	var b strings.Builder
+	b.WriteString("You can call the following tools. To call tools, reply with only a JSON object " +
+		`{"tool_calls": [{"name": "<tool>", "arguments": {...}}]} and nothing else; ` +
+		"the results will be sent back to you. When no tool is needed, reply to the user in plain text.\n\nTools:\n")
+	for _, tool := range tools {
+		fmt.Fprintf(&b, "- %s: %s\n  parameters: %s\n", tool.Name, tool.Description, tool.Parameters)
+	}
+	b.WriteString("\nConversation:\n")
+	for _, msg := range messages {
+		switch {
+		case msg.Role == RoleTool:
+			fmt.Fprintf(&b, "\n[tool result %s]\n%s\n", msg.ToolCallID, msg.Content)
+		case len(msg.ToolCalls) > 0:
+			calls, _ := json.Marshal(map[string]interface{}{"tool_calls": msg.ToolCalls})
+			fmt.Fprintf(&b, "\n[%s]\n%s\n", msg.Role, calls)
+		default:
+			fmt.Fprintf(&b, "\n[%s]\n%s\n", msg.Role, msg.Content)
+		}
+	}
+	return b.String()
+}
+
+// parseToolResponse 从普通生成的输出中解析工具调用请求，输出不是调用已知工具的 JSON 时作为文本回复
+func parseToolResponse(output string, tools []ToolSpec) *ToolResponse {
+	text := strings.TrimSpace(output)
+	if body, ok := strings.CutPrefix(text, "```"); ok {
+		_, body, _ = strings.Cut(body, "\n")
+		text = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(body), "```"))
+	}
+	var parsed struct {
+		ToolCalls []ToolCall `json:"tool_calls"`
+	}
+	if err := json.Unmarshal([]byte(text), &parsed); err != nil || len(parsed.ToolCalls) == 0 {
+		return &ToolResponse{Text: output}
+	}
+	known := make(map[string]bool, len(tools))
+	for _, tool := range tools {
+		known[tool.Name] = true
+	}
+	for i := range parsed.ToolCalls {
+		call := &parsed.ToolCalls[i]
+		if !known[call.Name] {
+			return &ToolResponse{Text: output}
+		}
+		if call.ID == "" {
+			call.ID = fmt.Sprintf("call_%d", i+1)
+		}
+		if call.Arguments == nil {
+			call.Arguments = map[string]interface{}{}
+		}
+	}
+	return &ToolResponse{ToolCalls: parsed.ToolCalls}
+}
+
+// toolArguments 解析提供商返回的 JSON 参数，空字符串表示没有参数
+func toolArguments(provider, name, raw string) (map[string]interface{}, error) {
+	args := map[string]interface{}{}
+	if strings.TrimSpace(raw) == "" {
+		return args, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &args); err != nil {
+		return nil, fmt.Errorf("%s API returned invalid arguments for tool %s: %w", provider, name, err)
+	}
+	return args, nil
+}
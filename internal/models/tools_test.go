@@ -0,0 +1,174 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var weatherTool = ToolSpec{
+	Name:        "weather",
+	Description: "Look up the weather",
+	Parameters:  json.RawMessage(`{"type":"object","properties":{"city":{"type":"string"}},"required":["city"]}`),
+}
+
+func TestGenerateWithToolsFallback(t *testing.T) {
+	m := NewMockModel("",
+		MockResponse{Output: "```json\n{\"tool_calls\": [{\"name\": \"weather\", \"arguments\": {\"city\": \"Paris\"}}]}\n```"},
+		MockResponse{Output: "It is sunny in Paris."},
+	)
+	messages := []Message{{Role: RoleUser, Content: "weather in Paris?"}}
+	resp, err := GenerateWithTools(context.Background(), m, messages, []ToolSpec{weatherTool})
+	if err != nil || len(resp.ToolCalls) != 1 {
+		t.Fatalf("expected a tool call, got %+v %v", resp, err)
+	}
+	call := resp.ToolCalls[0]
+	if call.ID != "call_1" || call.Name != "weather" || call.Arguments["city"] != "Paris" {
+		t.Errorf("unexpected tool call %+v", call)
+	}
+	if prompt := m.Prompts()[0]; !strings.Contains(prompt, "- weather: Look up the weather") || !strings.Contains(prompt, "weather in Paris?") {
+		t.Errorf("expected the tools and conversation in the prompt, got %q", prompt)
+	}
+
+	messages = append(messages, Message{Role: RoleAssistant, ToolCalls: resp.ToolCalls},
+		Message{Role: RoleTool, ToolCallID: call.ID, Content: "sunny"})
+	resp, err = GenerateWithTools(context.Background(), m, messages, []ToolSpec{weatherTool})
+	if err != nil || resp.Text != "It is sunny in Paris." || len(resp.ToolCalls) != 0 {
+		t.Errorf("expected a text reply, got %+v %v", resp, err)
+	}
+	if prompt := m.Prompts()[1]; !strings.Contains(prompt, "[tool result call_1]\nsunny") {
+		t.Errorf("expected the tool result in the prompt, got %q", prompt)
+	}
+}
+
+func TestParseToolResponse(t *testing.T) {
+	tests := map[string]bool{
+		`{"tool_calls": [{"name": "weather", "arguments": {}}]}`: true,
+		`{"tool_calls": [{"name": "rm", "arguments": {}}]}`:      false,
+		`{"tool_calls": []}`:   false,
+		"The weather is nice.": false,
+	}
+	for output, wantCalls := range tests {
+		resp := parseToolResponse(output, []ToolSpec{weatherTool})
+		if got := len(resp.ToolCalls) > 0; got != wantCalls {
+			t.Errorf("%s: expected tool calls %v, got %+v", output, wantCalls, resp)
+		}
+		if !wantCalls && resp.Text != output {
+			t.Errorf("%s: expected the output as text, got %q", output, resp.Text)
+		}
+	}
+}
+
+func TestChatGenerateWithTools(t *testing.T) {
+	var got map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		chunks := []string{
+			`{"choices": [{"delta": {"content": "Checking."}}]}`,
+			`{"choices": [{"delta": {"tool_calls": [{"index": 0, "id": "call_a", "type": "function", "function": {"name": "weather", "arguments": ""}}]}}]}`,
+			`{"choices": [{"delta": {"tool_calls": [{"index": 0, "function": {"arguments": "{\"city\": "}}]}}]}`,
+			`{"choices": [{"delta": {"tool_calls": [{"index": 0, "function": {"arguments": "\"Paris\"}"}}]}}]}`,
+			`{"choices": [{"delta": {"tool_calls": [{"index": 1, "id": "call_b", "type": "function", "function": {"name": "weather", "arguments": "{\"city\": \"Rome\"}"}}]}}]}`,
+		}
+		for _, chunk := range chunks {
+			w.Write([]byte("data: " + chunk + "\n\n"))
+		}
+		w.Write([]byte("data: [DONE]\n\n"))
+	}))
+	defer srv.Close()
+
+	m, _ := newDeepSeekModel(ModelConfig{APIKey: "sk-test", ModelType: ModelTypeDeepSeek, BaseURL: srv.URL})
+	messages := []Message{
+		{Role: RoleSystem, Content: "be brief"},
+		{Role: RoleUser, Content: "weather?"},
+		{Role: RoleAssistant, ToolCalls: []ToolCall{{ID: "call_0", Name: "weather", Arguments: map[string]interface{}{}}}},
+		{Role: RoleTool, ToolCallID: "call_0", Content: "which city?"},
+	}
+	resp, err := GenerateWithTools(context.Background(), m, messages, []ToolSpec{weatherTool})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text != "Checking." || len(resp.ToolCalls) != 2 ||
+		resp.ToolCalls[0].ID != "call_a" || resp.ToolCalls[0].Arguments["city"] != "Paris" ||
+		resp.ToolCalls[1].ID != "call_b" || resp.ToolCalls[1].Arguments["city"] != "Rome" {
+		t.Errorf("unexpected response %+v", resp)
+	}
+
+	tools, _ := got["tools"].([]interface{})
+	if len(tools) != 1 || tools[0].(map[string]interface{})["function"].(map[string]interface{})["name"] != "weather" {
+		t.Errorf("expected the tool in the request, got %v", got["tools"])
+	}
+	sent, _ := got["messages"].([]interface{})
+	if len(sent) != 4 {
+		t.Fatalf("expected 4 messages, got %v", got["messages"])
+	}
+	call := sent[2].(map[string]interface{})["tool_calls"].([]interface{})[0].(map[string]interface{})
+	if _, ok := call["index"]; ok || call["id"] != "call_0" || call["function"].(map[string]interface{})["arguments"] != "{}" {
+		t.Errorf("unexpected assistant tool call %v", call)
+	}
+	if sent[3].(map[string]interface{})["tool_call_id"] != "call_0" {
+		t.Errorf("unexpected tool message %v", sent[3])
+	}
+}
+
+func TestClaudeGenerateWithTools(t *testing.T) {
+	var got map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		w.Header().Set("Content-Type", "text/event-stream")
+		events := []string{
+			`{"type": "message_start", "message": {"usage": {"input_tokens": 5, "output_tokens": 1}}}`,
+			`{"type": "content_block_start", "index": 0, "content_block": {"type": "text", "text": ""}}`,
+			`{"type": "content_block_delta", "index": 0, "delta": {"type": "text_delta", "text": "Checking."}}`,
+			`{"type": "content_block_start", "index": 1, "content_block": {"type": "tool_use", "id": "toolu_1", "name": "weather", "input": {}}}`,
+			`{"type": "content_block_delta", "index": 1, "delta": {"type": "input_json_delta", "partial_json": "{\"city\":"}}`,
+			`{"type": "content_block_delta", "index": 1, "delta": {"type": "input_json_delta", "partial_json": " \"Paris\"}"}}`,
+			`{"type": "message_delta", "usage": {"output_tokens": 12}}`,
+			`{"type": "message_stop"}`,
+		}
+		for _, event := range events {
+			w.Write([]byte("data: " + event + "\n\n"))
+		}
+	}))
+	defer srv.Close()
+
+	m, _ := newClaudeModel(ModelConfig{APIKey: "sk-test", ModelType: ModelTypeClaude, BaseURL: srv.URL})
+	messages := []Message{
+		{Role: RoleSystem, Content: "be brief"},
+		{Role: RoleUser, Content: "weather?"},
+		{Role: RoleAssistant, ToolCalls: []ToolCall{{ID: "toolu_0", Name: "weather"}, {ID: "toolu_9", Name: "weather"}}},
+		{Role: RoleTool, ToolCallID: "toolu_0", Content: "which city?"},
+		{Role: RoleTool, ToolCallID: "toolu_9", Content: "which city?"},
+	}
+	resp, err := GenerateWithTools(context.Background(), m, messages, []ToolSpec{weatherTool})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text != "Checking." || len(resp.ToolCalls) != 1 ||
+		resp.ToolCalls[0].ID != "toolu_1" || resp.ToolCalls[0].Arguments["city"] != "Paris" {
+		t.Errorf("unexpected response %+v", resp)
+	}
+
+	if got["system"] != "be brief" {
+		t.Errorf("expected the system message as the system field, got %v", got["system"])
+	}
+	tools, _ := got["tools"].([]interface{})
+	if len(tools) != 1 || tools[0].(map[string]interface{})["input_schema"] == nil {
+		t.Errorf("expected the tool with its input schema, got %v", got["tools"])
+	}
+	sent, _ := got["messages"].([]interface{})
+	if len(sent) != 3 {
+		t.Fatalf("expected tool results to be merged into one user message, got %v", got["messages"])
+	}
+	use := sent[1].(map[string]interface{})["content"].([]interface{})[0].(map[string]interface{})
+	if use["type"] != "tool_use" || use["input"] == nil {
+		t.Errorf("expected a tool_use block with an empty input, got %v", use)
+	}
+	results := sent[2].(map[string]interface{})["content"].([]interface{})
+	if len(results) != 2 || results[1].(map[string]interface{})["tool_use_id"] != "toolu_9" {
+		t.Errorf("unexpected tool results %v", results)
+	}
+}
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"context"
+	"sync"
+)
+
+// Usage 是提供商返回的一次生成实际消耗的 token 数
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+// Total 返回输入和输出 token 的总数
+func (u Usage) Total() int {
+	return u.PromptTokens + u.CompletionTokens
+}
+
+// usageHandlerKey 是 token 用量回调在 context 中的键
+type usageHandlerKey struct{}
+
+// AddUsageHandler 在 ctx 中追加 token 用量回调，模型实现在生成结束后调用一次 ReportUsage。
+// 与流式输出回调一样通过 context 传递，限流和 Key 池等包装层不需要感知
+func AddUsageHandler(ctx context.Context, fn func(Usage)) context.Context {
+	prev, ok := ctx.Value(usageHandlerKey{}).(func(Usage))
+	if !ok {
+		return context.WithValue(ctx, usageHandlerKey{}, fn)
+	}
+	return context.WithValue(ctx, usageHandlerKey{}, func(u Usage) {
+		prev(u)
+		fn(u)
+	})
+}
+
+// ReportUsage 将提供商返回的 token 用量交给 ctx 中注册的回调，没有回调时不做任何事
+func ReportUsage(ctx context.Context, u Usage) {
+	if fn, ok := ctx.Value(usageHandlerKey{}).(func(Usage)); ok {
+		fn(u)
+	}
+}
+
+// UsageRecorder 累计 ctx 中报告的 token 用量，同一个 ctx 中的多次生成（如重试）累加在一起
+type UsageRecorder struct {
+	mu       sync.Mutex
+	usage    Usage
+	reported bool
+}
+
+// RecordUsage 返回记录 token 用量的 ctx
+func RecordUsage(ctx context.Context) (context.Context, *UsageRecorder) {
+	r := &UsageRecorder{}
+	return AddUsageHandler(ctx, func(u Usage) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.usage.PromptTokens += u.PromptTokens
+		r.usage.CompletionTokens += u.CompletionTokens
+		r.reported = true
+	}), r
+}
+
+// Usage 返回累计的用量，ok 为 false 表示模型没有报告用量
+func (r *UsageRecorder) Usage() (Usage, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.usage, r.reported
+}
+
+// Tokens 返回累计的 token 总数，模型没有报告用量时按提示词和输出估算
+func (r *UsageRecorder) Tokens(prompt, output string) int {
+	if u, ok := r.Usage(); ok {
+		return u.Total()
+	}
+	return EstimateTokens(prompt) + EstimateTokens(output)
+}
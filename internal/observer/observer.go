@@ -0,0 +1,97 @@
+// Package observer 管理只读的观察者令牌，持有令牌的客户端可以订阅 agent 步骤、
+// 文件 diff 和对话消息等事件，用于结对编程时让第二个客户端旁观 AI 会话
+package observer
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Token 是只读的观察者令牌，只保存在内存中，服务重启后失效
+type Token struct {
+	Token     string    `json:"token"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at,omitempty"`
+}
+
+// expired 判断令牌是否已过期
+func (t *Token) expired(now time.Time) bool {
+	return !t.ExpiresAt.IsZero() && now.After(t.ExpiresAt)
+}
+
+// Hub 管理观察者令牌，观察者通过令牌只读地订阅事件总线
+type Hub struct {
+	mu     sync.Mutex
+	tokens map[string]*Token
+}
+
+// New 创建一个 Hub
+func New() *Hub {
+	return &Hub{tokens: make(map[string]*Token)}
+}
+
+// IssueToken 创建一个观察者令牌，ttl 为 0 时不过期
+func (h *Hub) IssueToken(name string, ttl time.Duration) (*Token, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	token := &Token{Token: hex.EncodeToString(buf), Name: name, CreatedAt: now}
+	if ttl > 0 {
+		token.ExpiresAt = now.Add(ttl)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.tokens[token.Token] = token
+	c := *token
+	return &c, nil
+}
+
+// Tokens 按创建时间列出未过期的令牌
+func (h *Hub) Tokens() []*Token {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := time.Now()
+	tokens := make([]*Token, 0, len(h.tokens))
+	for key, token := range h.tokens {
+		if token.expired(now) {
+			delete(h.tokens, key)
+			continue
+		}
+		c := *token
+		tokens = append(tokens, &c)
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].CreatedAt.Before(tokens[j].CreatedAt)
+	})
+	return tokens
+}
+
+// Revoke 吊销令牌
+func (h *Hub) Revoke(token string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, exists := h.tokens[token]; !exists {
+		return errors.New("token not found")
+	}
+	delete(h.tokens, token)
+	return nil
+}
+
+// Valid 判断令牌是否存在且未过期
+func (h *Hub) Valid(token string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	t, exists := h.tokens[token]
+	return exists && !t.expired(time.Now())
+}
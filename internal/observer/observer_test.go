@@ -0,0 +1,36 @@
+package observer
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTokens(t *testing.T) {
+	hub := New()
+	token, err := hub.IssueToken("pair", 0)
+	if err != nil {
+		t.Fatalf("failed to issue token: %v", err)
+	}
+	if !hub.Valid(token.Token) {
+		t.Error("expected issued token to be valid")
+	}
+	if hub.Valid("unknown") {
+		t.Error("expected unknown token to be invalid")
+	}
+
+	expired, _ := hub.IssueToken("old", time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	if hub.Valid(expired.Token) {
+		t.Error("expected expired token to be invalid")
+	}
+	if tokens := hub.Tokens(); len(tokens) != 1 || tokens[0].Name != "pair" {
+		t.Errorf("expected only the pair token, got %+v", tokens)
+	}
+
+	if err := hub.Revoke(token.Token); err != nil {
+		t.Fatalf("failed to revoke token: %v", err)
+	}
+	if hub.Valid(token.Token) {
+		t.Error("expected revoked token to be invalid")
+	}
+}
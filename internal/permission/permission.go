@@ -0,0 +1,215 @@
+// Package permission 定义 agent 动作的权限协议：执行前按工作区的策略规则放行或拒绝，
+// 规则要求询问时通过事件总线向插件发出权限请求，插件回复允许一次、始终允许或拒绝后继续执行
+package permission
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/liangsj/vimcoplit/internal/events"
+)
+
+// Operation 表示需要权限的操作类型
+type Operation string
+
+const (
+	OpCommand   Operation = "command"    // 执行命令，目标为完整命令行
+	OpWriteFile Operation = "write_file" // 写文件，目标为文件路径
+	OpTool      Operation = "tool"       // 调用 MCP 工具，目标为工具 ID
+)
+
+// Risk 表示操作的风险等级
+type Risk string
+
+const (
+	RiskLow    Risk = "low"
+	RiskMedium Risk = "medium"
+	RiskHigh   Risk = "high"
+)
+
+// DefaultRisk 返回操作类型的默认风险等级，Scorer 命中规则时提高到 high
+func DefaultRisk(op Operation) Risk {
+	switch op {
+	case OpCommand, OpTool:
+		return RiskMedium
+	}
+	return RiskLow
+}
+
+// Decision 是策略规则的处理方式
+type Decision string
+
+const (
+	DecisionAllow Decision = "allow" // 直接执行
+	DecisionDeny  Decision = "deny"  // 拒绝执行
+	DecisionAsk   Decision = "ask"   // 发出权限请求，等待插件回复
+)
+
+// Rule 是一条策略规则。Operation 为 * 时匹配所有操作，Target 为 glob 模式，
+// 以 * 结尾时按前缀匹配（可以跨越 /），为空或 * 时匹配所有目标
+type Rule struct {
+	Operation Operation `json:"operation"`
+	Target    string    `json:"target"`
+	Decision  Decision  `json:"decision"`
+}
+
+// Validate 检查规则是否有效
+func (r *Rule) Validate() error {
+	switch r.Operation {
+	case OpCommand, OpWriteFile, OpTool, "*":
+	default:
+		return fmt.Errorf("invalid permission operation %q", r.Operation)
+	}
+	switch r.Decision {
+	case DecisionAllow, DecisionDeny, DecisionAsk:
+	default:
+		return fmt.Errorf("invalid permission decision %q", r.Decision)
+	}
+	if _, err := path.Match(r.Target, ""); err != nil {
+		return fmt.Errorf("invalid permission target %q: %v", r.Target, err)
+	}
+	return nil
+}
+
+// matches 判断规则是否匹配操作和目标
+func (r *Rule) matches(op Operation, target string) bool {
+	if r.Operation != "*" && r.Operation != op {
+		return false
+	}
+	if r.Target == "" || r.Target == "*" || r.Target == target {
+		return true
+	}
+	if matched, _ := path.Match(r.Target, target); matched {
+		return true
+	}
+	prefix, ok := strings.CutSuffix(r.Target, "*")
+	return ok && !strings.ContainsAny(prefix, "*?[") && strings.HasPrefix(target, prefix)
+}
+
+// Evaluate 按顺序查找第一条匹配的规则，没有匹配的规则时 ok 为 false
+func Evaluate(rules []Rule, op Operation, target string) (decision Decision, ok bool) {
+	for i := range rules {
+		if rules[i].matches(op, target) {
+			return rules[i].Decision, true
+		}
+	}
+	return "", false
+}
+
+// Answer 是插件对权限请求的回复
+type Answer string
+
+const (
+	AnswerAllowOnce   Answer = "allow_once"   // 只允许这一次
+	AnswerAllowAlways Answer = "allow_always" // 允许并保存为策略规则，之后相同的操作不再询问
+	AnswerDeny        Answer = "deny"         // 拒绝，步骤失败
+)
+
+// Valid 判断回复是否有效
+func (a Answer) Valid() bool {
+	return a == AnswerAllowOnce || a == AnswerAllowAlways || a == AnswerDeny
+}
+
+// Request 是发给插件的权限请求
+type Request struct {
+	ID        string    `json:"id"`
+	RunID     string    `json:"run_id,omitempty"`
+	StepID    string    `json:"step_id,omitempty"`
+	Operation Operation `json:"operation"`
+	Target    string    `json:"target"`
+	Risk      Risk      `json:"risk"`
+	Diff      string    `json:"diff,omitempty"` // 写文件时的修改预览
+	Status    string    `json:"status"`         // requested 或 answered
+	Answer    Answer    `json:"answer,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ErrNotFound 表示权限请求不存在或已经回复
+var ErrNotFound = errors.New("permission request not found")
+
+// pending 是等待回复的权限请求
+type pending struct {
+	req *Request
+	ch  chan Answer
+}
+
+// Broker 管理等待回复的权限请求，请求和回复都以 permission 事件发布到事件总线
+type Broker struct {
+	mu      sync.Mutex
+	bus     *events.Bus
+	pending map[string]*pending
+}
+
+// NewBroker 创建权限请求管理器，bus 为 nil 时不发布事件
+func NewBroker(bus *events.Bus) *Broker {
+	return &Broker{bus: bus, pending: make(map[string]*pending)}
+}
+
+// Ask 发出权限请求并等待回复，ctx 结束时撤回请求并返回 ctx 的错误
+func (b *Broker) Ask(ctx context.Context, req *Request) (Answer, error) {
+	req.ID = uuid.New().String()
+	req.Status = "requested"
+	req.CreatedAt = time.Now()
+	p := &pending{req: req, ch: make(chan Answer, 1)}
+
+	b.mu.Lock()
+	b.pending[req.ID] = p
+	c := *req
+	b.mu.Unlock()
+	b.bus.Publish(events.TypePermission, &c)
+
+	select {
+	case answer := <-p.ch:
+		return answer, nil
+	case <-ctx.Done():
+		b.mu.Lock()
+		delete(b.pending, req.ID)
+		b.mu.Unlock()
+		return "", ctx.Err()
+	}
+}
+
+// Respond 回复权限请求，返回已回复的请求
+func (b *Broker) Respond(id string, answer Answer) (*Request, error) {
+	if !answer.Valid() {
+		return nil, fmt.Errorf("invalid permission answer %q", answer)
+	}
+	b.mu.Lock()
+	p, ok := b.pending[id]
+	if ok {
+		delete(b.pending, id)
+	}
+	b.mu.Unlock()
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	c := *p.req
+	c.Status = "answered"
+	c.Answer = answer
+	p.ch <- answer
+	b.bus.Publish(events.TypePermission, &c)
+	return &c, nil
+}
+
+// Pending 返回等待回复的权限请求，按创建时间排序，插件重连后据此补发提示
+func (b *Broker) Pending() []*Request {
+	b.mu.Lock()
+	reqs := make([]*Request, 0, len(b.pending))
+	for _, p := range b.pending {
+		c := *p.req
+		reqs = append(reqs, &c)
+	}
+	b.mu.Unlock()
+	sort.Slice(reqs, func(i, j int) bool {
+		return reqs[i].CreatedAt.Before(reqs[j].CreatedAt)
+	})
+	return reqs
+}
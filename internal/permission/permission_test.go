@@ -0,0 +1,100 @@
+package permission
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/liangsj/vimcoplit/internal/events"
+)
+
+func TestEvaluate(t *testing.T) {
+	rules := []Rule{
+		{Operation: OpCommand, Target: "go test*", Decision: DecisionAllow},
+		{Operation: OpWriteFile, Target: ".github/*", Decision: DecisionDeny},
+		{Operation: "*", Target: "*", Decision: DecisionAsk},
+	}
+	for _, tc := range []struct {
+		op       Operation
+		target   string
+		decision Decision
+	}{
+		{OpCommand, "go test ./...", DecisionAllow},
+		{OpCommand, "rm -rf /", DecisionAsk},
+		{OpWriteFile, ".github/ci.yml", DecisionDeny},
+		{OpTool, "server/echo", DecisionAsk},
+	} {
+		if got, ok := Evaluate(rules, tc.op, tc.target); !ok || got != tc.decision {
+			t.Errorf("%s %q: expected %s, got %s", tc.op, tc.target, tc.decision, got)
+		}
+	}
+	if _, ok := Evaluate(rules[:2], OpTool, "x"); ok {
+		t.Error("expected no match")
+	}
+
+	for _, bad := range []Rule{
+		{Operation: "delete", Decision: DecisionAllow},
+		{Operation: OpCommand, Decision: "maybe"},
+		{Operation: OpCommand, Target: "[", Decision: DecisionAllow},
+	} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("expected %+v to be invalid", bad)
+		}
+	}
+}
+
+func TestBrokerAskAndRespond(t *testing.T) {
+	bus := events.New(0)
+	_, ch, cancel := bus.Subscribe(0, events.TypePermission)
+	defer cancel()
+	broker := NewBroker(bus)
+
+	done := make(chan Answer)
+	go func() {
+		answer, err := broker.Ask(context.Background(), &Request{Operation: OpCommand, Target: "make", Risk: RiskHigh})
+		if err != nil {
+			t.Errorf("Ask: %v", err)
+		}
+		done <- answer
+	}()
+
+	var req *Request
+	select {
+	case event := <-ch:
+		req = event.Data.(*Request)
+	case <-time.After(time.Second):
+		t.Fatal("expected permission request event")
+	}
+	if req.Status != "requested" || req.Target != "make" || len(broker.Pending()) != 1 {
+		t.Fatalf("unexpected request %+v", req)
+	}
+
+	if _, err := broker.Respond(req.ID, "sure"); err == nil {
+		t.Error("expected invalid answer to be rejected")
+	}
+	if _, err := broker.Respond(req.ID, AnswerAllowOnce); err != nil {
+		t.Fatalf("Respond: %v", err)
+	}
+	if answer := <-done; answer != AnswerAllowOnce {
+		t.Errorf("expected allow_once, got %s", answer)
+	}
+	if event := <-ch; event.Data.(*Request).Status != "answered" {
+		t.Errorf("expected answered event, got %+v", event.Data)
+	}
+	if _, err := broker.Respond(req.ID, AnswerDeny); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for answered request, got %v", err)
+	}
+}
+
+func TestBrokerAskCanceled(t *testing.T) {
+	broker := NewBroker(nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := broker.Ask(ctx, &Request{Operation: OpTool, Target: "t"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline error, got %v", err)
+	}
+	if len(broker.Pending()) != 0 {
+		t.Error("expected canceled request to be withdrawn")
+	}
+}
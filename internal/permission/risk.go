@@ -0,0 +1,152 @@
+package permission
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// defaultMaxDeletedLines 是默认的大量删除阈值
+const defaultMaxDeletedLines = 50
+
+// sensitivePatterns 是 CI 配置和密钥文件，以 / 结尾的匹配整个目录
+var sensitivePatterns = []string{
+	".github/", ".gitlab/", ".circleci/", ".buildkite/",
+	".gitlab-ci.yml", ".travis.yml", "Jenkinsfile", "azure-pipelines.yml",
+	".env", ".env.*", "*.pem", "*.key", "*.p12", "id_rsa*", "id_ed25519*",
+	".npmrc", ".netrc", ".pypirc", "credentials*", "*secret*",
+}
+
+// Action 是需要评估风险的动作
+type Action struct {
+	Operation Operation
+	Target    string // 命令行、文件路径或工具 ID
+	Command   string // 命令的可执行文件
+	Current   string // 写文件前的内容，文件不存在时为空
+	Content   string // 写入的内容
+}
+
+// Assessment 是动作的风险评估结果，Reasons 说明把风险提高到 high 的原因
+type Assessment struct {
+	Level   Risk     `json:"level"`
+	Reasons []string `json:"reasons,omitempty"`
+}
+
+// Scorer 按规则评估动作的风险：写工作区外的文件、执行不在允许列表中的命令、
+// 大量删除内容以及修改 CI 配置或密钥文件都是高风险
+type Scorer struct {
+	Workspace       string
+	AllowedCmds     []string // 为空时不检查命令
+	MaxDeletedLines int      // 删除超过该行数视为大量删除，0 时使用 defaultMaxDeletedLines
+}
+
+// Score 评估动作的风险，没有命中规则时使用操作类型的默认等级
+func (s *Scorer) Score(a *Action) *Assessment {
+	var reasons []string
+	switch a.Operation {
+	case OpWriteFile:
+		rel, inside := s.relative(a.Target)
+		if !inside {
+			reasons = append(reasons, "file is outside the workspace")
+		}
+		if sensitive(rel) {
+			reasons = append(reasons, "touches a CI or secrets file")
+		}
+		max := s.MaxDeletedLines
+		if max <= 0 {
+			max = defaultMaxDeletedLines
+		}
+		if n := deletedLines(a.Current, a.Content); n > max {
+			reasons = append(reasons, fmt.Sprintf("deletes %d lines", n))
+		}
+	case OpCommand:
+		if !s.allowed(a.Command) {
+			reasons = append(reasons, fmt.Sprintf("command %q is not in the allowlist", a.Command))
+		}
+		for i, field := range strings.Fields(a.Target) {
+			if i > 0 && sensitive(strings.TrimPrefix(filepath.ToSlash(field), "./")) {
+				reasons = append(reasons, "touches a CI or secrets file")
+				break
+			}
+		}
+	}
+
+	assessment := &Assessment{Level: DefaultRisk(a.Operation), Reasons: reasons}
+	if len(reasons) > 0 {
+		assessment.Level = RiskHigh
+	}
+	return assessment
+}
+
+// relative 返回相对工作区的路径，inside 表示文件是否在工作区内
+func (s *Scorer) relative(target string) (rel string, inside bool) {
+	if s.Workspace == "" {
+		return filepath.ToSlash(target), true
+	}
+	abs := target
+	if !filepath.IsAbs(abs) {
+		abs = filepath.Join(s.Workspace, abs)
+	}
+	rel, err := filepath.Rel(s.Workspace, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return filepath.ToSlash(target), false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// allowed 判断命令是否在允许列表中，与命令执行时的检查一致
+func (s *Scorer) allowed(command string) bool {
+	return CommandAllowed(command, s.AllowedCmds)
+}
+
+// CommandAllowed 检查命令是否在允许列表中，列表为空时不做限制。带路径的命令
+// 只有在列表中写了同样的路径时才允许，否则 /tmp/evil/git 会被当作 git
+func CommandAllowed(command string, allowed []string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, a := range allowed {
+		if a == command {
+			return true
+		}
+	}
+	return false
+}
+
+// sensitive 判断路径是否为 CI 配置或密钥文件
+func sensitive(rel string) bool {
+	base := path.Base(rel)
+	for _, pattern := range sensitivePatterns {
+		if dir, ok := strings.CutSuffix(pattern, "/"); ok {
+			if strings.HasPrefix(rel, dir+"/") || strings.Contains(rel, "/"+dir+"/") {
+				return true
+			}
+			continue
+		}
+		if matched, _ := path.Match(pattern, base); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// deletedLines 统计 current 中在 content 里不再出现的行数
+func deletedLines(current, content string) int {
+	if current == "" {
+		return 0
+	}
+	remaining := make(map[string]int)
+	for _, line := range strings.Split(content, "\n") {
+		remaining[line]++
+	}
+	deleted := 0
+	for _, line := range strings.Split(current, "\n") {
+		if remaining[line] > 0 {
+			remaining[line]--
+			continue
+		}
+		deleted++
+	}
+	return deleted
+}
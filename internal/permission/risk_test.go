@@ -0,0 +1,57 @@
+package permission
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestScore(t *testing.T) {
+	s := &Scorer{Workspace: "/repo", AllowedCmds: []string{"go", "git"}, MaxDeletedLines: 3}
+	long := strings.Repeat("line\n", 10)
+
+	for _, tc := range []struct {
+		name   string
+		action Action
+		level  Risk
+		reason string
+	}{
+		{"workspace file", Action{Operation: OpWriteFile, Target: "main.go", Content: "package main\n"}, RiskLow, ""},
+		{"outside workspace", Action{Operation: OpWriteFile, Target: "/etc/hosts"}, RiskHigh, "outside the workspace"},
+		{"escaping path", Action{Operation: OpWriteFile, Target: "../other/x.go"}, RiskHigh, "outside the workspace"},
+		{"ci file", Action{Operation: OpWriteFile, Target: ".github/workflows/ci.yml"}, RiskHigh, "CI or secrets"},
+		{"secrets file", Action{Operation: OpWriteFile, Target: "/repo/config/.env"}, RiskHigh, "CI or secrets"},
+		{"large deletion", Action{Operation: OpWriteFile, Target: "a.txt", Current: long, Content: "line\n"}, RiskHigh, "deletes 9 lines"},
+		{"allowed command", Action{Operation: OpCommand, Target: "go test ./...", Command: "go"}, RiskMedium, ""},
+		{"unknown command", Action{Operation: OpCommand, Target: "curl example.com", Command: "curl"}, RiskHigh, `"curl" is not in the allowlist`},
+		{"command with a path", Action{Operation: OpCommand, Target: "/tmp/evil/git status", Command: "/tmp/evil/git"}, RiskHigh, `"/tmp/evil/git" is not in the allowlist`},
+		{"command on secrets", Action{Operation: OpCommand, Target: "git add ./id_rsa", Command: "git"}, RiskHigh, "CI or secrets"},
+		{"tool", Action{Operation: OpTool, Target: "server/echo"}, RiskMedium, ""},
+	} {
+		got := s.Score(&tc.action)
+		if got.Level != tc.level {
+			t.Errorf("%s: expected %s, got %+v", tc.name, tc.level, got)
+		}
+		if tc.reason != "" && !strings.Contains(strings.Join(got.Reasons, ";"), tc.reason) {
+			t.Errorf("%s: expected reason %q, got %v", tc.name, tc.reason, got.Reasons)
+		}
+	}
+}
+
+func TestCommandAllowed(t *testing.T) {
+	allowed := []string{"git", "/usr/local/bin/go"}
+	for command, want := range map[string]bool{
+		"git":               true,
+		"/tmp/evil/git":     false,
+		"./git":             false,
+		"/usr/local/bin/go": true,
+		"go":                false,
+		"curl":              false,
+	} {
+		if got := CommandAllowed(command, allowed); got != want {
+			t.Errorf("%s: expected %v, got %v", command, want, got)
+		}
+	}
+	if !CommandAllowed("/tmp/evil/git", nil) {
+		t.Error("expected an empty allowlist to allow every command")
+	}
+}
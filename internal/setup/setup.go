@@ -0,0 +1,204 @@
+// Package setup 实现首次运行的配置向导，命令行的 vimcoplit init 和 /api/setup 共用
+package setup
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/liangsj/vimcoplit/internal/config"
+	"github.com/liangsj/vimcoplit/internal/models"
+)
+
+// KeyStorage 表示 API Key 的保存方式
+type KeyStorage string
+
+const (
+	KeyStorageConfig KeyStorage = "config" // 直接写入配置文件
+	KeyStorageFile   KeyStorage = "file"   // 写入配置目录下单独的 api_key 文件，配置中只记录路径
+	KeyStorageEnv    KeyStorage = "env"    // 不保存，启动时从 VIMCOPLIT_API_KEY 读取
+)
+
+// Options 是配置向导的输入
+type Options struct {
+	Provider       models.ModelType `json:"provider"`
+	APIKey         string           `json:"api_key"`
+	KeyStorage     KeyStorage       `json:"key_storage"`
+	WorkspaceRoot  string           `json:"workspace_root"`
+	AllowedCmds    []string         `json:"allowed_cmds"`
+	SkipValidation bool             `json:"skip_validation"` // 跳过连通性检查，如离线时先写入配置
+}
+
+// Result 是配置向导的结果，连通性检查失败时不写入配置
+type Result struct {
+	ConfigPath string             `json:"config_path"`
+	KeyFile    string             `json:"key_file,omitempty"`
+	Written    bool               `json:"written"`
+	Test       *models.TestResult `json:"test,omitempty"`
+}
+
+// Status 描述当前的配置状态
+type Status struct {
+	Configured    bool             `json:"configured"` // 配置文件是否存在
+	ConfigPath    string           `json:"config_path"`
+	Provider      models.ModelType `json:"provider"`
+	HasAPIKey     bool             `json:"has_api_key"`
+	WorkspaceRoot string           `json:"workspace_root"`
+	AllowedCmds   []string         `json:"allowed_cmds"`
+}
+
+// CurrentStatus 返回已加载配置的状态
+func CurrentStatus(cfg *config.Config) *Status {
+	_, err := os.Stat(cfg.Path())
+	return &Status{
+		Configured:    err == nil,
+		ConfigPath:    cfg.Path(),
+		Provider:      cfg.Model.Type,
+		HasAPIKey:     cfg.Model.APIKey != "" || len(cfg.Model.APIKeys) > 0,
+		WorkspaceRoot: cfg.WorkspaceRoot(),
+		AllowedCmds:   cfg.Command.AllowedCmds,
+	}
+}
+
+// Defaults 返回向导的默认选项，基于 configPath 处已有的配置
+func Defaults(configPath string) (*Options, error) {
+	cfg, err := readConfig(configPath)
+	if err != nil {
+		return nil, err
+	}
+	root := cfg.Workspace.Root
+	if root == "" {
+		root, _ = os.Getwd()
+	}
+	return &Options{
+		Provider:      cfg.Model.Type,
+		KeyStorage:    KeyStorageFile,
+		WorkspaceRoot: root,
+		AllowedCmds:   cfg.Command.AllowedCmds,
+	}, nil
+}
+
+// Run 校验选项并检查与模型提供商的连通性，通过后将配置写入 configPath，已有配置中的其他字段保持不变。
+// 返回的配置中 APIKey 为实际使用的 Key，可直接应用到正在运行的服务
+func Run(ctx context.Context, configPath string, opts *Options) (*Result, *config.Config, error) {
+	if configPath == "" {
+		configPath = config.DefaultPath()
+	}
+	if err := validate(opts); err != nil {
+		return nil, nil, err
+	}
+	cfg, err := readConfig(configPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	apiKey := opts.APIKey
+	if opts.KeyStorage == KeyStorageEnv && apiKey == "" {
+		apiKey = os.Getenv("VIMCOPLIT_API_KEY")
+	}
+	result := &Result{ConfigPath: configPath}
+	if !opts.SkipValidation {
+		defaults := cfg.ModelDefaults(opts.Provider)
+		model, err := models.NewModel(models.ModelConfig{
+			APIKey:      apiKey,
+			ModelType:   opts.Provider,
+			MaxTokens:   defaults.MaxTokens,
+			Temperature: defaults.Temperature,
+			TopP:        defaults.TopP,
+			Stop:        defaults.Stop,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		result.Test = models.SelfTest(ctx, model, apiKey != "" || opts.Provider.IsLocal())
+		if !result.Test.OK {
+			return result, nil, fmt.Errorf("connectivity check failed: %s", result.Test.Error)
+		}
+	}
+
+	cfg.Model.Type = opts.Provider
+	cfg.Model.APIKey = ""
+	cfg.Model.APIKeyFile = ""
+	switch opts.KeyStorage {
+	case KeyStorageConfig:
+		cfg.Model.APIKey = apiKey
+	case KeyStorageFile:
+		result.KeyFile = filepath.Join(filepath.Dir(configPath), "api_key")
+		if err := os.MkdirAll(filepath.Dir(result.KeyFile), 0755); err != nil {
+			return nil, nil, err
+		}
+		if err := os.WriteFile(result.KeyFile, []byte(apiKey+"\n"), 0600); err != nil {
+			return nil, nil, fmt.Errorf("failed to write API key file: %v", err)
+		}
+		cfg.Model.APIKeyFile = result.KeyFile
+	}
+	if opts.WorkspaceRoot != "" {
+		root, err := filepath.Abs(opts.WorkspaceRoot)
+		if err != nil {
+			return nil, nil, err
+		}
+		cfg.Workspace.Root = root
+	}
+	if opts.AllowedCmds != nil {
+		cfg.Command.AllowedCmds = opts.AllowedCmds
+	}
+
+	if err := config.SaveConfig(configPath, cfg); err != nil {
+		return nil, nil, err
+	}
+	result.Written = true
+	cfg.Model.APIKey = apiKey
+	return result, cfg, nil
+}
+
+// validate 检查向导选项
+func validate(opts *Options) error {
+	supported := false
+	for _, t := range models.SupportedModelTypes() {
+		if t == opts.Provider {
+			supported = true
+		}
+	}
+	if !supported {
+		return fmt.Errorf("unsupported provider %q", opts.Provider)
+	}
+	switch opts.KeyStorage {
+	case "":
+		opts.KeyStorage = KeyStorageFile
+	case KeyStorageConfig, KeyStorageFile, KeyStorageEnv:
+	default:
+		return fmt.Errorf("invalid key storage %q", opts.KeyStorage)
+	}
+	if opts.APIKey == "" && opts.KeyStorage != KeyStorageEnv && !opts.Provider.IsLocal() {
+		return errors.New("API key is required")
+	}
+	if opts.WorkspaceRoot != "" {
+		if info, err := os.Stat(opts.WorkspaceRoot); err != nil || !info.IsDir() {
+			return fmt.Errorf("workspace root %s is not a directory", opts.WorkspaceRoot)
+		}
+	}
+	for i, cmd := range opts.AllowedCmds {
+		opts.AllowedCmds[i] = strings.TrimSpace(cmd)
+	}
+	return nil
+}
+
+// readConfig 读取 configPath 处的配置，不存在时返回默认配置，只包含该文件中的配置，不叠加其他配置层
+func readConfig(configPath string) (*config.Config, error) {
+	cfg := config.DefaultConfig()
+	data, err := os.ReadFile(configPath)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %v", err)
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %v", err)
+	}
+	return cfg, nil
+}
@@ -0,0 +1,75 @@
+package setup
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/liangsj/vimcoplit/internal/config"
+	"github.com/liangsj/vimcoplit/internal/models"
+)
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.json")
+	opts := &Options{
+		Provider:       models.ModelTypeDeepSeek,
+		APIKey:         "sk-test",
+		WorkspaceRoot:  dir,
+		AllowedCmds:    []string{"git", " make "},
+		SkipValidation: true,
+	}
+	result, cfg, err := Run(context.Background(), configPath, opts)
+	if err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+	if !result.Written || result.KeyFile != filepath.Join(dir, "api_key") || cfg.Model.APIKey != "sk-test" {
+		t.Errorf("unexpected result %+v", result)
+	}
+
+	saved, err := readConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.Model.APIKey != "" || saved.Model.APIKeyFile != result.KeyFile {
+		t.Errorf("expected API key to be stored in key file, got %+v", saved.Model)
+	}
+	if saved.Model.Type != models.ModelTypeDeepSeek || saved.Workspace.Root != dir || saved.Command.AllowedCmds[1] != "make" {
+		t.Errorf("unexpected saved config %+v", saved)
+	}
+	if key, _ := os.ReadFile(result.KeyFile); string(key) != "sk-test\n" {
+		t.Errorf("unexpected key file content %q", key)
+	}
+
+	// 已有配置中的其他字段保持不变
+	saved.Server.Port = 9999
+	config.SaveConfig(configPath, saved)
+	opts.KeyStorage = KeyStorageConfig
+	if _, _, err := Run(context.Background(), configPath, opts); err != nil {
+		t.Fatal(err)
+	}
+	saved, _ = readConfig(configPath)
+	if saved.Server.Port != 9999 || saved.Model.APIKey != "sk-test" || saved.Model.APIKeyFile != "" {
+		t.Errorf("unexpected config after rerun %+v", saved)
+	}
+}
+
+func TestRunInvalid(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.json")
+	tests := []*Options{
+		{Provider: "gpt-x", APIKey: "k"},
+		{Provider: models.ModelTypeClaude},
+		{Provider: models.ModelTypeClaude, APIKey: "k", KeyStorage: "keychain"},
+		{Provider: models.ModelTypeClaude, APIKey: "k", WorkspaceRoot: filepath.Join(t.TempDir(), "missing")},
+	}
+	for _, opts := range tests {
+		opts.SkipValidation = true
+		if _, _, err := Run(context.Background(), configPath, opts); err == nil {
+			t.Errorf("expected error for options %+v", opts)
+		}
+	}
+	if _, err := os.Stat(configPath); !os.IsNotExist(err) {
+		t.Error("expected invalid options not to write config")
+	}
+}
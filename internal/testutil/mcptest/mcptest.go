@@ -0,0 +1,240 @@
+// Package mcptest 提供 mcp.ToolManager 的一致性测试，任何工具管理器实现
+// （例如由远程服务支撑的实现）都应通过这些测试，以保持与 mcp.Manager 相同的行为约定
+package mcptest
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/liangsj/vimcoplit/internal/core/mcp"
+	"github.com/liangsj/vimcoplit/internal/testutil/mockmcp"
+)
+
+// Opener 打开以 dir 为存储位置的工具管理器。同一个 dir 多次打开时应读到之前持久化的状态，
+// 相当于进程重启后重新创建管理器
+type Opener func(t *testing.T, dir string) mcp.ToolManager
+
+// TestToolManager 对 open 创建的工具管理器运行一致性测试，约定包括：
+//   - AddServer 为空 ID 的服务器分配 ID，并写回 server.ID
+//   - 操作不存在的服务器或工具返回错误，不会 panic
+//   - 远程服务器健康检查失败时 StartServer 返回错误，服务器状态为 error
+//   - 服务器、自动批准和超时设置在重新打开后保留，重新打开后不会有服务器处于运行状态
+//   - 所有方法可以并发调用
+func TestToolManager(t *testing.T, open Opener) {
+	t.Run("ServerLifecycle", func(t *testing.T) { testServerLifecycle(t, open) })
+	t.Run("ErrorSemantics", func(t *testing.T) { testErrorSemantics(t, open) })
+	t.Run("Persistence", func(t *testing.T) { testPersistence(t, open) })
+	t.Run("Concurrency", func(t *testing.T) { testConcurrency(t, open) })
+}
+
+// remoteServer 启动模拟的远程 MCP 服务器
+func remoteServer(t *testing.T) (*mockmcp.Server, string) {
+	server := mockmcp.New()
+	ts := httptest.NewServer(server)
+	t.Cleanup(ts.Close)
+	return server, ts.URL
+}
+
+func testServerLifecycle(t *testing.T, open Opener) {
+	ctx := context.Background()
+	manager := open(t, t.TempDir())
+	mock, url := remoteServer(t)
+
+	server := &mcp.Server{Name: "remote", Type: mcp.ServerTypeRemote, URL: url}
+	if err := manager.AddServer(ctx, server); err != nil {
+		t.Fatalf("AddServer: %v", err)
+	}
+	if server.ID == "" {
+		t.Fatal("AddServer did not assign an id")
+	}
+
+	got, err := manager.GetServer(ctx, server.ID)
+	if err != nil || got.Name != "remote" || got.URL != url {
+		t.Fatalf("GetServer returned %+v %v", got, err)
+	}
+	servers, err := manager.ListServers(ctx)
+	if err != nil || len(servers) != 1 {
+		t.Fatalf("ListServers returned %d servers (%v)", len(servers), err)
+	}
+
+	if err := manager.StartServer(ctx, server.ID); err != nil {
+		t.Fatalf("StartServer: %v", err)
+	}
+	if got, _ := manager.GetServer(ctx, server.ID); got.Status != mcp.ServerStatusRunning {
+		t.Errorf("expected running after start, got %s", got.Status)
+	}
+	if err := manager.StopServer(ctx, server.ID); err != nil {
+		t.Fatalf("StopServer: %v", err)
+	}
+	if got, _ := manager.GetServer(ctx, server.ID); got.Status != mcp.ServerStatusStopped {
+		t.Errorf("expected stopped after stop, got %s", got.Status)
+	}
+
+	// 健康检查失败的服务器启动失败并标记为错误，恢复后可以再次启动
+	mock.SetHealthy(false)
+	if err := manager.StartServer(ctx, server.ID); err == nil {
+		t.Error("expected StartServer to fail for an unhealthy server")
+	}
+	if got, _ := manager.GetServer(ctx, server.ID); got.Status != mcp.ServerStatusError {
+		t.Errorf("expected error status after failed start, got %s", got.Status)
+	}
+	mock.SetHealthy(true)
+	if err := manager.StartServer(ctx, server.ID); err != nil {
+		t.Errorf("expected StartServer to succeed after recovery: %v", err)
+	}
+
+	if err := manager.RemoveServer(ctx, server.ID); err != nil {
+		t.Fatalf("RemoveServer: %v", err)
+	}
+	if _, err := manager.GetServer(ctx, server.ID); err == nil {
+		t.Error("expected removed server to be gone")
+	}
+}
+
+func testErrorSemantics(t *testing.T, open Opener) {
+	ctx := context.Background()
+	manager := open(t, t.TempDir())
+
+	if _, err := manager.GetServer(ctx, "missing"); err == nil {
+		t.Error("GetServer should fail for an unknown server")
+	}
+	if err := manager.RemoveServer(ctx, "missing"); err == nil {
+		t.Error("RemoveServer should fail for an unknown server")
+	}
+	if err := manager.StartServer(ctx, "missing"); err == nil {
+		t.Error("StartServer should fail for an unknown server")
+	}
+	if err := manager.StopServer(ctx, "missing"); err == nil {
+		t.Error("StopServer should fail for an unknown server")
+	}
+	if _, err := manager.GetTool(ctx, "missing"); err == nil {
+		t.Error("GetTool should fail for an unknown tool")
+	}
+	if _, err := manager.ExecuteTool(ctx, "missing", nil); err == nil {
+		t.Error("ExecuteTool should fail for an unknown tool")
+	}
+
+	server := &mcp.Server{Name: "bogus", Type: mcp.ServerType("bogus")}
+	if err := manager.AddServer(ctx, server); err != nil {
+		t.Fatalf("AddServer: %v", err)
+	}
+	if err := manager.StartServer(ctx, server.ID); err == nil {
+		t.Error("StartServer should fail for an unsupported server type")
+	}
+
+	if tools, err := manager.ListTools(ctx); err != nil {
+		t.Errorf("ListTools on an empty manager: %v", err)
+	} else {
+		for _, tool := range tools {
+			if tool.ServerID == server.ID {
+				t.Errorf("unexpected tool %s for a server without tools", tool.ID)
+			}
+		}
+	}
+}
+
+func testPersistence(t *testing.T, open Opener) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	_, url := remoteServer(t)
+
+	manager := open(t, dir)
+	server := &mcp.Server{ID: "persisted", Name: "persisted", Type: mcp.ServerTypeRemote, URL: url}
+	if err := manager.AddServer(ctx, server); err != nil {
+		t.Fatalf("AddServer: %v", err)
+	}
+	if err := manager.StartServer(ctx, server.ID); err != nil {
+		t.Fatalf("StartServer: %v", err)
+	}
+	if err := manager.SetAutoApprove(ctx, true); err != nil {
+		t.Fatalf("SetAutoApprove: %v", err)
+	}
+	if err := manager.SetTimeout(ctx, 7*time.Second); err != nil {
+		t.Fatalf("SetTimeout: %v", err)
+	}
+
+	reopened := open(t, dir)
+	got, err := reopened.GetServer(ctx, "persisted")
+	if err != nil {
+		t.Fatalf("server lost after reopen: %v", err)
+	}
+	if got.URL != url || got.Type != mcp.ServerTypeRemote {
+		t.Errorf("server changed after reopen: %+v", got)
+	}
+	// 重新打开的管理器没有启动过该服务器，不能报告为运行中
+	if got.Status == mcp.ServerStatusRunning {
+		t.Error("reopened manager reports a server it never started as running")
+	}
+	if !reopened.GetAutoApprove(ctx) {
+		t.Error("auto approve lost after reopen")
+	}
+	if timeout := reopened.GetTimeout(ctx); timeout != 7*time.Second {
+		t.Errorf("timeout lost after reopen, got %s", timeout)
+	}
+
+	if err := reopened.RemoveServer(ctx, "persisted"); err != nil {
+		t.Fatalf("RemoveServer: %v", err)
+	}
+	if _, err := open(t, dir).GetServer(ctx, "persisted"); err == nil {
+		t.Error("removed server came back after reopen")
+	}
+}
+
+func testConcurrency(t *testing.T, open Opener) {
+	ctx := context.Background()
+	manager := open(t, t.TempDir())
+
+	const workers = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			id := fmt.Sprintf("server-%d", i)
+			if err := manager.AddServer(ctx, &mcp.Server{ID: id, Name: id, Type: mcp.ServerTypeRemote}); err != nil {
+				errs <- err
+				return
+			}
+			if _, err := manager.GetServer(ctx, id); err != nil {
+				errs <- err
+				return
+			}
+			if _, err := manager.ListServers(ctx); err != nil {
+				errs <- err
+				return
+			}
+			if _, err := manager.ListTools(ctx); err != nil {
+				errs <- err
+				return
+			}
+			if err := manager.SetTimeout(ctx, time.Duration(i+1)*time.Second); err != nil {
+				errs <- err
+				return
+			}
+			manager.GetTimeout(ctx)
+			if i%2 == 0 {
+				if err := manager.RemoveServer(ctx, id); err != nil {
+					errs <- err
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("concurrent call failed: %v", err)
+	}
+
+	servers, err := manager.ListServers(ctx)
+	if err != nil {
+		t.Fatalf("ListServers: %v", err)
+	}
+	if len(servers) != workers/2 {
+		t.Errorf("expected %d servers after concurrent add/remove, got %d", workers/2, len(servers))
+	}
+}
@@ -0,0 +1,226 @@
+// Package mockmcp 提供按脚本响应的 MCP 远程服务器，用于集成测试和插件开发，
+// 不需要真实的工具服务
+package mockmcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/liangsj/vimcoplit/internal/core/mcp"
+)
+
+// Response 是工具的一条脚本响应
+type Response struct {
+	Result interface{} `json:"result,omitempty"`
+	// Error 不为空时返回 {"error": Error}，Status 为 0 时使用 500
+	Error  string `json:"error,omitempty"`
+	Status int    `json:"status,omitempty"`
+	// DelayMS 是响应前的等待时间，用于测试超时
+	DelayMS int `json:"delay_ms,omitempty"`
+	// Malformed 为 true 时返回无法解析的响应体
+	Malformed bool `json:"malformed,omitempty"`
+}
+
+// ToolSpec 描述服务器提供的工具及其脚本响应，没有脚本时原样返回参数，
+// 脚本用完后重复最后一条响应
+type ToolSpec struct {
+	mcp.Tool
+	Responses []Response `json:"responses,omitempty"`
+}
+
+// Call 是服务器收到的一次工具调用
+type Call struct {
+	ToolID string                 `json:"tool_id"`
+	Params map[string]interface{} `json:"params"`
+}
+
+// Server 是 MCP 远程服务器的模拟实现：
+// GET /health 检查健康状态，GET /tools 列出工具，POST /tools/{id} 调用工具
+type Server struct {
+	mu      sync.Mutex
+	specs   map[string]*ToolSpec
+	calls   []Call
+	healthy bool
+}
+
+// New 创建提供 specs 中工具的服务器
+func New(specs ...ToolSpec) *Server {
+	s := &Server{specs: make(map[string]*ToolSpec), healthy: true}
+	for i := range specs {
+		spec := specs[i]
+		s.specs[spec.ID] = &spec
+	}
+	return s
+}
+
+// LoadSpecs 从 JSON 文件读取 ToolSpec 数组
+func LoadSpecs(path string) ([]ToolSpec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var specs []ToolSpec
+	if err := json.Unmarshal(data, &specs); err != nil {
+		return nil, fmt.Errorf("failed to parse tool specs: %v", err)
+	}
+	return specs, nil
+}
+
+// SetHealthy 设置健康检查的结果，不健康时 /health 返回 503
+func (s *Server) SetHealthy(healthy bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.healthy = healthy
+}
+
+// Calls 返回服务器收到的所有工具调用
+func (s *Server) Calls() []Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Call(nil), s.calls...)
+}
+
+// Tools 返回服务器提供的工具，endpoint 元数据指向 baseURL 下的调用地址，按 ID 排序
+func (s *Server) Tools(baseURL string) []*mcp.Tool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tools := make([]*mcp.Tool, 0, len(s.specs))
+	for _, spec := range s.specs {
+		tool := spec.Tool
+		tool.Metadata = map[string]string{}
+		for k, v := range spec.Metadata {
+			tool.Metadata[k] = v
+		}
+		tool.Metadata["endpoint"] = strings.TrimSuffix(baseURL, "/") + "/tools/" + tool.ID
+		tools = append(tools, &tool)
+	}
+	sort.Slice(tools, func(i, j int) bool { return tools[i].ID < tools[j].ID })
+	return tools
+}
+
+// WriteConfig 将以 serverID 注册、地址为 baseURL 的远程服务器及其工具写入 MCP 配置文件，
+// mcp.Manager 调用 Recover 后即可加载
+func (s *Server) WriteConfig(path, serverID, baseURL string) error {
+	now := time.Now()
+	server := &mcp.Server{
+		ID:        serverID,
+		Name:      serverID,
+		URL:       strings.TrimSuffix(baseURL, "/"),
+		Type:      mcp.ServerTypeRemote,
+		Status:    mcp.ServerStatusStopped,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	tools := make(map[string]*mcp.Tool)
+	for _, tool := range s.Tools(baseURL) {
+		tool.ServerID = serverID
+		tool.CreatedAt = now
+		tool.UpdatedAt = now
+		server.Tools = append(server.Tools, *tool)
+		tools[tool.ID] = tool
+	}
+
+	data, err := json.MarshalIndent(map[string]interface{}{
+		"servers": map[string]*mcp.Server{serverID: server},
+		"tools":   tools,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/health":
+		s.mu.Lock()
+		healthy := s.healthy
+		s.mu.Unlock()
+		if !healthy {
+			http.Error(w, "unhealthy", http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	case r.URL.Path == "/tools" && r.Method == "GET":
+		json.NewEncoder(w).Encode(s.Tools("http://" + r.Host))
+	case strings.HasPrefix(r.URL.Path, "/tools/") && r.Method == "POST":
+		s.handleCall(w, r, strings.TrimPrefix(r.URL.Path, "/tools/"))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// handleCall 记录调用并返回工具的下一条脚本响应
+func (s *Server) handleCall(w http.ResponseWriter, r *http.Request, toolID string) {
+	var params map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid params"})
+		return
+	}
+
+	resp, ok := s.next(toolID, params)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "tool not found"})
+		return
+	}
+	if resp == nil {
+		json.NewEncoder(w).Encode(params)
+		return
+	}
+
+	if resp.DelayMS > 0 {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(time.Duration(resp.DelayMS) * time.Millisecond):
+		}
+	}
+	status := resp.Status
+	if status == 0 && resp.Error != "" {
+		status = http.StatusInternalServerError
+	}
+	if status != 0 {
+		w.WriteHeader(status)
+	}
+	switch {
+	case resp.Malformed:
+		w.Write([]byte("{"))
+	case resp.Error != "":
+		json.NewEncoder(w).Encode(map[string]string{"error": resp.Error})
+	default:
+		json.NewEncoder(w).Encode(resp.Result)
+	}
+}
+
+// next 记录调用并取出工具的下一条响应，工具没有脚本时返回 nil
+func (s *Server) next(toolID string, params map[string]interface{}) (*Response, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	spec, exists := s.specs[toolID]
+	if !exists {
+		return nil, false
+	}
+	s.calls = append(s.calls, Call{ToolID: toolID, Params: params})
+	if len(spec.Responses) == 0 {
+		return nil, true
+	}
+	resp := spec.Responses[0]
+	if len(spec.Responses) > 1 {
+		spec.Responses = spec.Responses[1:]
+	}
+	return &resp, true
+}
@@ -0,0 +1,97 @@
+package mockmcp
+
+import (
+	"context"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/liangsj/vimcoplit/internal/core/mcp"
+)
+
+// startManager 启动模拟服务器并返回加载了其工具的 mcp.Manager
+func startManager(t *testing.T, server *Server) *mcp.Manager {
+	t.Helper()
+	ts := httptest.NewServer(server)
+	t.Cleanup(ts.Close)
+
+	path := filepath.Join(t.TempDir(), "mcp.json")
+	if err := server.WriteConfig(path, "mock", ts.URL); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	manager := mcp.NewManager(path)
+	ctx := context.Background()
+	if _, err := manager.Recover(ctx); err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	if err := manager.StartServer(ctx, "mock"); err != nil {
+		t.Fatalf("failed to start server: %v", err)
+	}
+	return manager
+}
+
+func TestManagerAgainstMockServer(t *testing.T) {
+	server := New(
+		ToolSpec{Tool: mcp.Tool{ID: "echo", Parameters: []mcp.ToolParameter{{Name: "text", Type: "string"}}}},
+		ToolSpec{Tool: mcp.Tool{ID: "flaky"}, Responses: []Response{
+			{Error: "backend down", Status: 502},
+			{Malformed: true},
+			{Result: map[string]interface{}{"ok": true}},
+		}},
+	)
+	manager := startManager(t, server)
+	ctx := context.Background()
+
+	tools, err := manager.ListTools(ctx)
+	if err != nil || len(tools) != 2 {
+		t.Fatalf("expected 2 tools, got %d (%v)", len(tools), err)
+	}
+
+	result, err := manager.ExecuteTool(ctx, "echo", map[string]interface{}{"text": "hi"})
+	if err != nil || result.Status != string(mcp.ToolExecutionStatusSuccess) {
+		t.Fatalf("unexpected echo result %+v %v", result, err)
+	}
+	if len(result.Content) != 1 || result.Content[0].Type != mcp.ContentJSON || result.Text() != `{"text":"hi"}` {
+		t.Errorf("expected params to be echoed, got %+v", result.Content)
+	}
+
+	result, _ = manager.ExecuteTool(ctx, "flaky", nil)
+	if result.Status != string(mcp.ToolExecutionStatusError) || result.Error != "backend down" {
+		t.Errorf("expected scripted error, got %+v", result)
+	}
+	result, _ = manager.ExecuteTool(ctx, "flaky", nil)
+	if result.Status != string(mcp.ToolExecutionStatusError) {
+		t.Errorf("expected decode error for malformed response, got %+v", result)
+	}
+	for i := 0; i < 2; i++ {
+		result, _ = manager.ExecuteTool(ctx, "flaky", nil)
+		if result.Status != string(mcp.ToolExecutionStatusSuccess) {
+			t.Errorf("expected last response to repeat, got %+v", result)
+		}
+	}
+
+	if calls := server.Calls(); len(calls) != 5 || calls[0].ToolID != "echo" {
+		t.Errorf("unexpected recorded calls %+v", calls)
+	}
+}
+
+func TestUnhealthyMockServer(t *testing.T) {
+	server := New(ToolSpec{Tool: mcp.Tool{ID: "echo"}})
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	path := filepath.Join(t.TempDir(), "mcp.json")
+	if err := server.WriteConfig(path, "mock", ts.URL); err != nil {
+		t.Fatal(err)
+	}
+	manager := mcp.NewManager(path)
+	ctx := context.Background()
+	if _, err := manager.Recover(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	server.SetHealthy(false)
+	if err := manager.StartServer(ctx, "mock"); err == nil {
+		t.Error("expected unhealthy server to fail to start")
+	}
+}
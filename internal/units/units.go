@@ -0,0 +1,149 @@
+// Package units 提供以可读字符串表示的时长和大小类型，用于配置文件和 API，
+// 例如 "30s"、"5m"、"10MB"。为兼容旧的配置，也接受 JSON 数字
+package units
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Duration 是以 "30s"、"1h30m" 等字符串表示的时长，JSON 数字按秒解析
+type Duration time.Duration
+
+// Std 返回 time.Duration
+func (d Duration) Std() time.Duration {
+	return time.Duration(d)
+}
+
+// String 返回可读的时长，省略末尾为零的单位，例如 "5m" 而不是 "5m0s"
+func (d Duration) String() string {
+	s := time.Duration(d).String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
+
+// MarshalText 实现 encoding.TextMarshaler
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalText 解析 time.ParseDuration 格式的时长，不带单位的数字按秒解析
+func (d *Duration) UnmarshalText(text []byte) error {
+	s := strings.TrimSpace(string(text))
+	if n, err := strconv.ParseFloat(s, 64); err == nil {
+		*d = Duration(n * float64(time.Second))
+		return nil
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("invalid duration %q", s)
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// UnmarshalJSON 接受字符串或按秒计的数字
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	return unmarshalJSON(data, d.UnmarshalText)
+}
+
+// Size 是以 "512KB"、"10MB" 等字符串表示的字节数，单位按 1024 进位，JSON 数字按字节解析
+type Size int64
+
+// 大小单位
+const (
+	Byte Size = 1
+	KB        = 1024 * Byte
+	MB        = 1024 * KB
+	GB        = 1024 * MB
+	TB        = 1024 * GB
+)
+
+// sizeUnits 按从大到小排列，格式化时使用能整除的最大单位
+var sizeUnits = []struct {
+	name string
+	size Size
+}{
+	{"TB", TB}, {"GB", GB}, {"MB", MB}, {"KB", KB}, {"B", Byte},
+}
+
+// String 返回可读的大小，例如 "10MB"，不能被 KB 整除时以字节表示
+func (s Size) String() string {
+	if s == 0 {
+		return "0B"
+	}
+	for _, u := range sizeUnits {
+		if s%u.size == 0 {
+			return strconv.FormatInt(int64(s/u.size), 10) + u.name
+		}
+	}
+	return strconv.FormatInt(int64(s), 10) + "B"
+}
+
+// MarshalText 实现 encoding.TextMarshaler
+func (s Size) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText 解析带单位的大小，单位不区分大小写，支持 B、K/KB/KiB、M/MB/MiB、G/GB/GiB、T/TB/TiB，
+// 不带单位的数字按字节解析
+func (s *Size) UnmarshalText(text []byte) error {
+	str := strings.TrimSpace(string(text))
+	i := len(str)
+	for i > 0 && (str[i-1] < '0' || str[i-1] > '9') {
+		i--
+	}
+	number, unit := strings.TrimSpace(str[:i]), strings.ToUpper(strings.TrimSpace(str[i:]))
+	unit = strings.TrimSuffix(strings.Replace(unit, "IB", "B", 1), "B")
+
+	var multiplier Size
+	switch unit {
+	case "":
+		multiplier = Byte
+	case "K":
+		multiplier = KB
+	case "M":
+		multiplier = MB
+	case "G":
+		multiplier = GB
+	case "T":
+		multiplier = TB
+	default:
+		return fmt.Errorf("invalid size %q", str)
+	}
+	n, err := strconv.ParseFloat(number, 64)
+	if err != nil || n < 0 {
+		return fmt.Errorf("invalid size %q", str)
+	}
+	*s = Size(n * float64(multiplier))
+	return nil
+}
+
+// UnmarshalJSON 接受字符串或按字节计的数字
+func (s *Size) UnmarshalJSON(data []byte) error {
+	return unmarshalJSON(data, s.UnmarshalText)
+}
+
+// unmarshalJSON 把 JSON 字符串或数字交给 parse 解析
+func unmarshalJSON(data []byte, parse func([]byte) error) error {
+	if string(data) == "null" {
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		return parse([]byte(s))
+	}
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err != nil {
+		return fmt.Errorf("expected string or number, got %s", data)
+	}
+	return parse([]byte(n))
+}
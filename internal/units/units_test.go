@@ -0,0 +1,112 @@
+package units
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestDurationJSON(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+	}{
+		{`"30s"`, 30 * time.Second},
+		{`"1h30m"`, 90 * time.Minute},
+		{`"250ms"`, 250 * time.Millisecond},
+		{`30`, 30 * time.Second},
+		{`1.5`, 1500 * time.Millisecond},
+		{`"45"`, 45 * time.Second},
+	}
+	for _, tt := range tests {
+		var d Duration
+		if err := json.Unmarshal([]byte(tt.in), &d); err != nil {
+			t.Errorf("Unmarshal(%s): %v", tt.in, err)
+			continue
+		}
+		if d.Std() != tt.want {
+			t.Errorf("Unmarshal(%s) = %v, want %v", tt.in, d.Std(), tt.want)
+		}
+	}
+
+	for _, in := range []string{`"soon"`, `true`, `"10MB"`} {
+		var d Duration
+		if err := json.Unmarshal([]byte(in), &d); err == nil {
+			t.Errorf("expected error for %s", in)
+		}
+	}
+}
+
+func TestDurationString(t *testing.T) {
+	tests := map[Duration]string{
+		Duration(30 * time.Second):   "30s",
+		Duration(5 * time.Minute):    "5m",
+		Duration(2 * time.Hour):      "2h",
+		Duration(90 * time.Minute):   "1h30m",
+		Duration(61 * time.Second):   "1m1s",
+		Duration(0):                  "0s",
+		Duration(time.Millisecond):   "1ms",
+		Duration(3601 * time.Second): "1h0m1s",
+	}
+	for d, want := range tests {
+		data, err := json.Marshal(d)
+		if err != nil || string(data) != `"`+want+`"` {
+			t.Errorf("Marshal(%v) = %s %v, want %q", d.Std(), data, err, want)
+		}
+	}
+}
+
+func TestSizeJSON(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Size
+	}{
+		{`"10MB"`, 10 * MB},
+		{`"512KB"`, 512 * KB},
+		{`"512k"`, 512 * KB},
+		{`"1GiB"`, GB},
+		{`"1.5 MB"`, 1536 * KB},
+		{`"100B"`, 100},
+		{`"100"`, 100},
+		{`4000`, 4000},
+	}
+	for _, tt := range tests {
+		var s Size
+		if err := json.Unmarshal([]byte(tt.in), &s); err != nil {
+			t.Errorf("Unmarshal(%s): %v", tt.in, err)
+			continue
+		}
+		if s != tt.want {
+			t.Errorf("Unmarshal(%s) = %d, want %d", tt.in, s, tt.want)
+		}
+	}
+
+	for _, in := range []string{`"10XB"`, `"MB"`, `"-1KB"`, `false`} {
+		var s Size
+		if err := json.Unmarshal([]byte(in), &s); err == nil {
+			t.Errorf("expected error for %s", in)
+		}
+	}
+}
+
+func TestSizeString(t *testing.T) {
+	tests := map[Size]string{
+		0:         "0B",
+		100:       "100B",
+		4000:      "4000B",
+		4 * KB:    "4KB",
+		10 * MB:   "10MB",
+		64 * MB:   "64MB",
+		2 * GB:    "2GB",
+		1536 * KB: "1536KB",
+	}
+	for s, want := range tests {
+		if got := s.String(); got != want {
+			t.Errorf("String(%d) = %q, want %q", s, got, want)
+		}
+		var parsed Size
+		if err := parsed.UnmarshalText([]byte(want)); err != nil || parsed != s {
+			t.Errorf("round trip %q = %d %v, want %d", want, parsed, err, s)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+// Package update 实现从发布渠道检查、下载、校验并替换当前可执行文件的自更新
+package update
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// 发布渠道
+const (
+	ChannelStable = "stable"
+	ChannelBeta   = "beta"
+)
+
+// Asset 是某个平台的可执行文件
+type Asset struct {
+	OS        string `json:"os"`
+	Arch      string `json:"arch"`
+	URL       string `json:"url"`
+	SHA256    string `json:"sha256"`
+	Signature string `json:"signature,omitempty"` // 对 SignedData 的 ed25519 签名，base64 编码
+}
+
+// Release 是发布渠道的清单，保存在 <URL>/<channel>.json
+type Release struct {
+	Version string  `json:"version"`
+	Channel string  `json:"channel"`
+	Notes   string  `json:"notes,omitempty"`
+	Assets  []Asset `json:"assets"`
+}
+
+// ErrUnsigned 表示没有配置发布公钥，无法校验下载的可执行文件
+var ErrUnsigned = errors.New("no release public key is configured, refusing to install an unverified binary")
+
+// Updater 检查并安装更新
+type Updater struct {
+	URL       string            // 发布清单的根地址
+	Channel   string            // stable 或 beta
+	PublicKey ed25519.PublicKey // 校验可执行文件签名的公钥
+	Insecure  bool              // 为 true 时允许在没有公钥的情况下只校验摘要
+	Client    *http.Client
+}
+
+// SignedData 返回发布时签名的内容：版本、渠道和摘要以 | 连接，
+// 旧版本或其他渠道的签名不能用于冒充当前渠道的新版本
+func SignedData(release *Release, asset *Asset) []byte {
+	return []byte(release.Version + "|" + release.Channel + "|" + strings.ToLower(asset.SHA256))
+}
+
+// New 创建更新器，publicKey 为 base64 编码的 ed25519 公钥，可以为空
+func New(url, channel, publicKey string) (*Updater, error) {
+	switch channel {
+	case "":
+		channel = ChannelStable
+	case ChannelStable, ChannelBeta:
+	default:
+		return nil, fmt.Errorf("invalid update channel %q", channel)
+	}
+	u := &Updater{URL: strings.TrimSuffix(url, "/"), Channel: channel, Client: http.DefaultClient}
+	if publicKey != "" {
+		key, err := base64.StdEncoding.DecodeString(publicKey)
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid update public key")
+		}
+		u.PublicKey = key
+	}
+	return u, nil
+}
+
+// Check 获取渠道的最新版本，返回当前平台的可执行文件以及是否比 current 新
+func (u *Updater) Check(ctx context.Context, current string) (*Release, *Asset, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", u.URL+"/"+u.Channel+".json", nil)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	resp, err := u.Client.Do(req)
+	if err != nil {
+		return nil, nil, false, fmt.Errorf("failed to fetch release manifest: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, false, fmt.Errorf("failed to fetch release manifest: status %d", resp.StatusCode)
+	}
+
+	var release Release
+	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
+		return nil, nil, false, fmt.Errorf("invalid release manifest: %v", err)
+	}
+	if release.Channel != u.Channel {
+		return nil, nil, false, fmt.Errorf("release manifest is for channel %q, expected %q", release.Channel, u.Channel)
+	}
+	for i := range release.Assets {
+		asset := &release.Assets[i]
+		if asset.OS == runtime.GOOS && asset.Arch == runtime.GOARCH {
+			return &release, asset, Compare(release.Version, current) > 0, nil
+		}
+	}
+	return &release, nil, false, fmt.Errorf("release %s has no binary for %s/%s", release.Version, runtime.GOOS, runtime.GOARCH)
+}
+
+// Install 下载 release 中的可执行文件，校验签名和摘要后替换 target。没有公钥时返回 ErrUnsigned，
+// 除非设置了 Insecure。新文件先写入 target 所在目录的临时文件，校验通过后再重命名，失败时 target 保持不变
+func (u *Updater) Install(ctx context.Context, release *Release, asset *Asset, target string) error {
+	switch {
+	case u.PublicKey != nil:
+		sig, err := base64.StdEncoding.DecodeString(asset.Signature)
+		if err != nil || release.Channel != u.Channel || !ed25519.Verify(u.PublicKey, SignedData(release, asset), sig) {
+			return errors.New("release signature verification failed")
+		}
+	case !u.Insecure:
+		return ErrUnsigned
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", asset.URL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := u.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to download release: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to download release: status %d", resp.StatusCode)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(target), "."+filepath.Base(target)+".update-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, hash), resp.Body); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to download release: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if sum := hex.EncodeToString(hash.Sum(nil)); !strings.EqualFold(sum, asset.SHA256) {
+		return fmt.Errorf("checksum mismatch: expected %s, got %s", asset.SHA256, sum)
+	}
+	if err := os.Chmod(tmp.Name(), 0755); err != nil {
+		return err
+	}
+
+	// Windows 不能覆盖正在运行的可执行文件，先把旧文件移开
+	if runtime.GOOS == "windows" {
+		old := target + ".old"
+		os.Remove(old)
+		if err := os.Rename(target, old); err != nil {
+			return err
+		}
+		if err := os.Rename(tmp.Name(), target); err != nil {
+			os.Rename(old, target)
+			return err
+		}
+		return nil
+	}
+	return os.Rename(tmp.Name(), target)
+}
+
+// Compare 比较两个形如 v1.2.3 或 v1.3.0-beta.1 的版本号，a 较新时返回 1，较旧时返回 -1。
+// dev 等无法解析的版本视为最旧，预发布版本比对应的正式版本旧
+func Compare(a, b string) int {
+	pa, preA, okA := parseVersion(a)
+	pb, preB, okB := parseVersion(b)
+	switch {
+	case !okA && !okB:
+		return 0
+	case !okA:
+		return -1
+	case !okB:
+		return 1
+	}
+	for i := 0; i < 3; i++ {
+		if pa[i] != pb[i] {
+			if pa[i] > pb[i] {
+				return 1
+			}
+			return -1
+		}
+	}
+	switch {
+	case preA == preB:
+		return 0
+	case preA == "":
+		return 1
+	case preB == "":
+		return -1
+	case comparePrerelease(preA, preB) > 0:
+		return 1
+	}
+	return -1
+}
+
+// parseVersion 解析主、次、修订版本号和预发布标识
+func parseVersion(v string) ([3]int, string, bool) {
+	var parts [3]int
+	v = strings.TrimPrefix(v, "v")
+	v, pre, _ := strings.Cut(v, "-")
+	fields := strings.Split(v, ".")
+	if len(fields) == 0 || len(fields) > 3 {
+		return parts, "", false
+	}
+	for i, f := range fields {
+		n, err := strconv.Atoi(f)
+		if err != nil {
+			return parts, "", false
+		}
+		parts[i] = n
+	}
+	return parts, pre, true
+}
+
+// comparePrerelease 逐段比较预发布标识，数字段按数值比较
+func comparePrerelease(a, b string) int {
+	fa, fb := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(fa) && i < len(fb); i++ {
+		na, errA := strconv.Atoi(fa[i])
+		nb, errB := strconv.Atoi(fb[i])
+		switch {
+		case errA == nil && errB == nil && na != nb:
+			if na > nb {
+				return 1
+			}
+			return -1
+		case (errA != nil || errB != nil) && fa[i] != fb[i]:
+			if fa[i] > fb[i] {
+				return 1
+			}
+			return -1
+		}
+	}
+	return len(fa) - len(fb)
+}
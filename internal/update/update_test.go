@@ -0,0 +1,130 @@
+package update
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"v1.2.0", "v1.1.9", 1},
+		{"1.2.0", "v1.2.0", 0},
+		{"v1.2.0-beta.2", "v1.2.0", -1},
+		{"v1.2.0-beta.10", "v1.2.0-beta.2", 1},
+		{"v0.1.0", "dev", 1},
+		{"dev", "dev", 0},
+	}
+	for _, tt := range tests {
+		if got := Compare(tt.a, tt.b); got != tt.want {
+			t.Errorf("Compare(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestCheckAndInstall(t *testing.T) {
+	binary := []byte("new binary")
+	sum := sha256.Sum256(binary)
+	checksum := hex.EncodeToString(sum[:])
+	pub, priv, _ := ed25519.GenerateKey(nil)
+
+	sign := func(version, channel, sha string) string {
+		return base64.StdEncoding.EncodeToString(ed25519.Sign(priv, []byte(version+"|"+channel+"|"+sha)))
+	}
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/beta.json", "/stable.json":
+			// stable 渠道返回 beta 渠道的清单，模拟跨渠道重放
+			json.NewEncoder(w).Encode(Release{
+				Version: "v1.3.0-beta.1",
+				Channel: ChannelBeta,
+				Assets: []Asset{{
+					OS:        runtime.GOOS,
+					Arch:      runtime.GOARCH,
+					URL:       server.URL + "/vimcoplit",
+					SHA256:    checksum,
+					Signature: sign("v1.3.0-beta.1", ChannelBeta, checksum),
+				}},
+			})
+		case "/vimcoplit":
+			w.Write(binary)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	u, err := New(server.URL, ChannelBeta, base64.StdEncoding.EncodeToString(pub))
+	if err != nil {
+		t.Fatal(err)
+	}
+	release, asset, newer, err := u.Check(context.Background(), "v1.2.0")
+	if err != nil || !newer || release.Version != "v1.3.0-beta.1" {
+		t.Fatalf("unexpected check result %+v %v %v", release, newer, err)
+	}
+
+	target := filepath.Join(t.TempDir(), "vimcoplit")
+	os.WriteFile(target, []byte("old binary"), 0755)
+	if err := u.Install(context.Background(), release, asset, target); err != nil {
+		t.Fatalf("install failed: %v", err)
+	}
+	if data, _ := os.ReadFile(target); string(data) != "new binary" {
+		t.Errorf("expected binary to be replaced, got %q", data)
+	}
+
+	// 摘要或签名不匹配时不替换
+	os.WriteFile(target, []byte("old binary"), 0755)
+	bad := *asset
+	bad.SHA256 = hex.EncodeToString(make([]byte, 32))
+	bad.Signature = sign(release.Version, release.Channel, bad.SHA256)
+	if err := u.Install(context.Background(), release, &bad, target); err == nil {
+		t.Error("expected checksum mismatch")
+	}
+	bad = *asset
+	bad.Signature = ""
+	if err := u.Install(context.Background(), release, &bad, target); err == nil {
+		t.Error("expected signature verification to fail")
+	}
+	// 旧版本的签名不能用于新的版本号
+	relabeled := *release
+	relabeled.Version = "v9.0.0"
+	if err := u.Install(context.Background(), &relabeled, asset, target); err == nil {
+		t.Error("expected a signature for another version to be rejected")
+	}
+
+	// 没有公钥时只有 Insecure 才安装
+	unsigned, _ := New(server.URL, ChannelBeta, "")
+	if err := unsigned.Install(context.Background(), release, asset, target); !errors.Is(err, ErrUnsigned) {
+		t.Errorf("expected an unsigned install to be refused, got %v", err)
+	}
+	if data, _ := os.ReadFile(target); string(data) != "old binary" {
+		t.Errorf("expected binary to be unchanged, got %q", data)
+	}
+	unsigned.Insecure = true
+	if err := unsigned.Install(context.Background(), release, asset, target); err != nil {
+		t.Errorf("expected an insecure install to succeed, got %v", err)
+	}
+
+	stable, _ := New(server.URL, ChannelStable, base64.StdEncoding.EncodeToString(pub))
+	if _, _, _, err := stable.Check(context.Background(), "v1.2.0"); err == nil {
+		t.Error("expected a manifest for another channel to be rejected")
+	}
+	if _, err := New(server.URL, "nightly", ""); err == nil {
+		t.Error("expected invalid channel to be rejected")
+	}
+}
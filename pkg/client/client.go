@@ -0,0 +1,320 @@
+// Package client 是 vimcoplit HTTP API 的 Go 客户端，供自动化工具以编程方式驱动助手。
+// 客户端只依赖本包中定义的稳定类型，使用版本化的 /api/v1 路由
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// apiPrefix 是客户端使用的 API 路由前缀
+const apiPrefix = "/api/v1"
+
+// Client 是 vimcoplit HTTP API 的客户端，可以并发使用
+type Client struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+}
+
+// Option 配置客户端
+type Option func(*Client)
+
+// WithToken 设置请求携带的 Bearer 令牌，例如只读的观察者令牌
+func WithToken(token string) Option {
+	return func(c *Client) { c.token = token }
+}
+
+// WithHTTPClient 设置发送请求使用的 http.Client
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.httpClient = hc }
+}
+
+// WithHandler 将请求直接交给 h 处理而不经过网络，用于在进程内嵌入服务
+func WithHandler(h http.Handler) Option {
+	return func(c *Client) { c.httpClient = &http.Client{Transport: handlerTransport{h}} }
+}
+
+// handlerTransport 在进程内调用 http.Handler 的 RoundTripper
+type handlerTransport struct {
+	handler http.Handler
+}
+
+func (t handlerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rec := httptest.NewRecorder()
+	t.handler.ServeHTTP(rec, req)
+	resp := rec.Result()
+	resp.Request = req
+	return resp, nil
+}
+
+// New 创建连接到 baseURL（例如 http://localhost:8080）的客户端
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: http.DefaultClient,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// APIError 是服务返回的错误响应
+type APIError struct {
+	StatusCode int
+	Message    string
+	RetryAfter time.Duration // 被限流时服务建议的重试等待时间
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("vimcoplit: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// IsRateLimited 判断错误是否为模型提供商限流，此时应在 RetryAfter 之后重试
+func IsRateLimited(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusTooManyRequests
+}
+
+// IsUnavailable 判断错误是否为服务暂时不可用，例如离线或同时进行的会话数达到上限
+func IsUnavailable(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusServiceUnavailable
+}
+
+// do 发送请求并将 JSON 响应解码到 out，body 不为 nil 时以 JSON 发送
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out interface{}) error {
+	u := c.baseURL + apiPrefix + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request: %v", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, reader)
+	if err != nil {
+		return err
+	}
+	// 服务拒绝不带 JSON Content-Type 的修改请求，没有请求体时也需要设置
+	if body != nil || method != http.MethodGet {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		data, _ := io.ReadAll(resp.Body)
+		apiErr := &APIError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(data))}
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			apiErr.RetryAfter = time.Duration(seconds) * time.Second
+		}
+		return apiErr
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %v", err)
+	}
+	return nil
+}
+
+// Version 返回服务构建的版本信息
+func (c *Client) Version(ctx context.Context) (*Version, error) {
+	var v Version
+	if err := c.do(ctx, "GET", "/version", nil, nil, &v); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// Capabilities 返回服务支持的功能
+func (c *Client) Capabilities(ctx context.Context) (*Capabilities, error) {
+	var caps Capabilities
+	if err := c.do(ctx, "GET", "/capabilities", nil, nil, &caps); err != nil {
+		return nil, err
+	}
+	return &caps, nil
+}
+
+// ListModels 列出可选的模型及其能力
+func (c *Client) ListModels(ctx context.Context) ([]*ModelInfo, error) {
+	var list []*ModelInfo
+	if err := c.do(ctx, "GET", "/models", nil, nil, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// Generate 生成回复
+func (c *Client) Generate(ctx context.Context, req *GenerateRequest) (*GenerateResponse, error) {
+	var resp GenerateResponse
+	if err := c.do(ctx, "POST", "/generate", nil, req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// GenerateBatch 并发生成多个提示词的回复，返回按提示词 ID 索引的结果
+func (c *Client) GenerateBatch(ctx context.Context, req *BatchRequest) (map[string]*BatchResult, error) {
+	var resp struct {
+		Results map[string]*BatchResult `json:"results"`
+	}
+	if err := c.do(ctx, "POST", "/generate/batch", nil, req, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Results, nil
+}
+
+// CancelGeneration 取消进行中的生成请求，id 为 GenerateRequest.ID
+func (c *Client) CancelGeneration(ctx context.Context, id string) (*GenerationRecord, error) {
+	var record GenerationRecord
+	if err := c.do(ctx, "POST", "/generate/"+url.PathEscape(id)+"/cancel", nil, nil, &record); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// Complete 生成行内补全
+func (c *Client) Complete(ctx context.Context, req *CompletionRequest) (*Completion, error) {
+	var completion Completion
+	if err := c.do(ctx, "POST", "/complete", nil, req, &completion); err != nil {
+		return nil, err
+	}
+	return &completion, nil
+}
+
+// Execute 在工作区中执行命令
+func (c *Client) Execute(ctx context.Context, command string, args ...string) (*CommandResult, error) {
+	body := map[string]interface{}{"command": command, "args": args}
+	var result CommandResult
+	if err := c.do(ctx, "POST", "/execute", nil, body, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ReadFile 读取工作区中的文件
+func (c *Client) ReadFile(ctx context.Context, path string) (*File, error) {
+	var file File
+	if err := c.do(ctx, "GET", "/files", url.Values{"path": {path}}, nil, &file); err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+// WriteFile 写入工作区中的文件，BaseHash 对应的内容在期间被修改时服务会尝试自动合并，
+// 无法合并时返回 409 的 *APIError
+func (c *Client) WriteFile(ctx context.Context, edit *FileEdit) (*EditResult, error) {
+	var result EditResult
+	if err := c.do(ctx, "POST", "/files", nil, edit, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// CreateTask 创建后台任务，返回任务 ID
+func (c *Client) CreateTask(ctx context.Context, description string) (string, error) {
+	var resp struct {
+		TaskID string `json:"task_id"`
+	}
+	err := c.do(ctx, "POST", "/tasks", nil, map[string]string{"description": description}, &resp)
+	return resp.TaskID, err
+}
+
+// GetTask 返回任务
+func (c *Client) GetTask(ctx context.Context, id string) (*Task, error) {
+	var task Task
+	if err := c.do(ctx, "GET", "/tasks", url.Values{"id": {id}}, nil, &task); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// ListTasks 列出所有任务
+func (c *Client) ListTasks(ctx context.Context) ([]*Task, error) {
+	var tasks []*Task
+	if err := c.do(ctx, "GET", "/tasks", nil, nil, &tasks); err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+// CreateConversation 创建对话
+func (c *Client) CreateConversation(ctx context.Context, title string) (*Conversation, error) {
+	var conv Conversation
+	if err := c.do(ctx, "POST", "/conversations", nil, map[string]string{"title": title}, &conv); err != nil {
+		return nil, err
+	}
+	return &conv, nil
+}
+
+// GetConversation 返回包含所有消息的对话
+func (c *Client) GetConversation(ctx context.Context, id string) (*Conversation, error) {
+	var conv Conversation
+	if err := c.do(ctx, "GET", "/conversations", url.Values{"id": {id}}, nil, &conv); err != nil {
+		return nil, err
+	}
+	return &conv, nil
+}
+
+// ListConversations 列出所有对话，不包含消息
+func (c *Client) ListConversations(ctx context.Context) ([]*Conversation, error) {
+	var list []*Conversation
+	if err := c.do(ctx, "GET", "/conversations", nil, nil, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// RenameConversation 修改对话标题，之后服务端不会再自动生成标题
+func (c *Client) RenameConversation(ctx context.Context, id, title string) (*Conversation, error) {
+	var conv Conversation
+	body := map[string]string{"title": title}
+	if err := c.do(ctx, "PATCH", "/conversations", url.Values{"id": {id}}, body, &conv); err != nil {
+		return nil, err
+	}
+	return &conv, nil
+}
+
+// SendMessage 在对话中发送消息，返回用户消息之后的候选回复
+func (c *Client) SendMessage(ctx context.Context, conversationID string, req *MessageRequest) ([]*Message, error) {
+	var replies []*Message
+	if err := c.do(ctx, "POST", "/conversations/messages", url.Values{"id": {conversationID}}, req, &replies); err != nil {
+		return nil, err
+	}
+	return replies, nil
+}
+
+// ListCommands 返回聊天可用的斜杠命令，用于输入框补全
+func (c *Client) ListCommands(ctx context.Context) ([]*SlashCommand, error) {
+	var list []*SlashCommand
+	if err := c.do(ctx, "GET", "/commands", nil, nil, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
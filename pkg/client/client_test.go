@@ -0,0 +1,122 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClientRequests(t *testing.T) {
+	var gotAuth string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/generate", func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		var req GenerateRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Prompt != "hi" || req.Seed == nil || *req.Seed != 7 {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"id":       "g1",
+			"response": "hello",
+			"params":   map[string]interface{}{"model": "deepseek", "seed": 7},
+		})
+	})
+	mux.HandleFunc("/api/v1/conversations", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "PATCH" {
+			var req map[string]string
+			json.NewDecoder(r.Body).Decode(&req)
+			json.NewEncoder(w).Encode(map[string]string{"id": r.URL.Query().Get("id"), "title": req["title"]})
+			return
+		}
+		json.NewEncoder(w).Encode([]map[string]string{{"id": "c1", "title": "first"}})
+	})
+	mux.HandleFunc("/api/v1/commands", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode([]map[string]interface{}{{"name": "test", "template": "Write tests", "builtin": true}, {"name": "lint", "tool": "golangci"}})
+	})
+	mux.HandleFunc("/api/v1/generate/batch", func(w http.ResponseWriter, r *http.Request) {
+		var req BatchRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		results := map[string]*BatchResult{}
+		for _, p := range req.Prompts {
+			results[p.ID] = &BatchResult{ID: p.ID, Response: "summary of " + p.Prompt}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"results": results})
+	})
+
+	seed := int64(7)
+	for name, c := range map[string]*Client{
+		"http":    nil,
+		"handler": New("http://ignored", WithToken("secret"), WithHandler(mux)),
+	} {
+		t.Run(name, func(t *testing.T) {
+			if c == nil {
+				ts := httptest.NewServer(mux)
+				defer ts.Close()
+				c = New(ts.URL+"/", WithToken("secret"))
+			}
+			ctx := context.Background()
+			resp, err := c.Generate(ctx, &GenerateRequest{Prompt: "hi", Params: Params{Seed: &seed}})
+			if err != nil {
+				t.Fatalf("Generate: %v", err)
+			}
+			if resp.Response != "hello" || resp.Params.Model != "deepseek" || *resp.Params.Seed != 7 {
+				t.Errorf("unexpected response %+v", resp)
+			}
+			if gotAuth != "Bearer secret" {
+				t.Errorf("expected bearer token, got %q", gotAuth)
+			}
+
+			results, err := c.GenerateBatch(ctx, &BatchRequest{Prompts: []BatchPrompt{{ID: "a.go", Prompt: "a"}, {ID: "b.go", Prompt: "b"}}})
+			if err != nil || len(results) != 2 || results["b.go"].Response != "summary of b" {
+				t.Errorf("unexpected batch results %v %v", results, err)
+			}
+
+			list, err := c.ListConversations(ctx)
+			if err != nil || len(list) != 1 || list[0].Title != "first" {
+				t.Errorf("unexpected conversations %v %v", list, err)
+			}
+
+			conv, err := c.RenameConversation(ctx, "c1", "renamed")
+			if err != nil || conv.ID != "c1" || conv.Title != "renamed" {
+				t.Errorf("unexpected renamed conversation %+v %v", conv, err)
+			}
+
+			commands, err := c.ListCommands(ctx)
+			if err != nil || len(commands) != 2 || !commands[0].Builtin || commands[1].Tool != "golangci" {
+				t.Errorf("unexpected commands %v %v", commands, err)
+			}
+		})
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/generate":
+			w.Header().Set("Retry-After", "3")
+			http.Error(w, "rate limited", http.StatusTooManyRequests)
+		default:
+			http.Error(w, "offline", http.StatusServiceUnavailable)
+		}
+	}))
+	defer ts.Close()
+
+	c := New(ts.URL)
+	ctx := context.Background()
+	_, err := c.Generate(ctx, &GenerateRequest{Prompt: "hi"})
+	if !IsRateLimited(err) {
+		t.Fatalf("expected rate limit error, got %v", err)
+	}
+	if apiErr := err.(*APIError); apiErr.RetryAfter != 3*time.Second || apiErr.Message != "rate limited" {
+		t.Errorf("unexpected error details %+v", apiErr)
+	}
+
+	if _, err := c.Complete(ctx, &CompletionRequest{Path: "a.go"}); !IsUnavailable(err) {
+		t.Errorf("expected unavailable error, got %v", err)
+	}
+}
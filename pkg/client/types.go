@@ -0,0 +1,274 @@
+package client
+
+import "time"
+
+// 以下类型是 HTTP API 的稳定表示，与服务内部的类型分开定义，服务内部重构不会影响调用方。
+// 新增字段保持向后兼容，API 版本变化时才会删除或修改字段
+
+// Version 是服务构建的版本信息
+type Version struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	Date      string `json:"date"`
+	Modified  bool   `json:"modified,omitempty"`
+	GoVersion string `json:"go_version"`
+	Platform  string `json:"platform"`
+}
+
+// Capabilities 是服务支持的功能集合
+type Capabilities struct {
+	Version       string   `json:"version"`
+	APIVersion    string   `json:"api_version"`
+	APIPrefix     string   `json:"api_prefix"`
+	Streaming     bool     `json:"streaming"`
+	Embeddings    bool     `json:"embeddings"`
+	MCPTransports []string `json:"mcp_transports"`
+	Models        []string `json:"models"`
+	Features      []string `json:"features"`
+}
+
+// HasFeature 判断服务是否支持指定功能
+func (c *Capabilities) HasFeature(name string) bool {
+	for _, f := range c.Features {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Params 是单次生成的采样参数，为 nil 的字段使用服务配置中的默认值
+type Params struct {
+	Temperature   *float64 `json:"temperature,omitempty"`
+	Seed          *int64   `json:"seed,omitempty"`
+	Deterministic bool     `json:"deterministic,omitempty"`
+}
+
+// GenerationParams 是一次生成实际使用的完整参数
+type GenerationParams struct {
+	Model     string `json:"model"`
+	MaxTokens int    `json:"max_tokens,omitempty"`
+	Params
+}
+
+// ModelParams 是模型生效的默认生成参数
+type ModelParams struct {
+	MaxTokens   int      `json:"max_tokens"`
+	Temperature float64  `json:"temperature"`
+	TopP        float64  `json:"top_p,omitempty"`
+	Stop        []string `json:"stop,omitempty"`
+}
+
+// ModelInfo 是可选模型的能力和状态，插件据此填充模型选择器并隐藏不支持的操作
+type ModelInfo struct {
+	Type       string      `json:"type"`
+	Provider   string      `json:"provider"`
+	Local      bool        `json:"local"`
+	Streaming  bool        `json:"streaming"`
+	Tools      bool        `json:"tools"`
+	Vision     bool        `json:"vision"`
+	JSONMode   bool        `json:"json_mode"`
+	Seed       bool        `json:"seed"`
+	MaxContext int         `json:"max_context"`
+	Params     ModelParams `json:"params"`
+	Current    bool        `json:"current"`
+	Available  bool        `json:"available"`
+}
+
+// GenerateRequest 是生成请求
+type GenerateRequest struct {
+	Prompt   string `json:"prompt"`
+	N        int    `json:"n,omitempty"`        // 候选回复数
+	ID       string `json:"id,omitempty"`       // 为空时由服务生成，用于 CancelGeneration
+	Override bool   `json:"override,omitempty"` // 跳过输出过滤
+	Defer    bool   `json:"defer,omitempty"`    // 离线时排队到恢复联网后执行
+	Params
+}
+
+// Finding 是输出过滤命中的规则
+type Finding struct {
+	Rule        string `json:"rule"`
+	Description string `json:"description"`
+	Match       string `json:"match"`
+}
+
+// Redaction 是提示词中被脱敏的疑似密钥
+type Redaction struct {
+	Rule    string `json:"rule"`
+	Line    int    `json:"line"`
+	Preview string `json:"preview"`
+}
+
+// GenerateResponse 是生成结果。Canceled 为 true 时 Response 是取消前已生成的部分，
+// Deferred 不为 nil 时请求已排队，其余字段为空
+type GenerateResponse struct {
+	ID                string              `json:"id"`
+	Response          string              `json:"response"`
+	Params            *GenerationParams   `json:"params,omitempty"`
+	Alternatives      []string            `json:"alternatives,omitempty"`
+	AlternativeParams []*GenerationParams `json:"alternative_params,omitempty"`
+	Findings          []Finding           `json:"findings,omitempty"`
+	Redactions        []Redaction         `json:"redactions,omitempty"`
+	Canceled          bool                `json:"canceled,omitempty"`
+	Deferred          *DeferredItem       `json:"deferred,omitempty"`
+}
+
+// BatchPrompt 是批量生成中的一个提示词，ID 为空时使用其在批次中的序号
+type BatchPrompt struct {
+	ID     string `json:"id,omitempty"`
+	Prompt string `json:"prompt"`
+}
+
+// BatchRequest 是批量生成请求，采样参数对所有提示词生效
+type BatchRequest struct {
+	Prompts  []BatchPrompt `json:"prompts"`
+	Override bool          `json:"override,omitempty"` // 跳过输出过滤
+	Params
+}
+
+// BatchResult 是批量生成中一个提示词的结果，Error 不为空时该提示词失败或被输出过滤拦截
+type BatchResult struct {
+	ID         string      `json:"id"`
+	Response   string      `json:"response,omitempty"`
+	Error      string      `json:"error,omitempty"`
+	Findings   []Finding   `json:"findings,omitempty"`
+	Redactions []Redaction `json:"redactions,omitempty"`
+}
+
+// DeferredItem 是离线时排队的生成请求
+type DeferredItem struct {
+	ID          string    `json:"id"`
+	Prompt      string    `json:"prompt"`
+	Status      string    `json:"status"`
+	Response    string    `json:"response,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	CompletedAt time.Time `json:"completed_at,omitempty"`
+}
+
+// GenerationRecord 是生成请求的状态，取消时 Partial 为已生成的部分
+type GenerationRecord struct {
+	ID        string    `json:"id"`
+	Kind      string    `json:"kind"` // generate 或 conversation
+	Status    string    `json:"status"`
+	Partial   string    `json:"partial,omitempty"`
+	StartedAt time.Time `json:"started_at"`
+	EndedAt   time.Time `json:"ended_at,omitempty"`
+}
+
+// CompletionRequest 是行内补全请求，可以发送光标前后的内容，也可以发送整个缓冲区和光标位置
+type CompletionRequest struct {
+	Path     string `json:"path"`
+	Prefix   string `json:"prefix,omitempty"`
+	Suffix   string `json:"suffix,omitempty"`
+	Language string `json:"language,omitempty"`
+	Content  string `json:"content,omitempty"`
+	Line     int    `json:"line,omitempty"`
+	Column   int    `json:"column,omitempty"`
+}
+
+// Completion 是行内补全结果
+type Completion struct {
+	ID     string `json:"id"`
+	Text   string `json:"text"`
+	Cached bool   `json:"cached"`
+}
+
+// CommandResult 是命令执行结果，时间为 Unix 秒
+type CommandResult struct {
+	ID        string `json:"id"`
+	ExitCode  int    `json:"exit_code"`
+	Stdout    string `json:"stdout"`
+	Stderr    string `json:"stderr"`
+	StartTime int64  `json:"start_time"`
+	EndTime   int64  `json:"end_time"`
+}
+
+// File 是读取的文件内容，Hash 在写入时作为 BaseHash 传回以检测期间的修改
+type File struct {
+	Content string `json:"content"`
+	Hash    string `json:"hash"`
+}
+
+// FileEdit 是文件写入请求
+type FileEdit struct {
+	Path     string `json:"path"`
+	Content  string `json:"content"`
+	BaseHash string `json:"base_hash,omitempty"`
+	Override bool   `json:"override,omitempty"` // 跳过语法检查
+}
+
+// EditResult 是文件写入结果
+type EditResult struct {
+	Path   string `json:"path"`
+	Hash   string `json:"hash"`
+	Merged bool   `json:"merged"`
+	Diff   string `json:"diff"`
+}
+
+// Task 是后台任务
+type Task struct {
+	ID          string            `json:"id"`
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Status      string            `json:"status"`
+	CreatedAt   int64             `json:"created_at"`
+	UpdatedAt   int64             `json:"updated_at"`
+	Error       string            `json:"error,omitempty"`
+	Metadata    map[string]string `json:"metadata"`
+}
+
+// Mention 是用户消息中一个 @ 引用的解析结果，Error 不为空时该引用没有加入上下文
+type Mention struct {
+	Kind      string `json:"kind,omitempty"` // file、folder、url 或 symbol
+	Value     string `json:"value"`
+	Raw       string `json:"raw"`
+	Path      string `json:"path,omitempty"`
+	StartLine int    `json:"start_line,omitempty"`
+	EndLine   int    `json:"end_line,omitempty"`
+	Tokens    int    `json:"tokens,omitempty"`
+	Truncated bool   `json:"truncated,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Message 是对话中的一条消息
+type Message struct {
+	ID        string            `json:"id"`
+	ParentID  string            `json:"parent_id,omitempty"`
+	Role      string            `json:"role"`
+	Content   string            `json:"content"`
+	Params    *GenerationParams `json:"params,omitempty"`
+	Mentions  []*Mention        `json:"mentions,omitempty"`
+	Command   string            `json:"command,omitempty"` // 用户消息由斜杠命令展开时的原始输入
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+// SlashCommand 是聊天中可用的斜杠命令，Template 和 Tool 只有一个不为空
+type SlashCommand struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description,omitempty"`
+	Template    string            `json:"template,omitempty"`
+	Tool        string            `json:"tool,omitempty"`
+	Params      map[string]string `json:"params,omitempty"`
+	Builtin     bool              `json:"builtin,omitempty"`
+}
+
+// Conversation 是一个对话，列出对话时 Messages 为空
+type Conversation struct {
+	ID        string     `json:"id"`
+	Title     string     `json:"title"`
+	Head      string     `json:"head,omitempty"`
+	Messages  []*Message `json:"messages"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// MessageRequest 是在对话中发送消息的请求
+type MessageRequest struct {
+	ParentID    string `json:"parent_id,omitempty"` // 为空时接在主线末端，指定更早的消息时创建分支
+	Content     string `json:"content"`
+	N           int    `json:"n,omitempty"`
+	Path        string `json:"path,omitempty"`         // 当前编辑的文件，其相关文件会自动加入上下文
+	RawMentions bool   `json:"raw_mentions,omitempty"` // 为 true 时服务端不解析 @ 引用
+	Params
+}
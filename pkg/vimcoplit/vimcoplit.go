@@ -0,0 +1,102 @@
+// Package vimcoplit 在当前进程中嵌入 vimcoplit 服务，不需要单独启动服务器。
+// 嵌入的服务通过 pkg/client 的客户端访问，类型与 HTTP API 相同
+package vimcoplit
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/liangsj/vimcoplit/internal/api"
+	"github.com/liangsj/vimcoplit/internal/config"
+	"github.com/liangsj/vimcoplit/internal/core"
+	"github.com/liangsj/vimcoplit/pkg/client"
+)
+
+// Option 配置嵌入的服务，没有指定的配置项使用配置文件中的值
+type Option func(*options)
+
+type options struct {
+	configPath string
+	workspace  string
+	dataDir    string
+	settings   [][2]string
+}
+
+// WithConfigPath 设置配置文件路径，默认使用 ~/.vimcoplit/config.json
+func WithConfigPath(path string) Option {
+	return func(o *options) { o.configPath = path }
+}
+
+// WithWorkspace 设置工作区根目录
+func WithWorkspace(root string) Option {
+	return func(o *options) { o.workspace = root }
+}
+
+// WithDataDir 设置持久化数据目录
+func WithDataDir(dir string) Option {
+	return func(o *options) { o.dataDir = dir }
+}
+
+// WithSetting 覆盖一个配置项，key 为以 . 连接的 JSON 字段名，例如 model.type，
+// 列表类型的值以逗号分隔
+func WithSetting(key, value string) Option {
+	return func(o *options) { o.settings = append(o.settings, [2]string{key, value}) }
+}
+
+// Assistant 是在进程内运行的服务。每个 Assistant 使用独立的配置，
+// 同一进程中可以创建多个使用不同工作区和数据目录的 Assistant
+type Assistant struct {
+	handler *api.Handler
+	client  *client.Client
+}
+
+// New 加载配置并启动服务，恢复上次退出时的持久化状态
+func New(ctx context.Context, opts ...Option) (*Assistant, error) {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	cfg, err := config.LoadConfig(o.configPath)
+	if err != nil {
+		return nil, err
+	}
+	if o.workspace != "" {
+		o.settings = append([][2]string{{"workspace.root", o.workspace}}, o.settings...)
+	}
+	if o.dataDir != "" {
+		o.settings = append([][2]string{{"storage.data_dir", o.dataDir}}, o.settings...)
+	}
+	for _, kv := range o.settings {
+		if err := cfg.Set(kv[0], kv[1], "option"); err != nil {
+			return nil, err
+		}
+	}
+
+	service := core.NewService(cfg)
+	if _, err := service.Recover(ctx); err != nil {
+		service.Close()
+		return nil, fmt.Errorf("failed to recover state: %v", err)
+	}
+	handler := api.NewHandler(service)
+	return &Assistant{
+		handler: handler,
+		client:  client.New("http://vimcoplit.local", client.WithHandler(handler)),
+	}, nil
+}
+
+// Client 返回在进程内调用服务的客户端
+func (a *Assistant) Client() *client.Client {
+	return a.client
+}
+
+// Handler 返回服务的 HTTP 处理器，可以挂载到调用方自己的服务器上
+func (a *Assistant) Handler() http.Handler {
+	return a.handler
+}
+
+// Close 保存尚未落盘的数据，取消后台任务并停止 MCP 服务器
+func (a *Assistant) Close() error {
+	return a.handler.Close()
+}
@@ -0,0 +1,92 @@
+package vimcoplit
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestEmbeddedAssistant(t *testing.T) {
+	ctx := context.Background()
+	assistant, err := New(ctx,
+		WithConfigPath(filepath.Join(t.TempDir(), "config.json")),
+		WithWorkspace(t.TempDir()),
+		WithDataDir(t.TempDir()),
+	)
+	if err != nil {
+		t.Fatalf("failed to start embedded assistant: %v", err)
+	}
+	defer assistant.Close()
+
+	c := assistant.Client()
+	caps, err := c.Capabilities(ctx)
+	if err != nil || !caps.HasFeature("conversations") {
+		t.Fatalf("unexpected capabilities %+v %v", caps, err)
+	}
+
+	list, err := c.ListModels(ctx)
+	if err != nil || len(list) == 0 {
+		t.Fatalf("ListModels: %v %v", list, err)
+	}
+	current := 0
+	for _, m := range list {
+		if m.Current {
+			current++
+		}
+		if m.Provider == "" || m.MaxContext <= 0 || m.Params.MaxTokens <= 0 {
+			t.Errorf("incomplete model info %+v", m)
+		}
+	}
+	if current != 1 {
+		t.Errorf("expected exactly one current model, got %d", current)
+	}
+
+	conv, err := c.CreateConversation(ctx, "embedded")
+	if err != nil {
+		t.Fatalf("CreateConversation: %v", err)
+	}
+	got, err := c.GetConversation(ctx, conv.ID)
+	if err != nil || got.Title != "embedded" {
+		t.Errorf("unexpected conversation %+v %v", got, err)
+	}
+}
+
+func TestTwoAssistants(t *testing.T) {
+	ctx := context.Background()
+	start := func() *Assistant {
+		assistant, err := New(ctx,
+			WithConfigPath(filepath.Join(t.TempDir(), "config.json")),
+			WithWorkspace(t.TempDir()),
+			WithDataDir(t.TempDir()),
+			WithSetting("model.max_tokens", "256"),
+		)
+		if err != nil {
+			t.Fatalf("failed to start embedded assistant: %v", err)
+		}
+		t.Cleanup(func() { assistant.Close() })
+		return assistant
+	}
+	first, second := start(), start()
+
+	if _, err := first.Client().CreateConversation(ctx, "first"); err != nil {
+		t.Fatalf("CreateConversation: %v", err)
+	}
+	convs, err := second.Client().ListConversations(ctx)
+	if err != nil {
+		t.Fatalf("ListConversations: %v", err)
+	}
+	if len(convs) != 0 {
+		t.Errorf("expected second assistant to have its own data dir, got %d conversations", len(convs))
+	}
+}
+
+func TestInvalidSetting(t *testing.T) {
+	_, err := New(context.Background(),
+		WithConfigPath(filepath.Join(t.TempDir(), "config.json")),
+		WithDataDir(t.TempDir()),
+		WithSetting("model.max_tokens", "many"),
+	)
+	if err == nil {
+		t.Error("expected error for invalid setting")
+	}
+}